@@ -0,0 +1,270 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Error happens if message with such token was acknowledged already or returned to the queue
+var ErrUnknownAckToken = errors.New("unknown ack token")
+
+// Error happens if PollAck found Options.MaxInFlight messages of subscription not acknowledged yet, messages may be
+// pending
+var ErrMaxInFlight = errors.New("too many messages in flight")
+
+// AckToken identifies a message polled with PollAck, unique within a subscription
+type AckToken uint64
+
+// Message polled with PollAck waiting for acknowledgement
+// timer returns message to the queue (or moves it to dead-letter topic) when visibility timeout expires
+// backoff - message waits for redelivery (see Options.Backoff), timer returns it to the queue when the delay expires
+// lease - number of visibility timers armed for the message (see ExtendAck), timers armed before the last are ignored
+type inFlight struct {
+	it      item
+	timer   Timer
+	backoff bool
+	lease   int
+}
+
+// Fetching message for topic name (tn) and subscriber name (sn) with at-least-once semantic
+// Message stays in flight until Ack is called with returned token. If it isn't acknowledged during visibility
+// timeout it is returned to the beginning of the queue and will be polled again (unless its time-to-live expired
+// or it was delivered Options.MaxDeliveries times already, then it's moved to dead-letter topic)
+// nil, 0, nil should be returned if all messages was fetched already (ErrEmpty if WithEmptyError is used),
+// ErrMaxInFlight if Options.MaxInFlight messages are in flight
+// Poll middlewares (see Use) see the message after it was registered as in flight
+func (p *pubSub) PollAck(tn, sn string, visibility time.Duration) ([]byte, AckToken, error) {
+	if !p.interceptsPoll() {
+		it, b, token, err := p.pollAck(tn, sn, visibility)
+		if it.message == nil {
+			return nil, 0, p.empty(err)
+		}
+		return b, token, nil
+	}
+	msg, token, err := p.PollAckMsg(tn, sn, visibility)
+	if msg == nil {
+		return nil, 0, err
+	}
+	return msg.Body, token, err
+}
+
+// Fetching message with headers and metadata for topic name (tn) and subscriber name (sn) like PollAck
+func (p *pubSub) PollAckMsg(tn, sn string, visibility time.Duration) (*Message, AckToken, error) {
+	msg, token, err := p.pollAckMsg(context.Background(), tn, sn, visibility)
+	if msg == nil {
+		return nil, 0, p.empty(err)
+	}
+	return msg, token, err
+}
+
+// Fetching message like PollAckMsg, poll middlewares get (ctx) of caller (see NewCtx)
+func (p *pubSub) pollAckMsg(ctx context.Context, tn, sn string, visibility time.Duration) (*Message, AckToken, error) {
+	return p.interceptAck(ctx, tn, sn, func(_ context.Context, tn, sn string) (item, []byte, AckToken, error) {
+		return p.pollAck(tn, sn, visibility)
+	})
+}
+
+// Waiting for a message for PollAckMsg with topic name (tn) and subscriber name (sn) until it arrives or ctx is done
+// like PollMsgWait, used by Session.NextWait
+func (p *pubSub) pollAckWait(ctx context.Context, tn, sn string, visibility time.Duration) (*Message, AckToken, error) {
+	return p.interceptAck(ctx, tn, sn, func(ctx context.Context, tn, sn string) (item, []byte, AckToken, error) {
+		return p.waitAck(ctx, tn, sn, visibility)
+	})
+}
+
+// Function fetching message for PollAckMsg like pollAck
+type ackPollFunc func(ctx context.Context, tn, sn string) (item, []byte, AckToken, error)
+
+// Fetching message for PollAckMsg with (poll) wrapped by poll middlewares
+func (p *pubSub) interceptAck(ctx context.Context, tn, sn string, poll ackPollFunc) (*Message, AckToken, error) {
+	var token AckToken
+	msg, err := p.interceptPoll(ctx, tn, sn, func(ctx context.Context, tn, sn string) (*Message, error) {
+		it, b, t, err := poll(ctx, tn, sn)
+		token = t
+		return it.delivery(b), err
+	})
+	if msg == nil {
+		return nil, 0, err
+	}
+	return msg, token, err
+}
+
+// Fetching message for PollAck, see takeAck
+func (p *pubSub) pollAck(tn, sn string, visibility time.Duration) (item, []byte, AckToken, error) {
+	sub, err := p.acquireActive(tn, sn)
+	if err != nil {
+		return item{}, nil, 0, err
+	}
+	defer sub.Unlock()
+	return sub.takeAck(p.now(), visibility)
+}
+
+// Waiting for a message for pollAckWait like waitMsg, subscription with Options.MaxInFlight messages in flight
+// waits for their acknowledgement too
+func (p *pubSub) waitAck(ctx context.Context, tn, sn string, visibility time.Duration) (item, []byte, AckToken, error) {
+	var (
+		it    item
+		b     []byte
+		token AckToken
+	)
+	err := p.await(ctx, tn, sn, func(sub *subscription) (bool, error) {
+		if sub.paused {
+			return false, nil
+		}
+		var err error
+		it, b, token, err = sub.takeAck(p.now(), visibility)
+		if errors.Is(err, ErrMaxInFlight) {
+			return false, nil
+		}
+		return it.message != nil, err
+	})
+	return it, b, token, err
+}
+
+// Taking the next message at time (now) with its decoded body and registering it as in flight for (visibility)
+// Item with nil message is returned if there are no messages, ErrMaxInFlight or body of the message can't be decoded.
+// Such message is returned to the queue as a failed delivery, so it's moved to dead-letter topic after
+// Options.MaxDeliveries attempts
+func (s *subscription) takeAck(now time.Time, visibility time.Duration) (item, []byte, AckToken, error) {
+	if s.opts.MaxInFlight > 0 && len(s.inFlight) >= s.opts.MaxInFlight {
+		return item{}, nil, 0, ErrMaxInFlight
+	}
+	it, ok := s.next(now)
+	if !ok {
+		return item{}, nil, 0, nil
+	}
+	it.attempts++
+	b, err := it.body()
+	if err != nil {
+		s.log.Warn("message isn't delivered", "id", it.message, "attempts", it.attempts, "error", err)
+		s.requeue(it, true)
+		return item{}, nil, 0, err
+	}
+	s.hold(it)
+	s.lastToken++
+	token := s.lastToken
+	f := &inFlight{it: it}
+	// subscription is locked here, so callback can't run before message is registered as in flight
+	s.lease(token, f, visibility)
+	if s.inFlight == nil {
+		s.inFlight = map[AckToken]*inFlight{}
+	}
+	s.inFlight[token] = f
+	return it, b, token, nil
+}
+
+// Waking up pollers waiting for a free slot of Options.MaxInFlight after a message in flight was removed
+func (s *subscription) unblock() {
+	if s.opts.MaxInFlight > 0 && s.len() > 0 {
+		s.cond.Broadcast()
+	}
+}
+
+// Acknowledging message polled with PollAck by topic name (tn), subscriber name (sn) and token
+// ErrUnknownAckToken raises if message was acknowledged already or its visibility timeout expired
+func (p *pubSub) Ack(tn, sn string, token AckToken) error {
+	_, sub, err := p.acquireShared(tn, sn)
+	if err != nil {
+		return err
+	}
+	defer sub.Unlock()
+	f, ok := sub.inFlight[token]
+	if !ok || f.backoff {
+		return ErrUnknownAckToken
+	}
+	f.timer.Stop()
+	delete(sub.inFlight, token)
+	sub.release(f.it)
+	sub.unblock()
+	return nil
+}
+
+// Extending visibility timeout of message polled with PollAck by topic name (tn), subscriber name (sn) and token,
+// so it isn't redelivered while it's still processed. The message is redelivered if it isn't acknowledged during
+// (visibility) counted from now, it may be shorter than the rest of the current timeout
+// ErrUnknownAckToken raises if message was acknowledged already or its visibility timeout expired
+func (p *pubSub) ExtendAck(tn, sn string, token AckToken, visibility time.Duration) error {
+	_, sub, err := p.acquireShared(tn, sn)
+	if err != nil {
+		return err
+	}
+	defer sub.Unlock()
+	f, ok := sub.inFlight[token]
+	if !ok || f.backoff {
+		return ErrUnknownAckToken
+	}
+	f.timer.Stop()
+	sub.lease(token, f, visibility)
+	return nil
+}
+
+// Arming visibility timer of message in flight (f) with (token), message is redelivered if it isn't acknowledged
+// during (visibility)
+// Lock of subscription must be held by caller
+func (s *subscription) lease(token AckToken, f *inFlight, visibility time.Duration) {
+	f.lease++
+	lease := f.lease
+	f.timer = s.topic.clock.AfterFunc(visibility, func() {
+		s.Lock()
+		defer s.Unlock()
+		if s.inFlight[token] == f && !f.backoff && f.lease == lease {
+			s.redeliver(token, f, true)
+		}
+	})
+}
+
+// Returning not acknowledged message (token) for topic name (tn) and subscriber name (sn) to the queue
+// requeue - put message to the beginning of the queue, so it is polled next, otherwise to the end of the queue
+// (messages with ordering key always go to the beginning, so they stay in order)
+// Message is dropped if its time-to-live expired or moved to dead-letter topic if it was delivered
+// Options.MaxDeliveries times already, otherwise it waits for delay of Options.Backoff if it's set
+// ErrUnknownAckToken raises if message was acknowledged already or its visibility timeout expired
+func (p *pubSub) Nack(tn, sn string, token AckToken, requeue bool) error {
+	_, sub, err := p.acquireShared(tn, sn)
+	if err != nil {
+		return err
+	}
+	defer sub.Unlock()
+	f, ok := sub.inFlight[token]
+	if !ok || f.backoff {
+		return ErrUnknownAckToken
+	}
+	f.timer.Stop()
+	sub.redeliver(token, f, requeue)
+	return nil
+}
+
+// Returning not acknowledged item (it) to the beginning (front) or the end of the queue
+// Expired messages are dropped, messages delivered Options.MaxDeliveries times are moved to dead-letter topic
+func (s *subscription) requeue(it item, front bool) {
+	switch {
+	case it.expired(s.topic.clock.Now()):
+		atomic.AddUint64(&s.dropped, 1)
+		s.log.Debug("message dropped", "id", it.message, "reason", "expired")
+		s.emit(EventMessageDropped, it.message, 1, "expired")
+		s.unblock()
+	case s.opts.MaxDeliveries > 0 && it.attempts >= s.opts.MaxDeliveries:
+		s.log.Warn("message moved to dead-letter topic", "id", it.message, "attempts", it.attempts)
+		s.emit(EventDLQMove, it.message, 1, "")
+		// moved by Unlock
+		s.dead = append(s.dead, it)
+		s.unblock()
+	case front || it.Key != "":
+		s.pushFront(it)
+		s.cond.Broadcast()
+	default:
+		s.pushBack(it)
+		s.cond.Broadcast()
+	}
+}
+
+// Stopping visibility timers of all messages in flight, used when subscription is removed
+func (s *subscription) dropInFlight() {
+	for token, f := range s.inFlight {
+		f.timer.Stop()
+		delete(s.inFlight, token)
+	}
+	clear(s.busyKeys)
+}
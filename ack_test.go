@@ -0,0 +1,207 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPubSub_PollAck_Ack(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if _, _, err := lib.PollAck(tn, sn, time.Second); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	if msg, token, err := lib.PollAck(tn, sn, time.Second); err != nil || msg != nil || token != 0 {
+		t.FailNow()
+	}
+	lib.Publish(tn, []byte("message"))
+	msg, token, err := lib.PollAck(tn, sn, time.Second)
+	if err != nil || string(msg) != "message" || token == 0 {
+		t.FailNow()
+	}
+	if err := lib.Ack(tn, sn, token); err != nil {
+		t.FailNow()
+	}
+	if err := lib.Ack(tn, sn, token); err != ErrUnknownAckToken {
+		t.FailNow()
+	}
+	if err := lib.Ack(tn, "subscriber not exist id", token); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	if msg, _ := lib.Poll(tn, sn); msg != nil {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_PollAckMsg(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	if msg, token, err := lib.PollAckMsg(tn, sn, time.Second); err != nil || msg != nil || token != 0 {
+		t.FailNow()
+	}
+	id, _ := lib.PublishMsg(tn, Message{Headers: map[string]string{"type": "x"}, Body: []byte("message")})
+	msg, token, err := lib.PollAckMsg(tn, sn, time.Second)
+	if err != nil || msg.ID != id || msg.Headers["type"] != "x" || string(msg.Body) != "message" {
+		t.FailNow()
+	}
+	if err := lib.Ack(tn, sn, token); err != nil {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_PollAck_Redelivery(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	lib.Publish(tn, []byte("first"))
+	lib.Publish(tn, []byte("second"))
+	_, token, _ := lib.PollAck(tn, sn, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if err := lib.Ack(tn, sn, token); err != ErrUnknownAckToken {
+		t.FailNow()
+	}
+	// expired message goes back to the beginning of the queue
+	if msg, _ := lib.Poll(tn, sn); string(msg) != "first" {
+		t.FailNow()
+	}
+	if msg, _ := lib.Poll(tn, sn); string(msg) != "second" {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Nack(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxDeliveries: 3})
+	lib.Publish(tn, []byte("first"))
+	lib.Publish(tn, []byte("second"))
+	_, token, _ := lib.PollAck(tn, sn, time.Hour)
+	if err := lib.Nack(tn, sn, token, true); err != nil {
+		t.FailNow()
+	}
+	if err := lib.Nack(tn, sn, token, true); err != ErrUnknownAckToken {
+		t.FailNow()
+	}
+	// front
+	msg, token, _ := lib.PollAck(tn, sn, time.Hour)
+	if string(msg) != "first" {
+		t.FailNow()
+	}
+	// back
+	_ = lib.Nack(tn, sn, token, false)
+	if msg, token, _ = lib.PollAck(tn, sn, time.Hour); string(msg) != "second" {
+		t.FailNow()
+	}
+	_ = lib.Ack(tn, sn, token)
+	// third delivery of "first" exceeds MaxDeliveries
+	_, token, _ = lib.PollAck(tn, sn, time.Hour)
+	_ = lib.Nack(tn, sn, token, true)
+	if msg, _ := lib.Poll(tn, sn); msg != nil {
+		t.FailNow()
+	}
+	if msg, _ := lib.PollDLQ(tn, sn); msg == nil || string(msg.Body) != "first" {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_MaxInFlight(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxInFlight: 2})
+	for _, b := range []string{"a", "b", "c"} {
+		lib.Publish(tn, []byte(b))
+	}
+	_, first, _ := lib.PollAck(tn, sn, time.Hour)
+	_, second, _ := lib.PollAck(tn, sn, time.Hour)
+	if msg, token, err := lib.PollAck(tn, sn, time.Hour); msg != nil || token != 0 || err != ErrMaxInFlight {
+		t.Fatal(err)
+	}
+	// the limit applies to PollAck only
+	if n, _ := lib.Depth(tn, sn); n != 1 {
+		t.FailNow()
+	}
+	_ = lib.Ack(tn, sn, first)
+	if msg, _, _ := lib.PollAck(tn, sn, time.Hour); string(msg) != "c" {
+		t.FailNow()
+	}
+	_ = lib.Nack(tn, sn, second, true)
+	if n, _ := lib.InFlight(tn, sn); n != 1 {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_MaxInFlight_EmptyError(t *testing.T) {
+	lib := New(WithEmptyError(true))
+	tn, sn := "orders", "billing"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxInFlight: 1})
+	if _, _, err := lib.PollAck(tn, sn, time.Hour); err != ErrEmpty {
+		t.Fatal(err)
+	}
+	lib.Publish(tn, []byte("a"))
+	lib.Publish(tn, []byte("b"))
+	_, token, _ := lib.PollAck(tn, sn, time.Hour)
+	// "b" is pending, so the subscription isn't empty
+	if _, _, err := lib.PollAckMsg(tn, sn, time.Hour); err != ErrMaxInFlight {
+		t.Fatal(err)
+	}
+	_ = lib.Ack(tn, sn, token)
+	if msg, _, err := lib.PollAck(tn, sn, time.Hour); err != nil || string(msg) != "b" {
+		t.Fatal(string(msg), err)
+	}
+}
+
+func TestPubSub_DeliveryAttempt(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	lib.Publish(tn, []byte("message"))
+	lib.Publish(tn, []byte("message"))
+	for attempt := 1; attempt <= 3; attempt++ {
+		msg, token, _ := lib.PollAckMsg(tn, sn, time.Hour)
+		if msg == nil || msg.DeliveryAttempt != attempt {
+			t.Fatal(msg, attempt)
+		}
+		_ = lib.Nack(tn, sn, token, true)
+	}
+	// messages polled without ack aren't counted
+	if msg, _ := lib.PollMsg(tn, sn); msg == nil || msg.DeliveryAttempt != 0 {
+		t.Fatal(msg)
+	}
+}
+
+func TestPubSub_ExtendAck(t *testing.T) {
+	lib := New(WithClock(&stepClock{now: time.Unix(0, 0)}))
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	lib.Publish(tn, []byte("message"))
+	if err := lib.ExtendAck(tn, sn, 1, time.Hour); err != ErrUnknownAckToken {
+		t.FailNow()
+	}
+	_, token, _ := lib.PollAck(tn, sn, 30*time.Millisecond)
+	_ = lib.Advance(20 * time.Millisecond)
+	if err := lib.ExtendAck(tn, sn, token, 60*time.Millisecond); err != nil {
+		t.FailNow()
+	}
+	// the first timeout passed, but lease was renewed
+	_ = lib.Advance(30 * time.Millisecond)
+	if b, _ := lib.Poll(tn, sn); b != nil {
+		t.FailNow()
+	}
+	_ = lib.Advance(50 * time.Millisecond)
+	if err := lib.ExtendAck(tn, sn, token, time.Hour); err != ErrUnknownAckToken {
+		t.FailNow()
+	}
+	if b, _ := lib.Poll(tn, sn); string(b) != "message" {
+		t.FailNow()
+	}
+}
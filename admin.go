@@ -0,0 +1,195 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limit of messages kept by subscription of tail view while browser doesn't read them
+const adminTailMessages = 100
+
+// Web UI of broker: live tables of topics and subscriptions with depths and rates and a tail view of new messages
+// Paths are relative, so the handler may be mounted with http.StripPrefix:
+//
+//	http.Handle("/admin/", http.StripPrefix("/admin", ps.AdminHandler()))
+//
+// The handler has no authentication, mount it behind one or serve a facade returned by As
+type adminHandler struct {
+	ps PubSuber
+}
+
+// Web UI showing topics, subscriptions and new messages of (ps), e.g. of a PubSuber wrapping a broker
+// Brokers serve it with AdminHandler method
+func AdminHandler(ps PubSuber) http.Handler {
+	return &adminHandler{ps: ps}
+}
+
+// Web UI showing topics, subscriptions and new messages of the broker
+func (p *pubSub) AdminHandler() http.Handler {
+	return AdminHandler(p)
+}
+
+// Web UI showing topics, subscriptions and new messages of the namespace
+func (n *namespace) AdminHandler() http.Handler {
+	return AdminHandler(n)
+}
+
+// Web UI showing topics, subscriptions and new messages the principal may inspect
+func (a *authorized) AdminHandler() http.Handler {
+	return AdminHandler(a)
+}
+
+// Routing requests: GET / - dashboard page, GET /stats - counters of topics and subscriptions as JSON,
+// GET /tail?topic=tn - new messages of topic (or wildcard topic) tn as server-sent events
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(adminPage))
+	case "/stats":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Time time.Time
+			BrokerStats
+		}{time.Now(), h.ps.Stats()})
+	case "/tail":
+		h.tail(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Message of tail view
+type adminMessage struct {
+	ID          string            `json:"id"`
+	Topic       string            `json:"topic"`
+	PublishedAt time.Time         `json:"publishedAt"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body"`
+}
+
+// Streaming new messages of topic with temporary subscription removed when request is done
+// Subscription keeps up to adminTailMessages messages, the oldest ones are dropped if browser is slow
+func (h *adminHandler) tail(w http.ResponseWriter, r *http.Request) {
+	tn := r.URL.Query().Get("topic")
+	if tn == "" {
+		http.Error(w, "topic is required", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	sn := "admin-tail-" + newIDPrefix()
+	for _, op := range []Operation{OpSubscribe, OpPoll} {
+		if err := Authorize(h.ps, op, tn, sn); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	h.ps.SubscribeWithOptions(tn, sn, Options{MaxMessages: adminTailMessages, Overflow: DropOldest})
+	defer h.ps.Unsubscribe(tn, sn)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		msg, err := h.ps.PollMsgWait(r.Context(), tn, sn)
+		if err != nil {
+			return
+		}
+		b, _ := json.Marshal(adminMessage{
+			ID:          msg.ID,
+			Topic:       msg.Topic,
+			PublishedAt: msg.PublishedAt,
+			Headers:     msg.Headers,
+			Body:        string(msg.Body),
+		})
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// Dashboard refreshing statistics every 2 seconds, rates are computed from differences of counters
+const adminPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pubsub</title>
+<style>
+body { font-family: sans-serif; margin: 20px; color: #222; }
+table { border-collapse: collapse; margin-bottom: 20px; }
+th, td { padding: 4px 12px; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child, th:nth-child(2), td:nth-child(2) { text-align: left; }
+tr.topic td { font-weight: bold; background: #f4f4f4; }
+#tail { font-family: monospace; white-space: pre-wrap; max-height: 400px; overflow-y: auto; border: 1px solid #ddd; padding: 8px; }
+</style>
+</head>
+<body>
+<h2>Topics</h2>
+<table>
+<thead><tr><th>Topic</th><th>Subscription</th><th>Published</th><th>Publish/s</th><th>Pending</th><th>In flight</th><th>Delivered</th><th>Deliver/s</th><th>Dropped</th><th>Oldest</th><th>Bytes</th></tr></thead>
+<tbody id="stats"></tbody>
+</table>
+<h2>Tail</h2>
+<form id="form"><input id="topic" placeholder="topic or wildcard"> <button>Tail</button> <button type="button" id="stop">Stop</button></form>
+<div id="tail"></div>
+<script>
+var prev = null, source = null;
+function cell(tr, v) { var td = document.createElement('td'); td.textContent = v; tr.appendChild(td); }
+function rate(cur, old, dt) { return old === undefined || dt <= 0 ? '' : ((cur - old) / dt).toFixed(1); }
+function refresh() {
+	fetch('stats').then(function (r) { return r.json(); }).then(function (s) {
+		var now = Date.parse(s.Time), counters = {}, dt = prev ? (now - prev.time) / 1000 : 0;
+		var body = document.getElementById('stats');
+		body.textContent = '';
+		(s.Topics || []).forEach(function (t) {
+			counters[t.Name] = t.Published;
+			var tr = document.createElement('tr');
+			tr.className = 'topic';
+			[t.Name, '', t.Published, rate(t.Published, prev && prev.counters[t.Name], dt), '', '', '', '', '', '', ''].forEach(function (v) { cell(tr, v); });
+			body.appendChild(tr);
+			(t.Subscriptions || []).forEach(function (sub) {
+				var key = t.Name + '\u0000' + sub.Name;
+				counters[key] = sub.Delivered;
+				tr = document.createElement('tr');
+				[t.Name, sub.Name, '', '', sub.Pending, sub.InFlight, sub.Delivered, rate(sub.Delivered, prev && prev.counters[key], dt),
+					sub.Dropped, (sub.OldestAge / 1e9).toFixed(1) + 's', sub.Bytes].forEach(function (v) { cell(tr, v); });
+				body.appendChild(tr);
+			});
+		});
+		prev = {time: now, counters: counters};
+	});
+}
+function stop() { if (source) { source.close(); source = null; } }
+document.getElementById('stop').onclick = stop;
+document.getElementById('form').onsubmit = function (e) {
+	e.preventDefault();
+	stop();
+	var tail = document.getElementById('tail');
+	tail.textContent = '';
+	source = new EventSource('tail?topic=' + encodeURIComponent(document.getElementById('topic').value));
+	source.onmessage = function (e) {
+		var m = JSON.parse(e.data);
+		tail.textContent += m.publishedAt + ' ' + m.topic + ' ' + m.id + (m.headers ? ' ' + JSON.stringify(m.headers) : '') + '\n' + m.body + '\n\n';
+		tail.scrollTop = tail.scrollHeight;
+	};
+};
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPubSub_AdminHandler(t *testing.T) {
+	lib := New()
+	lib.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("message"))
+	srv := httptest.NewServer(http.StripPrefix("/admin", lib.AdminHandler()))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/admin/")
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.FailNow()
+	}
+	resp.Body.Close()
+	resp, err = http.Get(srv.URL + "/admin/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats BrokerStats
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if err != nil || len(stats.Topics) != 1 || stats.Topics[0].Subscriptions[0].Pending != 1 {
+		t.Fatal(err, stats)
+	}
+	if resp, _ := http.Post(srv.URL+"/admin/stats", "", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_AdminHandler_Tail(t *testing.T) {
+	lib := New()
+	srv := httptest.NewServer(AdminHandler(lib))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/tail?topic=orders/%2B", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if sns, _ := lib.Subscriptions("orders/+"); len(sns) != 1 {
+		t.FailNow()
+	}
+	lib.PublishMsg("orders/1", Message{Headers: map[string]string{"type": "x"}, Body: []byte("message")})
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	var msg adminMessage
+	if err != nil || json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg) != nil {
+		t.Fatal(err, line)
+	}
+	if msg.Topic != "orders/1" || msg.Body != "message" || msg.Headers["type"] != "x" {
+		t.Fatal(msg)
+	}
+	// temporary subscription is removed when request is done
+	cancel()
+	for i := 0; i < 100; i++ {
+		if sns, _ := lib.Subscriptions("orders/+"); len(sns) == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.FailNow()
+}
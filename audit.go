@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Operation of facade returned by As: who (Principal) performed operation (Op) on topic name (Topic) of broker
+// and subscription name (Subscription) and when (Time). Err is the error of Authorizer if operation was denied
+type AuditRecord struct {
+	Time         time.Time
+	Principal    any
+	Op           Operation
+	Topic        string
+	Subscription string
+	Err          error
+}
+
+// AuditSink gets records of operations, it's called synchronously by every checked call, so it must be fast
+// and safe for concurrent use
+type AuditSink interface {
+	Record(r AuditRecord)
+}
+
+// AuditSinkFunc is an adapter to use ordinary function as AuditSink
+type AuditSinkFunc func(r AuditRecord)
+
+func (f AuditSinkFunc) Record(r AuditRecord) {
+	f(r)
+}
+
+// Recording operations of facades returned by As to (sink), both allowed and denied ones, e.g. for compliance of
+// multi-team HTTP API (see pubsubhttp.Handler.Principal). OpInspect operations (listing topics, reading counters)
+// aren't recorded. Methods of broker itself aren't recorded, like they aren't checked by WithAuthorizer
+func WithAudit(sink AuditSink) Option {
+	return func(p *pubSub) {
+		p.audit = sink
+	}
+}
+
+// Recording operation (op) of (principal) on topic name (tn) and subscription name (sn) denied with (err)
+func (p *pubSub) record(op Operation, tn, sn string, principal any, err error) {
+	if p.audit == nil || op == OpInspect {
+		return
+	}
+	p.audit.Record(AuditRecord{Time: p.now(), Principal: principal, Op: op, Topic: tn, Subscription: sn, Err: err})
+}
+
+// Line of AuditWriter
+type auditLine struct {
+	Time         time.Time `json:"time"`
+	Principal    string    `json:"principal"`
+	Operation    string    `json:"operation"`
+	Topic        string    `json:"topic,omitempty"`
+	Subscription string    `json:"subscription,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// AuditSink appending records to (w) as JSON lines, principal is formatted with fmt.Sprint
+// Write errors are ignored, so w should be a file or another reliable writer
+func AuditWriter(w io.Writer) AuditSink {
+	var mux sync.Mutex
+	enc := json.NewEncoder(w)
+	return AuditSinkFunc(func(r AuditRecord) {
+		line := auditLine{
+			Time:         r.Time,
+			Principal:    fmt.Sprint(r.Principal),
+			Operation:    r.Op.String(),
+			Topic:        r.Topic,
+			Subscription: r.Subscription,
+		}
+		if r.Err != nil {
+			line.Error = r.Err.Error()
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		_ = enc.Encode(line)
+	})
+}
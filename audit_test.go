@@ -0,0 +1,65 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithAudit(t *testing.T) {
+	var records []AuditRecord
+	lib := New(WithAuthorizer(testAuthorizer()), WithAudit(AuditSinkFunc(func(r AuditRecord) {
+		records = append(records, r)
+	})))
+	alice := lib.As("alice")
+	alice.Subscribe("alice/orders", "billing")
+	alice.Publish("bob/orders", []byte("order"))
+	_ = alice.Topics()
+	lib.Publish("alice/orders", []byte("order"))
+	_, _ = alice.PurgeSubscription("alice/orders", "billing")
+	if err := Authorize(alice, OpPublish, "alice/orders", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := Authorize(alice, OpPublish, "bob/orders", ""); err != ErrForbidden {
+		t.Fatal(err)
+	}
+	want := []AuditRecord{
+		{Principal: "alice", Op: OpSubscribe, Topic: "alice/orders", Subscription: "billing"},
+		{Principal: "alice", Op: OpPublish, Topic: "bob/orders", Err: ErrForbidden},
+		{Principal: "alice", Op: OpManage, Topic: "alice/orders", Subscription: "billing"},
+		{Principal: "alice", Op: OpPublish, Topic: "bob/orders", Err: ErrForbidden},
+	}
+	if len(records) != len(want) {
+		t.Fatal(records)
+	}
+	for i, r := range records {
+		if r.Time.IsZero() {
+			t.Fatal(r)
+		}
+		r.Time = time.Time{}
+		if r != want[i] {
+			t.Fatal(i, r)
+		}
+	}
+}
+
+func TestAuditWriter(t *testing.T) {
+	var buf bytes.Buffer
+	lib := New(WithAudit(AuditWriter(&buf)))
+	lib.As(42).Subscribe("orders", "billing")
+	lib.As(42).Publish("orders", []byte("order"))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal(buf.String())
+	}
+	var line map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["principal"] != "42" || line["operation"] != "subscribe" || line["topic"] != "orders" ||
+		line["subscription"] != "billing" || line["error"] != "" || line["time"] == "" {
+		t.Fatal(line)
+	}
+}
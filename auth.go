@@ -0,0 +1,532 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"io"
+	"time"
+)
+
+// Error which Authorizer should return (or wrap) to deny an operation, transport layers turn it into
+// "forbidden" responses
+var ErrForbidden = errors.New("operation is forbidden")
+
+// Error which TokenVerifier and CertVerifier should return (or wrap) if credentials of remote client are missing or
+// invalid, transport layers turn it into "unauthenticated" responses
+var ErrUnauthenticated = errors.New("authentication failed")
+
+// Verifying bearer token of remote client for transport layers (pubsubhttp, pubsubws, pubsubgrpc),
+// returned principal is passed to Authorizer
+type TokenVerifier func(ctx context.Context, token string) (principal any, err error)
+
+// Verifying client certificate of mutual TLS connection for transport layers, the certificate is verified by TLS
+// already, returned principal is passed to Authorizer
+type CertVerifier func(cert *x509.Certificate) (principal any, err error)
+
+// Verifier of fixed tokens (tokens) mapped to principals, e.g. loaded from configuration
+func StaticTokens(tokens map[string]any) TokenVerifier {
+	return func(_ context.Context, token string) (any, error) {
+		for t, principal := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return principal, nil
+			}
+		}
+		return nil, ErrUnauthenticated
+	}
+}
+
+// Verifier using subject common name of client certificate as principal
+func CommonName(cert *x509.Certificate) (any, error) {
+	if cert.Subject.CommonName == "" {
+		return nil, ErrUnauthenticated
+	}
+	return cert.Subject.CommonName, nil
+}
+
+// Operation checked by Authorizer
+type Operation int
+
+const (
+	// OpPublish - publishing messages to topic
+	OpPublish Operation = iota
+	// OpSubscribe - adding and removing subscription of topic
+	OpSubscribe
+	// OpPoll - fetching, acknowledging and rewinding messages of subscription, polling its dead letters
+	OpPoll
+	// OpInspect - listing topics and subscriptions, reading counters, depth and pending messages without removing them
+	OpInspect
+	// OpManage - creating, configuring, purging and deleting topics; for broker-wide methods (Use, Snapshot, Restore,
+	// Close) topic name is empty (prefix of namespace for namespaces)
+	OpManage
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OpPublish:
+		return "publish"
+	case OpSubscribe:
+		return "subscribe"
+	case OpPoll:
+		return "poll"
+	case OpInspect:
+		return "inspect"
+	case OpManage:
+		return "manage"
+	}
+	return "unknown"
+}
+
+// Authorizer decides if principal may perform operation (op) on topic name (tn) and subscription name (sn)
+// sn is empty for operations on a whole topic. Topic names are names of broker: topics of namespaces have their
+// prefix (see Namespace) and wildcard subscriptions pass the pattern itself, so "#" should be allowed carefully.
+// Non-nil error denies the operation and is returned to the caller as is
+type Authorizer interface {
+	Allow(op Operation, tn, sn string, principal any) error
+}
+
+// AuthorizerFunc is an adapter to use ordinary function as Authorizer
+type AuthorizerFunc func(op Operation, tn, sn string, principal any) error
+
+func (f AuthorizerFunc) Allow(op Operation, tn, sn string, principal any) error {
+	return f(op, tn, sn, principal)
+}
+
+// Checking operations of facades returned by As with authorizer (a)
+// Methods of broker itself aren't checked, so it should be kept by trusted code only
+func WithAuthorizer(a Authorizer) Option {
+	return func(p *pubSub) {
+		p.authorizer = a
+	}
+}
+
+// Checks if facade (ps) returned by As may perform operation (op) on topic name (tn) and subscription name (sn)
+// Helps to report denial of methods which don't return errors (Subscribe, Unsubscribe, ...) before calling them.
+// Only denial is recorded to audit sink (see WithAudit), allowed operation is recorded when it's called.
+// nil is returned for any other PubSuber
+func Authorize(ps PubSuber, op Operation, tn, sn string) error {
+	if a, ok := ps.(*authorized); ok {
+		if err := a.check(op, tn, sn); err != nil {
+			a.p.record(op, a.prefix+tn, sn, a.principal, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Facade returned by As: every method is checked by Authorizer of broker before calling (next)
+// prefix - prefix of namespace added to topic names passed to Authorizer, empty for broker
+type authorized struct {
+	p         *pubSub
+	next      PubSuber
+	prefix    string
+	principal any
+}
+
+// Facade acting on behalf of (principal): every call is checked by Authorizer set by WithAuthorizer.
+// Denied methods return the error of Authorizer, methods without error result do nothing and log a warning.
+// Topics and Stats contain only topics allowed for OpInspect. Everything is allowed if there is no Authorizer
+func (p *pubSub) As(principal any) PubSuber {
+	return &authorized{p: p, next: p, principal: principal}
+}
+
+// Facade of namespace acting on behalf of (principal), Authorizer sees topic names of broker
+func (n *namespace) As(principal any) PubSuber {
+	return &authorized{p: n.p, next: n, prefix: n.prefix, principal: principal}
+}
+
+// Asking Authorizer of broker, operation is recorded to audit sink (see WithAudit)
+func (a *authorized) allow(op Operation, tn, sn string) error {
+	err := a.check(op, tn, sn)
+	a.p.record(op, a.prefix+tn, sn, a.principal, err)
+	return err
+}
+
+// Asking Authorizer of broker without recording operation, denial is logged
+func (a *authorized) check(op Operation, tn, sn string) error {
+	if a.p.authorizer == nil {
+		return nil
+	}
+	tn = a.prefix + tn
+	if err := a.p.authorizer.Allow(op, tn, sn, a.principal); err != nil {
+		a.p.log.Warn("operation is forbidden", "operation", op.String(), "topic", tn, "subscription", sn, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (a *authorized) Publish(tn string, b []byte) {
+	if a.allow(OpPublish, tn, "") == nil {
+		a.next.Publish(tn, b)
+	}
+}
+
+func (a *authorized) TryPublish(tn string, b []byte) error {
+	if err := a.allow(OpPublish, tn, ""); err != nil {
+		return err
+	}
+	return a.next.TryPublish(tn, b)
+}
+
+func (a *authorized) PublishResult(tn string, b []byte) (int, error) {
+	if err := a.allow(OpPublish, tn, ""); err != nil {
+		return 0, err
+	}
+	return a.next.PublishResult(tn, b)
+}
+
+func (a *authorized) PublishTx(fn func(tx Tx) error) error {
+	return a.next.PublishTx(func(tx Tx) error { return fn(&authorizedTx{a: a, next: tx}) })
+}
+
+// Transaction checking every message with Authorizer, denied message fails the transaction if fn returns the error
+type authorizedTx struct {
+	a    *authorized
+	next Tx
+}
+
+func (t *authorizedTx) Publish(tn string, b []byte) error {
+	if err := t.a.allow(OpPublish, tn, ""); err != nil {
+		return err
+	}
+	return t.next.Publish(tn, b)
+}
+
+func (t *authorizedTx) PublishMsg(tn string, msg Message) (string, error) {
+	if err := t.a.allow(OpPublish, tn, ""); err != nil {
+		return "", err
+	}
+	return t.next.PublishMsg(tn, msg)
+}
+
+func (a *authorized) PublishMsg(tn string, msg Message) (string, error) {
+	if err := a.allow(OpPublish, tn, ""); err != nil {
+		return "", err
+	}
+	return a.next.PublishMsg(tn, msg)
+}
+
+func (a *authorized) PublishAfter(tn string, b []byte, delay time.Duration) {
+	if a.allow(OpPublish, tn, "") == nil {
+		a.next.PublishAfter(tn, b, delay)
+	}
+}
+
+func (a *authorized) PublishAt(tn string, b []byte, at time.Time) {
+	if a.allow(OpPublish, tn, "") == nil {
+		a.next.PublishAt(tn, b, at)
+	}
+}
+
+func (a *authorized) PublishWithPriority(tn string, b []byte, prio int) error {
+	if err := a.allow(OpPublish, tn, ""); err != nil {
+		return err
+	}
+	return a.next.PublishWithPriority(tn, b, prio)
+}
+
+func (a *authorized) PublishRetained(tn string, b []byte) error {
+	if err := a.allow(OpPublish, tn, ""); err != nil {
+		return err
+	}
+	return a.next.PublishRetained(tn, b)
+}
+
+func (a *authorized) PublishWithTTL(tn string, b []byte, ttl time.Duration) error {
+	if err := a.allow(OpPublish, tn, ""); err != nil {
+		return err
+	}
+	return a.next.PublishWithTTL(tn, b, ttl)
+}
+
+func (a *authorized) PublishWithKey(tn string, key string, b []byte) {
+	if a.allow(OpPublish, tn, "") == nil {
+		a.next.PublishWithKey(tn, key, b)
+	}
+}
+
+func (a *authorized) Subscribe(tn, sn string) {
+	if a.allow(OpSubscribe, tn, sn) == nil {
+		a.next.Subscribe(tn, sn)
+	}
+}
+
+func (a *authorized) SubscribeFrom(tn, sn string, from SeekPosition) error {
+	if err := a.allow(OpSubscribe, tn, sn); err != nil {
+		return err
+	}
+	return a.next.SubscribeFrom(tn, sn, from)
+}
+
+func (a *authorized) Seek(tn, sn string, seq uint64) error {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return err
+	}
+	return a.next.Seek(tn, sn, seq)
+}
+
+func (a *authorized) SetHistory(tn string, size int) {
+	if a.allow(OpManage, tn, "") == nil {
+		a.next.SetHistory(tn, size)
+	}
+}
+
+func (a *authorized) SubscribeWithOptions(tn, sn string, opts Options) {
+	if a.allow(OpSubscribe, tn, sn) == nil {
+		a.next.SubscribeWithOptions(tn, sn, opts)
+	}
+}
+
+func (a *authorized) SubscribeChan(tn, sn string, buf int) (<-chan []byte, error) {
+	if err := a.allow(OpSubscribe, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.SubscribeChan(tn, sn, buf)
+}
+
+func (a *authorized) SubscribeFunc(tn, sn string, fn func([]byte), opts ...HandlerOption) error {
+	if err := a.allow(OpSubscribe, tn, sn); err != nil {
+		return err
+	}
+	return a.next.SubscribeFunc(tn, sn, fn, opts...)
+}
+
+func (a *authorized) Unsubscribe(tn, sn string) {
+	if a.allow(OpSubscribe, tn, sn) == nil {
+		a.next.Unsubscribe(tn, sn)
+	}
+}
+
+func (a *authorized) PurgeSubscription(tn, sn string) (int, error) {
+	if err := a.allow(OpManage, tn, sn); err != nil {
+		return 0, err
+	}
+	return a.next.PurgeSubscription(tn, sn)
+}
+
+func (a *authorized) PurgeTopic(tn string) (int, error) {
+	if err := a.allow(OpManage, tn, ""); err != nil {
+		return 0, err
+	}
+	return a.next.PurgeTopic(tn)
+}
+
+func (a *authorized) CreateTopic(tn string, cfg TopicConfig) error {
+	if err := a.allow(OpManage, tn, ""); err != nil {
+		return err
+	}
+	return a.next.CreateTopic(tn, cfg)
+}
+
+func (a *authorized) ConfigureTopic(tn string, cfg TopicConfig) error {
+	if err := a.allow(OpManage, tn, ""); err != nil {
+		return err
+	}
+	return a.next.ConfigureTopic(tn, cfg)
+}
+
+func (a *authorized) TopicConfig(tn string) (TopicConfig, error) {
+	if err := a.allow(OpInspect, tn, ""); err != nil {
+		return TopicConfig{}, err
+	}
+	return a.next.TopicConfig(tn)
+}
+
+// Handle of topic publishing on behalf of the principal
+func (a *authorized) Topic(tn string) *Topic {
+	return NewTopic(a, tn)
+}
+
+func (a *authorized) DeleteTopic(tn string) {
+	if a.allow(OpManage, tn, "") == nil {
+		a.next.DeleteTopic(tn)
+	}
+}
+
+func (a *authorized) Poll(tn, sn string) ([]byte, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.Poll(tn, sn)
+}
+
+func (a *authorized) PollMsg(tn, sn string) (*Message, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.PollMsg(tn, sn)
+}
+
+func (a *authorized) PollN(tn, sn string, max int) ([][]byte, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.PollN(tn, sn, max)
+}
+
+func (a *authorized) PollWait(ctx context.Context, tn, sn string) ([]byte, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.PollWait(ctx, tn, sn)
+}
+
+func (a *authorized) PollMsgWait(ctx context.Context, tn, sn string) (*Message, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.PollMsgWait(ctx, tn, sn)
+}
+
+func (a *authorized) PollAck(tn, sn string, visibility time.Duration) ([]byte, AckToken, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, 0, err
+	}
+	return a.next.PollAck(tn, sn, visibility)
+}
+
+func (a *authorized) PollAckMsg(tn, sn string, visibility time.Duration) (*Message, AckToken, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, 0, err
+	}
+	return a.next.PollAckMsg(tn, sn, visibility)
+}
+
+// Waiting for a message for PollAckMsg if principal may poll the subscription
+func (a *authorized) pollAckWait(ctx context.Context, tn, sn string, visibility time.Duration) (*Message, AckToken, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, 0, err
+	}
+	// next is either broker or namespace
+	return a.next.(ackWaiter).pollAckWait(ctx, tn, sn, visibility)
+}
+
+func (a *authorized) Ack(tn, sn string, token AckToken) error {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return err
+	}
+	return a.next.Ack(tn, sn, token)
+}
+
+func (a *authorized) Nack(tn, sn string, token AckToken, requeue bool) error {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return err
+	}
+	return a.next.Nack(tn, sn, token, requeue)
+}
+
+func (a *authorized) ExtendAck(tn, sn string, token AckToken, visibility time.Duration) error {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return err
+	}
+	return a.next.ExtendAck(tn, sn, token, visibility)
+}
+
+func (a *authorized) PollDLQ(tn, sn string) (*Message, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.PollDLQ(tn, sn)
+}
+
+func (a *authorized) Redrive(tn, sn string) (int, error) {
+	if err := a.allow(OpManage, tn, sn); err != nil {
+		return 0, err
+	}
+	return a.next.Redrive(tn, sn)
+}
+
+// List of topic names allowed for OpInspect
+func (a *authorized) Topics() []string {
+	var tns []string
+	for _, tn := range a.next.Topics() {
+		if a.allow(OpInspect, tn, "") == nil {
+			tns = append(tns, tn)
+		}
+	}
+	return tns
+}
+
+func (a *authorized) Subscriptions(tn string) ([]string, error) {
+	if err := a.allow(OpInspect, tn, ""); err != nil {
+		return nil, err
+	}
+	return a.next.Subscriptions(tn)
+}
+
+func (a *authorized) Depth(tn, sn string) (int, error) {
+	if err := a.allow(OpInspect, tn, sn); err != nil {
+		return 0, err
+	}
+	return a.next.Depth(tn, sn)
+}
+
+func (a *authorized) InFlight(tn, sn string) (int, error) {
+	if err := a.allow(OpInspect, tn, sn); err != nil {
+		return 0, err
+	}
+	return a.next.InFlight(tn, sn)
+}
+
+func (a *authorized) Peek(tn, sn string) ([]byte, error) {
+	if err := a.allow(OpInspect, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.Peek(tn, sn)
+}
+
+func (a *authorized) PeekN(tn, sn string, max int) ([][]byte, error) {
+	if err := a.allow(OpInspect, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.PeekN(tn, sn, max)
+}
+
+// Counters of topics allowed for OpInspect
+func (a *authorized) Stats() BrokerStats {
+	var stats BrokerStats
+	for _, ts := range a.next.Stats().Topics {
+		if a.allow(OpInspect, ts.Name, "") == nil {
+			stats.Topics = append(stats.Topics, ts)
+		}
+	}
+	return stats
+}
+
+func (a *authorized) Use(mw Middleware) {
+	if a.allow(OpManage, "", "") == nil {
+		a.next.Use(mw)
+	}
+}
+
+func (a *authorized) Snapshot(w io.Writer) error {
+	if err := a.allow(OpManage, "", ""); err != nil {
+		return err
+	}
+	return a.next.Snapshot(w)
+}
+
+func (a *authorized) Close(ctx context.Context) error {
+	if err := a.allow(OpManage, "", ""); err != nil {
+		return err
+	}
+	return a.next.Close(ctx)
+}
+
+func (a *authorized) Restore(r io.Reader) error {
+	if err := a.allow(OpManage, "", ""); err != nil {
+		return err
+	}
+	return a.next.Restore(r)
+}
+
+// Namespace (name) acting on behalf of the same principal
+func (a *authorized) Namespace(name string) PubSuber {
+	return a.next.Namespace(name).As(a.principal)
+}
+
+// The same facade acting on behalf of another (principal)
+func (a *authorized) As(principal any) PubSuber {
+	return &authorized{p: a.p, next: a.next, prefix: a.prefix, principal: principal}
+}
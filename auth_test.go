@@ -0,0 +1,117 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Allowing principal "admin" everything and other principals only topics starting with their name
+func testAuthorizer() Authorizer {
+	return AuthorizerFunc(func(op Operation, tn, sn string, principal any) error {
+		if principal == "admin" || strings.HasPrefix(tn, principal.(string)+"/") {
+			return nil
+		}
+		return ErrForbidden
+	})
+}
+
+func TestPubSub_As(t *testing.T) {
+	lib := New(WithAuthorizer(testAuthorizer()))
+	alice, admin := lib.As("alice"), lib.As("admin")
+	sn := "subscriber/id"
+	alice.Subscribe("alice/orders", sn)
+	alice.Subscribe("bob/orders", sn)
+	if topics := lib.Topics(); !reflect.DeepEqual(topics, []string{"alice/orders"}) {
+		t.Fatal(topics)
+	}
+	if err := alice.TryPublish("alice/orders", []byte("message")); err != nil {
+		t.FailNow()
+	}
+	if err := alice.TryPublish("bob/orders", []byte("message")); err != ErrForbidden {
+		t.FailNow()
+	}
+	if _, err := alice.Poll("bob/orders", sn); err != ErrForbidden {
+		t.FailNow()
+	}
+	if err := Authorize(alice, OpSubscribe, "bob/orders", sn); err != ErrForbidden {
+		t.FailNow()
+	}
+	if err := Authorize(lib, OpSubscribe, "bob/orders", sn); err != nil {
+		t.FailNow()
+	}
+	// broker itself isn't checked
+	lib.Subscribe("bob/orders", sn)
+	if topics := alice.Topics(); !reflect.DeepEqual(topics, []string{"alice/orders"}) {
+		t.Fatal(topics)
+	}
+	if stats := alice.Stats(); len(stats.Topics) != 1 {
+		t.FailNow()
+	}
+	if b, err := admin.Poll("alice/orders", sn); err != nil || string(b) != "message" {
+		t.FailNow()
+	}
+	if err := alice.As("admin").CreateTopic("bob/events", TopicConfig{}); err != nil {
+		t.FailNow()
+	}
+}
+
+func TestNamespace_As(t *testing.T) {
+	var seen []string
+	lib := New(WithAuthorizer(AuthorizerFunc(func(op Operation, tn, sn string, principal any) error {
+		seen = append(seen, op.String()+" "+tn)
+		return nil
+	})))
+	acme := lib.Namespace("acme").As("alice")
+	acme.Subscribe("orders", "subscriber/id")
+	lib.As("alice").Namespace("acme").Publish("orders", []byte("message"))
+	if !reflect.DeepEqual(seen, []string{"subscribe $ns/acme/orders", "publish $ns/acme/orders"}) {
+		t.Fatal(seen)
+	}
+	if b, _ := acme.Poll("orders", "subscriber/id"); string(b) != "message" {
+		t.FailNow()
+	}
+	// everything is allowed without authorizer
+	if err := New().As(nil).TryPublish("orders", []byte("message")); err != nil {
+		t.FailNow()
+	}
+}
+
+func TestAuthorized_PublishTx(t *testing.T) {
+	lib := New(WithAuthorizer(testAuthorizer()))
+	alice := lib.As("alice")
+	lib.Subscribe("alice/orders", "billing")
+	// denied message fails the whole transaction
+	err := alice.PublishTx(func(tx Tx) error {
+		if err := tx.Publish("alice/orders", []byte("message")); err != nil {
+			return err
+		}
+		return tx.Publish("bob/orders", []byte("message"))
+	})
+	if err != ErrForbidden {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth("alice/orders", "billing"); depth != 0 {
+		t.FailNow()
+	}
+}
+
+func TestStaticTokens(t *testing.T) {
+	verify := StaticTokens(map[string]any{"secret": "alice"})
+	if principal, err := verify(context.Background(), "secret"); err != nil || principal != "alice" {
+		t.Fatal(principal, err)
+	}
+	if _, err := verify(context.Background(), "wrong"); err != ErrUnauthenticated {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "bob"}}
+	if principal, err := CommonName(cert); err != nil || principal != "bob" {
+		t.Fatal(principal, err)
+	}
+	if _, err := CommonName(&x509.Certificate{}); err != ErrUnauthenticated {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff returns delay before redelivery of message which was delivered (attempts) times and wasn't acknowledged
+// (see Options.Backoff), zero means redelivery without delay
+type Backoff func(attempts int) time.Duration
+
+// Backoff with the same (delay) before every redelivery
+func FixedBackoff(delay time.Duration) Backoff {
+	return func(int) time.Duration {
+		return delay
+	}
+}
+
+// Limit of ExponentialBackoff delays without max
+const MaxBackoff = 24 * time.Hour
+
+// Backoff doubling delay starting from (base) with every delivery up to (max), MaxBackoff is used if max isn't positive
+// jitter - fraction of delay subtracted randomly (from 0 to 1), so redeliveries of messages failed together spread out
+func ExponentialBackoff(base, max time.Duration, jitter float64) Backoff {
+	if max <= 0 {
+		max = MaxBackoff
+	}
+	return func(attempts int) time.Duration {
+		delay := base
+		for i := 1; i < attempts && delay > 0 && delay < max; i++ {
+			if delay > max/2 {
+				delay = max
+				break
+			}
+			delay *= 2
+		}
+		if delay > max {
+			delay = max
+		}
+		if jitter > 0 {
+			delay -= time.Duration(rand.Float64() * min(jitter, 1) * float64(delay))
+		}
+		return delay
+	}
+}
+
+// Returning message in flight (f) with (token) to the beginning (front) or the end of the queue like requeue
+// Message which is going to be redelivered waits for delay of Options.Backoff first, it stays in flight meanwhile,
+// but its token is not valid anymore
+// Lock of subscription must be held by caller
+func (s *subscription) redeliver(token AckToken, f *inFlight, front bool) {
+	if delay := s.backoff(f.it); delay > 0 {
+		f.backoff = true
+		f.timer = s.topic.clock.AfterFunc(delay, func() {
+			s.Lock()
+			defer s.Unlock()
+			if s.inFlight[token] == f {
+				delete(s.inFlight, token)
+				s.requeue(f.it, front)
+				s.release(f.it)
+			}
+		})
+		return
+	}
+	delete(s.inFlight, token)
+	s.requeue(f.it, front)
+	s.release(f.it)
+}
+
+// Delay of Options.Backoff before redelivery of item (it), zero if it isn't going to be redelivered
+func (s *subscription) backoff(it item) time.Duration {
+	switch {
+	case s.opts.Backoff == nil, it.expired(s.topic.clock.Now()):
+		return 0
+	case s.opts.MaxDeliveries > 0 && it.attempts >= s.opts.MaxDeliveries:
+		return 0
+	}
+	return s.opts.Backoff(it.attempts)
+}
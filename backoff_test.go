@@ -0,0 +1,72 @@
+package pubsub
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFixedBackoff(t *testing.T) {
+	b := FixedBackoff(time.Second)
+	if b(1) != time.Second || b(10) != time.Second {
+		t.FailNow()
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(time.Second, 10*time.Second, 0)
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 100: 10 * time.Second} {
+		if got := b(attempts); got != want {
+			t.Fatal(attempts, got)
+		}
+	}
+	// delay doesn't overflow without max
+	b = ExponentialBackoff(time.Second, 0, 0)
+	for _, attempts := range []int{40, 63, 64, 100, 1 << 30} {
+		if got := b(attempts); got != MaxBackoff {
+			t.Fatal(attempts, got)
+		}
+	}
+	b = ExponentialBackoff(time.Second, math.MaxInt64, 0)
+	if got := b(100); got != math.MaxInt64 {
+		t.Fatal(got)
+	}
+	b = ExponentialBackoff(time.Second, 0, 0.5)
+	for i := 0; i < 100; i++ {
+		if got := b(3); got <= 2*time.Second || got > 4*time.Second {
+			t.Fatal(got)
+		}
+	}
+}
+
+func TestPubSub_Backoff(t *testing.T) {
+	lib := New(WithClock(&stepClock{now: time.Unix(0, 0)}))
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{Backoff: FixedBackoff(50 * time.Millisecond), MaxDeliveries: 2})
+	lib.Publish(tn, []byte("message"))
+	_, token, _ := lib.PollAck(tn, sn, time.Hour)
+	if err := lib.Nack(tn, sn, token, true); err != nil {
+		t.FailNow()
+	}
+	// message waits for redelivery in flight, its token is not valid anymore
+	if b, _ := lib.Poll(tn, sn); b != nil {
+		t.FailNow()
+	}
+	if n, _ := lib.InFlight(tn, sn); n != 1 {
+		t.FailNow()
+	}
+	if err := lib.Ack(tn, sn, token); err != ErrUnknownAckToken {
+		t.FailNow()
+	}
+	_ = lib.Advance(100 * time.Millisecond)
+	// the last delivery is moved to dead-letter topic without delay
+	_, token, _ = lib.PollAck(tn, sn, 10*time.Millisecond)
+	if token == 0 {
+		t.FailNow()
+	}
+	_ = lib.Advance(50 * time.Millisecond)
+	if msg, _ := lib.PollDLQ(tn, sn); msg == nil || string(msg.Body) != "message" {
+		t.FailNow()
+	}
+}
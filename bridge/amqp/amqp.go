@@ -0,0 +1,263 @@
+/*
+AMQP (RabbitMQ) bridge for pubsub package, helps to migrate services off RabbitMQ gradually: Source binds a RabbitMQ
+queue to a local topic, Sink binds a local subscription to an exchange.
+
+Source acknowledges deliveries only after the broker accepted them, Sink acknowledges messages only after RabbitMQ
+confirmed them (publisher confirms), so nothing is lost when either side fails. Both reconnect with ReconnectDelay
+when the connection breaks: not acknowledged deliveries are redelivered by RabbitMQ, not confirmed messages are
+returned to the subscription.
+
+Bridge talks to RabbitMQ through small Channel interface. Adapter for github.com/rabbitmq/amqp091-go is built only
+with `amqp` build tag:
+
+	go build -tags amqp ./...
+*/
+package amqp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Headers added by Source to published messages
+const (
+	HeaderExchange   = "amqp-exchange"
+	HeaderRoutingKey = "amqp-routing-key"
+)
+
+// Defaults of Source and Sink settings
+const (
+	DefaultReconnectDelay = time.Second
+	DefaultRetryDelay     = 100 * time.Millisecond
+	DefaultPollInterval   = 100 * time.Millisecond
+	DefaultVisibility     = 30 * time.Second
+)
+
+// Error of broken or failed connection, such connection is dialed again
+var errDisconnected = errors.New("amqp connection is broken")
+
+// Message received from queue
+type Delivery struct {
+	Tag        uint64
+	MessageID  string
+	Exchange   string
+	RoutingKey string
+	Headers    map[string]string
+	Body       []byte
+}
+
+// Message published to exchange
+type Publishing struct {
+	Exchange   string
+	RoutingKey string
+	Headers    map[string]string
+	Body       []byte
+}
+
+// Channel of AMQP connection
+// Consume starts delivering messages of queue, the channel of deliveries is closed when connection breaks
+// Publish returns after the message was confirmed by RabbitMQ, error is returned if it was rejected
+// Close closes the channel and its connection
+type Channel interface {
+	Consume(ctx context.Context, queue string) (<-chan Delivery, error)
+	Ack(tag uint64) error
+	Nack(tag uint64, requeue bool) error
+	Publish(ctx context.Context, msg Publishing) error
+	Close() error
+}
+
+// Dialer opens a new connection and returns its channel, it's called again after connection breaks
+type Dialer func(ctx context.Context) (Channel, error)
+
+// Waiting for (d) or until ctx is done, ctx.Err() is returned in the second case
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// Source publishes messages of RabbitMQ queue into the broker
+// ReconnectDelay - delay before dialing again after connection broke, DefaultReconnectDelay is used if zero
+// RetryDelay - delay before publishing again if broker rejected message as full or rate limited, DefaultRetryDelay
+// is used if zero
+type Source struct {
+	ps             pubsub.PubSuber
+	dial           Dialer
+	queue, tn      string
+	ReconnectDelay time.Duration
+	RetryDelay     time.Duration
+}
+
+// Constructor. Creates Source publishing messages of queue into broker (ps) topic name (tn)
+func NewSource(ps pubsub.PubSuber, dial Dialer, queue, tn string) *Source {
+	return &Source{ps: ps, dial: dial, queue: queue, tn: tn}
+}
+
+// Publishing messages until ctx is done or broker fails, ctx.Err() is returned in the first case
+// Broken connections are dialed again
+func (s *Source) Run(ctx context.Context) error {
+	reconnect := s.ReconnectDelay
+	if reconnect <= 0 {
+		reconnect = DefaultReconnectDelay
+	}
+	for {
+		err := s.consume(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !errors.Is(err, errDisconnected) {
+			return err
+		}
+		if err := sleep(ctx, reconnect); err != nil {
+			return err
+		}
+	}
+}
+
+// Consuming queue with one connection until it breaks
+func (s *Source) consume(ctx context.Context) error {
+	ch, err := s.dial(ctx)
+	if err != nil {
+		return errDisconnected
+	}
+	defer ch.Close()
+	deliveries, err := ch.Consume(ctx, s.queue)
+	if err != nil {
+		return errDisconnected
+	}
+	for {
+		var d Delivery
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d, ok = <-deliveries:
+		}
+		if !ok {
+			return errDisconnected
+		}
+		if err := s.publish(ctx, d); err != nil {
+			_ = ch.Nack(d.Tag, true)
+			return err
+		}
+		if ch.Ack(d.Tag) != nil {
+			return errDisconnected
+		}
+	}
+}
+
+// Publishing delivery (d), rejected messages are published again after RetryDelay
+func (s *Source) publish(ctx context.Context, d Delivery) error {
+	headers := make(map[string]string, len(d.Headers)+2)
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[HeaderExchange] = d.Exchange
+	headers[HeaderRoutingKey] = d.RoutingKey
+	delay := s.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	for {
+		_, err := s.ps.PublishMsg(s.tn, pubsub.Message{ID: d.MessageID, Headers: headers, Body: d.Body})
+		if !retryable(err) {
+			return err
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Checks if publishing may succeed later
+func retryable(err error) bool {
+	return errors.Is(err, pubsub.ErrQueueFull) || errors.Is(err, pubsub.ErrRateLimited) ||
+		errors.Is(err, pubsub.ErrMemoryLimit) || errors.Is(err, pubsub.ErrQuotaExceeded)
+}
+
+// Sink publishes messages of broker subscription to RabbitMQ exchange
+// RoutingKey - routing key of published messages, topic name is used if empty
+// ReconnectDelay - delay before dialing again after connection broke, DefaultReconnectDelay is used if zero
+// PollInterval - how often subscription is polled when it's empty, DefaultPollInterval is used if zero
+// Visibility - visibility timeout of polled messages (see PollAck), DefaultVisibility is used if zero
+type Sink struct {
+	ps             pubsub.PubSuber
+	dial           Dialer
+	tn, sn         string
+	exchange       string
+	RoutingKey     string
+	ReconnectDelay time.Duration
+	PollInterval   time.Duration
+	Visibility     time.Duration
+}
+
+// Constructor. Creates Sink publishing messages of topic name (tn) and subscription name (sn) of broker (ps)
+// to exchange
+func NewSink(ps pubsub.PubSuber, dial Dialer, tn, sn, exchange string) *Sink {
+	return &Sink{ps: ps, dial: dial, tn: tn, sn: sn, exchange: exchange}
+}
+
+// Subscribing and publishing messages until ctx is done or broker fails, ctx.Err() is returned in the first case
+// Broken connections are dialed again, not confirmed messages are returned to the subscription
+func (s *Sink) Run(ctx context.Context) error {
+	s.ps.Subscribe(s.tn, s.sn)
+	reconnect := s.ReconnectDelay
+	if reconnect <= 0 {
+		reconnect = DefaultReconnectDelay
+	}
+	for {
+		err := s.forward(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !errors.Is(err, errDisconnected) {
+			return err
+		}
+		if err := sleep(ctx, reconnect); err != nil {
+			return err
+		}
+	}
+}
+
+// Forwarding messages with one connection until it breaks
+func (s *Sink) forward(ctx context.Context) error {
+	ch, err := s.dial(ctx)
+	if err != nil {
+		return errDisconnected
+	}
+	defer ch.Close()
+	visibility, interval := s.Visibility, s.PollInterval
+	if visibility <= 0 {
+		visibility = DefaultVisibility
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	key := s.RoutingKey
+	if key == "" {
+		key = s.tn
+	}
+	for {
+		b, token, err := s.ps.PollAck(s.tn, s.sn, visibility)
+		if err != nil && !errors.Is(err, pubsub.ErrEmpty) && !errors.Is(err, pubsub.ErrMaxInFlight) {
+			return err
+		}
+		if b == nil {
+			if err := sleep(ctx, interval); err != nil {
+				return err
+			}
+			continue
+		}
+		if ch.Publish(ctx, Publishing{Exchange: s.exchange, RoutingKey: key, Body: b}) != nil {
+			_ = s.ps.Nack(s.tn, s.sn, token, true)
+			return errDisconnected
+		}
+		_ = s.ps.Ack(s.tn, s.sn, token)
+	}
+}
@@ -0,0 +1,106 @@
+//go:build amqp
+
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+// Error happens if RabbitMQ didn't confirm published message
+var ErrNotConfirmed = errors.New("message is not confirmed")
+
+// Channel of amqp091 connection in confirm mode
+type channel struct {
+	conn *amqp091.Connection
+	ch   *amqp091.Channel
+}
+
+// Dialer connecting to (url) with amqp091, channels are put into confirm mode
+func Dial(url string) Dialer {
+	return func(ctx context.Context) (Channel, error) {
+		conn, err := amqp091.Dial(url)
+		if err != nil {
+			return nil, err
+		}
+		ch, err := conn.Channel()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := ch.Confirm(false); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return &channel{conn: conn, ch: ch}, nil
+	}
+}
+
+func (c *channel) Consume(ctx context.Context, queue string) (<-chan Delivery, error) {
+	msgs, err := c.ch.ConsumeWithContext(ctx, queue, "", false, false, false, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make(chan Delivery)
+	go func() {
+		defer close(deliveries)
+		for m := range msgs {
+			d := Delivery{
+				Tag:        m.DeliveryTag,
+				MessageID:  m.MessageId,
+				Exchange:   m.Exchange,
+				RoutingKey: m.RoutingKey,
+				Body:       m.Body,
+			}
+			if len(m.Headers) > 0 {
+				d.Headers = make(map[string]string, len(m.Headers))
+				for k, v := range m.Headers {
+					d.Headers[k] = fmt.Sprint(v)
+				}
+			}
+			select {
+			case deliveries <- d:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return deliveries, nil
+}
+
+func (c *channel) Ack(tag uint64) error {
+	return c.ch.Ack(tag, false)
+}
+
+func (c *channel) Nack(tag uint64, requeue bool) error {
+	return c.ch.Nack(tag, false, requeue)
+}
+
+func (c *channel) Publish(ctx context.Context, msg Publishing) error {
+	p := amqp091.Publishing{Body: msg.Body, DeliveryMode: amqp091.Persistent}
+	if len(msg.Headers) > 0 {
+		p.Headers = make(amqp091.Table, len(msg.Headers))
+		for k, v := range msg.Headers {
+			p.Headers[k] = v
+		}
+	}
+	confirm, err := c.ch.PublishWithDeferredConfirmWithContext(ctx, msg.Exchange, msg.RoutingKey, false, false, p)
+	if err != nil {
+		return err
+	}
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
+func (c *channel) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,177 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Channel of fake RabbitMQ, broken channels fail every call
+type fakeChannel struct {
+	r          *fakeRabbit
+	deliveries chan Delivery
+}
+
+// Fake RabbitMQ: one queue and a list of published messages, (failures) next dials or publishes fail
+type fakeRabbit struct {
+	mux       sync.Mutex
+	queue     []Delivery
+	acked     []uint64
+	published []string
+	failures  int
+	dials     int
+	current   *fakeChannel
+}
+
+func (r *fakeRabbit) fail() bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		return true
+	}
+	return false
+}
+
+func (r *fakeRabbit) dial(ctx context.Context) (Channel, error) {
+	r.mux.Lock()
+	r.dials++
+	r.mux.Unlock()
+	if r.fail() {
+		return nil, errors.New("connection refused")
+	}
+	return &fakeChannel{r: r}, nil
+}
+
+func (c *fakeChannel) Consume(ctx context.Context, queue string) (<-chan Delivery, error) {
+	c.r.mux.Lock()
+	defer c.r.mux.Unlock()
+	c.deliveries = make(chan Delivery, len(c.r.queue))
+	for _, d := range c.r.queue {
+		c.deliveries <- d
+	}
+	c.r.current = c
+	return c.deliveries, nil
+}
+
+func (c *fakeChannel) Ack(tag uint64) error {
+	c.r.mux.Lock()
+	defer c.r.mux.Unlock()
+	c.r.acked = append(c.r.acked, tag)
+	for i, d := range c.r.queue {
+		if d.Tag == tag {
+			c.r.queue = append(c.r.queue[:i:i], c.r.queue[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (c *fakeChannel) Nack(tag uint64, requeue bool) error { return nil }
+
+func (c *fakeChannel) Publish(ctx context.Context, msg Publishing) error {
+	if c.r.fail() {
+		return errors.New("connection reset")
+	}
+	c.r.mux.Lock()
+	defer c.r.mux.Unlock()
+	c.r.published = append(c.r.published, msg.RoutingKey+":"+string(msg.Body))
+	return nil
+}
+
+func (c *fakeChannel) Close() error { return nil }
+
+// Waiting until (cond) holds, it's checked with lock of (r) held
+func (r *fakeRabbit) waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mux.Lock()
+		ok := cond()
+		r.mux.Unlock()
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSource(t *testing.T) {
+	r := &fakeRabbit{failures: 1, queue: []Delivery{
+		{Tag: 1, MessageID: "a", RoutingKey: "orders.created", Body: []byte("first")},
+		{Tag: 2, Body: []byte("second"), Headers: map[string]string{"type": "x"}},
+	}}
+	lib := pubsub.New()
+	sn := "subscriber/id"
+	lib.Subscribe("orders", sn)
+	s := NewSource(lib, r.dial, "orders", "orders")
+	s.ReconnectDelay = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	r.waitFor(t, func() bool { return len(r.acked) == 2 })
+	// connection breaks, consuming goes on with a new one
+	r.mux.Lock()
+	close(r.current.deliveries)
+	r.queue = append(r.queue, Delivery{Tag: 3, Body: []byte("third")})
+	r.mux.Unlock()
+	r.waitFor(t, func() bool { return len(r.acked) == 3 })
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal(err)
+	}
+	msg, _ := lib.PollMsg("orders", sn)
+	if msg == nil || msg.ID != "a" || string(msg.Body) != "first" || msg.Headers[HeaderRoutingKey] != "orders.created" {
+		t.FailNow()
+	}
+	msg, _ = lib.PollMsg("orders", sn)
+	if msg == nil || msg.Headers["type"] != "x" {
+		t.FailNow()
+	}
+	if b, _ := lib.Poll("orders", sn); string(b) != "third" {
+		t.FailNow()
+	}
+	if r.dials != 3 || len(r.acked) != 3 {
+		t.Fatal(r.dials, r.acked)
+	}
+}
+
+func TestSink(t *testing.T) {
+	r := &fakeRabbit{}
+	lib := pubsub.New()
+	tn := "orders"
+	s := NewSink(lib, r.dial, tn, "amqp", "events")
+	s.ReconnectDelay, s.PollInterval = time.Millisecond, time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	// the subscription is created before dialing
+	r.waitFor(t, func() bool { return r.dials == 1 })
+	r.mux.Lock()
+	r.failures = 1
+	r.mux.Unlock()
+	lib.Publish(tn, []byte("first"))
+	lib.Publish(tn, []byte("second"))
+	r.waitFor(t, func() bool { return len(r.published) == 2 })
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal(err)
+	}
+	// not confirmed message is published again after reconnecting
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if len(r.published) != 2 || r.published[0] != "orders:first" || r.published[1] != "orders:second" || r.dials != 2 {
+		t.Fatal(r.published, r.dials)
+	}
+}
@@ -0,0 +1,247 @@
+/*
+AWS bridge for pubsub package: Source drains an SQS queue into a local topic, Sink forwards messages of a local
+subscription to an SNS topic in batches. Both keep working through AWS outages: failed calls are retried after
+RetryDelay, meanwhile messages are buffered by the local broker, so edge services don't lose them.
+
+Source deletes messages from the queue only after the broker accepted them, Sink acknowledges messages only after
+SNS accepted them (entries failed in a batch are returned to the subscription and sent again), so a message may be
+delivered twice, but isn't lost.
+
+Bridge talks to AWS through small Queue and Topic interfaces. Adapters for github.com/aws/aws-sdk-go-v2 are built
+only with `aws` build tag:
+
+	go build -tags aws ./...
+*/
+package aws
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Header added by Source to published messages
+const HeaderMessageID = "sqs-message-id"
+
+// Limits of AWS batch calls
+const (
+	MaxBatchSize = 10
+	MaxWaitTime  = 20 * time.Second
+)
+
+// Defaults of Source and Sink settings
+const (
+	DefaultWaitTime     = MaxWaitTime
+	DefaultRetryDelay   = 5 * time.Second
+	DefaultPollInterval = 100 * time.Millisecond
+	DefaultVisibility   = 30 * time.Second
+)
+
+// Message received from SQS queue
+type QueueMessage struct {
+	ID            string
+	ReceiptHandle string
+	Attributes    map[string]string
+	Body          string
+}
+
+// Queue receives and deletes messages of SQS queue
+// Receive waits up to (wait) for up to (max) messages (long polling), no messages and nil error are returned on timeout
+type Queue interface {
+	Receive(ctx context.Context, max int, wait time.Duration) ([]QueueMessage, error)
+	Delete(ctx context.Context, receiptHandles ...string) error
+}
+
+// Message published to SNS topic
+type Publishing struct {
+	Attributes map[string]string
+	Body       string
+}
+
+// Topic publishes messages to SNS topic
+// Publish returns indexes of batch entries which failed, error means that the whole batch failed
+type Topic interface {
+	Publish(ctx context.Context, batch []Publishing) (failed []int, err error)
+}
+
+// Waiting for (d) or until ctx is done, ctx.Err() is returned in the second case
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// Checks if publishing to broker may succeed later
+func retryable(err error) bool {
+	return errors.Is(err, pubsub.ErrQueueFull) || errors.Is(err, pubsub.ErrRateLimited) ||
+		errors.Is(err, pubsub.ErrMemoryLimit) || errors.Is(err, pubsub.ErrQuotaExceeded)
+}
+
+// Source drains SQS queue into the broker
+// BatchSize - up to how many messages are received at once (up to MaxBatchSize), MaxBatchSize is used if zero
+// WaitTime - long polling time of receiving (up to MaxWaitTime), DefaultWaitTime is used if zero
+// RetryDelay - delay before calling AWS again after failure and before publishing again if broker rejected message
+// as full or rate limited, DefaultRetryDelay is used if zero
+type Source struct {
+	ps         pubsub.PubSuber
+	q          Queue
+	tn         string
+	BatchSize  int
+	WaitTime   time.Duration
+	RetryDelay time.Duration
+}
+
+// Constructor. Creates Source draining queue (q) into broker (ps) topic name (tn)
+func NewSource(ps pubsub.PubSuber, q Queue, tn string) *Source {
+	return &Source{ps: ps, q: q, tn: tn}
+}
+
+// Draining the queue until ctx is done or broker fails, ctx.Err() is returned in the first case
+// Failed AWS calls are retried after RetryDelay, messages which weren't deleted are received again by SQS
+func (s *Source) Run(ctx context.Context) error {
+	batch, wait, delay := s.BatchSize, s.WaitTime, s.RetryDelay
+	if batch <= 0 || batch > MaxBatchSize {
+		batch = MaxBatchSize
+	}
+	if wait <= 0 || wait > MaxWaitTime {
+		wait = DefaultWaitTime
+	}
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	for {
+		msgs, err := s.q.Receive(ctx, batch, wait)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if err := sleep(ctx, delay); err != nil {
+				return err
+			}
+			continue
+		}
+		handles := make([]string, 0, len(msgs))
+		for _, m := range msgs {
+			if err := s.publish(ctx, m, delay); err != nil {
+				return err
+			}
+			handles = append(handles, m.ReceiptHandle)
+		}
+		if len(handles) > 0 {
+			_ = s.q.Delete(ctx, handles...)
+		}
+	}
+}
+
+// Publishing message (m), rejected messages are published again after (delay)
+func (s *Source) publish(ctx context.Context, m QueueMessage, delay time.Duration) error {
+	headers := make(map[string]string, len(m.Attributes)+1)
+	for k, v := range m.Attributes {
+		headers[k] = v
+	}
+	headers[HeaderMessageID] = m.ID
+	for {
+		_, err := s.ps.PublishMsg(s.tn, pubsub.Message{Headers: headers, Body: []byte(m.Body)})
+		if !retryable(err) {
+			return err
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Sink forwards messages of broker subscription to SNS topic in batches
+// BatchSize - up to how many messages are published at once (up to MaxBatchSize), MaxBatchSize is used if zero
+// PollInterval - how often subscription is polled when it's empty, DefaultPollInterval is used if zero
+// Visibility - visibility timeout of polled messages (see PollAck), DefaultVisibility is used if zero
+// RetryDelay - delay before publishing again after failure, DefaultRetryDelay is used if zero
+type Sink struct {
+	ps           pubsub.PubSuber
+	t            Topic
+	tn, sn       string
+	BatchSize    int
+	PollInterval time.Duration
+	Visibility   time.Duration
+	RetryDelay   time.Duration
+}
+
+// Constructor. Creates Sink forwarding messages of topic name (tn) and subscription name (sn) of broker (ps)
+// to SNS topic (t)
+func NewSink(ps pubsub.PubSuber, t Topic, tn, sn string) *Sink {
+	return &Sink{ps: ps, t: t, tn: tn, sn: sn}
+}
+
+// Subscribing and forwarding messages until ctx is done or broker fails, ctx.Err() is returned in the first case
+// Messages which weren't published are returned to the subscription and published again after RetryDelay
+func (s *Sink) Run(ctx context.Context) error {
+	s.ps.Subscribe(s.tn, s.sn)
+	batch, interval, visibility, delay := s.BatchSize, s.PollInterval, s.Visibility, s.RetryDelay
+	if batch <= 0 || batch > MaxBatchSize {
+		batch = MaxBatchSize
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if visibility <= 0 {
+		visibility = DefaultVisibility
+	}
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	msgs := make([]Publishing, 0, batch)
+	tokens := make([]pubsub.AckToken, 0, batch)
+	for ctx.Err() == nil {
+		msgs, tokens = msgs[:0], tokens[:0]
+		for len(msgs) < batch {
+			msg, token, err := s.ps.PollAckMsg(s.tn, s.sn, visibility)
+			if err != nil && !errors.Is(err, pubsub.ErrEmpty) && !errors.Is(err, pubsub.ErrMaxInFlight) {
+				s.nack(tokens)
+				return err
+			}
+			if msg == nil {
+				break
+			}
+			msgs = append(msgs, Publishing{Attributes: msg.Headers, Body: string(msg.Body)})
+			tokens = append(tokens, token)
+		}
+		if len(msgs) == 0 {
+			_ = sleep(ctx, interval)
+			continue
+		}
+		failed, err := s.t.Publish(ctx, msgs)
+		if err != nil {
+			s.nack(tokens)
+			_ = sleep(ctx, delay)
+			continue
+		}
+		// failed messages are returned to the beginning of the queue in reverse order, so their order is kept
+		slices.Sort(failed)
+		for i := len(failed) - 1; i >= 0; i-- {
+			_ = s.ps.Nack(s.tn, s.sn, tokens[failed[i]], true)
+			tokens[failed[i]] = 0
+		}
+		for _, token := range tokens {
+			if token != 0 {
+				_ = s.ps.Ack(s.tn, s.sn, token)
+			}
+		}
+		if len(failed) > 0 {
+			_ = sleep(ctx, delay)
+		}
+	}
+	return ctx.Err()
+}
+
+// Returning messages of tokens to the subscription keeping their order
+func (s *Sink) nack(tokens []pubsub.AckToken) {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		_ = s.ps.Nack(s.tn, s.sn, tokens[i], true)
+	}
+}
@@ -0,0 +1,126 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// SQS queue failing the first (failures) receives
+type fakeQueue struct {
+	mux      sync.Mutex
+	msgs     []QueueMessage
+	deleted  []string
+	failures int
+}
+
+func (q *fakeQueue) Receive(ctx context.Context, max int, wait time.Duration) ([]QueueMessage, error) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if q.failures > 0 {
+		q.failures--
+		return nil, errors.New("service unavailable")
+	}
+	msgs := q.msgs
+	if len(msgs) > max {
+		msgs = msgs[:max]
+	}
+	q.msgs = q.msgs[len(msgs):]
+	return msgs, nil
+}
+
+func (q *fakeQueue) Delete(ctx context.Context, receiptHandles ...string) error {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	q.deleted = append(q.deleted, receiptHandles...)
+	return nil
+}
+
+// SNS topic failing the whole batch (failures) times and entries of (reject) bodies once
+type fakeTopic struct {
+	mux       sync.Mutex
+	published []string
+	batches   int
+	failures  int
+	reject    map[string]bool
+}
+
+func (t *fakeTopic) Publish(ctx context.Context, batch []Publishing) ([]int, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.failures > 0 {
+		t.failures--
+		return nil, errors.New("service unavailable")
+	}
+	t.batches++
+	var failed []int
+	for i, p := range batch {
+		if t.reject[p.Body] {
+			delete(t.reject, p.Body)
+			failed = append(failed, i)
+			continue
+		}
+		t.published = append(t.published, p.Body)
+	}
+	return failed, nil
+}
+
+func TestSource(t *testing.T) {
+	q := &fakeQueue{failures: 1}
+	for i := 0; i < 12; i++ {
+		q.msgs = append(q.msgs, QueueMessage{ID: strconv.Itoa(i), ReceiptHandle: "h" + strconv.Itoa(i), Body: strconv.Itoa(i)})
+	}
+	q.msgs[0].Attributes = map[string]string{"type": "x"}
+	lib := pubsub.New()
+	sn := "subscriber/id"
+	lib.Subscribe("orders", sn)
+	s := NewSource(lib, q, "orders")
+	s.RetryDelay = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	msg, _ := lib.PollMsg("orders", sn)
+	if msg == nil || string(msg.Body) != "0" || msg.Headers["type"] != "x" || msg.Headers[HeaderMessageID] != "0" {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth("orders", sn); depth != 11 || len(q.deleted) != 12 {
+		t.FailNow()
+	}
+}
+
+func TestSink(t *testing.T) {
+	topic := &fakeTopic{failures: 1, reject: map[string]bool{"3": true}}
+	lib := pubsub.New()
+	tn := "orders"
+	s := NewSink(lib, topic, tn, "sns")
+	s.BatchSize, s.PollInterval, s.RetryDelay = 5, time.Millisecond, time.Millisecond
+	lib.Subscribe(tn, "sns")
+	for i := 0; i < 7; i++ {
+		lib.Publish(tn, []byte(strconv.Itoa(i)))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	// failed entries are sent again in order
+	expected := []string{"0", "1", "2", "4", "3", "5", "6"}
+	if len(topic.published) != len(expected) {
+		t.Fatal(topic.published)
+	}
+	for i, b := range expected {
+		if topic.published[i] != b {
+			t.Fatal(topic.published)
+		}
+	}
+	if depth, _ := lib.Depth(tn, "sns"); depth != 0 || topic.batches != 2 {
+		t.Fatal(topic.batches)
+	}
+}
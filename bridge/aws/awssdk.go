@@ -0,0 +1,100 @@
+//go:build aws
+
+package aws
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Queue backed by SQS client of aws-sdk-go-v2
+type queue struct {
+	c   *sqs.Client
+	url string
+}
+
+// Constructor. Creates Queue receiving messages of queue (url) with SQS client (c)
+func NewQueue(c *sqs.Client, url string) Queue {
+	return &queue{c: c, url: url}
+}
+
+func (q *queue) Receive(ctx context.Context, max int, wait time.Duration) ([]QueueMessage, error) {
+	out, err := q.c.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              awssdk.String(q.url),
+		MaxNumberOfMessages:   int32(max),
+		WaitTimeSeconds:       int32(wait / time.Second),
+		MessageAttributeNames: []string{"All"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]QueueMessage, len(out.Messages))
+	for i, m := range out.Messages {
+		msgs[i] = QueueMessage{
+			ID:            awssdk.ToString(m.MessageId),
+			ReceiptHandle: awssdk.ToString(m.ReceiptHandle),
+			Body:          awssdk.ToString(m.Body),
+		}
+		if len(m.MessageAttributes) > 0 {
+			msgs[i].Attributes = make(map[string]string, len(m.MessageAttributes))
+			for k, v := range m.MessageAttributes {
+				msgs[i].Attributes[k] = awssdk.ToString(v.StringValue)
+			}
+		}
+	}
+	return msgs, nil
+}
+
+func (q *queue) Delete(ctx context.Context, receiptHandles ...string) error {
+	entries := make([]sqstypes.DeleteMessageBatchRequestEntry, len(receiptHandles))
+	for i, h := range receiptHandles {
+		entries[i] = sqstypes.DeleteMessageBatchRequestEntry{Id: awssdk.String(strconv.Itoa(i)), ReceiptHandle: awssdk.String(h)}
+	}
+	_, err := q.c.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{QueueUrl: awssdk.String(q.url), Entries: entries})
+	return err
+}
+
+// Topic backed by SNS client of aws-sdk-go-v2
+type topic struct {
+	c   *sns.Client
+	arn string
+}
+
+// Constructor. Creates Topic publishing to topic (arn) with SNS client (c)
+func NewTopic(c *sns.Client, arn string) Topic {
+	return &topic{c: c, arn: arn}
+}
+
+func (t *topic) Publish(ctx context.Context, batch []Publishing) ([]int, error) {
+	entries := make([]snstypes.PublishBatchRequestEntry, len(batch))
+	for i, p := range batch {
+		entries[i] = snstypes.PublishBatchRequestEntry{Id: awssdk.String(strconv.Itoa(i)), Message: awssdk.String(p.Body)}
+		if len(p.Attributes) > 0 {
+			entries[i].MessageAttributes = make(map[string]snstypes.MessageAttributeValue, len(p.Attributes))
+			for k, v := range p.Attributes {
+				entries[i].MessageAttributes[k] = snstypes.MessageAttributeValue{
+					DataType:    awssdk.String("String"),
+					StringValue: awssdk.String(v),
+				}
+			}
+		}
+	}
+	out, err := t.c.PublishBatch(ctx, &sns.PublishBatchInput{TopicArn: awssdk.String(t.arn), PublishBatchRequestEntries: entries})
+	if err != nil {
+		return nil, err
+	}
+	failed := make([]int, 0, len(out.Failed))
+	for _, f := range out.Failed {
+		if i, err := strconv.Atoi(awssdk.ToString(f.Id)); err == nil {
+			failed = append(failed, i)
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,251 @@
+/*
+Kafka bridge for pubsub package. Source mirrors records of Kafka topics into the broker, so the broker may be used
+as a local cache in front of Kafka; Sink forwards messages of a broker subscription to Kafka.
+
+Source publishes every record with PublishMsg and commits its offset to Kafka only after the broker accepted it,
+offsets of mirrored records are tracked per partition, so records delivered again after rebalancing are skipped.
+Sink polls its subscription with PollAck and acknowledges messages only after Kafka accepted them (at-least-once).
+
+Bridge talks to Kafka through small Consumer and Producer interfaces. Adapters for github.com/segmentio/kafka-go
+are built only with `kafka` build tag:
+
+	go build -tags kafka ./...
+*/
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Headers added by Source to mirrored messages
+const (
+	HeaderKey       = "kafka-key"
+	HeaderPartition = "kafka-partition"
+	HeaderOffset    = "kafka-offset"
+)
+
+// Defaults of Source and Sink settings
+const (
+	DefaultRetryDelay   = 100 * time.Millisecond
+	DefaultPollInterval = 100 * time.Millisecond
+	DefaultBatchSize    = 100
+	DefaultVisibility   = 30 * time.Second
+)
+
+// Record of Kafka topic
+type Record struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Time      time.Time
+}
+
+// Consumer fetches records of Kafka topics and commits offsets of processed ones
+type Consumer interface {
+	Fetch(ctx context.Context) (Record, error)
+	Commit(ctx context.Context, rec Record) error
+}
+
+// Producer writes records to Kafka
+type Producer interface {
+	Write(ctx context.Context, recs ...Record) error
+}
+
+// Source mirrors records fetched by consumer into the broker
+// Topic - broker topic name for all records, Kafka topic name of record is used if empty
+// RetryDelay - delay before publishing again if broker rejected record as full or rate limited, DefaultRetryDelay
+// is used if zero
+type Source struct {
+	ps         pubsub.PubSuber
+	c          Consumer
+	Topic      string
+	RetryDelay time.Duration
+	mux        sync.Mutex
+	offsets    map[string]map[int]int64
+}
+
+// Constructor. Creates Source mirroring records of consumer (c) into broker (ps) topic name (tn)
+func NewSource(ps pubsub.PubSuber, c Consumer, tn string) *Source {
+	return &Source{ps: ps, c: c, Topic: tn, RetryDelay: DefaultRetryDelay}
+}
+
+// Mirroring records until ctx is done or an error happens, ctx.Err() is returned in the first case
+func (s *Source) Run(ctx context.Context) error {
+	for {
+		rec, err := s.c.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		if !s.mirrored(rec) {
+			if err := s.publish(ctx, rec); err != nil {
+				return err
+			}
+		}
+		if err := s.c.Commit(ctx, rec); err != nil {
+			return err
+		}
+		s.track(rec)
+	}
+}
+
+// Publishing record (rec), rejected messages are published again after RetryDelay
+func (s *Source) publish(ctx context.Context, rec Record) error {
+	tn := s.Topic
+	if tn == "" {
+		tn = rec.Topic
+	}
+	headers := make(map[string]string, len(rec.Headers)+3)
+	for k, v := range rec.Headers {
+		headers[k] = v
+	}
+	if rec.Key != nil {
+		headers[HeaderKey] = string(rec.Key)
+	}
+	headers[HeaderPartition] = strconv.Itoa(rec.Partition)
+	headers[HeaderOffset] = strconv.FormatInt(rec.Offset, 10)
+	delay := s.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	for {
+		_, err := s.ps.PublishMsg(tn, pubsub.Message{Headers: headers, Body: rec.Value})
+		if !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Checks if publishing may succeed later
+func retryable(err error) bool {
+	return errors.Is(err, pubsub.ErrQueueFull) || errors.Is(err, pubsub.ErrRateLimited) ||
+		errors.Is(err, pubsub.ErrMemoryLimit) || errors.Is(err, pubsub.ErrQuotaExceeded)
+}
+
+// Checks if record (rec) was mirrored already
+func (s *Source) mirrored(rec Record) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	next, ok := s.offsets[rec.Topic][rec.Partition]
+	return ok && rec.Offset < next
+}
+
+// Remembering offset of mirrored record (rec)
+func (s *Source) track(rec Record) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.offsets == nil {
+		s.offsets = map[string]map[int]int64{}
+	}
+	partitions, ok := s.offsets[rec.Topic]
+	if !ok {
+		partitions = map[int]int64{}
+		s.offsets[rec.Topic] = partitions
+	}
+	if rec.Offset >= partitions[rec.Partition] {
+		partitions[rec.Partition] = rec.Offset + 1
+	}
+}
+
+// Offsets of the next records to mirror by Kafka topic name and partition
+func (s *Source) Offsets() map[string]map[int]int64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	offsets := make(map[string]map[int]int64, len(s.offsets))
+	for topic, partitions := range s.offsets {
+		offsets[topic] = make(map[int]int64, len(partitions))
+		for partition, offset := range partitions {
+			offsets[topic][partition] = offset
+		}
+	}
+	return offsets
+}
+
+// Sink forwards messages of broker subscription to Kafka
+// Topic - Kafka topic name of records, producer decides if empty
+// BatchSize - up to how many messages are written at once, DefaultBatchSize is used if zero
+// PollInterval - how often subscription is polled when it's empty, DefaultPollInterval is used if zero
+// Visibility - visibility timeout of polled messages (see PollAck), DefaultVisibility is used if zero
+type Sink struct {
+	ps           pubsub.PubSuber
+	p            Producer
+	tn, sn       string
+	Topic        string
+	BatchSize    int
+	PollInterval time.Duration
+	Visibility   time.Duration
+}
+
+// Constructor. Creates Sink forwarding messages of topic name (tn) and subscription name (sn) of broker (ps)
+// to Kafka topic (topic) with producer (p)
+func NewSink(ps pubsub.PubSuber, p Producer, tn, sn, topic string) *Sink {
+	return &Sink{ps: ps, p: p, tn: tn, sn: sn, Topic: topic}
+}
+
+// Subscribing and forwarding messages until ctx is done or an error happens, ctx.Err() is returned in the first case
+// Messages which weren't written are returned to the subscription
+func (s *Sink) Run(ctx context.Context) error {
+	s.ps.Subscribe(s.tn, s.sn)
+	batch, visibility, interval := s.BatchSize, s.Visibility, s.PollInterval
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+	if visibility <= 0 {
+		visibility = DefaultVisibility
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	recs := make([]Record, 0, batch)
+	tokens := make([]pubsub.AckToken, 0, batch)
+	for {
+		recs, tokens = recs[:0], tokens[:0]
+		for len(recs) < batch {
+			b, token, err := s.ps.PollAck(s.tn, s.sn, visibility)
+			if err != nil && !errors.Is(err, pubsub.ErrEmpty) && !errors.Is(err, pubsub.ErrMaxInFlight) {
+				s.nack(tokens)
+				return err
+			}
+			if b == nil {
+				break
+			}
+			recs = append(recs, Record{Topic: s.Topic, Value: b})
+			tokens = append(tokens, token)
+		}
+		if len(recs) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+			continue
+		}
+		if err := s.p.Write(ctx, recs...); err != nil {
+			s.nack(tokens)
+			return err
+		}
+		for _, token := range tokens {
+			_ = s.ps.Ack(s.tn, s.sn, token)
+		}
+	}
+}
+
+// Returning messages of tokens to the subscription
+func (s *Sink) nack(tokens []pubsub.AckToken) {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		_ = s.ps.Nack(s.tn, s.sn, tokens[i], true)
+	}
+}
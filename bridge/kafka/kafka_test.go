@@ -0,0 +1,149 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Consumer returning records one by one, then waiting for ctx
+type fakeConsumer struct {
+	recs      []Record
+	committed []int64
+}
+
+func (c *fakeConsumer) Fetch(ctx context.Context) (Record, error) {
+	if len(c.recs) == 0 {
+		<-ctx.Done()
+		return Record{}, ctx.Err()
+	}
+	rec := c.recs[0]
+	c.recs = c.recs[1:]
+	return rec, nil
+}
+
+func (c *fakeConsumer) Commit(ctx context.Context, rec Record) error {
+	c.committed = append(c.committed, rec.Offset)
+	return nil
+}
+
+// Producer collecting records, the first (fail) writes fail
+type fakeProducer struct {
+	mux  sync.Mutex
+	recs []Record
+	fail int
+}
+
+func (p *fakeProducer) Write(ctx context.Context, recs ...Record) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.fail > 0 {
+		p.fail--
+		return errors.New("broker is not available")
+	}
+	p.recs = append(p.recs, recs...)
+	return nil
+}
+
+func (p *fakeProducer) values() []string {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	var values []string
+	for _, rec := range p.recs {
+		values = append(values, string(rec.Value))
+	}
+	return values
+}
+
+func TestSource(t *testing.T) {
+	lib := pubsub.New()
+	sn := "subscriber/id"
+	lib.Subscribe("orders", sn)
+	c := &fakeConsumer{recs: []Record{
+		{Topic: "orders", Partition: 0, Offset: 5, Key: []byte("a"), Value: []byte("first")},
+		{Topic: "orders", Partition: 1, Offset: 7, Value: []byte("second"), Headers: map[string]string{"type": "x"}},
+		// delivered again after rebalancing
+		{Topic: "orders", Partition: 0, Offset: 5, Value: []byte("first")},
+	}}
+	s := NewSource(lib, c, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	msg, _ := lib.PollMsg("orders", sn)
+	if msg == nil || string(msg.Body) != "first" || msg.Headers[HeaderKey] != "a" || msg.Headers[HeaderOffset] != "5" {
+		t.FailNow()
+	}
+	msg, _ = lib.PollMsg("orders", sn)
+	if msg == nil || msg.Headers["type"] != "x" || msg.Headers[HeaderPartition] != "1" {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth("orders", sn); depth != 0 || len(c.committed) != 3 {
+		t.FailNow()
+	}
+	if offsets := s.Offsets(); offsets["orders"][0] != 6 || offsets["orders"][1] != 8 {
+		t.Fatal(offsets)
+	}
+}
+
+func TestSource_Retry(t *testing.T) {
+	lib := pubsub.New()
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions("cache", sn, pubsub.Options{MaxMessages: 1, Overflow: pubsub.RejectPublish})
+	c := &fakeConsumer{recs: []Record{{Topic: "orders", Value: []byte("first")}, {Topic: "orders", Offset: 1, Value: []byte("second")}}}
+	s := NewSource(lib, c, "cache")
+	s.RetryDelay = time.Millisecond
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lib.Poll("cache", sn)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	if b, _ := lib.Poll("cache", sn); string(b) != "second" {
+		t.FailNow()
+	}
+}
+
+func TestSink(t *testing.T) {
+	lib := pubsub.New()
+	tn, sn := "orders", "kafka"
+	p := &fakeProducer{fail: 1}
+	s := NewSink(lib, p, tn, sn, "orders")
+	s.PollInterval = time.Millisecond
+	lib.Subscribe(tn, sn)
+	lib.Publish(tn, []byte("first"))
+	lib.Publish(tn, []byte("second"))
+	if err := s.Run(context.Background()); err == nil {
+		t.FailNow()
+	}
+	// not written messages are kept in order
+	if depth, _ := lib.Depth(tn, sn); depth != 2 {
+		t.FailNow()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	lib.Publish(tn, []byte("third"))
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal(err)
+	}
+	if values := p.values(); len(values) != 3 || values[0] != "first" || values[1] != "second" || values[2] != "third" {
+		t.Fatal(values)
+	}
+	if p.recs[0].Topic != "orders" {
+		t.FailNow()
+	}
+}
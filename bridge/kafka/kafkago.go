@@ -0,0 +1,63 @@
+//go:build kafka
+
+package kafka
+
+import (
+	"context"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Consumer reading records with kafka-go reader
+type reader struct {
+	r *kafkago.Reader
+}
+
+// Constructor. Creates Consumer backed by kafka-go reader (r), the reader must have GroupID to commit offsets
+func NewConsumer(r *kafkago.Reader) Consumer {
+	return &reader{r: r}
+}
+
+func (r *reader) Fetch(ctx context.Context) (Record, error) {
+	m, err := r.r.FetchMessage(ctx)
+	if err != nil {
+		return Record{}, err
+	}
+	rec := Record{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset, Key: m.Key, Value: m.Value, Time: m.Time}
+	if len(m.Headers) > 0 {
+		rec.Headers = make(map[string]string, len(m.Headers))
+		for _, h := range m.Headers {
+			rec.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return rec, nil
+}
+
+func (r *reader) Commit(ctx context.Context, rec Record) error {
+	return r.r.CommitMessages(ctx, kafkago.Message{Topic: rec.Topic, Partition: rec.Partition, Offset: rec.Offset})
+}
+
+// Producer writing records with kafka-go writer
+type writer struct {
+	w *kafkago.Writer
+}
+
+// Constructor. Creates Producer backed by kafka-go writer (w)
+// Topic of records is ignored if writer has its own Topic
+func NewProducer(w *kafkago.Writer) Producer {
+	return &writer{w: w}
+}
+
+func (w *writer) Write(ctx context.Context, recs ...Record) error {
+	msgs := make([]kafkago.Message, len(recs))
+	for i, rec := range recs {
+		msgs[i] = kafkago.Message{Key: rec.Key, Value: rec.Value, Time: rec.Time}
+		if w.w.Topic == "" {
+			msgs[i].Topic = rec.Topic
+		}
+		for k, v := range rec.Headers {
+			msgs[i].Headers = append(msgs[i].Headers, kafkago.Header{Key: k, Value: []byte(v)})
+		}
+	}
+	return w.w.WriteMessages(ctx, msgs...)
+}
@@ -0,0 +1,70 @@
+//go:build redis
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Client running stream commands with go-redis client
+type client struct {
+	c goredis.UniversalClient
+}
+
+// Constructor. Creates Client backed by go-redis client (c)
+func NewClient(c goredis.UniversalClient) Client {
+	return &client{c: c}
+}
+
+func (c *client) Add(ctx context.Context, stream string, values map[string]string) (string, error) {
+	args := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		args[k] = v
+	}
+	return c.c.XAdd(ctx, &goredis.XAddArgs{Stream: stream, Values: args}).Result()
+}
+
+func (c *client) CreateGroup(ctx context.Context, stream, group string) error {
+	err := c.c.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+func (c *client) ReadGroup(ctx context.Context, stream, group, consumer, id string, count int, block time.Duration) ([]Entry, error) {
+	streams, err := c.c.XReadGroup(ctx, &goredis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, id},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, s := range streams {
+		for _, m := range s.Messages {
+			e := Entry{ID: m.ID, Values: make(map[string]string, len(m.Values))}
+			for k, v := range m.Values {
+				e.Values[k] = fmt.Sprint(v)
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (c *client) Ack(ctx context.Context, stream, group string, ids ...string) error {
+	return c.c.XAck(ctx, stream, group, ids...).Err()
+}
@@ -0,0 +1,207 @@
+/*
+Redis Streams bridge for pubsub package, lets several processes share topics: Sink appends messages of a broker
+subscription to a stream, Source reads the stream with a consumer group and publishes entries into the broker.
+Every process keeps using the same PubSuber interface with its local broker.
+
+Consumer group decides how entries are shared: processes with different groups get every entry (fan-out), processes
+with the same group get every entry once (work queue). Source acknowledges entries only after the broker accepted
+them and reads its pending entries first after restart. Sink acknowledges messages only after Redis accepted them.
+Messages published by Source are marked with HeaderStream, so Sink of the same stream skips them and messages
+don't loop between processes.
+
+Bridge talks to Redis through small Client interface. Adapter for github.com/redis/go-redis/v9 is built only
+with `redis` build tag:
+
+	go build -tags redis ./...
+*/
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Headers added by Source to published messages
+const (
+	HeaderID     = "redis-id"
+	HeaderStream = "redis-stream"
+)
+
+// Fields of stream entries: body and headers with prefix
+const (
+	FieldBody         = "body"
+	FieldHeaderPrefix = "header:"
+)
+
+// Defaults of Source and Sink settings
+const (
+	DefaultRetryDelay   = 100 * time.Millisecond
+	DefaultPollInterval = 100 * time.Millisecond
+	DefaultBlock        = 5 * time.Second
+	DefaultBatchSize    = 100
+	DefaultVisibility   = 30 * time.Second
+)
+
+// Entry of Redis stream
+type Entry struct {
+	ID     string
+	Values map[string]string
+}
+
+// Client runs stream commands
+// CreateGroup creates consumer group (creating the stream if needed) reading new entries, existing group isn't an error
+// ReadGroup reads up to count entries after id (">" - new entries, "0" - pending entries of the consumer) blocking up
+// to block, no entries and nil error are returned on timeout
+type Client interface {
+	Add(ctx context.Context, stream string, values map[string]string) (string, error)
+	CreateGroup(ctx context.Context, stream, group string) error
+	ReadGroup(ctx context.Context, stream, group, consumer, id string, count int, block time.Duration) ([]Entry, error)
+	Ack(ctx context.Context, stream, group string, ids ...string) error
+}
+
+// Source publishes entries of stream into the broker
+// Topic - broker topic name for entries
+// BatchSize - up to how many entries are read at once, DefaultBatchSize is used if zero
+// Block - how long one read waits for new entries, DefaultBlock is used if zero
+// RetryDelay - delay before publishing again if broker rejected entry as full or rate limited, DefaultRetryDelay
+// is used if zero
+type Source struct {
+	ps                      pubsub.PubSuber
+	c                       Client
+	stream, group, consumer string
+	Topic                   string
+	BatchSize               int
+	Block                   time.Duration
+	RetryDelay              time.Duration
+}
+
+// Constructor. Creates Source publishing entries of stream read by consumer of group into broker (ps) topic name (tn)
+func NewSource(ps pubsub.PubSuber, c Client, stream, group, consumer, tn string) *Source {
+	return &Source{ps: ps, c: c, stream: stream, group: group, consumer: consumer, Topic: tn}
+}
+
+// Publishing entries until ctx is done or an error happens, ctx.Err() is returned in the first case
+// Pending entries of the consumer (read, but not acknowledged before) are published first
+func (s *Source) Run(ctx context.Context) error {
+	if err := s.c.CreateGroup(ctx, s.stream, s.group); err != nil {
+		return err
+	}
+	batch, block := s.BatchSize, s.Block
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+	if block <= 0 {
+		block = DefaultBlock
+	}
+	id := "0"
+	for {
+		entries, err := s.c.ReadGroup(ctx, s.stream, s.group, s.consumer, id, batch, block)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 && id == "0" {
+			id = ">"
+			continue
+		}
+		for _, e := range entries {
+			if err := s.publish(ctx, e); err != nil {
+				return err
+			}
+			if err := s.c.Ack(ctx, s.stream, s.group, e.ID); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Publishing entry (e), rejected messages are published again after RetryDelay
+func (s *Source) publish(ctx context.Context, e Entry) error {
+	headers := map[string]string{HeaderID: e.ID, HeaderStream: s.stream}
+	for k, v := range e.Values {
+		if strings.HasPrefix(k, FieldHeaderPrefix) {
+			headers[k[len(FieldHeaderPrefix):]] = v
+		}
+	}
+	delay := s.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	for {
+		_, err := s.ps.PublishMsg(s.Topic, pubsub.Message{Headers: headers, Body: []byte(e.Values[FieldBody])})
+		if !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Checks if publishing may succeed later
+func retryable(err error) bool {
+	return errors.Is(err, pubsub.ErrQueueFull) || errors.Is(err, pubsub.ErrRateLimited) ||
+		errors.Is(err, pubsub.ErrMemoryLimit) || errors.Is(err, pubsub.ErrQuotaExceeded)
+}
+
+// Sink appends messages of broker subscription to stream
+// PollInterval - how often subscription is polled when it's empty, DefaultPollInterval is used if zero
+// Visibility - visibility timeout of polled messages (see PollAck), DefaultVisibility is used if zero
+type Sink struct {
+	ps           pubsub.PubSuber
+	c            Client
+	tn, sn       string
+	stream       string
+	PollInterval time.Duration
+	Visibility   time.Duration
+}
+
+// Constructor. Creates Sink appending messages of topic name (tn) and subscription name (sn) of broker (ps)
+// to stream
+func NewSink(ps pubsub.PubSuber, c Client, tn, sn, stream string) *Sink {
+	return &Sink{ps: ps, c: c, tn: tn, sn: sn, stream: stream}
+}
+
+// Subscribing and appending messages until ctx is done or an error happens, ctx.Err() is returned in the first case
+// Messages published by Source of the same stream are skipped, messages which weren't appended are returned
+// to the subscription
+func (s *Sink) Run(ctx context.Context) error {
+	stream := s.stream
+	s.ps.SubscribeWithOptions(s.tn, s.sn, pubsub.Options{Filter: func(msg *pubsub.Message) bool {
+		return msg.Headers[HeaderStream] != stream
+	}})
+	visibility, interval := s.Visibility, s.PollInterval
+	if visibility <= 0 {
+		visibility = DefaultVisibility
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	for ctx.Err() == nil {
+		b, token, err := s.ps.PollAck(s.tn, s.sn, visibility)
+		if err != nil && !errors.Is(err, pubsub.ErrEmpty) && !errors.Is(err, pubsub.ErrMaxInFlight) {
+			return err
+		}
+		if b == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(interval):
+			}
+			continue
+		}
+		if _, err := s.c.Add(ctx, stream, map[string]string{FieldBody: string(b)}); err != nil {
+			_ = s.ps.Nack(s.tn, s.sn, token, true)
+			return err
+		}
+		_ = s.ps.Ack(s.tn, s.sn, token)
+	}
+	return ctx.Err()
+}
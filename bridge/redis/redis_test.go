@@ -0,0 +1,121 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// In-memory stream with consumer groups, enough for one consumer per group
+type fakeClient struct {
+	mux     sync.Mutex
+	entries []Entry
+	next    map[string]int
+	pending map[string][]Entry
+}
+
+func (c *fakeClient) Add(ctx context.Context, stream string, values map[string]string) (string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	id := strconv.Itoa(len(c.entries) + 1)
+	c.entries = append(c.entries, Entry{ID: id, Values: values})
+	return id, nil
+}
+
+func (c *fakeClient) CreateGroup(ctx context.Context, stream, group string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.next == nil {
+		c.next, c.pending = map[string]int{}, map[string][]Entry{}
+	}
+	if _, ok := c.next[group]; !ok {
+		c.next[group] = len(c.entries)
+	}
+	return nil
+}
+
+func (c *fakeClient) ReadGroup(ctx context.Context, stream, group, consumer, id string, count int, block time.Duration) ([]Entry, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if id == "0" {
+		return c.pending[group], nil
+	}
+	entries := c.entries[c.next[group]:]
+	if len(entries) > count {
+		entries = entries[:count]
+	}
+	c.next[group] += len(entries)
+	c.pending[group] = append(c.pending[group], entries...)
+	return entries, nil
+}
+
+func (c *fakeClient) Ack(ctx context.Context, stream, group string, ids ...string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for _, id := range ids {
+		for i, e := range c.pending[group] {
+			if e.ID == id {
+				c.pending[group] = append(c.pending[group][:i:i], c.pending[group][i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func TestSource(t *testing.T) {
+	c := &fakeClient{}
+	_ = c.CreateGroup(context.Background(), "orders", "cache")
+	c.Add(context.Background(), "orders", map[string]string{FieldBody: "first", FieldHeaderPrefix + "type": "x"})
+	// read before restart, but not acknowledged
+	c.ReadGroup(context.Background(), "orders", "cache", "a", ">", 10, 0)
+	c.Add(context.Background(), "orders", map[string]string{FieldBody: "second"})
+	lib := pubsub.New()
+	sn := "subscriber/id"
+	lib.Subscribe("orders", sn)
+	s := NewSource(lib, c, "orders", "cache", "a", "orders")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	msg, _ := lib.PollMsg("orders", sn)
+	if msg == nil || string(msg.Body) != "first" || msg.Headers["type"] != "x" || msg.Headers[HeaderID] != "1" {
+		t.FailNow()
+	}
+	if b, _ := lib.Poll("orders", sn); string(b) != "second" {
+		t.FailNow()
+	}
+	if len(c.pending["cache"]) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestSink(t *testing.T) {
+	c := &fakeClient{}
+	lib := pubsub.New()
+	tn := "orders"
+	s := NewSink(lib, c, tn, "redis", "orders")
+	s.PollInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	lib.Publish(tn, []byte("local"))
+	// messages of the stream itself don't loop back
+	lib.PublishMsg(tn, pubsub.Message{Headers: map[string]string{HeaderStream: "orders"}, Body: []byte("remote")})
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal(err)
+	}
+	if len(c.entries) != 1 || c.entries[0].Values[FieldBody] != "local" {
+		t.Fatal(c.entries)
+	}
+}
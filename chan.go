@@ -0,0 +1,67 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+)
+
+// Error happens if subscription with such name exists already
+var ErrSubscriptionExists = errors.New("subscription exists already")
+
+// Error happens if buffer size of SubscribeChan is negative
+var ErrInvalidBuffer = errors.New("invalid channel buffer size")
+
+// Subscribe to message by topic name (tn) and subscriber name (sn), messages are pushed into returned channel
+// with buffer size (buf). Channel is closed by Unsubscribe. Messages are still kept in the subscription until
+// the goroutine feeding channel takes them, so Options and Depth work as for polling subscriptions
+// ErrSubscriptionExists raises if subscription was created before, ErrInvalidBuffer if buf is negative
+func (p *pubSub) SubscribeChan(tn, sn string, buf int) (<-chan []byte, error) {
+	if buf < 0 {
+		return nil, ErrInvalidBuffer
+	}
+	ctx, err := p.subscribeCancelable(tn, sn)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []byte, buf)
+	go func() {
+		defer close(ch)
+		for {
+			msg, err := p.PollWait(ctx, tn, sn)
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Creates new subscription which returned context is cancelled by Unsubscribe
+// ErrSubscriptionExists raises if subscription was created before
+func (p *pubSub) subscribeCancelable(tn, sn string) (context.Context, error) {
+	if p.closed.Load() {
+		return nil, ErrClosed
+	}
+	subs, err := p.lockSubscribable(tn)
+	if err != nil {
+		return nil, err
+	}
+	defer subs.mux.Unlock()
+	if _, ok := subs.hm[sn]; ok {
+		return nil, ErrSubscriptionExists
+	}
+	if err := subs.admit(sn); err != nil {
+		return nil, err
+	}
+	sub := newSubscription(sn, subs)
+	ctx, cancel := context.WithCancel(context.Background())
+	sub.cancel = cancel
+	subs.insert(sub)
+	p.deliverRetained(tn, sub)
+	return ctx, nil
+}
@@ -0,0 +1,64 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPubSub_SubscribeChan(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	ch, err := lib.SubscribeChan(tn, sn, 1)
+	if err != nil {
+		t.FailNow()
+	}
+	if _, err := lib.SubscribeChan(tn, sn, 1); err != ErrSubscriptionExists {
+		t.FailNow()
+	}
+	// invalid buffer size doesn't create subscription
+	if _, err := lib.SubscribeChan(tn, "invalid", -1); err != ErrInvalidBuffer {
+		t.FailNow()
+	}
+	if _, err := lib.Depth(tn, "invalid"); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Publish(tn, []byte("a"))
+	lib.Publish(tn, []byte("b"))
+	for _, expected := range []string{"a", "b"} {
+		select {
+		case msg := <-ch:
+			if string(msg) != expected {
+				t.FailNow()
+			}
+		case <-time.After(time.Second):
+			t.FailNow()
+		}
+	}
+	lib.Unsubscribe(tn, sn)
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.FailNow()
+		}
+	case <-time.After(time.Second):
+		t.FailNow()
+	}
+}
+
+func TestPubSub_SubscribeChan_UnsubscribeBlocked(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	ch, _ := lib.SubscribeChan(tn, sn, 0)
+	// nobody reads channel, feeding goroutine is blocked on send
+	lib.Publish(tn, []byte("a"))
+	time.Sleep(10 * time.Millisecond)
+	lib.Unsubscribe(tn, sn)
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.FailNow()
+	}
+}
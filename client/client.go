@@ -0,0 +1,823 @@
+/*
+Client of pubsubhttp server implementing pubsub.PubSuber, so code written against in-memory broker works with a remote
+one. Requests failing with network errors or 429, 502, 503 and 504 responses are retried with exponential backoff.
+When the server can't be reached, the client reconnects in background: it probes the server, subscribes again
+subscriptions created through it (the server may have restarted) and sends publishes buffered meanwhile (see
+WithBuffer) in order. With WithSession polled messages stay in flight on the server until the next poll acknowledges
+them, so a client restarted with the same session ID resumes its subscriptions without losing messages.
+
+	c := client.New("http://localhost:8080", client.WithBuffer(1000))
+	defer c.Close(ctx)
+	c.Subscribe("orders", "billing")
+	err := c.TryPublish("orders", b) // buffered if the server is down
+	msg, err := c.PollMsgWait(ctx, "orders", "billing")
+
+The HTTP API carries message body and content type only, so other headers, keys, priorities and options of
+subscriptions aren't sent. Message ID is assigned by the client and sent as Idempotency-Key, so retried publishes
+aren't delivered twice to topics with pubsub.TopicConfig.DedupWindow. Methods the HTTP API has no endpoint for
+(transactions, acknowledgements, dead letters, topic administration, ...) return errors.ErrUnsupported, or do
+nothing if they return no error.
+*/
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubhttp"
+)
+
+var (
+	// Error happens if the server can't be reached after all retries and the message isn't buffered
+	ErrUnavailable = errors.New("server is unavailable")
+	// Error happens if the server is unavailable and the publish buffer is full
+	ErrBufferFull = errors.New("publish buffer is full")
+)
+
+// Request header with principal of facade returned by As, pubsubhttp.Handler.Principal may read it
+const HeaderPrincipal = "X-Pubsub-Principal"
+
+// Defaults of options
+const (
+	DefaultRetries    = 3
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+	DefaultPollWait   = 30 * time.Second
+)
+
+// Option of New
+type Option func(c *conn)
+
+// Sending requests with (client) instead of http.DefaultClient
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *conn) {
+		c.http = client
+	}
+}
+
+// Retrying failed requests (retries) times with backoff growing from (min) to (max), zero retries disable them
+func WithRetries(retries int, min, max time.Duration) Option {
+	return func(c *conn) {
+		c.retries = retries
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// Keeping up to (size) messages published while the server is unavailable, they're sent once the client reconnects
+func WithBuffer(size int) Option {
+	return func(c *conn) {
+		c.bufSize = size
+	}
+}
+
+// Polling subscriptions with session (id) of the server (see pubsubhttp), so messages stay in flight until the next
+// poll of the subscription acknowledges them, and a message whose response was lost is polled again after reconnect
+func WithSession(id string) Option {
+	return func(c *conn) {
+		c.session = id
+	}
+}
+
+// Longest wait of one poll request of PollWait methods, the server may limit it further
+func WithPollWait(d time.Duration) Option {
+	return func(c *conn) {
+		c.pollWait = d
+	}
+}
+
+// Client of pubsubhttp server, see New
+// prefix - prefix of topic names of facade returned by Namespace, principal - principal of facade returned by As
+type Client struct {
+	c         *conn
+	prefix    string
+	principal string
+}
+
+var _ pubsub.PubSuber = (*Client)(nil)
+
+// Subscription created through client
+type subKey struct {
+	tn, sn string
+}
+
+// Message waiting for the server in buffer
+type buffered struct {
+	tn        string
+	msg       pubsub.Message
+	principal string
+}
+
+// State shared by client and its facades
+// down - the server is unreachable and reconnect goroutine runs, buffer - publishes waiting for reconnect
+// subs - subscriptions to restore after reconnect with principals which created them
+// cancels - stop goroutines of SubscribeChan and SubscribeFunc, done - closed by Close
+// flushMux - serializes sending of buffer by reconnect goroutine and Close
+// acks - sequence numbers of messages of session to acknowledge by the next poll of subscription
+type conn struct {
+	base       string
+	http       *http.Client
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	bufSize    int
+	pollWait   time.Duration
+	session    string
+	acks       map[subKey]string
+	idPrefix   string
+	lastID     uint64
+	mux        sync.Mutex
+	down       bool
+	buffer     []buffered
+	flushMux   sync.Mutex
+	subs       map[subKey]string
+	cancels    map[subKey]context.CancelFunc
+	publishMW  []func(pubsub.PublishFunc) pubsub.PublishFunc
+	pollMW     []func(pubsub.PollFunc) pubsub.PollFunc
+	closed     atomic.Bool
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// Constructor. Creates client of pubsubhttp server at (base) URL, e.g. "http://localhost:8080"
+func New(base string, opts ...Option) *Client {
+	c := &conn{
+		base:       strings.TrimSuffix(base, "/"),
+		http:       http.DefaultClient,
+		retries:    DefaultRetries,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		pollWait:   DefaultPollWait,
+		idPrefix:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		subs:       map[subKey]string{},
+		cancels:    map[subKey]context.CancelFunc{},
+		acks:       map[subKey]string{},
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &Client{c: c}
+}
+
+// Name of topic (tn) on the server
+func (cl *Client) topic(tn string) string {
+	return cl.prefix + tn
+}
+
+// Response of the server
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Sending request (method, path, query, body) with retries, connection errors make client reconnect
+// Response with status other than 2xx and 404 is returned as error
+func (cl *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, header http.Header) (response, error) {
+	if cl.c.closed.Load() {
+		return response{}, pubsub.ErrClosed
+	}
+	backoff := cl.c.minBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := cl.send(ctx, method, path, query, body, header)
+		if err == nil && !retryable(resp.status) {
+			if resp.status >= 300 && resp.status != http.StatusNotFound {
+				return resp, responseError(resp)
+			}
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return response{}, ctx.Err()
+		}
+		if attempt >= cl.c.retries {
+			if err != nil || resp.status == http.StatusBadGateway || resp.status == http.StatusGatewayTimeout {
+				cl.c.disconnect()
+				return response{}, fmt.Errorf("%w: %v", ErrUnavailable, errorOf(err, resp))
+			}
+			return resp, responseError(resp)
+		}
+		select {
+		case <-ctx.Done():
+			return response{}, ctx.Err()
+		case <-cl.c.done:
+			return response{}, pubsub.ErrClosed
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, cl.c.maxBackoff)
+	}
+}
+
+// Sending request once
+func (cl *Client) send(ctx context.Context, method, path string, query url.Values, body []byte, header http.Header) (response, error) {
+	u := cl.c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		r = strings.NewReader(string(body))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return response{}, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if cl.principal != "" {
+		req.Header.Set(HeaderPrincipal, cl.principal)
+	}
+	res, err := cl.c.http.Do(req)
+	if err != nil {
+		return response{}, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return response{}, err
+	}
+	return response{status: res.StatusCode, header: res.Header, body: b}, nil
+}
+
+// Checks if request with response (status) may succeed if it's retried
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Errors of broker recognized by text of responses
+var knownErrors = []error{
+	pubsub.ErrClosed, pubsub.ErrTopicNotFound, pubsub.ErrSubscriptionNotFound, pubsub.ErrNoSubscriptions,
+	pubsub.ErrQueueFull, pubsub.ErrPaused, pubsub.ErrRateLimited,
+	pubsub.ErrMemoryLimit, pubsub.ErrQuotaExceeded, pubsub.ErrForbidden,
+}
+
+// Error of response (resp): error of broker if it's recognized, status and text otherwise
+func responseError(resp response) error {
+	text := strings.TrimSpace(string(resp.body))
+	for _, err := range knownErrors {
+		if text == err.Error() {
+			return err
+		}
+	}
+	switch resp.status {
+	case http.StatusForbidden:
+		return fmt.Errorf("%w: %s", pubsub.ErrForbidden, text)
+	case http.StatusBadRequest:
+		if strings.Contains(text, pubsub.ErrInvalidMessage.Error()) {
+			return fmt.Errorf("%w: %s", pubsub.ErrInvalidMessage, text)
+		}
+	}
+	return fmt.Errorf("%d %s: %s", resp.status, http.StatusText(resp.status), text)
+}
+
+// Error of the last attempt: transport error (err) or response (resp)
+func errorOf(err error, resp response) error {
+	if err != nil {
+		return err
+	}
+	return responseError(resp)
+}
+
+// Adding middleware (mw) called by publish and poll methods of client and its facades
+func (cl *Client) Use(mw pubsub.Middleware) {
+	cl.c.mux.Lock()
+	defer cl.c.mux.Unlock()
+	if mw.Publish != nil {
+		cl.c.publishMW = append(cl.c.publishMW, mw.Publish)
+	}
+	if mw.Poll != nil {
+		cl.c.pollMW = append(cl.c.pollMW, mw.Poll)
+	}
+}
+
+// Publishing message (msg) through middlewares, ID is assigned if it's empty
+func (cl *Client) publish(ctx context.Context, tn string, msg pubsub.Message) (string, error) {
+	if msg.ID == "" {
+		msg.ID = cl.c.idPrefix + "-" + strconv.FormatUint(atomic.AddUint64(&cl.c.lastID, 1), 10)
+	}
+	cl.c.mux.Lock()
+	fn := cl.publishRemote
+	for i := len(cl.c.publishMW) - 1; i >= 0; i-- {
+		fn = cl.c.publishMW[i](fn)
+	}
+	cl.c.mux.Unlock()
+	err := fn(ctx, tn, &msg)
+	return msg.ID, err
+}
+
+// Publishing message (msg) to the server, it's buffered if the server is down
+func (cl *Client) publishRemote(ctx context.Context, tn string, msg *pubsub.Message) error {
+	if cl.c.closed.Load() {
+		return pubsub.ErrClosed
+	}
+	if cl.c.isDown() {
+		return cl.c.enqueue(buffered{tn: cl.topic(tn), msg: *msg, principal: cl.principal})
+	}
+	err := cl.post(ctx, cl.topic(tn), *msg)
+	if errors.Is(err, ErrUnavailable) && cl.c.bufSize > 0 {
+		return cl.c.enqueue(buffered{tn: cl.topic(tn), msg: *msg, principal: cl.principal})
+	}
+	return err
+}
+
+// Sending message (msg) to topic (tn) of the server
+func (cl *Client) post(ctx context.Context, tn string, msg pubsub.Message) error {
+	header := http.Header{}
+	header.Set("Idempotency-Key", msg.ID)
+	if ct := msg.Headers[pubsub.HeaderContentType]; ct != "" {
+		header.Set("Content-Type", ct)
+	}
+	body := msg.Body
+	if body == nil {
+		body = []byte{}
+	}
+	resp, err := cl.do(ctx, http.MethodPost, "/topics/"+url.PathEscape(tn), nil, body, header)
+	if err == nil && resp.status == http.StatusNotFound {
+		return responseError(resp)
+	}
+	return err
+}
+
+func (cl *Client) Publish(tn string, b []byte) {
+	_ = cl.TryPublish(tn, b)
+}
+
+// Publishing message (b), nil error means the server accepted it or it's buffered
+func (cl *Client) TryPublish(tn string, b []byte) error {
+	_, err := cl.publish(context.Background(), tn, pubsub.Message{Body: b})
+	return err
+}
+
+// Number of subscriptions isn't reported by the server, it's 1 if message is accepted or buffered
+func (cl *Client) PublishResult(tn string, b []byte) (int, error) {
+	if err := cl.TryPublish(tn, b); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// Publishing message (msg), its body and content type header are sent
+func (cl *Client) PublishMsg(tn string, msg pubsub.Message) (string, error) {
+	return cl.publish(context.Background(), tn, msg)
+}
+
+// Message is kept by the client until its time, it's lost if the process exits
+func (cl *Client) PublishAfter(tn string, b []byte, delay time.Duration) {
+	time.AfterFunc(delay, func() { cl.Publish(tn, b) })
+}
+
+func (cl *Client) PublishAt(tn string, b []byte, at time.Time) {
+	cl.PublishAfter(tn, b, time.Until(at))
+}
+
+// Key isn't sent, the message is published as is
+func (cl *Client) PublishWithKey(tn string, key string, b []byte) {
+	cl.Publish(tn, b)
+}
+
+// Message of poll response (resp) of topic (tn)
+func pollMessage(tn string, resp response) *pubsub.Message {
+	msg := &pubsub.Message{Topic: tn, Body: resp.body}
+	if ct := resp.header.Get("Content-Type"); ct != "" && ct != "application/octet-stream" {
+		msg.Headers = map[string]string{pubsub.HeaderContentType: ct}
+	}
+	return msg
+}
+
+// Polling subscription (sn) of topic (tn) through middlewares, waiting up to (wait) for a message
+func (cl *Client) poll(ctx context.Context, tn, sn string, wait time.Duration) (*pubsub.Message, error) {
+	cl.c.mux.Lock()
+	fn := func(ctx context.Context, tn, sn string) (*pubsub.Message, error) {
+		return cl.pollRemote(ctx, tn, sn, wait)
+	}
+	for i := len(cl.c.pollMW) - 1; i >= 0; i-- {
+		fn = cl.c.pollMW[i](fn)
+	}
+	cl.c.mux.Unlock()
+	return fn(ctx, tn, sn)
+}
+
+// Polling the server, nil message is returned if there are no messages
+// With session (see WithSession) message polled before is acknowledged by the request
+func (cl *Client) pollRemote(ctx context.Context, tn, sn string, wait time.Duration) (*pubsub.Message, error) {
+	query := url.Values{"topic": {cl.topic(tn)}, "sub": {sn}}
+	if wait > 0 {
+		query.Set("wait", wait.String())
+	}
+	key := subKey{cl.topic(tn), sn}
+	if cl.c.session != "" {
+		query.Set("session", cl.c.session)
+		cl.c.mux.Lock()
+		if seq := cl.c.acks[key]; seq != "" {
+			query.Set("ack", seq)
+		}
+		cl.c.mux.Unlock()
+	}
+	resp, err := cl.do(ctx, http.MethodGet, "/poll", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cl.c.session != "" {
+		// acknowledgement is applied, so it isn't sent again to a session which may be replaced after expiration
+		cl.c.mux.Lock()
+		if seq := resp.header.Get(pubsubhttp.HeaderDelivery); seq != "" && resp.status == http.StatusOK {
+			cl.c.acks[key] = seq
+		} else {
+			delete(cl.c.acks, key)
+		}
+		cl.c.mux.Unlock()
+	}
+	if resp.status == http.StatusNotFound {
+		return nil, nil
+	}
+	return pollMessage(tn, resp), nil
+}
+
+func (cl *Client) Poll(tn, sn string) ([]byte, error) {
+	msg, err := cl.PollMsg(tn, sn)
+	if msg == nil {
+		return nil, err
+	}
+	return msg.Body, err
+}
+
+// Message has body, topic and content type header, ID isn't returned by the server
+func (cl *Client) PollMsg(tn, sn string) (*pubsub.Message, error) {
+	return cl.poll(context.Background(), tn, sn, 0)
+}
+
+// Polling up to (max) messages one request each
+func (cl *Client) PollN(tn, sn string, max int) ([][]byte, error) {
+	var msgs [][]byte
+	for len(msgs) < max {
+		b, err := cl.Poll(tn, sn)
+		if err != nil {
+			return msgs, err
+		}
+		if b == nil {
+			break
+		}
+		msgs = append(msgs, b)
+	}
+	return msgs, nil
+}
+
+func (cl *Client) PollWait(ctx context.Context, tn, sn string) ([]byte, error) {
+	msg, err := cl.PollMsgWait(ctx, tn, sn)
+	if msg == nil {
+		return nil, err
+	}
+	return msg.Body, err
+}
+
+// Long polling the server until a message arrives or ctx is done, the server is asked again when its wait ends
+func (cl *Client) PollMsgWait(ctx context.Context, tn, sn string) (*pubsub.Message, error) {
+	for {
+		wait := cl.c.pollWait
+		if deadline, ok := ctx.Deadline(); ok {
+			wait = min(wait, time.Until(deadline))
+		}
+		if wait < time.Millisecond {
+			// deadline is reached, but ctx may be not done yet
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		msg, err := cl.poll(ctx, tn, sn, wait.Round(time.Millisecond))
+		if msg != nil || err != nil {
+			return msg, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Subscribing, subscription is created again when the client reconnects
+func (cl *Client) Subscribe(tn, sn string) {
+	_ = cl.subscribe(context.Background(), cl.topic(tn), sn)
+}
+
+// Creating subscription (sn) of topic (tn) on the server and remembering it for reconnects
+func (cl *Client) subscribe(ctx context.Context, tn, sn string) error {
+	cl.c.mux.Lock()
+	cl.c.subs[subKey{tn, sn}] = cl.principal
+	cl.c.mux.Unlock()
+	body, _ := json.Marshal(map[string]string{"topic": tn, "subscription": sn})
+	header := http.Header{"Content-Type": {"application/json"}}
+	_, err := cl.do(ctx, http.MethodPost, "/subscriptions", nil, body, header)
+	return err
+}
+
+// Options aren't sent, subscription is created with options of the server
+func (cl *Client) SubscribeWithOptions(tn, sn string, opts pubsub.Options) {
+	cl.Subscribe(tn, sn)
+}
+
+// Subscribing and polling messages to returned channel until Unsubscribe or Close is called
+func (cl *Client) SubscribeChan(tn, sn string, buf int) (<-chan []byte, error) {
+	if buf < 0 {
+		return nil, pubsub.ErrInvalidBuffer
+	}
+	ctx, err := cl.subscribeCancelable(tn, sn)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []byte, buf)
+	cl.c.wg.Add(1)
+	go func() {
+		defer cl.c.wg.Done()
+		defer close(ch)
+		cl.consume(ctx, tn, sn, func(b []byte) {
+			select {
+			case ch <- b:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return ch, nil
+}
+
+// Subscribing and calling (fn) for every message by one goroutine until Unsubscribe or Close is called
+// Handler options aren't supported
+func (cl *Client) SubscribeFunc(tn, sn string, fn func([]byte), opts ...pubsub.HandlerOption) error {
+	ctx, err := cl.subscribeCancelable(tn, sn)
+	if err != nil {
+		return err
+	}
+	cl.c.wg.Add(1)
+	go func() {
+		defer cl.c.wg.Done()
+		cl.consume(ctx, tn, sn, fn)
+	}()
+	return nil
+}
+
+// Subscribing and returning context canceled by Unsubscribe and Close
+func (cl *Client) subscribeCancelable(tn, sn string) (context.Context, error) {
+	key := subKey{cl.topic(tn), sn}
+	cl.c.mux.Lock()
+	if _, ok := cl.c.cancels[key]; ok {
+		cl.c.mux.Unlock()
+		return nil, pubsub.ErrSubscriptionExists
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cl.c.cancels[key] = cancel
+	cl.c.mux.Unlock()
+	cl.Subscribe(tn, sn)
+	return ctx, nil
+}
+
+// Polling messages of subscription (sn) of topic (tn) and passing them to (fn) until ctx is done
+func (cl *Client) consume(ctx context.Context, tn, sn string, fn func([]byte)) {
+	backoff := cl.c.minBackoff
+	for ctx.Err() == nil {
+		msg, err := cl.PollMsgWait(ctx, tn, sn)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, cl.c.maxBackoff)
+			continue
+		}
+		backoff = cl.c.minBackoff
+		fn(msg.Body)
+	}
+}
+
+// Iterating over messages long polled from the server until ctx is done, requests failing after retries are yielded
+// once and end the loop like pubsub.PubSuber.Iter
+func (cl *Client) Iter(ctx context.Context, tn, sn string) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for {
+			msg, err := cl.PollMsgWait(ctx, tn, sn)
+			if ctx.Err() != nil && msg == nil {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(msg.Body, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Unsubscribing, goroutines of SubscribeChan and SubscribeFunc are stopped
+func (cl *Client) Unsubscribe(tn, sn string) {
+	key := subKey{cl.topic(tn), sn}
+	cl.c.mux.Lock()
+	delete(cl.c.subs, key)
+	if cancel, ok := cl.c.cancels[key]; ok {
+		cancel()
+		delete(cl.c.cancels, key)
+	}
+	cl.c.mux.Unlock()
+	query := url.Values{"topic": {key.tn}, "sub": {sn}}
+	_, _ = cl.do(context.Background(), http.MethodDelete, "/subscriptions", query, nil, nil)
+}
+
+// Purging pending messages of subscription (sn) or of all subscriptions of topic if (sn) is empty
+func (cl *Client) purge(tn, sn string) (int, error) {
+	query := url.Values{"topic": {cl.topic(tn)}}
+	if sn != "" {
+		query.Set("sub", sn)
+	}
+	resp, err := cl.do(context.Background(), http.MethodDelete, "/messages", query, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	if resp.status == http.StatusNotFound {
+		return 0, responseError(resp)
+	}
+	var res struct {
+		Purged int `json:"purged"`
+	}
+	err = json.Unmarshal(resp.body, &res)
+	return res.Purged, err
+}
+
+func (cl *Client) PurgeSubscription(tn, sn string) (int, error) {
+	return cl.purge(tn, sn)
+}
+
+func (cl *Client) PurgeTopic(tn string) (int, error) {
+	return cl.purge(tn, "")
+}
+
+// Names of topics, topics of namespace without prefix
+func (cl *Client) Topics() []string {
+	resp, err := cl.do(context.Background(), http.MethodGet, "/topics", nil, nil, nil)
+	if err != nil {
+		return nil
+	}
+	var all []string
+	if json.Unmarshal(resp.body, &all) != nil {
+		return nil
+	}
+	var topics []string
+	for _, tn := range all {
+		if strings.HasPrefix(tn, cl.prefix) {
+			topics = append(topics, tn[len(cl.prefix):])
+		}
+	}
+	return topics
+}
+
+// Counters of the server, topics of namespace without prefix, empty if the server is unavailable
+func (cl *Client) Stats() pubsub.BrokerStats {
+	resp, err := cl.do(context.Background(), http.MethodGet, "/stats", nil, nil, nil)
+	if err != nil {
+		return pubsub.BrokerStats{}
+	}
+	var all pubsub.BrokerStats
+	if json.Unmarshal(resp.body, &all) != nil {
+		return pubsub.BrokerStats{}
+	}
+	var stats pubsub.BrokerStats
+	for _, ts := range all.Topics {
+		if strings.HasPrefix(ts.Name, cl.prefix) {
+			ts.Name = ts.Name[len(cl.prefix):]
+			stats.Topics = append(stats.Topics, ts)
+		}
+	}
+	return stats
+}
+
+// Statistics of subscriptions of topic (tn)
+func (cl *Client) topicStats(tn string) ([]pubsub.SubscriptionStats, error) {
+	for _, ts := range cl.Stats().Topics {
+		if ts.Name == tn {
+			return ts.Subscriptions, nil
+		}
+	}
+	return nil, pubsub.ErrNoSubscriptions
+}
+
+// Handle of topic publishing through the client
+func (cl *Client) Topic(tn string) *pubsub.Topic {
+	return pubsub.NewTopic(cl, tn)
+}
+
+// Names of subscriptions of topic, read from Stats of the server
+func (cl *Client) Subscriptions(tn string) ([]string, error) {
+	subs, err := cl.topicStats(tn)
+	var names []string
+	for _, s := range subs {
+		names = append(names, s.Name)
+	}
+	return names, err
+}
+
+// Number of pending messages of subscription, read from Stats of the server
+func (cl *Client) Depth(tn, sn string) (int, error) {
+	subs, err := cl.topicStats(tn)
+	for _, s := range subs {
+		if s.Name == sn {
+			return s.Pending, nil
+		}
+	}
+	if err == nil {
+		err = pubsub.ErrNoSubscriptions
+	}
+	return 0, err
+}
+
+// Number of messages of subscription in flight, read from Stats of the server
+func (cl *Client) InFlight(tn, sn string) (int, error) {
+	subs, err := cl.topicStats(tn)
+	for _, s := range subs {
+		if s.Name == sn {
+			return s.InFlight, nil
+		}
+	}
+	if err == nil {
+		err = pubsub.ErrNoSubscriptions
+	}
+	return 0, err
+}
+
+// Report of readiness probe of the server, not live if the server can't be reached
+func (cl *Client) Health() pubsub.HealthReport {
+	resp, err := cl.send(context.Background(), http.MethodGet, "/readyz", nil, nil, nil)
+	var h pubsub.HealthReport
+	if err != nil {
+		h.Problems = []string{err.Error()}
+		return h
+	}
+	if err := json.Unmarshal(resp.body, &h); err != nil {
+		h.Problems = []string{err.Error()}
+	}
+	if cl.c.isDown() {
+		h.Ready = false
+		h.Problems = append(h.Problems, ErrUnavailable.Error())
+	}
+	return h
+}
+
+// Writing snapshot of the whole server, namespaces can't be dumped separately
+func (cl *Client) Snapshot(w io.Writer) error {
+	if cl.prefix != "" {
+		return errors.ErrUnsupported
+	}
+	resp, err := cl.do(context.Background(), http.MethodGet, "/snapshot", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(resp.body)
+	return err
+}
+
+// Stopping reconnects and subscriptions of SubscribeChan and SubscribeFunc, buffered messages are sent if the server
+// is available, ErrUnavailable is returned if some of them are lost. The server isn't closed
+func (cl *Client) Close(ctx context.Context) error {
+	cl.c.mux.Lock()
+	for key, cancel := range cl.c.cancels {
+		cancel()
+		delete(cl.c.cancels, key)
+	}
+	cl.c.mux.Unlock()
+	err := cl.c.flush(ctx)
+	if cl.c.closed.CompareAndSwap(false, true) {
+		close(cl.c.done)
+	}
+	cl.c.wg.Wait()
+	cl.c.mux.Lock()
+	lost := len(cl.c.buffer)
+	cl.c.buffer = nil
+	cl.c.mux.Unlock()
+	if lost > 0 {
+		return fmt.Errorf("%w: %d buffered messages are lost: %v", ErrUnavailable, lost, err)
+	}
+	return nil
+}
+
+// Facade of client prefixing topic names with (name) and "/"; unlike namespaces of broker, isolation isn't
+// enforced by the server
+func (cl *Client) Namespace(name string) pubsub.PubSuber {
+	return &Client{c: cl.c, prefix: cl.prefix + name + "/", principal: cl.principal}
+}
+
+// Facade of client sending (principal) in HeaderPrincipal header, server reads it with pubsubhttp.Handler.Principal
+func (cl *Client) As(principal any) pubsub.PubSuber {
+	return &Client{c: cl.c, prefix: cl.prefix, principal: fmt.Sprint(principal)}
+}
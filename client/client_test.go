@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubhttp"
+)
+
+// Transport failing requests while (down) is set
+type flaky struct {
+	down atomic.Bool
+}
+
+func (f *flaky) RoundTrip(r *http.Request) (*http.Response, error) {
+	if f.down.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// Client of test server of broker (ps) with flaky transport
+func testClient(t *testing.T, ps pubsub.PubSuber, opts ...Option) (*Client, *flaky) {
+	s := httptest.NewServer(pubsubhttp.NewHandler(ps))
+	t.Cleanup(s.Close)
+	f := &flaky{}
+	opts = append([]Option{
+		WithHTTPClient(&http.Client{Transport: f}),
+		WithRetries(1, time.Millisecond, 10*time.Millisecond),
+	}, opts...)
+	return New(s.URL, opts...), f
+}
+
+// Waiting until (cond) holds
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClient(t *testing.T) {
+	lib := pubsub.New()
+	c, _ := testClient(t, lib)
+	c.Subscribe("orders", "billing")
+	if err := c.TryPublish("payments", []byte("payment")); err != nil {
+		t.Fatal(err)
+	}
+	id, err := c.PublishMsg("orders", pubsub.Message{Body: []byte("{}"), Headers: map[string]string{pubsub.HeaderContentType: "application/json"}})
+	if err != nil || id == "" {
+		t.Fatal(id, err)
+	}
+	c.Publish("orders", []byte("order"))
+	if depth, err := c.Depth("orders", "billing"); err != nil || depth != 2 {
+		t.Fatal(depth, err)
+	}
+	if n, err := c.InFlight("orders", "billing"); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+	if subs, err := c.Subscriptions("orders"); err != nil || len(subs) != 1 || subs[0] != "billing" {
+		t.Fatal(subs, err)
+	}
+	msg, err := c.PollMsg("orders", "billing")
+	if err != nil || string(msg.Body) != "{}" || msg.Headers[pubsub.HeaderContentType] != "application/json" {
+		t.Fatal(msg, err)
+	}
+	if msgs, err := c.PollN("orders", "billing", 10); err != nil || len(msgs) != 1 || string(msgs[0]) != "order" {
+		t.Fatal(msgs, err)
+	}
+	if b, err := c.Poll("orders", "billing"); err != nil || b != nil {
+		t.Fatal(b, err)
+	}
+	if _, err := c.Poll("orders", "shipping"); !errors.Is(err, pubsub.ErrNoSubscriptions) {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		lib.Publish("orders", []byte("late"))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if b, err := c.PollWait(ctx, "orders", "billing"); err != nil || string(b) != "late" {
+		t.Fatal(b, err)
+	}
+	if topics := c.Topics(); len(topics) != 1 || topics[0] != "orders" {
+		t.Fatal(topics)
+	}
+	if h := c.Health(); !h.Ready {
+		t.Fatal(h)
+	}
+	if err := c.PublishTx(nil); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatal(err)
+	}
+	c.Unsubscribe("orders", "billing")
+	if subs, _ := lib.Subscriptions("orders"); len(subs) != 0 {
+		t.Fatal(subs)
+	}
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TryPublish("orders", nil); err != pubsub.ErrClosed {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_SubscribeChan(t *testing.T) {
+	lib := pubsub.New()
+	c, _ := testClient(t, lib, WithPollWait(50*time.Millisecond))
+	ch, err := c.SubscribeChan("orders", "billing", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SubscribeChan("orders", "billing", 1); err != pubsub.ErrSubscriptionExists {
+		t.Fatal(err)
+	}
+	c.Publish("orders", []byte("order"))
+	select {
+	case b := <-ch:
+		if string(b) != "order" {
+			t.Fatal(b)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	c.Unsubscribe("orders", "billing")
+	for range ch {
+	}
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Namespace(t *testing.T) {
+	lib := pubsub.New()
+	c, _ := testClient(t, lib)
+	ns := c.Namespace("tenant")
+	ns.Subscribe("orders", "billing")
+	ns.Publish("orders", []byte("order"))
+	if d, _ := lib.Depth("tenant/orders", "billing"); d != 1 {
+		t.Fatal(d)
+	}
+	if topics := ns.Topics(); len(topics) != 1 || topics[0] != "orders" {
+		t.Fatal(topics)
+	}
+	if topics := c.Namespace("other").Topics(); len(topics) != 0 {
+		t.Fatal(topics)
+	}
+	if b, _ := ns.Poll("orders", "billing"); string(b) != "order" {
+		t.Fatal(b)
+	}
+}
+
+func TestClient_As(t *testing.T) {
+	lib := pubsub.New(pubsub.WithAuthorizer(pubsub.AuthorizerFunc(func(op pubsub.Operation, tn, sn string, principal any) error {
+		if principal != "admin" && op == pubsub.OpPublish {
+			return pubsub.ErrForbidden
+		}
+		return nil
+	})))
+	h := pubsubhttp.NewHandler(lib)
+	h.Principal = func(r *http.Request) any { return r.Header.Get(HeaderPrincipal) }
+	s := httptest.NewServer(h)
+	defer s.Close()
+	c := New(s.URL)
+	c.Subscribe("orders", "billing")
+	if err := c.As("guest").TryPublish("orders", nil); !errors.Is(err, pubsub.ErrForbidden) {
+		t.Fatal(err)
+	}
+	if err := c.As("admin").TryPublish("orders", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Session(t *testing.T) {
+	lib := pubsub.New()
+	lib.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("1"))
+	lib.Publish("orders", []byte("2"))
+	s := httptest.NewServer(pubsubhttp.NewHandler(lib))
+	defer s.Close()
+	c := New(s.URL, WithSession("s1"))
+	if b, err := c.Poll("orders", "billing"); err != nil || string(b) != "1" {
+		t.Fatal(b, err)
+	}
+	// restarted client didn't acknowledge the message
+	c = New(s.URL, WithSession("s1"))
+	if b, err := c.Poll("orders", "billing"); err != nil || string(b) != "1" {
+		t.Fatal(b, err)
+	}
+	if b, err := c.Poll("orders", "billing"); err != nil || string(b) != "2" {
+		t.Fatal(b, err)
+	}
+	if b, err := c.Poll("orders", "billing"); err != nil || b != nil {
+		t.Fatal(b, err)
+	}
+	if stats := lib.Stats(); stats.Topics[0].Subscriptions[0].InFlight != 0 {
+		t.Fatal(stats)
+	}
+}
+
+func TestClient_Iter(t *testing.T) {
+	lib := pubsub.New()
+	c, _ := testClient(t, lib, WithPollWait(20*time.Millisecond))
+	c.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("1"))
+	lib.Publish("orders", []byte("2"))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var got []string
+	for msg, err := range c.Iter(ctx, "orders", "billing") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(msg))
+	}
+	if len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Checks if the server is unreachable
+func (c *conn) isDown() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.down
+}
+
+// Marking the server unreachable and starting reconnect goroutine unless it runs already
+func (c *conn) disconnect() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.down || c.closed.Load() {
+		return
+	}
+	c.down = true
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.reconnect()
+	}()
+}
+
+// Adding message (b) to buffer, ErrUnavailable if buffering is disabled
+func (c *conn) enqueue(b buffered) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	switch {
+	case c.bufSize == 0:
+		return ErrUnavailable
+	case len(c.buffer) >= c.bufSize:
+		return ErrBufferFull
+	}
+	c.buffer = append(c.buffer, b)
+	return nil
+}
+
+// Probing the server with backoff until it answers, then restoring subscriptions and sending buffered messages
+func (c *conn) reconnect() {
+	backoff := c.minBackoff
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, c.maxBackoff)
+		if !c.probe() {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-c.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		err := c.restore(ctx)
+		if err == nil {
+			err = c.flush(ctx)
+		}
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+}
+
+// Checks if the server answers liveness probe
+func (c *conn) probe() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.maxBackoff)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/healthz", nil)
+	if err != nil {
+		return false
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+	return res.StatusCode == http.StatusOK
+}
+
+// Subscribing again subscriptions created through client
+func (c *conn) restore(ctx context.Context) error {
+	c.mux.Lock()
+	subs := make(map[subKey]string, len(c.subs))
+	for key, principal := range c.subs {
+		subs[key] = principal
+	}
+	c.mux.Unlock()
+	for key, principal := range subs {
+		cl := &Client{c: c, principal: principal}
+		if err := cl.subscribe(ctx, key.tn, key.sn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sending buffered messages in order, the client is up again once buffer is empty
+// Messages rejected by the server are dropped, sending stops at the first connection error
+func (c *conn) flush(ctx context.Context) error {
+	c.flushMux.Lock()
+	defer c.flushMux.Unlock()
+	for {
+		c.mux.Lock()
+		if len(c.buffer) == 0 {
+			c.down = false
+			c.mux.Unlock()
+			return nil
+		}
+		b := c.buffer[0]
+		c.mux.Unlock()
+		cl := &Client{c: c, principal: b.principal}
+		if err := cl.post(ctx, b.tn, b.msg); isConnError(err) {
+			return err
+		}
+		c.mux.Lock()
+		c.buffer = c.buffer[1:]
+		c.mux.Unlock()
+	}
+}
+
+// Checks if error (err) means the message didn't reach the server
+func isConnError(err error) bool {
+	return err != nil && (errors.Is(err, ErrUnavailable) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, pubsub.ErrClosed))
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+func TestClient_Reconnect(t *testing.T) {
+	lib := pubsub.New()
+	c, f := testClient(t, lib, WithBuffer(2))
+	c.Subscribe("orders", "billing")
+	f.down.Store(true)
+	if err := c.TryPublish("orders", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TryPublish("orders", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TryPublish("orders", []byte("3")); err != ErrBufferFull {
+		t.Fatal(err)
+	}
+	if _, err := c.Poll("orders", "billing"); !errors.Is(err, ErrUnavailable) {
+		t.Fatal(err)
+	}
+	// the server restarted without subscriptions
+	lib.Unsubscribe("orders", "billing")
+	f.down.Store(false)
+	waitFor(t, func() bool { d, _ := lib.Depth("orders", "billing"); return d == 2 })
+	for _, want := range []string{"1", "2"} {
+		if b, err := c.Poll("orders", "billing"); err != nil || string(b) != want {
+			t.Fatal(b, err)
+		}
+	}
+	if err := c.TryPublish("orders", []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_CloseLosesBuffer(t *testing.T) {
+	c, f := testClient(t, pubsub.New(), WithBuffer(10))
+	f.down.Store(true)
+	c.Publish("orders", []byte("order"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Close(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Methods of pubsub.PubSuber the HTTP API has no endpoint for
+
+func (cl *Client) PublishTx(fn func(tx pubsub.Tx) error) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) PublishWithPriority(tn string, b []byte, prio int) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) PublishRetained(tn string, b []byte) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) PublishWithTTL(tn string, b []byte, ttl time.Duration) error {
+	return errors.ErrUnsupported
+}
+
+// Messages polled over HTTP can't be put back, so budget of whole messages can't be kept
+func (cl *Client) PollBytes(tn, sn string, maxBytes int) ([][]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (cl *Client) SubscribeFrom(tn, sn string, from pubsub.SeekPosition) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) Seek(tn, sn string, seq uint64) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) SetHistory(tn string, n int) {}
+
+func (cl *Client) CreateTopic(tn string, cfg pubsub.TopicConfig) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) ConfigureTopic(tn string, cfg pubsub.TopicConfig) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) TopicConfig(tn string) (pubsub.TopicConfig, error) {
+	return pubsub.TopicConfig{}, errors.ErrUnsupported
+}
+
+func (cl *Client) DeleteTopic(tn string) {}
+
+func (cl *Client) PollAck(tn, sn string, visibility time.Duration) ([]byte, pubsub.AckToken, error) {
+	return nil, 0, errors.ErrUnsupported
+}
+
+func (cl *Client) PollAckMsg(tn, sn string, visibility time.Duration) (*pubsub.Message, pubsub.AckToken, error) {
+	return nil, 0, errors.ErrUnsupported
+}
+
+func (cl *Client) Ack(tn, sn string, token pubsub.AckToken) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) Nack(tn, sn string, token pubsub.AckToken, requeue bool) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) ExtendAck(tn, sn string, token pubsub.AckToken, visibility time.Duration) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) PollDLQ(tn, sn string) (*pubsub.Message, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (cl *Client) Redrive(tn, sn string) (int, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func (cl *Client) Peek(tn, sn string) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (cl *Client) PeekN(tn, sn string, max int) ([][]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (cl *Client) SetValidator(tn string, v pubsub.Validator) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) Forward(src, dst string, transform func([]byte) ([]byte, bool)) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) Unforward(src, dst string) {}
+
+func (cl *Client) Pause(tn, sn string, drop bool) error {
+	return errors.ErrUnsupported
+}
+
+func (cl *Client) Resume(tn, sn string) error {
+	return errors.ErrUnsupported
+}
+
+// Events of the server aren't streamed, the channel is nil
+func (cl *Client) Events(context.Context) <-chan pubsub.Event {
+	return nil
+}
+
+// Clock of the server can't be moved
+func (cl *Client) Advance(d time.Duration) error {
+	return pubsub.ErrRealClock
+}
+
+func (cl *Client) Restore(r io.Reader) error {
+	return errors.ErrUnsupported
+}
+
+// Replies are published with reply-to header which the HTTP API doesn't carry
+func (cl *Client) Request(ctx context.Context, tn string, b []byte) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// Admin UI is served by the server itself, see pubsubhttp.NewHandler
+func (cl *Client) AdminHandler() http.Handler {
+	return http.NotFoundHandler()
+}
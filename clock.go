@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+)
+
+// Error happens if Advance is called for broker which clock isn't ManualClock
+var ErrRealClock = errors.New("clock of broker can't be advanced")
+
+// Clock is the source of time of broker: publishing time, expiration of messages, visibility timeouts,
+// delayed publishing, rate limits and idle subscriptions use it
+// Tests replace it with a fake one (see pubsubtest.FakeClock) to make time-dependent behavior deterministic
+type Clock interface {
+	Now() time.Time
+	// Calling (f) after duration (d), returned Timer cancels the call
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer of Clock.AfterFunc, Stop returns false if the call has already happened or was stopped
+type Timer interface {
+	Stop() bool
+}
+
+// Clock of real time
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// ManualClock is Clock which time moves only by Advance, e.g. pubsubtest.FakeClock
+// Its timers must be fired by Advance in the calling goroutine
+type ManualClock interface {
+	Clock
+	Advance(d time.Duration)
+}
+
+// Option sets clock (c) of broker, real time is used by default
+// Background sweeper wakes up by real time, but expiration is checked by (c). If (c) is ManualClock, broker has no
+// background work at all: timers, redelivery and sweeping happen only in Advance of broker
+func WithClock(c Clock) Option {
+	return func(p *pubSub) {
+		p.clock = c
+	}
+}
+
+// Current time of broker clock
+func (p *pubSub) now() time.Time {
+	return p.clock.Now()
+}
+
+// Checks if time of broker is moved by Advance
+func (p *pubSub) manual() bool {
+	_, ok := p.clock.(ManualClock)
+	return ok
+}
+
+// Moving time of broker by (d): timers which time has come are fired (delayed messages are published, messages
+// not acknowledged within visibility timeout are redelivered), then expired messages and idle subscriptions are
+// removed and watchdog checks subscriptions like background sweeper. Everything happens in the calling goroutine before Advance returns
+// ErrRealClock is returned if clock of broker isn't ManualClock (see WithClock)
+func (p *pubSub) Advance(d time.Duration) error {
+	c, ok := p.clock.(ManualClock)
+	if !ok {
+		return ErrRealClock
+	}
+	c.Advance(d)
+	now := p.now()
+	p.sweep(now)
+	p.expireIdle(now)
+	p.watch(now)
+	return nil
+}
+
+// Moving time of broker by (d), time is shared by all namespaces
+func (n *namespace) Advance(d time.Duration) error {
+	return n.p.Advance(d)
+}
+
+// Moving time of broker by (d) if principal may manage broker
+func (a *authorized) Advance(d time.Duration) error {
+	if err := a.allow(OpManage, "", ""); err != nil {
+		return err
+	}
+	return a.next.Advance(d)
+}
@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// ManualClock of package tests, timers are fired by Advance in order of their time (see pubsubtest.FakeClock)
+type stepClock struct {
+	mux    sync.Mutex
+	now    time.Time
+	timers []*stepTimer
+}
+
+// Timer of stepClock fired at time (at)
+type stepTimer struct {
+	c  *stepClock
+	at time.Time
+	f  func()
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+func (c *stepClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := &stepTimer{c: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *stepTimer) Stop() bool {
+	t.c.mux.Lock()
+	defer t.c.mux.Unlock()
+	for i, s := range t.c.timers {
+		if s == t {
+			t.c.timers = append(t.c.timers[:i], t.c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *stepClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	end := c.now.Add(d)
+	for {
+		next := -1
+		for i, t := range c.timers {
+			if !t.at.After(end) && (next < 0 || t.at.Before(c.timers[next].at)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		t := c.timers[next]
+		c.timers = append(c.timers[:next], c.timers[next+1:]...)
+		if t.at.After(c.now) {
+			c.now = t.at
+		}
+		c.mux.Unlock()
+		t.f()
+		c.mux.Lock()
+	}
+	c.now = end
+	c.mux.Unlock()
+}
+
+func TestPubSub_Advance(t *testing.T) {
+	if err := New().Advance(time.Second); err != ErrRealClock {
+		t.Fatal(err)
+	}
+	c := &stepClock{now: time.Unix(0, 0)}
+	lib := New(WithClock(c), WithAuthorizer(testAuthorizer()))
+	lib.Subscribe("t", "s")
+	if err := lib.PublishWithTTL("t", []byte("message"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if lib.Stats().Topics[0].Subscriptions[0].Dropped != 0 {
+		t.FailNow()
+	}
+	if err := lib.As("alice").Advance(time.Minute); err != ErrForbidden {
+		t.Fatal(err)
+	}
+	if err := lib.Namespace("tenant").Advance(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if dropped := lib.Stats().Topics[0].Subscriptions[0].Dropped; dropped != 1 {
+		t.Fatal(dropped)
+	}
+}
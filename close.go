@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Error happens if broker is used after Close
+// errors.Is(ErrClosed, ErrNoSubscriptions) is true, because there are no subscriptions after Close
+var ErrClosed error = noSubscriptionsError("pubsub is closed")
+
+// How often Close checks if queues are drained
+const drainCheckInterval = 10 * time.Millisecond
+
+// Closing broker in two phases
+// At first publishing is rejected (publish methods return ErrClosed, Publish and PublishAfter ignore messages),
+// messages scheduled by PublishAfter/PublishAt are dropped, and Close waits until all pending and in flight messages
+// are polled (dead-letter topics are not waited for) or ctx is done.
+// Then all subscriptions are removed: waiting pollers, channels of SubscribeChan and workers of SubscribeFunc
+// are released, background goroutines and timers are stopped, and all subsequent calls return ErrClosed.
+// ctx.Err() is returned if ctx was done before queues were drained, broker is closed anyway
+// ErrClosed raises if Close was called before
+func (p *pubSub) Close(ctx context.Context) error {
+	if !p.closing.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	p.schedMux.Lock()
+	if p.schedTimer != nil {
+		p.schedTimer.Stop()
+	}
+	p.sched = nil
+	p.schedMux.Unlock()
+	err := p.drain(ctx, "")
+	p.closed.Store(true)
+	close(p.done)
+	p.mux.Lock()
+	for _, subs := range p.topics.all() {
+		subs.mux.Lock()
+		for _, sub := range subs.hm {
+			sub.close()
+		}
+		subs.deleted.Store(true)
+		subs.mux.Unlock()
+	}
+	p.topics.clear()
+	p.wildcards = topicTree{}
+	p.patterns.Store(0)
+	p.namespaces = nil
+	p.mux.Unlock()
+	p.events.close()
+	p.retainMux.Lock()
+	p.retained = nil
+	p.retainMux.Unlock()
+	p.log.Info("closed")
+	return err
+}
+
+// Waiting until all messages of topics which names start with (prefix) are polled or ctx is done
+func (p *pubSub) drain(ctx context.Context, prefix string) error {
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for !p.drained(prefix) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Checks if topics which names start with (prefix) have no pending and in flight messages except dead letters,
+// expired messages are removed
+func (p *pubSub) drained(prefix string) bool {
+	now := p.now()
+	for _, subs := range p.topics.all() {
+		if !strings.HasPrefix(subs.tn, prefix) {
+			continue
+		}
+		subs.mux.Lock()
+		// topic is used as dead-letter topic
+		if subs.refs > 0 {
+			subs.mux.Unlock()
+			continue
+		}
+		for _, sub := range subs.hm {
+			sub.dropExpired(now)
+			if sub.len() > 0 || len(sub.inFlight) > 0 {
+				subs.mux.Unlock()
+				return false
+			}
+		}
+		subs.mux.Unlock()
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPubSub_Close(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	ch, _ := lib.SubscribeChan(tn, "chan", 0)
+	lib.PublishAfter(tn, []byte("scheduled"), time.Hour)
+	_ = lib.PublishWithTTL(tn, []byte("message"), time.Hour)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		if b, err := lib.Poll(tn, sn); err != nil || string(b) != "message" {
+			t.Error("message is not polled")
+		}
+	}()
+	<-ch
+	closed := make(chan error)
+	go func() {
+		closed <- lib.Close(context.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := lib.TryPublish(tn, []byte("rejected")); err != ErrClosed {
+		t.FailNow()
+	}
+	if err := <-closed; err != nil {
+		t.FailNow()
+	}
+	if _, ok := <-ch; ok {
+		t.FailNow()
+	}
+	if _, err := lib.Poll(tn, sn); err != ErrClosed {
+		t.FailNow()
+	}
+	if _, err := lib.PollWait(context.Background(), tn, sn); err != ErrClosed {
+		t.FailNow()
+	}
+	if err := lib.SubscribeFunc(tn, "func", func([]byte) {}); err != ErrClosed {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	if len(lib.Topics()) != 0 {
+		t.FailNow()
+	}
+	if err := lib.Close(context.Background()); err != ErrClosed {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Close_Deadline(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxDeliveries: 1})
+	lib.Publish(tn, []byte("message"))
+	done := make(chan error)
+	go func() {
+		_, err := lib.PollWait(context.Background(), tn, "waiter")
+		done <- err
+	}()
+	lib.Subscribe(tn, "waiter")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := lib.Close(ctx); err != context.DeadlineExceeded {
+		t.FailNow()
+	}
+	if err := <-done; err != ErrClosed && !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Close_DeadLetters(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxDeliveries: 1})
+	lib.Publish(tn, []byte("message"))
+	_, token, _ := lib.PollAck(tn, sn, time.Minute)
+	_ = lib.Nack(tn, sn, token, true)
+	if msg, _ := lib.PeekN(DeadLetterTopic(tn), sn, 1); len(msg) != 1 {
+		t.FailNow()
+	}
+	// dead letters are not waited for
+	if err := lib.Close(context.Background()); err != nil {
+		t.FailNow()
+	}
+}
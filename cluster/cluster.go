@@ -0,0 +1,390 @@
+/*
+Clustered mode of pubsub package: brokers of several nodes replicate changes through Raft consensus, so a standby
+node takes over with the same queues when leader crashes.
+
+Every node wraps its own broker. Publish, Subscribe, Unsubscribe and Poll are accepted by the leader only, appended
+to the replicated log and applied to brokers of all nodes in the same order once majority of nodes has them, so
+queues of all nodes stay equal. Calls to a follower return ErrNotLeader, the client retries with the node of Leader.
+Reads (Depth, Peek, Stats, ...) may be served by Broker of any node, followers may lag behind the leader.
+
+	nodes := map[string]string{"a": "http://10.0.0.1:7000", "b": "http://10.0.0.2:7000", "c": "http://10.0.0.3:7000"}
+	n := cluster.New("a", pubsub.New(), cluster.NewHTTPTransport(nil), cluster.Config{Peers: nodes})
+	go http.ListenAndServe(":7000", cluster.Handler(n))
+	go n.Run(ctx)
+	id, err := n.Publish(ctx, "orders", pubsub.Message{Body: b})
+
+A new node starts with empty Config.Peers and is added by Join called on the leader, then it receives the whole
+log. Leave removes a node. Membership is changed one node at a time.
+
+Limitations: log is kept in memory without compaction, and term and vote aren't persisted, so a restarted node must
+join the cluster again with a new ID. Applying must be deterministic: brokers shouldn't be changed directly, and
+time-dependent or random features (time-to-live, idle expiration, Options.SampleRate, ...) may diverge between nodes.
+*/
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+var (
+	// Error happens if operation is called on a node which isn't the leader or the node lost leadership before
+	// operation was committed, in the latter case it may still be applied by the new leader
+	ErrNotLeader = errors.New("node is not the leader")
+	// Error happens if Join or Leave is called while previous membership change isn't committed yet
+	ErrMembershipChange = errors.New("membership change is in progress")
+	// Error happens if Join is called for a member or Leave for a node which isn't a member
+	ErrInvalidMember = errors.New("invalid member")
+)
+
+// Defaults of Config
+const (
+	DefaultElectionTimeout   = 300 * time.Millisecond
+	DefaultHeartbeatInterval = 50 * time.Millisecond
+)
+
+// Maximum number of entries sent in one AppendEntries request
+const maxAppendEntries = 256
+
+// Settings of Node
+// Peers - members of a new cluster by ID with their addresses for Transport, including the node itself; every node
+// of a new cluster starts with the same Peers. Node joining existing cluster starts with empty Peers
+// ElectionTimeout - follower starts election if it has no leader for a random time between ElectionTimeout and
+// twice of it, DefaultElectionTimeout if zero
+// HeartbeatInterval - how often the leader replicates log to followers, DefaultHeartbeatInterval if zero
+type Config struct {
+	Peers             map[string]string
+	ElectionTimeout   time.Duration
+	HeartbeatInterval time.Duration
+}
+
+// Role of node in current term
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+// Operations of log entries
+const (
+	opNoop        = "noop"
+	opPublish     = "publish"
+	opSubscribe   = "subscribe"
+	opUnsubscribe = "unsubscribe"
+	opPoll        = "poll"
+	opJoin        = "join"
+	opLeave       = "leave"
+)
+
+// Operation of log entry
+// Member, Addr - node of opJoin and opLeave, Peers - all members after opJoin or opLeave
+type command struct {
+	Op           string            `json:"op"`
+	Topic        string            `json:"topic,omitempty"`
+	Subscription string            `json:"subscription,omitempty"`
+	Message      *pubsub.Message   `json:"message,omitempty"`
+	Options      *pubsub.Options   `json:"options,omitempty"`
+	Member       string            `json:"member,omitempty"`
+	Addr         string            `json:"addr,omitempty"`
+	Peers        map[string]string `json:"peers,omitempty"`
+}
+
+// Entry of log in memory, data - encoded command sent to followers
+type logEntry struct {
+	term uint64
+	cmd  command
+	data []byte
+}
+
+// Outcome of applying entry returned to the caller on the leader
+type result struct {
+	id  string
+	msg *pubsub.Message
+	err error
+}
+
+// Node of cluster
+// log - replicated log, log[0] is a sentinel, so index of entry is its position
+// commit - index of the last entry known to be replicated to majority, applied - index of the last applied entry
+// peers - members by ID with addresses according to the last membership entry of log (committed or not)
+// next, match - index of the next entry to send and of the last entry replicated to every peer (leader only)
+// sending - peers with AppendEntries request in flight (leader only)
+// waiters - callers of operations by index of their entries (leader only)
+// contact, timeout - time of the last message of leader (or vote granted) and current election timeout
+type Node struct {
+	id       string
+	ps       pubsub.PubSuber
+	t        Transport
+	cfg      Config
+	mux      sync.Mutex
+	role     role
+	term     uint64
+	votedFor string
+	leader   string
+	log      []logEntry
+	commit   uint64
+	applied  uint64
+	peers    map[string]string
+	next     map[string]uint64
+	match    map[string]uint64
+	sending  map[string]bool
+	waiters  map[uint64]chan result
+	contact  time.Time
+	timeout  time.Duration
+	applyCh  chan struct{}
+}
+
+// Constructor. Creates node (id) of cluster applying log to broker (ps) and talking to other nodes with transport (t)
+// Node does nothing until Run is called
+func New(id string, ps pubsub.PubSuber, t Transport, cfg Config) *Node {
+	if cfg.ElectionTimeout <= 0 {
+		cfg.ElectionTimeout = DefaultElectionTimeout
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+	n := &Node{
+		id:      id,
+		ps:      ps,
+		t:       t,
+		cfg:     cfg,
+		log:     []logEntry{{}},
+		peers:   map[string]string{},
+		waiters: map[uint64]chan result{},
+		applyCh: make(chan struct{}, 1),
+	}
+	for id, addr := range cfg.Peers {
+		n.peers[id] = addr
+	}
+	n.resetTimeout()
+	return n
+}
+
+// ID of the node
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Broker of the node, it must be changed through the node only, otherwise nodes diverge
+func (n *Node) Broker() pubsub.PubSuber {
+	return n.ps
+}
+
+// ID and address of the current leader, empty if it's unknown
+func (n *Node) Leader() (string, string) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	return n.leader, n.peers[n.leader]
+}
+
+// Members of the cluster by ID with their addresses, as the node knows them
+func (n *Node) Members() map[string]string {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	peers := make(map[string]string, len(n.peers))
+	for id, addr := range n.peers {
+		peers[id] = addr
+	}
+	return peers
+}
+
+// Taking part in the cluster: elections, replication and applying of log, until ctx is done
+func (n *Node) Run(ctx context.Context) error {
+	go n.applier(ctx)
+	ticker := time.NewTicker(n.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.mux.Lock()
+			n.stepDown(n.term)
+			n.mux.Unlock()
+			return ctx.Err()
+		case <-ticker.C:
+			n.tick()
+		}
+	}
+}
+
+// Publishing message (msg) to topic name (tn) on all nodes, returns ID of message which is the same on all nodes
+// ID of message is assigned by the leader unless it's set
+func (n *Node) Publish(ctx context.Context, tn string, msg pubsub.Message) (string, error) {
+	res, err := n.propose(ctx, command{Op: opPublish, Topic: tn, Message: &msg})
+	if err != nil {
+		return "", err
+	}
+	return res.id, res.err
+}
+
+// Subscribing (sn) to topic name (tn) with options (opts) on all nodes, nil opts keeps options of existing
+// subscription like pubsub.PubSuber.Subscribe. Options.Filter and Options.OnIdle aren't replicated
+func (n *Node) Subscribe(ctx context.Context, tn, sn string, opts *pubsub.Options) error {
+	_, err := n.propose(ctx, command{Op: opSubscribe, Topic: tn, Subscription: sn, Options: opts})
+	return err
+}
+
+// Unsubscribing (sn) from topic name (tn) on all nodes
+func (n *Node) Unsubscribe(ctx context.Context, tn, sn string) error {
+	_, err := n.propose(ctx, command{Op: opUnsubscribe, Topic: tn, Subscription: sn})
+	return err
+}
+
+// Taking the next message of subscription (sn) of topic name (tn) on all nodes, nil message is returned if there
+// are no messages. Poll is replicated like other changes, so it costs a round trip to majority
+func (n *Node) Poll(ctx context.Context, tn, sn string) (*pubsub.Message, error) {
+	res, err := n.propose(ctx, command{Op: opPoll, Topic: tn, Subscription: sn})
+	if err != nil {
+		return nil, err
+	}
+	return res.msg, res.err
+}
+
+// Adding node (id) with address (addr) to the cluster, it takes part in the majority as soon as it's added
+// Must be called on the leader, the new node should be running with empty Config.Peers
+func (n *Node) Join(ctx context.Context, id, addr string) error {
+	_, err := n.propose(ctx, command{Op: opJoin, Member: id, Addr: addr})
+	return err
+}
+
+// Removing node (id) from the cluster, leader removing itself steps down once removal is committed
+func (n *Node) Leave(ctx context.Context, id string) error {
+	_, err := n.propose(ctx, command{Op: opLeave, Member: id})
+	return err
+}
+
+// Appending command (cmd) to the log of the leader and waiting until it's applied
+func (n *Node) propose(ctx context.Context, cmd command) (result, error) {
+	n.mux.Lock()
+	if n.role != leader {
+		n.mux.Unlock()
+		return result{}, ErrNotLeader
+	}
+	index := uint64(len(n.log))
+	switch cmd.Op {
+	case opJoin, opLeave:
+		if err := n.checkMembership(cmd); err != nil {
+			n.mux.Unlock()
+			return result{}, err
+		}
+		// new node learns all members from the entry
+		cmd.Peers = make(map[string]string, len(n.peers)+1)
+		for id, addr := range n.peers {
+			cmd.Peers[id] = addr
+		}
+		if cmd.Op == opJoin {
+			cmd.Peers[cmd.Member] = cmd.Addr
+		} else {
+			delete(cmd.Peers, cmd.Member)
+		}
+	case opPublish:
+		if cmd.Message.ID == "" {
+			// term and index identify entry in the cluster
+			cmd.Message.ID = strconv.FormatUint(n.term, 10) + "-" + strconv.FormatUint(index, 10)
+		}
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		n.mux.Unlock()
+		return result{}, err
+	}
+	ch := make(chan result, 1)
+	n.waiters[index] = ch
+	n.append(logEntry{term: n.term, cmd: cmd, data: data})
+	n.mux.Unlock()
+	n.replicate()
+	select {
+	case res := <-ch:
+		if res.err == ErrNotLeader {
+			return result{}, ErrNotLeader
+		}
+		return res, nil
+	case <-ctx.Done():
+		return result{}, ctx.Err()
+	}
+}
+
+// Checks if membership change (cmd) is allowed now, n.mux must be held by caller
+func (n *Node) checkMembership(cmd command) error {
+	for i := n.commit + 1; i < uint64(len(n.log)); i++ {
+		if op := n.log[i].cmd.Op; op == opJoin || op == opLeave {
+			return ErrMembershipChange
+		}
+	}
+	_, member := n.peers[cmd.Member]
+	if cmd.Member == "" || cmd.Op == opJoin && (member || cmd.Addr == "") || cmd.Op == opLeave && !member {
+		return ErrInvalidMember
+	}
+	return nil
+}
+
+// Applying committed entries to broker in order until ctx is done
+func (n *Node) applier(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.applyCh:
+		}
+		for {
+			n.mux.Lock()
+			if n.applied >= n.commit {
+				n.mux.Unlock()
+				break
+			}
+			n.applied++
+			index, e := n.applied, n.log[n.applied]
+			n.mux.Unlock()
+			res := n.apply(e.cmd)
+			n.mux.Lock()
+			if ch, ok := n.waiters[index]; ok {
+				delete(n.waiters, index)
+				if e.term != n.term || n.role != leader {
+					res.err = ErrNotLeader
+				}
+				ch <- res
+			}
+			if e.cmd.Op == opLeave && e.cmd.Member == n.id && n.role == leader {
+				// removed leader isn't counted in majority anymore
+				n.stepDown(n.term)
+			}
+			n.mux.Unlock()
+		}
+	}
+}
+
+// Applying command (cmd) to broker
+func (n *Node) apply(cmd command) result {
+	switch cmd.Op {
+	case opPublish:
+		id, err := n.ps.PublishMsg(cmd.Topic, *cmd.Message)
+		return result{id: id, err: err}
+	case opSubscribe:
+		if cmd.Options != nil {
+			n.ps.SubscribeWithOptions(cmd.Topic, cmd.Subscription, *cmd.Options)
+		} else {
+			n.ps.Subscribe(cmd.Topic, cmd.Subscription)
+		}
+	case opUnsubscribe:
+		n.ps.Unsubscribe(cmd.Topic, cmd.Subscription)
+	case opPoll:
+		msg, err := n.ps.PollMsg(cmd.Topic, cmd.Subscription)
+		return result{msg: msg, err: err}
+	}
+	// membership is changed when entry is appended, see updatePeers
+	return result{}
+}
+
+// Choosing a new random election timeout and counting it from now
+func (n *Node) resetTimeout() {
+	n.contact = time.Now()
+	n.timeout = n.cfg.ElectionTimeout + time.Duration(rand.Int63n(int64(n.cfg.ElectionTimeout)))
+}
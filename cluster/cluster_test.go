@@ -0,0 +1,193 @@
+package cluster
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+var testConfig = Config{ElectionTimeout: 50 * time.Millisecond, HeartbeatInterval: 10 * time.Millisecond}
+
+// Running cluster of (size) nodes "n0", "n1", ... at addresses equal to their IDs until test ends
+func testCluster(t *testing.T, size int) (*Network, []*Node) {
+	nw := NewNetwork()
+	cfg := testConfig
+	cfg.Peers = map[string]string{}
+	for i := 0; i < size; i++ {
+		cfg.Peers["n"+strconv.Itoa(i)] = "n" + strconv.Itoa(i)
+	}
+	var nodes []*Node
+	for i := 0; i < size; i++ {
+		nodes = append(nodes, testNode(t, nw, "n"+strconv.Itoa(i), cfg))
+	}
+	return nw, nodes
+}
+
+// Running node (id) with config (cfg) in network (nw) until test ends
+func testNode(t *testing.T, nw *Network, id string, cfg Config) *Node {
+	n := New(id, pubsub.New(), nw, cfg)
+	nw.Add(id, n)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go n.Run(ctx)
+	return n
+}
+
+// Waiting for a leader among connected (nodes)
+func waitLeader(t *testing.T, nodes ...*Node) *Node {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, n := range nodes {
+			if id, _ := n.Leader(); id == n.ID() {
+				return n
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no leader")
+	return nil
+}
+
+// Waiting until (cond) holds
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Number of pending messages of subscription "billing" of topic "orders" of node (n)
+func depth(n *Node) int {
+	d, _ := n.Broker().Depth("orders", "billing")
+	return d
+}
+
+func TestNode_Publish(t *testing.T) {
+	_, nodes := testCluster(t, 3)
+	l := waitLeader(t, nodes...)
+	ctx := context.Background()
+	if err := l.Subscribe(ctx, "orders", "billing", &pubsub.Options{MaxMessages: 10}); err != nil {
+		t.Fatal(err)
+	}
+	id, err := l.Publish(ctx, "orders", pubsub.Message{Body: []byte("order")})
+	if err != nil || id == "" {
+		t.Fatal(id, err)
+	}
+	for _, n := range nodes {
+		waitFor(t, func() bool { return depth(n) == 1 })
+		if body, _ := n.Broker().Peek("orders", "billing"); string(body) != "order" {
+			t.Fatal(n.ID(), body)
+		}
+	}
+	msg, err := l.Poll(ctx, "orders", "billing")
+	if err != nil || msg == nil || msg.ID != id {
+		t.Fatal(msg, err)
+	}
+	for _, n := range nodes {
+		waitFor(t, func() bool { return depth(n) == 0 })
+	}
+	if msg, err := l.Poll(ctx, "orders", "billing"); err != nil || msg != nil {
+		t.Fatal(msg, err)
+	}
+	if err := l.Unsubscribe(ctx, "orders", "billing"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNode_NotLeader(t *testing.T) {
+	_, nodes := testCluster(t, 3)
+	l := waitLeader(t, nodes...)
+	for _, n := range nodes {
+		if n == l {
+			continue
+		}
+		if _, err := n.Publish(context.Background(), "orders", pubsub.Message{}); err != ErrNotLeader {
+			t.Fatal(err)
+		}
+		waitFor(t, func() bool { id, _ := n.Leader(); return id == l.ID() })
+		if _, addr := n.Leader(); addr != l.ID() {
+			t.Fatal(addr)
+		}
+	}
+}
+
+func TestNode_Failover(t *testing.T) {
+	nw, nodes := testCluster(t, 3)
+	l := waitLeader(t, nodes...)
+	ctx := context.Background()
+	l.Subscribe(ctx, "orders", "billing", nil)
+	id, err := l.Publish(ctx, "orders", pubsub.Message{Body: []byte("order")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nw.Disconnect(l.ID())
+	var rest []*Node
+	for _, n := range nodes {
+		if n != l {
+			rest = append(rest, n)
+		}
+	}
+	nl := waitLeader(t, rest...)
+	msg, err := nl.Poll(ctx, "orders", "billing")
+	if err != nil || msg == nil || msg.ID != id {
+		t.Fatal(msg, err)
+	}
+	// old leader can't commit without majority, its entry is replaced once it hears the new term
+	shortCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	_, err = l.Publish(shortCtx, "orders", pubsub.Message{Body: []byte("lost")})
+	cancel()
+	if err != ErrNotLeader && err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	nw.Connect(l.ID())
+	waitFor(t, func() bool { return depth(l) == 0 })
+	waitFor(t, func() bool { id, _ := l.Leader(); return id == nl.ID() })
+}
+
+func TestNode_JoinLeave(t *testing.T) {
+	nw, nodes := testCluster(t, 1)
+	l := waitLeader(t, nodes...)
+	ctx := context.Background()
+	if err := l.Subscribe(ctx, "orders", "billing", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Publish(ctx, "orders", pubsub.Message{Body: []byte("order")}); err != nil {
+		t.Fatal(err)
+	}
+	n := testNode(t, nw, "n1", testConfig)
+	if err := l.Join(ctx, "n1", "n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Join(ctx, "n1", "n1"); err != ErrInvalidMember {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return depth(n) == 1 })
+	if m := n.Members(); len(m) != 2 || m["n0"] != "n0" || m["n1"] != "n1" {
+		t.Fatal(m)
+	}
+	if err := l.Leave(ctx, "n0"); err != nil {
+		t.Fatal(err)
+	}
+	nl := waitLeader(t, n)
+	if nl != n {
+		t.Fatal(nl.ID())
+	}
+	if m := n.Members(); len(m) != 1 || m["n1"] != "n1" {
+		t.Fatal(m)
+	}
+	if _, err := n.Publish(ctx, "orders", pubsub.Message{Body: []byte("order")}); err != nil {
+		t.Fatal(err)
+	}
+	if d := depth(n); d != 2 {
+		t.Fatal(d)
+	}
+	if err := n.Leave(ctx, "n0"); err != ErrInvalidMember {
+		t.Fatal(err)
+	}
+}
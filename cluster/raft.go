@@ -0,0 +1,353 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Entry of replicated log sent by AppendEntries, Data is an encoded operation
+type Entry struct {
+	Term uint64 `json:"term"`
+	Data []byte `json:"data"`
+}
+
+// RequestVote request of candidate (Candidate) of term (Term) with the last entry of its log (LastIndex, LastTerm)
+type VoteRequest struct {
+	Term      uint64 `json:"term"`
+	Candidate string `json:"candidate"`
+	LastIndex uint64 `json:"lastIndex"`
+	LastTerm  uint64 `json:"lastTerm"`
+}
+
+// Response to VoteRequest, Term - current term of the voter
+type VoteResponse struct {
+	Term    uint64 `json:"term"`
+	Granted bool   `json:"granted"`
+}
+
+// AppendEntries request of leader (Leader) of term (Term): entries following entry (PrevIndex, PrevTerm) and index of
+// the last committed entry (Commit). Request without entries is a heartbeat
+type AppendRequest struct {
+	Term      uint64  `json:"term"`
+	Leader    string  `json:"leader"`
+	PrevIndex uint64  `json:"prevIndex"`
+	PrevTerm  uint64  `json:"prevTerm"`
+	Entries   []Entry `json:"entries,omitempty"`
+	Commit    uint64  `json:"commit"`
+}
+
+// Response to AppendRequest, Term - current term of the follower
+// Index - index of the last entry matching log of the leader on success, a hint where to continue otherwise
+type AppendResponse struct {
+	Term    uint64 `json:"term"`
+	Success bool   `json:"success"`
+	Index   uint64 `json:"index"`
+}
+
+// Index and term of the last entry of log, n.mux must be held by caller
+func (n *Node) last() (uint64, uint64) {
+	i := uint64(len(n.log) - 1)
+	return i, n.log[i].term
+}
+
+// Number of votes or replicas making majority of members, n.mux must be held by caller
+func (n *Node) quorum() int {
+	return len(n.peers)/2 + 1
+}
+
+// Work of the node every heartbeat interval: leader replicates log, follower starts election if leader is silent
+func (n *Node) tick() {
+	n.mux.Lock()
+	switch {
+	case n.role == leader:
+		n.mux.Unlock()
+		n.replicate()
+		return
+	case n.peers[n.id] == "" || time.Since(n.contact) < n.timeout:
+		// node which isn't a member never starts elections
+		n.mux.Unlock()
+		return
+	}
+	n.campaign()
+	n.mux.Unlock()
+}
+
+// Starting election of a new term, n.mux must be held by caller
+func (n *Node) campaign() {
+	n.role = candidate
+	n.term++
+	n.votedFor = n.id
+	n.leader = ""
+	n.resetTimeout()
+	term := n.term
+	index, lastTerm := n.last()
+	req := VoteRequest{Term: term, Candidate: n.id, LastIndex: index, LastTerm: lastTerm}
+	votes := 1
+	if votes >= n.quorum() {
+		n.becomeLeader()
+		return
+	}
+	for id, addr := range n.peers {
+		if id == n.id {
+			continue
+		}
+		go func(addr string) {
+			ctx, cancel := context.WithTimeout(context.Background(), n.cfg.ElectionTimeout)
+			defer cancel()
+			resp, err := n.t.RequestVote(ctx, addr, req)
+			if err != nil {
+				return
+			}
+			n.mux.Lock()
+			defer n.mux.Unlock()
+			if resp.Term > n.term {
+				n.stepDown(resp.Term)
+				return
+			}
+			if !resp.Granted || n.role != candidate || n.term != term {
+				return
+			}
+			if votes++; votes >= n.quorum() {
+				n.becomeLeader()
+			}
+		}(addr)
+	}
+}
+
+// Taking leadership of the current term, n.mux must be held by caller
+func (n *Node) becomeLeader() {
+	n.role = leader
+	n.leader = n.id
+	n.next = map[string]uint64{}
+	n.match = map[string]uint64{}
+	n.sending = map[string]bool{}
+	index, _ := n.last()
+	for id := range n.peers {
+		n.next[id] = index + 1
+	}
+	// entries of previous terms are committed only together with an entry of the current term
+	data, _ := json.Marshal(command{Op: opNoop})
+	n.append(logEntry{term: n.term, cmd: command{Op: opNoop}, data: data})
+	go n.replicate()
+}
+
+// Becoming follower of term (term), callers waiting for their entries get ErrNotLeader
+// n.mux must be held by caller
+func (n *Node) stepDown(term uint64) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.leader = ""
+	}
+	if n.role == leader {
+		n.leader = ""
+	}
+	n.role = follower
+	n.resetTimeout()
+	for index, ch := range n.waiters {
+		delete(n.waiters, index)
+		ch <- result{err: ErrNotLeader}
+	}
+}
+
+// Appending entry (e) to log of the leader, n.mux must be held by caller
+func (n *Node) append(e logEntry) {
+	n.log = append(n.log, e)
+	if e.cmd.Op == opJoin || e.cmd.Op == opLeave {
+		n.updatePeers()
+	}
+	if n.role == leader {
+		// single member cluster commits right away
+		n.advanceCommit()
+	}
+}
+
+// Members according to the last membership entry of log, it's applied as soon as it's appended
+// n.mux must be held by caller
+func (n *Node) updatePeers() {
+	peers := n.cfg.Peers
+	for _, e := range n.log[1:] {
+		if e.cmd.Op == opJoin || e.cmd.Op == opLeave {
+			peers = e.cmd.Peers
+		}
+	}
+	n.peers = make(map[string]string, len(peers))
+	for id, addr := range peers {
+		n.peers[id] = addr
+	}
+	if n.role != leader {
+		return
+	}
+	index, _ := n.last()
+	for id := range n.peers {
+		if _, ok := n.next[id]; !ok {
+			n.next[id] = 1
+			if id == n.id {
+				n.next[id] = index + 1
+			}
+		}
+	}
+}
+
+// Sending missing entries to every follower, peers with request in flight are skipped
+func (n *Node) replicate() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.role != leader {
+		return
+	}
+	for id, addr := range n.peers {
+		if id == n.id || n.sending[id] {
+			continue
+		}
+		n.sending[id] = true
+		go n.send(id, addr, n.appendRequest(id))
+	}
+}
+
+// AppendEntries request for peer (id), n.mux must be held by caller
+func (n *Node) appendRequest(id string) AppendRequest {
+	next := n.next[id]
+	if next == 0 {
+		next = 1
+	}
+	req := AppendRequest{Term: n.term, Leader: n.id, PrevIndex: next - 1, PrevTerm: n.log[next-1].term, Commit: n.commit}
+	for i := next; i < uint64(len(n.log)) && len(req.Entries) < maxAppendEntries; i++ {
+		req.Entries = append(req.Entries, Entry{Term: n.log[i].term, Data: n.log[i].data})
+	}
+	return req
+}
+
+// Sending request (req) to peer (id) at address (addr) and handling response
+func (n *Node) send(id, addr string, req AppendRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.cfg.ElectionTimeout)
+	defer cancel()
+	resp, err := n.t.AppendEntries(ctx, addr, req)
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.sending != nil {
+		delete(n.sending, id)
+	}
+	if err != nil {
+		return
+	}
+	if resp.Term > n.term {
+		n.stepDown(resp.Term)
+		return
+	}
+	if n.role != leader || n.term != req.Term {
+		return
+	}
+	if !resp.Success {
+		n.next[id] = max(1, min(req.PrevIndex, resp.Index+1))
+		return
+	}
+	if resp.Index > n.match[id] {
+		n.match[id] = resp.Index
+	}
+	n.next[id] = n.match[id] + 1
+	n.advanceCommit()
+}
+
+// Committing entries of the current term replicated to majority, n.mux must be held by caller
+func (n *Node) advanceCommit() {
+	last, _ := n.last()
+	for index := last; index > n.commit && n.log[index].term == n.term; index-- {
+		replicas := 0
+		for id := range n.peers {
+			if id == n.id || n.match[id] >= index {
+				replicas++
+			}
+		}
+		if replicas >= n.quorum() {
+			n.commit = index
+			n.notifyApplier()
+			return
+		}
+	}
+}
+
+// Waking up applier without blocking, n.mux must be held by caller
+func (n *Node) notifyApplier() {
+	select {
+	case n.applyCh <- struct{}{}:
+	default:
+	}
+}
+
+// Handling RequestVote of candidate, called by Transport
+func (n *Node) HandleVote(req VoteRequest) VoteResponse {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if req.Term > n.term {
+		n.stepDown(req.Term)
+	}
+	resp := VoteResponse{Term: n.term}
+	if req.Term < n.term || n.votedFor != "" && n.votedFor != req.Candidate {
+		return resp
+	}
+	index, term := n.last()
+	if req.LastTerm < term || req.LastTerm == term && req.LastIndex < index {
+		// candidate's log is behind
+		return resp
+	}
+	n.votedFor = req.Candidate
+	n.resetTimeout()
+	resp.Granted = true
+	return resp
+}
+
+// Handling AppendEntries of leader, called by Transport
+func (n *Node) HandleAppend(req AppendRequest) AppendResponse {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if req.Term < n.term {
+		return AppendResponse{Term: n.term}
+	}
+	if req.Term > n.term || n.role != follower {
+		n.stepDown(req.Term)
+	}
+	n.leader = req.Leader
+	n.resetTimeout()
+	resp := AppendResponse{Term: n.term}
+	if last, _ := n.last(); req.PrevIndex > last {
+		resp.Index = last
+		return resp
+	}
+	if n.log[req.PrevIndex].term != req.PrevTerm {
+		resp.Index = req.PrevIndex - 1
+		return resp
+	}
+	changed := false
+	for i, e := range req.Entries {
+		index := req.PrevIndex + 1 + uint64(i)
+		if index < uint64(len(n.log)) {
+			if n.log[index].term == e.Term {
+				continue
+			}
+			// conflicting entries are removed, they can't be committed
+			for _, c := range n.log[index:] {
+				changed = changed || c.cmd.Op == opJoin || c.cmd.Op == opLeave
+			}
+			n.log = n.log[:index]
+		}
+		var cmd command
+		if err := json.Unmarshal(e.Data, &cmd); err != nil {
+			resp.Index = index - 1
+			return resp
+		}
+		n.log = append(n.log, logEntry{term: e.Term, cmd: cmd, data: e.Data})
+		changed = changed || cmd.Op == opJoin || cmd.Op == opLeave
+	}
+	if changed {
+		n.updatePeers()
+	}
+	resp.Success = true
+	resp.Index = req.PrevIndex + uint64(len(req.Entries))
+	if commit := min(req.Commit, resp.Index); commit > n.commit {
+		n.commit = commit
+		n.notifyApplier()
+	}
+	return resp
+}
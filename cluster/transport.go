@@ -0,0 +1,190 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Error happens if Network has no connected node at address
+var ErrUnreachable = errors.New("node is unreachable")
+
+// Paths of Handler
+const (
+	votePath   = "/raft/vote"
+	appendPath = "/raft/append"
+)
+
+// Delivery of Raft requests to node at address (addr)
+type Transport interface {
+	RequestVote(ctx context.Context, addr string, req VoteRequest) (VoteResponse, error)
+	AppendEntries(ctx context.Context, addr string, req AppendRequest) (AppendResponse, error)
+}
+
+// Transport delivering requests to nodes of the same process, for tests and embedding
+// Disconnect and Connect simulate network partitions
+type Network struct {
+	mux   sync.RWMutex
+	nodes map[string]*Node
+	down  map[string]bool
+}
+
+// Constructor
+func NewNetwork() *Network {
+	return &Network{nodes: map[string]*Node{}, down: map[string]bool{}}
+}
+
+// Adding node (n) at address (addr)
+func (nw *Network) Add(addr string, n *Node) {
+	nw.mux.Lock()
+	defer nw.mux.Unlock()
+	nw.nodes[addr] = n
+}
+
+// Cutting node at address (addr) off: it neither receives requests nor gets responses to its own requests
+func (nw *Network) Disconnect(addr string) {
+	nw.mux.Lock()
+	defer nw.mux.Unlock()
+	nw.down[addr] = true
+}
+
+// Connecting node at address (addr) back
+func (nw *Network) Connect(addr string) {
+	nw.mux.Lock()
+	defer nw.mux.Unlock()
+	delete(nw.down, addr)
+}
+
+// Node at address (addr) and whether both it and the sender at address (from) are connected
+func (nw *Network) node(from, addr string) (*Node, bool) {
+	nw.mux.RLock()
+	defer nw.mux.RUnlock()
+	n, ok := nw.nodes[addr]
+	return n, ok && !nw.down[addr] && !nw.down[from]
+}
+
+// Address of node (id) in the network
+func (nw *Network) addr(id string) string {
+	nw.mux.RLock()
+	defer nw.mux.RUnlock()
+	for addr, n := range nw.nodes {
+		if n.id == id {
+			return addr
+		}
+	}
+	return ""
+}
+
+func (nw *Network) RequestVote(ctx context.Context, addr string, req VoteRequest) (VoteResponse, error) {
+	n, ok := nw.node(nw.addr(req.Candidate), addr)
+	if !ok {
+		return VoteResponse{}, ErrUnreachable
+	}
+	if err := ctx.Err(); err != nil {
+		return VoteResponse{}, err
+	}
+	return n.HandleVote(req), nil
+}
+
+func (nw *Network) AppendEntries(ctx context.Context, addr string, req AppendRequest) (AppendResponse, error) {
+	n, ok := nw.node(nw.addr(req.Leader), addr)
+	if !ok {
+		return AppendResponse{}, ErrUnreachable
+	}
+	if err := ctx.Err(); err != nil {
+		return AppendResponse{}, err
+	}
+	return n.HandleAppend(req), nil
+}
+
+// Transport sending requests as JSON over HTTP to Handler of node, address is the base URL of Handler
+type httpTransport struct {
+	client *http.Client
+}
+
+// Constructor. Creates transport with (client), http.DefaultClient if nil
+func NewHTTPTransport(client *http.Client) Transport {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpTransport{client: client}
+}
+
+func (t *httpTransport) RequestVote(ctx context.Context, addr string, req VoteRequest) (VoteResponse, error) {
+	var resp VoteResponse
+	err := t.call(ctx, strings.TrimSuffix(addr, "/")+votePath, req, &resp)
+	return resp, err
+}
+
+func (t *httpTransport) AppendEntries(ctx context.Context, addr string, req AppendRequest) (AppendResponse, error) {
+	var resp AppendResponse
+	err := t.call(ctx, strings.TrimSuffix(addr, "/")+appendPath, req, &resp)
+	return resp, err
+}
+
+// Posting (req) to (url) and decoding response to (resp)
+func (t *httpTransport) call(ctx context.Context, url string, req, resp interface{}) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	res, err := t.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+// HTTP handler of Raft requests of node (n) sent by NewHTTPTransport: POST /raft/vote and POST /raft/append
+func Handler(n *Node) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(votePath, func(w http.ResponseWriter, r *http.Request) {
+		var req VoteRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		respond(w, n.HandleVote(req))
+	})
+	mux.HandleFunc(appendPath, func(w http.ResponseWriter, r *http.Request) {
+		var req AppendRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		respond(w, n.HandleAppend(req))
+	})
+	return mux
+}
+
+// Decoding POST request (r) to (req), false if error was written to (w)
+func decode(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Writing (resp) as JSON to (w)
+func respond(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+func TestHTTPTransport(t *testing.T) {
+	handlers := make([]http.Handler, 3)
+	cfg := testConfig
+	cfg.Peers = map[string]string{}
+	for i := range handlers {
+		i := i
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		t.Cleanup(s.Close)
+		cfg.Peers["n"+strconv.Itoa(i)] = s.URL
+	}
+	var nodes []*Node
+	for i := range handlers {
+		n := New("n"+strconv.Itoa(i), pubsub.New(), NewHTTPTransport(nil), cfg)
+		handlers[i] = Handler(n)
+		nodes = append(nodes, n)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, n := range nodes {
+		go n.Run(ctx)
+	}
+	l := waitLeader(t, nodes...)
+	l.Subscribe(ctx, "orders", "billing", nil)
+	if _, err := l.Publish(ctx, "orders", pubsub.Message{Body: []byte("order")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		waitFor(t, func() bool { return depth(n) == 1 })
+	}
+}
+
+func TestHandler(t *testing.T) {
+	n := New("a", pubsub.New(), NewNetwork(), Config{Peers: map[string]string{"a": "a"}})
+	s := httptest.NewServer(Handler(n))
+	defer s.Close()
+	tr := NewHTTPTransport(s.Client())
+	resp, err := tr.RequestVote(context.Background(), s.URL, VoteRequest{Term: 2, Candidate: "b"})
+	if err != nil || !resp.Granted || resp.Term != 2 {
+		t.Fatal(resp, err)
+	}
+	ar, err := tr.AppendEntries(context.Background(), s.URL, AppendRequest{Term: 1, Leader: "b"})
+	if err != nil || ar.Success || ar.Term != 2 {
+		t.Fatal(ar, err)
+	}
+	if id, _ := n.Leader(); id != "" {
+		t.Fatal(id)
+	}
+	ar, err = tr.AppendEntries(context.Background(), s.URL, AppendRequest{Term: 2, Leader: "b"})
+	if err != nil || !ar.Success || ar.Index != 0 {
+		t.Fatal(ar, err)
+	}
+	if id, _ := n.Leader(); id != "b" {
+		t.Fatal(id)
+	}
+	res, err := http.Get(s.URL + "/raft/vote")
+	if err != nil || res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal(res, err)
+	}
+	res.Body.Close()
+	res, err = http.Post(s.URL+"/raft/append", "application/json", strings.NewReader("{"))
+	if err != nil || res.StatusCode != http.StatusBadRequest {
+		t.Fatal(res, err)
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,82 @@
+/*
+Command pubsub-bench runs load of pubsubbench package against an in-process broker and prints throughput, latency
+percentiles and memory:
+
+	pubsub-bench [flags]
+
+Flags:
+
+	-topics n         number of topics (1)
+	-publishers n     number of publishing goroutines (4)
+	-subscribers n    number of subscriptions of every topic (1)
+	-size bytes       size of message bodies (128)
+	-messages n       messages of every publisher (100000), ignored if -duration is set
+	-duration d       how long publishers publish, e.g. 10s
+	-rate n           messages per second of every publisher, unlimited if zero
+	-priority         subscriptions use priority storage (pubsub.Options.Priority)
+	-max-messages n   limit of pending messages of subscriptions, exceeding messages are rejected and counted
+	-max-memory bytes memory limit of broker (pubsub.WithMaxMemory), exceeding messages are rejected and counted
+
+Run is stopped by interrupt, result of the part done is printed then.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubbench"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "pubsub-bench:", err)
+		os.Exit(1)
+	}
+}
+
+// Running load of command line arguments (args), usage is written to (stderr)
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("pubsub-bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var cfg pubsubbench.Config
+	fs.IntVar(&cfg.Topics, "topics", 1, "number of topics")
+	fs.IntVar(&cfg.Publishers, "publishers", 4, "number of publishing goroutines")
+	fs.IntVar(&cfg.Subscribers, "subscribers", 1, "number of subscriptions of every topic")
+	fs.IntVar(&cfg.PayloadSize, "size", 128, "size of message bodies in bytes")
+	fs.IntVar(&cfg.Messages, "messages", 100000, "messages of every publisher, ignored if -duration is set")
+	fs.DurationVar(&cfg.Duration, "duration", 0, "how long publishers publish")
+	fs.Float64Var(&cfg.Rate, "rate", 0, "messages per second of every publisher, unlimited if zero")
+	fs.BoolVar(&cfg.Options.Priority, "priority", false, "subscriptions use priority storage")
+	fs.IntVar(&cfg.Options.MaxMessages, "max-messages", 0, "limit of pending messages of subscriptions, rejected messages are counted")
+	maxMemory := fs.Int64("max-memory", 0, "memory limit of broker in bytes, publish is rejected when it's reached")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	cfg.Options.Overflow = pubsub.RejectPublish
+	ps := pubsub.New(pubsub.WithMaxMemory(*maxMemory, pubsub.RejectPublish))
+	res, err := pubsubbench.Run(ctx, ps, cfg)
+	if errors.Is(err, pubsubbench.ErrInvalidConfig) {
+		return err
+	}
+	fmt.Fprintln(stdout, res)
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
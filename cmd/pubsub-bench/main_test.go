@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git/pubsubbench"
+)
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	args := []string{"-topics", "2", "-publishers", "2", "-subscribers", "2", "-messages", "100", "-priority"}
+	if err := run(context.Background(), args, &out, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "published 200, rejected 0, received 400") {
+		t.Fatal(out.String())
+	}
+	out.Reset()
+	if err := run(context.Background(), []string{"-messages", "100", "-max-messages", "10", "-subscribers", "1"}, &out, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "published") {
+		t.Fatal(out.String())
+	}
+	if err := run(context.Background(), []string{"extra"}, &out, io.Discard); err != flag.ErrHelp {
+		t.Fatal(err)
+	}
+	if err := run(context.Background(), []string{"-publishers", "0"}, &out, io.Discard); err != pubsubbench.ErrInvalidConfig {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,281 @@
+/*
+Command pubsubctl administers and debugs a broker served by pubsubhttp.Handler:
+
+	pubsubctl [-addr url] [-auth value] <command> [arguments]
+
+Commands:
+
+	topics                           list topic names
+	depth [topic]                    show pending and in flight messages of subscriptions
+	publish <topic> [message]        publish message (standard input if omitted)
+	tail [-n count] [-rm] <topic> <sub>
+	                                 subscribe and print messages until interrupted or (count) messages are printed,
+	                                 -rm removes the subscription on exit
+	purge <topic> [sub]              drop pending messages of subscription (of all subscriptions of the topic)
+	snapshot [file]                  write snapshot of broker to file (standard output if omitted)
+
+-auth is sent as Authorization header, so the handler may map it to principal (see pubsubhttp.Handler.Principal).
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// How long tail waits for a message in one poll request
+const tailWait = 30 * time.Second
+
+const usage = `usage: pubsubctl [-addr url] [-auth value] <command> [arguments]
+
+commands:
+  topics                               list topic names
+  depth [topic]                        show pending and in flight messages of subscriptions
+  publish <topic> [message]            publish message (standard input if omitted)
+  tail [-n count] [-rm] <topic> <sub>  print messages of subscription
+  purge <topic> [sub]                  drop pending messages of subscription or topic
+  snapshot [file]                      write snapshot of broker to file or standard output
+`
+
+// Error happens if command line is invalid
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if err == errUsage {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "pubsubctl:", err)
+		os.Exit(1)
+	}
+}
+
+// Running command of command line arguments (args)
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("pubsubctl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addr := fs.String("addr", "http://localhost:8080", "base URL of pubsubhttp handler")
+	auth := fs.String("auth", "", "value of Authorization header")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+	c := &client{base: strings.TrimSuffix(*addr, "/"), auth: *auth}
+	args = fs.Args()[1:]
+	switch fs.Arg(0) {
+	case "topics":
+		return c.topics(ctx, stdout)
+	case "depth":
+		if len(args) > 1 {
+			return errUsage
+		}
+		return c.depth(ctx, stdout, append(args, "")[0])
+	case "publish":
+		return c.publish(ctx, stdin, args)
+	case "tail":
+		return c.tail(ctx, stdout, args)
+	case "purge":
+		if len(args) < 1 || len(args) > 2 {
+			return errUsage
+		}
+		return c.purge(ctx, stdout, args[0], append(args, "")[1])
+	case "snapshot":
+		if len(args) > 1 {
+			return errUsage
+		}
+		return c.snapshot(ctx, stdout, append(args, "")[0])
+	default:
+		return errUsage
+	}
+}
+
+// Client of pubsubhttp handler
+type client struct {
+	base string
+	auth string
+}
+
+// Sending request and returning response with status (expected), other responses are returned as errors
+func (c *client) do(ctx context.Context, method, path string, body io.Reader, expected ...int) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.auth != "" {
+		req.Header.Set("Authorization", c.auth)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(b)))
+}
+
+// Decoding JSON response of GET (path) into (v)
+func (c *client) get(ctx context.Context, path string, v any) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *client) topics(ctx context.Context, w io.Writer) error {
+	var tns []string
+	if err := c.get(ctx, "/topics", &tns); err != nil {
+		return err
+	}
+	for _, tn := range tns {
+		fmt.Fprintln(w, tn)
+	}
+	return nil
+}
+
+// Printing table of subscriptions of topic (tn), of all topics if tn is empty
+func (c *client) depth(ctx context.Context, w io.Writer, tn string) error {
+	var stats pubsub.BrokerStats
+	if err := c.get(ctx, "/stats", &stats); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tSUBSCRIPTION\tPENDING\tIN FLIGHT\tOLDEST")
+	for _, ts := range stats.Topics {
+		if tn != "" && ts.Name != tn {
+			continue
+		}
+		for _, ss := range ts.Subscriptions {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", ts.Name, ss.Name, ss.Pending, ss.InFlight, ss.OldestAge.Round(time.Millisecond))
+		}
+	}
+	return tw.Flush()
+}
+
+func (c *client) publish(ctx context.Context, stdin io.Reader, args []string) error {
+	var body io.Reader
+	switch len(args) {
+	case 1:
+		body = stdin
+	case 2:
+		body = strings.NewReader(args[1])
+	default:
+		return errUsage
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/topics/"+url.PathEscape(args[0]), body, http.StatusAccepted)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Subscribing and printing messages one per line until ctx is done
+func (c *client) tail(ctx context.Context, w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	n := fs.Int("n", 0, "exit after printing n messages")
+	rm := fs.Bool("rm", false, "remove subscription on exit")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return errUsage
+	}
+	tn, sn := fs.Arg(0), fs.Arg(1)
+	b, _ := json.Marshal(map[string]string{"topic": tn, "subscription": sn})
+	resp, err := c.do(ctx, http.MethodPost, "/subscriptions", strings.NewReader(string(b)), http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	q := url.Values{"topic": {tn}, "sub": {sn}}
+	if *rm {
+		defer func() {
+			// ctx may be done already
+			if resp, err := c.do(context.Background(), http.MethodDelete, "/subscriptions?"+q.Encode(), nil, http.StatusNoContent); err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+	q.Set("wait", tailWait.String())
+	for printed := 0; *n <= 0 || printed < *n; {
+		resp, err := c.do(ctx, http.MethodGet, "/poll?"+q.Encode(), nil, http.StatusOK, http.StatusNotFound)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusOK {
+			msg, err := io.ReadAll(resp.Body)
+			if err != nil {
+				resp.Body.Close()
+				return err
+			}
+			fmt.Fprintf(w, "%s\n", msg)
+			printed++
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+
+func (c *client) purge(ctx context.Context, w io.Writer, tn, sn string) error {
+	q := url.Values{"topic": {tn}}
+	if sn != "" {
+		q.Set("sub", sn)
+	}
+	resp, err := c.do(ctx, http.MethodDelete, "/messages?"+q.Encode(), nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Purged int `json:"purged"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "purged %d messages\n", res.Purged)
+	return nil
+}
+
+// Writing snapshot to file (path), to (w) if path is empty
+func (c *client) snapshot(ctx context.Context, w io.Writer, path string) error {
+	resp, err := c.do(ctx, http.MethodGet, "/snapshot", nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if path == "" {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubhttp"
+)
+
+// Running command against server (addr), returns standard output
+func ctl(t *testing.T, addr string, args ...string) string {
+	var out bytes.Buffer
+	if err := run(context.Background(), append([]string{"-addr", addr}, args...), strings.NewReader("from stdin"), &out); err != nil {
+		t.Fatal(args, err)
+	}
+	return out.String()
+}
+
+func TestRun(t *testing.T) {
+	lib := pubsub.New()
+	srv := httptest.NewServer(pubsubhttp.NewHandler(lib))
+	defer srv.Close()
+	lib.Subscribe("orders", "billing")
+	ctl(t, srv.URL, "publish", "orders", "first")
+	ctl(t, srv.URL, "publish", "orders")
+	if out := ctl(t, srv.URL, "topics"); out != "orders\n" {
+		t.Fatal(out)
+	}
+	if out := ctl(t, srv.URL, "depth", "orders"); !strings.Contains(out, "billing") || !strings.Contains(out, " 2 ") {
+		t.Fatal(out)
+	}
+	if out := ctl(t, srv.URL, "tail", "-n", "2", "orders", "billing"); out != "first\nfrom stdin\n" {
+		t.Fatal(out)
+	}
+	lib.Publish("orders", []byte("message"))
+	if out := ctl(t, srv.URL, "purge", "orders", "billing"); out != "purged 1 messages\n" {
+		t.Fatal(out)
+	}
+	path := filepath.Join(t.TempDir(), "snapshot")
+	ctl(t, srv.URL, "snapshot", path)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := pubsub.New().Restore(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(context.Background(), []string{"-addr", srv.URL, "purge", "unknown"}, nil, &bytes.Buffer{}); err == nil {
+		t.FailNow()
+	}
+	if err := run(context.Background(), []string{"unknown"}, nil, &bytes.Buffer{}); err != errUsage {
+		t.FailNow()
+	}
+}
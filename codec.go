@@ -0,0 +1,127 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"mime"
+	"sync"
+)
+
+// Header of messages with content type of body, e.g. "application/json"
+const HeaderContentType = "content-type"
+
+// Error happens if value passed to BinaryCodec doesn't implement encoding.BinaryMarshaler/BinaryUnmarshaler
+var ErrNotBinary = errors.New("value doesn't implement binary marshalling")
+
+// Codec converts values to message bodies and back, it's used by typed wrapper, HTTP layer and snapshots
+// Unmarshal receives a pointer to the value, like json.Unmarshal. Codec must be safe for concurrent use
+//
+// Protocol buffers codec is typed.Proto, MessagePack or CBOR are plugged with RegisterCodec, e.g.:
+//
+//	type MsgPack struct{}
+//	func (MsgPack) ContentType() string { return "application/msgpack" }
+//	func (MsgPack) Marshal(v any) ([]byte, error) { return msgpack.Marshal(v) }
+//	func (MsgPack) Unmarshal(b []byte, v any) error { return msgpack.Unmarshal(b, v) }
+type Codec interface {
+	// Media type of encoded values without parameters
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(b []byte, v any) error
+}
+
+// Registered codecs by content type
+var (
+	codecsMux sync.RWMutex
+	codecs    = map[string]Codec{}
+)
+
+func init() {
+	for _, c := range []Codec{JSONCodec{}, GobCodec{}, BinaryCodec{}} {
+		RegisterCodec(c)
+	}
+}
+
+// Registering codec (c) under its content type, the previous codec of the content type is replaced
+// JSONCodec, GobCodec and BinaryCodec are registered already
+func RegisterCodec(c Codec) {
+	swapCodec(c.ContentType(), c)
+}
+
+// Replacing codec of content type (ct) with (c), nil removes it, returns the previous codec (nil if there was none)
+func swapCodec(ct string, c Codec) Codec {
+	codecsMux.Lock()
+	defer codecsMux.Unlock()
+	prev := codecs[ct]
+	if c == nil {
+		delete(codecs, ct)
+	} else {
+		codecs[ct] = c
+	}
+	return prev
+}
+
+// Codec registered for content type (ct), parameters like charset are ignored, nil if there is no such codec
+func CodecFor(ct string) Codec {
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		ct = mt
+	}
+	codecsMux.RLock()
+	defer codecsMux.RUnlock()
+	return codecs[ct]
+}
+
+// JSONCodec uses encoding/json, content type "application/json"
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return "application/json" }
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(b []byte, v any) error {
+	return json.Unmarshal(b, v)
+}
+
+// GobCodec uses encoding/gob, content type "application/x-gob"
+// Every message carries type information, so it's bigger than JSON for small values
+type GobCodec struct{}
+
+func (GobCodec) ContentType() string { return "application/x-gob" }
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(b []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// BinaryCodec uses encoding.BinaryMarshaler and encoding.BinaryUnmarshaler implemented by values,
+// content type "application/octet-stream"
+type BinaryCodec struct{}
+
+func (BinaryCodec) ContentType() string { return "application/octet-stream" }
+
+func (BinaryCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, ErrNotBinary
+	}
+	return m.MarshalBinary()
+}
+
+func (BinaryCodec) Unmarshal(b []byte, v any) error {
+	u, ok := v.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return ErrNotBinary
+	}
+	return u.UnmarshalBinary(b)
+}
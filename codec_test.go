@@ -0,0 +1,59 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// Codec of plain text values
+type textCodec struct{}
+
+func (textCodec) ContentType() string { return "text/plain" }
+
+func (textCodec) Marshal(v any) ([]byte, error) { return []byte(v.(string)), nil }
+
+func (textCodec) Unmarshal(b []byte, v any) error {
+	*v.(*string) = string(b)
+	return nil
+}
+
+func TestCodecFor(t *testing.T) {
+	if _, ok := CodecFor("application/json; charset=utf-8").(JSONCodec); !ok {
+		t.FailNow()
+	}
+	if _, ok := CodecFor("application/x-gob").(GobCodec); !ok {
+		t.FailNow()
+	}
+	if CodecFor("application/x-unregistered") != nil || CodecFor("") != nil {
+		t.FailNow()
+	}
+	prev := CodecFor("text/plain")
+	t.Cleanup(func() { swapCodec("text/plain", prev) })
+	RegisterCodec(textCodec{})
+	c := CodecFor("text/plain")
+	var s string
+	if b, _ := c.Marshal("hello"); c.Unmarshal(b, &s) != nil || s != "hello" {
+		t.Fatal(s)
+	}
+}
+
+func TestWithSnapshotCodec(t *testing.T) {
+	lib := New(WithSnapshotCodec(JSONCodec{}))
+	lib.SubscribeWithOptions("orders", "billing", Options{MaxMessages: 10, Filter: HeaderExists("id")})
+	lib.PublishMsg("orders", Message{Headers: map[string]string{"id": "1"}, Body: []byte("a")})
+	var buf bytes.Buffer
+	if err := lib.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Fatal(buf.String())
+	}
+	restored := New(WithSnapshotCodec(JSONCodec{}))
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ := restored.PollMsg("orders", "billing"); msg == nil || string(msg.Body) != "a" || msg.Headers["id"] != "1" {
+		t.Fatal(msg)
+	}
+}
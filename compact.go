@@ -0,0 +1,54 @@
+package pubsub
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Keeping message (m) as the latest value of its key if the topic is compacted (see TopicConfig.Compact)
+// Message with empty body removes the key, messages without key aren't kept
+// s.mux held for writing or lockPublish must be held by caller
+func (s *subscriptions) compact(m *message) {
+	if cfg := s.config.Load(); cfg == nil || !cfg.Compact || m.Key == "" {
+		return
+	}
+	if len(m.Body) == 0 {
+		delete(s.latest, m.Key)
+		return
+	}
+	if s.latest == nil {
+		s.latest = map[string]*message{}
+	}
+	m.pin()
+	s.latest[m.Key] = m
+}
+
+// The latest not expired messages of keys of compacted topic in order of publish, expired ones are forgotten
+// s.mux must be held by caller
+func (s *subscriptions) compacted(now time.Time) []*message {
+	msgs := make([]*message, 0, len(s.latest))
+	for key, m := range s.latest {
+		if m.expired(now) {
+			delete(s.latest, key)
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	slices.SortFunc(msgs, func(a, b *message) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	return msgs
+}
+
+// Adding the latest messages of keys of compacted topic (s) to just created subscription (sub)
+// s.mux must be held by caller
+func (s *subscriptions) deliverCompacted(sub *subscription) {
+	if len(s.latest) == 0 {
+		return
+	}
+	for _, m := range s.compacted(s.clock.Now()) {
+		sub.push(m)
+	}
+	sub.cond.Broadcast()
+}
@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTopicConfig_Compact(t *testing.T) {
+	lib := New()
+	tn := "config"
+	if err := lib.CreateTopic(tn, TopicConfig{Compact: true}); err != nil {
+		t.Fatal(err)
+	}
+	lib.Subscribe(tn, "early")
+	lib.PublishWithKey(tn, "a", []byte("a1"))
+	lib.PublishWithKey(tn, "b", []byte("b1"))
+	lib.PublishWithKey(tn, "a", []byte("a2"))
+	lib.PublishWithKey(tn, "c", []byte("c1"))
+	lib.PublishWithKey(tn, "c", nil)
+	lib.Publish(tn, []byte("no key"))
+	// current subscriptions get every message
+	if depth, _ := lib.Depth(tn, "early"); depth != 6 {
+		t.Fatal(depth)
+	}
+	lib.Subscribe(tn, "late")
+	for _, want := range []string{"b1", "a2", ""} {
+		if b, _ := lib.Poll(tn, "late"); string(b) != want {
+			t.Fatal(string(b), want)
+		}
+	}
+	// disabled compaction forgets the values
+	lib.ConfigureTopic(tn, TopicConfig{})
+	lib.Subscribe(tn, "later")
+	if depth, _ := lib.Depth(tn, "later"); depth != 0 {
+		t.Fatal(depth)
+	}
+}
+
+func TestTopicConfig_Compact_Retention(t *testing.T) {
+	lib := New()
+	tn := "config"
+	lib.CreateTopic(tn, TopicConfig{Compact: true, Retention: 10 * time.Millisecond})
+	lib.PublishWithKey(tn, "a", []byte("a1"))
+	time.Sleep(20 * time.Millisecond)
+	lib.Subscribe(tn, "late")
+	if depth, _ := lib.Depth(tn, "late"); depth != 0 {
+		t.Fatal(depth)
+	}
+}
+
+func TestTopicConfig_Compact_Snapshot(t *testing.T) {
+	lib := New()
+	tn := "config"
+	lib.CreateTopic(tn, TopicConfig{Compact: true})
+	lib.PublishWithKey(tn, "a", []byte("a1"))
+	lib.PublishWithKey(tn, "a", []byte("a2"))
+	var buf bytes.Buffer
+	if err := lib.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored.Subscribe(tn, "late")
+	if msg, _ := restored.PollMsg(tn, "late"); msg == nil || string(msg.Body) != "a2" || msg.Key != "a" {
+		t.Fatal(msg)
+	}
+}
@@ -0,0 +1,133 @@
+package pubsub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Error happens if TopicConfig.Compression names compressor which isn't registered with RegisterCompressor
+var ErrUnknownCompressor = errors.New("unknown compressor")
+
+// Error happens if body of polled message can't be decompressed, the message stays pending
+var ErrDecompress = errors.New("message can't be decompressed")
+
+// Default TopicConfig.CompressThreshold, smaller messages aren't worth compressing
+const DefaultCompressThreshold = 1024
+
+// Compressor of stored message bodies, must be safe for concurrent use
+type Compressor interface {
+	// Compressing body (b), b must not be modified
+	Compress(b []byte) ([]byte, error)
+	// Restoring body compressed by Compress
+	Decompress(b []byte) ([]byte, error)
+}
+
+// Registered compressors by name, "gzip" is built in
+var (
+	compressorsMux sync.RWMutex
+	compressors    = map[string]Compressor{"gzip": gzipCompressor{}}
+)
+
+// Registering compressor (c) under name, so topics may use it with TopicConfig.Compression
+// Compressors of third-party packages like snappy or zstd are registered this way, the name replaces the previous
+// compressor with the same name
+func RegisterCompressor(name string, c Compressor) {
+	compressorsMux.Lock()
+	defer compressorsMux.Unlock()
+	compressors[name] = c
+}
+
+// Compressor registered under name, nil if there is no such
+func compressorOf(name string) Compressor {
+	compressorsMux.RLock()
+	defer compressorsMux.RUnlock()
+	return compressors[name]
+}
+
+// Compressing body of message (m) published to topic name (tn) according to TopicConfig.Compression of the topic
+// Body is kept as is if compression doesn't make it smaller
+// Message must not be visible to other goroutines yet
+func (p *pubSub) compress(tn string, m *message) {
+	if !p.compression.Load() || m.codec != nil {
+		return
+	}
+	var cfg *TopicConfig
+	if subs, ok := p.topics.get(tn); ok {
+		cfg = subs.config.Load()
+	}
+	if cfg == nil || cfg.Compression == "" {
+		return
+	}
+	threshold := cfg.CompressThreshold
+	if threshold <= 0 {
+		threshold = DefaultCompressThreshold
+	}
+	c := compressorOf(cfg.Compression)
+	if c == nil || len(m.Body) < threshold {
+		return
+	}
+	b, err := c.Compress(m.Body)
+	if err != nil {
+		p.log.Warn("message isn't compressed", "topic", tn, "id", m, "error", err)
+		return
+	}
+	if len(b) < len(m.Body) {
+		m.Body, m.codec, m.compression = b, c, cfg.Compression
+	}
+}
+
+// Original body of message, it's decrypted and decompressed if needed
+// Error wraps ErrDecrypt if body can't be decrypted or ErrDecompress if it can't be decompressed
+func (m *message) body() ([]byte, error) {
+	b := m.Body
+	if m.enc != nil {
+		var err error
+		if b, err = m.decrypt(b); err != nil {
+			return nil, err
+		}
+	}
+	if m.codec == nil {
+		return b, nil
+	}
+	d, err := m.codec.Decompress(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecompress, err)
+	}
+	return d, nil
+}
+
+// Compressor of "gzip" name, writers are reused
+type gzipCompressor struct{}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+func (gzipCompressor) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
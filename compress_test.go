@@ -0,0 +1,128 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopicConfig_Compression(t *testing.T) {
+	lib := New()
+	tn, sn := "events", "archive"
+	if err := lib.CreateTopic("other", TopicConfig{Compression: "lz4"}); err != ErrUnknownCompressor {
+		t.Fatal(err)
+	}
+	if err := lib.CreateTopic(tn, TopicConfig{Compression: "gzip", CompressThreshold: 100}); err != nil {
+		t.Fatal(err)
+	}
+	lib.Subscribe(tn, sn)
+	large := []byte(strings.Repeat(`{"name":"value"},`, 100))
+	lib.Publish(tn, large)
+	lib.Publish(tn, []byte("small"))
+	// the small message isn't compressed
+	if b := lib.Stats().Topics[0].Subscriptions[0].Bytes; b >= int64(len(large)) || b <= 5 {
+		t.Fatal(b)
+	}
+	if b, _ := lib.Peek(tn, sn); !bytes.Equal(b, large) {
+		t.Fatal(string(b))
+	}
+	if msg, _ := lib.PollMsg(tn, sn); msg == nil || !bytes.Equal(msg.Body, large) {
+		t.Fatal(msg)
+	}
+	if b, _ := lib.Poll(tn, sn); string(b) != "small" {
+		t.Fatal(string(b))
+	}
+	for _, poll := range []func() []byte{
+		func() []byte { b, _, _ := lib.PollAck(tn, sn, time.Minute); return b },
+		func() []byte { b, _ := lib.PollWait(context.Background(), tn, sn); return b },
+		func() []byte { msgs, _ := lib.PollN(tn, sn, 1); return msgs[0] },
+	} {
+		lib.Publish(tn, large)
+		if b := poll(); !bytes.Equal(b, large) {
+			t.Fatal(string(b))
+		}
+	}
+}
+
+func TestTopicConfig_Compression_Snapshot(t *testing.T) {
+	lib := New()
+	tn, sn := "events", "archive"
+	lib.CreateTopic(tn, TopicConfig{Compression: "gzip"})
+	lib.Subscribe(tn, sn)
+	large := []byte(strings.Repeat("x", 2*DefaultCompressThreshold))
+	lib.Publish(tn, large)
+	var buf bytes.Buffer
+	if err := lib.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := restored.Poll(tn, sn); !bytes.Equal(b, large) {
+		t.Fatal(len(b))
+	}
+}
+
+// Compressor counting compressed messages
+type countingCompressor struct {
+	gzipCompressor
+	n *int
+}
+
+func (c countingCompressor) Compress(b []byte) ([]byte, error) {
+	*c.n++
+	return c.gzipCompressor.Compress(b)
+}
+
+// Compressor which can't decompress anything
+type brokenCompressor struct {
+	gzipCompressor
+}
+
+func (brokenCompressor) Decompress([]byte) ([]byte, error) {
+	return nil, errors.New("corrupted")
+}
+
+func TestTopicConfig_Compression_Broken(t *testing.T) {
+	RegisterCompressor("broken", brokenCompressor{})
+	lib := New()
+	lib.CreateTopic("events", TopicConfig{Compression: "broken", CompressThreshold: 1})
+	lib.SubscribeWithOptions("events", "archive", Options{MaxDeliveries: 2})
+	lib.Publish("events", []byte(strings.Repeat("a", 100)))
+	if b, err := lib.Poll("events", "archive"); !errors.Is(err, ErrDecompress) || b != nil {
+		t.Fatal(string(b), err)
+	}
+	if msgs, err := lib.PollN("events", "archive", 10); !errors.Is(err, ErrDecompress) || msgs != nil {
+		t.Fatal(msgs, err)
+	}
+	// failed deliveries of PollAck move the message to dead-letter topic
+	for i := 0; i < 2; i++ {
+		if _, _, err := lib.PollAck("events", "archive", time.Minute); !errors.Is(err, ErrDecompress) {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := lib.Depth("events", "archive"); n != 0 {
+		t.Fatal(n)
+	}
+	if n, _ := lib.Depth(DeadLetterTopic("events"), "archive"); n != 1 {
+		t.Fatal(n)
+	}
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	var n int
+	RegisterCompressor("counting", countingCompressor{n: &n})
+	lib := New()
+	lib.CreateTopic("events", TopicConfig{Compression: "counting", CompressThreshold: 10})
+	lib.Subscribe("events", "archive")
+	body := strings.Repeat("a", 100)
+	lib.Publish("events", []byte(body))
+	lib.Publish("events", []byte("short"))
+	if b, _ := lib.Poll("events", "archive"); string(b) != body || n != 1 {
+		t.Fatal(string(b), n)
+	}
+}
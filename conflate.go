@@ -0,0 +1,33 @@
+package pubsub
+
+import "sync/atomic"
+
+// Key of message (m) for Options.Conflate, empty if subscription doesn't conflate messages or message has no key
+func (s *subscription) conflationKey(m *message) string {
+	if !s.opts.Conflate {
+		return ""
+	}
+	if s.opts.ConflateHeader != "" {
+		return m.Headers[s.opts.ConflateHeader]
+	}
+	return m.Key
+}
+
+// Replacing pending message of (key) with message (m), false if the key has no pending message
+func (s *subscription) conflate(key string, m *message) bool {
+	if s.conflated == nil {
+		s.conflated = map[string]*message{}
+	}
+	old, ok := s.conflated[key]
+	if !ok || !s.swap(old, m) {
+		return false
+	}
+	// message is kept by the subscription and by conflated
+	m.retain()
+	m.pin()
+	s.conflated[key] = m
+	atomic.AddUint64(&s.dropped, 1)
+	s.log.Debug("message dropped", "id", old, "reason", "conflated")
+	s.emit(EventMessageDropped, old, 1, "conflated")
+	return true
+}
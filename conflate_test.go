@@ -0,0 +1,54 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPubSub_Conflate(t *testing.T) {
+	lib := New()
+	lib.SubscribeWithOptions("prices", "slow", Options{Conflate: true})
+	lib.PublishWithKey("prices", "EUR", []byte("1.10"))
+	lib.PublishWithKey("prices", "USD", []byte("1.00"))
+	lib.PublishWithKey("prices", "EUR", []byte("1.11"))
+	lib.PublishWithKey("prices", "EUR", []byte("1.12"))
+	lib.Publish("prices", []byte("no key"))
+	lib.Publish("prices", []byte("no key"))
+	if depth, _ := lib.Depth("prices", "slow"); depth != 4 {
+		t.Fatal(depth)
+	}
+	// replaced message takes place of the old one
+	for _, want := range []string{"1.12", "1.00", "no key", "no key"} {
+		if b, _ := lib.Poll("prices", "slow"); string(b) != want {
+			t.Fatal(string(b))
+		}
+	}
+	if dropped := lib.Stats().Topics[0].Subscriptions[0].Dropped; dropped != 2 {
+		t.Fatal(dropped)
+	}
+	// the key has no pending message anymore
+	lib.PublishWithKey("prices", "EUR", []byte("1.13"))
+	if b, _ := lib.Poll("prices", "slow"); string(b) != "1.13" {
+		t.Fatal(string(b))
+	}
+}
+
+func TestPubSub_Conflate_Header(t *testing.T) {
+	lib := New()
+	lib.SubscribeWithOptions("prices", "slow", Options{Conflate: true, ConflateHeader: "symbol", Priority: true})
+	for _, b := range []string{"1", "2"} {
+		_, _ = lib.PublishMsg("prices", Message{Headers: map[string]string{"symbol": "EUR"}, Body: []byte(b)})
+	}
+	if b, _ := lib.Poll("prices", "slow"); string(b) != "2" {
+		t.Fatal(string(b))
+	}
+	// message in flight isn't replaced
+	_, _ = lib.PublishMsg("prices", Message{Headers: map[string]string{"symbol": "EUR"}, Body: []byte("3")})
+	if _, _, err := lib.PollAck("prices", "slow", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = lib.PublishMsg("prices", Message{Headers: map[string]string{"symbol": "EUR"}, Body: []byte("4")})
+	if depth, _ := lib.Depth("prices", "slow"); depth != 1 {
+		t.Fatal(depth)
+	}
+}
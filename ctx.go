@@ -0,0 +1,168 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+)
+
+// PubSuberCtx is a variant of the core of PubSuber taking context of caller in every call, so deadlines, cancellation
+// and values (e.g. trace spans) reach the broker and its middlewares (see Use). Calls with done context return
+// ctx.Err() without doing anything, waiting calls return it when context is done while they wait
+type PubSuberCtx interface {
+	// Publish message, see PubSuber.TryPublish
+	Publish(ctx context.Context, tn string, b []byte) error
+	// Publish message with headers, returns message ID
+	PublishMsg(ctx context.Context, tn string, msg Message) (string, error)
+	// Subscribe for messages by topic and subscription name
+	Subscribe(ctx context.Context, tn, sn string) error
+	// Unsubscribe for messages by topic and subscription name
+	Unsubscribe(ctx context.Context, tn, sn string) error
+	// Fetching message without waiting, nil if there are no messages
+	Poll(ctx context.Context, tn, sn string) ([]byte, error)
+	// Fetching message with headers and metadata without waiting
+	PollMsg(ctx context.Context, tn, sn string) (*Message, error)
+	// Waiting for a message until it arrives or ctx is done
+	PollWait(ctx context.Context, tn, sn string) ([]byte, error)
+	// Waiting for a message with headers and metadata until it arrives or ctx is done
+	PollMsgWait(ctx context.Context, tn, sn string) (*Message, error)
+	// Fetching message which stays in flight until Ack is called or visibility timeout expires
+	PollAck(ctx context.Context, tn, sn string, visibility time.Duration) ([]byte, AckToken, error)
+	// Acknowledging message polled with PollAck
+	Ack(ctx context.Context, tn, sn string, token AckToken) error
+	// Returning message polled with PollAck to the queue
+	Nack(ctx context.Context, tn, sn string, token AckToken, requeue bool) error
+	// Extending visibility timeout of message polled with PollAck
+	ExtendAck(ctx context.Context, tn, sn string, token AckToken, visibility time.Duration) error
+	// Number of pending messages of subscription
+	Depth(ctx context.Context, tn, sn string) (int, error)
+	// Rejecting new messages, waiting for queues to drain until ctx is done and releasing all resources
+	Close(ctx context.Context) error
+	// Broker the variant calls, for methods without context
+	Unwrap() PubSuber
+}
+
+// PubSuberCtx calling broker (ps), p - the same broker if it was created by New, so context is passed to middlewares
+type ctxBroker struct {
+	ps PubSuber
+	p  *pubSub
+}
+
+// Constructor. Creates PubSuberCtx calling broker (ps). Context reaches middlewares of brokers created by New, other
+// brokers (facades, clients, ...) only check it before calls
+func NewCtx(ps PubSuber) PubSuberCtx {
+	c := &ctxBroker{ps: ps}
+	c.p, _ = ps.(*pubSub)
+	return c
+}
+
+func (c *ctxBroker) Publish(ctx context.Context, tn string, b []byte) error {
+	_, err := c.PublishMsg(ctx, tn, Message{Body: b})
+	return err
+}
+
+func (c *ctxBroker) PublishMsg(ctx context.Context, tn string, msg Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if c.p == nil {
+		return c.ps.PublishMsg(tn, msg)
+	}
+	m := c.p.newMessage(tn, msg)
+	_, err := c.p.publishCtx(ctx, tn, m)
+	id := m.id()
+	m.release()
+	return id, err
+}
+
+func (c *ctxBroker) Subscribe(ctx context.Context, tn, sn string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.ps.Subscribe(tn, sn)
+	return nil
+}
+
+func (c *ctxBroker) Unsubscribe(ctx context.Context, tn, sn string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.ps.Unsubscribe(tn, sn)
+	return nil
+}
+
+func (c *ctxBroker) Poll(ctx context.Context, tn, sn string) ([]byte, error) {
+	return bodyOf(c.PollMsg(ctx, tn, sn))
+}
+
+func (c *ctxBroker) PollMsg(ctx context.Context, tn, sn string) (*Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.p == nil {
+		return c.ps.PollMsg(tn, sn)
+	}
+	msg, err := c.p.interceptPoll(ctx, tn, sn, c.p.fetch)
+	if msg == nil {
+		return nil, c.p.empty(err)
+	}
+	return msg, err
+}
+
+// Pending message is returned even if ctx is done already, like PubSuber.PollWait
+func (c *ctxBroker) PollWait(ctx context.Context, tn, sn string) ([]byte, error) {
+	return c.ps.PollWait(ctx, tn, sn)
+}
+
+func (c *ctxBroker) PollMsgWait(ctx context.Context, tn, sn string) (*Message, error) {
+	return c.ps.PollMsgWait(ctx, tn, sn)
+}
+
+func (c *ctxBroker) PollAck(ctx context.Context, tn, sn string, visibility time.Duration) ([]byte, AckToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	if c.p == nil {
+		return c.ps.PollAck(tn, sn, visibility)
+	}
+	msg, token, err := c.p.pollAckMsg(ctx, tn, sn, visibility)
+	if msg == nil {
+		return nil, 0, c.p.empty(err)
+	}
+	return msg.Body, token, err
+}
+
+func (c *ctxBroker) Ack(ctx context.Context, tn, sn string, token AckToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ps.Ack(tn, sn, token)
+}
+
+func (c *ctxBroker) Nack(ctx context.Context, tn, sn string, token AckToken, requeue bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ps.Nack(tn, sn, token, requeue)
+}
+
+func (c *ctxBroker) ExtendAck(ctx context.Context, tn, sn string, token AckToken, visibility time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ps.ExtendAck(tn, sn, token, visibility)
+}
+
+func (c *ctxBroker) Depth(ctx context.Context, tn, sn string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.ps.Depth(tn, sn)
+}
+
+func (c *ctxBroker) Close(ctx context.Context) error {
+	return c.ps.Close(ctx)
+}
+
+func (c *ctxBroker) Unwrap() PubSuber {
+	return c.ps
+}
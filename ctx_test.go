@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+func TestNewCtx(t *testing.T) {
+	lib := New()
+	var published, polled any
+	lib.Use(Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(ctx context.Context, tn string, msg *Message) error {
+				published = ctx.Value(ctxKey{})
+				return next(ctx, tn, msg)
+			}
+		},
+		Poll: func(next PollFunc) PollFunc {
+			return func(ctx context.Context, tn, sn string) (*Message, error) {
+				polled = ctx.Value(ctxKey{})
+				return next(ctx, tn, sn)
+			}
+		},
+	})
+	ps := NewCtx(lib)
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace")
+	if err := ps.Subscribe(ctx, "orders", "billing"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Publish(ctx, "orders", []byte("1")); err != nil || published != "trace" {
+		t.Fatal(err, published)
+	}
+	if msg, err := ps.Poll(ctx, "orders", "billing"); string(msg) != "1" || err != nil || polled != "trace" {
+		t.Fatal(msg, err, polled)
+	}
+	_ = ps.Publish(context.Background(), "orders", []byte("2"))
+	polled = nil
+	msg, token, err := ps.PollAck(ctx, "orders", "billing", time.Minute)
+	if string(msg) != "2" || err != nil || polled != "trace" {
+		t.Fatal(msg, err, polled)
+	}
+	if err := ps.Ack(ctx, "orders", "billing", token); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is done with done context
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := ps.Publish(cancelled, "orders", []byte("3")); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if depth, _ := ps.Depth(ctx, "orders", "billing"); depth != 0 {
+		t.Fatal(depth)
+	}
+	if _, err := ps.PollWait(cancelled, "orders", "billing"); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if ps.Unwrap() != lib {
+		t.Fatal()
+	}
+}
+
+func TestNewCtx_Namespace(t *testing.T) {
+	ps := NewCtx(New().Namespace("tenant"))
+	ctx := context.Background()
+	_ = ps.Subscribe(ctx, "orders", "billing")
+	if _, err := ps.PublishMsg(ctx, "orders", Message{Body: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := ps.PollMsg(ctx, "orders", "billing"); msg == nil || string(msg.Body) != "1" || err != nil {
+		t.Fatal(msg, err)
+	}
+	if err := ps.Unsubscribe(ctx, "orders", "billing"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+)
+
+// Returned by deliver if message is dropped as duplicate, publish reports success then
+var errDuplicate = errors.New("duplicate message")
+
+// Message ID remembered by topic for TopicConfig.DedupWindow
+type seenID struct {
+	id string
+	at time.Time
+}
+
+// Subscriptions list of topic name (tn) among (targets) if the topic deduplicates messages, nil otherwise
+// Subscriptions lists must be locked by caller
+func dedupOf(tn string, targets []*subscriptions) *subscriptions {
+	for _, subs := range targets {
+		if cfg := subs.config.Load(); subs.tn == tn && cfg != nil && cfg.DedupWindow > 0 {
+			return subs
+		}
+	}
+	return nil
+}
+
+// Checks if message (m) with producer-supplied ID was published to the topic within TopicConfig.DedupWindow
+// before the moment now, forgets expired IDs. s.mux held for writing or lockPublish must be held by caller, nil list
+// has no duplicates
+func (s *subscriptions) duplicate(m *message, now time.Time) bool {
+	if s == nil || !m.ownID {
+		return false
+	}
+	s.forget(now.Add(-s.config.Load().DedupWindow))
+	_, ok := s.seen[m.ID]
+	return ok
+}
+
+// Remembering ID of message (m) published at the moment now
+// s.mux held for writing or lockPublish must be held by caller
+func (s *subscriptions) remember(m *message, now time.Time) {
+	if s == nil || !m.ownID {
+		return
+	}
+	if s.seen == nil {
+		s.seen = map[string]time.Time{}
+	}
+	s.seen[m.ID] = now
+	s.seenOrder = append(s.seenOrder, seenID{id: m.ID, at: now})
+}
+
+// Forgetting IDs remembered before (edge)
+// s.mux held for writing or lockPublish must be held by caller
+func (s *subscriptions) forget(edge time.Time) {
+	i := 0
+	for ; i < len(s.seenOrder) && !s.seenOrder[i].at.After(edge); i++ {
+		// ID may be remembered again later, then it stays
+		if e := s.seenOrder[i]; s.seen[e.id].Equal(e.at) {
+			delete(s.seen, e.id)
+		}
+	}
+	if i > 0 {
+		clear(s.seenOrder[:i])
+		s.seenOrder = s.seenOrder[i:]
+	}
+}
+
+// Removing messages of transaction (items) published before within TopicConfig.DedupWindow of their topics or
+// repeating IDs of earlier messages of the transaction
+// Subscriptions lists of items must be locked by caller
+func dropDuplicates(items []txItem, now time.Time) []txItem {
+	type key struct {
+		subs *subscriptions
+		id   string
+	}
+	var added map[key]bool
+	kept := items[:0]
+	for _, it := range items {
+		dedup := dedupOf(it.tn, it.targets)
+		if dedup == nil || !it.m.ownID {
+			kept = append(kept, it)
+			continue
+		}
+		k := key{dedup, it.m.ID}
+		if added[k] || dedup.duplicate(it.m, now) {
+			dedup.log.Debug("message dropped", "id", it.m, "reason", "duplicate")
+			continue
+		}
+		if added == nil {
+			added = map[key]bool{}
+		}
+		added[k] = true
+		kept = append(kept, it)
+	}
+	return kept
+}
@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopicConfig_DedupWindow(t *testing.T) {
+	lib := New(WithNoSubscribersError(true))
+	tn, sn := "orders", "billing"
+	if err := lib.CreateTopic(tn, TopicConfig{DedupWindow: 20 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	lib.Subscribe(tn, sn)
+	for i := 0; i < 2; i++ {
+		if id, err := lib.PublishMsg(tn, Message{ID: "order-1", Body: []byte("1")}); err != nil || id != "order-1" {
+			t.Fatal(id, err)
+		}
+	}
+	// messages without producer-supplied ID aren't deduplicated
+	lib.Publish(tn, []byte("2"))
+	lib.Publish(tn, []byte("2"))
+	if depth, _ := lib.Depth(tn, sn); depth != 3 {
+		t.Fatal(depth)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := lib.PublishMsg(tn, Message{ID: "order-1", Body: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	if depth, _ := lib.Depth(tn, sn); depth != 4 {
+		t.Fatal(depth)
+	}
+	// disabled deduplication forgets IDs
+	if err := lib.ConfigureTopic(tn, TopicConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	lib.PublishMsg(tn, Message{ID: "order-1"})
+	if depth, _ := lib.Depth(tn, sn); depth != 5 {
+		t.Fatal(depth)
+	}
+}
+
+func TestTopicConfig_DedupWindow_Rejected(t *testing.T) {
+	lib := New()
+	tn, sn := "orders", "billing"
+	lib.CreateTopic(tn, TopicConfig{DedupWindow: time.Minute})
+	lib.SubscribeWithOptions(tn, sn, Options{MaxMessages: 1, Overflow: RejectPublish})
+	lib.Publish(tn, []byte("1"))
+	if _, err := lib.PublishMsg(tn, Message{ID: "order-1"}); err != ErrQueueFull {
+		t.Fatal(err)
+	}
+	lib.Poll(tn, sn)
+	// rejected message isn't remembered, so retry is delivered
+	if _, err := lib.PublishMsg(tn, Message{ID: "order-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if depth, _ := lib.Depth(tn, sn); depth != 1 {
+		t.Fatal(depth)
+	}
+}
+
+func TestPubSub_PublishTx_Dedup(t *testing.T) {
+	lib := New()
+	lib.CreateTopic("orders", TopicConfig{DedupWindow: time.Minute})
+	lib.Subscribe("orders", "billing")
+	lib.Subscribe("invoices", "billing")
+	lib.PublishMsg("orders", Message{ID: "order-1"})
+	err := lib.PublishTx(func(tx Tx) error {
+		for _, id := range []string{"order-1", "order-2", "order-2"} {
+			if _, err := tx.PublishMsg("orders", Message{ID: id}); err != nil {
+				return err
+			}
+		}
+		// topic without DedupWindow
+		tx.PublishMsg("invoices", Message{ID: "order-2"})
+		_, err := tx.PublishMsg("invoices", Message{ID: "order-2"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth, _ := lib.Depth("orders", "billing"); depth != 2 {
+		t.Fatal(depth)
+	}
+	if depth, _ := lib.Depth("invoices", "billing"); depth != 2 {
+		t.Fatal(depth)
+	}
+}
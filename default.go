@@ -0,0 +1,61 @@
+package pubsub
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Broker of package-level functions, nil until the first use or SetDefault
+type defaultHolder struct {
+	ps PubSuber
+}
+
+var defaultBroker atomic.Pointer[defaultHolder]
+
+// Broker used by package-level functions (Publish, Subscribe, Poll, ...), like http.DefaultServeMux it saves small
+// applications and tests passing a broker around. It's created by New on first use unless SetDefault set another one
+func Default() PubSuber {
+	if h := defaultBroker.Load(); h != nil {
+		return h.ps
+	}
+	h := &defaultHolder{ps: New()}
+	if defaultBroker.CompareAndSwap(nil, h) {
+		return h.ps
+	}
+	return defaultBroker.Load().ps
+}
+
+// Replacing broker of package-level functions with (ps), the previous one isn't closed
+// nil makes the next call create a new broker with New
+func SetDefault(ps PubSuber) {
+	if ps == nil {
+		defaultBroker.Store(nil)
+		return
+	}
+	defaultBroker.Store(&defaultHolder{ps: ps})
+}
+
+// Publishing message (b) by topic name (tn) to the default broker, see PubSuber.TryPublish
+func Publish(tn string, b []byte) error {
+	return Default().TryPublish(tn, b)
+}
+
+// Subscribing to topic name (tn) with subscription name (sn) in the default broker
+func Subscribe(tn, sn string) {
+	Default().Subscribe(tn, sn)
+}
+
+// Unsubscribing subscription (sn) of topic name (tn) in the default broker
+func Unsubscribe(tn, sn string) {
+	Default().Unsubscribe(tn, sn)
+}
+
+// Fetching message of subscription (sn) of topic name (tn) of the default broker, nil if there are no messages
+func Poll(tn, sn string) ([]byte, error) {
+	return Default().Poll(tn, sn)
+}
+
+// Waiting for a message of subscription (sn) of topic name (tn) of the default broker until it arrives or ctx is done
+func PollWait(ctx context.Context, tn, sn string) ([]byte, error) {
+	return Default().PollWait(ctx, tn, sn)
+}
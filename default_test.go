@@ -0,0 +1,38 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDefault(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+	SetDefault(nil)
+	if Default() != Default() {
+		t.Fatal("default broker is created twice")
+	}
+	Subscribe("orders", "billing")
+	if err := Publish("orders", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := Poll("orders", "billing"); string(msg) != "1" || err != nil {
+		t.Fatal(msg, err)
+	}
+	lib := New()
+	SetDefault(lib)
+	if Default() != lib {
+		t.Fatal()
+	}
+	Subscribe("orders", "billing")
+	_ = Publish("orders", []byte("2"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if msg, err := PollWait(ctx, "orders", "billing"); string(msg) != "2" || err != nil {
+		t.Fatal(msg, err)
+	}
+	Unsubscribe("orders", "billing")
+	if subs, _ := lib.Subscriptions("orders"); len(subs) != 0 {
+		t.Fatal(subs)
+	}
+}
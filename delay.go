@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Message waiting for its publishing time
+// seq keeps publishing order of messages scheduled at the same time
+type scheduled struct {
+	at  time.Time
+	seq uint64
+	tn  string
+	m   *message
+}
+
+// Min-heap of scheduled messages by publishing time, implements heap.Interface
+type schedule []*scheduled
+
+func (s schedule) Len() int { return len(s) }
+
+func (s schedule) Less(i, j int) bool {
+	if s[i].at.Equal(s[j].at) {
+		return s[i].seq < s[j].seq
+	}
+	return s[i].at.Before(s[j].at)
+}
+
+func (s schedule) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *schedule) Push(x interface{}) { *s = append(*s, x.(*scheduled)) }
+
+func (s *schedule) Pop() interface{} {
+	old := *s
+	x := old[len(old)-1]
+	old[len(old)-1] = nil
+	*s = old[:len(old)-1]
+	return x
+}
+
+// Publish message (b) by topic name (tn) after delay
+// Message is delivered to subscriptions existing at that moment, overflow policies are applied then too
+func (p *pubSub) PublishAfter(tn string, b []byte, delay time.Duration) {
+	p.PublishAt(tn, b, p.now().Add(delay))
+}
+
+// Publish message (b) by topic name (tn) at time (at), past time means as soon as possible
+// Message is delivered to subscriptions existing at that moment, overflow policies are applied then too
+func (p *pubSub) PublishAt(tn string, b []byte, at time.Time) {
+	p.schedMux.Lock()
+	defer p.schedMux.Unlock()
+	if p.closing.Load() {
+		return
+	}
+	p.schedSeq++
+	heap.Push(&p.sched, &scheduled{at: at, seq: p.schedSeq, tn: tn, m: p.newMessage(tn, Message{Body: b})})
+	if p.sched[0].seq == p.schedSeq {
+		p.resetScheduleTimer()
+	}
+}
+
+// Starting timer for the earliest scheduled message, p.schedMux must be held by caller
+// Single timer is used for all scheduled messages, so memory is used only by messages themselves
+func (p *pubSub) resetScheduleTimer() {
+	if p.schedTimer != nil {
+		p.schedTimer.Stop()
+	}
+	if len(p.sched) > 0 {
+		p.schedTimer = p.clock.AfterFunc(p.sched[0].at.Sub(p.now()), p.publishScheduled)
+	}
+}
+
+// Publishing all messages which time has come, in order of their publishing time
+func (p *pubSub) publishScheduled() {
+	now := p.now()
+	p.schedMux.Lock()
+	var due []*scheduled
+	for len(p.sched) > 0 && !p.sched[0].at.After(now) {
+		due = append(due, heap.Pop(&p.sched).(*scheduled))
+	}
+	p.resetScheduleTimer()
+	p.schedMux.Unlock()
+	for _, s := range due {
+		s.m.PublishedAt = now
+		_, _ = p.publish(s.tn, s.m)
+	}
+}
@@ -0,0 +1,40 @@
+package pubsub
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+)
+
+func TestPubSub_PublishAfter(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	lib.PublishAfter(tn, []byte("later"), 40*time.Millisecond)
+	lib.PublishAfter(tn, []byte("sooner"), 20*time.Millisecond)
+	lib.PublishAt(tn, []byte("past"), time.Now().Add(-time.Hour))
+	time.Sleep(10 * time.Millisecond)
+	if msgs, _ := lib.PollN(tn, sn, 10); len(msgs) != 1 || string(msgs[0]) != "past" {
+		t.Fatal(msgs)
+	}
+	time.Sleep(60 * time.Millisecond)
+	msgs, _ := lib.PollN(tn, sn, 10)
+	if len(msgs) != 2 || string(msgs[0]) != "sooner" || string(msgs[1]) != "later" {
+		t.Fatal(msgs)
+	}
+}
+
+func TestSchedule_Order(t *testing.T) {
+	p := New().(*pubSub)
+	at := time.Now().Add(time.Hour)
+	p.PublishAt("topic", []byte("1"), at)
+	p.PublishAt("topic", []byte("2"), at)
+	p.PublishAt("topic", []byte("0"), at.Add(-time.Minute))
+	p.schedTimer.Stop()
+	for _, expected := range []string{"0", "1", "2"} {
+		if s := heap.Pop(&p.sched).(*scheduled); string(s.m.Body) != expected {
+			t.Fatal(string(s.m.Body))
+		}
+	}
+}
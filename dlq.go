@@ -0,0 +1,80 @@
+package pubsub
+
+// Suffix of dead-letter topic names
+const deadLetterSuffix = ".dlq"
+
+// DeadLetterTopic returns name of topic where messages of topic (tn) exceeding Options.MaxDeliveries are moved,
+// every subscription has its own dead-letter subscription with the same name
+func DeadLetterTopic(tn string) string {
+	return tn + deadLetterSuffix
+}
+
+// Moving items (its) to dead-letter subscription of dead-letter topic (dlq), it's created again if was removed
+// Messages are dropped if dead-letter topic was deleted with DeleteTopic
+// Subscription must not be locked by caller: publisher of dead-letter topic may wait for it while holding dlq,
+// if the topic is a wildcard matching dead-letter topic
+func (s *subscription) deadLetter(dlq *subscriptions, its []item) {
+	dlq.mux.Lock()
+	defer dlq.mux.Unlock()
+	if dlq.deleted.Load() {
+		return
+	}
+	dead, _ := dlq.ensure(s.sn)
+	for _, it := range its {
+		dead.add(it.message)
+	}
+	dead.cond.Broadcast()
+}
+
+// Releasing reference to dead-letter topic, the topic isn't removed here even if it becomes empty
+// Lock of subscription must be held by caller
+func (s *subscription) releaseDLQ() {
+	if s.dlq != nil {
+		s.dlq.refs--
+		s.dlq = nil
+	}
+}
+
+// Fetching dead letter for topic name (tn) and subscriber name (sn), message keeps its original topic name
+// error raises if there is no dead-letter subscription (MaxDeliveries wasn't set for the subscription)
+// nil, nil should be returned if there are no dead letters
+func (p *pubSub) PollDLQ(tn, sn string) (*Message, error) {
+	return p.PollMsg(DeadLetterTopic(tn), sn)
+}
+
+// Moving all dead letters of topic name (tn) and subscriber name (sn) back to the end of the subscription queue
+// with reset delivery attempts, returns number of moved messages
+// error raises if subscription or its dead-letter subscription doesn't exist
+func (p *pubSub) Redrive(tn, sn string) (int, error) {
+	if p.closed.Load() {
+		return 0, ErrClosed
+	}
+	subs, ok := p.topics.get(tn)
+	dlq, dlqOk := p.topics.get(DeadLetterTopic(tn))
+	if !ok || !dlqOk {
+		return 0, ErrTopicNotFound
+	}
+	subs.mux.Lock()
+	defer subs.mux.Unlock()
+	dlq.mux.Lock()
+	defer dlq.mux.Unlock()
+	sub, ok := subs.hm[sn]
+	dead, dlqOk := dlq.hm[sn]
+	if !ok || !dlqOk {
+		return 0, ErrSubscriptionNotFound
+	}
+	n := 0
+	now := p.now()
+	for {
+		it, ok := dead.next(now)
+		if !ok {
+			break
+		}
+		sub.add(it.message)
+		n++
+	}
+	if n > 0 {
+		sub.cond.Broadcast()
+	}
+	return n, nil
+}
@@ -0,0 +1,62 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPubSub_DeadLetters(t *testing.T) {
+	lib := New(WithClock(&stepClock{now: time.Unix(0, 0)}))
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxDeliveries: 2})
+	lib.Publish(tn, []byte("poison"))
+	for i := 0; i < 2; i++ {
+		if msg, _, _ := lib.PollAck(tn, sn, 10*time.Millisecond); string(msg) != "poison" {
+			t.Fatal(i, msg)
+		}
+		_ = lib.Advance(30 * time.Millisecond)
+	}
+	if n, _ := lib.Depth(tn, sn); n != 0 {
+		t.FailNow()
+	}
+	msg, err := lib.PollDLQ(tn, sn)
+	if err != nil || msg == nil || string(msg.Body) != "poison" || msg.Topic != tn {
+		t.Fatal(msg, err)
+	}
+
+	lib.Publish(tn, []byte("poison"))
+	_, _, _ = lib.PollAck(tn, sn, time.Millisecond)
+	_ = lib.Advance(10 * time.Millisecond)
+	_, _, _ = lib.PollAck(tn, sn, time.Millisecond)
+	_ = lib.Advance(10 * time.Millisecond)
+	if n, err := lib.Redrive(tn, sn); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	// delivery attempts are reset by Redrive
+	_, token, _ := lib.PollAck(tn, sn, time.Millisecond)
+	_ = lib.Advance(10 * time.Millisecond)
+	if err := lib.Ack(tn, sn, token); err != ErrUnknownAckToken {
+		t.FailNow()
+	}
+	if msg, _ := lib.Poll(tn, sn); string(msg) != "poison" {
+		t.FailNow()
+	}
+	if msg, _ := lib.PollDLQ(tn, sn); msg != nil {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_DeadLetters_NoDLQ(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	if _, err := lib.PollDLQ(tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	if _, err := lib.Redrive(tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+}
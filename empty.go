@@ -0,0 +1,25 @@
+package pubsub
+
+import "errors"
+
+// Error happens if subscription exists but has no messages to poll, only if broker is created with WithEmptyError
+var ErrEmpty = errors.New("subscription is empty")
+
+// Reporting empty polls
+// Non-waiting polling methods (Poll, PollMsg, PollN, PollBytes, PollAck, PollAckMsg, PollDLQ) return ErrEmpty
+// instead of nil, nil if the subscription exists but there are no messages to return, so empty result can't be
+// mistaken for a message. PollN and PollBytes return it only if no message was fetched. Poll middlewares (see Use)
+// still see nil, nil
+func WithEmptyError(enabled bool) Option {
+	return func(p *pubSub) {
+		p.emptyError = enabled
+	}
+}
+
+// Error of poll which returned no message (err) or ErrEmpty if it's nil and broker reports empty polls
+func (p *pubSub) empty(err error) error {
+	if err == nil && p.emptyError {
+		return ErrEmpty
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithEmptyError(t *testing.T) {
+	lib := New(WithEmptyError(true))
+	tn, sn := "orders", "billing"
+	if _, err := lib.Poll(tn, sn); err != ErrTopicNotFound {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	if b, err := lib.Poll(tn, sn); b != nil || err != ErrEmpty {
+		t.FailNow()
+	}
+	if msg, err := lib.PollMsg(tn, sn); msg != nil || err != ErrEmpty {
+		t.FailNow()
+	}
+	if msgs, err := lib.PollN(tn, sn, 10); msgs != nil || err != ErrEmpty {
+		t.FailNow()
+	}
+	if msgs, err := lib.PollBytes(tn, sn, 10); msgs != nil || err != ErrEmpty {
+		t.FailNow()
+	}
+	if b, token, err := lib.PollAck(tn, sn, time.Minute); b != nil || token != 0 || err != ErrEmpty {
+		t.FailNow()
+	}
+	if msg, _, err := lib.PollAckMsg(tn, sn, time.Minute); msg != nil || err != ErrEmpty {
+		t.FailNow()
+	}
+	// non-positive limits aren't empty polls
+	if msgs, err := lib.PollN(tn, sn, 0); msgs != nil || err != nil {
+		t.FailNow()
+	}
+	lib.Publish(tn, []byte("message"))
+	if msgs, err := lib.PollN(tn, sn, 10); len(msgs) != 1 || err != nil {
+		t.FailNow()
+	}
+	lib.Publish(tn, []byte("message"))
+	if b, err := lib.Poll(tn, sn); string(b) != "message" || err != nil {
+		t.FailNow()
+	}
+}
+
+func TestWithEmptyError_Middleware(t *testing.T) {
+	lib := New(WithEmptyError(true))
+	tn, sn := "orders", "billing"
+	lib.Subscribe(tn, sn)
+	var seen error
+	lib.Use(Middleware{Poll: func(next PollFunc) PollFunc {
+		return func(ctx context.Context, tn, sn string) (*Message, error) {
+			msg, err := next(ctx, tn, sn)
+			seen = err
+			return msg, err
+		}
+	}})
+	if _, err := lib.Poll(tn, sn); err != ErrEmpty || seen != nil {
+		t.FailNow()
+	}
+	if msgs, err := lib.PollN(tn, sn, 10); msgs != nil || err != ErrEmpty {
+		t.FailNow()
+	}
+}
+
+func TestWithEmptyError_Callers(t *testing.T) {
+	lib := New(WithEmptyError(true))
+	tn, sn := "orders", "billing"
+	lib.Subscribe(tn, sn)
+	s := NewSessions(time.Minute).Resume(tn, sn, "s1")
+	if msg, _, err := s.Next(lib); msg != nil || err != nil {
+		t.FailNow()
+	}
+	m := Multi(lib, New())
+	m.Subscribe(tn, sn)
+	if msg, err := m.PollMsg(tn, sn); msg != nil || !errors.Is(err, ErrEmpty) {
+		t.FailNow()
+	}
+	lib.Publish(tn, []byte("message"))
+	if msgs, err := m.PollN(tn, sn, 10); len(msgs) != 1 || err != nil {
+		t.FailNow()
+	}
+}
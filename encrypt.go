@@ -0,0 +1,152 @@
+package pubsub
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Error happens if snapshot contains encrypted messages, but broker is created without WithEncryption
+var ErrNoKeyProvider = errors.New("key provider isn't set")
+
+// Error happens if body of polled message can't be decrypted, the message stays pending (see WithEncryption)
+var ErrDecrypt = errors.New("message can't be decrypted")
+
+// KeyProvider supplies AES keys (16, 24 or 32 bytes) for encryption of message bodies, must be safe for concurrent use
+// Keys are identified by IDs, so they may be rotated: new messages are encrypted with the current key, messages
+// encrypted before are decrypted with the key they were encrypted with. Key of an ID must never change
+type KeyProvider interface {
+	// Key for encrypting new messages and its ID
+	CurrentKey() (id string, key []byte, err error)
+	// Key with ID for decrypting messages encrypted with it before
+	Key(id string) ([]byte, error)
+}
+
+// KeyProvider with a single key (key) of ID "static"
+func StaticKey(key []byte) KeyProvider {
+	return staticKey(key)
+}
+
+type staticKey []byte
+
+func (k staticKey) CurrentKey() (string, []byte, error) {
+	return "static", k, nil
+}
+
+func (k staticKey) Key(id string) ([]byte, error) {
+	if id != "static" {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return k, nil
+}
+
+// Encrypting bodies of all published messages with AES-GCM using keys of (kp), so payloads pending in memory and
+// written by Snapshot aren't plaintext. Bodies are decrypted when they are polled, message ID is authenticated
+// together with the body. Publishing fails if kp can't give the current key, polling fails with ErrDecrypt if the
+// body can't be decrypted, the message stays pending then (PollAck counts it as a failed delivery)
+// Compressed bodies (see TopicConfig.Compression) are compressed before encryption
+func WithEncryption(kp KeyProvider) Option {
+	return func(p *pubSub) {
+		p.enc = &encryption{keys: kp}
+	}
+}
+
+// AES-GCM encryption of message bodies with keys of KeyProvider
+// aeads - ciphers by key IDs, created once for every key
+type encryption struct {
+	keys  KeyProvider
+	mux   sync.RWMutex
+	aeads map[string]cipher.AEAD
+}
+
+// Cipher of key with (id), (key) is used if it's given, otherwise it's requested from KeyProvider
+func (e *encryption) aead(id string, key []byte) (cipher.AEAD, error) {
+	e.mux.RLock()
+	aead, ok := e.aeads[id]
+	e.mux.RUnlock()
+	if ok {
+		return aead, nil
+	}
+	if key == nil {
+		var err error
+		if key, err = e.keys.Key(id); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.aeads == nil {
+		e.aeads = map[string]cipher.AEAD{}
+	}
+	e.aeads[id] = aead
+	return aead, nil
+}
+
+// Encrypting body of message (m) with the current key, body becomes nonce followed by ciphertext
+// Empty bodies stay empty, they mean removal for retained messages and compacted topics
+// Message must not be visible to other goroutines yet
+func (p *pubSub) encrypt(m *message) error {
+	if p.enc == nil || m.enc != nil || len(m.Body) == 0 {
+		return nil
+	}
+	id, key, err := p.enc.keys.CurrentKey()
+	if err != nil {
+		return fmt.Errorf("encrypting message: %w", err)
+	}
+	aead, err := p.enc.aead(id, key)
+	if err != nil {
+		return fmt.Errorf("encrypting message: %w", err)
+	}
+	b := make([]byte, aead.NonceSize(), aead.NonceSize()+len(m.Body)+aead.Overhead())
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("encrypting message: %w", err)
+	}
+	// ID is authenticated, so it's formatted before message is shared
+	m.formatID()
+	m.Body = aead.Seal(b, b, m.Body, []byte(m.ID))
+	m.enc, m.keyID = p.enc, id
+	return nil
+}
+
+// Decrypting body (b) of message (m), error wraps ErrDecrypt
+func (m *message) decrypt(b []byte) ([]byte, error) {
+	aead, err := m.enc.aead(m.keyID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: message is too short", ErrDecrypt)
+	}
+	d, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(m.ID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	return d, nil
+}
+
+// Validating message (m) published to topic name (tn) and preparing its body for storing: it's compressed and
+// encrypted. Message must not be visible to other goroutines yet, it's sealed once
+func (p *pubSub) seal(tn string, m *message) error {
+	if m.sealed {
+		return nil
+	}
+	if err := p.validate(tn, m); err != nil {
+		return err
+	}
+	p.compress(tn, m)
+	if err := p.encrypt(m); err != nil {
+		return err
+	}
+	m.sealed = true
+	return nil
+}
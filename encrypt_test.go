@@ -0,0 +1,110 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// KeyProvider with rotated keys, the last one is current
+type rotatingKeys struct {
+	ids  []string
+	keys map[string][]byte
+}
+
+func (k *rotatingKeys) CurrentKey() (string, []byte, error) {
+	if len(k.ids) == 0 {
+		return "", nil, errors.New("no keys")
+	}
+	id := k.ids[len(k.ids)-1]
+	return id, k.keys[id], nil
+}
+
+func (k *rotatingKeys) Key(id string) ([]byte, error) {
+	return k.keys[id], nil
+}
+
+func TestWithEncryption(t *testing.T) {
+	secret := []byte("card number 4111")
+	lib := New(WithEncryption(StaticKey(bytes.Repeat([]byte{1}, 32))))
+	lib.Subscribe("payments", "billing")
+	lib.Publish("payments", secret)
+	lib.Publish("payments", nil)
+	var buf bytes.Buffer
+	if err := lib.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), secret) {
+		t.Fatal("snapshot contains plaintext")
+	}
+	snapshot := buf.Bytes()
+	if b, _ := lib.Poll("payments", "billing"); !bytes.Equal(b, secret) {
+		t.Fatal(string(b))
+	}
+	if b, _ := lib.Poll("payments", "billing"); len(b) != 0 {
+		t.Fatal(string(b))
+	}
+	if err := New().Restore(bytes.NewReader(snapshot)); err != ErrNoKeyProvider {
+		t.Fatal(err)
+	}
+	restored := New(WithEncryption(StaticKey(bytes.Repeat([]byte{1}, 32))))
+	if err := restored.Restore(bytes.NewReader(snapshot)); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ := restored.PollMsg("payments", "billing"); msg == nil || !bytes.Equal(msg.Body, secret) {
+		t.Fatal(msg)
+	}
+	// wrong key, message stays pending
+	wrong := New(WithEncryption(StaticKey(bytes.Repeat([]byte{2}, 32))))
+	wrong.Restore(bytes.NewReader(snapshot))
+	if b, err := wrong.Poll("payments", "billing"); !errors.Is(err, ErrDecrypt) || b != nil {
+		t.Fatal(string(b), err)
+	}
+	if msg, err := wrong.PollMsgWait(context.Background(), "payments", "billing"); !errors.Is(err, ErrDecrypt) || msg != nil {
+		t.Fatal(msg, err)
+	}
+	if b, _, err := wrong.PollAck("payments", "billing", time.Minute); !errors.Is(err, ErrDecrypt) || b != nil {
+		t.Fatal(string(b), err)
+	}
+	if n, _ := wrong.InFlight("payments", "billing"); n != 0 {
+		t.Fatal(n)
+	}
+	if n, _ := wrong.Depth("payments", "billing"); n != 2 {
+		t.Fatal(n)
+	}
+}
+
+func TestWithEncryption_Rotation(t *testing.T) {
+	keys := &rotatingKeys{keys: map[string][]byte{"1": bytes.Repeat([]byte{1}, 16), "2": bytes.Repeat([]byte{2}, 16)}}
+	lib := New(WithEncryption(keys))
+	lib.Subscribe("payments", "billing")
+	if err := lib.TryPublish("payments", []byte("a")); err == nil || !strings.Contains(err.Error(), "no keys") {
+		t.Fatal(err)
+	}
+	keys.ids = []string{"1"}
+	lib.Publish("payments", []byte("a"))
+	keys.ids = []string{"1", "2"}
+	lib.Publish("payments", []byte("b"))
+	for _, want := range []string{"a", "b"} {
+		if b, _, _ := lib.PollAck("payments", "billing", time.Minute); string(b) != want {
+			t.Fatal(string(b), want)
+		}
+	}
+}
+
+func TestWithEncryption_Compression(t *testing.T) {
+	lib := New(WithEncryption(StaticKey(bytes.Repeat([]byte{1}, 16))))
+	lib.CreateTopic("events", TopicConfig{Compression: "gzip", CompressThreshold: 1})
+	lib.Subscribe("events", "archive")
+	body := []byte(strings.Repeat("abc", 1000))
+	lib.Publish("events", body)
+	if b := lib.Stats().Topics[0].Subscriptions[0].Bytes; b >= int64(len(body)) {
+		t.Fatal(b)
+	}
+	if b, _ := lib.Poll("events", "archive"); !bytes.Equal(b, body) {
+		t.Fatal(len(b))
+	}
+}
@@ -0,0 +1,211 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Capacity of channels returned by Events, events are dropped for listeners which channels are full
+const eventsBuffer = 256
+
+// Kind of broker event
+type EventType int
+
+const (
+	// EventTopicCreated - topic was created by subscription, publish with history or CreateTopic
+	EventTopicCreated EventType = iota
+	// EventTopicDeleted - topic was removed because it became empty or by DeleteTopic
+	EventTopicDeleted
+	// EventSubscriptionCreated - subscription (including dead-letter one) was created
+	EventSubscriptionCreated
+	// EventSubscriptionDeleted - subscription was removed by Unsubscribe, DeleteTopic, idle expiration or Close
+	EventSubscriptionDeleted
+	// EventMessageDropped - Count messages were dropped by subscription because of Reason (overflow, expired, ...)
+	EventMessageDropped
+	// EventDLQMove - message was moved to dead-letter topic after Options.MaxDeliveries attempts
+	EventDLQMove
+	// EventSlowSubscriber - subscription became full (Reason "full") or exceeded thresholds of WithWatchdog
+	// (Reason "watchdog")
+	EventSlowSubscriber
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventTopicCreated:
+		return "topic-created"
+	case EventTopicDeleted:
+		return "topic-deleted"
+	case EventSubscriptionCreated:
+		return "subscription-created"
+	case EventSubscriptionDeleted:
+		return "subscription-deleted"
+	case EventMessageDropped:
+		return "message-dropped"
+	case EventDLQMove:
+		return "dlq-move"
+	case EventSlowSubscriber:
+		return "slow-subscriber"
+	}
+	return "unknown"
+}
+
+// Event of broker lifecycle, Subscription is empty for topic events, MessageID is empty if event isn't about
+// a single message
+type Event struct {
+	Type         EventType
+	Topic        string
+	Subscription string
+	MessageID    string
+	Count        int
+	Reason       string
+	Time         time.Time
+}
+
+// Listeners of broker events with functions stopping their context.AfterFunc, active is set while there are
+// listeners, so events aren't built without them
+type eventHub struct {
+	mux       sync.Mutex
+	listeners map[chan Event]func() bool
+	closed    bool
+	active    atomic.Bool
+}
+
+// Adding listener removed when ctx is done, closed channel is returned if broker is closed
+func (h *eventHub) listen(ctx context.Context) <-chan Event {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	ch := make(chan Event, eventsBuffer)
+	if h.closed || ctx.Err() != nil {
+		close(ch)
+		return ch
+	}
+	if h.listeners == nil {
+		h.listeners = map[chan Event]func() bool{}
+	}
+	h.listeners[ch] = context.AfterFunc(ctx, func() { h.remove(ch) })
+	h.active.Store(true)
+	return ch
+}
+
+// Removing listener (ch) and closing its channel
+func (h *eventHub) remove(ch chan Event) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if _, ok := h.listeners[ch]; !ok {
+		return
+	}
+	delete(h.listeners, ch)
+	close(ch)
+	h.active.Store(len(h.listeners) > 0)
+}
+
+// Sending event (e) to all listeners without blocking
+func (h *eventHub) emit(e Event) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for ch := range h.listeners {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Closing channels of all listeners
+func (h *eventHub) close() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.closed = true
+	for ch, stop := range h.listeners {
+		stop()
+		close(ch)
+	}
+	h.listeners = nil
+	h.active.Store(false)
+}
+
+// Channel of broker events, every call returns a new channel getting all events since the call
+// Events are sent without blocking the broker: they are dropped if the channel is full (eventsBuffer events), so
+// it should be read all the time. Channel is closed when ctx is done or by Close
+func (p *pubSub) Events(ctx context.Context) <-chan Event {
+	return p.events.listen(ctx)
+}
+
+// Emitting event of type (t) about topic (subs)
+func (p *pubSub) emitTopic(t EventType, subs *subscriptions) {
+	if p.events.active.Load() {
+		p.events.emit(Event{Type: t, Topic: subs.tn, Time: p.now()})
+	}
+}
+
+// Emitting event of type (t) about subscription, message (m), number of messages (n) and (reason) are optional
+// Lock of subscription must be held by caller
+func (s *subscription) emit(t EventType, m *message, n int, reason string) {
+	if h := s.topic.events; h != nil && h.active.Load() {
+		var id string
+		if m != nil {
+			id = m.id()
+		}
+		h.emit(Event{Type: t, Topic: s.topic.tn, Subscription: s.sn, MessageID: id, Count: n, Reason: reason,
+			Time: s.topic.clock.Now()})
+	}
+}
+
+// Events of topics of the namespace with topic names of the namespace
+func (n *namespace) Events(ctx context.Context) <-chan Event {
+	return relayEvents(n.p.Events(ctx), func(e *Event) bool {
+		tn, ok := n.local(e.Topic)
+		e.Topic = tn
+		return ok
+	})
+}
+
+// Events of topics and subscriptions principal may inspect
+func (a *authorized) Events(ctx context.Context) <-chan Event {
+	return relayEvents(a.next.Events(ctx), func(e *Event) bool {
+		return a.allow(OpInspect, e.Topic, e.Subscription) == nil
+	})
+}
+
+// Forwarding events of channel (in) accepted by (fn), which may change them, to returned channel
+// Returned channel is closed when (in) is closed, e.g. when context of Events is done
+func relayEvents(in <-chan Event, fn func(e *Event) bool) <-chan Event {
+	out := make(chan Event, eventsBuffer)
+	go func() {
+		defer close(out)
+		for e := range in {
+			if fn(&e) {
+				select {
+				case out <- e:
+				default:
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Forwarding events of all channels (chans) to returned channel, it's closed when all of them are closed
+func mergeEvents(chans []<-chan Event) <-chan Event {
+	out := make(chan Event, eventsBuffer)
+	var wg sync.WaitGroup
+	for _, in := range chans {
+		wg.Add(1)
+		go func(in <-chan Event) {
+			defer wg.Done()
+			for e := range in {
+				select {
+				case out <- e:
+				default:
+				}
+			}
+		}(in)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
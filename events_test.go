@@ -0,0 +1,116 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Events received from channel (ch) until it has no events for a while
+func receiveEvents(ch <-chan Event) []Event {
+	var events []Event
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, e)
+		case <-time.After(20 * time.Millisecond):
+			return events
+		}
+	}
+}
+
+func TestPubSub_Events(t *testing.T) {
+	lib := New()
+	events := lib.Events(context.Background())
+	lib.SubscribeWithOptions("orders", "billing", Options{MaxMessages: 1})
+	lib.Publish("orders", []byte("1"))
+	lib.Publish("orders", []byte("2"))
+	lib.Unsubscribe("orders", "billing")
+	want := []struct {
+		t      EventType
+		sn     string
+		reason string
+	}{
+		{EventTopicCreated, "", ""},
+		{EventSubscriptionCreated, "billing", ""},
+		{EventSlowSubscriber, "billing", "full"},
+		{EventMessageDropped, "billing", "overflow"},
+		{EventSubscriptionDeleted, "billing", ""},
+		{EventTopicDeleted, "", ""},
+	}
+	got := receiveEvents(events)
+	if len(got) != len(want) {
+		t.Fatal(got)
+	}
+	for i, e := range got {
+		if e.Type != want[i].t || e.Topic != "orders" || e.Subscription != want[i].sn || e.Reason != want[i].reason {
+			t.Fatal(i, e)
+		}
+	}
+	if got[3].MessageID == "" || got[3].Count != 1 {
+		t.Fatal(got[3])
+	}
+	_ = lib.Close(context.Background())
+	if _, ok := <-events; ok {
+		t.Fatal("channel isn't closed")
+	}
+	if _, ok := <-lib.Events(context.Background()); ok {
+		t.Fatal("channel of closed broker isn't closed")
+	}
+}
+
+func TestPubSub_Events_DLQMove(t *testing.T) {
+	lib := New()
+	lib.SubscribeWithOptions("orders", "billing", Options{MaxDeliveries: 1})
+	lib.Publish("orders", []byte("1"))
+	events := lib.Events(context.Background())
+	_, token, _ := lib.PollAck("orders", "billing", time.Minute)
+	_ = lib.Nack("orders", "billing", token, false)
+	for _, e := range receiveEvents(events) {
+		if e.Type == EventDLQMove && e.Subscription == "billing" {
+			return
+		}
+	}
+	t.Fatal("no dlq-move event")
+}
+
+func TestNamespace_Events(t *testing.T) {
+	lib := New()
+	events := lib.Namespace("tenant").Events(context.Background())
+	lib.Subscribe("orders", "billing")
+	lib.Namespace("tenant").Subscribe("orders", "billing")
+	got := receiveEvents(events)
+	if len(got) != 2 || got[0].Type != EventTopicCreated || got[0].Topic != "orders" || got[1].Subscription != "billing" {
+		t.Fatal(got)
+	}
+}
+
+func TestEventType_String(t *testing.T) {
+	if EventDLQMove.String() != "dlq-move" || EventType(100).String() != "unknown" {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Events_Cancel(t *testing.T) {
+	lib := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	events := lib.Namespace("tenant").Events(ctx)
+	cancel()
+	// channel of the namespace is closed after the listener of the broker is removed
+	if _, ok := <-events; ok {
+		t.Fatal("channel isn't closed")
+	}
+	p := lib.(*pubSub)
+	p.events.mux.Lock()
+	n := len(p.events.listeners)
+	p.events.mux.Unlock()
+	if n != 0 || p.events.active.Load() {
+		t.Fatal(n)
+	}
+	if _, ok := <-lib.Events(ctx); ok {
+		t.Fatal("channel of done context isn't closed")
+	}
+}
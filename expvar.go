@@ -0,0 +1,40 @@
+package pubsub
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// Variables published by WithExpvar by their names, expvar can't remove or replace variables, so a new broker
+// using the same name takes the variable over
+var (
+	expvarsMux sync.Mutex
+	expvars    = map[string]*atomic.Pointer[pubSub]{}
+)
+
+// Publishing counters of broker (Stats) via expvar as variable (name), so services exposing /debug/vars get depths
+// of queues and counters of published, delivered and dropped messages, rates are derived from counters by monitoring
+// Counters are collected on every read of the variable. Broker created later with the same name replaces this one
+// The variable keeps the last broker published under the name reachable, so it isn't garbage collected after Close
+// Name taken by a variable published without WithExpvar (e.g. "memstats") is skipped with a warning, since
+// expvar.Publish panics on duplicates
+func WithExpvar(name string) Option {
+	return func(p *pubSub) {
+		expvarsMux.Lock()
+		defer expvarsMux.Unlock()
+		current, ok := expvars[name]
+		if !ok {
+			if expvar.Get(name) != nil {
+				p.log.Warn("expvar name is taken", "name", name)
+				return
+			}
+			current = &atomic.Pointer[pubSub]{}
+			expvars[name] = current
+			expvar.Publish(name, expvar.Func(func() any {
+				return current.Load().Stats()
+			}))
+		}
+		current.Store(p)
+	}
+}
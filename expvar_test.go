@@ -0,0 +1,30 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestWithExpvar(t *testing.T) {
+	New(WithExpvar("pubsub_test"))
+	lib := New(WithExpvar("pubsub_test"))
+	lib.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("order"))
+	var stats BrokerStats
+	if err := json.Unmarshal([]byte(expvar.Get("pubsub_test").String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Topics) != 1 || stats.Topics[0].Published != 1 || stats.Topics[0].Subscriptions[0].Pending != 1 {
+		t.Fatal(stats)
+	}
+}
+
+func TestWithExpvar_Taken(t *testing.T) {
+	// published by expvar package itself
+	New(WithExpvar("memstats"))
+	var stats struct{ Alloc uint64 }
+	if err := json.Unmarshal([]byte(expvar.Get("memstats").String()), &stats); err != nil || stats.Alloc == 0 {
+		t.Fatal(stats, err)
+	}
+}
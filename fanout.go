@@ -0,0 +1,19 @@
+package pubsub
+
+// Reporting messages which no subscription received
+// Publishing methods returning error (TryPublish, PublishResult, PublishMsg, PublishWithPriority, PublishWithTTL)
+// return ErrNoSubscriptions if the message wasn't added to any subscription: topic has no subscriptions (and no
+// matching wildcard ones) or all of them filtered or dropped the message. The message is still recorded in topic
+// history. PublishRetained doesn't report it, because retained message waits for future subscriptions
+func WithNoSubscribersError(enabled bool) Option {
+	return func(p *pubSub) {
+		p.noSubsError = enabled
+	}
+}
+
+// Publish message (b) by topic name (tn), returns number of subscriptions which received it including wildcard ones
+// Errors are the same as of TryPublish, ErrNoSubscriptions raises if nobody received the message and broker is
+// created with WithNoSubscribersError
+func (p *pubSub) PublishResult(tn string, b []byte) (int, error) {
+	return p.publishReleased(tn, p.newMessage(tn, Message{Body: b}))
+}
@@ -0,0 +1,48 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func TestPubSub_PublishResult(t *testing.T) {
+	lib := New()
+	tn := "orders/1"
+	if n, err := lib.PublishResult(tn, []byte("message")); n != 0 || err != nil {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, "billing")
+	lib.Subscribe("orders/+", "audit")
+	lib.SubscribeWithOptions(tn, "filtered", Options{Filter: HeaderExists("type")})
+	if n, err := lib.PublishResult(tn, []byte("message")); n != 2 || err != nil {
+		t.FailNow()
+	}
+	// middlewares don't hide the result
+	lib.Use(Middleware{Publish: func(next PublishFunc) PublishFunc { return next }})
+	if n, err := lib.PublishResult(tn, []byte("message")); n != 2 || err != nil {
+		t.FailNow()
+	}
+}
+
+func TestWithNoSubscribersError(t *testing.T) {
+	lib := New(WithNoSubscribersError(true))
+	tn := "orders"
+	if err := lib.TryPublish(tn, []byte("message")); err != ErrNoSubscriptions {
+		t.FailNow()
+	}
+	if _, err := lib.PublishMsg(tn, Message{Body: []byte("message")}); err != ErrNoSubscriptions {
+		t.FailNow()
+	}
+	if err := lib.PublishRetained(tn, []byte("message")); err != nil {
+		t.FailNow()
+	}
+	lib.SubscribeWithOptions(tn, "filtered", Options{Filter: HeaderExists("type")})
+	if n, err := lib.PublishResult(tn, []byte("message")); n != 0 || err != ErrNoSubscriptions {
+		t.FailNow()
+	}
+	if _, err := lib.PublishMsg(tn, Message{Headers: map[string]string{"type": "x"}, Body: []byte("message")}); err != nil {
+		t.FailNow()
+	}
+	if n, err := lib.Namespace("tenant").PublishResult(tn, []byte("message")); n != 0 || err != ErrNoSubscriptions {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,450 @@
+/*
+Federation of pubsub brokers: broker instances of several processes discover each other by gossip and forward
+messages of topics having subscriptions at remote instances, making a lightweight mesh without a central broker.
+
+Every node knows a few seed addresses. Each gossip interval it sends the list of members it knows, with topics
+their brokers have subscriptions of, to a few random members and merges the list they answer with, so membership
+and subscriptions spread through the mesh. A member which isn't heard of for Config.DeadTimeout is removed.
+
+	n := federation.New("a", "http://10.0.0.1:7100", pubsub.New(), federation.Config{Seeds: []string{"http://10.0.0.2:7100"}})
+	go http.ListenAndServe(":7100", federation.Handler(n))
+	go n.Run(ctx)
+	n.Broker().Publish("orders", b) // delivered to local subscriptions and forwarded to members subscribed to "orders"
+
+Messages published to the local broker are forwarded to members having subscriptions of the topic (or of a wildcard
+pattern matching it) once, forwarded messages aren't forwarded again. Forwarding is asynchronous and at most once:
+message is dropped if the queue of the member is full or the member doesn't respond. Subscriptions are learned with
+a delay of a few gossip intervals, messages published meanwhile aren't forwarded.
+*/
+package federation
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Header marking messages forwarded by a member, value is ID of the member, it's removed before delivery
+const OriginHeader = "Pubsub-Federation-Origin"
+
+// Defaults of Config
+const (
+	DefaultGossipInterval = time.Second
+	DefaultDeadTimeout    = 10 * time.Second
+	DefaultFanout         = 3
+	DefaultQueueSize      = 1024
+)
+
+// Error happens if Run is called on a node which already runs or left
+var ErrRunning = errors.New("node is already running")
+
+// Settings of Node
+// Seeds - addresses of members to join through, the node is alone until somebody gossips to it if empty
+// GossipInterval - how often the node gossips, DefaultGossipInterval if zero
+// DeadTimeout - member isn't heard of for this time is removed, DefaultDeadTimeout if zero
+// Fanout - number of random members the node gossips to each interval, DefaultFanout if zero
+// QueueSize - number of messages waiting for forwarding to every member, DefaultQueueSize if zero
+// Client - client of gossip and forwarding requests, http.DefaultClient if nil
+type Config struct {
+	Seeds          []string
+	GossipInterval time.Duration
+	DeadTimeout    time.Duration
+	Fanout         int
+	QueueSize      int
+	Client         *http.Client
+}
+
+// Member of federation as nodes gossip about it
+// Heartbeat - counter increased by the member every gossip interval, newer state of member has a larger one
+// Topics - topics and wildcard patterns the broker of member has subscriptions of
+// Left - member left the federation by Leave
+type Member struct {
+	ID        string   `json:"id"`
+	Addr      string   `json:"addr"`
+	Heartbeat uint64   `json:"heartbeat"`
+	Topics    []string `json:"topics,omitempty"`
+	Left      bool     `json:"left,omitempty"`
+}
+
+// Counters of Node
+// Forwarded - messages delivered to members, Received - messages received from members
+// Dropped - messages not forwarded because queue of member was full, Failed - forwarding requests which failed
+type Stats struct {
+	Forwarded uint64
+	Received  uint64
+	Dropped   uint64
+	Failed    uint64
+}
+
+// Known member, seen - when its heartbeat changed last time by local clock
+// dead - member wasn't heard of for DeadTimeout, it's kept for a while so stale gossip doesn't bring it back
+// queue - messages waiting for forwarding, nil for members which left or are dead
+type peer struct {
+	Member
+	seen  time.Time
+	dead  bool
+	queue chan forward
+}
+
+// Message (msg) of topic name (tn) to forward
+type forward struct {
+	tn  string
+	msg pubsub.Message
+}
+
+// Node of federation
+// self - the node as it gossips about itself, peers - other known members by ID including ones which left or are dead
+// ctx - context of Run, workers forwarding messages stop when it's done
+type Node struct {
+	id    string
+	ps    pubsub.PubSuber
+	cfg   Config
+	mux   sync.Mutex
+	self  Member
+	peers map[string]*peer
+	ctx   context.Context
+	stats Stats
+}
+
+// Constructor. Creates node (id) of federation reachable at address (addr) by other members, address is the base
+// URL of Handler. Published messages of broker (ps) are forwarded by middleware added to it right away, but members
+// are discovered only while Run works
+func New(id, addr string, ps pubsub.PubSuber, cfg Config) *Node {
+	if cfg.GossipInterval <= 0 {
+		cfg.GossipInterval = DefaultGossipInterval
+	}
+	if cfg.DeadTimeout <= 0 {
+		cfg.DeadTimeout = DefaultDeadTimeout
+	}
+	if cfg.Fanout <= 0 {
+		cfg.Fanout = DefaultFanout
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	n := &Node{
+		id:    id,
+		ps:    ps,
+		cfg:   cfg,
+		self:  Member{ID: id, Addr: addr},
+		peers: map[string]*peer{},
+	}
+	ps.Use(pubsub.Middleware{Publish: n.middleware})
+	return n
+}
+
+// ID of the node
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Broker of the node
+func (n *Node) Broker() pubsub.PubSuber {
+	return n.ps
+}
+
+// Members of federation known to the node including itself, sorted by ID
+func (n *Node) Members() []Member {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	members := []Member{n.self}
+	for _, p := range n.peers {
+		if !p.Left && !p.dead {
+			members = append(members, p.Member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	return members
+}
+
+// Counters of forwarded and received messages
+func (n *Node) Stats() Stats {
+	return Stats{
+		Forwarded: atomic.LoadUint64(&n.stats.Forwarded),
+		Received:  atomic.LoadUint64(&n.stats.Received),
+		Dropped:   atomic.LoadUint64(&n.stats.Dropped),
+		Failed:    atomic.LoadUint64(&n.stats.Failed),
+	}
+}
+
+// Gossiping and forwarding messages until ctx is done or Leave is called
+func (n *Node) Run(ctx context.Context) error {
+	n.mux.Lock()
+	if n.ctx != nil || n.self.Left {
+		n.mux.Unlock()
+		return ErrRunning
+	}
+	n.ctx = ctx
+	for _, p := range n.peers {
+		n.startQueue(p)
+	}
+	n.mux.Unlock()
+	defer n.stop()
+	ticker := time.NewTicker(n.cfg.GossipInterval)
+	defer ticker.Stop()
+	for {
+		n.gossip(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		n.mux.Lock()
+		left := n.self.Left
+		n.mux.Unlock()
+		if left {
+			return nil
+		}
+	}
+}
+
+// Leaving federation: members are told to stop forwarding to the node, gossip stops
+func (n *Node) Leave(ctx context.Context) error {
+	n.mux.Lock()
+	n.self.Left = true
+	n.self.Heartbeat++
+	targets := n.alive()
+	n.mux.Unlock()
+	var errs []error
+	for _, addr := range targets {
+		if err := n.exchange(ctx, addr); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stopping queues of all members when Run finishes
+func (n *Node) stop() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	for _, p := range n.peers {
+		n.stopQueue(p)
+	}
+}
+
+// One round of gossip: heartbeat and topics of the node are refreshed and sent to random members
+func (n *Node) gossip(ctx context.Context) {
+	topics := n.interest()
+	n.mux.Lock()
+	n.self.Heartbeat++
+	n.self.Topics = topics
+	n.expire(time.Now())
+	targets := n.alive()
+	rand.Shuffle(len(targets), func(i, j int) { targets[i], targets[j] = targets[j], targets[i] })
+	targets = targets[:min(len(targets), n.cfg.Fanout)]
+	if len(targets) == 0 {
+		// nobody is known yet or everybody is gone, start over with seeds
+		targets = n.cfg.Seeds
+	}
+	n.mux.Unlock()
+	var wg sync.WaitGroup
+	for _, addr := range targets {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			rctx, cancel := context.WithTimeout(ctx, n.cfg.GossipInterval)
+			defer cancel()
+			_ = n.exchange(rctx, addr)
+		}(addr)
+	}
+	wg.Wait()
+}
+
+// Topics and patterns the local broker has subscriptions of
+func (n *Node) interest() []string {
+	var topics []string
+	for _, tn := range n.ps.Topics() {
+		if subs, err := n.ps.Subscriptions(tn); err == nil && len(subs) > 0 {
+			topics = append(topics, tn)
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Addresses of members which haven't left and aren't dead, n.mux must be held by caller
+func (n *Node) alive() []string {
+	var addrs []string
+	for _, p := range n.peers {
+		if !p.Left && !p.dead {
+			addrs = append(addrs, p.Addr)
+		}
+	}
+	return addrs
+}
+
+// Marking members not heard of for DeadTimeout till (now) as dead, forgetting them after three DeadTimeouts
+// n.mux must be held by caller
+func (n *Node) expire(now time.Time) {
+	for id, p := range n.peers {
+		switch since := now.Sub(p.seen); {
+		case since > 3*n.cfg.DeadTimeout:
+			n.stopQueue(p)
+			delete(n.peers, id)
+		case since > n.cfg.DeadTimeout:
+			p.dead = true
+			n.stopQueue(p)
+		}
+	}
+}
+
+// List of members to gossip: the node itself and members it knows, n.mux must be held by caller
+func (n *Node) digest() []Member {
+	members := make([]Member, 0, len(n.peers)+1)
+	members = append(members, n.self)
+	for _, p := range n.peers {
+		if !p.dead {
+			members = append(members, p.Member)
+		}
+	}
+	return members
+}
+
+// Merging gossip (members) into known members, newer heartbeats win
+func (n *Node) merge(members []Member) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	now := time.Now()
+	for _, m := range members {
+		if m.ID == n.id || m.ID == "" {
+			continue
+		}
+		p, ok := n.peers[m.ID]
+		if ok && m.Heartbeat <= p.Heartbeat {
+			continue
+		}
+		if !ok {
+			if m.Left {
+				continue
+			}
+			p = &peer{}
+			n.peers[m.ID] = p
+		}
+		p.Member = m
+		p.seen = now
+		p.dead = false
+		if m.Left {
+			n.stopQueue(p)
+		} else if p.queue == nil {
+			n.startQueue(p)
+		}
+	}
+}
+
+// Forwarding middleware of broker: messages published locally are queued for members interested in their topic,
+// messages received from members are delivered without origin header and aren't forwarded again
+func (n *Node) middleware(next pubsub.PublishFunc) pubsub.PublishFunc {
+	return func(ctx context.Context, tn string, msg *pubsub.Message) error {
+		if _, ok := msg.Headers[OriginHeader]; ok {
+			headers := make(map[string]string, len(msg.Headers)-1)
+			for k, v := range msg.Headers {
+				if k != OriginHeader {
+					headers[k] = v
+				}
+			}
+			if len(headers) == 0 {
+				headers = nil
+			}
+			msg.Headers = headers
+			return next(ctx, tn, msg)
+		}
+		err := next(ctx, tn, msg)
+		if err != nil && !errors.Is(err, pubsub.ErrNoSubscriptions) {
+			return err
+		}
+		n.route(tn, *msg)
+		return err
+	}
+}
+
+// Queueing message (msg) of topic name (tn) for every member having subscriptions of the topic
+func (n *Node) route(tn string, msg pubsub.Message) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.self.Left {
+		return
+	}
+	for _, p := range n.peers {
+		if p.queue == nil || !p.interested(tn) {
+			continue
+		}
+		select {
+		case p.queue <- forward{tn: tn, msg: msg}:
+		default:
+			atomic.AddUint64(&n.stats.Dropped, 1)
+		}
+	}
+}
+
+// Checks if member has subscriptions of topic name (tn)
+func (p *peer) interested(tn string) bool {
+	for _, pattern := range p.Topics {
+		if pubsub.MatchTopic(pattern, tn) {
+			return true
+		}
+	}
+	return false
+}
+
+// Starting queue of member (p) and its worker if Run works, n.mux must be held by caller
+func (n *Node) startQueue(p *peer) {
+	if n.ctx == nil || n.ctx.Err() != nil || p.Left || p.dead || p.queue != nil {
+		return
+	}
+	p.queue = make(chan forward, n.cfg.QueueSize)
+	go n.worker(n.ctx, p.Addr, p.queue)
+}
+
+// Stopping queue of member (p), queued messages are dropped, n.mux must be held by caller
+func (n *Node) stopQueue(p *peer) {
+	if p.queue != nil {
+		close(p.queue)
+		p.queue = nil
+	}
+}
+
+// Sending messages of (queue) to member at address (addr) until queue is closed or ctx is done
+func (n *Node) worker(ctx context.Context, addr string, queue chan forward) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case f, ok := <-queue:
+			if !ok {
+				return
+			}
+			if err := n.send(ctx, addr, f); err != nil {
+				atomic.AddUint64(&n.stats.Failed, 1)
+			} else {
+				atomic.AddUint64(&n.stats.Forwarded, 1)
+			}
+		}
+	}
+}
+
+// Publishing message forwarded by member (origin) to the local broker
+func (n *Node) receive(origin string, f forward) error {
+	headers := make(map[string]string, len(f.msg.Headers)+1)
+	for k, v := range f.msg.Headers {
+		headers[k] = v
+	}
+	headers[OriginHeader] = origin
+	f.msg.Headers = headers
+	_, err := n.ps.PublishMsg(f.tn, f.msg)
+	if err == nil || errors.Is(err, pubsub.ErrNoSubscriptions) {
+		atomic.AddUint64(&n.stats.Received, 1)
+		return nil
+	}
+	return err
+}
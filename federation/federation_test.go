@@ -0,0 +1,138 @@
+package federation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+var testConfig = Config{GossipInterval: 10 * time.Millisecond, DeadTimeout: 200 * time.Millisecond}
+
+// Running node (id) served by a test server with seeds (seeds) until test ends or returned function is called
+func testNode(t *testing.T, id string, seeds ...string) (*Node, string, func()) {
+	var h http.Handler
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	cfg := testConfig
+	cfg.Seeds = seeds
+	n := New(id, s.URL, pubsub.New(), cfg)
+	h = Handler(n)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go n.Run(ctx)
+	return n, s.URL, func() {
+		cancel()
+		s.Close()
+	}
+}
+
+// Waiting until (cond) holds
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Checks if node (n) knows members (ids) with topics (topics) of the last one
+func knows(n *Node, topics int, ids ...string) bool {
+	members := n.Members()
+	if len(members) != len(ids) {
+		return false
+	}
+	for i, m := range members {
+		if m.ID != ids[i] {
+			return false
+		}
+	}
+	return len(members[len(members)-1].Topics) == topics
+}
+
+func TestNode_Forward(t *testing.T) {
+	a, sa, _ := testNode(t, "a")
+	b, _, _ := testNode(t, "b", sa)
+	c, _, _ := testNode(t, "c", sa)
+	a.Broker().Subscribe("orders", "audit")
+	b.Broker().Subscribe("orders", "billing")
+	c.Broker().Subscribe("orders/#", "shipping")
+	c.Broker().Subscribe("payments", "shipping")
+	waitFor(t, func() bool { return knows(a, 2, "a", "b", "c") && knows(b, 2, "a", "b", "c") })
+	waitFor(t, func() bool { return knows(c, 2, "a", "b", "c") })
+
+	id, err := a.Broker().PublishMsg("orders", pubsub.Message{Body: []byte("order"), Headers: map[string]string{"k": "v"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []struct {
+		n      *Node
+		tn, sn string
+	}{{b, "orders", "billing"}, {c, "orders/#", "shipping"}} {
+		var msg *pubsub.Message
+		waitFor(t, func() bool { msg, _ = s.n.Broker().PollMsg(s.tn, s.sn); return msg != nil })
+		if msg.ID != id || string(msg.Body) != "order" || len(msg.Headers) != 1 || msg.Headers["k"] != "v" {
+			t.Fatal(msg)
+		}
+	}
+	b.Broker().Publish("payments", []byte("payment"))
+	waitFor(t, func() bool { d, _ := c.Broker().Depth("payments", "shipping"); return d == 1 })
+	waitFor(t, func() bool { return a.Stats().Forwarded == 2 && b.Stats().Forwarded == 1 })
+	if d, _ := a.Broker().Depth("orders", "audit"); d != 1 {
+		// forwarded messages don't come back
+		t.Fatal(d)
+	}
+	if s := c.Stats(); s.Received != 2 || s.Forwarded != 0 {
+		t.Fatal(s)
+	}
+}
+
+func TestNode_Leave(t *testing.T) {
+	a, sa, _ := testNode(t, "a")
+	b, _, stop := testNode(t, "b", sa)
+	c, _, _ := testNode(t, "c", sa)
+	b.Broker().Subscribe("orders", "billing")
+	waitFor(t, func() bool { return knows(a, 0, "a", "b", "c") && knows(c, 0, "a", "b", "c") })
+	if err := c.Leave(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return knows(a, 1, "a", "b") })
+	if err := c.Run(context.Background()); err != ErrRunning {
+		t.Fatal(err)
+	}
+	stop()
+	waitFor(t, func() bool { return knows(a, 0, "a") })
+	a.Broker().Publish("orders", []byte("order"))
+	if s := a.Stats(); s.Forwarded != 0 || s.Dropped != 0 {
+		t.Fatal(s)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	n := New("a", "", pubsub.New(), Config{})
+	s := httptest.NewServer(Handler(n))
+	defer s.Close()
+	res, err := http.Get(s.URL + gossipPath)
+	if err != nil || res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal(res, err)
+	}
+	res.Body.Close()
+	n.Broker().Subscribe("orders", "billing")
+	if err := n.send(context.Background(), s.URL, forward{tn: "orders", msg: pubsub.Message{Body: []byte("order")}}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ := n.Broker().PollMsg("orders", "billing"); msg == nil || string(msg.Body) != "order" || msg.Headers != nil {
+		t.Fatal(msg)
+	}
+	if s := n.Stats(); s.Received != 1 || s.Forwarded != 0 {
+		t.Fatal(s)
+	}
+}
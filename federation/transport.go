@@ -0,0 +1,112 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Paths of Handler
+const (
+	gossipPath  = "/federation/gossip"
+	publishPath = "/federation/publish"
+)
+
+// Body of forwarding request
+type publishRequest struct {
+	Origin  string         `json:"origin"`
+	Topic   string         `json:"topic"`
+	Message pubsub.Message `json:"message"`
+}
+
+// Sending known members to member at address (addr) and merging members it knows
+func (n *Node) exchange(ctx context.Context, addr string) error {
+	n.mux.Lock()
+	members := n.digest()
+	n.mux.Unlock()
+	var resp []Member
+	if err := n.post(ctx, addr, gossipPath, members, &resp); err != nil {
+		return err
+	}
+	n.merge(resp)
+	return nil
+}
+
+// Forwarding message (f) to member at address (addr)
+func (n *Node) send(ctx context.Context, addr string, f forward) error {
+	return n.post(ctx, addr, publishPath, publishRequest{Origin: n.id, Topic: f.tn, Message: f.msg}, nil)
+}
+
+// Posting (req) as JSON to (path) of member at address (addr), response is decoded to (resp) unless it's nil
+func (n *Node) post(ctx context.Context, addr, path string, req, resp interface{}) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(addr, "/") + path
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	res, err := n.cfg.Client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("%s: %s", url, res.Status)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+// HTTP handler of requests of other members of node (n): POST /federation/gossip and POST /federation/publish
+func Handler(n *Node) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(gossipPath, func(w http.ResponseWriter, r *http.Request) {
+		var members []Member
+		if !decode(w, r, &members) {
+			return
+		}
+		n.merge(members)
+		n.mux.Lock()
+		members = n.digest()
+		n.mux.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(members)
+	})
+	mux.HandleFunc(publishPath, func(w http.ResponseWriter, r *http.Request) {
+		var req publishRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		if err := n.receive(req.Origin, forward{tn: req.Topic, msg: req.Message}); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// Decoding POST request (r) to (req), false if error was written to (w)
+func decode(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package pubsub
+
+import (
+	"maps"
+	"slices"
+)
+
+// How many times a message may be forwarded, so rules forwarding messages in a loop don't publish them forever
+const maxForwardHops = 8
+
+// Rule of Forward: messages of topic name or pattern (src) are published to topic name (dst)
+type forwardRule struct {
+	src, dst  string
+	transform func([]byte) ([]byte, bool)
+}
+
+// Publishing messages of topic name (src), which may be a wildcard pattern, to topic name (dst) after they're
+// published to src, e.g. for fan-in, renaming or enrichment. Forwarded message is a new message with body returned
+// by (transform), key and headers of the original one; it's dropped if transform returns false. Nil transform
+// forwards body as is. Transform gets body shared with subscriptions, it must not modify it
+// Messages are forwarded after they're delivered to src (duplicates and rejected messages aren't), forwarded
+// messages pass publish middlewares and rules of dst. Messages forwarded maxForwardHops times aren't forwarded
+// anymore, messages of dst matching pattern src aren't forwarded to dst. Adding rule of the same src and dst again
+// replaces transform
+// ErrInvalidTopic raises if src or dst is empty or dst is a pattern
+func (p *pubSub) Forward(src, dst string, transform func([]byte) ([]byte, bool)) error {
+	if src == "" || dst == "" || isPattern(dst) {
+		return ErrInvalidTopic
+	}
+	p.updateForwards(func(rules []forwardRule) []forwardRule {
+		for i, r := range rules {
+			if r.src == src && r.dst == dst {
+				rules[i].transform = transform
+				return rules
+			}
+		}
+		return append(rules, forwardRule{src: src, dst: dst, transform: transform})
+	})
+	return nil
+}
+
+// Removing rule added by Forward for topic names (src) and (dst)
+func (p *pubSub) Unforward(src, dst string) {
+	p.updateForwards(func(rules []forwardRule) []forwardRule {
+		return slices.DeleteFunc(rules, func(r forwardRule) bool {
+			return r.src == src && r.dst == dst
+		})
+	})
+}
+
+// Replacing rules with copy changed by (fn)
+func (p *pubSub) updateForwards(fn func(rules []forwardRule) []forwardRule) {
+	p.forwardsMux.Lock()
+	defer p.forwardsMux.Unlock()
+	var rules []forwardRule
+	if old := p.forwards.Load(); old != nil {
+		rules = slices.Clone(*old)
+	}
+	rules = fn(rules)
+	p.forwards.Store(&rules)
+}
+
+// Publishing message (m) delivered to topic name (tn) to destinations of matching rules in order rules were added
+// Locks must not be held by caller
+func (p *pubSub) forward(tn string, m *message) {
+	rules := p.forwards.Load()
+	if rules == nil || len(*rules) == 0 {
+		return
+	}
+	var body []byte
+	for _, r := range *rules {
+		if r.dst == tn || r.src != tn && !(isPattern(r.src) && matchPattern(r.src, tn)) {
+			continue
+		}
+		if m.hops >= maxForwardHops {
+			p.log.Warn("message isn't forwarded", "topic", tn, "id", m, "reason", "too many hops")
+			return
+		}
+		if body == nil {
+			var err error
+			if body, err = m.body(); err != nil {
+				p.log.Warn("message isn't forwarded", "topic", tn, "id", m, "error", err)
+				return
+			}
+		}
+		b, ok := body, true
+		if r.transform != nil {
+			if b, ok = r.transform(body); !ok {
+				continue
+			}
+		}
+		fm := p.newMessage(r.dst, Message{Key: m.Key, Headers: maps.Clone(m.Headers), Body: b})
+		fm.hops = m.hops + 1
+		if _, err := p.publish(r.dst, fm); err != nil {
+			p.log.Warn("message isn't forwarded", "topic", tn, "to", r.dst, "id", m, "error", err)
+		}
+	}
+}
+
+// Forwarding messages of topic name (src) of the namespace to topic name (dst) of the namespace
+func (n *namespace) Forward(src, dst string, transform func([]byte) ([]byte, bool)) error {
+	if src == "" || dst == "" {
+		return ErrInvalidTopic
+	}
+	return n.p.Forward(n.topic(src), n.topic(dst), transform)
+}
+
+func (n *namespace) Unforward(src, dst string) {
+	n.p.Unforward(n.topic(src), n.topic(dst))
+}
+
+// Forwarding messages if principal may subscribe to (src) and publish to (dst)
+func (a *authorized) Forward(src, dst string, transform func([]byte) ([]byte, bool)) error {
+	if err := a.allow(OpSubscribe, src, ""); err != nil {
+		return err
+	}
+	if err := a.allow(OpPublish, dst, ""); err != nil {
+		return err
+	}
+	return a.next.Forward(src, dst, transform)
+}
+
+// Removing forwarding rule if principal may subscribe to (src) and publish to (dst)
+func (a *authorized) Unforward(src, dst string) {
+	if a.allow(OpSubscribe, src, "") == nil && a.allow(OpPublish, dst, "") == nil {
+		a.next.Unforward(src, dst)
+	}
+}
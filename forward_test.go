@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPubSub_Forward(t *testing.T) {
+	lib := New()
+	lib.Subscribe("all", "s")
+	lib.Subscribe("orders/eu", "s")
+	if err := lib.Forward("orders/+", "all", func(b []byte) ([]byte, bool) {
+		if bytes.Equal(b, []byte("skip")) {
+			return nil, false
+		}
+		return append([]byte("order "), b...), true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.Forward("orders/eu", "all/+", nil); err != ErrInvalidTopic {
+		t.Fatal(err)
+	}
+	if _, err := lib.PublishMsg("orders/eu", Message{Key: "k", Headers: map[string]string{"h": "v"}, Body: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	lib.Publish("orders/us", []byte("2"))
+	lib.Publish("orders/us", []byte("skip"))
+	if err := lib.PublishTx(func(tx Tx) error { return tx.Publish("orders/eu", []byte("3")) }); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := lib.Poll("orders/eu", "s"); string(b) != "1" {
+		t.Fatal(string(b))
+	}
+	msg, _ := lib.PollMsg("all", "s")
+	if msg == nil || string(msg.Body) != "order 1" || msg.Key != "k" || msg.Headers["h"] != "v" {
+		t.Fatal(msg)
+	}
+	for _, want := range []string{"order 2", "order 3"} {
+		if b, _ := lib.Poll("all", "s"); string(b) != want {
+			t.Fatal(string(b))
+		}
+	}
+	lib.Unforward("orders/+", "all")
+	lib.Publish("orders/eu", []byte("4"))
+	if depth, _ := lib.Depth("all", "s"); depth != 0 {
+		t.Fatal(depth)
+	}
+}
+
+func TestPubSub_Forward_Loop(t *testing.T) {
+	lib := New()
+	lib.Subscribe("a", "s")
+	_ = lib.Forward("a", "b", nil)
+	_ = lib.Forward("b", "a", nil)
+	lib.Publish("a", []byte("message"))
+	// the original message and forwarded back by every second hop
+	if depth, _ := lib.Depth("a", "s"); depth != 1+maxForwardHops/2 {
+		t.Fatal(depth)
+	}
+}
+
+func TestNamespace_Forward(t *testing.T) {
+	lib := New()
+	ns := lib.Namespace("tenant")
+	ns.Subscribe("dst", "s")
+	if err := ns.Forward("#", "dst", nil); err != nil {
+		t.Fatal(err)
+	}
+	lib.Subscribe("src", "s")
+	lib.Publish("src", []byte("other"))
+	ns.Publish("src", []byte("message"))
+	if b, _ := ns.Poll("dst", "s"); string(b) != "message" {
+		t.Fatal(string(b))
+	}
+	if depth, _ := ns.Depth("dst", "s"); depth != 0 {
+		t.Fatal(depth)
+	}
+}
+
+func TestAuthorized_Forward(t *testing.T) {
+	lib := New(WithAuthorizer(testAuthorizer()))
+	if err := lib.As("alice").Forward("alice/a", "bob/b", nil); err != ErrForbidden {
+		t.Fatal(err)
+	}
+	if err := lib.As("alice").Forward("alice/a", "alice/b", nil); err != nil {
+		t.Fatal(err)
+	}
+}
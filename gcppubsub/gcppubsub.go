@@ -0,0 +1,370 @@
+/*
+Facade of pubsub package mirroring API of cloud.google.com/go/pubsub (Client, Topic, Subscription, Message,
+ReceiveSettings), so code written for Google Cloud Pub/Sub may be tested against the in-memory broker without Docker
+or the emulator:
+
+	client := gcppubsub.NewClient(pubsub.New(), "project")
+	topic, _ := client.CreateTopic(ctx, "orders")
+	sub, _ := client.CreateSubscription(ctx, "billing", gcppubsub.SubscriptionConfig{Topic: topic})
+	topic.Publish(ctx, &gcppubsub.Message{Data: b})
+	err := sub.Receive(ctx, func(ctx context.Context, m *gcppubsub.Message) { m.Ack() })
+
+Topic ID is the topic name of broker, subscription ID is the subscription name. Messages are received with PollAck:
+a message which is neither acknowledged nor nacked is delivered again after ReceiveSettings.MaxExtension
+(AckDeadline of subscription if MaxExtension is negative). Unlike Google Cloud Pub/Sub deleting a topic deletes its
+subscriptions too.
+*/
+package gcppubsub
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Error happens if topic or subscription with such ID exists already
+var ErrAlreadyExists = errors.New("pubsub: resource already exists")
+
+// Default ack deadline of subscriptions
+const DefaultAckDeadline = 10 * time.Second
+
+// How often Receive polls empty subscription
+const pollInterval = 10 * time.Millisecond
+
+// Settings of Subscription.Receive
+// MaxExtension - how long a message may stay not acknowledged before it is delivered again, AckDeadline of
+// subscription is used if negative
+// MaxOutstandingMessages - maximum number of callbacks running at once
+type ReceiveSettings struct {
+	MaxExtension           time.Duration
+	MaxOutstandingMessages int
+}
+
+// Default settings of Subscription.Receive, used for zero fields
+var DefaultReceiveSettings = ReceiveSettings{
+	MaxExtension:           60 * time.Minute,
+	MaxOutstandingMessages: 1000,
+}
+
+// Settings of subscription
+// Topic - topic of subscription, required by CreateSubscription
+// AckDeadline - see ReceiveSettings.MaxExtension, DefaultAckDeadline is used if zero
+type SubscriptionConfig struct {
+	Topic       *Topic
+	AckDeadline time.Duration
+}
+
+// Client creates and looks up topics and subscriptions of broker
+// configs - settings of subscriptions created by the client, protected by mux
+type Client struct {
+	ps      pubsub.PubSuber
+	project string
+	mux     sync.Mutex
+	configs map[string]SubscriptionConfig
+}
+
+// Constructor. Creates Client of project (projectID) backed by broker (ps)
+func NewClient(ps pubsub.PubSuber, projectID string) *Client {
+	return &Client{ps: ps, project: projectID, configs: map[string]SubscriptionConfig{}}
+}
+
+// ID of project of the client
+func (c *Client) Project() string {
+	return c.project
+}
+
+// Doing nothing, the broker isn't closed
+func (c *Client) Close() error {
+	return nil
+}
+
+// Topic (id) which may not exist yet
+func (c *Client) Topic(id string) *Topic {
+	return &Topic{c: c, id: id}
+}
+
+// Creating topic (id), ErrAlreadyExists raises if it exists already
+func (c *Client) CreateTopic(ctx context.Context, id string) (*Topic, error) {
+	t := c.Topic(id)
+	if ok, _ := t.Exists(ctx); ok {
+		return nil, ErrAlreadyExists
+	}
+	if err := c.ps.CreateTopic(id, pubsub.TopicConfig{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Subscription (id) which may not exist yet
+func (c *Client) Subscription(id string) *Subscription {
+	return &Subscription{c: c, id: id}
+}
+
+// Creating subscription (id) of cfg.Topic receiving messages published after that
+// ErrAlreadyExists raises if it exists already, pubsub.ErrTopicNotFound - if topic doesn't exist
+func (c *Client) CreateSubscription(ctx context.Context, id string, cfg SubscriptionConfig) (*Subscription, error) {
+	if cfg.Topic == nil {
+		return nil, pubsub.ErrTopicNotFound
+	}
+	if err := cfg.Topic.exists(); err != nil {
+		return nil, err
+	}
+	s := c.Subscription(id)
+	if _, err := s.topic(); err == nil {
+		return nil, ErrAlreadyExists
+	}
+	if cfg.AckDeadline <= 0 {
+		cfg.AckDeadline = DefaultAckDeadline
+	}
+	c.ps.Subscribe(cfg.Topic.id, id)
+	c.mux.Lock()
+	c.configs[id] = cfg
+	c.mux.Unlock()
+	return s, nil
+}
+
+// Topic of broker
+type Topic struct {
+	c  *Client
+	id string
+}
+
+// ID of the topic
+func (t *Topic) ID() string {
+	return t.id
+}
+
+// Fully qualified name of the topic
+func (t *Topic) String() string {
+	return "projects/" + t.c.project + "/topics/" + t.id
+}
+
+// Checks if the topic exists
+func (t *Topic) Exists(ctx context.Context) (bool, error) {
+	err := t.exists()
+	if err == pubsub.ErrTopicNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// pubsub.ErrTopicNotFound raises if the topic doesn't exist
+func (t *Topic) exists() error {
+	_, err := t.c.ps.Subscriptions(t.id)
+	return err
+}
+
+// Deleting the topic with all its subscriptions, pubsub.ErrTopicNotFound raises if it doesn't exist
+func (t *Topic) Delete(ctx context.Context) error {
+	if err := t.exists(); err != nil {
+		return err
+	}
+	t.c.ps.DeleteTopic(t.id)
+	return nil
+}
+
+// Publishing message (msg) to the topic, the result is ready at once, pubsub.ErrTopicNotFound raises if the topic
+// doesn't exist
+// ID and PublishTime of msg are set after publishing
+func (t *Topic) Publish(ctx context.Context, msg *Message) *PublishResult {
+	r := &PublishResult{ready: make(chan struct{})}
+	defer close(r.ready)
+	if r.err = t.exists(); r.err != nil {
+		return r
+	}
+	r.id, r.err = t.c.ps.PublishMsg(t.id, pubsub.Message{Headers: msg.Attributes, Body: msg.Data})
+	if r.err == nil {
+		msg.ID, msg.PublishTime = r.id, time.Now()
+	}
+	return r
+}
+
+// Doing nothing, messages are published synchronously
+func (t *Topic) Stop() {}
+
+// Result of Topic.Publish
+type PublishResult struct {
+	ready chan struct{}
+	id    string
+	err   error
+}
+
+// Channel closed when the result is ready
+func (r *PublishResult) Ready() <-chan struct{} {
+	return r.ready
+}
+
+// ID of published message or publishing error, waits until the result is ready or ctx is done
+func (r *PublishResult) Get(ctx context.Context) (string, error) {
+	select {
+	case <-r.ready:
+		return r.id, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Message published to a topic or received from a subscription
+// Attributes are headers of broker message, OrderingKey is kept for compatibility only (broker keeps order anyway)
+// DeliveryAttempt - number of deliveries of received message including this one, nil for published messages
+type Message struct {
+	ID              string
+	Data            []byte
+	Attributes      map[string]string
+	PublishTime     time.Time
+	OrderingKey     string
+	DeliveryAttempt *int
+	once            sync.Once
+	done            func(ack bool)
+}
+
+// Acknowledging received message, calls after the first Ack or Nack are ignored
+func (m *Message) Ack() {
+	m.finish(true)
+}
+
+// Returning received message to the subscription for redelivery, calls after the first Ack or Nack are ignored
+func (m *Message) Nack() {
+	m.finish(false)
+}
+
+func (m *Message) finish(ack bool) {
+	if m.done != nil {
+		m.once.Do(func() { m.done(ack) })
+	}
+}
+
+// Subscription of broker
+// ReceiveSettings - settings of Receive, DefaultReceiveSettings are used for zero fields
+type Subscription struct {
+	c               *Client
+	id              string
+	ReceiveSettings ReceiveSettings
+}
+
+// ID of the subscription
+func (s *Subscription) ID() string {
+	return s.id
+}
+
+// Fully qualified name of the subscription
+func (s *Subscription) String() string {
+	return "projects/" + s.c.project + "/subscriptions/" + s.id
+}
+
+// Topic name of the subscription, pubsub.ErrSubscriptionNotFound raises if it doesn't exist
+// Subscriptions created by other clients are looked up in all topics of broker
+func (s *Subscription) topic() (string, error) {
+	s.c.mux.Lock()
+	cfg, ok := s.c.configs[s.id]
+	s.c.mux.Unlock()
+	if ok {
+		if sns, err := s.c.ps.Subscriptions(cfg.Topic.id); err == nil && slices.Contains(sns, s.id) {
+			return cfg.Topic.id, nil
+		}
+	}
+	for _, tn := range s.c.ps.Topics() {
+		if sns, err := s.c.ps.Subscriptions(tn); err == nil && slices.Contains(sns, s.id) {
+			return tn, nil
+		}
+	}
+	return "", pubsub.ErrSubscriptionNotFound
+}
+
+// Checks if the subscription exists
+func (s *Subscription) Exists(ctx context.Context) (bool, error) {
+	if _, err := s.topic(); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Settings of the subscription
+func (s *Subscription) Config(ctx context.Context) (SubscriptionConfig, error) {
+	tn, err := s.topic()
+	if err != nil {
+		return SubscriptionConfig{}, err
+	}
+	s.c.mux.Lock()
+	cfg, ok := s.c.configs[s.id]
+	s.c.mux.Unlock()
+	if !ok || cfg.Topic.id != tn {
+		cfg = SubscriptionConfig{Topic: s.c.Topic(tn), AckDeadline: DefaultAckDeadline}
+	}
+	return cfg, nil
+}
+
+// Deleting the subscription with its pending messages
+func (s *Subscription) Delete(ctx context.Context) error {
+	tn, err := s.topic()
+	if err != nil {
+		return err
+	}
+	s.c.ps.Unsubscribe(tn, s.id)
+	s.c.mux.Lock()
+	delete(s.c.configs, s.id)
+	s.c.mux.Unlock()
+	return nil
+}
+
+// Calling (f) concurrently for received messages until ctx is done or the subscription is deleted
+// nil is returned when ctx is done, Receive returns after all running callbacks returned
+func (s *Subscription) Receive(ctx context.Context, f func(context.Context, *Message)) error {
+	cfg, err := s.Config(ctx)
+	if err != nil {
+		return err
+	}
+	settings := s.ReceiveSettings
+	if settings.MaxExtension == 0 {
+		settings.MaxExtension = DefaultReceiveSettings.MaxExtension
+	}
+	if settings.MaxExtension < 0 {
+		settings.MaxExtension = cfg.AckDeadline
+	}
+	if settings.MaxOutstandingMessages <= 0 {
+		settings.MaxOutstandingMessages = DefaultReceiveSettings.MaxOutstandingMessages
+	}
+	tn := cfg.Topic.id
+	slots := make(chan struct{}, settings.MaxOutstandingMessages)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case slots <- struct{}{}:
+		}
+		msg, token, err := s.c.ps.PollAckMsg(tn, s.id, settings.MaxExtension)
+		if err != nil && !errors.Is(err, pubsub.ErrEmpty) && !errors.Is(err, pubsub.ErrMaxInFlight) {
+			return err
+		}
+		if msg == nil {
+			<-slots
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		m := &Message{ID: msg.ID, Data: msg.Body, Attributes: msg.Headers, PublishTime: msg.PublishedAt,
+			DeliveryAttempt: &msg.DeliveryAttempt}
+		m.done = func(ack bool) {
+			if ack {
+				_ = s.c.ps.Ack(tn, s.id, token)
+			} else {
+				_ = s.c.ps.Nack(tn, s.id, token, true)
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			f(ctx, m)
+		}()
+	}
+}
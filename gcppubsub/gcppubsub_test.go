@@ -0,0 +1,126 @@
+package gcppubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(pubsub.New(), "project")
+	topic, err := c.CreateTopic(ctx, "orders")
+	if err != nil || topic.String() != "projects/project/topics/orders" {
+		t.FailNow()
+	}
+	if _, err := c.CreateTopic(ctx, "orders"); err != ErrAlreadyExists {
+		t.FailNow()
+	}
+	if ok, err := c.Topic("unknown").Exists(ctx); ok || err != nil {
+		t.FailNow()
+	}
+	if _, err := c.Topic("unknown").Publish(ctx, &Message{Data: []byte("message")}).Get(ctx); err != pubsub.ErrTopicNotFound {
+		t.FailNow()
+	}
+	if _, err := c.CreateSubscription(ctx, "billing", SubscriptionConfig{Topic: c.Topic("unknown")}); err != pubsub.ErrTopicNotFound {
+		t.FailNow()
+	}
+	sub, err := c.CreateSubscription(ctx, "billing", SubscriptionConfig{Topic: topic, AckDeadline: time.Minute})
+	if err != nil {
+		t.FailNow()
+	}
+	if _, err := c.CreateSubscription(ctx, "billing", SubscriptionConfig{Topic: topic}); err != ErrAlreadyExists {
+		t.FailNow()
+	}
+	// subscriptions are found by other clients of the broker
+	other := NewClient(c.ps, "project")
+	if cfg, err := other.Subscription("billing").Config(ctx); err != nil || cfg.Topic.ID() != "orders" || cfg.AckDeadline != DefaultAckDeadline {
+		t.FailNow()
+	}
+	if cfg, _ := sub.Config(ctx); cfg.AckDeadline != time.Minute {
+		t.FailNow()
+	}
+	if err := topic.Delete(ctx); err != nil {
+		t.FailNow()
+	}
+	if ok, _ := sub.Exists(ctx); ok {
+		t.FailNow()
+	}
+}
+
+func TestSubscription_Receive(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(pubsub.New(), "project")
+	topic, _ := c.CreateTopic(ctx, "orders")
+	sub, _ := c.CreateSubscription(ctx, "billing", SubscriptionConfig{Topic: topic})
+	msg := &Message{Data: []byte("first"), Attributes: map[string]string{"type": "x"}}
+	id, err := topic.Publish(ctx, msg).Get(ctx)
+	if err != nil || id == "" || msg.ID != id {
+		t.FailNow()
+	}
+	topic.Publish(ctx, &Message{Data: []byte("second")})
+	var mux sync.Mutex
+	var received []string
+	nacked := false
+	rctx, cancel := context.WithCancel(ctx)
+	err = sub.Receive(rctx, func(ctx context.Context, m *Message) {
+		mux.Lock()
+		defer mux.Unlock()
+		// the first message is nacked once and delivered again
+		if string(m.Data) == "first" && !nacked {
+			nacked = true
+			m.Nack()
+			return
+		}
+		if string(m.Data) == "first" && (m.ID != id || m.Attributes["type"] != "x") {
+			t.Error("wrong message")
+		}
+		received = append(received, string(m.Data))
+		m.Ack()
+		if len(received) == 2 {
+			cancel()
+		}
+	})
+	if err != nil || len(received) != 2 {
+		t.Fatal(err, received)
+	}
+	if depth, _ := c.ps.Depth("orders", "billing"); depth != 0 {
+		t.FailNow()
+	}
+	if err := sub.Delete(ctx); err != nil {
+		t.FailNow()
+	}
+	if err := sub.Receive(ctx, func(ctx context.Context, m *Message) {}); err != pubsub.ErrSubscriptionNotFound {
+		t.FailNow()
+	}
+}
+
+func TestSubscription_Receive_Redelivery(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(pubsub.New(), "project")
+	topic, _ := c.CreateTopic(ctx, "orders")
+	sub, _ := c.CreateSubscription(ctx, "billing", SubscriptionConfig{Topic: topic, AckDeadline: 10 * time.Millisecond})
+	sub.ReceiveSettings.MaxExtension = -1
+	topic.Publish(ctx, &Message{Data: []byte("message")})
+	var mux sync.Mutex
+	deliveries := 0
+	rctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_ = sub.Receive(rctx, func(ctx context.Context, m *Message) {
+		mux.Lock()
+		defer mux.Unlock()
+		// not acknowledged message is delivered again after ack deadline
+		if deliveries++; m.DeliveryAttempt == nil || *m.DeliveryAttempt != deliveries {
+			t.Error(m.DeliveryAttempt, deliveries)
+		}
+		if deliveries == 2 {
+			m.Ack()
+		}
+	})
+	if deliveries != 2 {
+		t.Fatal(deliveries)
+	}
+}
@@ -0,0 +1,171 @@
+package pubsub
+
+import (
+	"context"
+	"iter"
+	"sync/atomic"
+	"time"
+)
+
+// Handle of subscription (sn) of topic name (tn) of broker (ps), its methods call methods of the broker with the names
+// of the subscription, so they aren't passed (and mixed up) everywhere. Handles of any PubSuber (facades, clients, ...)
+// work the same way, the string API of PubSuber stays available
+type Subscription struct {
+	ps     PubSuber
+	tn, sn string
+}
+
+// Constructor. Subscribes to topic name (tn) with subscription name (sn) of broker (ps) and returns its handle
+// Existing subscription is kept as is, so the handle may be created for it again, e.g. after restart
+func NewSubscription(ps PubSuber, tn, sn string) *Subscription {
+	ps.Subscribe(tn, sn)
+	return &Subscription{ps: ps, tn: tn, sn: sn}
+}
+
+// Topic name of the subscription
+func (s *Subscription) Topic() string {
+	return s.tn
+}
+
+// Name of the subscription
+func (s *Subscription) Name() string {
+	return s.sn
+}
+
+// Fetching message, nil if there are no messages (see PubSuber.Poll)
+func (s *Subscription) Poll() ([]byte, error) {
+	return s.ps.Poll(s.tn, s.sn)
+}
+
+// Fetching message with headers and metadata (see PubSuber.PollMsg)
+func (s *Subscription) PollMsg() (*Message, error) {
+	return s.ps.PollMsg(s.tn, s.sn)
+}
+
+// Waiting for a message until it arrives or ctx is done (see PubSuber.PollWait)
+func (s *Subscription) PollWait(ctx context.Context) ([]byte, error) {
+	return s.ps.PollWait(ctx, s.tn, s.sn)
+}
+
+// Fetching message which stays in flight until Ack is called or (visibility) timeout expires (see PubSuber.PollAck)
+func (s *Subscription) PollAck(visibility time.Duration) ([]byte, AckToken, error) {
+	return s.ps.PollAck(s.tn, s.sn, visibility)
+}
+
+// Acknowledging message polled with PollAck
+func (s *Subscription) Ack(token AckToken) error {
+	return s.ps.Ack(s.tn, s.sn, token)
+}
+
+// Returning message polled with PollAck to the beginning of the queue if (requeue) is set, to its end otherwise
+func (s *Subscription) Nack(token AckToken, requeue bool) error {
+	return s.ps.Nack(s.tn, s.sn, token, requeue)
+}
+
+// Extending visibility timeout of message polled with PollAck to (visibility) from now
+func (s *Subscription) ExtendAck(token AckToken, visibility time.Duration) error {
+	return s.ps.ExtendAck(s.tn, s.sn, token, visibility)
+}
+
+// Iterating over messages until ctx is done (see PubSuber.Iter)
+func (s *Subscription) Iter(ctx context.Context) iter.Seq2[[]byte, error] {
+	return s.ps.Iter(ctx, s.tn, s.sn)
+}
+
+// Number of pending messages
+func (s *Subscription) Depth() (int, error) {
+	return s.ps.Depth(s.tn, s.sn)
+}
+
+// Number of messages polled with PollAck and not acknowledged yet
+func (s *Subscription) InFlight() (int, error) {
+	return s.ps.InFlight(s.tn, s.sn)
+}
+
+// Unsubscribing, pending messages are dropped. Error is always nil, it's returned to implement io.Closer
+func (s *Subscription) Close() error {
+	s.ps.Unsubscribe(s.tn, s.sn)
+	return nil
+}
+
+// Handle of topic name (tn) of broker (ps) publishing to it and inspecting it without passing its name everywhere
+// Handles of the broker created by New cache the topic, so publishing through them doesn't look it up by name while
+// the topic exists (the cache is refreshed if the topic is removed and created again)
+// subs - cached subscriptions list of the topic, used only by broker (p) created by New
+type Topic struct {
+	ps   PubSuber
+	tn   string
+	p    *pubSub
+	subs atomic.Pointer[subscriptions]
+}
+
+// Constructor. Creates handle of topic name (tn) of broker (ps), brokers implement PubSuber.Topic with it
+func NewTopic(ps PubSuber, tn string) *Topic {
+	t := &Topic{ps: ps, tn: tn}
+	t.p, _ = ps.(*pubSub)
+	return t
+}
+
+// Handle of topic name (tn)
+func (p *pubSub) Topic(tn string) *Topic {
+	return NewTopic(p, tn)
+}
+
+// Subscriptions list of topic name (tn), nil if there is no such topic. Cached list of handle (t) is used if it's
+// a handle of the topic and the list wasn't removed, otherwise the looked up one is cached
+func (p *pubSub) lookup(tn string, t *Topic) *subscriptions {
+	if t == nil || t.tn != tn {
+		subs, _ := p.topics.get(tn)
+		return subs
+	}
+	if subs := t.subs.Load(); subs != nil && !subs.deleted.Load() {
+		return subs
+	}
+	subs, _ := p.topics.get(tn)
+	t.subs.Store(subs)
+	return subs
+}
+
+// Name of the topic
+func (t *Topic) Name() string {
+	return t.tn
+}
+
+// Publishing message (b), see PubSuber.TryPublish
+func (t *Topic) Publish(b []byte) error {
+	if t.p == nil {
+		return t.ps.TryPublish(t.tn, b)
+	}
+	m := t.p.newMessage(t.tn, Message{Body: b})
+	m.via = t
+	_, err := t.p.publishReleased(t.tn, m)
+	return err
+}
+
+// Publishing message (msg) with headers, returns message ID, see PubSuber.PublishMsg
+func (t *Topic) PublishMsg(msg Message) (string, error) {
+	if t.p == nil {
+		return t.ps.PublishMsg(t.tn, msg)
+	}
+	m := t.p.newMessage(t.tn, msg)
+	m.via = t
+	_, err := t.p.publish(t.tn, m)
+	id := m.id()
+	m.release()
+	return id, err
+}
+
+// Names of subscriptions of the topic, see PubSuber.Subscriptions
+func (t *Topic) Subscribers() ([]string, error) {
+	return t.ps.Subscriptions(t.tn)
+}
+
+// Settings of the topic, see PubSuber.TopicConfig
+func (t *Topic) Config() (TopicConfig, error) {
+	return t.ps.TopicConfig(t.tn)
+}
+
+// Subscribing to the topic with subscription name (sn), see NewSubscription
+func (t *Topic) Subscribe(sn string) *Subscription {
+	return NewSubscription(t.ps, t.tn, sn)
+}
@@ -0,0 +1,121 @@
+package pubsub
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+var _ io.Closer = (*Subscription)(nil)
+
+func TestSubscription(t *testing.T) {
+	lib := New()
+	sub := NewSubscription(lib, "orders", "billing")
+	if sub.Topic() != "orders" || sub.Name() != "billing" {
+		t.Fatal(sub.Topic(), sub.Name())
+	}
+	for _, b := range []string{"1", "2", "3"} {
+		lib.Publish("orders", []byte(b))
+	}
+	if depth, err := sub.Depth(); depth != 3 || err != nil {
+		t.Fatal(depth, err)
+	}
+	if msg, err := sub.Poll(); string(msg) != "1" || err != nil {
+		t.Fatal(msg, err)
+	}
+	msg, token, err := sub.PollAck(time.Minute)
+	if string(msg) != "2" || err != nil {
+		t.Fatal(msg, err)
+	}
+	if err := sub.Nack(token, true); err != nil {
+		t.Fatal(err)
+	}
+	_, token, _ = sub.PollAck(time.Minute)
+	if err := sub.Ack(token); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if msg, err := sub.PollWait(ctx); string(msg) != "3" || err != nil {
+		t.Fatal(msg, err)
+	}
+	// handle of existing subscription keeps its messages
+	lib.Publish("orders", []byte("4"))
+	sub = NewSubscription(lib, "orders", "billing")
+	if depth, _ := sub.Depth(); depth != 1 {
+		t.Fatal(depth)
+	}
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Poll(); err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTopic(t *testing.T) {
+	lib := New()
+	topic := lib.Topic("orders")
+	if _, err := topic.Config(); err != ErrTopicNotFound {
+		t.Fatal(err)
+	}
+	sub := topic.Subscribe("billing")
+	lib.Subscribe("orders", "shipping")
+	if err := topic.Publish([]byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	id, err := topic.PublishMsg(Message{Body: []byte("2"), Headers: map[string]string{"k": "v"}})
+	if err != nil || id == "" {
+		t.Fatal(id, err)
+	}
+	if sns, _ := topic.Subscribers(); len(sns) != 2 || sns[0] != "billing" || sns[1] != "shipping" {
+		t.Fatal(sns)
+	}
+	if msg, _ := sub.PollMsg(); msg == nil || string(msg.Body) != "1" || msg.Topic != "orders" {
+		t.Fatal(msg)
+	}
+	if msg, _ := sub.PollMsg(); msg == nil || msg.ID != id || msg.Headers["k"] != "v" {
+		t.Fatal(msg)
+	}
+	if cfg, err := topic.Config(); err != nil || cfg != (TopicConfig{}) {
+		t.Fatal(cfg, err)
+	}
+	// cached topic is refreshed when it's created again
+	lib.DeleteTopic("orders")
+	if err := lib.CreateTopic("orders", TopicConfig{MaxMessages: 1}); err != nil {
+		t.Fatal(err)
+	}
+	sub = topic.Subscribe("billing")
+	_ = topic.Publish([]byte("3"))
+	if depth, _ := sub.Depth(); depth != 1 {
+		t.Fatal(depth)
+	}
+	if cfg, _ := topic.Config(); cfg.MaxMessages != 1 {
+		t.Fatal(cfg)
+	}
+}
+
+func TestTopic_Namespace(t *testing.T) {
+	lib := New()
+	ns := lib.Namespace("tenant")
+	topic := ns.Topic("orders")
+	topic.Subscribe("billing")
+	_ = topic.Publish([]byte("1"))
+	if depth, _ := ns.Depth("orders", "billing"); depth != 1 || len(lib.Topics()) != 1 || lib.Topics()[0] == "orders" {
+		t.Fatal(depth, lib.Topics())
+	}
+}
+
+func BenchmarkTopic_Publish(b *testing.B) {
+	lib := New()
+	topic := lib.Topic("some topic")
+	topic.Subscribe("sub")
+	lib.SubscribeWithOptions("some topic", "sub", Options{MaxMessages: 1000})
+	body := []byte("message")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = topic.Publish(body)
+	}
+}
@@ -0,0 +1,65 @@
+package pubsub
+
+// Settings of a SubscribeFunc subscription
+// workers - number of goroutines calling handler, onPanic - called when handler panics
+type handlerConfig struct {
+	workers int
+	onPanic func(msg []byte, r interface{})
+}
+
+// HandlerOption configures SubscribeFunc subscription
+type HandlerOption func(*handlerConfig)
+
+// WithWorkers sets number of goroutines calling handler concurrently (1 by default)
+// Messages are handled in publishing order only with a single worker
+func WithWorkers(n int) HandlerOption {
+	return func(c *handlerConfig) {
+		if n > 0 {
+			c.workers = n
+		}
+	}
+}
+
+// WithPanicHandler sets function which is called with message and recovered value when handler panics
+// Panics are recovered anyway, worker continues with the next message
+func WithPanicHandler(fn func(msg []byte, r interface{})) HandlerOption {
+	return func(c *handlerConfig) {
+		c.onPanic = fn
+	}
+}
+
+// Subscribe to message by topic name (tn) and subscriber name (sn), messages are passed to handler (fn)
+// by a pool of goroutines until Unsubscribe is called
+// ErrSubscriptionExists raises if subscription was created before
+func (p *pubSub) SubscribeFunc(tn, sn string, fn func([]byte), opts ...HandlerOption) error {
+	cfg := handlerConfig{workers: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	ctx, err := p.subscribeCancelable(tn, sn)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < cfg.workers; i++ {
+		go func() {
+			for {
+				msg, err := p.PollWait(ctx, tn, sn)
+				if err != nil {
+					return
+				}
+				cfg.handle(fn, msg)
+			}
+		}()
+	}
+	return nil
+}
+
+// Calling handler (fn) with message (msg), panic is recovered and passed to onPanic if set
+func (c *handlerConfig) handle(fn func([]byte), msg []byte) {
+	defer func() {
+		if r := recover(); r != nil && c.onPanic != nil {
+			c.onPanic(msg, r)
+		}
+	}()
+	fn(msg)
+}
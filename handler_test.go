@@ -0,0 +1,53 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPubSub_SubscribeFunc(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	var mux sync.Mutex
+	var got []string
+	var panics int
+	handled := make(chan struct{}, 3)
+	err := lib.SubscribeFunc(tn, sn, func(b []byte) {
+		if string(b) == "panic" {
+			panic("handler failed")
+		}
+		mux.Lock()
+		got = append(got, string(b))
+		mux.Unlock()
+		handled <- struct{}{}
+	}, WithWorkers(2), WithPanicHandler(func(msg []byte, r interface{}) {
+		mux.Lock()
+		panics++
+		mux.Unlock()
+		handled <- struct{}{}
+	}))
+	if err != nil {
+		t.FailNow()
+	}
+	if err := lib.SubscribeFunc(tn, sn, func([]byte) {}); err != ErrSubscriptionExists {
+		t.FailNow()
+	}
+	for _, m := range []string{"a", "panic", "b"} {
+		lib.Publish(tn, []byte(m))
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.FailNow()
+		}
+	}
+	lib.Unsubscribe(tn, sn)
+	mux.Lock()
+	defer mux.Unlock()
+	if len(got) != 2 || panics != 1 {
+		t.FailNow()
+	}
+}
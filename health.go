@@ -0,0 +1,121 @@
+package pubsub
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// Health of broker returned by Health
+// Live - broker isn't closed; Ready - broker accepts messages: it isn't closing, memory limit isn't reached and all
+// checks added by WithHealthCheck pass; Problems - reasons why broker isn't ready
+// Memory - total size of pending messages, MaxMemory - limit of WithMaxMemory, zero if unlimited
+// Topics, Subscriptions, Pending, InFlight - number of topics, subscriptions, pending messages and messages in flight
+// Slow - number of subscriptions which are full or exceed thresholds of WithWatchdog
+// Goroutines - number of goroutines of the process
+// Checks - errors of failed checks by their names
+type HealthReport struct {
+	Live          bool              `json:"live"`
+	Ready         bool              `json:"ready"`
+	Problems      []string          `json:"problems,omitempty"`
+	Memory        int64             `json:"memory"`
+	MaxMemory     int64             `json:"maxMemory,omitempty"`
+	Topics        int               `json:"topics"`
+	Subscriptions int               `json:"subscriptions"`
+	Pending       int               `json:"pending"`
+	InFlight      int               `json:"inFlight"`
+	Slow          int               `json:"slow"`
+	Goroutines    int               `json:"goroutines"`
+	Checks        map[string]string `json:"checks,omitempty"`
+}
+
+// Named check of WithHealthCheck
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// Adding (check) named (name) to Health, e.g. connectivity of bridge client or lag of persistence, broker isn't
+// ready while it fails. Checks are called by every Health call in order they were added
+func WithHealthCheck(name string, check func() error) Option {
+	return func(p *pubSub) {
+		p.checks = append(p.checks, healthCheck{name: name, check: check})
+	}
+}
+
+// Summary of memory usage, backlog and checks of broker
+func (p *pubSub) Health() HealthReport {
+	h := HealthReport{
+		Live:       !p.closed.Load(),
+		MaxMemory:  p.maxMemory,
+		Goroutines: runtime.NumGoroutine(),
+	}
+	topics := p.topics.all()
+	h.Topics = len(topics)
+	for _, subs := range topics {
+		subs.mux.Lock()
+		for _, sub := range subs.hm {
+			h.Subscriptions++
+			h.Pending += sub.len()
+			h.InFlight += len(sub.inFlight)
+			h.Memory += sub.bytes()
+			if sub.slow || sub.lagging {
+				h.Slow++
+			}
+		}
+		subs.mux.Unlock()
+	}
+	if p.closing.Load() {
+		h.Problems = append(h.Problems, ErrClosed.Error())
+	}
+	if p.maxMemory > 0 && atomic.LoadInt64(&p.memory) >= p.maxMemory {
+		h.Problems = append(h.Problems, ErrMemoryLimit.Error())
+	}
+	for _, c := range p.checks {
+		if err := c.check(); err != nil {
+			if h.Checks == nil {
+				h.Checks = map[string]string{}
+			}
+			h.Checks[c.name] = err.Error()
+			h.Problems = append(h.Problems, fmt.Sprintf("%s: %v", c.name, err))
+		}
+	}
+	h.Ready = h.Live && len(h.Problems) == 0
+	return h
+}
+
+// Adding report (b) of one of several brokers named (name) to combined report, see Multi and Shard
+func (h *HealthReport) add(name string, b HealthReport) {
+	h.Live = h.Live && b.Live
+	h.Ready = h.Ready && b.Ready
+	for _, problem := range b.Problems {
+		h.Problems = append(h.Problems, name+": "+problem)
+	}
+	for check, err := range b.Checks {
+		if h.Checks == nil {
+			h.Checks = map[string]string{}
+		}
+		h.Checks[name+": "+check] = err
+	}
+	h.Memory += b.Memory
+	h.MaxMemory += b.MaxMemory
+	h.Topics += b.Topics
+	h.Subscriptions += b.Subscriptions
+	h.Pending += b.Pending
+	h.InFlight += b.InFlight
+	h.Slow += b.Slow
+	h.Goroutines = b.Goroutines
+}
+
+// Health of broker, namespace shares memory and checks with other namespaces
+func (n *namespace) Health() HealthReport {
+	return n.p.Health()
+}
+
+// Health of broker if principal may inspect broker, empty report otherwise
+func (a *authorized) Health() HealthReport {
+	if a.allow(OpInspect, "", "") != nil {
+		return HealthReport{}
+	}
+	return a.next.Health()
+}
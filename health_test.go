@@ -0,0 +1,41 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPubSub_Health(t *testing.T) {
+	lib := New(WithMaxMemory(10, RejectPublish), WithHealthCheck("disk", func() error {
+		return errors.New("disk is full")
+	}))
+	lib.SubscribeWithOptions("orders", "billing", Options{MaxMessages: 1})
+	lib.Publish("orders", []byte("12345"))
+	lib.Publish("orders", []byte("12345"))
+	h := lib.Health()
+	if !h.Live || h.Ready || h.Topics != 1 || h.Subscriptions != 1 || h.Pending != 1 || h.Memory != 5 ||
+		h.MaxMemory != 10 || h.Slow != 1 || h.Goroutines == 0 {
+		t.Fatal(h)
+	}
+	if len(h.Problems) != 1 || h.Problems[0] != "disk: disk is full" || h.Checks["disk"] != "disk is full" {
+		t.Fatal(h.Problems, h.Checks)
+	}
+	lib.Subscribe("events", "audit")
+	lib.Publish("events", []byte("12345"))
+	if h := lib.Health(); len(h.Problems) != 2 || h.Problems[0] != ErrMemoryLimit.Error() {
+		t.Fatal(h.Problems)
+	}
+}
+
+func TestPubSub_Health_Ready(t *testing.T) {
+	lib := New(WithAuthorizer(testAuthorizer()))
+	if h := lib.Health(); !h.Live || !h.Ready || h.Problems != nil {
+		t.Fatal(h)
+	}
+	if h := lib.As("alice").Health(); h.Live {
+		t.Fatal(h)
+	}
+	if h := lib.As("admin").Health(); !h.Ready {
+		t.Fatal(h)
+	}
+}
@@ -0,0 +1,165 @@
+package pubsub
+
+import (
+	"sort"
+	"time"
+)
+
+// Kinds of SeekPosition
+type seekKind int
+
+const (
+	seekEnd seekKind = iota
+	seekBeginning
+	seekSequence
+	seekTime
+)
+
+// SeekPosition points to a message in topic history
+type SeekPosition struct {
+	kind seekKind
+	seq  uint64
+	at   time.Time
+}
+
+var (
+	// Beginning points to the oldest message kept in topic history
+	Beginning = SeekPosition{kind: seekBeginning}
+	// End points after the last published message, so only new messages are delivered
+	End = SeekPosition{kind: seekEnd}
+)
+
+// AtSequence points to the message with sequence number (seq) or the next one kept in topic history
+func AtSequence(seq uint64) SeekPosition {
+	return SeekPosition{kind: seekSequence, seq: seq}
+}
+
+// AtTime points to the first message kept in topic history published at time (t) or later
+func AtTime(t time.Time) SeekPosition {
+	return SeekPosition{kind: seekTime, at: t}
+}
+
+// Keeping last (n) messages published to topic name (tn) in history for SubscribeFrom
+// Creates new topic if not exist before (ignored for unknown topics in strict mode), non-positive n disables history
+// Wildcard topics have no history, because nothing is published to them directly
+func (p *pubSub) SetHistory(tn string, n int) {
+	if p.closed.Load() {
+		return
+	}
+	subs, err := p.lockSubscribable(tn)
+	if err != nil {
+		return
+	}
+	defer subs.mux.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	subs.historySize = n
+	subs.trimHistory()
+}
+
+// Subscribe to message by topic name (tn) and subscriber name (sn) starting from position (from) of topic history
+// Messages of history starting from the position are added to the subscription immediately
+// Creates new topic if not exist before (ErrTopicNotFound raises in strict mode instead)
+// ErrSubscriptionExists raises if subscription was created before
+func (p *pubSub) SubscribeFrom(tn, sn string, from SeekPosition) error {
+	if p.closed.Load() {
+		return ErrClosed
+	}
+	subs, err := p.lockSubscribable(tn)
+	if err != nil {
+		return err
+	}
+	defer subs.mux.Unlock()
+	if _, ok := subs.hm[sn]; ok {
+		return ErrSubscriptionExists
+	}
+	if err := subs.admit(sn); err != nil {
+		return err
+	}
+	sub, _ := subs.ensure(sn)
+	if from.kind == seekEnd {
+		p.deliverRetained(tn, sub)
+		return nil
+	}
+	for _, m := range subs.historyFrom(from) {
+		sub.push(m)
+	}
+	sub.cond.Broadcast()
+	return nil
+}
+
+// Adding message (m) to history, the oldest messages are removed if history is full
+// s.mux held for writing or lockPublish must be held by caller
+func (s *subscriptions) record(m *message) {
+	if s.historySize > 0 {
+		m.pin()
+		s.history = append(s.history, m)
+		s.trimHistory()
+	}
+}
+
+// Removing the oldest messages exceeding history size
+// Messages are moved to the beginning of the slice, so the backing array doesn't grow infinitely
+func (s *subscriptions) trimHistory() {
+	if extra := len(s.history) - s.historySize; extra > 0 {
+		n := copy(s.history, s.history[extra:])
+		for i := n; i < len(s.history); i++ {
+			s.history[i] = nil
+		}
+		s.history = s.history[:n]
+	}
+}
+
+// Messages of history starting from position (from)
+// s.mux must be held by caller
+func (s *subscriptions) historyFrom(from SeekPosition) []*message {
+	for i, m := range s.history {
+		switch {
+		case from.kind == seekBeginning,
+			from.kind == seekSequence && m.Seq >= from.seq,
+			from.kind == seekTime && !m.PublishedAt.Before(from.at):
+			return s.history[i:]
+		}
+	}
+	return nil
+}
+
+// Moving subscription of topic name (tn) and subscriber name (sn) to sequence number (seq)
+// Pending messages with lower sequence numbers are skipped, messages of topic history starting from seq are added
+// again (rewind), so the next polled message is the first available one with sequence number seq or greater.
+// Messages of history rejected by Options.Filter of the subscription are not added. Messages in flight are not
+// affected. Sequence numbers are comparable only within one topic, so Seek of wildcard subscriptions compares
+// numbers of different topics
+// error raises if no subscriptions
+func (p *pubSub) Seek(tn, sn string, seq uint64) error {
+	subs, sub, err := p.acquire(tn, sn)
+	if err != nil {
+		return err
+	}
+	defer subs.mux.Unlock()
+	var items []item
+	seen := map[*message]bool{}
+	for _, it := range sub.items() {
+		if it.Seq >= seq {
+			items = append(items, it)
+			seen[it.message] = true
+		}
+	}
+	for _, m := range subs.historyFrom(AtSequence(seq)) {
+		if !seen[m] && sub.accepts(m) {
+			m.retain()
+			items = append(items, item{message: m})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Seq < items[j].Seq
+	})
+	st := sub.emptyStorage()
+	for _, it := range items {
+		st.pushBack(it)
+	}
+	sub.replace(st)
+	sub.cond.Broadcast()
+	return nil
+}
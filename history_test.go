@@ -0,0 +1,139 @@
+package pubsub
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPubSub_SubscribeFrom(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	lib.SetHistory(tn, 3)
+	for _, m := range []string{"1", "2", "3"} {
+		lib.Publish(tn, []byte(m))
+	}
+	middle := time.Now()
+	time.Sleep(time.Millisecond)
+	for _, m := range []string{"4", "5"} {
+		lib.Publish(tn, []byte(m))
+	}
+	for sn, c := range map[string]struct {
+		from     SeekPosition
+		expected string
+	}{
+		"beginning":   {Beginning, "345"},
+		"end":         {End, ""},
+		"sequence 4":  {AtSequence(4), "45"},
+		"sequence 1":  {AtSequence(1), "345"},
+		"sequence 10": {AtSequence(10), ""},
+		"time":        {AtTime(middle), "45"},
+	} {
+		if err := lib.SubscribeFrom(tn, sn, c.from); err != nil {
+			t.Fatal(sn, err)
+		}
+		msgs, _ := lib.PollN(tn, sn, 10)
+		got := ""
+		for _, msg := range msgs {
+			got += string(msg)
+		}
+		if got != c.expected {
+			t.Errorf("%s: got %q, expected %q", sn, got, c.expected)
+		}
+	}
+	if err := lib.SubscribeFrom(tn, "beginning", Beginning); err != ErrSubscriptionExists {
+		t.FailNow()
+	}
+	lib.Publish(tn, []byte("6"))
+	if msg, _ := lib.Poll(tn, "beginning"); string(msg) != "6" {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Snapshot_History(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	lib.SetHistory(tn, 2)
+	for _, m := range []string{"1", "2", "3"} {
+		lib.Publish(tn, []byte(m))
+	}
+	var buf bytes.Buffer
+	_ = lib.Snapshot(&buf)
+	restored := New()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	_ = restored.SubscribeFrom(tn, "sub", AtSequence(3))
+	restored.Publish(tn, []byte("4"))
+	msgs, _ := restored.PollN(tn, "sub", 10)
+	if len(msgs) != 2 || string(msgs[0]) != "3" || string(msgs[1]) != "4" {
+		t.Fatal(msgs)
+	}
+}
+
+func TestPubSub_SetHistory_Shrink(t *testing.T) {
+	p := New().(*pubSub)
+	tn := "some topic"
+	p.SetHistory(tn, 5)
+	for _, m := range []string{"1", "2", "3", "4"} {
+		p.Publish(tn, []byte(m))
+	}
+	p.SetHistory(tn, 2)
+	subs, _ := p.topics.get(tn)
+	if len(subs.history) != 2 || string(subs.history[0].Body) != "3" || subs.lastSeq != 4 || subs.history[1].Seq != 4 {
+		t.FailNow()
+	}
+	p.SetHistory(tn, 0)
+	if len(subs.history) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Seek(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SetHistory(tn, 10)
+	lib.Subscribe(tn, sn)
+	for _, m := range []string{"1", "2", "3", "4", "5"} {
+		lib.Publish(tn, []byte(m))
+	}
+	if msg, _ := lib.PollMsg(tn, sn); msg.Seq != 1 {
+		t.FailNow()
+	}
+	// skip
+	if err := lib.Seek(tn, sn, 4); err != nil {
+		t.FailNow()
+	}
+	if msg, _ := lib.PollMsg(tn, sn); msg.Seq != 4 || string(msg.Body) != "4" {
+		t.Fatal(msg)
+	}
+	// rewind
+	_ = lib.Seek(tn, sn, 2)
+	msgs, _ := lib.PollN(tn, sn, 10)
+	if len(msgs) != 4 || string(msgs[0]) != "2" || string(msgs[3]) != "5" {
+		t.Fatal(msgs)
+	}
+	if err := lib.Seek(tn, "subscriber not exist id", 1); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Seek_Filter(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SetHistory(tn, 10)
+	lib.SubscribeWithOptions(tn, sn, Options{Filter: HeaderEquals("k", "yes")})
+	for _, v := range []string{"yes", "no", "yes"} {
+		lib.PublishMsg(tn, Message{Headers: map[string]string{"k": v}, Body: []byte(v)})
+	}
+	if err := lib.Seek(tn, sn, 0); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _ := lib.PollN(tn, sn, 10)
+	if len(msgs) != 2 || string(msgs[0]) != "yes" || string(msgs[1]) != "yes" {
+		t.Fatal(len(msgs))
+	}
+}
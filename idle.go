@@ -0,0 +1,49 @@
+package pubsub
+
+import "time"
+
+// Checks if subscription with Options.IdleTimeout wasn't polled since now - IdleTimeout
+// Lock of subscription must be held by caller
+func (s *subscription) idle(now time.Time) bool {
+	return s.opts.IdleTimeout > 0 && s.waiters == 0 && now.Sub(s.lastPoll) >= s.opts.IdleTimeout
+}
+
+// Unsubscribing subscriptions which are idle at the moment now, Options.OnIdle is called for each of them
+// Candidates are found under read lock and checked again under write lock, because they could be polled in between
+func (p *pubSub) expireIdle(now time.Time) {
+	type candidate struct {
+		subs *subscriptions
+		sub  *subscription
+	}
+	var found []candidate
+	for _, subs := range p.topics.all() {
+		subs.mux.Lock()
+		for _, sub := range subs.hm {
+			if sub.idle(now) {
+				found = append(found, candidate{subs, sub})
+			}
+		}
+		subs.mux.Unlock()
+	}
+	var expired []candidate
+	for _, c := range found {
+		p.mux.Lock()
+		c.subs.mux.Lock()
+		if c.subs.hm[c.sub.sn] == c.sub && c.sub.idle(now) {
+			c.sub.log.Info("idle subscription expired", "idle", now.Sub(c.sub.lastPoll))
+			p.dropSubscription(c.subs, c.sub, false)
+			if c.subs.empty() && !c.subs.deleted.Load() {
+				p.removeTopic(c.subs)
+			}
+			expired = append(expired, c)
+		}
+		c.subs.mux.Unlock()
+		p.mux.Unlock()
+	}
+	// callbacks are called without locks, so they may use the PubSuber
+	for _, c := range expired {
+		if c.sub.opts.OnIdle != nil {
+			c.sub.opts.OnIdle(c.subs.tn, c.sub.sn)
+		}
+	}
+}
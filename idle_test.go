@@ -0,0 +1,61 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPubSub_expireIdle(t *testing.T) {
+	p := New().(*pubSub)
+	tn := "some topic"
+	sn := "subscriber/id"
+	var expired []string
+	p.SubscribeWithOptions(tn, sn, Options{IdleTimeout: time.Minute, OnIdle: func(tn, sn string) {
+		expired = append(expired, tn+":"+sn)
+	}})
+	p.Subscribe(tn, "forever")
+	p.expireIdle(time.Now().Add(30 * time.Second))
+	if _, err := p.Poll(tn, sn); err != nil || len(expired) != 0 {
+		t.FailNow()
+	}
+	p.expireIdle(time.Now().Add(2 * time.Minute))
+	if _, err := p.Poll(tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	if len(expired) != 1 || expired[0] != tn+":"+sn {
+		t.FailNow()
+	}
+	if _, err := p.Poll(tn, "forever"); err != nil {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_IdleTimeout(t *testing.T) {
+	p := New().(*pubSub)
+	p.sweepEvery = 5 * time.Millisecond
+	tn := "some topic"
+	sn := "subscriber/id"
+	done := make(chan string, 1)
+	p.SubscribeWithOptions(tn, sn, Options{IdleTimeout: 10 * time.Millisecond, OnIdle: func(tn, sn string) {
+		done <- sn
+	}})
+	// waiting poller keeps subscription alive
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.PollWait(ctx, tn, sn); err != context.DeadlineExceeded {
+		t.FailNow()
+	}
+	select {
+	case got := <-done:
+		if got != sn {
+			t.FailNow()
+		}
+	case <-time.After(time.Second):
+		t.FailNow()
+	}
+	if len(p.Topics()) != 0 {
+		t.FailNow()
+	}
+}
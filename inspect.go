@@ -0,0 +1,98 @@
+package pubsub
+
+import (
+	"sort"
+)
+
+// List of topic names sorted in ascending order
+func (p *pubSub) Topics() []string {
+	topics := p.topics.all()
+	tns := make([]string, 0, len(topics))
+	for _, subs := range topics {
+		tns = append(tns, subs.tn)
+	}
+	sort.Strings(tns)
+	return tns
+}
+
+// List of subscription names of topic name (tn) sorted in ascending order
+// error raises if topic doesn't exist
+func (p *pubSub) Subscriptions(tn string) ([]string, error) {
+	if p.closed.Load() {
+		return nil, ErrClosed
+	}
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		return nil, ErrTopicNotFound
+	}
+	subs.mux.Lock()
+	sns := make([]string, 0, len(subs.hm))
+	for sn := range subs.hm {
+		sns = append(sns, sn)
+	}
+	subs.mux.Unlock()
+	sort.Strings(sns)
+	return sns, nil
+}
+
+// Number of pending messages for topic name (tn) and subscriber name (sn), messages in flight are not counted
+// error raises if no subscriptions
+// Complexity: O(n) because expired messages are removed first
+func (p *pubSub) Depth(tn, sn string) (int, error) {
+	subs, sub, err := p.acquire(tn, sn)
+	if err != nil {
+		return 0, err
+	}
+	defer subs.mux.Unlock()
+	sub.dropExpired(p.now())
+	return sub.len(), nil
+}
+
+// Number of messages polled with PollAck for topic name (tn) and subscriber name (sn) and not acknowledged yet
+// error raises if no subscriptions
+func (p *pubSub) InFlight(tn, sn string) (int, error) {
+	subs, sub, err := p.acquire(tn, sn)
+	if err != nil {
+		return 0, err
+	}
+	defer subs.mux.Unlock()
+	return len(sub.inFlight), nil
+}
+
+// Fetching the next message for topic name (tn) and subscriber name (sn) without removing it from the subscription
+// error raises if no subscriptions
+// nil, nil should be returned if there are no pending messages
+// Complexity: O(n) because expired messages are removed first
+func (p *pubSub) Peek(tn, sn string) ([]byte, error) {
+	msgs, err := p.PeekN(tn, sn, 1)
+	if len(msgs) == 0 {
+		return nil, err
+	}
+	return msgs[0], nil
+}
+
+// Fetching up to max next messages for topic name (tn) and subscriber name (sn) in delivery order
+// without removing them from the subscription. Returned slices are shared with pollers and must not be modified
+// error raises if no subscriptions
+// nil, nil should be returned if there are no pending messages or max is not positive
+// Complexity: O(n) because expired messages are removed first
+func (p *pubSub) PeekN(tn, sn string, max int) ([][]byte, error) {
+	subs, sub, err := p.acquire(tn, sn)
+	if err != nil {
+		return nil, err
+	}
+	defer subs.mux.Unlock()
+	if max <= 0 {
+		return nil, nil
+	}
+	sub.dropExpired(p.now())
+	var msgs [][]byte
+	for _, it := range sub.peek(max) {
+		b, err := it.body()
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, b)
+	}
+	return msgs, nil
+}
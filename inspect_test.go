@@ -0,0 +1,112 @@
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPubSub_Topics(t *testing.T) {
+	lib := New()
+	if tns := lib.Topics(); len(tns) != 0 {
+		t.FailNow()
+	}
+	lib.Subscribe("topic b", "subscriber/id")
+	lib.Subscribe("topic a", "subscriber/id")
+	if tns := lib.Topics(); !reflect.DeepEqual(tns, []string{"topic a", "topic b"}) {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Subscriptions(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	if _, err := lib.Subscriptions(tn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, "subscriber 2")
+	lib.Subscribe(tn, "subscriber 1")
+	if sns, err := lib.Subscriptions(tn); err != nil || !reflect.DeepEqual(sns, []string{"subscriber 1", "subscriber 2"}) {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Depth(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if _, err := lib.Depth(tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	lib.Publish(tn, []byte("a"))
+	lib.Publish(tn, []byte("b"))
+	_ = lib.PublishWithTTL(tn, []byte("c"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if n, err := lib.Depth(tn, sn); err != nil || n != 2 {
+		t.FailNow()
+	}
+	_, _ = lib.Poll(tn, sn)
+	if n, _ := lib.Depth(tn, sn); n != 1 {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_InFlight(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if _, err := lib.InFlight(tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	lib.Publish(tn, []byte("a"))
+	lib.Publish(tn, []byte("b"))
+	_, token, _ := lib.PollAck(tn, sn, time.Hour)
+	_, _ = lib.Poll(tn, sn)
+	if n, err := lib.InFlight(tn, sn); err != nil || n != 1 {
+		t.FailNow()
+	}
+	_ = lib.Ack(tn, sn, token)
+	if n, _ := lib.InFlight(tn, sn); n != 0 {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Peek(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if _, err := lib.Peek(tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	lib.SubscribeWithOptions(tn, "priority", Options{Priority: true})
+	if b, err := lib.Peek(tn, sn); err != nil || b != nil {
+		t.FailNow()
+	}
+	_ = lib.PublishWithTTL(tn, []byte("expired"), time.Nanosecond)
+	lib.Publish(tn, []byte("a"))
+	_ = lib.PublishWithPriority(tn, []byte("b"), 1)
+	time.Sleep(time.Millisecond)
+	if b, err := lib.Peek(tn, sn); err != nil || string(b) != "a" {
+		t.FailNow()
+	}
+	if msgs, _ := lib.PeekN(tn, sn, 10); !reflect.DeepEqual(msgs, [][]byte{[]byte("a"), []byte("b")}) {
+		t.FailNow()
+	}
+	if msgs, _ := lib.PeekN(tn, "priority", 1); !reflect.DeepEqual(msgs, [][]byte{[]byte("b")}) {
+		t.FailNow()
+	}
+	if msgs, err := lib.PeekN(tn, sn, 0); err != nil || msgs != nil {
+		t.FailNow()
+	}
+	// messages are not consumed
+	if depth, _ := lib.Depth(tn, sn); depth != 2 {
+		t.FailNow()
+	}
+	if b, _ := lib.Poll(tn, sn); string(b) != "a" {
+		t.FailNow()
+	}
+}
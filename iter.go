@@ -0,0 +1,45 @@
+package pubsub
+
+import (
+	"context"
+	"iter"
+)
+
+// Iterating over messages of subscription (sn) of topic name (tn) with PollWait:
+//
+//	for msg, err := range ps.Iter(ctx, "orders", "billing") {
+//
+// Loop ends without error when ctx is done or the body breaks it, other errors of PollWait (e.g.
+// ErrSubscriptionNotFound, ErrClosed) are yielded once with nil message and end the loop
+func (p *pubSub) Iter(ctx context.Context, tn, sn string) iter.Seq2[[]byte, error] {
+	return iterate(ctx, p, tn, sn)
+}
+
+// Iterator over messages of subscription (sn) of topic name (tn) polled from broker (ps), see Iter
+func iterate(ctx context.Context, ps PubSuber, tn, sn string) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for {
+			msg, err := ps.PollWait(ctx, tn, sn)
+			if ctx.Err() != nil && msg == nil {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(msg, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Iterating over messages of subscription (sn) of topic name (tn) of the namespace
+func (n *namespace) Iter(ctx context.Context, tn, sn string) iter.Seq2[[]byte, error] {
+	return iterate(ctx, n, tn, sn)
+}
+
+// Iterating over messages of subscription, every poll is checked by Authorizer
+func (a *authorized) Iter(ctx context.Context, tn, sn string) iter.Seq2[[]byte, error] {
+	return iterate(ctx, a, tn, sn)
+}
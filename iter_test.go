@@ -0,0 +1,59 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIter(t *testing.T) {
+	tn, sn := "orders", "billing"
+	lib := New()
+	lib.Subscribe(tn, sn)
+	for _, b := range []string{"1", "2", "3"} {
+		lib.Publish(tn, []byte(b))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+	for msg, err := range lib.Iter(ctx, tn, sn) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(msg))
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Fatal(got)
+	}
+	// cancellation ends the loop without error
+	time.AfterFunc(20*time.Millisecond, cancel)
+	got = got[:0]
+	for msg, err := range lib.Iter(ctx, tn, sn) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(msg))
+	}
+	if len(got) != 1 || got[0] != "3" {
+		t.Fatal(got)
+	}
+}
+
+func TestIter_Unsubscribe(t *testing.T) {
+	tn, sn := "orders", "billing"
+	lib := New()
+	lib.Subscribe(tn, sn)
+	time.AfterFunc(20*time.Millisecond, func() { lib.Unsubscribe(tn, sn) })
+	n := 0
+	for msg, err := range lib.Iter(context.Background(), tn, sn) {
+		if msg != nil || err != ErrSubscriptionNotFound {
+			t.Fatal(msg, err)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Fatal(n)
+	}
+}
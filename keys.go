@@ -0,0 +1,44 @@
+package pubsub
+
+// Publish message (b) with ordering key (key) by topic name (tn)
+// Messages with the same key are delivered by PollAck one at a time in order of publish: the next one is held back
+// while the previous one is in flight, messages with other keys are polled meanwhile. So a consumer group polling
+// the subscription in parallel processes different keys concurrently, but every key in order.
+// Empty key means no ordering. Overflow policies are applied like in Publish
+func (p *pubSub) PublishWithKey(tn string, key string, b []byte) {
+	_, _ = p.publish(tn, p.newMessage(tn, Message{Key: key, Body: b}))
+}
+
+// Checks if message with key of item (it) may be delivered now, items without key always may
+func (s *subscription) ready(it item) bool {
+	return it.Key == "" || !s.busyKeys[it.Key]
+}
+
+// Holding back messages with key of item (it) until release is called
+func (s *subscription) hold(it item) {
+	if it.Key == "" {
+		return
+	}
+	if s.busyKeys == nil {
+		s.busyKeys = map[string]bool{}
+	}
+	s.busyKeys[it.Key] = true
+}
+
+// Allowing delivery of messages with key of item (it), pollers waiting for messages are woken up
+func (s *subscription) release(it item) {
+	if it.Key == "" || !s.busyKeys[it.Key] {
+		return
+	}
+	delete(s.busyKeys, it.Key)
+	if s.len() > 0 {
+		s.cond.Broadcast()
+	}
+}
+
+// Putting items (skipped) by next back to the beginning of the queue in their order
+func (s *subscription) unskip(skipped []item) {
+	for i := len(skipped) - 1; i >= 0; i-- {
+		s.pushFront(skipped[i])
+	}
+}
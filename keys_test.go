@@ -0,0 +1,101 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPubSub_PublishWithKey(t *testing.T) {
+	lib := New()
+	tn, sn := "orders", "billing"
+	lib.Subscribe(tn, sn)
+	lib.PublishWithKey(tn, "a", []byte("a1"))
+	lib.PublishWithKey(tn, "a", []byte("a2"))
+	lib.PublishWithKey(tn, "b", []byte("b1"))
+	lib.Publish(tn, []byte("x"))
+	msg, _ := lib.PollMsg(tn, sn)
+	if msg == nil || msg.Key != "a" {
+		t.Fatal(msg)
+	}
+	// a1 was polled without ack, so nothing is held back
+	b, token, _ := lib.PollAck(tn, sn, time.Minute)
+	if string(b) != "a2" {
+		t.Fatal(string(b))
+	}
+	// a2 is in flight, next message of key "a" would wait, other keys are delivered
+	lib.PublishWithKey(tn, "a", []byte("a3"))
+	for _, want := range []string{"b1", "x", ""} {
+		if b, _, _ := lib.PollAck(tn, sn, time.Minute); string(b) != want {
+			t.Fatal(string(b), want)
+		}
+	}
+	if depth, _ := lib.Depth(tn, sn); depth != 1 {
+		t.Fatal(depth)
+	}
+	if err := lib.Ack(tn, sn, token); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := lib.Poll(tn, sn); string(b) != "a3" {
+		t.Fatal(string(b))
+	}
+}
+
+func TestPubSub_PublishWithKey_Redelivery(t *testing.T) {
+	lib := New(WithClock(&stepClock{now: time.Unix(0, 0)}))
+	tn, sn := "orders", "billing"
+	lib.Subscribe(tn, sn)
+	lib.PublishWithKey(tn, "a", []byte("a1"))
+	lib.PublishWithKey(tn, "a", []byte("a2"))
+	lib.PublishWithKey(tn, "b", []byte("b1"))
+	_, token, _ := lib.PollAck(tn, sn, time.Minute)
+	if b, _, _ := lib.PollAck(tn, sn, time.Minute); string(b) != "b1" {
+		t.Fatal(string(b))
+	}
+	// returned to the end of the queue, but it goes first as the key must stay in order
+	if err := lib.Nack(tn, sn, token, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"a1", "a2"} {
+		if b, _ := lib.Poll(tn, sn); string(b) != want {
+			t.Fatal(string(b), want)
+		}
+	}
+	// visibility timeout releases the key
+	lib.PublishWithKey(tn, "a", []byte("a3"))
+	lib.PollAck(tn, sn, time.Millisecond)
+	if err := lib.Advance(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := lib.Poll(tn, sn); string(b) != "a3" {
+		t.Fatal(string(b))
+	}
+}
+
+func TestPubSub_PublishWithKey_Wait(t *testing.T) {
+	lib := New()
+	tn, sn := "orders", "billing"
+	lib.Subscribe(tn, sn)
+	lib.PublishWithKey(tn, "a", []byte("a1"))
+	lib.PublishWithKey(tn, "a", []byte("a2"))
+	_, token, _ := lib.PollAck(tn, sn, time.Minute)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lib.Ack(tn, sn, token)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if b, err := lib.PollWait(ctx, tn, sn); err != nil || string(b) != "a2" {
+		t.Fatal(string(b), err)
+	}
+}
+
+func TestNamespace_PublishWithKey(t *testing.T) {
+	lib := New()
+	ns := lib.Namespace("tenant")
+	ns.Subscribe("orders", "billing")
+	ns.PublishWithKey("orders", "a", []byte("a1"))
+	if msg, _ := lib.PollMsg("$ns/tenant/orders", "billing"); msg == nil || msg.Key != "a" {
+		t.Fatal(msg)
+	}
+}
@@ -0,0 +1,41 @@
+package pubsub
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger used if WithLogger isn't set, its handler is disabled for all levels,
+// so arguments of log calls aren't even formatted
+var nopLogger = slog.New(nopHandler{})
+
+// slog.Handler discarding all records
+type nopHandler struct{}
+
+func (nopHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (nopHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h nopHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h nopHandler) WithGroup(string) slog.Handler           { return h }
+
+// Logging broker events with logger (l): subscribe, unsubscribe and expiration of idle subscriptions (info),
+// dropped messages and removed topics (debug), messages moved to dead-letter topics and slow subscribers (warn)
+// Events of a subscription have "topic" and "subscription" attributes. Nil logger disables logging
+func WithLogger(l *slog.Logger) Option {
+	return func(p *pubSub) {
+		if l == nil {
+			l = nopLogger
+		}
+		p.log = l
+	}
+}
+
+// Warning that subscription reached Options.MaxMessages, it's logged once until subscriber polls
+// half of pending messages
+// Lock of subscription must be held by caller
+func (s *subscription) warnSlow() {
+	if !s.slow {
+		s.slow = true
+		s.log.Warn("subscription is full, subscriber is too slow", "pending", s.len(), "max", s.limit())
+		s.emit(EventSlowSubscriber, nil, 0, "full")
+	}
+}
@@ -0,0 +1,51 @@
+package pubsub
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	lib := New(WithLogger(log), WithClock(&stepClock{now: time.Unix(0, 0)}))
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxMessages: 1, Overflow: DropNewest, MaxDeliveries: 1})
+	lib.Publish(tn, []byte("first"))
+	lib.Publish(tn, []byte("second"))
+	lib.Publish(tn, []byte("third"))
+	if _, _, err := lib.PollAck(tn, sn, time.Millisecond); err != nil {
+		t.FailNow()
+	}
+	if err := lib.Advance(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	lib.Unsubscribe(tn, sn)
+	out := buf.String()
+	for _, event := range []string{
+		`level=INFO msg=subscribed topic="some topic" subscription=subscriber/id`,
+		`level=WARN msg="subscription is full, subscriber is too slow" topic="some topic" subscription=subscriber/id`,
+		`level=DEBUG msg="message dropped"`,
+		`level=WARN msg="message moved to dead-letter topic"`,
+		`level=INFO msg=unsubscribed topic="some topic" subscription=subscriber/id`,
+		`level=DEBUG msg="topic removed" topic="some topic"`,
+	} {
+		if !strings.Contains(out, event) {
+			t.Errorf("event %q is not logged", event)
+		}
+	}
+	// warning is logged once
+	if strings.Count(out, "subscriber is too slow") != 1 {
+		t.FailNow()
+	}
+}
+
+func TestWithLogger_Nil(t *testing.T) {
+	lib := New(WithLogger(nil))
+	lib.Subscribe("some topic", "subscriber/id")
+	lib.Unsubscribe("some topic", "subscriber/id")
+}
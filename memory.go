@@ -0,0 +1,89 @@
+package pubsub
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Error happens if message is published when broker holds WithMaxMemory bytes already and policy rejects new messages
+var ErrMemoryLimit = errors.New("memory limit is reached")
+
+// Limiting total size of bodies of pending messages of all subscriptions to (max) bytes, zero means unlimited
+// Message delivered to several subscriptions is counted for each of them, messages in flight aren't counted
+// When the limit is exceeded policy is applied:
+// DropOldest - the oldest pending messages across all topics are removed (Options.Priority subscriptions remove
+// the oldest one among messages with the lowest priority) until memory fits the limit
+// DropNewest, RejectPublish - new message isn't delivered to any subscription, TryPublish returns ErrMemoryLimit
+// The limit is approximate, concurrent publishers to different topics may exceed it slightly
+func WithMaxMemory(max int64, policy Overflow) Option {
+	return func(p *pubSub) {
+		if max < 0 {
+			max = 0
+		}
+		p.maxMemory = max
+		p.memoryPolicy = policy
+	}
+}
+
+// Checks if message (m) delivered to its subscriptions of topics (targets) fits memory limit
+// Lists of targets must be locked by caller
+func (p *pubSub) fits(targets []*subscriptions, m *message) bool {
+	need := atomic.LoadInt64(&p.memory)
+	for _, subs := range targets {
+		for _, sub := range subs.hm {
+			if sub.accepts(m) {
+				need += int64(len(m.Body))
+			}
+		}
+	}
+	if need <= p.maxMemory {
+		return true
+	}
+	p.log.Warn("memory limit is reached", "topic", m.Topic, "max", p.maxMemory)
+	return false
+}
+
+// Removing the oldest pending messages across all topics until memory fits the limit
+// Every round scans all subscriptions for the oldest message, then the subscription holding it loses messages
+// until they aren't older than messages of others anymore
+// Complexity: O(N) per round, where N is number of subscriptions
+func (p *pubSub) reclaim() {
+	for atomic.LoadInt64(&p.memory) > p.maxMemory {
+		topics := p.topics.all()
+		var victim *subscription
+		var oldest, next time.Time
+		for _, subs := range topics {
+			subs.mux.Lock()
+			for _, sub := range subs.hm {
+				it, ok := sub.evictable()
+				switch {
+				case !ok:
+				case victim == nil || it.PublishedAt.Before(oldest):
+					if victim != nil {
+						next = oldest
+					}
+					victim, oldest = sub, it.PublishedAt
+				case next.IsZero() || it.PublishedAt.Before(next):
+					next = it.PublishedAt
+				}
+			}
+			subs.mux.Unlock()
+		}
+		if victim == nil {
+			return
+		}
+		victim.topic.mux.Lock()
+		if victim.topic.hm[victim.sn] == victim {
+			victim.drop("memory")
+			for atomic.LoadInt64(&p.memory) > p.maxMemory {
+				it, ok := victim.evictable()
+				if !ok || !next.IsZero() && it.PublishedAt.After(next) {
+					break
+				}
+				victim.drop("memory")
+			}
+		}
+		victim.topic.mux.Unlock()
+	}
+}
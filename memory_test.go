@@ -0,0 +1,59 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func TestWithMaxMemory(t *testing.T) {
+	lib := New(WithMaxMemory(10, DropOldest))
+	sn := "subscriber/id"
+	lib.Subscribe("a", sn)
+	lib.Subscribe("b", sn)
+	lib.Publish("a", []byte("a1234"))
+	lib.Publish("b", []byte("b1234"))
+	lib.Publish("a", []byte("a5678"))
+	// the oldest message across topics is removed
+	if msgs, _ := lib.PeekN("a", sn, 10); len(msgs) != 1 || string(msgs[0]) != "a5678" {
+		t.FailNow()
+	}
+	if b, _ := lib.Poll("b", sn); string(b) != "b1234" {
+		t.FailNow()
+	}
+	// polled messages free memory
+	lib.Publish("b", []byte("b5678"))
+	if depth, _ := lib.Depth("a", sn); depth != 1 {
+		t.FailNow()
+	}
+	if stats := lib.Stats(); stats.Topics[0].Subscriptions[0].Dropped != 1 {
+		t.FailNow()
+	}
+	// removed subscriptions free memory
+	lib.Unsubscribe("a", sn)
+	lib.Publish("b", []byte("b9012"))
+	if depth, _ := lib.Depth("b", sn); depth != 2 {
+		t.FailNow()
+	}
+}
+
+func TestWithMaxMemory_Reject(t *testing.T) {
+	lib := New(WithMaxMemory(10, RejectPublish))
+	tn := "some topic"
+	lib.Subscribe(tn, "first")
+	lib.Subscribe(tn, "second")
+	if err := lib.TryPublish(tn, []byte("12345")); err != nil {
+		t.FailNow()
+	}
+	// the message is counted for every subscription
+	if err := lib.TryPublish(tn, []byte("1")); err != ErrMemoryLimit {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth(tn, "second"); depth != 1 {
+		t.FailNow()
+	}
+	if _, err := lib.PurgeTopic(tn); err != nil {
+		t.FailNow()
+	}
+	if err := lib.TryPublish(tn, []byte("12345")); err != nil {
+		t.FailNow()
+	}
+}
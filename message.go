@@ -0,0 +1,185 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Message is an envelope of published payload
+// ID - unique message ID, generated on publish if empty
+// Topic - name of topic message was published to (may differ from subscription topic for wildcard subscriptions)
+// Headers - arbitrary metadata like content type or correlation ID, must not be modified after publish
+// (unless WithCopyOnPublish is used)
+// PublishedAt - time of publishing, set by broker
+// Key - ordering key set by PublishWithKey, empty if messages are delivered in plain FIFO order
+// Seq - sequence number within topic, increases by one with every message published to the topic, set by broker.
+// It's 0 for messages published to topics which didn't exist (had neither subscriptions nor history), such messages
+// can be delivered only to wildcard subscriptions
+// DeliveryAttempt - number of deliveries of the message to the subscription by PollAck including this one (1 for the
+// first delivery), so consumers can give up on messages failing again and again; 0 for messages polled without ack
+// Body - payload, must not be modified after publish (unless WithCopyOnPublish is used). Polled messages share
+// body with all subscriptions, so it must not be modified by pollers too
+type Message struct {
+	ID              string
+	Topic           string
+	Key             string
+	Headers         map[string]string
+	PublishedAt     time.Time
+	Seq             uint64
+	DeliveryAttempt int
+	Body            []byte
+}
+
+// Random prefix of message IDs, so IDs of different brokers don't collide
+func newIDPrefix() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Wrappers of published messages released by publisher and all subscriptions, reused by newMessage
+var messagePool = sync.Pool{
+	New: func() interface{} {
+		return new(message)
+	},
+}
+
+// Building internal message for topic name (tn) from msg, ID is generated if it's empty
+// Body and headers are copied if broker is created with WithCopyOnPublish
+// Message is taken from messagePool with one reference of caller, publishers which don't use the message after
+// publishing release it
+func (p *pubSub) newMessage(tn string, msg Message) *message {
+	if p.copyOnPublish {
+		msg.Body = append([]byte(nil), msg.Body...)
+		if msg.Headers != nil {
+			headers := make(map[string]string, len(msg.Headers))
+			for k, v := range msg.Headers {
+				headers[k] = v
+			}
+			msg.Headers = headers
+		}
+	}
+	msg.Topic = tn
+	msg.PublishedAt = p.now()
+	msg.Seq = 0
+	m := messagePool.Get().(*message)
+	*m = message{Message: msg, ownID: msg.ID != "", refs: 1}
+	if !m.ownID {
+		m.num, m.idPrefix = atomic.AddUint64(&p.lastID, 1), p.idPrefix
+	}
+	return m
+}
+
+// ID of message, ID generated by broker is formatted on every call, so it costs nothing until it's read
+func (m *message) id() string {
+	if m.num == 0 {
+		return m.ID
+	}
+	// formatting on stack, so ID costs one allocation
+	var buf [64]byte
+	id := append(append(buf[:0], m.idPrefix...), '-')
+	return string(strconv.AppendUint(id, m.num, 10))
+}
+
+// Formatting ID of message (m) once before message is shared, so its readers (middlewares, validators, filters) see
+// Message.ID; caller must be the only holder of the message
+func (m *message) formatID() {
+	if m.num != 0 {
+		m.ID, m.num = m.id(), 0
+	}
+}
+
+// ID of message for logs, formatted only if record is written
+func (m *message) LogValue() slog.Value {
+	return slog.StringValue(m.id())
+}
+
+// Taking a reference to message (m) which is released by release later
+func (m *message) retain() {
+	atomic.AddInt32(&m.refs, 1)
+}
+
+// Taking a reference to message (m) which is never released, e.g. by history, so message is never reused
+func (m *message) pin() {
+	atomic.AddInt32(&m.refs, 1)
+}
+
+// Releasing reference to message (m), the last one returns message to messagePool, so m must not be used after that
+func (m *message) release() {
+	if atomic.AddInt32(&m.refs, -1) == 0 {
+		*m = message{}
+		messagePool.Put(m)
+	}
+}
+
+// Copying body and headers of published messages, so caller may reuse or modify them after publish
+// By default broker keeps the caller's slice and map, which must not be modified after publish
+func WithCopyOnPublish(enabled bool) Option {
+	return func(p *pubSub) {
+		p.copyOnPublish = enabled
+	}
+}
+
+// Publish message (msg) with headers by topic name (tn), returns message ID
+// Overflow policies are applied like in TryPublish
+// ID of msg is kept if set, then the message is dropped without error if it's a duplicate (see TopicConfig.DedupWindow)
+func (p *pubSub) PublishMsg(tn string, msg Message) (string, error) {
+	m := p.newMessage(tn, msg)
+	_, err := p.publish(tn, m)
+	id := m.id()
+	m.release()
+	return id, err
+}
+
+// Fetching message with headers and metadata for topic name (tn) and subscriber name (sn)
+// error raises if no subscriptions
+// nil, nil should be returned if all messages was fetched already (ErrEmpty if WithEmptyError is used)
+func (p *pubSub) PollMsg(tn, sn string) (*Message, error) {
+	msg, err := p.interceptPoll(context.Background(), tn, sn, p.fetch)
+	if msg == nil {
+		return nil, p.empty(err)
+	}
+	return msg, err
+}
+
+// Fetching message without poll middlewares, PollFunc in the end of poll chain of Poll and PollMsg
+func (p *pubSub) fetch(_ context.Context, tn, sn string) (*Message, error) {
+	sub, err := p.acquireActive(tn, sn)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unlock()
+	if it, ok := sub.next(p.now()); ok {
+		return sub.consume(it)
+	}
+	return nil, nil
+}
+
+// Copy of message of item (it) with decoded (body) returned to caller of PollAck with its delivery attempt, nil if
+// there is no message
+func (it item) delivery(body []byte) *Message {
+	if it.message == nil {
+		return nil
+	}
+	msg := it.Message
+	msg.ID = it.id()
+	msg.Body = body
+	msg.DeliveryAttempt = it.attempts
+	return &msg
+}
+
+// Body of message (msg) returned by a PollFunc with error (err), nil if there is no message
+func bodyOf(msg *Message, err error) ([]byte, error) {
+	if msg == nil {
+		return nil, err
+	}
+	return msg.Body, err
+}
@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPubSub_PublishMsg_PollMsg(t *testing.T) {
+	lib := New()
+	sn := "subscriber/id"
+	lib.Subscribe("orders/+", sn)
+	if msg, err := lib.PollMsg("orders/+", sn); err != nil || msg != nil {
+		t.FailNow()
+	}
+	start := time.Now()
+	id, err := lib.PublishMsg("orders/1", Message{
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    []byte("{}"),
+	})
+	if err != nil || id == "" {
+		t.FailNow()
+	}
+	customID, _ := lib.PublishMsg("orders/2", Message{ID: "custom id"})
+	lib.Publish("orders/3", []byte("raw"))
+
+	msg, err := lib.PollMsg("orders/+", sn)
+	if err != nil || msg.ID != id || msg.Topic != "orders/1" || msg.Headers["Content-Type"] != "application/json" ||
+		string(msg.Body) != "{}" || msg.PublishedAt.Before(start) {
+		t.Fatal(msg, err)
+	}
+	if msg, _ := lib.PollMsg("orders/+", sn); msg.ID != "custom id" || customID != "custom id" {
+		t.FailNow()
+	}
+	if msg, _ := lib.PollMsg("orders/+", sn); msg.ID == "" || msg.ID == id || string(msg.Body) != "raw" {
+		t.FailNow()
+	}
+	if _, err := lib.PollMsg("orders/+", "subscriber not exist id"); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+}
+
+func TestWithCopyOnPublish(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		lib := New(WithCopyOnPublish(enabled))
+		tn := "some topic"
+		sn := "subscriber/id"
+		lib.Subscribe(tn, sn)
+		b := []byte("message")
+		headers := map[string]string{"key": "value"}
+		_, _ = lib.PublishMsg(tn, Message{Body: b, Headers: headers})
+		lib.PublishAfter(tn, b, 0)
+		b[0] = 'M'
+		headers["key"] = "changed"
+		msg, _ := lib.PollMsg(tn, sn)
+		time.Sleep(10 * time.Millisecond)
+		delayed, _ := lib.Poll(tn, sn)
+		copied := string(msg.Body) == "message" && msg.Headers["key"] == "value" && string(delayed) == "message"
+		if copied != enabled {
+			t.FailNow()
+		}
+	}
+}
+
+func TestPubSub_MessagePool(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	lib.SetHistory(tn, 200)
+	lib.Subscribe(tn, "a")
+	lib.Subscribe(tn, "b")
+	lib.Publish(tn, []byte("1"))
+	// message released by publisher and one subscription stays with the other one and history
+	first, _ := lib.PollMsg(tn, "a")
+	for i := 2; i <= 100; i++ {
+		lib.Publish(tn, []byte(strconv.Itoa(i)))
+		if b, _ := lib.Poll(tn, "a"); string(b) != strconv.Itoa(i) {
+			t.Fatal(i, string(b))
+		}
+	}
+	if msg, _ := lib.PollMsg(tn, "b"); msg.ID != first.ID || string(msg.Body) != "1" || msg.Seq != 1 {
+		t.Fatal(msg)
+	}
+	ids := map[string]bool{first.ID: true}
+	for i := 2; i <= 100; i++ {
+		msg, _ := lib.PollMsg(tn, "b")
+		if string(msg.Body) != strconv.Itoa(i) || ids[msg.ID] {
+			t.Fatal(i, msg)
+		}
+		ids[msg.ID] = true
+	}
+	if err := lib.Seek(tn, "a", 95); err != nil {
+		t.Fatal(err)
+	}
+	lib.Publish(tn, []byte("101"))
+	if msgs, _ := lib.PollN(tn, "a", 10); len(msgs) != 7 || string(msgs[0]) != "95" || string(msgs[6]) != "101" {
+		t.Fatal(msgs)
+	}
+	// messages of history aren't reused
+	for i := 0; i < 10; i++ {
+		lib.Publish(tn, []byte("new"))
+		_, _ = lib.Poll(tn, "a")
+		_, _ = lib.Poll(tn, "b")
+	}
+	_ = lib.Seek(tn, "a", 95)
+	if b, _ := lib.Poll(tn, "a"); string(b) != "95" {
+		t.Fatal(string(b))
+	}
+}
@@ -0,0 +1,150 @@
+package pubsub
+
+import "context"
+
+// PublishFunc publishes message (msg) to topic name (tn)
+// Middleware may modify msg (body, headers) or replace topic name before calling the next PublishFunc
+type PublishFunc func(ctx context.Context, tn string, msg *Message) error
+
+// PollFunc fetches message for topic name (tn) and subscriber name (sn), nil message means there are no messages
+type PollFunc func(ctx context.Context, tn, sn string) (*Message, error)
+
+// Middleware wraps publish and poll methods, e.g. for auth, validation, metrics or payload transformation
+// Publish wraps all publish methods (Publish, TryPublish, PublishMsg, PublishWithTTL, etc).
+// Delayed messages (PublishAfter, PublishAt) are passed through it when their time comes.
+// Poll wraps Poll, PollN, PollMsg, PollWait and PollAck (and so SubscribeChan and SubscribeFunc)
+// Messages are already taken from the queue when the poll chain returns, a message dropped by middleware is lost
+// (unless it was fetched by PollAck). Context is context.Background() for methods without context
+// Any of the functions may be nil
+type Middleware struct {
+	Publish func(next PublishFunc) PublishFunc
+	Poll    func(next PollFunc) PollFunc
+}
+
+// Middlewares added by Use, the first one is the outermost
+type chain struct {
+	publish []func(PublishFunc) PublishFunc
+	poll    []func(PollFunc) PollFunc
+}
+
+// Adding middleware (mw), middlewares are called in order they were added
+// Broker without middlewares doesn't pay for them, Poll and PollN without middlewares don't allocate messages
+func (p *pubSub) Use(mw Middleware) {
+	p.chainMux.Lock()
+	defer p.chainMux.Unlock()
+	c := &chain{}
+	if old := p.chain.Load(); old != nil {
+		c.publish = append(c.publish, old.publish...)
+		c.poll = append(c.poll, old.poll...)
+	}
+	if mw.Publish != nil {
+		c.publish = append(c.publish, mw.Publish)
+	}
+	if mw.Poll != nil {
+		c.poll = append(c.poll, mw.Poll)
+	}
+	p.chain.Store(c)
+}
+
+// Passing message (m) through publish middlewares and delivering it to subscriptions, returns number of
+// subscriptions which received it, ErrNoSubscriptions if none did and WithNoSubscribersError is used
+func (p *pubSub) publish(tn string, m *message) (int, error) {
+	return p.publishCtx(context.Background(), tn, m)
+}
+
+// Publishing message (m) like publish and releasing reference of caller, so the message is reused once
+// subscriptions release it too
+func (p *pubSub) publishReleased(tn string, m *message) (int, error) {
+	n, err := p.publish(tn, m)
+	m.release()
+	return n, err
+}
+
+// Publishing message (m) like publish, middlewares get (ctx) of caller (see NewCtx)
+func (p *pubSub) publishCtx(ctx context.Context, tn string, m *message) (int, error) {
+	if p.labels {
+		var n int
+		var err error
+		labeled(ctx, tn, func(ctx context.Context) {
+			n, err = p.publishMsg(ctx, tn, m)
+		})
+		return n, err
+	}
+	return p.publishMsg(ctx, tn, m)
+}
+
+// Publishing message (m) like publish, but without profiler labels, middlewares get (ctx)
+func (p *pubSub) publishMsg(ctx context.Context, tn string, m *message) (int, error) {
+	if p.closing.Load() {
+		return 0, ErrClosed
+	}
+	var n int
+	var err error
+	if c := p.chain.Load(); c == nil || len(c.publish) == 0 {
+		n, err = p.deliver(tn, m)
+	} else {
+		n, err = p.interceptPublish(ctx, c, tn, m)
+	}
+	if err == errDuplicate {
+		return 0, nil
+	}
+	if err == nil {
+		p.forward(m.Topic, m)
+	}
+	if err == nil && n == 0 && p.noSubsError {
+		err = ErrNoSubscriptions
+	}
+	return n, err
+}
+
+// Passing message (m) through publish middlewares of chain (c) and delivering it, returns number of subscriptions
+// which received it
+// Kept apart from publishMsg, so counter captured by the chain doesn't escape to heap on publishing without middlewares
+func (p *pubSub) interceptPublish(ctx context.Context, c *chain, tn string, m *message) (int, error) {
+	// middlewares see ID of message and may keep it, so it isn't reused
+	m.formatID()
+	m.pin()
+	var n int
+	next := func(_ context.Context, tn string, msg *Message) error {
+		if msg != &m.Message {
+			m.Message = *msg
+		}
+		m.Topic = tn
+		var err error
+		n, err = p.deliver(tn, m)
+		return err
+	}
+	for i := len(c.publish) - 1; i >= 0; i-- {
+		next = c.publish[i](next)
+	}
+	err := next(ctx, tn, &m.Message)
+	return n, err
+}
+
+// Checks if there are poll middlewares or polls are labeled for profiler (see WithProfilerLabels)
+func (p *pubSub) interceptsPoll() bool {
+	if p.labels {
+		return true
+	}
+	c := p.chain.Load()
+	return c != nil && len(c.poll) > 0
+}
+
+// Fetching message with (fetch) wrapped by poll middlewares
+func (p *pubSub) interceptPoll(ctx context.Context, tn, sn string, fetch PollFunc) (*Message, error) {
+	next := fetch
+	if c := p.chain.Load(); c != nil {
+		for i := len(c.poll) - 1; i >= 0; i-- {
+			next = c.poll[i](next)
+		}
+	}
+	if !p.labels {
+		return next(ctx, tn, sn)
+	}
+	var msg *Message
+	var err error
+	labeled(ctx, tn, func(ctx context.Context) {
+		msg, err = next(ctx, tn, sn)
+	})
+	return msg, err
+}
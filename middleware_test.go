@@ -0,0 +1,98 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPubSub_Use(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	errDenied := errors.New("denied")
+	var order []string
+	lib.Use(Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(ctx context.Context, tn string, msg *Message) error {
+				order = append(order, "first")
+				if string(msg.Body) == "secret" {
+					return errDenied
+				}
+				return next(ctx, tn, msg)
+			}
+		},
+	})
+	lib.Use(Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(ctx context.Context, tn string, msg *Message) error {
+				order = append(order, "second")
+				msg.Body = []byte(strings.ToUpper(string(msg.Body)))
+				return next(ctx, tn, msg)
+			}
+		},
+		Poll: func(next PollFunc) PollFunc {
+			return func(ctx context.Context, tn, sn string) (*Message, error) {
+				msg, err := next(ctx, tn, sn)
+				if msg != nil {
+					msg.Body = append(msg.Body, '!')
+				}
+				return msg, err
+			}
+		},
+	})
+	if err := lib.TryPublish(tn, []byte("secret")); err != errDenied {
+		t.FailNow()
+	}
+	lib.Publish(tn, []byte("a"))
+	if strings.Join(order, ",") != "first,first,second" {
+		t.FailNow()
+	}
+	_, _ = lib.PublishMsg(tn, Message{Body: []byte("b")})
+	_ = lib.PublishWithTTL(tn, []byte("c"), time.Hour)
+	_ = lib.PublishWithPriority(tn, []byte("d"), 1)
+	_ = lib.PublishRetained(tn, []byte("e"))
+	if b, err := lib.Poll(tn, sn); err != nil || string(b) != "A!" {
+		t.FailNow()
+	}
+	if msg, err := lib.PollMsg(tn, sn); err != nil || string(msg.Body) != "B!" {
+		t.FailNow()
+	}
+	if b, err := lib.PollWait(context.Background(), tn, sn); err != nil || string(b) != "C!" {
+		t.FailNow()
+	}
+	if b, token, err := lib.PollAck(tn, sn, time.Minute); err != nil || string(b) != "D!" || lib.Ack(tn, sn, token) != nil {
+		t.FailNow()
+	}
+	if msgs, err := lib.PollN(tn, sn, 10); err != nil || len(msgs) != 1 || string(msgs[0]) != "E!" {
+		t.FailNow()
+	}
+	if b, err := lib.Poll(tn, sn); err != nil || b != nil {
+		t.FailNow()
+	}
+	if _, err := lib.Poll(tn, "unknown"); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Use_Topic(t *testing.T) {
+	lib := New()
+	lib.Subscribe("new", "sn")
+	lib.Use(Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(ctx context.Context, tn string, msg *Message) error {
+				return next(ctx, "new", &Message{ID: "id", Body: msg.Body})
+			}
+		},
+	})
+	if id, err := lib.PublishMsg("old", Message{Body: []byte("message")}); err != nil || id != "id" {
+		t.FailNow()
+	}
+	msg, _ := lib.PollMsg("new", "sn")
+	if msg == nil || msg.Topic != "new" || msg.ID != "id" || string(msg.Body) != "message" || msg.Seq != 1 {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,854 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Subscription of multi
+type multiKey struct {
+	tn, sn string
+}
+
+// Message taken from broker (i) of multi
+type multiPolled struct {
+	i   int
+	msg *Message
+}
+
+// AckToken of broker (i) issued for subscription (tn, sn)
+type multiToken struct {
+	i      int
+	tn, sn string
+	token  AckToken
+}
+
+// Copies of message taken from broker (owner) which are left in other brokers
+type multiSeen struct {
+	owner, left int
+}
+
+// Composite of several brokers, see Multi
+// stash - messages taken by PollMsgWait from brokers which lost the race, they're returned by next polls
+// seen - IDs of messages taken from brokers, their copies polled from other brokers are dropped
+// tokens - AckTokens of multi mapped to tokens of brokers, cancels - stop SubscribeChan and SubscribeFunc goroutines
+type multi struct {
+	brokers   []PubSuber
+	idPrefix  string
+	lastID    uint64
+	lastToken AckToken
+	mux       sync.Mutex
+	stash     map[multiKey][]multiPolled
+	seen      map[multiKey]map[string]multiSeen
+	tokens    map[AckToken]multiToken
+	cancels   map[multiKey]context.CancelFunc
+}
+
+// Constructor. Creates PubSuber publishing to all brokers (brokers) and polling them all, e.g. in-memory broker
+// together with a persistent or remote one
+// Messages are published to brokers in order, errors of brokers are joined, so a message may be published to some
+// of them only. PublishMsg, Publish and TryPublish give all copies the same ID. Subscriptions and topics are created
+// in every broker, a message published through Multi is polled once: copies with the same ID taken from other brokers
+// are dropped (and acknowledged). Polls take messages from brokers in order, PollWait methods wait for all brokers
+// at once
+// Depth is a sum of depths, Peek and PeekN look through brokers in order; Stats, Snapshot and Restore use
+// the first broker. Transactions are atomic within every broker, but not across them
+// At least one broker must be passed
+func Multi(brokers ...PubSuber) PubSuber {
+	return &multi{
+		brokers:  brokers,
+		idPrefix: newIDPrefix(),
+		stash:    map[multiKey][]multiPolled{},
+		seen:     map[multiKey]map[string]multiSeen{},
+		tokens:   map[AckToken]multiToken{},
+		cancels:  map[multiKey]context.CancelFunc{},
+	}
+}
+
+// Calling (fn) for every broker, errors are joined
+func (m *multi) each(fn func(b PubSuber) error) error {
+	var errs []error
+	for _, b := range m.brokers {
+		if err := fn(b); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Calling (fn) for every broker and summing returned numbers, errors are joined
+func (m *multi) sum(fn func(b PubSuber) (int, error)) (int, error) {
+	total := 0
+	err := m.each(func(b PubSuber) error {
+		n, err := fn(b)
+		total += n
+		return err
+	})
+	return total, err
+}
+
+func (m *multi) newID() string {
+	return m.idPrefix + "-" + strconv.FormatUint(atomic.AddUint64(&m.lastID, 1), 10)
+}
+
+func (m *multi) Publish(tn string, b []byte) {
+	_, _ = m.PublishMsg(tn, Message{Body: b})
+}
+
+func (m *multi) TryPublish(tn string, b []byte) error {
+	_, err := m.PublishMsg(tn, Message{Body: b})
+	return err
+}
+
+// Returns sum of numbers of subscriptions received message in every broker
+func (m *multi) PublishResult(tn string, b []byte) (int, error) {
+	return m.sum(func(ps PubSuber) (int, error) { return ps.PublishResult(tn, b) })
+}
+
+// Adding messages of (fn) to transaction of every broker, (fn) is called once
+func (m *multi) PublishTx(fn func(tx Tx) error) error {
+	t := &multiTx{m: m}
+	defer func() { t.done = true }()
+	if err := fn(t); err != nil {
+		return err
+	}
+	t.done = true
+	if len(t.items) == 0 {
+		return nil
+	}
+	return m.each(func(ps PubSuber) error {
+		return ps.PublishTx(func(tx Tx) error {
+			for _, it := range t.items {
+				if _, err := tx.PublishMsg(it.tn, it.msg); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Message (msg) gets ID if it's empty, so its copies have the same ID in all brokers
+func (m *multi) PublishMsg(tn string, msg Message) (string, error) {
+	if msg.ID == "" {
+		msg.ID = m.newID()
+	}
+	return msg.ID, m.each(func(ps PubSuber) error {
+		_, err := ps.PublishMsg(tn, msg)
+		return err
+	})
+}
+
+func (m *multi) PublishAfter(tn string, b []byte, delay time.Duration) {
+	for _, ps := range m.brokers {
+		ps.PublishAfter(tn, b, delay)
+	}
+}
+
+func (m *multi) PublishAt(tn string, b []byte, at time.Time) {
+	for _, ps := range m.brokers {
+		ps.PublishAt(tn, b, at)
+	}
+}
+
+func (m *multi) PublishWithPriority(tn string, b []byte, prio int) error {
+	return m.each(func(ps PubSuber) error { return ps.PublishWithPriority(tn, b, prio) })
+}
+
+func (m *multi) PublishRetained(tn string, b []byte) error {
+	return m.each(func(ps PubSuber) error { return ps.PublishRetained(tn, b) })
+}
+
+func (m *multi) PublishWithTTL(tn string, b []byte, ttl time.Duration) error {
+	return m.each(func(ps PubSuber) error { return ps.PublishWithTTL(tn, b, ttl) })
+}
+
+func (m *multi) PublishWithKey(tn string, key string, b []byte) {
+	_, _ = m.PublishMsg(tn, Message{Key: key, Body: b})
+}
+
+func (m *multi) Subscribe(tn, sn string) {
+	for _, ps := range m.brokers {
+		ps.Subscribe(tn, sn)
+	}
+}
+
+func (m *multi) SubscribeFrom(tn, sn string, from SeekPosition) error {
+	return m.each(func(ps PubSuber) error { return ps.SubscribeFrom(tn, sn, from) })
+}
+
+func (m *multi) Seek(tn, sn string, seq uint64) error {
+	return m.each(func(ps PubSuber) error { return ps.Seek(tn, sn, seq) })
+}
+
+func (m *multi) SetHistory(tn string, n int) {
+	for _, ps := range m.brokers {
+		ps.SetHistory(tn, n)
+	}
+}
+
+func (m *multi) SubscribeWithOptions(tn, sn string, opts Options) {
+	for _, ps := range m.brokers {
+		ps.SubscribeWithOptions(tn, sn, opts)
+	}
+}
+
+// Messages of all brokers are pushed into returned channel, see SubscribeChan of broker
+func (m *multi) SubscribeChan(tn, sn string, buf int) (<-chan []byte, error) {
+	if buf < 0 {
+		return nil, ErrInvalidBuffer
+	}
+	ctx, err := m.subscribeCancelable(tn, sn)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []byte, buf)
+	go func() {
+		defer close(ch)
+		for {
+			msg, err := m.PollWait(ctx, tn, sn)
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Messages of all brokers are passed to handler (fn), see SubscribeFunc of broker
+func (m *multi) SubscribeFunc(tn, sn string, fn func([]byte), opts ...HandlerOption) error {
+	cfg := handlerConfig{workers: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	ctx, err := m.subscribeCancelable(tn, sn)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < cfg.workers; i++ {
+		go func() {
+			for {
+				msg, err := m.PollWait(ctx, tn, sn)
+				if err != nil {
+					return
+				}
+				cfg.handle(fn, msg)
+			}
+		}()
+	}
+	return nil
+}
+
+// Creates subscription in all brokers which returned context is cancelled by Unsubscribe or Close
+// ErrSubscriptionExists raises if some broker has the subscription already
+func (m *multi) subscribeCancelable(tn, sn string) (context.Context, error) {
+	for _, ps := range m.brokers {
+		if sns, _ := ps.Subscriptions(tn); slices.Contains(sns, sn) {
+			return nil, ErrSubscriptionExists
+		}
+	}
+	m.Subscribe(tn, sn)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mux.Lock()
+	m.cancels[multiKey{tn, sn}] = cancel
+	m.mux.Unlock()
+	return ctx, nil
+}
+
+func (m *multi) Unsubscribe(tn, sn string) {
+	key := multiKey{tn, sn}
+	m.mux.Lock()
+	if cancel, ok := m.cancels[key]; ok {
+		cancel()
+		delete(m.cancels, key)
+	}
+	delete(m.stash, key)
+	delete(m.seen, key)
+	m.mux.Unlock()
+	for _, ps := range m.brokers {
+		ps.Unsubscribe(tn, sn)
+	}
+}
+
+func (m *multi) PurgeSubscription(tn, sn string) (int, error) {
+	m.mux.Lock()
+	n := len(m.stash[multiKey{tn, sn}])
+	delete(m.stash, multiKey{tn, sn})
+	m.mux.Unlock()
+	purged, err := m.sum(func(ps PubSuber) (int, error) { return ps.PurgeSubscription(tn, sn) })
+	return n + purged, err
+}
+
+func (m *multi) PurgeTopic(tn string) (int, error) {
+	n := 0
+	m.mux.Lock()
+	for key, polled := range m.stash {
+		if key.tn == tn {
+			n += len(polled)
+			delete(m.stash, key)
+		}
+	}
+	m.mux.Unlock()
+	purged, err := m.sum(func(ps PubSuber) (int, error) { return ps.PurgeTopic(tn) })
+	return n + purged, err
+}
+
+func (m *multi) CreateTopic(tn string, cfg TopicConfig) error {
+	return m.each(func(ps PubSuber) error { return ps.CreateTopic(tn, cfg) })
+}
+
+// Settings of topic of the first broker having it, error is returned only if all brokers failed
+func (m *multi) TopicConfig(tn string) (TopicConfig, error) {
+	var errs []error
+	for _, ps := range m.brokers {
+		cfg, err := ps.TopicConfig(tn)
+		if err == nil {
+			return cfg, nil
+		}
+		errs = append(errs, err)
+	}
+	return TopicConfig{}, errors.Join(errs...)
+}
+
+// Handle of topic publishing to all brokers
+func (m *multi) Topic(tn string) *Topic {
+	return NewTopic(m, tn)
+}
+
+func (m *multi) ConfigureTopic(tn string, cfg TopicConfig) error {
+	return m.each(func(ps PubSuber) error { return ps.ConfigureTopic(tn, cfg) })
+}
+
+func (m *multi) DeleteTopic(tn string) {
+	m.mux.Lock()
+	for key, cancel := range m.cancels {
+		if key.tn == tn {
+			cancel()
+			delete(m.cancels, key)
+		}
+	}
+	for key := range m.stash {
+		if key.tn == tn {
+			delete(m.stash, key)
+		}
+	}
+	for key := range m.seen {
+		if key.tn == tn {
+			delete(m.seen, key)
+		}
+	}
+	m.mux.Unlock()
+	for _, ps := range m.brokers {
+		ps.DeleteTopic(tn)
+	}
+}
+
+func (m *multi) Poll(tn, sn string) ([]byte, error) {
+	msg, err := m.PollMsg(tn, sn)
+	if msg == nil {
+		return nil, err
+	}
+	return msg.Body, err
+}
+
+// Taking message from brokers in order, error is returned only if all brokers failed
+// ErrEmpty of brokers created with WithEmptyError isn't a failure, it's returned if there are no messages
+func (m *multi) PollMsg(tn, sn string) (*Message, error) {
+	if p, ok := m.unstash(tn, sn); ok {
+		return p.msg, nil
+	}
+	var errs []error
+	var empty error
+	for i, ps := range m.brokers {
+		msg, err := ps.PollMsg(tn, sn)
+		for msg != nil && m.duplicate(i, tn, sn, msg.ID) {
+			msg, err = ps.PollMsg(tn, sn)
+		}
+		switch {
+		case msg != nil:
+			return msg, nil
+		case errors.Is(err, ErrEmpty):
+			empty = err
+		case err != nil:
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(m.brokers) {
+		return nil, errors.Join(errs...)
+	}
+	return nil, empty
+}
+
+// Reports whether message (id) taken from broker (i) is a copy of message taken from another broker, copies of
+// message published through Multi have the same ID in all brokers. Message redelivered by the broker it was taken
+// from isn't a copy
+func (m *multi) duplicate(i int, tn, sn, id string) bool {
+	if id == "" || len(m.brokers) < 2 {
+		return false
+	}
+	key := multiKey{tn, sn}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	ids := m.seen[key]
+	s, ok := ids[id]
+	switch {
+	case !ok:
+		if ids == nil {
+			ids = map[string]multiSeen{}
+			m.seen[key] = ids
+		}
+		ids[id] = multiSeen{owner: i, left: len(m.brokers) - 1}
+		return false
+	case s.owner == i:
+		return false
+	case s.left <= 1:
+		delete(ids, id)
+	default:
+		s.left--
+		ids[id] = s
+	}
+	return true
+}
+
+// Taking message stashed by PollMsgWait
+func (m *multi) unstash(tn, sn string) (multiPolled, bool) {
+	key := multiKey{tn, sn}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	polled := m.stash[key]
+	if len(polled) == 0 {
+		return multiPolled{}, false
+	}
+	if len(polled) == 1 {
+		delete(m.stash, key)
+	} else {
+		m.stash[key] = polled[1:]
+	}
+	return polled[0], true
+}
+
+func (m *multi) PollN(tn, sn string, max int) ([][]byte, error) {
+	var msgs [][]byte
+	for len(msgs) < max {
+		msg, err := m.PollMsg(tn, sn)
+		if len(msgs) > 0 && errors.Is(err, ErrEmpty) {
+			break
+		}
+		if err != nil {
+			return msgs, err
+		}
+		if msg == nil {
+			break
+		}
+		msgs = append(msgs, msg.Body)
+	}
+	return msgs, nil
+}
+
+// Taking messages from brokers in order while they fit into (maxBytes), message which doesn't fit is stashed
+func (m *multi) PollBytes(tn, sn string, maxBytes int) ([][]byte, error) {
+	var msgs [][]byte
+	for size := 0; size < maxBytes; {
+		msg, err := m.PollMsg(tn, sn)
+		if len(msgs) > 0 && errors.Is(err, ErrEmpty) {
+			break
+		}
+		if err != nil {
+			return msgs, err
+		}
+		if msg == nil {
+			break
+		}
+		if len(msgs) > 0 && size+len(msg.Body) > maxBytes {
+			key := multiKey{tn, sn}
+			m.mux.Lock()
+			m.stash[key] = append([]multiPolled{{msg: msg}}, m.stash[key]...)
+			m.mux.Unlock()
+			break
+		}
+		msgs = append(msgs, msg.Body)
+		size += len(msg.Body)
+	}
+	return msgs, nil
+}
+
+// Iterating over messages of subscription taken from brokers in order, see Iter
+func (m *multi) Iter(ctx context.Context, tn, sn string) iter.Seq2[[]byte, error] {
+	return iterate(ctx, m, tn, sn)
+}
+
+func (m *multi) PollWait(ctx context.Context, tn, sn string) ([]byte, error) {
+	msg, err := m.PollMsgWait(ctx, tn, sn)
+	if msg == nil {
+		return nil, err
+	}
+	return msg.Body, err
+}
+
+// Taking message from brokers in order, waiting for all brokers at once if there are no messages
+func (m *multi) PollMsgWait(ctx context.Context, tn, sn string) (*Message, error) {
+	for {
+		if msg, err := m.PollMsg(tn, sn); msg != nil || (err != nil && !errors.Is(err, ErrEmpty)) {
+			return msg, err
+		}
+		if err := m.wait(ctx, tn, sn); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Waiting until some broker has message for subscription (tn, sn), messages taken while waiters are stopped
+// are stashed. Error is returned if ctx is done or all brokers failed
+func (m *multi) wait(ctx context.Context, tn, sn string) error {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan multiPolled, len(m.brokers))
+	errs := make([]error, len(m.brokers))
+	for i, ps := range m.brokers {
+		go func(i int, ps PubSuber) {
+			msg, err := ps.PollMsgWait(wctx, tn, sn)
+			errs[i] = err
+			results <- multiPolled{i: i, msg: msg}
+		}(i, ps)
+	}
+	failed := 0
+	for range m.brokers {
+		p := <-results
+		switch {
+		case p.msg != nil && m.duplicate(p.i, tn, sn, p.msg.ID):
+			cancel()
+		case p.msg != nil:
+			m.mux.Lock()
+			m.stash[multiKey{tn, sn}] = append(m.stash[multiKey{tn, sn}], p)
+			m.mux.Unlock()
+			cancel()
+		case wctx.Err() == nil:
+			failed++
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if failed == len(m.brokers) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func (m *multi) PollAck(tn, sn string, visibility time.Duration) ([]byte, AckToken, error) {
+	msg, token, err := m.PollAckMsg(tn, sn, visibility)
+	if msg == nil {
+		return nil, 0, err
+	}
+	return msg.Body, token, err
+}
+
+// Taking message from brokers in order, returned token is mapped to token of broker
+// Messages stashed by PollMsgWait are taken already, so they aren't returned
+func (m *multi) PollAckMsg(tn, sn string, visibility time.Duration) (*Message, AckToken, error) {
+	var errs []error
+	var empty error
+	for i, ps := range m.brokers {
+		msg, token, err := ps.PollAckMsg(tn, sn, visibility)
+		for msg != nil && m.duplicate(i, tn, sn, msg.ID) {
+			_ = ps.Ack(tn, sn, token)
+			msg, token, err = ps.PollAckMsg(tn, sn, visibility)
+		}
+		switch {
+		case msg != nil:
+			m.mux.Lock()
+			defer m.mux.Unlock()
+			m.lastToken++
+			m.tokens[m.lastToken] = multiToken{i: i, tn: tn, sn: sn, token: token}
+			return msg, m.lastToken, nil
+		case errors.Is(err, ErrMaxInFlight):
+			empty = err
+		case errors.Is(err, ErrEmpty):
+			if empty == nil {
+				empty = err
+			}
+		case err != nil:
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(m.brokers) {
+		return nil, 0, errors.Join(errs...)
+	}
+	return nil, 0, empty
+}
+
+// Returns token of broker (token) of subscription (tn, sn), ErrUnknownAckToken if there is no such token
+func (m *multi) token(tn, sn string, token AckToken) (multiToken, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	t, ok := m.tokens[token]
+	if !ok || t.tn != tn || t.sn != sn {
+		return multiToken{}, ErrUnknownAckToken
+	}
+	delete(m.tokens, token)
+	return t, nil
+}
+
+func (m *multi) Ack(tn, sn string, token AckToken) error {
+	t, err := m.token(tn, sn, token)
+	if err != nil {
+		return err
+	}
+	return m.brokers[t.i].Ack(tn, sn, t.token)
+}
+
+func (m *multi) Nack(tn, sn string, token AckToken, requeue bool) error {
+	t, err := m.token(tn, sn, token)
+	if err != nil {
+		return err
+	}
+	return m.brokers[t.i].Nack(tn, sn, t.token, requeue)
+}
+
+// Extending visibility timeout in broker of token, token stays valid for Ack and Nack
+func (m *multi) ExtendAck(tn, sn string, token AckToken, visibility time.Duration) error {
+	m.mux.Lock()
+	t, ok := m.tokens[token]
+	m.mux.Unlock()
+	if !ok || t.tn != tn || t.sn != sn {
+		return ErrUnknownAckToken
+	}
+	return m.brokers[t.i].ExtendAck(tn, sn, t.token, visibility)
+}
+
+// Taking dead letter from brokers in order
+func (m *multi) PollDLQ(tn, sn string) (*Message, error) {
+	var empty error
+	for _, ps := range m.brokers {
+		msg, err := ps.PollDLQ(tn, sn)
+		if errors.Is(err, ErrEmpty) {
+			empty = err
+			continue
+		}
+		if msg != nil || err != nil {
+			return msg, err
+		}
+	}
+	return nil, empty
+}
+
+func (m *multi) Redrive(tn, sn string) (int, error) {
+	return m.sum(func(ps PubSuber) (int, error) { return ps.Redrive(tn, sn) })
+}
+
+// Names of topics of all brokers, sorted
+func (m *multi) Topics() []string {
+	var topics []string
+	for _, ps := range m.brokers {
+		topics = append(topics, ps.Topics()...)
+	}
+	slices.Sort(topics)
+	return slices.Compact(topics)
+}
+
+// Names of subscriptions of topic in all brokers, sorted, error is returned only if all brokers failed
+func (m *multi) Subscriptions(tn string) ([]string, error) {
+	var sns []string
+	var errs []error
+	for _, ps := range m.brokers {
+		names, err := ps.Subscriptions(tn)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		sns = append(sns, names...)
+	}
+	if len(errs) == len(m.brokers) {
+		return nil, errors.Join(errs...)
+	}
+	slices.Sort(sns)
+	return slices.Compact(sns), nil
+}
+
+// Sum of depths of subscription in all brokers and messages stashed by PollMsgWait
+func (m *multi) Depth(tn, sn string) (int, error) {
+	m.mux.Lock()
+	n := len(m.stash[multiKey{tn, sn}])
+	m.mux.Unlock()
+	depth, err := m.sum(func(ps PubSuber) (int, error) { return ps.Depth(tn, sn) })
+	return n + depth, err
+}
+
+// Sum of messages in flight of subscription in all brokers
+func (m *multi) InFlight(tn, sn string) (int, error) {
+	return m.sum(func(ps PubSuber) (int, error) { return ps.InFlight(tn, sn) })
+}
+
+func (m *multi) Peek(tn, sn string) ([]byte, error) {
+	msgs, err := m.PeekN(tn, sn, 1)
+	if len(msgs) == 0 {
+		return nil, err
+	}
+	return msgs[0], err
+}
+
+// Messages stashed by PollMsgWait and then messages of brokers in order
+func (m *multi) PeekN(tn, sn string, max int) ([][]byte, error) {
+	var msgs [][]byte
+	m.mux.Lock()
+	for _, p := range m.stash[multiKey{tn, sn}] {
+		if len(msgs) < max {
+			msgs = append(msgs, p.msg.Body)
+		}
+	}
+	m.mux.Unlock()
+	for _, ps := range m.brokers {
+		if len(msgs) >= max {
+			break
+		}
+		peeked, err := ps.PeekN(tn, sn, max-len(msgs))
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, peeked...)
+	}
+	return msgs, nil
+}
+
+func (m *multi) Stats() BrokerStats {
+	return m.brokers[0].Stats()
+}
+
+// Health of all brokers: live and ready if all of them are, counters are summed, problems and failed checks are
+// prefixed with index of broker
+func (m *multi) Health() HealthReport {
+	h := HealthReport{Live: true, Ready: true}
+	for i, ps := range m.brokers {
+		h.add(strconv.Itoa(i), ps.Health())
+	}
+	return h
+}
+
+// Adding middleware (mw) to every broker
+func (m *multi) Use(mw Middleware) {
+	for _, ps := range m.brokers {
+		ps.Use(mw)
+	}
+}
+
+func (m *multi) SetValidator(tn string, v Validator) error {
+	return m.each(func(ps PubSuber) error { return ps.SetValidator(tn, v) })
+}
+
+// Adding forwarding rule to every broker, messages are forwarded within each broker
+func (m *multi) Forward(src, dst string, transform func([]byte) ([]byte, bool)) error {
+	return m.each(func(ps PubSuber) error { return ps.Forward(src, dst, transform) })
+}
+
+func (m *multi) Unforward(src, dst string) {
+	for _, ps := range m.brokers {
+		ps.Unforward(src, dst)
+	}
+}
+
+func (m *multi) Pause(tn, sn string, drop bool) error {
+	return m.each(func(ps PubSuber) error { return ps.Pause(tn, sn, drop) })
+}
+
+func (m *multi) Resume(tn, sn string) error {
+	return m.each(func(ps PubSuber) error { return ps.Resume(tn, sn) })
+}
+
+// Events of all brokers, channel is closed when channels of all brokers are closed
+func (m *multi) Events(ctx context.Context) <-chan Event {
+	chans := make([]<-chan Event, 0, len(m.brokers))
+	for _, ps := range m.brokers {
+		chans = append(chans, ps.Events(ctx))
+	}
+	return mergeEvents(chans)
+}
+
+func (m *multi) Snapshot(w io.Writer) error {
+	return m.brokers[0].Snapshot(w)
+}
+
+// Closing all brokers, SubscribeChan and SubscribeFunc subscriptions are stopped
+func (m *multi) Close(ctx context.Context) error {
+	m.mux.Lock()
+	for key, cancel := range m.cancels {
+		cancel()
+		delete(m.cancels, key)
+	}
+	m.mux.Unlock()
+	return m.each(func(ps PubSuber) error { return ps.Close(ctx) })
+}
+
+func (m *multi) Advance(d time.Duration) error {
+	return m.each(func(ps PubSuber) error { return ps.Advance(d) })
+}
+
+func (m *multi) Restore(r io.Reader) error {
+	return m.brokers[0].Restore(r)
+}
+
+// Multi of namespaces (name) of all brokers
+func (m *multi) Namespace(name string) PubSuber {
+	brokers := make([]PubSuber, len(m.brokers))
+	for i, ps := range m.brokers {
+		brokers[i] = ps.Namespace(name)
+	}
+	return Multi(brokers...)
+}
+
+// Multi of facades of principal of all brokers
+func (m *multi) As(principal any) PubSuber {
+	brokers := make([]PubSuber, len(m.brokers))
+	for i, ps := range m.brokers {
+		brokers[i] = ps.As(principal)
+	}
+	return Multi(brokers...)
+}
+
+func (m *multi) Request(ctx context.Context, tn string, b []byte) ([]byte, error) {
+	return request(ctx, m, tn, b)
+}
+
+// Web UI showing topics of all brokers, tail view subscribes through multi
+func (m *multi) AdminHandler() http.Handler {
+	return AdminHandler(m)
+}
+
+// Transaction of multi, messages are collected and published to every broker by PublishTx
+type multiTx struct {
+	m     *multi
+	items []multiTxItem
+	done  bool
+}
+
+type multiTxItem struct {
+	tn  string
+	msg Message
+}
+
+func (t *multiTx) Publish(tn string, b []byte) error {
+	_, err := t.PublishMsg(tn, Message{Body: b})
+	return err
+}
+
+func (t *multiTx) PublishMsg(tn string, msg Message) (string, error) {
+	if t.done {
+		return "", ErrTxDone
+	}
+	if msg.ID == "" {
+		msg.ID = t.m.newID()
+	}
+	t.items = append(t.items, multiTxItem{tn: tn, msg: msg})
+	return msg.ID, nil
+}
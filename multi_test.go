@@ -0,0 +1,190 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMulti_Publish(t *testing.T) {
+	a, b := New(), New()
+	lib := Multi(a, b)
+	lib.Subscribe("t", "s")
+	id, err := lib.PublishMsg("t", Message{Body: []byte("message")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ps := range []PubSuber{a, b} {
+		if msg, _ := ps.PollMsg("t", "s"); msg == nil || msg.ID != id {
+			t.Fatal(msg)
+		}
+	}
+	if err := lib.PublishTx(func(tx Tx) error {
+		_ = tx.Publish("t", []byte("1"))
+		return tx.Publish("t", []byte("2"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if depth, _ := lib.Depth("t", "s"); depth != 4 {
+		t.Fatal(depth)
+	}
+	// errors of brokers are joined, message is published to the rest
+	a.SubscribeWithOptions("t", "s", Options{MaxMessages: 2, Overflow: RejectPublish})
+	if err := lib.TryPublish("t", []byte("3")); !errors.Is(err, ErrQueueFull) {
+		t.Fatal(err)
+	}
+	if depth, _ := b.Depth("t", "s"); depth != 3 {
+		t.Fatal(depth)
+	}
+	if topics := lib.Topics(); !reflect.DeepEqual(topics, []string{"t"}) {
+		t.Fatal(topics)
+	}
+}
+
+func TestMulti_Poll(t *testing.T) {
+	a, b := New(), New()
+	lib := Multi(a, b)
+	lib.Subscribe("t", "s")
+	a.Publish("t", []byte("a"))
+	b.Publish("t", []byte("b"))
+	if msgs, _ := lib.PollN("t", "s", 10); len(msgs) != 2 || string(msgs[0]) != "a" || string(msgs[1]) != "b" {
+		t.Fatal(msgs)
+	}
+	if b, err := lib.Poll("t", "s"); b != nil || err != nil {
+		t.Fatal(b, err)
+	}
+	if _, err := lib.Poll("t", "unknown"); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Fatal(err)
+	}
+	// waiting for all brokers at once
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Publish("t", []byte("late"))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if msg, err := lib.PollWait(ctx, "t", "s"); string(msg) != "late" {
+		t.Fatal(string(msg), err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := lib.PollWait(ctx, "t", "s"); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+}
+
+func TestMulti_Duplicates(t *testing.T) {
+	a, b := New(), New()
+	lib := Multi(a, b)
+	lib.Subscribe("t", "s")
+	lib.Publish("t", []byte("1"))
+	lib.Publish("t", []byte("2"))
+	if msgs, _ := lib.PollN("t", "s", 10); len(msgs) != 2 || string(msgs[0]) != "1" || string(msgs[1]) != "2" {
+		t.Fatal(msgs)
+	}
+	if depth, _ := lib.Depth("t", "s"); depth != 0 {
+		t.Fatal(depth)
+	}
+	// copy is acknowledged in its broker, message redelivered by its broker isn't a copy
+	lib.Publish("t", []byte("3"))
+	msg, token, err := lib.PollAck("t", "s", time.Minute)
+	if err != nil || string(msg) != "3" {
+		t.Fatal(string(msg), err)
+	}
+	if err := lib.Nack("t", "s", token, true); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _, _ = lib.PollAck("t", "s", time.Minute); string(msg) != "3" {
+		t.Fatal(string(msg))
+	}
+	if msg, _, err = lib.PollAck("t", "s", time.Minute); msg != nil {
+		t.Fatal(string(msg), err)
+	}
+	if depth, _ := b.Depth("t", "s"); depth != 0 {
+		t.Fatal(depth)
+	}
+}
+
+func TestMulti_PollAck(t *testing.T) {
+	a, b := New(), New()
+	lib := Multi(a, b)
+	lib.Subscribe("t", "s")
+	b.Publish("t", []byte("b"))
+	msg, token, err := lib.PollAck("t", "s", time.Minute)
+	if err != nil || string(msg) != "b" {
+		t.Fatal(string(msg), err)
+	}
+	if err := lib.Nack("t", "s", token, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, token, _ = lib.PollAck("t", "s", time.Minute); token == 0 {
+		t.FailNow()
+	}
+	if err := lib.ExtendAck("t", "s", token, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.Ack("t", "s", token); err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.Ack("t", "s", token); err != ErrUnknownAckToken {
+		t.Fatal(err)
+	}
+	if err := lib.ExtendAck("t", "s", token, time.Hour); err != ErrUnknownAckToken {
+		t.Fatal(err)
+	}
+	if depth, _ := b.Depth("t", "s"); depth != 0 {
+		t.Fatal(depth)
+	}
+}
+
+func TestMulti_SubscribeChan(t *testing.T) {
+	a, b := New(), New()
+	lib := Multi(a, b)
+	ch, err := lib.SubscribeChan("t", "s", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.SubscribeChan("t", "invalid", -1); err != ErrInvalidBuffer {
+		t.Fatal(err)
+	}
+	if _, err := lib.SubscribeChan("t", "s", 0); err != ErrSubscriptionExists {
+		t.Fatal(err)
+	}
+	b.Publish("t", []byte("b"))
+	if msg := <-ch; string(msg) != "b" {
+		t.Fatal(string(msg))
+	}
+	lib.Unsubscribe("t", "s")
+	if _, ok := <-ch; ok {
+		t.FailNow()
+	}
+}
+
+func TestMulti_Namespace(t *testing.T) {
+	a, b := New(), New()
+	lib := Multi(a, b).Namespace("tenant")
+	lib.Subscribe("t", "s")
+	lib.Publish("t", []byte("message"))
+	for _, ps := range []PubSuber{a, b} {
+		if depth, _ := ps.Namespace("tenant").Depth("t", "s"); depth != 1 {
+			t.Fatal(depth)
+		}
+	}
+}
+
+func TestMulti_PollBytes(t *testing.T) {
+	a, b := New(), New()
+	lib := Multi(a, b)
+	lib.Subscribe("t", "s")
+	a.Publish("t", []byte("aa"))
+	b.Publish("t", []byte("bbb"))
+	if msgs, _ := lib.PollBytes("t", "s", 4); len(msgs) != 1 || string(msgs[0]) != "aa" {
+		t.Fatal(msgs)
+	}
+	// message which didn't fit is stashed
+	if msgs, _ := lib.PollBytes("t", "s", 4); len(msgs) != 1 || string(msgs[0]) != "bbb" {
+		t.Fatal(msgs)
+	}
+}
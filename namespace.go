@@ -0,0 +1,429 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Prefix of topic names of namespaces, topic (tn) of namespace (name) is "$ns/name/tn"
+// Wildcards at the first level don't match topics starting with "$", so topics of namespaces are hidden from
+// wildcard subscriptions outside of them
+const namespacePrefix = "$ns/"
+
+// Error happens if topic, subscription or message exceeds NamespaceQuota of its namespace
+var ErrQuotaExceeded = errors.New("namespace quota is exceeded")
+
+// Limits of every namespace (see Namespace), zero means unlimited
+// MaxTopics - number of topics including wildcard and dead-letter ones
+// MaxSubscriptions - number of subscriptions including dead-letter ones
+// MaxMemory - total size of bodies of pending messages of subscriptions of the namespace (like WithMaxMemory),
+// publishing is rejected when it's reached
+type NamespaceQuota struct {
+	MaxTopics        int
+	MaxSubscriptions int
+	MaxMemory        int64
+}
+
+// Limiting every namespace with quota (q), exceeding calls return ErrQuotaExceeded (Subscribe ignores them)
+func WithNamespaceQuota(q NamespaceQuota) Option {
+	return func(p *pubSub) {
+		p.nsQuota = q
+	}
+}
+
+// Usage of a namespace counted against its quota
+// topics - changed under pubSub.mux; subscriptions, memory - accessed atomically
+type namespaceUsage struct {
+	subscriptions int64
+	memory        int64
+	topics        int
+	quota         NamespaceQuota
+}
+
+// Prefix of namespace (name) added to its topic names, characters of levels and wildcards are escaped
+func namespaceOf(name string) string {
+	return namespacePrefix + strings.ReplaceAll(url.PathEscape(name), "+", "%2B") + levelSeparator
+}
+
+// Prefix of namespace of topic name (tn), empty string if topic doesn't belong to a namespace
+func prefixOf(tn string) string {
+	if !strings.HasPrefix(tn, namespacePrefix) {
+		return ""
+	}
+	i := strings.Index(tn[len(namespacePrefix):], levelSeparator)
+	if i < 0 {
+		return ""
+	}
+	return tn[:len(namespacePrefix)+i+1]
+}
+
+// Usage of namespace of topic name (tn), nil if quota isn't set or topic doesn't belong to a namespace
+// p.mux must be held by caller, usage is created if (create) is set (p.mux must be held for writing then)
+func (p *pubSub) usageOf(tn string, create bool) *namespaceUsage {
+	if p.nsQuota == (NamespaceQuota{}) {
+		return nil
+	}
+	prefix := prefixOf(tn)
+	if prefix == "" {
+		return nil
+	}
+	u, ok := p.namespaces[prefix]
+	if !ok && create {
+		if p.namespaces == nil {
+			p.namespaces = map[string]*namespaceUsage{}
+		}
+		u = &namespaceUsage{quota: p.nsQuota}
+		p.namespaces[prefix] = u
+	}
+	return u
+}
+
+// Usage of namespace of topic name (tn) like usageOf, p.mux is taken only if quota is set, so caller must not hold it
+func (p *pubSub) usage(tn string) *namespaceUsage {
+	if p.nsQuota == (NamespaceQuota{}) {
+		return nil
+	}
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.usageOf(tn, false)
+}
+
+// Checks if topic name (tn) may be created according to quota of its namespace
+// p.mux must be held for writing by caller
+func (p *pubSub) admitTopic(tn string) error {
+	u := p.usageOf(tn, false)
+	if u != nil && u.quota.MaxTopics > 0 && u.topics >= u.quota.MaxTopics {
+		p.log.Warn("namespace quota is exceeded", "topic", tn, "max_topics", u.quota.MaxTopics)
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Checks if subscription name (sn) may be added to the list according to quota of its namespace
+// Existing subscriptions are always admitted
+// s.mux must be held by caller
+func (s *subscriptions) admit(sn string) error {
+	if s.ns == nil || s.ns.quota.MaxSubscriptions <= 0 {
+		return nil
+	}
+	if _, ok := s.hm[sn]; ok {
+		return nil
+	}
+	if atomic.LoadInt64(&s.ns.subscriptions) >= int64(s.ns.quota.MaxSubscriptions) {
+		s.log.Warn("namespace quota is exceeded", "subscription", sn, "max_subscriptions", s.ns.quota.MaxSubscriptions)
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Checks if message (m) delivered to subscriptions of topics (targets) fits memory quota of namespace (u)
+// Lists of targets must be locked by caller
+func (u *namespaceUsage) fits(targets []*subscriptions, m *message) bool {
+	if u == nil || u.quota.MaxMemory <= 0 {
+		return true
+	}
+	need := atomic.LoadInt64(&u.memory)
+	for _, subs := range targets {
+		if subs.ns != u {
+			continue
+		}
+		for _, sub := range subs.hm {
+			if sub.accepts(m) {
+				need += int64(len(m.Body))
+			}
+		}
+	}
+	return need <= u.quota.MaxMemory
+}
+
+// Facade of namespace returned by Namespace: topic names are prefixed with namespaceOf(name)
+type namespace struct {
+	p      *pubSub
+	name   string
+	prefix string
+}
+
+// Namespace (name) isolating topics of a tenant: topic "tn" of the namespace is topic "$ns/name/tn" of broker
+// (name is escaped), Topics and Stats of the namespace contain its topics only without prefix. Wildcard subscriptions
+// of the namespace match its topics only, wildcards of broker (and of other namespaces) don't match them.
+// Middlewares added by Use of the namespace are called for its topics only and see topic names without prefix.
+// Snapshot and Restore of the namespace write and read its topics only, so snapshot may be restored into another
+// namespace. Close of the namespace waits until its queues are drained (like Close of broker) and removes all its
+// topics, broker and other namespaces keep working. Namespace of namespace is namespace "parent/name" of broker.
+// Quotas of namespaces are set by WithNamespaceQuota
+func (p *pubSub) Namespace(name string) PubSuber {
+	return &namespace{p: p, name: name, prefix: namespaceOf(name)}
+}
+
+// Topic name of broker for topic name (tn) of namespace
+func (n *namespace) topic(tn string) string {
+	return n.prefix + tn
+}
+
+// Topic name of namespace for topic name (tn) of broker, false is returned if topic isn't in the namespace
+func (n *namespace) local(tn string) (string, bool) {
+	if !strings.HasPrefix(tn, n.prefix) {
+		return "", false
+	}
+	return tn[len(n.prefix):], true
+}
+
+// Removing prefix from topic of message (msg) returned to user of namespace
+func (n *namespace) strip(msg *Message, err error) (*Message, error) {
+	if msg != nil {
+		msg.Topic = strings.TrimPrefix(msg.Topic, n.prefix)
+	}
+	return msg, err
+}
+
+func (n *namespace) Publish(tn string, b []byte) { n.p.Publish(n.topic(tn), b) }
+
+func (n *namespace) TryPublish(tn string, b []byte) error { return n.p.TryPublish(n.topic(tn), b) }
+
+func (n *namespace) PublishResult(tn string, b []byte) (int, error) {
+	return n.p.PublishResult(n.topic(tn), b)
+}
+
+func (n *namespace) PublishTx(fn func(tx Tx) error) error {
+	return n.p.PublishTx(func(tx Tx) error { return fn(&namespaceTx{n: n, next: tx}) })
+}
+
+// Transaction of namespace adding prefix to topic names
+type namespaceTx struct {
+	n    *namespace
+	next Tx
+}
+
+func (t *namespaceTx) Publish(tn string, b []byte) error { return t.next.Publish(t.n.topic(tn), b) }
+
+func (t *namespaceTx) PublishMsg(tn string, msg Message) (string, error) {
+	return t.next.PublishMsg(t.n.topic(tn), msg)
+}
+
+func (n *namespace) PublishMsg(tn string, msg Message) (string, error) {
+	return n.p.PublishMsg(n.topic(tn), msg)
+}
+
+func (n *namespace) PublishAfter(tn string, b []byte, delay time.Duration) {
+	n.p.PublishAfter(n.topic(tn), b, delay)
+}
+
+func (n *namespace) PublishAt(tn string, b []byte, at time.Time) { n.p.PublishAt(n.topic(tn), b, at) }
+
+func (n *namespace) PublishWithPriority(tn string, b []byte, prio int) error {
+	return n.p.PublishWithPriority(n.topic(tn), b, prio)
+}
+
+func (n *namespace) PublishRetained(tn string, b []byte) error {
+	return n.p.PublishRetained(n.topic(tn), b)
+}
+
+func (n *namespace) PublishWithTTL(tn string, b []byte, ttl time.Duration) error {
+	return n.p.PublishWithTTL(n.topic(tn), b, ttl)
+}
+
+func (n *namespace) PublishWithKey(tn string, key string, b []byte) {
+	n.p.PublishWithKey(n.topic(tn), key, b)
+}
+
+func (n *namespace) Subscribe(tn, sn string) { n.p.Subscribe(n.topic(tn), sn) }
+
+func (n *namespace) SubscribeFrom(tn, sn string, from SeekPosition) error {
+	return n.p.SubscribeFrom(n.topic(tn), sn, from)
+}
+
+func (n *namespace) Seek(tn, sn string, seq uint64) error { return n.p.Seek(n.topic(tn), sn, seq) }
+
+func (n *namespace) SetHistory(tn string, size int) { n.p.SetHistory(n.topic(tn), size) }
+
+func (n *namespace) SubscribeWithOptions(tn, sn string, opts Options) {
+	n.p.SubscribeWithOptions(n.topic(tn), sn, opts)
+}
+
+func (n *namespace) SubscribeChan(tn, sn string, buf int) (<-chan []byte, error) {
+	return n.p.SubscribeChan(n.topic(tn), sn, buf)
+}
+
+func (n *namespace) SubscribeFunc(tn, sn string, fn func([]byte), opts ...HandlerOption) error {
+	return n.p.SubscribeFunc(n.topic(tn), sn, fn, opts...)
+}
+
+func (n *namespace) Unsubscribe(tn, sn string) { n.p.Unsubscribe(n.topic(tn), sn) }
+
+func (n *namespace) PurgeSubscription(tn, sn string) (int, error) {
+	return n.p.PurgeSubscription(n.topic(tn), sn)
+}
+
+func (n *namespace) PurgeTopic(tn string) (int, error) { return n.p.PurgeTopic(n.topic(tn)) }
+
+func (n *namespace) CreateTopic(tn string, cfg TopicConfig) error {
+	if tn == "" {
+		return ErrInvalidTopic
+	}
+	return n.p.CreateTopic(n.topic(tn), cfg)
+}
+
+func (n *namespace) ConfigureTopic(tn string, cfg TopicConfig) error {
+	if tn == "" {
+		return ErrInvalidTopic
+	}
+	return n.p.ConfigureTopic(n.topic(tn), cfg)
+}
+
+func (n *namespace) DeleteTopic(tn string) { n.p.DeleteTopic(n.topic(tn)) }
+
+func (n *namespace) Poll(tn, sn string) ([]byte, error) { return n.p.Poll(n.topic(tn), sn) }
+
+func (n *namespace) PollMsg(tn, sn string) (*Message, error) {
+	return n.strip(n.p.PollMsg(n.topic(tn), sn))
+}
+
+func (n *namespace) PollN(tn, sn string, max int) ([][]byte, error) {
+	return n.p.PollN(n.topic(tn), sn, max)
+}
+
+func (n *namespace) PollWait(ctx context.Context, tn, sn string) ([]byte, error) {
+	return n.p.PollWait(ctx, n.topic(tn), sn)
+}
+
+func (n *namespace) PollMsgWait(ctx context.Context, tn, sn string) (*Message, error) {
+	return n.strip(n.p.PollMsgWait(ctx, n.topic(tn), sn))
+}
+
+func (n *namespace) PollAck(tn, sn string, visibility time.Duration) ([]byte, AckToken, error) {
+	return n.p.PollAck(n.topic(tn), sn, visibility)
+}
+
+func (n *namespace) PollAckMsg(tn, sn string, visibility time.Duration) (*Message, AckToken, error) {
+	msg, token, err := n.p.PollAckMsg(n.topic(tn), sn, visibility)
+	msg, err = n.strip(msg, err)
+	return msg, token, err
+}
+
+// Waiting for a message for PollAckMsg of subscription (sn) of topic name (tn) of the namespace
+func (n *namespace) pollAckWait(ctx context.Context, tn, sn string, visibility time.Duration) (*Message, AckToken, error) {
+	msg, token, err := n.p.pollAckWait(ctx, n.topic(tn), sn, visibility)
+	msg, err = n.strip(msg, err)
+	return msg, token, err
+}
+
+func (n *namespace) Ack(tn, sn string, token AckToken) error { return n.p.Ack(n.topic(tn), sn, token) }
+
+func (n *namespace) Nack(tn, sn string, token AckToken, requeue bool) error {
+	return n.p.Nack(n.topic(tn), sn, token, requeue)
+}
+
+func (n *namespace) ExtendAck(tn, sn string, token AckToken, visibility time.Duration) error {
+	return n.p.ExtendAck(n.topic(tn), sn, token, visibility)
+}
+
+func (n *namespace) PollDLQ(tn, sn string) (*Message, error) {
+	return n.strip(n.p.PollDLQ(n.topic(tn), sn))
+}
+
+func (n *namespace) Redrive(tn, sn string) (int, error) { return n.p.Redrive(n.topic(tn), sn) }
+
+// List of topic names of the namespace sorted in ascending order
+func (n *namespace) Topics() []string {
+	var tns []string
+	for _, tn := range n.p.Topics() {
+		if local, ok := n.local(tn); ok {
+			tns = append(tns, local)
+		}
+	}
+	return tns
+}
+
+func (n *namespace) Subscriptions(tn string) ([]string, error) { return n.p.Subscriptions(n.topic(tn)) }
+
+func (n *namespace) TopicConfig(tn string) (TopicConfig, error) { return n.p.TopicConfig(n.topic(tn)) }
+
+// Handle of topic name (tn) of the namespace, it publishes through the namespace
+func (n *namespace) Topic(tn string) *Topic { return NewTopic(n, tn) }
+
+func (n *namespace) Depth(tn, sn string) (int, error) { return n.p.Depth(n.topic(tn), sn) }
+
+func (n *namespace) InFlight(tn, sn string) (int, error) { return n.p.InFlight(n.topic(tn), sn) }
+
+func (n *namespace) Peek(tn, sn string) ([]byte, error) { return n.p.Peek(n.topic(tn), sn) }
+
+func (n *namespace) PeekN(tn, sn string, max int) ([][]byte, error) {
+	return n.p.PeekN(n.topic(tn), sn, max)
+}
+
+// Counters of topics of the namespace
+func (n *namespace) Stats() BrokerStats {
+	var stats BrokerStats
+	for _, ts := range n.p.Stats().Topics {
+		if local, ok := n.local(ts.Name); ok {
+			ts.Name = local
+			stats.Topics = append(stats.Topics, ts)
+		}
+	}
+	return stats
+}
+
+// Adding middleware (mw) called for topics of the namespace only
+func (n *namespace) Use(mw Middleware) {
+	var scoped Middleware
+	if mw.Publish != nil {
+		scoped.Publish = func(next PublishFunc) PublishFunc {
+			inner := mw.Publish(func(ctx context.Context, tn string, msg *Message) error {
+				return next(ctx, n.topic(tn), msg)
+			})
+			return func(ctx context.Context, tn string, msg *Message) error {
+				local, ok := n.local(tn)
+				if !ok {
+					return next(ctx, tn, msg)
+				}
+				msg.Topic = local
+				return inner(ctx, local, msg)
+			}
+		}
+	}
+	if mw.Poll != nil {
+		scoped.Poll = func(next PollFunc) PollFunc {
+			inner := mw.Poll(func(ctx context.Context, tn, sn string) (*Message, error) {
+				return n.strip(next(ctx, n.topic(tn), sn))
+			})
+			return func(ctx context.Context, tn, sn string) (*Message, error) {
+				local, ok := n.local(tn)
+				if !ok {
+					return next(ctx, tn, sn)
+				}
+				msg, err := inner(ctx, local, sn)
+				if msg != nil {
+					msg.Topic = n.topic(msg.Topic)
+				}
+				return msg, err
+			}
+		}
+	}
+	n.p.Use(scoped)
+}
+
+func (n *namespace) Snapshot(w io.Writer) error { return n.p.snapshot(w, n.prefix) }
+
+func (n *namespace) Restore(r io.Reader) error { return n.p.restore(r, n.prefix) }
+
+// Waiting until queues of the namespace are drained or ctx is done and removing all its topics
+// ctx.Err() is returned if ctx was done before queues were drained, topics are removed anyway
+func (n *namespace) Close(ctx context.Context) error {
+	if n.p.closing.Load() {
+		return ErrClosed
+	}
+	err := n.p.drain(ctx, n.prefix)
+	for _, tn := range n.Topics() {
+		n.DeleteTopic(tn)
+	}
+	return err
+}
+
+func (n *namespace) Namespace(name string) PubSuber {
+	return n.p.Namespace(n.name + levelSeparator + name)
+}
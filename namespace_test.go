@@ -0,0 +1,170 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPubSub_Namespace(t *testing.T) {
+	lib := New()
+	acme, other := lib.Namespace("acme"), lib.Namespace("other")
+	tn := "orders"
+	sn := "subscriber/id"
+	acme.Subscribe(tn, sn)
+	acme.Subscribe("#", "all")
+	other.Subscribe(tn, sn)
+	lib.Subscribe("#", "root")
+	if err := acme.TryPublish(tn, []byte("acme")); err != nil {
+		t.FailNow()
+	}
+	other.Publish(tn, []byte("other"))
+	if b, _ := acme.Poll(tn, sn); string(b) != "acme" {
+		t.FailNow()
+	}
+	if b, _ := other.Poll(tn, sn); string(b) != "other" {
+		t.FailNow()
+	}
+	// wildcards don't cross namespaces
+	if msg, _ := acme.PollMsg("#", "all"); msg == nil || msg.Topic != tn || string(msg.Body) != "acme" {
+		t.FailNow()
+	}
+	if depth, _ := acme.Depth("#", "all"); depth != 0 {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth("#", "root"); depth != 0 {
+		t.FailNow()
+	}
+	if topics := acme.Topics(); !reflect.DeepEqual(topics, []string{"#", tn}) {
+		t.Fatal(topics)
+	}
+	if stats := acme.Stats(); len(stats.Topics) != 2 || stats.Topics[1].Name != tn || stats.Topics[1].Published != 1 {
+		t.FailNow()
+	}
+	if _, err := lib.Poll(tn, sn); err != ErrTopicNotFound {
+		t.FailNow()
+	}
+	// names are escaped, so namespaces can't reach each other
+	if lib.Namespace("acme/orders").Topics() != nil {
+		t.FailNow()
+	}
+	if err := acme.Close(context.Background()); err != nil {
+		t.FailNow()
+	}
+	if len(acme.Topics()) != 0 || len(other.Topics()) != 1 {
+		t.FailNow()
+	}
+}
+
+func TestNamespace_PublishRetained(t *testing.T) {
+	lib := New()
+	acme := lib.Namespace("acme")
+	if err := acme.PublishRetained("orders", []byte("retained")); err != nil {
+		t.FailNow()
+	}
+	acme.Subscribe("+", "subscriber/id")
+	lib.Subscribe("#", "subscriber/id")
+	if b, _ := acme.Poll("+", "subscriber/id"); string(b) != "retained" {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth("#", "subscriber/id"); depth != 0 {
+		t.FailNow()
+	}
+}
+
+func TestNamespace_Use(t *testing.T) {
+	lib := New()
+	acme := lib.Namespace("acme")
+	var topics []string
+	acme.Use(Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(ctx context.Context, tn string, msg *Message) error {
+				topics = append(topics, tn+":"+msg.Topic)
+				return next(ctx, tn, msg)
+			}
+		},
+	})
+	sn := "subscriber/id"
+	acme.Subscribe("orders", sn)
+	lib.Subscribe("orders", sn)
+	acme.Publish("orders", []byte("acme"))
+	lib.Publish("orders", []byte("root"))
+	if !reflect.DeepEqual(topics, []string{"orders:orders"}) {
+		t.Fatal(topics)
+	}
+	if b, _ := acme.Poll("orders", sn); string(b) != "acme" {
+		t.FailNow()
+	}
+}
+
+func TestNamespace_Snapshot(t *testing.T) {
+	lib := New()
+	acme := lib.Namespace("acme")
+	sn := "subscriber/id"
+	acme.Subscribe("orders", sn)
+	lib.Subscribe("orders", sn)
+	acme.Publish("orders", []byte("acme"))
+	lib.Publish("orders", []byte("root"))
+	var buf bytes.Buffer
+	if err := acme.Snapshot(&buf); err != nil {
+		t.FailNow()
+	}
+	restored := New().Namespace("copy")
+	if err := restored.Restore(&buf); err != nil {
+		t.FailNow()
+	}
+	if msg, _ := restored.PollMsg("orders", sn); msg == nil || string(msg.Body) != "acme" || msg.Topic != "orders" {
+		t.FailNow()
+	}
+	if topics := restored.Topics(); len(topics) != 1 {
+		t.FailNow()
+	}
+}
+
+func TestWithNamespaceQuota(t *testing.T) {
+	lib := New(WithNamespaceQuota(NamespaceQuota{MaxTopics: 2, MaxSubscriptions: 2, MaxMemory: 10}))
+	acme := lib.Namespace("acme")
+	if err := acme.CreateTopic("a", TopicConfig{}); err != nil {
+		t.FailNow()
+	}
+	acme.Subscribe("b", "1")
+	if err := acme.CreateTopic("c", TopicConfig{}); err != ErrQuotaExceeded {
+		t.FailNow()
+	}
+	if _, err := acme.SubscribeChan("c", "1", 0); err != ErrQuotaExceeded {
+		t.FailNow()
+	}
+	acme.Subscribe("a", "1")
+	if err := acme.SubscribeFrom("a", "2", Beginning); err != ErrQuotaExceeded {
+		t.FailNow()
+	}
+	// dead-letter topic doesn't fit, subscription is ignored
+	acme.Unsubscribe("a", "1")
+	acme.SubscribeWithOptions("b", "2", Options{MaxDeliveries: 1})
+	if sns, _ := acme.Subscriptions("b"); len(sns) != 1 {
+		t.FailNow()
+	}
+	acme.Subscribe("a", "1")
+	if err := acme.TryPublish("a", []byte("12345")); err != nil {
+		t.FailNow()
+	}
+	if err := acme.TryPublish("a", []byte("123456")); err != ErrQuotaExceeded {
+		t.FailNow()
+	}
+	// other namespaces and broker have their own quotas
+	if err := lib.Namespace("other").CreateTopic("c", TopicConfig{}); err != nil {
+		t.FailNow()
+	}
+	if err := lib.CreateTopic("c", TopicConfig{}); err != nil {
+		t.FailNow()
+	}
+	// removed topics and subscriptions free quota
+	acme.DeleteTopic("a")
+	if err := acme.CreateTopic("c", TopicConfig{}); err != nil {
+		t.FailNow()
+	}
+	if err := acme.SubscribeFrom("c", "1", Beginning); err != nil {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,170 @@
+package pubsub
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"time"
+)
+
+// Broker doing nothing, see Noop
+type noop struct{}
+
+// Constructor. Creates PubSuber which drops published messages and never has messages to poll, its calls don't fail
+// Useful for dependency injection in tests and where publishing is disabled, e.g. by feature flag
+// Waiting calls (PollWait, PollMsgWait, Request) return ctx.Err() when ctx is done, channels of SubscribeChan stay empty
+func Noop() PubSuber {
+	return noop{}
+}
+
+func (noop) Publish(string, []byte) {}
+
+func (noop) TryPublish(string, []byte) error { return nil }
+
+func (noop) PublishResult(string, []byte) (int, error) { return 0, nil }
+
+// Calling (fn) with transaction dropping its messages
+func (noop) PublishTx(fn func(tx Tx) error) error { return fn(noopTx{}) }
+
+// Returns ID of message (msg), it's empty if not set by caller
+func (noop) PublishMsg(_ string, msg Message) (string, error) { return msg.ID, nil }
+
+func (noop) PublishAfter(string, []byte, time.Duration) {}
+
+func (noop) PublishAt(string, []byte, time.Time) {}
+
+func (noop) PublishWithPriority(string, []byte, int) error { return nil }
+
+func (noop) PublishRetained(string, []byte) error { return nil }
+
+func (noop) PublishWithTTL(string, []byte, time.Duration) error { return nil }
+
+func (noop) PublishWithKey(string, string, []byte) {}
+
+func (noop) Subscribe(string, string) {}
+
+func (noop) SubscribeFrom(string, string, SeekPosition) error { return nil }
+
+func (noop) Seek(string, string, uint64) error { return nil }
+
+func (noop) SetHistory(string, int) {}
+
+func (noop) SubscribeWithOptions(string, string, Options) {}
+
+func (noop) SubscribeChan(string, string, int) (<-chan []byte, error) { return make(chan []byte), nil }
+
+func (noop) SubscribeFunc(string, string, func([]byte), ...HandlerOption) error { return nil }
+
+func (noop) Unsubscribe(string, string) {}
+
+func (noop) PurgeSubscription(string, string) (int, error) { return 0, nil }
+
+func (noop) PurgeTopic(string) (int, error) { return 0, nil }
+
+func (noop) CreateTopic(string, TopicConfig) error { return nil }
+
+func (noop) ConfigureTopic(string, TopicConfig) error { return nil }
+
+func (noop) TopicConfig(string) (TopicConfig, error) { return TopicConfig{}, nil }
+
+func (noop) Topic(tn string) *Topic { return NewTopic(noop{}, tn) }
+
+func (noop) DeleteTopic(string) {}
+
+func (noop) Poll(string, string) ([]byte, error) { return nil, nil }
+
+func (noop) PollMsg(string, string) (*Message, error) { return nil, nil }
+
+func (noop) PollN(string, string, int) ([][]byte, error) { return nil, nil }
+
+func (noop) PollBytes(string, string, int) ([][]byte, error) { return nil, nil }
+
+func (noop) PollWait(ctx context.Context, _, _ string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (noop) PollMsgWait(ctx context.Context, _, _ string) (*Message, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (noop) Iter(ctx context.Context, tn, sn string) iter.Seq2[[]byte, error] {
+	return iterate(ctx, noop{}, tn, sn)
+}
+
+func (noop) PollAck(string, string, time.Duration) ([]byte, AckToken, error) { return nil, 0, nil }
+
+func (noop) PollAckMsg(string, string, time.Duration) (*Message, AckToken, error) { return nil, 0, nil }
+
+func (noop) Ack(string, string, AckToken) error { return nil }
+
+func (noop) Nack(string, string, AckToken, bool) error { return nil }
+
+func (noop) ExtendAck(string, string, AckToken, time.Duration) error { return nil }
+
+func (noop) PollDLQ(string, string) (*Message, error) { return nil, nil }
+
+func (noop) Redrive(string, string) (int, error) { return 0, nil }
+
+func (noop) Topics() []string { return nil }
+
+func (noop) Subscriptions(string) ([]string, error) { return nil, nil }
+
+func (noop) Depth(string, string) (int, error) { return 0, nil }
+
+func (noop) InFlight(string, string) (int, error) { return 0, nil }
+
+func (noop) Peek(string, string) ([]byte, error) { return nil, nil }
+
+func (noop) PeekN(string, string, int) ([][]byte, error) { return nil, nil }
+
+func (noop) Stats() BrokerStats { return BrokerStats{} }
+
+// Always live and ready
+func (noop) Health() HealthReport { return HealthReport{Live: true, Ready: true} }
+
+func (noop) Use(Middleware) {}
+
+func (noop) SetValidator(string, Validator) error { return nil }
+
+func (noop) Forward(string, string, func([]byte) ([]byte, bool)) error { return nil }
+
+func (noop) Unforward(string, string) {}
+
+func (noop) Pause(string, string, bool) error { return nil }
+
+func (noop) Resume(string, string) error { return nil }
+
+// Nil channel, nothing ever happens
+func (noop) Events(context.Context) <-chan Event { return nil }
+
+// Nothing is written
+func (noop) Snapshot(io.Writer) error { return nil }
+
+func (noop) Close(context.Context) error { return nil }
+
+func (noop) Advance(time.Duration) error { return nil }
+
+// Nothing is read
+func (noop) Restore(io.Reader) error { return nil }
+
+func (n noop) Namespace(string) PubSuber { return n }
+
+func (n noop) As(any) PubSuber { return n }
+
+// Nobody answers, so it waits until ctx is done
+func (noop) Request(ctx context.Context, _ string, _ []byte) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (noop) AdminHandler() http.Handler { return http.NotFoundHandler() }
+
+// Transaction of Noop
+type noopTx struct{}
+
+func (noopTx) Publish(string, []byte) error { return nil }
+
+func (noopTx) PublishMsg(_ string, msg Message) (string, error) { return msg.ID, nil }
@@ -0,0 +1,41 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNoop(t *testing.T) {
+	lib := Noop()
+	lib.Subscribe("t", "s")
+	lib.Publish("t", []byte("message"))
+	if err := lib.TryPublish("t", []byte("message")); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := lib.PublishMsg("t", Message{ID: "1", Body: []byte("message")}); err != nil || id != "1" {
+		t.Fatal(id, err)
+	}
+	if err := lib.PublishTx(func(tx Tx) error { return tx.Publish("t", []byte("message")) }); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := lib.Poll("t", "s"); b != nil || err != nil {
+		t.Fatal(b, err)
+	}
+	if depth, err := lib.Namespace("tenant").As("alice").Depth("t", "s"); depth != 0 || err != nil {
+		t.Fatal(depth, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := lib.PollWait(ctx, "t", "s"); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := lib.Snapshot(&buf); err != nil || buf.Len() != 0 {
+		t.FailNow()
+	}
+	if err := lib.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,236 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Error happens if message is published to a full subscription with RejectPublish policy
+var ErrQueueFull = errors.New("subscription queue is full")
+
+// Overflow policy defines what happens when message is published to a subscription
+// which already has Options.MaxMessages pending messages
+type Overflow int
+
+const (
+	// DropOldest removes the oldest pending message to free space for a new one
+	// (the oldest one among messages with the lowest priority for Options.Priority subscriptions)
+	DropOldest Overflow = iota
+	// DropNewest ignores a new message for this subscription only
+	DropNewest
+	// RejectPublish rejects a new message for all subscriptions of the topic, TryPublish returns ErrQueueFull
+	RejectPublish
+)
+
+// Options of a subscription
+// MaxMessages - limit of pending messages (messages in flight are not counted), zero means unlimited
+// Overflow - what to do when the limit is reached
+// MaxDeliveries - number of PollAck deliveries after which not acknowledged message is moved to the subscription
+// with the same name of dead-letter topic (see DeadLetterTopic), zero means unlimited
+// MaxInFlight - limit of messages polled with PollAck and not acknowledged yet (including ones waiting for Backoff),
+// PollAck returns ErrMaxInFlight while it's reached, zero means unlimited
+// Priority - deliver messages with higher priority (see PublishWithPriority) first, FIFO among equal priorities
+// IdleTimeout - subscription is unsubscribed if it isn't polled during this time, zero means never
+// OnIdle - optional callback called with topic and subscription names after idle subscription is removed,
+// it isn't saved by Snapshot
+// Filter - only messages accepted by the filter are added to the subscription, all if nil; it isn't saved by Snapshot
+// Backoff - delay before redelivery of message which wasn't acknowledged (visibility timeout expired or Nack was
+// called), redelivery is immediate if nil; message stays in flight meanwhile; it isn't saved by Snapshot
+// PollRate - limit of poll calls per second (token bucket), exceeding calls return ErrRateLimited, zero means unlimited;
+// PollN takes one token per call (per message if poll middlewares are used)
+// PollBurst - number of poll calls allowed at once before PollRate applies, at least 1
+// Conflate - new message replaces pending message with the same key taking its place in the queue, so slow pollers
+// get the latest value of every key only; replaced messages are counted as dropped, messages without key are added
+// ConflateHeader - header which value is the key of Conflate, ordering key (see PublishWithKey) is used if empty
+// SampleEvery - only the first of every SampleEvery messages accepted by Filter is added, all if zero or one
+// SampleRate - probability of adding a message accepted by Filter and SampleEvery, all if zero
+type Options struct {
+	MaxMessages    int
+	Overflow       Overflow
+	MaxDeliveries  int
+	MaxInFlight    int
+	Priority       bool
+	IdleTimeout    time.Duration
+	OnIdle         func(tn, sn string) `json:"-"`
+	Filter         Filter              `json:"-"`
+	Backoff        Backoff             `json:"-"`
+	PollRate       float64
+	PollBurst      int
+	Conflate       bool
+	ConflateHeader string
+	SampleEvery    int
+	SampleRate     float64
+}
+
+// Filter decides if message (msg) should be added to a subscription
+// It's called under lock of the topic for every published message, so it must be fast and must not modify msg
+type Filter func(msg *Message) bool
+
+// Filter accepting messages which have header (key) with value
+func HeaderEquals(key, value string) Filter {
+	return func(msg *Message) bool {
+		v, ok := msg.Headers[key]
+		return ok && v == value
+	}
+}
+
+// Filter accepting messages which have header (key) with any value
+func HeaderExists(key string) Filter {
+	return func(msg *Message) bool {
+		_, ok := msg.Headers[key]
+		return ok
+	}
+}
+
+// Filter accepting messages accepted by all filters (fs)
+func AllOf(fs ...Filter) Filter {
+	return func(msg *Message) bool {
+		for _, f := range fs {
+			if !f(msg) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Filter accepting messages accepted by any of filters (fs)
+func AnyOf(fs ...Filter) Filter {
+	return func(msg *Message) bool {
+		for _, f := range fs {
+			if f(msg) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Filter accepting messages rejected by filter (f)
+func Not(f Filter) Filter {
+	return func(msg *Message) bool {
+		return !f(msg)
+	}
+}
+
+// Checks if message (m) passes filter of the subscription
+func (s *subscription) accepts(m *message) bool {
+	return s.opts.Filter == nil || s.opts.Filter(&m.Message)
+}
+
+// Limit of pending messages: Options.MaxMessages, TopicConfig.MaxMessages of its topic or limit of broker
+// (see WithDefaultMaxMessages), the first one which is set; zero means unlimited
+// Lock of subscription must be held by caller
+func (s *subscription) limit() int {
+	if s.opts.MaxMessages != 0 {
+		return s.opts.MaxMessages
+	}
+	if cfg := s.topic.config.Load(); cfg != nil && cfg.MaxMessages != 0 {
+		return cfg.MaxMessages
+	}
+	return s.topic.maxMessages
+}
+
+// Limiting pending messages of subscriptions which have neither own Options.MaxMessages nor TopicConfig.MaxMessages
+// of their topic to (max), Options.Overflow of subscription is applied when it's reached. Zero means unlimited
+func WithDefaultMaxMessages(max int) Option {
+	return func(p *pubSub) {
+		p.maxMessages = max
+	}
+}
+
+// Limit of total body size of pending messages: TopicConfig.MaxBytes of its topic, zero means unlimited
+func (s *subscription) maxBytes() int64 {
+	if cfg := s.topic.config.Load(); cfg != nil {
+		return cfg.MaxBytes
+	}
+	return 0
+}
+
+// Checks if messages are delivered by priority: Options.Priority or TopicConfig.Priority of its topic
+func (s *subscription) priority() bool {
+	cfg := s.topic.config.Load()
+	return s.opts.Priority || cfg != nil && cfg.Priority
+}
+
+// Checks if subscription reached its pending messages limit
+func (s *subscription) full() bool {
+	max := s.limit()
+	return max > 0 && s.len() >= max
+}
+
+// Checks if there is no space for message (m): pending messages limit is reached or total body size limit
+// would be exceeded
+func (s *subscription) overflows(m *message) bool {
+	if s.full() {
+		return true
+	}
+	max := s.maxBytes()
+	return max > 0 && s.bytes()+int64(len(m.Body)) > max
+}
+
+// Add message (m) to the subscription according to its filter and overflow policy
+// returns false if message wasn't added
+func (s *subscription) push(m *message) bool {
+	if !s.accepts(m) || !s.sampled() || s.pauseDrops(m) {
+		return false
+	}
+	key := s.conflationKey(m)
+	if key != "" && s.conflate(key, m) {
+		return true
+	}
+	if s.overflows(m) {
+		s.warnSlow()
+		if s.opts.Overflow != DropOldest {
+			atomic.AddUint64(&s.dropped, 1)
+			s.log.Debug("message dropped", "id", m, "reason", "overflow")
+			s.emit(EventMessageDropped, m, 1, "overflow")
+			return false
+		}
+		for s.len() > 0 && s.overflows(m) {
+			s.drop("overflow")
+		}
+		if s.overflows(m) {
+			// message is bigger than TopicConfig.MaxBytes
+			atomic.AddUint64(&s.dropped, 1)
+			s.log.Debug("message dropped", "id", m, "reason", "overflow")
+			s.emit(EventMessageDropped, m, 1, "overflow")
+			return false
+		}
+	}
+	m.retain()
+	s.add(m)
+	if key != "" {
+		m.pin()
+		s.conflated[key] = m
+	}
+	return true
+}
+
+// Removing the least valuable pending message because of (reason)
+func (s *subscription) drop(reason string) {
+	it, _ := s.evictable()
+	s.evict()
+	atomic.AddUint64(&s.dropped, 1)
+	// LogAttrs doesn't allocate for disabled level unlike Debug with non-constant reason
+	s.log.LogAttrs(context.Background(), slog.LevelDebug, "message dropped", slog.String("reason", reason))
+	s.emit(EventMessageDropped, it.message, 1, reason)
+	if it.message != nil {
+		it.release()
+	}
+}
+
+// Removing pending messages exceeding limits of DropOldest subscription, the limits might be lowered by
+// ConfigureTopic; other policies keep messages which are already accepted
+func (s *subscription) trim() {
+	if s.opts.Overflow != DropOldest {
+		return
+	}
+	max, maxBytes := s.limit(), s.maxBytes()
+	for max > 0 && s.len() > max || maxBytes > 0 && s.bytes() > maxBytes {
+		s.drop("overflow")
+	}
+}
@@ -0,0 +1,130 @@
+package pubsub
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPubSub_SubscribeWithOptions_DropOldest(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxMessages: 2, Overflow: DropOldest})
+	for _, m := range []string{"a", "b", "c"} {
+		if err := lib.TryPublish(tn, []byte(m)); err != nil {
+			t.FailNow()
+		}
+	}
+	msgs, _ := lib.PollN(tn, sn, 10)
+	if len(msgs) != 2 || string(msgs[0]) != "b" || string(msgs[1]) != "c" {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_SubscribeWithOptions_DropNewest(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	sn2 := "unlimited subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxMessages: 2, Overflow: DropNewest})
+	lib.Subscribe(tn, sn2)
+	for _, m := range []string{"a", "b", "c"} {
+		if err := lib.TryPublish(tn, []byte(m)); err != nil {
+			t.FailNow()
+		}
+	}
+	msgs, _ := lib.PollN(tn, sn, 10)
+	if len(msgs) != 2 || string(msgs[0]) != "a" || string(msgs[1]) != "b" {
+		t.FailNow()
+	}
+	if msgs, _ := lib.PollN(tn, sn2, 10); len(msgs) != 3 {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_SubscribeWithOptions_RejectPublish(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	sn2 := "unlimited subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{MaxMessages: 1, Overflow: RejectPublish})
+	lib.Subscribe(tn, sn2)
+	if err := lib.TryPublish(tn, []byte("a")); err != nil {
+		t.FailNow()
+	}
+	if err := lib.TryPublish(tn, []byte("b")); err != ErrQueueFull {
+		t.FailNow()
+	}
+	// rejected message isn't delivered to other subscriptions too
+	if msgs, _ := lib.PollN(tn, sn2, 10); len(msgs) != 1 {
+		t.FailNow()
+	}
+	// Subscribe keeps options of existing subscription
+	lib.Subscribe(tn, sn)
+	if err := lib.TryPublish(tn, []byte("c")); err != ErrQueueFull {
+		t.FailNow()
+	}
+	lib.SubscribeWithOptions(tn, sn, Options{})
+	if err := lib.TryPublish(tn, []byte("c")); err != nil {
+		t.FailNow()
+	}
+}
+
+func TestOptions_Filter(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	eu := AllOf(HeaderEquals("region", "eu"), Not(HeaderExists("test")))
+	lib.SubscribeWithOptions(tn, "eu", Options{Filter: eu, MaxMessages: 1, Overflow: RejectPublish})
+	lib.SubscribeWithOptions(tn, "any", Options{Filter: AnyOf(HeaderExists("region"), HeaderExists("test"))})
+	lib.Subscribe(tn, "all")
+	publish := func(body string, headers map[string]string) error {
+		_, err := lib.PublishMsg(tn, Message{Body: []byte(body), Headers: headers})
+		return err
+	}
+	if publish("eu", map[string]string{"region": "eu"}) != nil {
+		t.FailNow()
+	}
+	// filtered out messages are not rejected by full subscription
+	if publish("us", map[string]string{"region": "us"}) != nil || publish("none", nil) != nil {
+		t.FailNow()
+	}
+	if publish("test", map[string]string{"region": "eu", "test": "1"}) != nil {
+		t.FailNow()
+	}
+	if publish("eu2", map[string]string{"region": "eu"}) != ErrQueueFull {
+		t.FailNow()
+	}
+	for sn, want := range map[string]string{"eu": "eu", "any": "eu,us,test", "all": "eu,us,none,test"} {
+		msgs, _ := lib.PollN(tn, sn, 10)
+		var got []string
+		for _, m := range msgs {
+			got = append(got, string(m))
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("%s: %v", sn, got)
+		}
+	}
+}
+
+func TestWithDefaultMaxMessages(t *testing.T) {
+	lib := New(WithDefaultMaxMessages(2), WithCopyOnPublish(true))
+	lib.Subscribe("orders", "billing")
+	lib.SubscribeWithOptions("orders", "shipping", Options{MaxMessages: 3})
+	_ = lib.CreateTopic("payments", TopicConfig{MaxMessages: 1})
+	lib.Subscribe("payments", "billing")
+	for _, m := range []string{"a", "b", "c", "d"} {
+		lib.Publish("orders", []byte(m))
+		lib.Publish("payments", []byte(m))
+	}
+	for _, c := range []struct {
+		tn, sn string
+		depth  int
+	}{{"orders", "billing", 2}, {"orders", "shipping", 3}, {"payments", "billing", 1}} {
+		if depth, _ := lib.Depth(c.tn, c.sn); depth != c.depth {
+			t.Fatal(c, depth)
+		}
+	}
+	if msgs, _ := lib.PollN("orders", "billing", 10); len(msgs) != 2 || string(msgs[0]) != "c" {
+		t.Fatal(msgs)
+	}
+}
@@ -0,0 +1,172 @@
+//go:build otel
+
+/*
+OpenTelemetry instrumentation for pubsub package. Broker wraps a PubSuber, starts a producer span for every
+publish and a consumer span for every poll, and propagates trace context through message headers, so traces
+flow from publisher to poller:
+
+	ps := otelpubsub.Wrap(pubsub.New())
+	_, _ = ps.PublishMsgContext(ctx, "orders", pubsub.Message{Body: b})
+	...
+	ctx, msg, err := ps.PollMsgContext(ctx, "orders", "billing")
+
+Context returned by PollMsgContext carries trace context of the publisher. Methods without context (Publish,
+Poll, etc) start spans from context.Background(). Poll returns only body, so trace context of the publisher
+is available only via PollMsg and PollMsgContext.
+
+The package requires go.opentelemetry.io/otel, so it's built only with `otel` build tag:
+
+	go build -tags otel ./...
+*/
+package otelpubsub
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cejixo3/pubsub.git"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the instrumentation library reported to the tracer provider
+const instrumentationName = "github.com/cejixo3/pubsub.git/otelpubsub"
+
+// Value of messaging.system attribute
+const messagingSystem = "pubsub"
+
+// Broker is a PubSuber with tracing of publish and poll methods, other methods are passed through
+type Broker struct {
+	pubsub.PubSuber
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// Option configures Broker
+type Option func(*Broker)
+
+// Using tracer provider (tp) instead of the global one
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(b *Broker) {
+		b.tracer = tp.Tracer(instrumentationName)
+	}
+}
+
+// Using propagator (p) instead of the global one
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(b *Broker) {
+		b.propagator = p
+	}
+}
+
+// Constructor. Wraps broker (ps) with tracing, global tracer provider and propagator are used by default
+func Wrap(ps pubsub.PubSuber, opts ...Option) *Broker {
+	b := &Broker{
+		PubSuber:   ps,
+		tracer:     otel.Tracer(instrumentationName),
+		propagator: otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Publish message (msg) to topic name (tn) in producer span, trace context of the span is added to message headers
+// Headers of msg are copied, the caller's map isn't modified
+func (b *Broker) PublishMsgContext(ctx context.Context, tn string, msg pubsub.Message) (string, error) {
+	ctx, span := b.tracer.Start(ctx, tn+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", messagingSystem),
+			attribute.String("messaging.destination.name", tn),
+			attribute.String("messaging.operation", "publish"),
+			attribute.Int("messaging.message.body.size", len(msg.Body)),
+		),
+	)
+	defer span.End()
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	b.propagator.Inject(ctx, propagation.MapCarrier(headers))
+	msg.Headers = headers
+	id, err := b.PubSuber.PublishMsg(tn, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+	span.SetAttributes(attribute.String("messaging.message.id", id))
+	return id, nil
+}
+
+// Fetching message for topic name (tn) and subscriber name (sn) in consumer span, which is linked to the span of
+// the publisher. Returned context is ctx with trace context of the publisher, nil message means there are no messages
+func (b *Broker) PollMsgContext(ctx context.Context, tn, sn string) (context.Context, *pubsub.Message, error) {
+	_, span := b.tracer.Start(ctx, tn+" receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", messagingSystem),
+			attribute.String("messaging.destination.name", tn),
+			attribute.String("messaging.destination.subscription.name", sn),
+			attribute.String("messaging.operation", "receive"),
+		),
+	)
+	defer span.End()
+	msg, err := b.PubSuber.PollMsg(tn, sn)
+	if errors.Is(err, pubsub.ErrEmpty) {
+		return ctx, nil, err
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return ctx, nil, err
+	}
+	if msg == nil {
+		return ctx, nil, nil
+	}
+	span.SetAttributes(
+		attribute.String("messaging.message.id", msg.ID),
+		attribute.Int("messaging.message.body.size", len(msg.Body)),
+	)
+	ctx = b.propagator.Extract(ctx, propagation.MapCarrier(msg.Headers))
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		span.AddLink(trace.Link{SpanContext: sc})
+	}
+	return ctx, msg, nil
+}
+
+// Publish message (body) by topic name (tn), see PublishMsgContext
+func (b *Broker) Publish(tn string, body []byte) {
+	_, _ = b.PublishMsgContext(context.Background(), tn, pubsub.Message{Body: body})
+}
+
+// Publish message (body) by topic name (tn), see PublishMsgContext
+func (b *Broker) TryPublish(tn string, body []byte) error {
+	_, err := b.PublishMsgContext(context.Background(), tn, pubsub.Message{Body: body})
+	return err
+}
+
+// Publish message (msg) by topic name (tn), see PublishMsgContext
+func (b *Broker) PublishMsg(tn string, msg pubsub.Message) (string, error) {
+	return b.PublishMsgContext(context.Background(), tn, msg)
+}
+
+// Fetching message for topic name (tn) and subscriber name (sn), see PollMsgContext
+func (b *Broker) PollMsg(tn, sn string) (*pubsub.Message, error) {
+	_, msg, err := b.PollMsgContext(context.Background(), tn, sn)
+	return msg, err
+}
+
+// Fetching message body for topic name (tn) and subscriber name (sn), see PollMsgContext
+func (b *Broker) Poll(tn, sn string) ([]byte, error) {
+	_, msg, err := b.PollMsgContext(context.Background(), tn, sn)
+	if msg == nil {
+		return nil, err
+	}
+	return msg.Body, nil
+}
@@ -0,0 +1,116 @@
+//go:build otel
+
+package otelpubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Broker wrapping new broker with tracer provider recording spans into returned exporter
+func testBroker(opts ...pubsub.Option) (*Broker, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return Wrap(pubsub.New(opts...), WithTracerProvider(tp), WithPropagator(propagation.TraceContext{})), exporter
+}
+
+// Value of attribute (key) of span (s), empty if it isn't set
+func attr(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestBroker_Propagation(t *testing.T) {
+	b, exporter := testBroker()
+	b.Subscribe("orders", "billing")
+	tp := sdktrace.NewTracerProvider()
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "handler")
+	headers := map[string]string{"type": "x"}
+	id, err := b.PublishMsgContext(ctx, "orders", pubsub.Message{Headers: headers, Body: []byte("message")})
+	parent.End()
+	if err != nil || len(headers) != 1 {
+		t.Fatal(err, headers)
+	}
+	pctx, msg, err := b.PollMsgContext(context.Background(), "orders", "billing")
+	if err != nil || msg == nil || msg.Headers["type"] != "x" || msg.Headers["traceparent"] == "" {
+		t.Fatal(msg, err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatal(spans)
+	}
+	publish, receive := spans[0], spans[1]
+	if publish.Name != "orders publish" || publish.SpanKind != trace.SpanKindProducer ||
+		publish.Parent.TraceID() != parent.SpanContext().TraceID() {
+		t.Fatal(publish)
+	}
+	if attr(publish, "messaging.system").AsString() != "pubsub" ||
+		attr(publish, "messaging.destination.name").AsString() != "orders" ||
+		attr(publish, "messaging.operation").AsString() != "publish" ||
+		attr(publish, "messaging.message.id").AsString() != id ||
+		attr(publish, "messaging.message.body.size").AsInt64() != 7 {
+		t.Fatal(publish.Attributes)
+	}
+	if receive.Name != "orders receive" || receive.SpanKind != trace.SpanKindConsumer {
+		t.Fatal(receive)
+	}
+	if attr(receive, "messaging.destination.subscription.name").AsString() != "billing" ||
+		attr(receive, "messaging.operation").AsString() != "receive" ||
+		attr(receive, "messaging.message.id").AsString() != id {
+		t.Fatal(receive.Attributes)
+	}
+	// consumer span is linked to the producer span, returned context carries trace context of the publisher
+	if len(receive.Links) != 1 || receive.Links[0].SpanContext.SpanID() != publish.SpanContext.SpanID() {
+		t.Fatal(receive.Links)
+	}
+	if sc := trace.SpanContextFromContext(pctx); sc.TraceID() != parent.SpanContext().TraceID() ||
+		sc.SpanID() != publish.SpanContext.SpanID() {
+		t.Fatal(sc)
+	}
+}
+
+func TestBroker_Empty(t *testing.T) {
+	b, exporter := testBroker(pubsub.WithEmptyError(true))
+	b.Subscribe("orders", "billing")
+	if msg, err := b.PollMsg("orders", "billing"); msg != nil || !errors.Is(err, pubsub.ErrEmpty) {
+		t.Fatal(msg, err)
+	}
+	// empty poll isn't an error of the span
+	if spans := exporter.GetSpans(); len(spans) != 1 || spans[0].Status.Code == codes.Error || len(spans[0].Links) != 0 {
+		t.Fatal(spans)
+	}
+}
+
+func TestBroker_Error(t *testing.T) {
+	b, exporter := testBroker()
+	_ = b.SetValidator("orders", pubsub.ValidatorFunc(func(string, *pubsub.Message) error { return errors.New("bad") }))
+	b.Subscribe("orders", "billing")
+	if err := b.TryPublish("orders", []byte("message")); !errors.Is(err, pubsub.ErrInvalidMessage) {
+		t.Fatal(err)
+	}
+	if _, err := b.Poll("orders", "unknown"); err == nil {
+		t.FailNow()
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatal(spans)
+	}
+	for _, s := range spans {
+		if s.Status.Code != codes.Error || len(s.Events) != 1 || s.Events[0].Name != "exception" {
+			t.Fatal(s)
+		}
+	}
+}
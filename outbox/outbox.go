@@ -0,0 +1,300 @@
+/*
+Transactional outbox for pubsub package. Services write messages into an outbox table in the same database
+transaction as their business data, Relay polls the table and publishes new rows into the broker, marking them
+delivered:
+
+	err := o.Publish(ctx, tx, "orders", pubsub.Message{Body: b}) // tx is *sql.Tx of the service
+	...
+	err = o.Relay(ctx, ps) // runs until ctx is done
+
+Expected table layout (names of columns are fixed, table name is Outbox.Table):
+
+	CREATE TABLE outbox (
+		id           BIGINT PRIMARY KEY AUTO_INCREMENT, -- BIGSERIAL in PostgreSQL, INTEGER in SQLite
+		topic        VARCHAR(255) NOT NULL,
+		headers      TEXT,                              -- JSON object, NULL if there are no headers
+		body         BLOB NOT NULL,                     -- BYTEA in PostgreSQL
+		created_at   TIMESTAMP NOT NULL,
+		delivered_at TIMESTAMP NULL
+	);
+
+Rows are published in order of id, a batch of rows is published with PublishTx (subscribers see all rows of the batch
+or none of them) before the database transaction marking it delivered is committed. Relay remembers rows published
+in the current batch until the transaction is committed, so rows aren't published twice if committing fails. The
+broker lives in memory of the process, so exactly once delivery holds for a single relay per table.
+
+Rows the broker rejects for good (unknown topic in strict mode, validator, no subscriptions with
+WithNoSubscribersError, forbidden) are marked delivered without being published and reported to Outbox.OnReject,
+so one bad row doesn't stall the outbox. Batches rejected for a while (full queues, rate limits, paused
+subscriptions) are retried.
+*/
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Header added to published messages, id of outbox row
+const HeaderOutboxID = "outbox-id"
+
+// Defaults of Outbox settings
+const (
+	DefaultTable        = "outbox"
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+	DefaultRetryDelay   = 5 * time.Second
+)
+
+// SQL syntax differences between databases
+// Placeholder - parameter placeholder of position n (starting from 1)
+// LockRows - suffix of SELECT locking relayed rows, so concurrent relays skip them, empty if not supported
+type Dialect struct {
+	Placeholder func(n int) string
+	LockRows    string
+}
+
+func question(int) string { return "?" }
+
+// Dialects of popular databases
+var (
+	Postgres = Dialect{Placeholder: func(n int) string { return "$" + strconv.Itoa(n) }, LockRows: "FOR UPDATE SKIP LOCKED"}
+	MySQL    = Dialect{Placeholder: question, LockRows: "FOR UPDATE SKIP LOCKED"}
+	SQLite   = Dialect{Placeholder: question}
+)
+
+// Execer executes statements, *sql.Tx, *sql.DB and *sql.Conn implement it
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Outbox writes messages into outbox table and relays them into broker
+// Table - name of outbox table, DefaultTable is used if empty
+// BatchSize - up to how many rows are relayed in one database transaction, DefaultBatchSize is used if zero
+// PollInterval - how often the table is polled when there are no new rows, DefaultPollInterval is used if zero
+// RetryDelay - delay before polling again after failure, DefaultRetryDelay is used if zero
+// OnReject - optional callback called with row (id) and topic (tn) the broker rejected with (err), the row is skipped
+type Outbox struct {
+	db           *sql.DB
+	dialect      Dialect
+	Table        string
+	BatchSize    int
+	PollInterval time.Duration
+	RetryDelay   time.Duration
+	OnReject     func(id int64, tn string, err error)
+}
+
+// Constructor. Creates Outbox of database (db) with SQL dialect (d)
+func New(db *sql.DB, d Dialect) *Outbox {
+	return &Outbox{db: db, dialect: d}
+}
+
+func (o *Outbox) table() string {
+	if o.Table == "" {
+		return DefaultTable
+	}
+	return o.Table
+}
+
+// Writing message (msg) for topic name (tn) into outbox table with (tx), usually transaction of the caller
+// The message is published by Relay after the transaction is committed
+func (o *Outbox) Publish(ctx context.Context, tx Execer, tn string, msg pubsub.Message) error {
+	var headers []byte
+	if len(msg.Headers) > 0 {
+		var err error
+		if headers, err = json.Marshal(msg.Headers); err != nil {
+			return err
+		}
+	}
+	p := o.dialect.Placeholder
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO "+o.table()+" (topic, headers, body, created_at) VALUES ("+p(1)+", "+p(2)+", "+p(3)+", "+p(4)+")",
+		tn, nullString(headers), msg.Body, time.Now().UTC())
+	return err
+}
+
+func nullString(b []byte) sql.NullString {
+	return sql.NullString{String: string(b), Valid: b != nil}
+}
+
+// Row of outbox table
+type row struct {
+	id      int64
+	tn      string
+	headers sql.NullString
+	body    []byte
+}
+
+// Relaying rows into broker (ps) until ctx is done or broker is closed, ctx.Err() is returned in the first case
+// Failed batches are retried after RetryDelay
+func (o *Outbox) Relay(ctx context.Context, ps pubsub.PubSuber) error {
+	batch, interval, delay := o.BatchSize, o.PollInterval, o.RetryDelay
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	// rows published in the broker, but not marked delivered in the database yet
+	published := map[int64]bool{}
+	for {
+		n, err := o.relay(ctx, ps, batch, published)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		wait := time.Duration(0)
+		switch {
+		case errors.Is(err, pubsub.ErrClosed):
+			return err
+		case err != nil:
+			wait = delay
+		case n < batch:
+			wait = interval
+		}
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// Relaying one batch of up to (batch) rows in a database transaction, returns number of relayed rows
+func (o *Outbox) relay(ctx context.Context, ps pubsub.PubSuber, batch int, published map[int64]bool) (int, error) {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	rows, err := o.fetch(ctx, tx, batch)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	var fresh []row
+	for _, r := range rows {
+		if !published[r.id] {
+			fresh = append(fresh, r)
+		}
+	}
+	if err := o.publishAll(ps, fresh, published); err != nil {
+		return 0, err
+	}
+	if err := o.markDelivered(ctx, tx, rows); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, r := range rows {
+		delete(published, r.id)
+	}
+	return len(rows), nil
+}
+
+// Selecting up to (batch) rows which aren't delivered yet in order of ids
+func (o *Outbox) fetch(ctx context.Context, tx *sql.Tx, batch int) ([]row, error) {
+	query := "SELECT id, topic, headers, body FROM " + o.table() + " WHERE delivered_at IS NULL ORDER BY id LIMIT " +
+		strconv.Itoa(batch)
+	if o.dialect.LockRows != "" {
+		query += " " + o.dialect.LockRows
+	}
+	rs, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var rows []row
+	for rs.Next() {
+		var r row
+		if err := rs.Scan(&r.id, &r.tn, &r.headers, &r.body); err != nil {
+			return nil, err
+		}
+		rows = append(rows, r)
+	}
+	return rows, rs.Err()
+}
+
+// Publishing (rows) and remembering them in (published). If the broker rejects the batch for good, rows are published
+// one by one, so rejected rows are found, reported to OnReject and remembered as published to be skipped
+func (o *Outbox) publishAll(ps pubsub.PubSuber, rows []row, published map[int64]bool) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	err := o.publish(ps, rows)
+	if err == nil {
+		for _, r := range rows {
+			published[r.id] = true
+		}
+		return nil
+	}
+	if !rejected(err) {
+		return err
+	}
+	for _, r := range rows {
+		// the only row of a batch is rejected already
+		if len(rows) > 1 {
+			err = o.publish(ps, []row{r})
+		}
+		if err != nil && !rejected(err) {
+			return err
+		}
+		if err != nil && o.OnReject != nil {
+			o.OnReject(r.id, r.tn, err)
+		}
+		published[r.id] = true
+	}
+	return nil
+}
+
+// Whether (err) of publish means that the broker will never accept the message, unlike full queues or closed broker
+func rejected(err error) bool {
+	if errors.Is(err, pubsub.ErrClosed) {
+		return false
+	}
+	return errors.Is(err, pubsub.ErrNoSubscriptions) || errors.Is(err, pubsub.ErrInvalidMessage) ||
+		errors.Is(err, pubsub.ErrForbidden) || errors.Is(err, pubsub.ErrInvalidTopic)
+}
+
+// Publishing rows atomically, rows with malformed headers are published without them
+func (o *Outbox) publish(ps pubsub.PubSuber, rows []row) error {
+	return ps.PublishTx(func(tx pubsub.Tx) error {
+		for _, r := range rows {
+			headers := map[string]string{}
+			if r.headers.Valid {
+				_ = json.Unmarshal([]byte(r.headers.String), &headers)
+			}
+			headers[HeaderOutboxID] = strconv.FormatInt(r.id, 10)
+			if _, err := tx.PublishMsg(r.tn, pubsub.Message{Headers: headers, Body: r.body}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Setting delivery time of rows
+func (o *Outbox) markDelivered(ctx context.Context, tx *sql.Tx, rows []row) error {
+	p := o.dialect.Placeholder
+	args := make([]any, 0, len(rows)+1)
+	args = append(args, time.Now().UTC())
+	ids := make([]string, len(rows))
+	for i, r := range rows {
+		ids[i] = p(i + 2)
+		args = append(args, r.id)
+	}
+	_, err := tx.ExecContext(ctx,
+		"UPDATE "+o.table()+" SET delivered_at = "+p(1)+" WHERE id IN ("+strings.Join(ids, ", ")+")", args...)
+	return err
+}
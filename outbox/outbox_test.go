@@ -0,0 +1,281 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Outbox table of fake database, changes of transactions are applied on commit
+type fakeTable struct {
+	mux         sync.Mutex
+	rows        []fakeRow
+	failCommits int
+}
+
+type fakeRow struct {
+	id        int64
+	tn        string
+	headers   any
+	body      []byte
+	delivered bool
+}
+
+// Driver of fake database understanding queries of Outbox only
+type fakeDriver struct {
+	t *fakeTable
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{t: d.t}, nil
+}
+
+// Connection with pending changes of current transaction (tx)
+type fakeConn struct {
+	t  *fakeTable
+	tx *fakeTx
+}
+
+type fakeTx struct {
+	c       *fakeConn
+	inserts []fakeRow
+	updates []int64
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.tx = &fakeTx{c: c}
+	return c.tx, nil
+}
+
+func (tx *fakeTx) Commit() error {
+	t := tx.c.t
+	tx.c.tx = nil
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.failCommits > 0 {
+		t.failCommits--
+		return errors.New("commit failed")
+	}
+	for _, r := range tx.inserts {
+		r.id = int64(len(t.rows) + 1)
+		t.rows = append(t.rows, r)
+	}
+	for _, id := range tx.updates {
+		t.rows[id-1].delivered = true
+	}
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.c.tx = nil
+	return nil
+}
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	tx := s.c.tx
+	if tx == nil {
+		return nil, errors.New("only transactions are supported")
+	}
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO outbox (topic, headers, body, created_at) VALUES"):
+		tx.inserts = append(tx.inserts, fakeRow{tn: args[0].(string), headers: args[1], body: args[2].([]byte)})
+	case strings.HasPrefix(s.query, "UPDATE outbox SET delivered_at = ? WHERE id IN ("):
+		for _, id := range args[1:] {
+			tx.updates = append(tx.updates, id.(int64))
+		}
+	default:
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	prefix := "SELECT id, topic, headers, body FROM outbox WHERE delivered_at IS NULL ORDER BY id LIMIT "
+	if !strings.HasPrefix(s.query, prefix) {
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	limit, _ := strconv.Atoi(strings.TrimPrefix(s.query, prefix))
+	t := s.c.t
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	rows := &fakeRows{}
+	for _, r := range t.rows {
+		if !r.delivered && len(rows.rows) < limit {
+			rows.rows = append(rows.rows, r)
+		}
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	rows []fakeRow
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id", "topic", "headers", "body"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	dest[0], dest[1], dest[2], dest[3] = row.id, row.tn, row.headers, row.body
+	return nil
+}
+
+var registerOnce sync.Once
+var table = &fakeTable{}
+
+// Fake database with empty outbox table
+func openDB(t *testing.T) (*sql.DB, *fakeTable) {
+	registerOnce.Do(func() { sql.Register("outboxtest", &fakeDriver{t: table}) })
+	table.mux.Lock()
+	table.rows, table.failCommits = nil, 0
+	table.mux.Unlock()
+	db, err := sql.Open("outboxtest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db, table
+}
+
+// Writing messages (bodies) in one transaction
+func write(t *testing.T, db *sql.DB, o *Outbox, bodies ...string) {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range bodies {
+		if err := o.Publish(ctx, tx, "orders", pubsub.Message{Headers: map[string]string{"type": "x"}, Body: []byte(b)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutbox_Relay(t *testing.T) {
+	db, table := openDB(t)
+	o := New(db, SQLite)
+	o.BatchSize, o.PollInterval = 2, time.Millisecond
+	write(t, db, o, "1", "2", "3")
+	lib := pubsub.New()
+	lib.Subscribe("orders", "billing")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := o.Relay(ctx, lib); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		msg, _ := lib.PollMsg("orders", "billing")
+		if msg == nil || string(msg.Body) != strconv.Itoa(i) || msg.Headers["type"] != "x" || msg.Headers[HeaderOutboxID] != strconv.Itoa(i) {
+			t.Fatal(msg)
+		}
+	}
+	for _, r := range table.rows {
+		if !r.delivered {
+			t.FailNow()
+		}
+	}
+}
+
+func TestOutbox_Relay_CommitFailure(t *testing.T) {
+	db, table := openDB(t)
+	o := New(db, SQLite)
+	o.PollInterval, o.RetryDelay = time.Millisecond, time.Millisecond
+	write(t, db, o, "1", "2")
+	table.failCommits = 2
+	lib := pubsub.New()
+	lib.Subscribe("orders", "billing")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_ = o.Relay(ctx, lib)
+	// rows are published once although they were relayed three times
+	if depth, _ := lib.Depth("orders", "billing"); depth != 2 {
+		t.Fatal(depth)
+	}
+	if !table.rows[0].delivered || !table.rows[1].delivered {
+		t.FailNow()
+	}
+}
+
+func TestOutbox_Relay_Rejected(t *testing.T) {
+	db, _ := openDB(t)
+	o := New(db, SQLite)
+	o.PollInterval, o.RetryDelay = time.Millisecond, time.Millisecond
+	write(t, db, o, "1", "2")
+	lib := pubsub.New()
+	lib.SubscribeWithOptions("orders", "billing", pubsub.Options{MaxMessages: 1, Overflow: pubsub.RejectPublish})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_ = o.Relay(ctx, lib)
+	// the batch doesn't fit, so nothing is published
+	if depth, _ := lib.Depth("orders", "billing"); depth != 0 {
+		t.Fatal(depth)
+	}
+}
+
+func TestOutbox_Relay_Poison(t *testing.T) {
+	db, table := openDB(t)
+	o := New(db, SQLite)
+	o.PollInterval, o.RetryDelay = time.Millisecond, time.Millisecond
+	var rejects []int64
+	o.OnReject = func(id int64, tn string, err error) {
+		if tn != "orders" || !errors.Is(err, pubsub.ErrInvalidMessage) {
+			t.Error(tn, err)
+		}
+		rejects = append(rejects, id)
+	}
+	write(t, db, o, "1", "poison", "3")
+	lib := pubsub.New()
+	lib.Subscribe("orders", "billing")
+	_ = lib.SetValidator("orders", pubsub.ValidatorFunc(func(tn string, msg *pubsub.Message) error {
+		if string(msg.Body) == "poison" {
+			return errors.New("poison")
+		}
+		return nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_ = o.Relay(ctx, lib)
+	// the rejected row is skipped, the rest of the batch is published
+	for _, want := range []string{"1", "3"} {
+		if msg, _ := lib.Poll("orders", "billing"); string(msg) != want {
+			t.Fatal(string(msg), want)
+		}
+	}
+	if len(rejects) != 1 || rejects[0] != 2 {
+		t.Fatal(rejects)
+	}
+	for _, r := range table.rows {
+		if !r.delivered {
+			t.FailNow()
+		}
+	}
+}
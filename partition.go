@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Level of partition topic names between name of partitioned topic and partition number
+const partitionLevel = "/$partition/"
+
+// Name of partition (i) of topic name (tn) created with TopicConfig.Partitions, subscribe to it to consume
+// the partition. Partitions are numbered from 0
+func Partition(tn string, i int) string {
+	return tn + partitionLevel + strconv.Itoa(i)
+}
+
+// Name of partitioned topic and partition number of partition topic name (tn), false if tn isn't partition name
+func parsePartition(tn string) (string, int, bool) {
+	i := strings.LastIndex(tn, partitionLevel)
+	if i <= 0 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(tn[i+len(partitionLevel):])
+	if err != nil || n < 0 {
+		return "", 0, false
+	}
+	return tn[:i], n, true
+}
+
+// Checks if topic name (tn) is a partition of topic created with TopicConfig.Partitions
+func (p *pubSub) isPartition(tn string) bool {
+	parent, i, ok := parsePartition(tn)
+	if !ok {
+		return false
+	}
+	subs, ok := p.topics.get(parent)
+	if !ok {
+		return false
+	}
+	cfg := subs.config.Load()
+	return cfg != nil && i < cfg.Partitions
+}
+
+// Partition of the topic which message (m) goes to: FNV-1a hash of Message.Key modulo number of partitions,
+// messages without key are spread round-robin. -1 is returned if the topic isn't partitioned
+func (s *subscriptions) partitionOf(m *message) int {
+	cfg := s.config.Load()
+	if cfg == nil || cfg.Partitions <= 0 {
+		return -1
+	}
+	n := uint32(cfg.Partitions)
+	if m.Key == "" {
+		return int((atomic.AddUint32(&s.nextPartition, 1) - 1) % n)
+	}
+	return int(fnv32(m.Key) % n)
+}
+
+// Appending to (targets) subscriptions lists message (m) published to topic name (tn) is delivered to: the topic
+// (subs, nil if there is no such topic), patterns matching it and the partition of m if the topic is partitioned,
+// in order of names
+// Partitions are matched only by their exact names, so wildcard subscriptions get the message once
+func (p *pubSub) route(tn string, subs *subscriptions, m *message, targets []*subscriptions) []*subscriptions {
+	targets = p.match(tn, subs, targets)
+	if subs == nil {
+		return targets
+	}
+	i := subs.partitionOf(m)
+	if i < 0 {
+		return targets
+	}
+	part, ok := p.topics.get(Partition(tn, i))
+	if !ok {
+		return targets
+	}
+	j, _ := slices.BinarySearchFunc(targets, part.tn, func(s *subscriptions, tn string) int {
+		return strings.Compare(s.tn, tn)
+	})
+	return slices.Insert(targets, j, part)
+}
@@ -0,0 +1,111 @@
+package pubsub
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPartition(t *testing.T) {
+	if tn := Partition("orders", 3); tn != "orders/$partition/3" {
+		t.Fatal(tn)
+	}
+	if tn, i, ok := parsePartition(Partition("a/b", 12)); !ok || tn != "a/b" || i != 12 {
+		t.Fatal(tn, i, ok)
+	}
+	for _, tn := range []string{"orders", "/$partition/1", "orders/$partition/x", "orders/$partition/-1"} {
+		if _, _, ok := parsePartition(tn); ok {
+			t.Fatal(tn)
+		}
+	}
+}
+
+func TestTopicConfig_Partitions(t *testing.T) {
+	lib := New()
+	tn := "orders"
+	if err := lib.CreateTopic(tn, TopicConfig{Partitions: 4}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		lib.Subscribe(Partition(tn, i), "workers")
+	}
+	lib.Subscribe(tn, "audit")
+	lib.Subscribe("#", "all")
+	for i := 0; i < 10; i++ {
+		lib.PublishWithKey(tn, "customer-"+strconv.Itoa(i%3), []byte(strconv.Itoa(i)))
+	}
+	// every key goes to one partition in order
+	partitions := map[string]int{}
+	total := 0
+	for i := 0; i < 4; i++ {
+		last := -1
+		for {
+			msg, _ := lib.PollMsg(Partition(tn, i), "workers")
+			if msg == nil {
+				break
+			}
+			if p, ok := partitions[msg.Key]; ok && p != i {
+				t.Fatal(msg.Key, p, i)
+			}
+			partitions[msg.Key] = i
+			if n, _ := strconv.Atoi(string(msg.Body)); n <= last || msg.Topic != tn {
+				t.Fatal(msg)
+			} else {
+				last = n
+			}
+			total++
+		}
+	}
+	if total != 10 || len(partitions) != 3 {
+		t.Fatal(total, partitions)
+	}
+	// the topic itself and wildcards receive all messages once
+	for _, sn := range []string{"audit", "all"} {
+		tn := tn
+		if sn == "all" {
+			tn = "#"
+		}
+		if depth, _ := lib.Depth(tn, sn); depth != 10 {
+			t.Fatal(sn, depth)
+		}
+	}
+	// messages without key are spread round-robin
+	for i := 0; i < 8; i++ {
+		lib.Publish(tn, []byte("x"))
+	}
+	for i := 0; i < 4; i++ {
+		if depth, _ := lib.Depth(Partition(tn, i), "workers"); depth != 2 {
+			t.Fatal(i, depth)
+		}
+	}
+}
+
+func TestTopicConfig_Partitions_Strict(t *testing.T) {
+	lib := New(WithStrictTopics(true))
+	tn := "orders"
+	lib.CreateTopic(tn, TopicConfig{Partitions: 2})
+	lib.Subscribe(Partition(tn, 1), "workers")
+	lib.Subscribe(Partition(tn, 2), "workers")
+	if _, err := lib.Depth(Partition(tn, 1), "workers"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.Depth(Partition(tn, 2), "workers"); err != ErrTopicNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestPubSub_PublishTx_Partitions(t *testing.T) {
+	lib := New()
+	lib.CreateTopic("orders", TopicConfig{Partitions: 2})
+	lib.Subscribe(Partition("orders", 0), "workers")
+	lib.Subscribe(Partition("orders", 1), "workers")
+	lib.PublishTx(func(tx Tx) error {
+		tx.PublishMsg("orders", Message{Key: "a"})
+		_, err := tx.PublishMsg("orders", Message{Key: "a"})
+		return err
+	})
+	d0, _ := lib.Depth(Partition("orders", 0), "workers")
+	d1, _ := lib.Depth(Partition("orders", 1), "workers")
+	if d0+d1 != 2 || d0 != 0 && d1 != 0 {
+		t.Fatal(d0, d1)
+	}
+}
@@ -0,0 +1,102 @@
+package pubsub
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Error happens if a paused subscription is polled without waiting (see Pause)
+var ErrPaused = errors.New("subscription is paused")
+
+// Pausing subscription (sn) of topic name (tn), e.g. for a maintenance window of its consumer
+// Poll, PollN, PollMsg and PollAck of paused subscription return ErrPaused, PollWait and PollMsgWait wait until
+// Resume, so SubscribeChan and SubscribeFunc consumers survive the pause. Messages published meanwhile stay pending
+// as usual, or are dropped if (drop) is true. Messages in flight may be acknowledged and are redelivered
+// Pausing paused subscription changes only drop
+func (p *pubSub) Pause(tn, sn string, drop bool) error {
+	subs, sub, err := p.acquire(tn, sn)
+	if err != nil {
+		return err
+	}
+	defer subs.mux.Unlock()
+	if !sub.paused {
+		sub.log.Debug("subscription paused", "drop", drop)
+	}
+	sub.paused, sub.pauseDrop = true, drop
+	return nil
+}
+
+// Resuming subscription (sn) of topic name (tn) paused by Pause, waiting pollers are woken up
+// Resuming subscription which isn't paused does nothing
+func (p *pubSub) Resume(tn, sn string) error {
+	subs, sub, err := p.acquire(tn, sn)
+	if err != nil {
+		return err
+	}
+	defer subs.mux.Unlock()
+	if sub.paused {
+		sub.paused, sub.pauseDrop = false, false
+		sub.log.Debug("subscription resumed")
+		sub.cond.Broadcast()
+	}
+	return nil
+}
+
+// Returns subscription like acquirePoll, but ErrPaused raises if the subscription is paused
+func (p *pubSub) acquireActive(tn, sn string) (*subscription, error) {
+	sub, err := p.acquirePoll(tn, sn)
+	if err != nil {
+		return nil, err
+	}
+	if sub.paused {
+		sub.Unlock()
+		return nil, ErrPaused
+	}
+	return sub, nil
+}
+
+// Take the next message like next, but paused subscription has no messages
+func (s *subscription) nextActive(now time.Time) (item, bool) {
+	if s.paused {
+		return item{}, false
+	}
+	return s.next(now)
+}
+
+// Checks if message (m) is dropped because subscription is paused with drop
+func (s *subscription) pauseDrops(m *message) bool {
+	if !s.paused || !s.pauseDrop {
+		return false
+	}
+	atomic.AddUint64(&s.dropped, 1)
+	s.log.Debug("message dropped", "id", m, "reason", "paused")
+	s.emit(EventMessageDropped, m, 1, "paused")
+	return true
+}
+
+// Pausing subscription (sn) of topic name (tn) of the namespace
+func (n *namespace) Pause(tn, sn string, drop bool) error {
+	return n.p.Pause(n.topic(tn), sn, drop)
+}
+
+// Resuming subscription (sn) of topic name (tn) of the namespace
+func (n *namespace) Resume(tn, sn string) error {
+	return n.p.Resume(n.topic(tn), sn)
+}
+
+// Pausing subscription if principal may manage it
+func (a *authorized) Pause(tn, sn string, drop bool) error {
+	if err := a.allow(OpManage, tn, sn); err != nil {
+		return err
+	}
+	return a.next.Pause(tn, sn, drop)
+}
+
+// Resuming subscription if principal may manage it
+func (a *authorized) Resume(tn, sn string) error {
+	if err := a.allow(OpManage, tn, sn); err != nil {
+		return err
+	}
+	return a.next.Resume(tn, sn)
+}
@@ -0,0 +1,95 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPubSub_Pause(t *testing.T) {
+	lib := New()
+	lib.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("1"))
+	if err := lib.Pause("orders", "billing", false); err != nil {
+		t.Fatal(err)
+	}
+	lib.Publish("orders", []byte("2"))
+	if _, err := lib.Poll("orders", "billing"); err != ErrPaused {
+		t.Fatal(err)
+	}
+	if _, err := lib.PollN("orders", "billing", 10); err != ErrPaused {
+		t.Fatal(err)
+	}
+	if _, _, err := lib.PollAck("orders", "billing", time.Second); err != ErrPaused {
+		t.Fatal(err)
+	}
+	if depth, _ := lib.Depth("orders", "billing"); depth != 2 {
+		t.Fatal(depth)
+	}
+	if !lib.Stats().Topics[0].Subscriptions[0].Paused {
+		t.Fatal("not paused")
+	}
+	if err := lib.Resume("orders", "billing"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1", "2"} {
+		if b, err := lib.Poll("orders", "billing"); err != nil || string(b) != want {
+			t.Fatal(string(b), err)
+		}
+	}
+	if err := lib.Pause("orders", "unknown", false); err != ErrSubscriptionNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestPubSub_Pause_Drop(t *testing.T) {
+	lib := New()
+	lib.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("1"))
+	_ = lib.Pause("orders", "billing", true)
+	lib.Publish("orders", []byte("2"))
+	_ = lib.Resume("orders", "billing")
+	lib.Publish("orders", []byte("3"))
+	for _, want := range []string{"1", "3"} {
+		if b, _ := lib.Poll("orders", "billing"); string(b) != want {
+			t.Fatal(string(b))
+		}
+	}
+	if dropped := lib.Stats().Topics[0].Subscriptions[0].Dropped; dropped != 1 {
+		t.Fatal(dropped)
+	}
+}
+
+func TestPubSub_Pause_Wait(t *testing.T) {
+	lib := New()
+	lib.Subscribe("orders", "billing")
+	_ = lib.Pause("orders", "billing", false)
+	lib.Publish("orders", []byte("1"))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := lib.PollWait(ctx, "orders", "billing"); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = lib.Resume("orders", "billing")
+	}()
+	if b, err := lib.PollWait(context.Background(), "orders", "billing"); err != nil || string(b) != "1" {
+		t.Fatal(string(b), err)
+	}
+}
+
+func TestNamespace_Pause(t *testing.T) {
+	lib := New()
+	ns := lib.Namespace("tenant")
+	ns.Subscribe("orders", "billing")
+	ns.Publish("orders", []byte("1"))
+	_ = ns.Pause("orders", "billing", false)
+	if _, err := ns.Poll("orders", "billing"); err != ErrPaused {
+		t.Fatal(err)
+	}
+	_ = ns.Resume("orders", "billing")
+	if b, _ := ns.Poll("orders", "billing"); string(b) != "1" {
+		t.Fatal(string(b))
+	}
+}
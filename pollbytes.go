@@ -0,0 +1,168 @@
+package pubsub
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Fetching as many whole messages for topic name (tn) and subscriber name (sn) as fit into (maxBytes) bytes of bodies
+// The first message is returned even if it's larger than maxBytes, so a big message doesn't block the subscription,
+// message which doesn't fit stays the first one of the subscription
+// nil, nil should be returned if all messages was fetched already or maxBytes is not positive (ErrEmpty in the first
+// case if WithEmptyError is used)
+// Complexity: O(n) where n - number of returned messages
+// Poll middlewares (see Use) are called for every message, budget is checked against bodies before them
+func (p *pubSub) PollBytes(tn, sn string, maxBytes int) ([][]byte, error) {
+	if maxBytes <= 0 {
+		return nil, nil
+	}
+	if p.interceptsPoll() {
+		var msgs [][]byte
+		for size := 0; size < maxBytes; {
+			left := maxBytes - size
+			if len(msgs) == 0 {
+				left = -1
+			}
+			b, err := bodyOf(p.interceptPoll(context.Background(), tn, sn, p.fetchWithin(left)))
+			if err != nil {
+				return msgs, err
+			}
+			if b == nil {
+				break
+			}
+			msgs = append(msgs, b)
+			size += len(b)
+		}
+		if len(msgs) == 0 {
+			return nil, p.empty(nil)
+		}
+		return msgs, nil
+	}
+	sub, err := p.acquireActive(tn, sn)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unlock()
+	msgs, err := sub.nextBytes(maxBytes, p.now())
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, p.empty(nil)
+	}
+	return msgs, nil
+}
+
+// PollFunc fetching a message only if its body fits into (maxBytes), any message if it's negative
+func (p *pubSub) fetchWithin(maxBytes int) PollFunc {
+	return func(_ context.Context, tn, sn string) (*Message, error) {
+		sub, err := p.acquireActive(tn, sn)
+		if err != nil {
+			return nil, err
+		}
+		defer sub.Unlock()
+		it, ok := sub.next(p.now())
+		if !ok {
+			return nil, nil
+		}
+		msg, err := sub.copy(it)
+		if err != nil {
+			return nil, err
+		}
+		if maxBytes >= 0 && len(msg.Body) > maxBytes {
+			sub.putBack(it)
+			return nil, nil
+		}
+		it.release()
+		return msg, nil
+	}
+}
+
+// Take oldest not expired messages while their bodies fit into (maxBytes), at least one if there are messages
+// Taking stops at message which body can't be decoded, error is returned only if no messages were taken
+func (s *subscription) nextBytes(maxBytes int, now time.Time) ([][]byte, error) {
+	var msgs [][]byte
+	for size := 0; size < maxBytes; {
+		it, ok := s.next(now)
+		if !ok {
+			break
+		}
+		b, err := s.body(it)
+		if err != nil {
+			if len(msgs) == 0 {
+				return nil, err
+			}
+			break
+		}
+		if len(msgs) > 0 && size+len(b) > maxBytes {
+			s.putBack(it)
+			break
+		}
+		it.release()
+		msgs = append(msgs, b)
+		size += len(b)
+	}
+	return msgs, nil
+}
+
+// Putting item (it) taken by next back, so it's taken first again and isn't counted as delivered
+func (s *subscription) putBack(it item) {
+	atomic.AddUint64(&s.delivered, ^uint64(0))
+	s.pushFront(it)
+}
+
+// Decoded body of item (it) taken by next, the item is put back if its body can't be decoded, so the message stays
+// pending
+func (s *subscription) body(it item) ([]byte, error) {
+	b, err := it.body()
+	if err != nil {
+		s.putBack(it)
+		s.log.Warn("message isn't delivered", "id", it.message, "error", err)
+	}
+	return b, err
+}
+
+// Copy of exported part of message of item (it) taken by next with decoded body, the item is put back like by body
+// if it fails
+func (s *subscription) copy(it item) (*Message, error) {
+	b, err := s.body(it)
+	if err != nil {
+		return nil, err
+	}
+	msg := it.Message
+	msg.ID = it.id()
+	msg.Body = b
+	return &msg, nil
+}
+
+// Copy of message of item (it) taken by next like copy, the item is released then, so the message may be reused
+func (s *subscription) consume(it item) (*Message, error) {
+	msg, err := s.copy(it)
+	if err == nil {
+		it.release()
+	}
+	return msg, err
+}
+
+// Decoded body of item (it) taken by next like body, the item is released then, so the message may be reused
+func (s *subscription) consumeBody(it item) ([]byte, error) {
+	b, err := s.body(it)
+	if err == nil {
+		it.release()
+	}
+	return b, err
+}
+
+// Fetching messages fitting into (maxBytes) of subscription (sn) of topic name (tn) of the namespace
+func (n *namespace) PollBytes(tn, sn string, maxBytes int) ([][]byte, error) {
+	return n.p.PollBytes(n.topic(tn), sn, maxBytes)
+}
+
+// Fetching messages fitting into (maxBytes) if principal may poll the subscription
+func (a *authorized) PollBytes(tn, sn string, maxBytes int) ([][]byte, error) {
+	if err := a.allow(OpPoll, tn, sn); err != nil {
+		return nil, err
+	}
+	return a.next.PollBytes(tn, sn, maxBytes)
+}
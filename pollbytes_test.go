@@ -0,0 +1,65 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPollBytes(t *testing.T) {
+	tn, sn := "orders", "billing"
+	lib := New()
+	lib.Subscribe(tn, sn)
+	for _, b := range []string{"aaa", "bbb", "cc", "dddddddd", "e"} {
+		lib.Publish(tn, []byte(b))
+	}
+	if msgs, err := lib.PollBytes(tn, sn, 0); msgs != nil || err != nil {
+		t.Fatal(msgs, err)
+	}
+	if msgs, _ := lib.PollBytes(tn, sn, 7); len(msgs) != 2 || string(msgs[0]) != "aaa" || string(msgs[1]) != "bbb" {
+		t.Fatal(msgs)
+	}
+	// message bigger than the budget is returned alone
+	if msgs, _ := lib.PollBytes(tn, sn, 4); len(msgs) != 1 || string(msgs[0]) != "cc" {
+		t.Fatal(msgs)
+	}
+	if msgs, _ := lib.PollBytes(tn, sn, 4); len(msgs) != 1 || string(msgs[0]) != "dddddddd" {
+		t.Fatal(msgs)
+	}
+	if msgs, _ := lib.PollBytes(tn, sn, 4); len(msgs) != 1 || string(msgs[0]) != "e" {
+		t.Fatal(msgs)
+	}
+	if msgs, err := lib.PollBytes(tn, sn, 4); msgs != nil || err != nil {
+		t.Fatal(msgs, err)
+	}
+	if delivered := lib.Stats().Topics[0].Subscriptions[0].Delivered; delivered != 5 {
+		t.Fatal(delivered)
+	}
+	if _, err := lib.PollBytes(tn, "unknown", 4); err == nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPollBytes_Middleware(t *testing.T) {
+	tn, sn := "orders", "billing"
+	lib := New()
+	polled := 0
+	lib.Use(Middleware{Poll: func(next PollFunc) PollFunc {
+		return func(ctx context.Context, tn, sn string) (*Message, error) {
+			msg, err := next(ctx, tn, sn)
+			if msg != nil {
+				polled++
+			}
+			return msg, err
+		}
+	}})
+	lib.Subscribe(tn, sn)
+	for _, b := range []string{"aaa", "bbb", "cc"} {
+		lib.Publish(tn, []byte(b))
+	}
+	if msgs, _ := lib.PollBytes(tn, sn, 7); len(msgs) != 2 || polled != 2 {
+		t.Fatal(msgs, polled)
+	}
+	if msg, _ := lib.Poll(tn, sn); string(msg) != "cc" {
+		t.Fatal(string(msg))
+	}
+}
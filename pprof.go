@@ -0,0 +1,25 @@
+package pubsub
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// Key of pprof label with topic name set by WithProfilerLabels
+const ProfilerLabelTopic = "pubsub.topic"
+
+// Labeling publishing and polling with pprof label ProfilerLabelTopic (topic name), so CPU profiles attribute time of
+// broker to topics during performance investigations, e.g. go tool pprof -tagfocus=pubsub.topic=orders
+// Labels are added to labels of context of PollWait and PollMsgWait, methods without context replace labels of the
+// calling goroutine and clear them when they return. Every poll is labeled separately, so PollN doesn't take
+// messages under a single lock while labels are enabled. Disabled by default, labels cost allocations
+func WithProfilerLabels(enabled bool) Option {
+	return func(p *pubSub) {
+		p.labels = enabled
+	}
+}
+
+// Calling (fn) with pprof label of topic name (tn) added to labels of (ctx)
+func labeled(ctx context.Context, tn string, fn func(ctx context.Context)) {
+	pprof.Do(ctx, pprof.Labels(ProfilerLabelTopic, tn), fn)
+}
@@ -0,0 +1,39 @@
+package pubsub
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestWithProfilerLabels(t *testing.T) {
+	lib := New(WithProfilerLabels(true))
+	var published, polled string
+	lib.Use(Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(ctx context.Context, tn string, msg *Message) error {
+				published, _ = pprof.Label(ctx, ProfilerLabelTopic)
+				return next(ctx, tn, msg)
+			}
+		},
+		Poll: func(next PollFunc) PollFunc {
+			return func(ctx context.Context, tn, sn string) (*Message, error) {
+				polled, _ = pprof.Label(ctx, ProfilerLabelTopic)
+				return next(ctx, tn, sn)
+			}
+		},
+	})
+	lib.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("order"))
+	if b, _ := lib.Poll("orders", "billing"); string(b) != "order" {
+		t.Fatal(string(b))
+	}
+	if published != "orders" || polled != "orders" {
+		t.Fatal(published, polled)
+	}
+	lib.Publish("orders", []byte("1"))
+	lib.Publish("orders", []byte("2"))
+	if msgs, _ := lib.PollN("orders", "billing", 10); len(msgs) != 2 {
+		t.Fatal(msgs)
+	}
+}
@@ -0,0 +1,188 @@
+package pubsub
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+)
+
+// Item of priority storage, seq keeps FIFO order among items with equal priority
+type prioEntry struct {
+	item
+	seq int64
+}
+
+// Storage for subscriptions with Options.Priority: max-heap by message priority, FIFO for equal priorities
+// first, last - lowest and highest seq used, items put to the front get seq lower than first
+type priorityStorage struct {
+	usage
+	entries []prioEntry
+	first   int64
+	last    int64
+}
+
+// Creates storage ordered by priority (see PublishWithPriority) or FIFO one
+// meters - counters of memory usage changed by the storage (see usage)
+func newStorage(priority bool, meters []*int64) storage {
+	if priority {
+		return &priorityStorage{usage: usage{meters: meters}}
+	}
+	return &ringStorage{usage: usage{meters: meters}}
+}
+
+func (s *priorityStorage) Len() int { return len(s.entries) }
+
+func (s *priorityStorage) Less(i, j int) bool {
+	a, b := s.entries[i], s.entries[j]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (s *priorityStorage) Swap(i, j int) { s.entries[i], s.entries[j] = s.entries[j], s.entries[i] }
+
+func (s *priorityStorage) Push(x interface{}) {
+	e := x.(prioEntry)
+	s.entries = append(s.entries, e)
+	s.grow(int64(len(e.Body)))
+}
+
+func (s *priorityStorage) Pop() interface{} {
+	n := len(s.entries) - 1
+	e := s.entries[n]
+	s.entries[n] = prioEntry{}
+	s.entries = s.entries[:n]
+	s.grow(-int64(len(e.Body)))
+	return e
+}
+
+// Add new message (m) after messages with the same priority
+func (s *priorityStorage) add(m *message) {
+	s.pushBack(item{message: m})
+}
+
+// Put item (it) before messages with the same priority
+func (s *priorityStorage) pushFront(it item) {
+	s.first--
+	heap.Push(s, prioEntry{item: it, seq: s.first})
+}
+
+// Put item (it) after messages with the same priority
+func (s *priorityStorage) pushBack(it item) {
+	s.last++
+	heap.Push(s, prioEntry{item: it, seq: s.last})
+}
+
+// Replace pending message (old) with (m) keeping its place among messages of the same priority
+// Complexity: O(n)
+func (s *priorityStorage) swap(old, m *message) bool {
+	for i := range s.entries {
+		if s.entries[i].message == old {
+			s.grow(int64(len(m.Body) - len(old.Body)))
+			s.entries[i].item = item{message: m}
+			heap.Fix(s, i)
+			return true
+		}
+	}
+	return false
+}
+
+// Take item with the highest priority
+func (s *priorityStorage) take() (item, bool) {
+	if len(s.entries) == 0 {
+		return item{}, false
+	}
+	return heap.Pop(s).(prioEntry).item, true
+}
+
+// Remove the oldest item with the lowest priority
+// Complexity: O(n)
+func (s *priorityStorage) evict() {
+	if len(s.entries) > 0 {
+		heap.Remove(s, s.victim())
+	}
+}
+
+// The oldest item with the lowest priority
+// Complexity: O(n)
+func (s *priorityStorage) evictable() (item, bool) {
+	if len(s.entries) == 0 {
+		return item{}, false
+	}
+	return s.entries[s.victim()].item, true
+}
+
+// Index of the oldest entry with the lowest priority, entries must not be empty
+func (s *priorityStorage) victim() int {
+	min := 0
+	for i := range s.entries {
+		if s.Less(min, i) {
+			min = i
+		}
+	}
+	// the last one among lowest priorities is the newest, look for the oldest
+	for i := range s.entries {
+		if s.entries[i].priority == s.entries[min].priority && s.entries[i].seq < s.entries[min].seq {
+			min = i
+		}
+	}
+	return min
+}
+
+func (s *priorityStorage) len() int {
+	return len(s.entries)
+}
+
+// Remove all messages which time-to-live is over at the moment now, heap is rebuilt afterwards
+func (s *priorityStorage) removeExpired(now time.Time) int {
+	kept := s.entries[:0]
+	for _, e := range s.entries {
+		if e.expired(now) {
+			s.grow(-int64(len(e.Body)))
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	removed := len(s.entries) - len(kept)
+	for i := len(kept); i < len(s.entries); i++ {
+		s.entries[i] = prioEntry{}
+	}
+	s.entries = kept
+	if removed > 0 {
+		heap.Init(s)
+	}
+	return removed
+}
+
+// Copy of all items in delivery order
+// Complexity: O(n*log(n))
+func (s *priorityStorage) items() []item {
+	sorted := &priorityStorage{entries: append([]prioEntry(nil), s.entries...)}
+	sort.Sort(sorted)
+	items := make([]item, len(sorted.entries))
+	for i, e := range sorted.entries {
+		items[i] = e.item
+	}
+	return items
+}
+
+// Copy of up to max first items in delivery order
+// Complexity: O(n*log(n))
+func (s *priorityStorage) peek(max int) []item {
+	items := s.items()
+	if max < len(items) {
+		items = items[:max]
+	}
+	return items
+}
+
+// Publish message (b) by topic name (tn) with priority (prio)
+// Subscriptions with Options.Priority deliver messages with higher priority first, others ignore priority
+// Overflow policies are applied like in TryPublish
+func (p *pubSub) PublishWithPriority(tn string, b []byte, prio int) error {
+	m := p.newMessage(tn, Message{Body: b})
+	m.priority = prio
+	_, err := p.publish(tn, m)
+	return err
+}
@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPubSub_PublishWithPriority(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "prioritized subscriber/id"
+	sn2 := "fifo subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{Priority: true})
+	lib.Subscribe(tn, sn2)
+	_ = lib.PublishWithPriority(tn, []byte("low"), 1)
+	_ = lib.PublishWithPriority(tn, []byte("high"), 10)
+	_ = lib.PublishWithPriority(tn, []byte("high 2"), 10)
+	lib.Publish(tn, []byte("default"))
+	for subName, expected := range map[string][]string{
+		sn:  {"high", "high 2", "low", "default"},
+		sn2: {"low", "high", "high 2", "default"},
+	} {
+		msgs, _ := lib.PollN(tn, subName, 10)
+		if len(msgs) != len(expected) {
+			t.Fatal(subName, msgs)
+		}
+		for i := range msgs {
+			if string(msgs[i]) != expected[i] {
+				t.Fatal(subName, i, string(msgs[i]))
+			}
+		}
+	}
+}
+
+func TestPubSub_Priority_Requeue(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	_ = lib.PublishWithPriority(tn, []byte("low"), 1)
+	_ = lib.PublishWithPriority(tn, []byte("high"), 10)
+	_ = lib.PublishWithPriority(tn, []byte("high 2"), 10)
+	// switching existing subscription to priority storage keeps pending messages
+	lib.SubscribeWithOptions(tn, sn, Options{Priority: true})
+	msg, token, _ := lib.PollAck(tn, sn, time.Hour)
+	if string(msg) != "high" {
+		t.Fatal(string(msg))
+	}
+	_ = lib.Nack(tn, sn, token, true)
+	msgs, _ := lib.PollN(tn, sn, 10)
+	if len(msgs) != 3 || string(msgs[0]) != "high" || string(msgs[1]) != "high 2" || string(msgs[2]) != "low" {
+		t.Fatal(msgs)
+	}
+}
+
+func TestPriorityStorage_removeExpired(t *testing.T) {
+	s := &priorityStorage{}
+	now := time.Now()
+	for i, ttl := range []time.Duration{time.Hour, time.Minute, time.Hour, time.Minute} {
+		s.add(&message{expires: now.Add(ttl), priority: i})
+	}
+	if s.removeExpired(now.Add(2*time.Minute)) != 2 || s.len() != 2 {
+		t.FailNow()
+	}
+	if it, _ := s.take(); it.priority != 2 {
+		t.FailNow()
+	}
+}
+
+func TestPriorityStorage_evict(t *testing.T) {
+	s := &priorityStorage{}
+	for i, prio := range []int{5, 1, 3, 1} {
+		s.add(&message{Message: Message{ID: string(rune('a' + i))}, priority: prio})
+	}
+	s.evict()
+	items := s.items()
+	if len(items) != 3 || items[0].ID != "a" || items[1].ID != "c" || items[2].ID != "d" {
+		t.Fatal(items)
+	}
+}
@@ -1,47 +1,338 @@
 /*
 	Simple in-memory implementation of Pub/Sub with polling an approach. You can use this package for building pub/sub
 	systems where the main method of obtaining data is poling (like cases such as with http). Messages are saved until the
-	subscriber picks them up. This package uses []byte as "message format".
+	subscriber picks them up. This package uses []byte as "message format", Message envelope adds headers and metadata.
+	Published slices are not copied, caller must not modify them after publish unless WithCopyOnPublish is used.
 
 	Each subscription stores an slice of pointers to messages (no copy - just pointers).
 	Storage complexity: messages: O(n) + pointers: O(k*n) where
 	n - number of messages,
 	k - number of subscribers
 	Also topic name and subscriber name must take part in complexity calculation
+
+	Wildcards. Topic names are split into levels by "/". Subscription topic may contain MQTT-style wildcards:
+	"+" matches exactly one level ("orders/+/created" matches "orders/1/created"),
+	"#" must be the last level and matches any number of levels including parent one ("orders/#" matches "orders",
+	"orders/1" and "orders/1/created"). Wildcards at the first level don't match topics starting with "$", they are
+	matched by patterns starting with the same level ("$SYS/#").
+	Wildcards must occupy a whole level, otherwise ("orders+", "a/#/b") topic name is treated literally.
+	Messages published to a topic are delivered to subscriptions of the topic and of all wildcard topics matching it.
 */
 package pubsub
 
 import (
+	"context"
 	"errors"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Error happens only if subscription not exist already
+// Methods return more specific ErrTopicNotFound, ErrSubscriptionNotFound or ErrClosed,
+// errors.Is(err, ErrNoSubscriptions) is true for all of them
 var ErrNoSubscriptions = errors.New("there are no subscriptions")
 
-// Storage for messages (something like FIFO stack)
-type sliceStorage [][]byte
+var (
+	// Error happens if topic doesn't exist
+	ErrTopicNotFound error = noSubscriptionsError("topic not found")
+	// Error happens if topic exists, but subscription doesn't
+	ErrSubscriptionNotFound error = noSubscriptionsError("subscription not found")
+)
+
+// Error which matches ErrNoSubscriptions with errors.Is, so callers checking it keep working
+type noSubscriptionsError string
+
+func (e noSubscriptionsError) Error() string { return string(e) }
+
+func (e noSubscriptionsError) Is(target error) bool { return target == ErrNoSubscriptions }
+
+// Single subscription: pending messages plus condition variable for waiting pollers
+// mux - lock of publishers and pollers, state of subscription is changed either under topic.mux held for writing or
+// under topic.mux held for reading plus mux (see Lock), so pollers of different subscriptions don't wait for each other
+// and publishers wait only for pollers of the subscription they deliver to
+// topic - the parent subscriptions list, cond uses the subscription itself as a locker
+// inFlight - messages polled with PollAck and not acknowledged yet (or waiting for redelivery, see Options.Backoff),
+// lastToken - last issued AckToken
+// cancel - stops goroutines of SubscribeChan and SubscribeFunc, nil for polling subscriptions
+// sn, dlq - name of subscription and subscriptions list of its dead-letter topic, dlq is nil if MaxDeliveries isn't set
+// lastPoll, waiters - time of the last poll and number of PollWait calls waiting now, used for idle expiration
+// delivered, dropped - counters for Stats (accessed atomically), placed first to be 64-bit aligned
+// log - logger with topic and subscription attributes, slow - "subscription is full" warning was logged already
+// dead - messages exceeded MaxDeliveries under Lock, they are moved to dead-letter topic by Unlock
+// pollLimit - poll rate limit of Options.PollRate, nil if unlimited
+// busyKeys - ordering keys of messages in flight, messages with these keys are held back (see PublishWithKey)
+// conflated - pending message of every key of Options.Conflate subscription, entries of taken messages are stale
+// sampleN - number of messages counted by Options.SampleEvery
+// paused, pauseDrop - subscription is paused by Pause and drops new messages meanwhile
+// lagging - subscription exceeds thresholds of watchdog, OnSlow was called already (see WithWatchdog)
+type subscription struct {
+	delivered uint64
+	dropped   uint64
+	storage
+	mux       sync.Mutex
+	cond      *sync.Cond
+	inFlight  map[AckToken]*inFlight
+	lastToken AckToken
+	opts      Options
+	cancel    context.CancelFunc
+	sn        string
+	topic     *subscriptions
+	dlq       *subscriptions
+	lastPoll  time.Time
+	waiters   int
+	log       *slog.Logger
+	slow      bool
+	dead      []item
+	pollLimit *bucket
+	busyKeys  map[string]bool
+	conflated map[string]*message
+	sampleN   int
+	paused    bool
+	pauseDrop bool
+	lagging   bool
+}
+
+// Creates an empty subscription with name (sn) of subscriptions list (topic), it isn't added to the list here
+func newSubscription(sn string, topic *subscriptions) *subscription {
+	s := &subscription{
+		sn:       sn,
+		topic:    topic,
+		lastPoll: topic.clock.Now(),
+		log:      topic.log.With("subscription", sn),
+	}
+	s.cond = sync.NewCond(s)
+	s.storage = s.emptyStorage()
+	return s
+}
+
+// Locking subscription for polling: its topic is locked for reading, so changes of the topic wait,
+// and the subscription for writing
+func (s *subscription) Lock() {
+	s.topic.mux.RLock()
+	s.mux.Lock()
+}
+
+// Unlocking subscription locked by Lock, messages which exceeded Options.MaxDeliveries meanwhile are moved to
+// dead-letter topic after that (see deadLetter)
+func (s *subscription) Unlock() {
+	dead, dlq := s.dead, s.dlq
+	s.dead = nil
+	s.mux.Unlock()
+	s.topic.mux.RUnlock()
+	if len(dead) > 0 {
+		s.deadLetter(dlq, dead)
+	}
+}
+
+// Checks if RejectPublish subscription has no room for message (m), warning is logged then
+func (s *subscription) rejects(m *message) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.overflows(m) && s.accepts(m) {
+		s.warnSlow()
+		return true
+	}
+	return false
+}
+
+// Take the oldest not expired message, expired messages on the way are dropped
+// Messages which ordering key is in flight (see PublishWithKey) are skipped and stay in their places
+// false should be returned if there are no such messages
+func (s *subscription) next(now time.Time) (item, bool) {
+	s.lastPoll = now
+	var skipped []item
+	if len(s.busyKeys) > 0 {
+		defer func() { s.unskip(skipped) }()
+	}
+	for s.len() > 0 {
+		it, _ := s.take()
+		if it.expired(now) {
+			atomic.AddUint64(&s.dropped, 1)
+			s.log.Debug("message dropped", "id", it.message, "reason", "expired")
+			s.emit(EventMessageDropped, it.message, 1, "expired")
+			it.release()
+			continue
+		}
+		if !s.ready(it) {
+			skipped = append(skipped, it)
+			continue
+		}
+		atomic.AddUint64(&s.delivered, 1)
+		if s.slow && s.len() <= s.limit()/2 {
+			s.slow = false
+		}
+		return it, true
+	}
+	return item{}, false
+}
+
+// Take up to max oldest not expired messages, expired messages on the way are dropped
+// Result is allocated once for all pending messages, nil is returned if there are no messages
+// Taking stops at message which body can't be decoded, error is returned only if no messages were taken
+func (s *subscription) nextN(max int, now time.Time) ([][]byte, error) {
+	if n := s.len(); n < max {
+		max = n
+	}
+	if max <= 0 {
+		return nil, nil
+	}
+	msgs := make([][]byte, max)
+	n, err := s.nextInto(msgs, now)
+	if n > 0 {
+		return msgs[:n], nil
+	}
+	return nil, err
+}
 
-// Add message (b) to the end of slice
-func (s *sliceStorage) add(b []byte) {
-	*s = append(*s, b)
+// Take up to len(buf) oldest not expired messages into (buf), expired messages on the way are dropped
+// Returns number of taken messages, taking stops at message which body can't be decoded (err)
+func (s *subscription) nextInto(buf [][]byte, now time.Time) (int, error) {
+	n := 0
+	for n < len(buf) {
+		it, ok := s.next(now)
+		if !ok {
+			break
+		}
+		b, err := s.consumeBody(it)
+		if err != nil {
+			return n, err
+		}
+		buf[n] = b
+		n++
+	}
+	return n, nil
+}
+
+// Creates storage of subscription according to Options.Priority and TopicConfig.Priority
+func (s *subscription) emptyStorage() storage {
+	return newStorage(s.priority(), s.topic.meters)
 }
 
-// Take a "oldest" message from slice and remove it from slice
-func (s *sliceStorage) take() []byte {
-	if len(*s) > 0 {
-		msg := (*s)[0]
-		*s = (*s)[1:]
-		return msg
+// Replacing storage of subscription with (st), items of the old one are thrown away
+func (s *subscription) replace(st storage) {
+	s.storage.release()
+	s.storage = st
+}
+
+// Rebuilding storage if its ordering doesn't match Options.Priority or TopicConfig.Priority anymore
+// Pending items are moved to the new storage keeping their order
+func (s *subscription) syncStorage() {
+	if _, ok := s.storage.(*priorityStorage); ok != s.priority() {
+		st := s.emptyStorage()
+		for _, it := range s.items() {
+			st.pushBack(it)
+		}
+		s.replace(st)
 	}
-	return nil
 }
 
-// List of subscriptions protected by mutex
-// hm - hashmap where key is subscription name (sn) and value - a list of messages for this subscription name
+// Releasing resources of removed subscription and waking up everybody who waits for it
+func (s *subscription) close() {
+	s.storage.release()
+	if u := s.topic.ns; u != nil {
+		atomic.AddInt64(&u.subscriptions, -1)
+	}
+	s.dropInFlight()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.cond.Broadcast()
+	s.emit(EventSubscriptionDeleted, nil, 0, "")
+}
+
+// List of subscriptions protected by RW mutex, held for writing by changes of the topic and for reading by publishers
+// and pollers which lock subscriptions one by one (see lockPublish and subscription.Lock)
+// pub - serializes publishers of the topic, state written by publish (lastSeq, history, seen, latest,
+// publishLimit) is protected by pub together with mux held for reading or by mux held for writing
+// tn - topic name, hm - hashmap where key is subscription name (sn) and value - a list of messages for this subscription name
+// lastSeq - sequence number of the last message published to the topic
+// history - last historySize messages published to the topic, used by SubscribeFrom
+// refs - number of subscriptions using the topic as dead-letter topic
+// deleted - list was removed from topics of broker, who locked it after that must look up the topic again
+// published - counter of messages published to the topic for Stats (accessed atomically)
+// log - logger with topic attribute
+// config - settings of topic created with CreateTopic or ConfigureTopic, such topic isn't removed when it becomes empty;
+// replaced as a whole under both pubSub.mux and mux, so publishers read it without locks
+// meters - counters of pending bytes passed to storages: of broker if WithMaxMemory is used and of namespace
+// if NamespaceQuota.MaxMemory is set
+// ns - usage of namespace of the topic, nil if namespace quota isn't set or topic isn't in a namespace
+// publishLimit - publish rate limit of TopicConfig.PublishRate, nil if unlimited
+// seen, seenOrder - producer-supplied IDs of messages published within TopicConfig.DedupWindow with time of publish,
+// seenOrder keeps them in order of publish
+// latest - the latest message of every key of compacted topic (see TopicConfig.Compact)
+// nextPartition - counter spreading messages without key between partitions (accessed atomically)
+// clock, events - clock and listeners of events of broker
+// maxMessages - limit of pending messages of broker (see WithDefaultMaxMessages)
 type subscriptions struct {
-	mux sync.Mutex
-	hm  map[string]*sliceStorage
+	published     uint64
+	nextPartition uint32
+	mux           sync.RWMutex
+	pub           sync.Mutex
+	tn            string
+	hm            map[string]*subscription
+	lastSeq       uint64
+	history       []*message
+	historySize   int
+	refs          int
+	deleted       atomic.Bool
+	log           *slog.Logger
+	config        atomic.Pointer[TopicConfig]
+	meters        []*int64
+	ns            *namespaceUsage
+	publishLimit  *bucket
+	seen          map[string]time.Time
+	seenOrder     []seenID
+	latest        map[string]*message
+	clock         Clock
+	events        *eventHub
+	maxMessages   int
+}
+
+// Locking subscriptions list for publishing: other publishers of the topic and its changes wait, but pollers don't
+// Subscriptions must be locked one by one by their mux then
+func (s *subscriptions) lockPublish() {
+	s.pub.Lock()
+	s.mux.RLock()
+}
+
+// Unlocking subscriptions list locked by lockPublish
+func (s *subscriptions) unlockPublish() {
+	s.mux.RUnlock()
+	s.pub.Unlock()
+}
+
+// Checks if topic may be removed: nobody subscribed, history is disabled, no dead-letter references
+// and topic wasn't created with CreateTopic
+// s.mux must be held by caller
+func (s *subscriptions) empty() bool {
+	return len(s.hm) == 0 && s.historySize == 0 && s.refs == 0 && s.config.Load() == nil
+}
+
+// Returns subscription by name (sn), creates it if not exist before
+// s.mux must be held by caller
+func (s *subscriptions) ensure(sn string) (*subscription, bool) {
+	sub, ok := s.hm[sn]
+	if !ok {
+		sub = newSubscription(sn, s)
+		s.insert(sub)
+		sub.log.Info("subscribed")
+		sub.emit(EventSubscriptionCreated, nil, 0, "")
+	}
+	return sub, !ok
+}
+
+// Adding new subscription (sub) to the list, it's counted against namespace quota
+// s.mux must be held by caller
+func (s *subscriptions) insert(sub *subscription) {
+	s.hm[sub.sn] = sub
+	if s.ns != nil {
+		atomic.AddInt64(&s.ns.subscriptions, 1)
+	}
 }
 
 // PubSuber interface helps to hide `pubSub` from direct access/initialization and make ability to
@@ -49,92 +340,771 @@ type subscriptions struct {
 type PubSuber interface {
 	// Publish message
 	Publish(tn string, b []byte)
+	// Publish message and report if it was rejected by some subscription
+	TryPublish(tn string, b []byte) error
+	// Publish message and report how many subscriptions received it
+	PublishResult(tn string, b []byte) (int, error)
+	// Publish messages to several topics atomically
+	PublishTx(fn func(tx Tx) error) error
+	// Publish message with headers, returns message ID
+	PublishMsg(tn string, msg Message) (string, error)
+	// Publish message after delay
+	PublishAfter(tn string, b []byte, delay time.Duration)
+	// Publish message at the given time
+	PublishAt(tn string, b []byte, at time.Time)
+	// Publish message which is delivered before messages with lower priority
+	PublishWithPriority(tn string, b []byte, prio int) error
+	// Publish message which is also kept as the last value of topic for new subscribers
+	PublishRetained(tn string, b []byte) error
+	// Publish message which is dropped if nobody polls it during time-to-live
+	PublishWithTTL(tn string, b []byte, ttl time.Duration) error
+	// Publish message which is delivered in order with other messages of the same key
+	PublishWithKey(tn string, key string, b []byte)
 	// Subscribe for messages by topic and subscription name
 	Subscribe(tn, sn string)
+	// Subscribe for messages by topic and subscription name starting from some position of topic history
+	SubscribeFrom(tn, sn string, from SeekPosition) error
+	// Rewinding or skipping messages of subscription to sequence number
+	Seek(tn, sn string, seq uint64) error
+	// Keeping last n messages of topic for SubscribeFrom
+	SetHistory(tn string, n int)
+	// Subscribe for messages by topic and subscription name with limits for pending messages
+	SubscribeWithOptions(tn, sn string, opts Options)
+	// Subscribe for messages by topic and subscription name which are pushed into returned channel
+	SubscribeChan(tn, sn string, buf int) (<-chan []byte, error)
+	// Subscribe for messages by topic and subscription name which are passed to fn by pool of goroutines
+	SubscribeFunc(tn, sn string, fn func([]byte), opts ...HandlerOption) error
 	// Unsubscribe for messages by topic and subscription name
 	Unsubscribe(tn, sn string)
+	// Dropping all pending messages of subscription
+	PurgeSubscription(tn, sn string) (int, error)
+	// Dropping all pending messages of all subscriptions of topic
+	PurgeTopic(tn string) (int, error)
+	// Creating topic with settings
+	CreateTopic(tn string, cfg TopicConfig) error
+	// Changing settings of topic
+	ConfigureTopic(tn string, cfg TopicConfig) error
+	// Settings of topic
+	TopicConfig(tn string) (TopicConfig, error)
+	// Handle of topic publishing to it without looking it up by name
+	Topic(tn string) *Topic
+	// Removing topic with all its subscriptions, history and retained message
+	DeleteTopic(tn string)
 	// Fetching messages for topic name (tn) and subscriber name (sn)
 	Poll(tn, sn string) ([]byte, error)
+	// Fetching message with headers and metadata for topic name (tn) and subscriber name (sn)
+	PollMsg(tn, sn string) (*Message, error)
+	// Fetching up to max messages for topic name (tn) and subscriber name (sn) in one call
+	PollN(tn, sn string, max int) ([][]byte, error)
+	// Fetching as many whole messages as fit into maxBytes bytes of bodies, at least one if there are messages
+	PollBytes(tn, sn string, maxBytes int) ([][]byte, error)
+	// Waiting for a message for topic name (tn) and subscriber name (sn) until it arrives or ctx is done
+	PollWait(ctx context.Context, tn, sn string) ([]byte, error)
+	// Waiting for a message with headers and metadata until it arrives or ctx is done
+	PollMsgWait(ctx context.Context, tn, sn string) (*Message, error)
+	// Iterating over messages with PollWait until ctx is done, for range-over-func loops
+	Iter(ctx context.Context, tn, sn string) iter.Seq2[[]byte, error]
+	// Fetching message which stays in flight until Ack is called or visibility timeout expires
+	PollAck(tn, sn string, visibility time.Duration) ([]byte, AckToken, error)
+	// Fetching message with headers and metadata which stays in flight until Ack is called
+	PollAckMsg(tn, sn string, visibility time.Duration) (*Message, AckToken, error)
+	// Acknowledging message polled with PollAck
+	Ack(tn, sn string, token AckToken) error
+	// Returning message polled with PollAck to the queue
+	Nack(tn, sn string, token AckToken, requeue bool) error
+	// Extending visibility timeout of message polled with PollAck
+	ExtendAck(tn, sn string, token AckToken, visibility time.Duration) error
+	// Fetching message moved to dead-letter topic after Options.MaxDeliveries deliveries
+	PollDLQ(tn, sn string) (*Message, error)
+	// Moving dead letters back to the subscription
+	Redrive(tn, sn string) (int, error)
+	// List of topic names
+	Topics() []string
+	// List of subscription names of topic
+	Subscriptions(tn string) ([]string, error)
+	// Number of pending messages of subscription
+	Depth(tn, sn string) (int, error)
+	// Number of messages of subscription polled with PollAck and not acknowledged yet
+	InFlight(tn, sn string) (int, error)
+	// Fetching message without removing it
+	Peek(tn, sn string) ([]byte, error)
+	// Fetching several messages without removing them
+	PeekN(tn, sn string, max int) ([][]byte, error)
+	// Counters of all topics and subscriptions
+	Stats() BrokerStats
+	// Summary of memory usage, backlog and health checks
+	Health() HealthReport
+	// Adding middleware wrapping publish and poll methods
+	Use(mw Middleware)
+	// Setting validator of messages published to topic
+	SetValidator(tn string, v Validator) error
+	// Publishing messages of one topic to another one, optionally transformed
+	Forward(src, dst string, transform func([]byte) ([]byte, bool)) error
+	// Removing rule added by Forward
+	Unforward(src, dst string)
+	// Pausing polls of subscription, messages keep accumulating or are dropped
+	Pause(tn, sn string, drop bool) error
+	// Resuming subscription paused by Pause
+	Resume(tn, sn string) error
+	// Channel of events of topics and subscriptions lifecycle, dropped messages and slow subscribers until ctx is done
+	Events(ctx context.Context) <-chan Event
+	// Writing all topics, subscriptions and pending messages
+	Snapshot(w io.Writer) error
+	// Rejecting new messages, waiting for queues to drain and releasing all resources
+	Close(ctx context.Context) error
+	// Moving time of broker with ManualClock and running background work which time has come
+	Advance(d time.Duration) error
+	// Reading topics, subscriptions and pending messages written by Snapshot
+	Restore(r io.Reader) error
+	// Facade isolating topics of a tenant
+	Namespace(name string) PubSuber
+	// Facade checking every call of principal with Authorizer
+	As(principal any) PubSuber
+	// Publishing request and waiting for response of Responder
+	Request(ctx context.Context, tn string, b []byte) ([]byte, error)
+	// Web UI with live tables of topics and subscriptions and a tail view of new messages
+	AdminHandler() http.Handler
 }
 
-// List of subscriptions protected by RW mutex
-// RW mutex used because access to `hm` not always means write operations
+// Topics of broker spread between buckets of topicMap, every bucket has its own lock
+// mux - held for writing when topics are added or removed, protects wildcards and namespaces
+// lastID - counter used for message IDs (accessed atomically), idPrefix - random prefix of IDs unique per broker
+// wildcards - index of wildcard topics, protected by mux; patterns - number of topics in it (accessed atomically),
+// so publishers don't take mux if there are no wildcard subscriptions
+// sweepEvery - how often expired messages are removed by background sweeper started by first PublishWithTTL
+// sched - messages of PublishAfter/PublishAt waiting for their time, protected by schedMux
+// retained - last retained message per topic name, protected by retainMux
+// log - logger of broker events, discards everything unless WithLogger is used
+// chain - middlewares added by Use, replaced as a whole (copy on write) under chainMux
+// copyOnPublish - published body and headers are copied (see WithCopyOnPublish)
+// strict - topics must be created with CreateTopic (see WithStrictTopics)
+// noSubsError - publishing methods report messages which no subscription received (see WithNoSubscribersError)
+// nsQuota - quota of every namespace, namespaces - usage of namespaces by prefix, protected by mux
+// closing - Close was called, publishing is rejected; closed - Close finished, everything is rejected
+// done - closed by Close to stop background goroutines
+// memory - total size of pending messages of all subscriptions (accessed atomically), counted only if maxMemory is set
+// maxMemory, memoryPolicy - memory budget and what to do when it's exceeded (see WithMaxMemory)
+// authorizer - checks calls of facades returned by As (see WithAuthorizer)
+// compression - some topic was configured with TopicConfig.Compression
+// enc - encryption of message bodies, nil unless WithEncryption is used
+// validators - validators set by SetValidator, replaced as a whole (copy on write) under validatorsMux
+// snapshotCodec - codec of Snapshot and Restore, nil means gob (see WithSnapshotCodec)
+// clock - source of time (see WithClock)
+// forwards - rules added by Forward, replaced as a whole (copy on write) under forwardsMux
+// watchdog - thresholds of slow subscriptions checked by sweeper, nil unless WithWatchdog is used
+// events - listeners of Events
+// audit - sink of operations of facades returned by As, nil unless WithAudit is used
+// checks - checks of Health added by WithHealthCheck
+// labels - publishing and polling are labeled for profiler (see WithProfilerLabels)
+// maxMessages - limit of pending messages of subscriptions without own or topic limit (see WithDefaultMaxMessages)
+// emptyError - non-waiting polls report empty subscription with ErrEmpty (see WithEmptyError)
 type pubSub struct {
-	mux sync.RWMutex
-	hm  map[string]*subscriptions
+	lastID        uint64
+	memory        int64
+	idPrefix      string
+	mux           sync.RWMutex
+	topics        topicMap
+	wildcards     topicTree
+	patterns      atomic.Int32
+	sweepEvery    time.Duration
+	sweepOnce     sync.Once
+	schedMux      sync.Mutex
+	sched         schedule
+	schedSeq      uint64
+	schedTimer    Timer
+	retainMux     sync.Mutex
+	retained      map[string]*message
+	log           *slog.Logger
+	chainMux      sync.Mutex
+	chain         atomic.Pointer[chain]
+	copyOnPublish bool
+	strict        bool
+	noSubsError   bool
+	emptyError    bool
+	closing       atomic.Bool
+	closed        atomic.Bool
+	done          chan struct{}
+	maxMemory     int64
+	memoryPolicy  Overflow
+	nsQuota       NamespaceQuota
+	namespaces    map[string]*namespaceUsage
+	authorizer    Authorizer
+	compression   atomic.Bool
+	enc           *encryption
+	validatorsMux sync.Mutex
+	validators    atomic.Pointer[validators]
+	snapshotCodec Codec
+	clock         Clock
+	forwardsMux   sync.Mutex
+	forwards      atomic.Pointer[[]forwardRule]
+	watchdog      *Watchdog
+	events        eventHub
+	audit         AuditSink
+	checks        []healthCheck
+	labels        bool
+	maxMessages   int
 }
 
+// Option configures PubSuber created by New
+type Option func(*pubSub)
+
 // Publish message (b) by topic name (tn) if have subscriptions already
+// Message is silently dropped if some subscription with RejectPublish policy is full, use TryPublish to know that
 // Complexity: O(N+1)
 func (p *pubSub) Publish(tn string, b []byte) {
-	p.mux.RLock()
-	subs, ok := p.hm[tn]
-	p.mux.RUnlock()
-	if ok {
-		subs.mux.Lock()
+	_ = p.TryPublish(tn, b)
+}
+
+// Publish message (b) by topic name (tn) if have subscriptions already
+// ErrQueueFull raises (and message isn't delivered to any subscription) if some subscription
+// with RejectPublish policy is full
+// Complexity: O(2N+1)
+func (p *pubSub) TryPublish(tn string, b []byte) error {
+	_, err := p.publishReleased(tn, p.newMessage(tn, Message{Body: b}))
+	return err
+}
+
+// Buffers of deliver targets reused between publishes
+var targetsPool = sync.Pool{
+	New: func() interface{} {
+		return new([]*subscriptions)
+	},
+}
+
+// Returning buffer of deliver targets (buf) to the pool, pointers are cleared so removed topics may be collected
+func releaseTargets(buf *[]*subscriptions) {
+	clear(*buf)
+	*buf = (*buf)[:0]
+	targetsPool.Put(buf)
+}
+
+// Delivering message (m) to all subscriptions of topic name (tn) and of wildcard topics matching it,
+// one pointer is shared by all of them, returns number of subscriptions which received the message
+// Subscriptions lists are locked in order of topic names to avoid deadlocks between concurrent publishers
+func (p *pubSub) deliver(tn string, m *message) (int, error) {
+	if err := p.seal(tn, m); err != nil {
+		return 0, err
+	}
+	subs := p.lookup(tn, m.via)
+	m.via = nil
+	if p.strict && (subs == nil || subs.config.Load() == nil) {
+		return 0, ErrTopicNotFound
+	}
+	if p.maxMemory > 0 && p.memoryPolicy == DropOldest {
+		// runs after topics are unlocked
+		defer p.reclaim()
+	}
+	buf := targetsPool.Get().(*[]*subscriptions)
+	defer releaseTargets(buf)
+	targets := p.route(tn, subs, m, (*buf)[:0])
+	*buf = targets
+	ns := p.usage(tn)
+	if len(targets) == 0 {
+		return 0, nil
+	}
+	for _, subs := range targets {
+		subs.lockPublish()
+	}
+	defer func() {
+		for _, subs := range targets {
+			subs.unlockPublish()
+		}
+	}()
+	now := p.now()
+	dedup := dedupOf(tn, targets)
+	if dedup.duplicate(m, now) {
+		dedup.log.Debug("message dropped", "id", m, "reason", "duplicate")
+		return 0, errDuplicate
+	}
+	for _, subs := range targets {
 		for _, sub := range subs.hm {
-			sub.add(b)
+			if sub.opts.Filter != nil {
+				// filters see ID of message and may keep it, so it isn't reused
+				m.formatID()
+				m.pin()
+			}
+			if sub.opts.Overflow == RejectPublish && sub.rejects(m) {
+				return 0, ErrQueueFull
+			}
 		}
-		subs.mux.Unlock()
 	}
+	if p.maxMemory > 0 && p.memoryPolicy != DropOldest && !p.fits(targets, m) {
+		return 0, ErrMemoryLimit
+	}
+	if !ns.fits(targets, m) {
+		p.log.Warn("namespace quota is exceeded", "topic", tn, "max_memory", ns.quota.MaxMemory)
+		return 0, ErrQuotaExceeded
+	}
+	for _, subs := range targets {
+		if subs.tn == tn && !subs.publishLimit.allow(now) {
+			subs.log.Debug("message rejected", "id", m, "reason", "rate limit")
+			return 0, ErrRateLimited
+		}
+	}
+	dedup.remember(m, now)
+	return p.push(tn, m, targets), nil
+}
+
+// Adding message (m) published to topic name (tn) to subscriptions lists (targets), returns number of subscriptions
+// which received it
+// Subscriptions lists must be locked by caller
+func (p *pubSub) push(tn string, m *message, targets []*subscriptions) int {
+	for _, subs := range targets {
+		if subs.tn != tn {
+			continue
+		}
+		cfg := subs.config.Load()
+		if subs.historySize > 0 || cfg != nil && (cfg.Compact || cfg.RetainLast) {
+			// message is added to subscriptions later, their filters see its ID
+			m.formatID()
+		}
+		if cfg != nil {
+			p.applyConfig(cfg, m)
+		}
+	}
+	n := 0
+	for _, subs := range targets {
+		if subs.tn == tn {
+			atomic.AddUint64(&subs.published, 1)
+			subs.lastSeq++
+			m.Seq = subs.lastSeq
+			subs.record(m)
+			subs.compact(m)
+		}
+		// subscriptions are locked one by one, so pollers of others don't wait
+		for _, sub := range subs.hm {
+			sub.mux.Lock()
+			ok := sub.push(m)
+			sub.mux.Unlock()
+			if ok {
+				sub.cond.Broadcast()
+				n++
+			}
+		}
+	}
+	return n
 }
 
 // Subscribe to message by topic name (tn) and subscriber name (sn)
-// Creates new topic if not exist before
+// Creates new topic if not exist before. Topic name may be a wildcard pattern (see package doc)
+// In strict mode (see WithStrictTopics) subscription to a topic which wasn't created with CreateTopic is ignored
 func (p *pubSub) Subscribe(tn, sn string) {
-	p.mux.Lock()
-	defer p.mux.Unlock()
-	if subs, ok := p.hm[tn]; !ok {
-		p.hm[tn] = &subscriptions{
-			hm: map[string]*sliceStorage{sn: &sliceStorage{}},
+	p.subscribe(tn, sn, nil)
+}
+
+// Subscribe to message by topic name (tn) and subscriber name (sn) with options
+// Creates new topic if not exist before, options of existing subscription are replaced
+func (p *pubSub) SubscribeWithOptions(tn, sn string, opts Options) {
+	p.subscribe(tn, sn, &opts)
+}
+
+// Returns locked subscriptions list of topic name (tn), creates new topic if not exist before
+// Caller must unlock it. ErrQuotaExceeded raises if topic can't be created because of its namespace quota
+func (p *pubSub) lockTopic(tn string) (*subscriptions, error) {
+	for {
+		subs, err := p.ensureTopic(tn)
+		if err != nil {
+			return nil, err
 		}
-	} else {
 		subs.mux.Lock()
-		if _, ok := subs.hm[sn]; !ok {
-			subs.hm[sn] = &sliceStorage{}
+		if !subs.deleted.Load() {
+			return subs, nil
 		}
+		// topic was removed between lookup and locking
 		subs.mux.Unlock()
 	}
 }
 
+// Returns subscriptions list of topic name (tn), creates new topic if not exist before
+// Wildcard topics are added to the index used by publish
+func (p *pubSub) ensureTopic(tn string) (*subscriptions, error) {
+	if subs, ok := p.topics.get(tn); ok {
+		return subs, nil
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		if err := p.admitTopic(tn); err != nil {
+			return nil, err
+		}
+		subs = p.newTopic(tn)
+	}
+	return subs, nil
+}
+
+// Adding empty subscriptions list of topic name (tn), p.mux must be held by caller
+func (p *pubSub) newTopic(tn string) *subscriptions {
+	subs := &subscriptions{tn: tn, hm: map[string]*subscription{}, log: p.log.With("topic", tn), clock: p.clock,
+		events: &p.events, maxMessages: p.maxMessages}
+	if p.maxMemory > 0 {
+		subs.meters = append(subs.meters, &p.memory)
+	}
+	if subs.ns = p.usageOf(tn, true); subs.ns != nil {
+		subs.ns.topics++
+		if subs.ns.quota.MaxMemory > 0 {
+			subs.meters = append(subs.meters, &subs.ns.memory)
+		}
+	}
+	p.topics.add(subs)
+	if isPattern(tn) {
+		p.wildcards.insert(tn)
+		p.patterns.Add(1)
+	}
+	p.emitTopic(EventTopicCreated, subs)
+	return subs
+}
+
+// Creates subscription if not exist before and sets its options if opts isn't nil
+// Subscription of dead-letter topic is created too if MaxDeliveries is set
+func (p *pubSub) subscribe(tn, sn string, opts *Options) {
+	if p.closed.Load() {
+		return
+	}
+	if !p.subscribable(tn) {
+		p.log.Warn("subscription to unknown topic is ignored", "topic", tn, "subscription", sn)
+		return
+	}
+	var dlq *subscriptions
+	if opts != nil && opts.MaxDeliveries > 0 {
+		// reference keeps dead-letter topic from removal until subscription is removed
+		var err error
+		if dlq, err = p.lockTopic(DeadLetterTopic(tn)); err != nil {
+			p.log.Warn("subscription is ignored", "topic", tn, "subscription", sn, "error", err)
+			return
+		}
+		_, _ = dlq.ensure(sn)
+		dlq.refs++
+		dlq.mux.Unlock()
+	}
+	subs, err := p.lockSubscribable(tn)
+	if err == nil {
+		if err = subs.admit(sn); err != nil {
+			subs.mux.Unlock()
+		}
+	}
+	if err != nil {
+		// topic was deleted concurrently or namespace quota is exceeded
+		p.log.Warn("subscription is ignored", "topic", tn, "subscription", sn, "error", err)
+		if dlq != nil {
+			p.unrefDLQ(dlq, sn)
+		}
+		return
+	}
+	defer subs.mux.Unlock()
+	sub, created := subs.ensure(sn)
+	if opts != nil {
+		sub.opts = *opts
+		sub.syncStorage()
+		sub.conflated = nil
+		sub.pollLimit = newBucket(opts.PollRate, opts.PollBurst)
+		if opts.IdleTimeout > 0 {
+			p.startSweeper()
+		}
+		if sub.dlq != nil {
+			sub.releaseDLQ()
+		}
+		sub.dlq = dlq
+	}
+	if created {
+		p.deliverRetained(tn, sub)
+		subs.deliverCompacted(sub)
+	}
+}
+
 // Unsubscribe by topic name (tn) and subscriber name (sn)
-// @todo implement removing keys from p.hm[tn] when subscription list is empty
+// Pollers waiting in PollWait are woken up and get ErrSubscriptionNotFound, channel of SubscribeChan is closed
+// Topic is removed when its last subscription is removed (unless it keeps history or is used as dead-letter topic)
+// p.mux is locked for writing, because topic may be removed, lists are always locked after it
 func (p *pubSub) Unsubscribe(tn, sn string) {
-	p.mux.RLock()
-	subs, ok := p.hm[tn]
-	p.mux.RUnlock()
-	if ok {
-		subs.mux.Lock()
-		delete(subs.hm, sn)
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		return
+	}
+	subs.mux.Lock()
+	defer subs.mux.Unlock()
+	if sub, ok := subs.hm[sn]; ok {
+		p.dropSubscription(subs, sub, false)
+	}
+	if subs.empty() {
+		p.removeTopic(subs)
+	}
+}
+
+// Removing topic name (tn) with all its subscriptions (like Unsubscribe for each of them), history and
+// retained message. Topic is created again by the next Subscribe
+func (p *pubSub) DeleteTopic(tn string) {
+	p.retainMux.Lock()
+	delete(p.retained, tn)
+	p.retainMux.Unlock()
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		return
+	}
+	subs.mux.Lock()
+	defer subs.mux.Unlock()
+	for _, sub := range subs.hm {
+		p.dropSubscription(subs, sub, true)
+	}
+	subs.history, subs.historySize = nil, 0
+	p.removeTopic(subs)
+}
+
+// Removing subscription (sub) from list (subs) with its dead-letter subscription,
+// dead-letter subscription with messages is kept unless (purge) is set
+// Dead-letter topic is removed if nobody else uses it
+// p.mux and subs.mux must be held by caller
+func (p *pubSub) dropSubscription(subs *subscriptions, sub *subscription, purge bool) {
+	delete(subs.hm, sub.sn)
+	sub.close()
+	sub.log.Info("unsubscribed")
+	if dlq := sub.dlq; dlq != nil {
+		dlq.mux.Lock()
+		sub.releaseDLQ()
+		if dead, ok := dlq.hm[sub.sn]; ok && (purge || dead.len() == 0) {
+			delete(dlq.hm, sub.sn)
+			dead.close()
+		}
+		if dlq.empty() && !dlq.deleted.Load() {
+			p.removeTopic(dlq)
+		}
+		dlq.mux.Unlock()
+	}
+}
+
+// Rolling back reference to dead-letter topic (dlq) taken by subscribe for subscription name (sn) which wasn't
+// created, empty dead-letter subscription and topic are removed
+func (p *pubSub) unrefDLQ(dlq *subscriptions, sn string) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	dlq.mux.Lock()
+	defer dlq.mux.Unlock()
+	dlq.refs--
+	if dead, ok := dlq.hm[sn]; ok && dead.len() == 0 {
+		delete(dlq.hm, sn)
+		dead.close()
+	}
+	if dlq.empty() && !dlq.deleted.Load() {
+		p.removeTopic(dlq)
+	}
+}
+
+// Removing list (subs) from topics, goroutines which have a pointer to it see `deleted` flag after locking
+// p.mux and subs.mux must be held by caller
+func (p *pubSub) removeTopic(subs *subscriptions) {
+	p.topics.remove(subs.tn)
+	if isPattern(subs.tn) {
+		p.wildcards.remove(subs.tn)
+		p.patterns.Add(-1)
+	}
+	subs.deleted.Store(true)
+	if u := subs.ns; u != nil {
+		if u.topics--; u.topics == 0 {
+			delete(p.namespaces, prefixOf(subs.tn))
+		}
+	}
+	subs.log.Debug("topic removed")
+	p.emitTopic(EventTopicDeleted, subs)
+}
+
+// Looking up subscription by topic name (tn) and subscriber name (sn)
+// On success the subscriptions list of the topic is returned locked, caller must unlock it
+func (p *pubSub) acquire(tn, sn string) (*subscriptions, *subscription, error) {
+	if p.closed.Load() {
+		return nil, nil, ErrClosed
+	}
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		return nil, nil, ErrTopicNotFound
+	}
+	subs.mux.Lock()
+	sub, ok := subs.hm[sn]
+	if !ok {
 		subs.mux.Unlock()
+		return nil, nil, ErrSubscriptionNotFound
 	}
+	return subs, sub, nil
+}
+
+// Looking up subscription like acquire, but on success the subscription is returned locked by its own lock
+// (see subscription.Lock), so pollers of other subscriptions of the topic don't wait. Caller must unlock it
+func (p *pubSub) acquireShared(tn, sn string) (*subscriptions, *subscription, error) {
+	if p.closed.Load() {
+		return nil, nil, ErrClosed
+	}
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		return nil, nil, ErrTopicNotFound
+	}
+	subs.mux.RLock()
+	sub, ok := subs.hm[sn]
+	if !ok {
+		subs.mux.RUnlock()
+		return nil, nil, ErrSubscriptionNotFound
+	}
+	sub.mux.Lock()
+	return subs, sub, nil
 }
 
 // Fetching messages for topic name (tn) and subscriber name (sn)
 // error raises if no subscriptions
-// nil, nil should be returned if all messages was fetched already
+// nil, nil should be returned if all messages was fetched already (ErrEmpty if WithEmptyError is used)
 // Complexity: O(3)
 func (p *pubSub) Poll(tn, sn string) ([]byte, error) {
-	p.mux.RLock()
-	subs, ok := p.hm[tn]
-	p.mux.RUnlock()
-	if !ok {
-		return nil, ErrNoSubscriptions
-	} else {
-		subs.mux.Lock()
-		defer subs.mux.Unlock()
-		if sub, ok := subs.hm[sn]; ok {
-			return sub.take(), nil
-		} else {
-			return nil, ErrNoSubscriptions
+	if p.interceptsPoll() {
+		return bodyOf(p.PollMsg(tn, sn))
+	}
+	sub, err := p.acquireActive(tn, sn)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unlock()
+	if it, ok := sub.next(p.now()); ok {
+		return sub.consumeBody(it)
+	}
+	return nil, p.empty(nil)
+}
+
+// Fetching up to max messages for topic name (tn) and subscriber name (sn) under a single lock
+// error raises if no subscriptions
+// nil, nil should be returned if all messages was fetched already or max is not positive (ErrEmpty in the first case
+// if WithEmptyError is used)
+// Complexity: O(max)
+// Poll middlewares (see Use) are called for every message, subscription is locked for each of them in this case
+func (p *pubSub) PollN(tn, sn string, max int) ([][]byte, error) {
+	if p.interceptsPoll() {
+		var msgs [][]byte
+		for len(msgs) < max {
+			b, err := bodyOf(p.interceptPoll(context.Background(), tn, sn, p.fetch))
+			if err != nil {
+				return msgs, err
+			}
+			if b == nil {
+				break
+			}
+			msgs = append(msgs, b)
+		}
+		if len(msgs) == 0 && max > 0 {
+			return nil, p.empty(nil)
 		}
+		return msgs, nil
+	}
+	sub, err := p.acquireActive(tn, sn)
+	if err != nil {
+		return nil, err
 	}
+	defer sub.Unlock()
+	msgs, err := sub.nextN(max, p.now())
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 && max > 0 {
+		return nil, p.empty(nil)
+	}
+	return msgs, nil
+}
+
+// Waiting for a message for topic name (tn) and subscriber name (sn)
+// Blocks until a message is published, the subscription is removed (ErrSubscriptionNotFound) or ctx is done (ctx.Err())
+// A pending message is returned immediately even if ctx is done already
+func (p *pubSub) PollWait(ctx context.Context, tn, sn string) ([]byte, error) {
+	if p.interceptsPoll() {
+		return bodyOf(p.interceptPoll(ctx, tn, sn, p.wait))
+	}
+	return bodyOf(p.waitMsg(ctx, tn, sn))
+}
+
+// Waiting for a message with headers and metadata for topic name (tn) and subscriber name (sn) like PollWait
+func (p *pubSub) PollMsgWait(ctx context.Context, tn, sn string) (*Message, error) {
+	return p.interceptPoll(ctx, tn, sn, p.wait)
+}
+
+// Waiting for a message without poll middlewares, PollFunc in the end of poll chain of PollWait and PollMsgWait
+func (p *pubSub) wait(ctx context.Context, tn, sn string) (*Message, error) {
+	return p.waitMsg(ctx, tn, sn)
+}
+
+// Waiting for a message for PollWait, message is never nil if error is nil
+func (p *pubSub) waitMsg(ctx context.Context, tn, sn string) (*Message, error) {
+	var msg *Message
+	err := p.await(ctx, tn, sn, func(sub *subscription) (bool, error) {
+		it, ok := sub.nextActive(p.now())
+		if !ok {
+			return false, nil
+		}
+		var err error
+		msg, err = sub.consume(it)
+		return true, err
+	})
+	return msg, err
 }
 
-// Constructor. Creates an instance of PubSuber
+// Waiting until (take) takes a message of subscription (sn) of topic name (tn), take is called with the subscription
+// locked once and then every time it may have a new message
+// Error of take is returned, as well as error of ctx, ErrSubscriptionNotFound if the subscription is removed and
+// ErrClosed if broker is closed meanwhile
+func (p *pubSub) await(ctx context.Context, tn, sn string, take func(sub *subscription) (bool, error)) error {
+	sub, err := p.acquirePoll(tn, sn)
+	if err != nil {
+		return err
+	}
+	defer sub.Unlock()
+	if ok, err := take(sub); ok || err != nil {
+		return err
+	}
+	// wake up waiters when ctx is done, cond.Wait knows nothing about contexts
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.Lock()
+			sub.cond.Broadcast()
+			sub.Unlock()
+		case <-done:
+		}
+	}()
+	// subscription with a waiting poller is never idle
+	sub.waiters++
+	defer func() { sub.waiters-- }()
+	for {
+		if p.closed.Load() {
+			return ErrClosed
+		}
+		if sub.topic.hm[sn] != sub {
+			return ErrSubscriptionNotFound
+		}
+		if ok, err := take(sub); ok || err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sub.cond.Wait()
+	}
+}
+
+// Constructor. Creates an instance of PubSuber configured by options (opts) applied in order, without options
+// the broker has unlimited queues and memory, real clock and no logging. Options are With... functions, e.g.
+// WithCopyOnPublish, WithMaxMemory, WithDefaultMaxMessages, WithClock and WithLogger
 // Using a PubSuber interface instead of a pointer to pubSub guarantees the using of this constructor in other packages
-func New() PubSuber {
-	return &pubSub{
-		hm: map[string]*subscriptions{},
+func New(opts ...Option) PubSuber {
+	p := &pubSub{
+		idPrefix:   newIDPrefix(),
+		sweepEvery: defaultSweepInterval,
+		log:        nopLogger,
+		done:       make(chan struct{}),
+		clock:      realClock{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.watchdog != nil {
+		p.startSweeper()
 	}
+	return p
 }
@@ -1,8 +1,13 @@
 package pubsub
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -28,10 +33,87 @@ func TestPubSub_Unsubscribe(t *testing.T) {
 	lib.Publish(tn, []byte("message"))
 	lib.Unsubscribe(tn, sn)
 	lib.Unsubscribe(tn, sn2)
-	if _, err := lib.Poll(tn, sn); err != ErrNoSubscriptions {
+	if _, err := lib.Poll(tn, sn); !errors.Is(err, ErrNoSubscriptions) {
 		t.FailNow()
 	}
-	if _, err := lib.Poll(tn, sn2); err != ErrNoSubscriptions {
+	if _, err := lib.Poll(tn, sn2); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_NotFoundErrors(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	if _, err := lib.Poll(tn, "subscriber/id"); err != ErrTopicNotFound || !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, "subscriber/id")
+	if _, err := lib.Poll(tn, "unknown"); err != ErrSubscriptionNotFound || !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	if _, err := lib.Subscriptions("unknown"); err != ErrTopicNotFound {
+		t.FailNow()
+	}
+	if !errors.Is(ErrClosed, ErrNoSubscriptions) || errors.Is(ErrTopicNotFound, ErrSubscriptionNotFound) {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Unsubscribe_RemovesTopic(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	lib.Subscribe(tn, "a")
+	lib.Subscribe(tn, "b")
+	lib.SubscribeWithOptions(tn, "c", Options{MaxDeliveries: 1})
+	lib.Unsubscribe(tn, "a")
+	lib.Unsubscribe(tn, "c")
+	if topics := lib.Topics(); len(topics) != 1 || topics[0] != tn {
+		t.FailNow()
+	}
+	lib.Unsubscribe(tn, "b")
+	if len(lib.Topics()) != 0 {
+		t.FailNow()
+	}
+	lib.Subscribe("a/+", "a")
+	lib.Unsubscribe("a/+", "a")
+	lib.Subscribe("a/b", "b")
+	lib.Publish("a/b", []byte("message"))
+	if depth, _ := lib.Depth("a/b", "b"); depth != 1 {
+		t.FailNow()
+	}
+	if topics := lib.Topics(); len(topics) != 1 || topics[0] != "a/b" {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_DeleteTopic(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.DeleteTopic(tn)
+	lib.SubscribeWithOptions(tn, sn, Options{MaxDeliveries: 1})
+	lib.SetHistory(tn, 10)
+	if err := lib.PublishRetained(tn, []byte("message")); err != nil {
+		t.FailNow()
+	}
+	done := make(chan error)
+	go func() {
+		_, err := lib.PollWait(context.Background(), tn, "waiter")
+		done <- err
+	}()
+	lib.Poll(tn, sn)
+	lib.DeleteTopic(tn)
+	if len(lib.Topics()) != 0 {
+		t.FailNow()
+	}
+	if _, err := lib.Poll(tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	if err := <-done; !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	if depth, _ := lib.Depth(tn, sn); depth != 0 {
 		t.FailNow()
 	}
 }
@@ -53,21 +135,214 @@ func TestPubSub_Subscribe_Publish_Poll(t *testing.T) {
 	}
 }
 
-func TestPubSub_PollParallel(t *testing.T) {
-	defer func() {
-		if p := recover(); p != nil {
-			t.FailNow()
+func TestPubSub_PollN(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if _, err := lib.PollN(tn, sn, 10); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	for _, m := range []string{"a", "b", "c"} {
+		lib.Publish(tn, []byte(m))
+	}
+	if msgs, err := lib.PollN(tn, sn, 0); err != nil || msgs != nil {
+		t.FailNow()
+	}
+	msgs, err := lib.PollN(tn, sn, 2)
+	if err != nil || len(msgs) != 2 || string(msgs[0]) != "a" || string(msgs[1]) != "b" {
+		t.FailNow()
+	}
+	msgs, _ = lib.PollN(tn, sn, 10)
+	if len(msgs) != 1 || string(msgs[0]) != "c" {
+		t.FailNow()
+	}
+	if msgs, _ = lib.PollN(tn, sn, 10); msgs != nil {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_PollNParallel(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	n := 1000
+	for i := 0; i < n; i++ {
+		lib.Publish(tn, []byte(strconv.Itoa(i)))
+	}
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	seen := map[string]bool{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				msgs, err := lib.PollN(tn, sn, 7)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if msgs == nil {
+					return
+				}
+				mux.Lock()
+				for j, m := range msgs {
+					// batch is a continuous run of messages in publishing order
+					prev, _ := strconv.Atoi(string(msgs[max(j-1, 0)]))
+					if seen[string(m)] || (j > 0 && string(m) != strconv.Itoa(prev+1)) {
+						t.Errorf("message %s in batch %q", m, msgs)
+					}
+					seen[string(m)] = true
+				}
+				mux.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != n {
+		t.Fatal(len(seen))
+	}
+}
+
+func TestPubSub_PollWait(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if _, err := lib.PollWait(context.Background(), tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lib.Publish(tn, []byte("message"))
+	}()
+	if msg, err := lib.PollWait(context.Background(), tn, sn); err != nil || string(msg) != "message" {
+		t.FailNow()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := lib.PollWait(ctx, tn, sn); err != context.DeadlineExceeded {
+		t.FailNow()
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lib.Unsubscribe(tn, sn)
+	}()
+	if _, err := lib.PollWait(context.Background(), tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_PollMsgWait(t *testing.T) {
+	lib := New()
+	sn := "subscriber/id"
+	lib.Subscribe("orders/+", sn)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lib.Publish("orders/1", []byte("message"))
+	}()
+	msg, err := lib.PollMsgWait(context.Background(), "orders/+", sn)
+	if err != nil || msg.Topic != "orders/1" || string(msg.Body) != "message" {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_PollSubscriptionsParallel(t *testing.T) {
+	lib := New()
+	tn, wildcard := "some/topic", "some/+"
+	n, subs := 200, 8
+	type poller struct {
+		tn, sn string
+		n      int
+	}
+	var pollers []poller
+	for i := 0; i < subs; i++ {
+		pollers = append(pollers, poller{tn, fmt.Sprintf("subscriber/%d", i), n})
+	}
+	// wildcard matches its own dead-letter topic, so its publishers lock both topics and wait for pollers of the
+	// wildcard subscription while they move messages to the dead-letter topic
+	pollers = append(pollers, poller{wildcard, "wildcard", 2 * n})
+	for _, p := range pollers {
+		lib.SubscribeWithOptions(p.tn, p.sn, Options{MaxDeliveries: 1})
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			lib.Publish(tn, []byte(strconv.Itoa(i)))
+			lib.Publish(DeadLetterTopic(wildcard), []byte(strconv.Itoa(i)))
 		}
 	}()
+	// every subscription is polled by its own goroutine, odd messages are nacked and go to dead-letter topic
+	var got, dead atomic.Int64
+	for _, p := range pollers {
+		wg.Add(1)
+		go func(p poller) {
+			defer wg.Done()
+			for j := 0; j < p.n; {
+				b, token, err := lib.PollAck(p.tn, p.sn, time.Minute)
+				if errors.Is(err, ErrPaused) || err == nil && b == nil {
+					// publisher locks every subscription, spinning pollers mustn't starve it
+					runtime.Gosched()
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if j%2 == 0 {
+					err = lib.Ack(p.tn, p.sn, token)
+				} else {
+					err = lib.Nack(p.tn, p.sn, token, true)
+					dead.Add(1)
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				got.Add(1)
+				j++
+			}
+		}(p)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			_ = lib.Pause(tn, "subscriber/0", false)
+			_ = lib.Resume(tn, "subscriber/0")
+		}
+	}()
+	wg.Wait()
+	if got.Load() != int64(n*subs+2*n) {
+		t.Fatal(got.Load())
+	}
+	// dead-letter subscription of wildcard gets published messages too
+	total := 0
+	for _, p := range pollers {
+		d, _ := lib.Depth(DeadLetterTopic(p.tn), p.sn)
+		total += d
+	}
+	if int64(total) != dead.Load()+int64(n) {
+		t.Fatal(total, dead.Load())
+	}
+}
+
+func TestPubSub_PollParallel(t *testing.T) {
 	tn := "testing topic name"
 	tn2 := tn + " 2"
 	snf := "testing subscriber name format %d"
 	topics := []string{tn, tn2}
-	lib := New()
+	lib := New(WithEmptyError(true))
 	nPol := 4
 	var wg sync.WaitGroup
 	sentSeq := "sequence for sending"
-	allSend := false
+	var allSend atomic.Bool
 	wg.Add(1 + nPol*len(topics))
 	for i := 0; i < nPol; i++ {
 		lib.Subscribe(tn, fmt.Sprintf(snf, i))
@@ -79,7 +354,7 @@ func TestPubSub_PollParallel(t *testing.T) {
 				lib.Publish(tn, []byte{[]byte(sentSeq)[i]})
 			}
 		}
-		allSend = true
+		allSend.Store(true)
 		wg.Done()
 	}()
 	time.Sleep(1 * time.Second)
@@ -90,12 +365,15 @@ func TestPubSub_PollParallel(t *testing.T) {
 				for {
 					time.Sleep(1 * time.Millisecond)
 					msg, err := lib.Poll(tn, fmt.Sprintf(snf, n))
-					if err == ErrNoSubscriptions {
-						t.Errorf("error in subscribe mechanism #%d", n)
-						t.FailNow()
+					if errors.Is(err, ErrEmpty) {
+						if allSend.Load() {
+							break
+						}
+						continue
 					}
-					if len(msg) == 0 && allSend {
-						break
+					if err != nil {
+						t.Errorf("error in subscribe mechanism #%d: %v", n, err)
+						t.FailNow()
 					}
 					seq = append(seq, msg[0])
 				}
@@ -109,3 +387,129 @@ func TestPubSub_PollParallel(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func BenchmarkPubSub_Publish(b *testing.B) {
+	for _, n := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("subscriptions=%d", n), func(b *testing.B) {
+			lib := New()
+			tn := "some topic"
+			for i := 0; i < n; i++ {
+				lib.SubscribeWithOptions(tn, fmt.Sprint(i), Options{MaxMessages: 1000})
+			}
+			body := []byte("message")
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lib.Publish(tn, body)
+			}
+		})
+	}
+}
+
+func BenchmarkPubSub_PublishPoll(b *testing.B) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	body := []byte("message")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lib.Publish(tn, body)
+		if _, err := lib.Poll(tn, sn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPubSub_PublishPollN(b *testing.B) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	body := []byte("message")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10; j++ {
+			lib.Publish(tn, body)
+		}
+		if _, err := lib.PollN(tn, sn, 10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPubSub_PublishMsg(b *testing.B) {
+	lib := New()
+	tn := "some topic"
+	lib.SubscribeWithOptions(tn, "subscriber/id", Options{MaxMessages: 1000})
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	body := []byte("message")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// message with ID of producer costs one allocation
+		if _, err := lib.PublishMsg(tn, Message{ID: ids[i%len(ids)], Body: body}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPubSub_PublishParallel(b *testing.B) {
+	lib := New()
+	tn := "some topic"
+	lib.SubscribeWithOptions(tn, "subscriber/id", Options{MaxMessages: 1000})
+	body := []byte("message")
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lib.Publish(tn, body)
+		}
+	})
+}
+
+func BenchmarkPubSub_PublishTopicsParallel(b *testing.B) {
+	lib := New()
+	topics := make([]string, 64)
+	for i := range topics {
+		topics[i] = fmt.Sprintf("topic %d", i)
+		lib.SubscribeWithOptions(topics[i], "subscriber/id", Options{MaxMessages: 1000})
+	}
+	body := []byte("message")
+	var next atomic.Int32
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		tn := topics[int(next.Add(1))%len(topics)]
+		for pb.Next() {
+			lib.Publish(tn, body)
+		}
+	})
+}
+
+func BenchmarkPubSub_PollSubscriptionsParallel(b *testing.B) {
+	lib := New()
+	tn := "some topic"
+	subs := make([]string, 64)
+	for i := range subs {
+		subs[i] = fmt.Sprintf("subscriber/%d", i)
+		lib.SubscribeWithOptions(tn, subs[i], Options{MaxMessages: 1000})
+	}
+	for i := 0; i < 1000; i++ {
+		lib.Publish(tn, []byte("message"))
+	}
+	var next atomic.Int32
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		sn := subs[int(next.Add(1))%len(subs)]
+		for pb.Next() {
+			_, _ = lib.Poll(tn, sn)
+		}
+	})
+}
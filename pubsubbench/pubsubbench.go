@@ -0,0 +1,268 @@
+/*
+Load generator for pubsub package: publishers and subscribers of several topics work with a broker concurrently,
+throughput, end-to-end latency percentiles and memory allocated meanwhile are reported. It's used by cmd/pubsub-bench
+and may be called from benchmarks to compare locking strategies and storages:
+
+	res, err := pubsubbench.Run(ctx, pubsub.New(), pubsubbench.Config{Topics: 4, Publishers: 8, Subscribers: 2,
+		Messages: 100000, PayloadSize: 256})
+	fmt.Println(res)
+
+Latency of a message is measured from its PublishedAt to the moment subscriber took it, so broker with WithClock
+must use real time.
+*/
+package pubsubbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Error happens if Config has nothing to publish or nobody to publish
+var ErrInvalidConfig = errors.New("invalid config")
+
+// How long subscribers wait for the rest of messages after publishers finished
+const drainTimeout = 100 * time.Millisecond
+
+// How often heap is sampled for Result.PeakHeap, reading memory statistics stops the world, so it's rare
+const heapSampleInterval = 100 * time.Millisecond
+
+// Load of Run
+// Topics - number of topics "bench/0", "bench/1", ..., one if zero
+// Publishers - number of publishing goroutines, publisher i publishes to topic i % Topics
+// Subscribers - number of subscriptions of every topic, each polled by its own goroutine with PollMsgWait
+// PayloadSize - size of message bodies in bytes
+// Messages - number of messages of every publisher, ignored if Duration is set
+// Duration - how long publishers publish
+// Rate - messages per second of every publisher, unlimited if zero
+// Options - options of subscriptions, e.g. Options.Priority to measure priority storage
+type Config struct {
+	Topics      int
+	Publishers  int
+	Subscribers int
+	PayloadSize int
+	Messages    int
+	Duration    time.Duration
+	Rate        float64
+	Options     pubsub.Options
+}
+
+// Outcome of Run
+// Published, Rejected - messages accepted and rejected by TryPublish, Received - messages taken by subscribers
+// Elapsed - time from start of publishers till the last message was received (publishers finished if there are none)
+// P50, P90, P99, Max - percentiles of latency from publish to poll
+// Allocated, Mallocs - bytes and objects allocated by the process meanwhile, PeakHeap - the largest sampled heap
+type Result struct {
+	Published uint64
+	Rejected  uint64
+	Received  uint64
+	Elapsed   time.Duration
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+	Allocated uint64
+	Mallocs   uint64
+	PeakHeap  uint64
+}
+
+// Messages received per second
+func (r Result) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Received) / r.Elapsed.Seconds()
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("published %d, rejected %d, received %d in %v (%.0f msg/s)\n"+
+		"latency p50 %v, p90 %v, p99 %v, max %v\n"+
+		"allocated %d bytes in %d objects, peak heap %d bytes",
+		r.Published, r.Rejected, r.Received, r.Elapsed.Round(time.Millisecond), r.Throughput(),
+		r.P50, r.P90, r.P99, r.Max, r.Allocated, r.Mallocs, r.PeakHeap)
+}
+
+// Topic name of topic (i)
+func topic(i int) string {
+	return "bench/" + strconv.Itoa(i)
+}
+
+// Subscription name of subscriber (i)
+func subscription(i int) string {
+	return "bench-" + strconv.Itoa(i)
+}
+
+// Running load (cfg) against broker (ps) until publishers finish and subscribers take all messages or ctx is done
+// Subscriptions are created before and removed after the run
+func Run(ctx context.Context, ps pubsub.PubSuber, cfg Config) (Result, error) {
+	if cfg.Topics <= 0 {
+		cfg.Topics = 1
+	}
+	if cfg.Publishers <= 0 || cfg.Messages <= 0 && cfg.Duration <= 0 {
+		return Result{}, ErrInvalidConfig
+	}
+	for t := 0; t < cfg.Topics; t++ {
+		for s := 0; s < cfg.Subscribers; s++ {
+			ps.SubscribeWithOptions(topic(t), subscription(s), cfg.Options)
+		}
+	}
+	defer func() {
+		for t := 0; t < cfg.Topics; t++ {
+			for s := 0; s < cfg.Subscribers; s++ {
+				ps.Unsubscribe(topic(t), subscription(s))
+			}
+		}
+	}()
+
+	var res Result
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	stopHeap := sampleHeap(&res.PeakHeap)
+	start := time.Now()
+
+	var published sync.WaitGroup
+	var publishing atomic.Bool
+	publishing.Store(true)
+	pubCtx := ctx
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		pubCtx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+	for i := 0; i < cfg.Publishers; i++ {
+		published.Add(1)
+		go func(i int) {
+			defer published.Done()
+			publish(pubCtx, ps, topic(i%cfg.Topics), cfg, &res)
+		}(i)
+	}
+
+	var received sync.WaitGroup
+	latencies := make([][]time.Duration, cfg.Topics*cfg.Subscribers)
+	lasts := make([]time.Time, len(latencies))
+	for t := 0; t < cfg.Topics; t++ {
+		for s := 0; s < cfg.Subscribers; s++ {
+			received.Add(1)
+			go func(i, t, s int) {
+				defer received.Done()
+				latencies[i], lasts[i] = receive(ctx, ps, topic(t), subscription(s), &publishing)
+			}(t*cfg.Subscribers+s, t, s)
+		}
+	}
+	published.Wait()
+	end := time.Now()
+	publishing.Store(false)
+	received.Wait()
+	for _, last := range lasts {
+		if last.After(end) {
+			end = last
+		}
+	}
+	res.Elapsed = end.Sub(start)
+	stopHeap()
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	res.Allocated = after.TotalAlloc - before.TotalAlloc
+	res.Mallocs = after.Mallocs - before.Mallocs
+
+	var all []time.Duration
+	for _, l := range latencies {
+		all = append(all, l...)
+	}
+	res.Received = uint64(len(all))
+	if len(all) > 0 {
+		slices.Sort(all)
+		res.P50 = percentile(all, 0.5)
+		res.P90 = percentile(all, 0.9)
+		res.P99 = percentile(all, 0.99)
+		res.Max = all[len(all)-1]
+	}
+	return res, ctx.Err()
+}
+
+// Publishing messages of config (cfg) to topic name (tn) until ctx is done, counters of (res) are updated
+func publish(ctx context.Context, ps pubsub.PubSuber, tn string, cfg Config, res *Result) {
+	body := make([]byte, cfg.PayloadSize)
+	var interval time.Duration
+	if cfg.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / cfg.Rate)
+	}
+	start := time.Now()
+	for n := 0; cfg.Duration > 0 || n < cfg.Messages; n++ {
+		if ctx.Err() != nil {
+			return
+		}
+		if interval > 0 {
+			if d := time.Until(start.Add(time.Duration(n) * interval)); d > 0 {
+				time.Sleep(d)
+			}
+		}
+		if err := ps.TryPublish(tn, body); err != nil {
+			atomic.AddUint64(&res.Rejected, 1)
+		} else {
+			atomic.AddUint64(&res.Published, 1)
+		}
+	}
+}
+
+// Taking messages of subscription (sn) of topic name (tn) until ctx is done or publishers finished and there are
+// no messages for drainTimeout, latencies of messages and time of the last message are returned
+func receive(ctx context.Context, ps pubsub.PubSuber, tn, sn string, publishing *atomic.Bool) ([]time.Duration, time.Time) {
+	var latencies []time.Duration
+	var last time.Time
+	for ctx.Err() == nil {
+		pollCtx, cancel := context.WithTimeout(ctx, drainTimeout)
+		msg, err := ps.PollMsgWait(pollCtx, tn, sn)
+		cancel()
+		if err == nil {
+			last = time.Now()
+			latencies = append(latencies, last.Sub(msg.PublishedAt))
+			continue
+		}
+		if err != context.DeadlineExceeded || !publishing.Load() {
+			break
+		}
+	}
+	return latencies, last
+}
+
+// Sampling heap until returned function is called, the largest heap is stored to (peak)
+func sampleHeap(peak *uint64) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(heapSampleInterval)
+		defer ticker.Stop()
+		var ms runtime.MemStats
+		for {
+			runtime.ReadMemStats(&ms)
+			if ms.HeapAlloc > *peak {
+				*peak = ms.HeapAlloc
+			}
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Value of percentile (q) of sorted durations (sorted)
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	i := int(float64(len(sorted))*q+0.5) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
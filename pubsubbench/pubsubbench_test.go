@@ -0,0 +1,52 @@
+package pubsubbench
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+func TestRun(t *testing.T) {
+	lib := pubsub.New()
+	res, err := Run(context.Background(), lib, Config{Topics: 2, Publishers: 4, Subscribers: 3, PayloadSize: 16,
+		Messages: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Published != 2000 || res.Rejected != 0 || res.Received != 6000 {
+		t.Fatal(res)
+	}
+	if res.P50 > res.P90 || res.P90 > res.P99 || res.P99 > res.Max || res.Elapsed <= 0 || res.Throughput() <= 0 {
+		t.Fatal(res)
+	}
+	if !strings.Contains(res.String(), "received 6000") {
+		t.Fatal(res.String())
+	}
+	if topics := lib.Topics(); len(topics) != 0 {
+		t.Fatal(topics)
+	}
+}
+
+func TestRun_Duration(t *testing.T) {
+	res, err := Run(context.Background(), pubsub.New(), Config{Publishers: 1, Subscribers: 1, Duration: 50 * time.Millisecond,
+		Rate: 100, Options: pubsub.Options{Priority: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Published == 0 || res.Published > 10 || res.Received != res.Published {
+		t.Fatal(res)
+	}
+	if _, err := Run(context.Background(), pubsub.New(), Config{Publishers: 1}); err != ErrInvalidConfig {
+		t.Fatal(err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if percentile(sorted, 0.5) != 5 || percentile(sorted, 0.9) != 9 || percentile(sorted, 0.99) != 10 {
+		t.FailNow()
+	}
+}
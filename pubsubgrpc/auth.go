@@ -0,0 +1,62 @@
+//go:build grpc
+
+package pubsubgrpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/cejixo3/pubsub.git"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Authenticator extracts principal of call (ctx) from its credentials, calls return codes.Unauthenticated if it
+// returns error (pubsub.ErrUnauthenticated or wrapping it), codes.PermissionDenied if error is pubsub.ErrForbidden
+type Authenticator func(ctx context.Context) (principal any, err error)
+
+// Authenticator of bearer tokens from "authorization" metadata verified by (verify)
+func BearerToken(verify pubsub.TokenVerifier) Authenticator {
+	return func(ctx context.Context) (any, error) {
+		for _, v := range metadata.ValueFromIncomingContext(ctx, "authorization") {
+			if len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+				return verify(ctx, v[7:])
+			}
+		}
+		return nil, pubsub.ErrUnauthenticated
+	}
+}
+
+// Authenticator of client certificates of mutual TLS verified by (verify), pubsub.CommonName is used if it's nil
+// Server credentials must verify certificates (tls.RequireAndVerifyClientCert, see pubsubhttp.TLSConfig)
+func ClientCert(verify pubsub.CertVerifier) Authenticator {
+	if verify == nil {
+		verify = pubsub.CommonName
+	}
+	return func(ctx context.Context) (any, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, pubsub.ErrUnauthenticated
+		}
+		info, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok || len(info.State.PeerCertificates) == 0 {
+			return nil, pubsub.ErrUnauthenticated
+		}
+		return verify(info.State.PeerCertificates[0])
+	}
+}
+
+// Authenticator trying authenticators (auths) in order, the first one not returning pubsub.ErrUnauthenticated wins
+func AnyOf(auths ...Authenticator) Authenticator {
+	return func(ctx context.Context) (any, error) {
+		for _, auth := range auths {
+			principal, err := auth(ctx)
+			if !errors.Is(err, pubsub.ErrUnauthenticated) {
+				return principal, err
+			}
+		}
+		return nil, pubsub.ErrUnauthenticated
+	}
+}
@@ -0,0 +1,88 @@
+//go:build grpc
+
+package pubsubgrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubgrpc/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Allowing principal "alice" everything
+var testAuthorizer = pubsub.AuthorizerFunc(func(op pubsub.Operation, tn, sn string, principal any) error {
+	if principal != "alice" {
+		return pubsub.ErrForbidden
+	}
+	return nil
+})
+
+func TestServer_BearerToken(t *testing.T) {
+	lib := pubsub.New(pubsub.WithAuthorizer(testAuthorizer))
+	lib.Subscribe("orders", "billing")
+	s := NewServer(lib)
+	s.Authenticate = BearerToken(pubsub.StaticTokens(map[string]any{"a": "alice", "b": "bob"}))
+	c := dial(t, s)
+	for _, tc := range []struct {
+		header string
+		code   codes.Code
+	}{
+		{"", codes.Unauthenticated},
+		{"Bearer wrong", codes.Unauthenticated},
+		{"Bearer a", codes.OK},
+		{"bearer b", codes.PermissionDenied},
+	} {
+		ctx := context.Background()
+		if tc.header != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tc.header)
+		}
+		_, err := c.Poll(ctx, &pb.PollRequest{Topic: "orders", Subscription: "billing"})
+		if status.Code(err) != tc.code {
+			t.Fatal(tc, err)
+		}
+	}
+}
+
+// Context of call made over TLS with client certificate of common name (cn)
+func tlsPeer(cn string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	info := credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+}
+
+func TestClientCert(t *testing.T) {
+	auth := ClientCert(nil)
+	if _, err := auth(context.Background()); !errors.Is(err, pubsub.ErrUnauthenticated) {
+		t.Fatal(err)
+	}
+	if _, err := auth(peer.NewContext(context.Background(), &peer.Peer{})); !errors.Is(err, pubsub.ErrUnauthenticated) {
+		t.Fatal(err)
+	}
+	if principal, err := auth(tlsPeer("alice")); err != nil || principal != "alice" {
+		t.Fatal(principal, err)
+	}
+}
+
+func TestAnyOf(t *testing.T) {
+	auth := AnyOf(BearerToken(pubsub.StaticTokens(map[string]any{"a": "alice"})), ClientCert(nil))
+	if principal, err := auth(tlsPeer("bob")); err != nil || principal != "bob" {
+		t.Fatal(principal, err)
+	}
+	ctx := metadata.NewIncomingContext(tlsPeer("bob"), metadata.Pairs("authorization", "Bearer a"))
+	if principal, err := auth(ctx); err != nil || principal != "alice" {
+		t.Fatal(principal, err)
+	}
+	if _, err := auth(context.Background()); !errors.Is(err, pubsub.ErrUnauthenticated) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,9 @@
+// Package pb contains gRPC bindings generated from pubsub.proto
+//
+// Bindings are committed, regenerate them after changing pubsub.proto with protoc, protoc-gen-go and
+// protoc-gen-go-grpc installed:
+//
+//	go generate ./pubsubgrpc/pb
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pubsub.proto
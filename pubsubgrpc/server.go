@@ -0,0 +1,192 @@
+//go:build grpc
+
+/*
+gRPC layer for pubsub package. Server implements pb.PubSubServer service defined in pb/pubsub.proto
+on top of a PubSuber, including StreamPoll which pushes messages as they arrive.
+
+The package requires google.golang.org/grpc, so it's built only with `grpc` build tag:
+
+	go build -tags grpc ./...
+*/
+package pubsubgrpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubgrpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Upper limit for wait_ms of Poll
+const MaxWait = time.Minute
+
+// Server implements pb.PubSubServer backed by a PubSuber
+// Principal - optional function extracting principal of call (e.g. from metadata or peer certificate) passed to
+// Authorizer of broker (see pubsub.WithAuthorizer), denied calls return codes.PermissionDenied
+// Authenticate - optional authenticator of calls (see BearerToken and ClientCert), calls without valid credentials
+// return codes.Unauthenticated, replaces Principal if it's set. TLS is configured by credentials of gRPC server
+type Server struct {
+	pb.UnimplementedPubSubServer
+	ps           pubsub.PubSuber
+	Principal    func(ctx context.Context) any
+	Authenticate Authenticator
+}
+
+// Constructor. Creates a Server serving broker (ps)
+func NewServer(ps pubsub.PubSuber) *Server {
+	return &Server{ps: ps}
+}
+
+// Registering Server (s) in gRPC server (g)
+func (s *Server) Register(g *grpc.Server) {
+	pb.RegisterPubSubServer(g, s)
+}
+
+// Broker acting on behalf of principal of call (ctx), the broker itself if neither Authenticate nor Principal is set
+func (s *Server) broker(ctx context.Context) (pubsub.PubSuber, error) {
+	if s.Authenticate != nil {
+		principal, err := s.Authenticate(ctx)
+		if errors.Is(err, pubsub.ErrForbidden) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return s.ps.As(principal), nil
+	}
+	if s.Principal == nil {
+		return s.ps, nil
+	}
+	return s.ps.As(s.Principal(ctx)), nil
+}
+
+// Publish message to a topic, codes.ResourceExhausted is returned if some subscription rejected it
+func (s *Server) Publish(ctx context.Context, req *pb.PublishRequest) (*pb.PublishResponse, error) {
+	if req.GetTopic() == "" {
+		return nil, status.Error(codes.InvalidArgument, "topic is required")
+	}
+	ps, err := s.broker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.TryPublish(req.GetTopic(), req.GetBody()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.PublishResponse{}, nil
+}
+
+// Subscribe to a topic
+func (s *Server) Subscribe(ctx context.Context, req *pb.SubscribeRequest) (*pb.SubscribeResponse, error) {
+	if req.GetTopic() == "" || req.GetSubscription() == "" {
+		return nil, status.Error(codes.InvalidArgument, "topic and subscription are required")
+	}
+	ps, err := s.broker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := pubsub.Authorize(ps, pubsub.OpSubscribe, req.GetTopic(), req.GetSubscription()); err != nil {
+		return nil, toStatus(err)
+	}
+	ps.Subscribe(req.GetTopic(), req.GetSubscription())
+	return &pb.SubscribeResponse{}, nil
+}
+
+// Unsubscribe from a topic
+func (s *Server) Unsubscribe(ctx context.Context, req *pb.SubscribeRequest) (*pb.SubscribeResponse, error) {
+	ps, err := s.broker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := pubsub.Authorize(ps, pubsub.OpSubscribe, req.GetTopic(), req.GetSubscription()); err != nil {
+		return nil, toStatus(err)
+	}
+	ps.Unsubscribe(req.GetTopic(), req.GetSubscription())
+	return &pb.SubscribeResponse{}, nil
+}
+
+// Poll fetches up to max_messages (at least one) messages, waits up to wait_ms if there are no messages
+func (s *Server) Poll(ctx context.Context, req *pb.PollRequest) (*pb.PollResponse, error) {
+	max := int(req.GetMaxMessages())
+	if max <= 0 {
+		max = 1
+	}
+	ps, err := s.broker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := ps.PollN(req.GetTopic(), req.GetSubscription(), max)
+	if err != nil && !errors.Is(err, pubsub.ErrEmpty) {
+		return nil, toStatus(err)
+	}
+	if len(msgs) == 0 && req.GetWaitMs() > 0 {
+		wait := time.Duration(req.GetWaitMs()) * time.Millisecond
+		if wait > MaxWait {
+			wait = MaxWait
+		}
+		wctx, cancel := context.WithTimeout(ctx, wait)
+		defer cancel()
+		msg, err := ps.PollWait(wctx, req.GetTopic(), req.GetSubscription())
+		switch {
+		case err == context.DeadlineExceeded && ctx.Err() == nil:
+		case err != nil:
+			return nil, toStatus(err)
+		default:
+			msgs = [][]byte{msg}
+		}
+	}
+	resp := &pb.PollResponse{Messages: make([]*pb.Message, len(msgs))}
+	for i, msg := range msgs {
+		resp.Messages[i] = &pb.Message{Body: msg}
+	}
+	return resp, nil
+}
+
+// StreamPoll pushes messages of a subscription as they arrive until client cancels the call
+// or the subscription is removed
+func (s *Server) StreamPoll(req *pb.SubscribeRequest, stream pb.PubSub_StreamPollServer) error {
+	ctx := stream.Context()
+	ps, err := s.broker(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		msg, err := ps.PollWait(ctx, req.GetTopic(), req.GetSubscription())
+		if err != nil {
+			return toStatus(err)
+		}
+		if err := stream.Send(&pb.Message{Body: msg}); err != nil {
+			return err
+		}
+	}
+}
+
+// Converting pubsub and context errors into gRPC status errors
+func toStatus(err error) error {
+	if errors.Is(err, pubsub.ErrForbidden) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	if errors.Is(err, pubsub.ErrUnauthenticated) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if errors.Is(err, pubsub.ErrInvalidMessage) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	switch err {
+	case pubsub.ErrClosed, pubsub.ErrPaused:
+		return status.Error(codes.Unavailable, err.Error())
+	case pubsub.ErrTopicNotFound, pubsub.ErrSubscriptionNotFound, pubsub.ErrNoSubscriptions:
+		return status.Error(codes.NotFound, err.Error())
+	case pubsub.ErrQueueFull, pubsub.ErrRateLimited:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
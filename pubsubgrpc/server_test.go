@@ -0,0 +1,149 @@
+//go:build grpc
+
+package pubsubgrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubgrpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Client connected to gRPC server serving (s) over in-memory listener
+func dial(t *testing.T, s *Server) pb.PubSubClient {
+	lis := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	s.Register(g)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewPubSubClient(conn)
+}
+
+func TestServer_PublishPoll(t *testing.T) {
+	c := dial(t, NewServer(pubsub.New()))
+	ctx := context.Background()
+	if _, err := c.Publish(ctx, &pb.PublishRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatal(err)
+	}
+	if _, err := c.Subscribe(ctx, &pb.SubscribeRequest{Topic: "orders"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatal(err)
+	}
+	if _, err := c.Poll(ctx, &pb.PollRequest{Topic: "orders", Subscription: "billing"}); status.Code(err) != codes.NotFound {
+		t.Fatal(err)
+	}
+	if _, err := c.Subscribe(ctx, &pb.SubscribeRequest{Topic: "orders", Subscription: "billing"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []string{"a", "b", "c"} {
+		if _, err := c.Publish(ctx, &pb.PublishRequest{Topic: "orders", Body: []byte(b)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := c.Poll(ctx, &pb.PollRequest{Topic: "orders", Subscription: "billing", MaxMessages: 2})
+	if err != nil || len(resp.GetMessages()) != 2 || string(resp.GetMessages()[1].GetBody()) != "b" {
+		t.Fatal(resp, err)
+	}
+	resp, err = c.Poll(ctx, &pb.PollRequest{Topic: "orders", Subscription: "billing"})
+	if err != nil || len(resp.GetMessages()) != 1 || string(resp.GetMessages()[0].GetBody()) != "c" {
+		t.Fatal(resp, err)
+	}
+	if _, err := c.Unsubscribe(ctx, &pb.SubscribeRequest{Topic: "orders", Subscription: "billing"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Poll(ctx, &pb.PollRequest{Topic: "orders", Subscription: "billing"}); status.Code(err) != codes.NotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_PollWait(t *testing.T) {
+	lib := pubsub.New()
+	lib.Subscribe("orders", "billing")
+	c := dial(t, NewServer(lib))
+	ctx := context.Background()
+	resp, err := c.Poll(ctx, &pb.PollRequest{Topic: "orders", Subscription: "billing", WaitMs: 10})
+	if err != nil || len(resp.GetMessages()) != 0 {
+		t.Fatal(resp, err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lib.Publish("orders", []byte("late"))
+	}()
+	resp, err = c.Poll(ctx, &pb.PollRequest{Topic: "orders", Subscription: "billing", WaitMs: 5000})
+	if err != nil || len(resp.GetMessages()) != 1 || string(resp.GetMessages()[0].GetBody()) != "late" {
+		t.Fatal(resp, err)
+	}
+}
+
+func TestServer_StreamPoll(t *testing.T) {
+	lib := pubsub.New()
+	lib.Subscribe("orders", "billing")
+	c := dial(t, NewServer(lib))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.StreamPoll(ctx, &pb.SubscribeRequest{Topic: "orders", Subscription: "billing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib.Publish("orders", []byte("first"))
+	lib.Publish("orders", []byte("second"))
+	for _, want := range []string{"first", "second"} {
+		msg, err := stream.Recv()
+		if err != nil || string(msg.GetBody()) != want {
+			t.Fatal(msg, err)
+		}
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_Principal(t *testing.T) {
+	lib := pubsub.New(pubsub.WithAuthorizer(testAuthorizer))
+	lib.Subscribe("orders", "billing")
+	s := NewServer(lib)
+	principal := "bob"
+	s.Principal = func(context.Context) any { return principal }
+	c := dial(t, s)
+	ctx := context.Background()
+	if _, err := c.Publish(ctx, &pb.PublishRequest{Topic: "orders", Body: []byte("a")}); status.Code(err) != codes.PermissionDenied {
+		t.Fatal(err)
+	}
+	if _, err := c.Subscribe(ctx, &pb.SubscribeRequest{Topic: "orders", Subscription: "x"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatal(err)
+	}
+	principal = "alice"
+	if _, err := c.Publish(ctx, &pb.PublishRequest{Topic: "orders", Body: []byte("a")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	for err, code := range map[error]codes.Code{
+		pubsub.ErrClosed:         codes.Unavailable,
+		pubsub.ErrTopicNotFound:  codes.NotFound,
+		pubsub.ErrQueueFull:      codes.ResourceExhausted,
+		pubsub.ErrForbidden:      codes.PermissionDenied,
+		context.DeadlineExceeded: codes.DeadlineExceeded,
+		context.Canceled:         codes.Canceled,
+	} {
+		if c := status.Code(toStatus(err)); c != code {
+			t.Fatal(err, c)
+		}
+	}
+}
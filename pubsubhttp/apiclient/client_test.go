@@ -0,0 +1,85 @@
+package apiclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubhttp"
+)
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(pubsubhttp.NewHandler(pubsub.New()))
+	defer srv.Close()
+	c, err := NewClientWithResponses(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sub, err := c.SubscribeWithResponse(ctx, SubscribeRequest{Topic: "orders", Subscription: "billing"})
+	if err != nil || sub.StatusCode() != http.StatusNoContent {
+		t.Fatal(sub, err)
+	}
+	for _, b := range []string{"first", "second"} {
+		pub, err := c.PublishWithBodyWithResponse(ctx, "orders", nil, "text/plain", strings.NewReader(b))
+		if err != nil || pub.StatusCode() != http.StatusAccepted {
+			t.Fatal(pub, err)
+		}
+	}
+	poll, err := c.PollWithResponse(ctx, &PollParams{Topic: "orders", Sub: "billing"})
+	if err != nil || poll.StatusCode() != http.StatusOK || string(poll.Body) != "first" {
+		t.Fatal(poll, err)
+	}
+	if ct := poll.HTTPResponse.Header.Get("Content-Type"); ct != "text/plain" {
+		t.Fatal(ct)
+	}
+	topics, err := c.ListTopicsWithResponse(ctx)
+	if err != nil || topics.JSON200 == nil || len(*topics.JSON200) != 1 || (*topics.JSON200)[0] != "orders" {
+		t.Fatal(topics, err)
+	}
+	stats, err := c.StatsWithResponse(ctx)
+	if err != nil || stats.JSON200 == nil || stats.JSON200.Topics == nil || len(*stats.JSON200.Topics) != 1 {
+		t.Fatal(stats, err)
+	}
+	purge, err := c.PurgeWithResponse(ctx, &PurgeParams{Topic: "orders"})
+	if err != nil || purge.JSON200 == nil || purge.JSON200.Purged != 1 {
+		t.Fatal(purge, err)
+	}
+	poll, err = c.PollWithResponse(ctx, &PollParams{Topic: "orders", Sub: "billing"})
+	if err != nil || poll.StatusCode() != http.StatusNotFound {
+		t.Fatal(poll, err)
+	}
+	unsub, err := c.UnsubscribeWithResponse(ctx, &UnsubscribeParams{Topic: "orders", Sub: "billing"})
+	if err != nil || unsub.StatusCode() != http.StatusNoContent {
+		t.Fatal(unsub, err)
+	}
+	health, err := c.HealthzWithResponse(ctx)
+	if err != nil || health.JSON200 == nil || health.JSON200.Live == nil || !*health.JSON200.Live {
+		t.Fatal(health, err)
+	}
+}
+
+func TestClient_BearerToken(t *testing.T) {
+	h := pubsubhttp.NewHandler(pubsub.New())
+	h.Authenticate = pubsubhttp.BearerToken(pubsub.StaticTokens(map[string]any{"a": "alice"}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c, err := NewClientWithResponses(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if topics, err := c.ListTopicsWithResponse(ctx); err != nil || topics.StatusCode() != http.StatusUnauthorized {
+		t.Fatal(topics, err)
+	}
+	auth := func(ctx context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer a")
+		return nil
+	}
+	if topics, err := c.ListTopicsWithResponse(ctx, auth); err != nil || topics.StatusCode() != http.StatusOK {
+		t.Fatal(topics, err)
+	}
+}
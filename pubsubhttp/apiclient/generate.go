@@ -0,0 +1,11 @@
+// Package apiclient contains Go client of pubsubhttp.Handler generated from its OpenAPI document (openapi.json)
+//
+// Client is committed (client.gen.go), regenerate it after changing openapi.json with oapi-codegen (fetched by go run):
+//
+//	go generate ./pubsubhttp/apiclient
+//
+// Clients of other languages are generated from the same document, e.g. with openapi-generator, or fetched from
+// GET /openapi.json of a running handler. Hand-written client package offers retries, buffering and reconnects
+package apiclient
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.4.1 -generate types,client -package apiclient -o client.gen.go ../openapi.json
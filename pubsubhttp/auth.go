@@ -0,0 +1,109 @@
+package pubsubhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Authenticator extracts principal of request (r) from its credentials, requests are rejected with 401 if it
+// returns error (pubsub.ErrUnauthenticated or wrapping it), with 403 if error is pubsub.ErrForbidden
+type Authenticator func(r *http.Request) (principal any, err error)
+
+// Authenticator of bearer tokens from Authorization header (or access_token query parameter, because browsers can't
+// set headers of WebSocket requests) verified by (verify)
+func BearerToken(verify pubsub.TokenVerifier) Authenticator {
+	return func(r *http.Request) (any, error) {
+		token := r.URL.Query().Get("access_token")
+		if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+			token = h[7:]
+		}
+		if token == "" {
+			return nil, pubsub.ErrUnauthenticated
+		}
+		return verify(r.Context(), token)
+	}
+}
+
+// Authenticator of client certificates of mutual TLS verified by (verify), pubsub.CommonName is used if it's nil
+// Server must verify certificates (see TLSConfig), otherwise any certificate is accepted
+func ClientCert(verify pubsub.CertVerifier) Authenticator {
+	if verify == nil {
+		verify = pubsub.CommonName
+	}
+	return func(r *http.Request) (any, error) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return nil, pubsub.ErrUnauthenticated
+		}
+		return verify(r.TLS.PeerCertificates[0])
+	}
+}
+
+// Authenticator trying authenticators (auths) in order, the first one not returning pubsub.ErrUnauthenticated wins
+func AnyOf(auths ...Authenticator) Authenticator {
+	return func(r *http.Request) (any, error) {
+		for _, auth := range auths {
+			principal, err := auth(r)
+			if !errors.Is(err, pubsub.ErrUnauthenticated) {
+				return principal, err
+			}
+		}
+		return nil, pubsub.ErrUnauthenticated
+	}
+}
+
+// Key of principal of authenticated request in its context
+type principalKey struct{}
+
+// Principal of request authenticated by Authenticate, nil if there is no such one
+func PrincipalOf(ctx context.Context) any {
+	return ctx.Value(principalKey{})
+}
+
+// Authenticating request (r) with (auth), returned request carries principal in its context (see PrincipalOf)
+// Responds with 401 (or 403 for pubsub.ErrForbidden) and returns false if (auth) rejects the request
+func Authenticate(w http.ResponseWriter, r *http.Request, auth Authenticator) (*http.Request, bool) {
+	principal, err := auth(r)
+	switch {
+	case err == nil:
+		return r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)), true
+	case errors.Is(err, pubsub.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	}
+	return nil, false
+}
+
+// TLS configuration of server with certificate (certFile) and its key (keyFile), e.g. for http.Server.TLSConfig or
+// credentials.NewTLS of gRPC. If (clientCAFile) isn't empty, clients must present certificates signed by its CAs
+// (mutual TLS, see ClientCert)
+func TLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		return cfg, nil
+	}
+	b, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("%s: no certificates found", clientCAFile)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
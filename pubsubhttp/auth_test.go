@@ -0,0 +1,138 @@
+package pubsubhttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Allowing principal "alice" everything
+var testAuthorizer = pubsub.AuthorizerFunc(func(op pubsub.Operation, tn, sn string, principal any) error {
+	if principal != "alice" {
+		return pubsub.ErrForbidden
+	}
+	return nil
+})
+
+func TestHandler_BearerToken(t *testing.T) {
+	lib := pubsub.New(pubsub.WithAuthorizer(testAuthorizer))
+	lib.Subscribe("orders", "billing")
+	h := NewHandler(lib)
+	h.Authenticate = BearerToken(pubsub.StaticTokens(map[string]any{"a": "alice", "b": "bob"}))
+	for _, c := range []struct {
+		header, query string
+		code          int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"Bearer wrong", "", http.StatusUnauthorized},
+		{"Bearer a", "", http.StatusNotFound},
+		{"bearer b", "", http.StatusForbidden},
+		{"", "&access_token=a", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/poll?topic=orders&sub=billing"+c.query, nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Fatal(c, rec.Code)
+		}
+		if c.code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Fatal(rec.Header())
+		}
+	}
+	if rec := do(t, h, http.MethodGet, "/healthz", ""); rec.Code != http.StatusOK {
+		t.Fatal(rec.Code)
+	}
+}
+
+// Writing PEM block of (typ) with (b) to file (name) of directory (dir)
+func writePEM(t *testing.T, dir, name, typ string, b []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Certificate with common name (cn) signed by (parent) or self-signed CA if it's nil
+func testCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid, tmpl.KeyUsage = true, true, x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key, der
+}
+
+func TestHandler_ClientCert(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caDER := testCert(t, "ca", nil, nil)
+	_, serverKey, serverDER := testCert(t, "server", ca, caKey)
+	_, clientKey, clientDER := testCert(t, "alice", ca, caKey)
+	keyDER, _ := x509.MarshalECPrivateKey(serverKey)
+	cfg, err := TLSConfig(writePEM(t, dir, "server.pem", "CERTIFICATE", serverDER),
+		writePEM(t, dir, "server.key", "EC PRIVATE KEY", keyDER), writePEM(t, dir, "ca.pem", "CERTIFICATE", caDER))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(pubsub.New(pubsub.WithAuthorizer(testAuthorizer)))
+	h.Authenticate = AnyOf(BearerToken(pubsub.StaticTokens(nil)), ClientCert(nil))
+	srv := httptest.NewUnstartedServer(h)
+	srv.TLS = cfg
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}},
+	}}}
+	res, err := client.Get(srv.URL + "/topics")
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatal(res, err)
+	}
+	res.Body.Close()
+	// clients without certificates are rejected by TLS handshake
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if res, err := client.Get(srv.URL + "/topics"); err == nil {
+		res.Body.Close()
+		t.Fatal(res.Status)
+	}
+}
@@ -0,0 +1,443 @@
+/*
+	HTTP long-polling layer for pubsub package. Handler exposes a PubSuber with the following endpoints:
+
+		POST   /topics/{tn}                        publish request body as a message to topic tn
+		POST   /subscriptions                      subscribe, body: {"topic": "tn", "subscription": "sn"}
+		DELETE /subscriptions?topic=tn&sub=sn      unsubscribe
+		GET    /poll?topic=tn&sub=sn&wait=30s      fetch a message, waits up to `wait` if there are no messages
+		GET    /poll?...&session=id&ack=seq        fetch a message of session (see below), acknowledging messages up to seq
+		POST   /heartbeat?topic=tn&sub=sn&session=id  keep session alive between polls, 404 if it expired
+		GET    /topics                             list topic names as JSON array
+		GET    /stats                              counters of topics and subscriptions (pubsub.BrokerStats) as JSON
+		DELETE /messages?topic=tn&sub=sn           purge pending messages of sub (of topic without sub), {"purged": n}
+		GET    /snapshot                           dump of broker written by Snapshot
+		GET    /healthz, /readyz                   liveness and readiness probes (see Healthz and Readyz)
+		GET    /openapi.json                       OpenAPI 3 document of these endpoints (see OpenAPI)
+
+	Topic name in the path must be escaped (url.PathEscape) if it contains slashes.
+	Poll responds with 200 and message as body, 404 if there are no messages and 400 if there is no such subscription.
+	Publish responds with 404 if nobody received the message (see pubsub.WithNoSubscribersError) or the topic
+	doesn't exist in strict mode. Publish and poll respond with 503 if broker is closed (publish also if some
+	subscription rejected the message, poll without wait also if the subscription is paused, see pubsub.Pause)
+	and 429 if rate limit of the topic or subscription is exceeded. Publish responds with 400 if validator of the
+	topic rejected the message (see pubsub.Validator).
+	Content-Type of publish request is kept in pubsub.HeaderContentType header of the message and returned by poll
+	(application/octet-stream if it isn't set), so codecs are chosen by it (see pubsub.CodecFor).
+	Publish uses Idempotency-Key request header as message ID, so retried requests aren't delivered twice to topics
+	with pubsub.TopicConfig.DedupWindow.
+	Poll with session parameter delivers messages of pubsub.Session with ID chosen by client: messages stay in flight
+	until they're acknowledged by ack parameter of a later poll with sequence number from X-Pubsub-Delivery response
+	header, and client polling again without acknowledging (e.g. after its connection broke) gets the same messages
+	first, so a reconnecting client resumes its subscription instead of losing messages. Session not polled or kept
+	alive by heartbeats during timeout of Handler.Sessions expires, and the subscription is removed or paused once its
+	last session expires if Handler.Sessions is set so (see pubsub.Sessions.SetExpiry).
+	If Handler.Principal is set, requests are served on behalf of the principal (see pubsub.WithAuthorizer),
+	every endpoint responds with 403 if Authorizer denies the operation with pubsub.ErrForbidden.
+	If Handler.Authenticate is set (see BearerToken and ClientCert), requests without valid credentials are rejected
+	with 401, and principal of credentials is used instead of Principal. Probes and OpenAPI document aren't authenticated.
+	TLS is configured by http.Server serving the handler, see TLSConfig.
+*/
+package pubsubhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Default limit for `wait` parameter of poll endpoint
+const DefaultMaxWait = time.Minute
+
+// Request header of publish endpoint with producer-supplied message ID
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// Response header of poll endpoint with sequence number of message delivered to session
+const HeaderDelivery = "X-Pubsub-Delivery"
+
+// Response header of poll endpoint with delivery attempt of message delivered to session (see Message.DeliveryAttempt)
+const HeaderDeliveryAttempt = "X-Pubsub-Delivery-Attempt"
+
+// Handler is an http.Handler serving pubsub endpoints
+// MaxWait - upper limit for `wait` parameter of poll endpoint, DefaultMaxWait is used if zero
+// MaxBodySize - limit of published message size in bytes, unlimited if zero
+// IdleTimeout - subscriptions created by the handler are removed if not polled during this time, never if zero
+// Principal - optional function extracting principal of request (user, token, ...) passed to Authorizer of broker
+// Sessions - sessions of poll endpoint, may be shared with other handlers (e.g. pubsubws) of the broker
+// Authenticate - optional authenticator of requests, replaces Principal if it's set
+type Handler struct {
+	ps           pubsub.PubSuber
+	MaxWait      time.Duration
+	MaxBodySize  int64
+	IdleTimeout  time.Duration
+	Principal    func(r *http.Request) any
+	Sessions     *pubsub.Sessions
+	Authenticate Authenticator
+}
+
+// Constructor. Creates a Handler serving broker (ps)
+func NewHandler(ps pubsub.PubSuber) *Handler {
+	return &Handler{ps: ps, MaxWait: DefaultMaxWait, Sessions: pubsub.NewSessions(pubsub.DefaultSessionTimeout)}
+}
+
+// Body of subscribe request
+type subscribeRequest struct {
+	Topic        string `json:"topic"`
+	Subscription string `json:"subscription"`
+}
+
+// Routing requests to endpoints
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	if h.Authenticate != nil && path != "/healthz" && path != "/readyz" && path != "/openapi.json" {
+		var ok bool
+		if r, ok = Authenticate(w, r, h.Authenticate); !ok {
+			return
+		}
+	}
+	switch {
+	case path == "/topics":
+		h.allow(w, r, http.MethodGet, h.topics)
+	case path == "/stats":
+		h.allow(w, r, http.MethodGet, h.stats)
+	case path == "/messages":
+		h.allow(w, r, http.MethodDelete, h.purge)
+	case path == "/snapshot":
+		h.allow(w, r, http.MethodGet, h.snapshot)
+	case strings.HasPrefix(path, "/topics/"):
+		h.allow(w, r, http.MethodPost, h.publish)
+	case path == "/subscriptions":
+		switch r.Method {
+		case http.MethodPost:
+			h.subscribe(w, r)
+		case http.MethodDelete:
+			h.unsubscribe(w, r)
+		default:
+			w.Header().Set("Allow", "POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	case path == "/poll":
+		h.allow(w, r, http.MethodGet, h.poll)
+	case path == "/heartbeat":
+		h.allow(w, r, http.MethodPost, h.heartbeat)
+	case path == "/healthz":
+		// probes don't carry principal, so the broker itself is checked
+		Healthz(h.ps).ServeHTTP(w, r)
+	case path == "/readyz":
+		Readyz(h.ps).ServeHTTP(w, r)
+	case path == "/openapi.json":
+		OpenAPI().ServeHTTP(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Calling endpoint (fn) only if request method is (method)
+func (h *Handler) allow(w http.ResponseWriter, r *http.Request, method string, fn http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	fn(w, r)
+}
+
+// Broker acting on behalf of principal of request (r), the broker itself if neither Authenticate nor Principal is set
+func (h *Handler) broker(r *http.Request) pubsub.PubSuber {
+	if h.Authenticate != nil {
+		return h.ps.As(PrincipalOf(r.Context()))
+	}
+	if h.Principal == nil {
+		return h.ps
+	}
+	return h.ps.As(h.Principal(r))
+}
+
+// Responds with 403 and returns false if Authorizer denies operation (op) of broker (ps)
+func authorize(w http.ResponseWriter, ps pubsub.PubSuber, op pubsub.Operation, tn, sn string) bool {
+	if err := pubsub.Authorize(ps, op, tn, sn); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// POST /topics/{tn}
+func (h *Handler) publish(w http.ResponseWriter, r *http.Request) {
+	tn, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/topics/"))
+	if err != nil || tn == "" {
+		http.Error(w, "invalid topic name", http.StatusBadRequest)
+		return
+	}
+	body := r.Body
+	if h.MaxBodySize > 0 {
+		body = http.MaxBytesReader(w, body, h.MaxBodySize)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msg := pubsub.Message{ID: r.Header.Get(HeaderIdempotencyKey), Body: b}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		msg.Headers = map[string]string{pubsub.HeaderContentType: ct}
+	}
+	if _, err := h.broker(r).PublishMsg(tn, msg); errors.Is(err, pubsub.ErrQueueFull) || errors.Is(err, pubsub.ErrClosed) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if errors.Is(err, pubsub.ErrRateLimited) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	} else if errors.Is(err, pubsub.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if errors.Is(err, pubsub.ErrNoSubscriptions) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, pubsub.ErrInvalidMessage) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// POST /subscriptions
+func (h *Handler) subscribe(w http.ResponseWriter, r *http.Request) {
+	var req subscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Topic == "" || req.Subscription == "" {
+		http.Error(w, "topic and subscription are required", http.StatusBadRequest)
+		return
+	}
+	ps := h.broker(r)
+	if !authorize(w, ps, pubsub.OpSubscribe, req.Topic, req.Subscription) {
+		return
+	}
+	if h.IdleTimeout > 0 {
+		ps.SubscribeWithOptions(req.Topic, req.Subscription, pubsub.Options{IdleTimeout: h.IdleTimeout})
+	} else {
+		ps.Subscribe(req.Topic, req.Subscription)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /subscriptions?topic=tn&sub=sn
+func (h *Handler) unsubscribe(w http.ResponseWriter, r *http.Request) {
+	tn, sn, ok := names(w, r)
+	if !ok {
+		return
+	}
+	ps := h.broker(r)
+	if !authorize(w, ps, pubsub.OpSubscribe, tn, sn) {
+		return
+	}
+	ps.Unsubscribe(tn, sn)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /poll?topic=tn&sub=sn&wait=30s
+func (h *Handler) poll(w http.ResponseWriter, r *http.Request) {
+	tn, sn, ok := names(w, r)
+	if !ok {
+		return
+	}
+	var wait time.Duration
+	if v := r.URL.Query().Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			http.Error(w, "invalid wait duration", http.StatusBadRequest)
+			return
+		}
+		wait = d
+	}
+	maxWait := h.MaxWait
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWait
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+
+	id := r.URL.Query().Get("session")
+	var ack uint64
+	if v := r.URL.Query().Get("ack"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || id == "" {
+			http.Error(w, "invalid ack", http.StatusBadRequest)
+			return
+		}
+		ack = n
+	}
+
+	ps := h.broker(r)
+	var msg *pubsub.Message
+	var seq uint64
+	var err error
+	if id != "" {
+		msg, seq, err = h.pollSession(r.Context(), ps, tn, sn, id, ack, wait)
+	} else if wait > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		msg, err = ps.PollMsgWait(ctx, tn, sn)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			msg, err = nil, nil
+		}
+	} else {
+		msg, err = ps.PollMsg(tn, sn)
+		if errors.Is(err, pubsub.ErrEmpty) {
+			msg, err = nil, nil
+		}
+	}
+	switch {
+	case errors.Is(err, pubsub.ErrClosed), errors.Is(err, pubsub.ErrPaused):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, pubsub.ErrRateLimited):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, pubsub.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, pubsub.ErrNoSubscriptions):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case msg == nil:
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		ct := msg.Headers[pubsub.HeaderContentType]
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ct)
+		if seq > 0 {
+			w.Header().Set(HeaderDelivery, strconv.FormatUint(seq, 10))
+		}
+		if msg.DeliveryAttempt > 0 {
+			w.Header().Set(HeaderDeliveryAttempt, strconv.Itoa(msg.DeliveryAttempt))
+		}
+		_, _ = w.Write(msg.Body)
+	}
+}
+
+// POST /heartbeat?topic=tn&sub=sn&session=id
+func (h *Handler) heartbeat(w http.ResponseWriter, r *http.Request) {
+	tn, sn, ok := names(w, r)
+	if !ok {
+		return
+	}
+	id := r.URL.Query().Get("session")
+	if id == "" {
+		http.Error(w, "session is required", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, h.broker(r), pubsub.OpPoll, tn, sn) {
+		return
+	}
+	if !h.Sessions.Heartbeat(tn, sn, id) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Acknowledging messages of session (id) up to (ack) and fetching the next message waiting up to (wait)
+func (h *Handler) pollSession(ctx context.Context, ps pubsub.PubSuber, tn, sn, id string, ack uint64,
+	wait time.Duration) (*pubsub.Message, uint64, error) {
+	sess := h.Sessions.Resume(tn, sn, id)
+	if ack > 0 {
+		if err := sess.Ack(ps, ack); err != nil {
+			return nil, 0, err
+		}
+	}
+	if wait <= 0 {
+		return sess.Next(ps)
+	}
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	msg, seq, err := sess.NextWait(ctx, ps)
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return nil, 0, nil
+	}
+	return msg, seq, err
+}
+
+// GET /topics
+func (h *Handler) topics(w http.ResponseWriter, r *http.Request) {
+	tns := h.broker(r).Topics()
+	if tns == nil {
+		tns = []string{}
+	}
+	writeJSON(w, tns)
+}
+
+// GET /stats
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.broker(r).Stats())
+}
+
+// DELETE /messages?topic=tn&sub=sn
+func (h *Handler) purge(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tn, sn := q.Get("topic"), q.Get("sub")
+	if tn == "" {
+		http.Error(w, "topic is required", http.StatusBadRequest)
+		return
+	}
+	var n int
+	var err error
+	if sn == "" {
+		n, err = h.broker(r).PurgeTopic(tn)
+	} else {
+		n, err = h.broker(r).PurgeSubscription(tn, sn)
+	}
+	switch {
+	case errors.Is(err, pubsub.ErrClosed):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, pubsub.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, pubsub.ErrNoSubscriptions):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, map[string]int{"purged": n})
+	}
+}
+
+// GET /snapshot
+func (h *Handler) snapshot(w http.ResponseWriter, r *http.Request) {
+	ps := h.broker(r)
+	if !authorize(w, ps, pubsub.OpManage, "", "") {
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_ = ps.Snapshot(w)
+}
+
+// Writing value (v) as JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Reading topic and subscription names from query string, responds with 400 if some of them is missing
+func names(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	q := r.URL.Query()
+	tn, sn := q.Get("topic"), q.Get("sub")
+	if tn == "" || sn == "" {
+		http.Error(w, "topic and sub are required", http.StatusBadRequest)
+		return "", "", false
+	}
+	return tn, sn, true
+}
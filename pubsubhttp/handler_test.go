@@ -0,0 +1,304 @@
+package pubsubhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+func do(t *testing.T, h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_PublishPoll(t *testing.T) {
+	h := NewHandler(pubsub.New())
+	tn := "some/topic"
+	poll := "/poll?" + url.Values{"topic": {tn}, "sub": {"subscriber/id"}}.Encode()
+	if rec := do(t, h, http.MethodGet, poll, ""); rec.Code != http.StatusBadRequest {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodPost, "/subscriptions", `{"topic":"some/topic","subscription":"subscriber/id"}`); rec.Code != http.StatusNoContent {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodGet, poll, ""); rec.Code != http.StatusNotFound {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodPost, "/topics/"+url.PathEscape(tn), "message"); rec.Code != http.StatusAccepted {
+		t.Fatal(rec.Code)
+	}
+	rec := do(t, h, http.MethodGet, poll, "")
+	if b, _ := io.ReadAll(rec.Body); rec.Code != http.StatusOK || string(b) != "message" {
+		t.Fatal(rec.Code, string(b))
+	}
+	if rec := do(t, h, http.MethodGet, "/unknown", ""); rec.Code != http.StatusNotFound {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodDelete, "/subscriptions"+poll[len("/poll"):], ""); rec.Code != http.StatusNoContent {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodGet, poll, ""); rec.Code != http.StatusBadRequest {
+		t.Fatal(rec.Code)
+	}
+}
+
+func TestHandler_LongPoll(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	ps.Subscribe("topic", "sub")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ps.Publish("topic", []byte("message"))
+	}()
+	rec := do(t, h, http.MethodGet, "/poll?topic=topic&sub=sub&wait=5s", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "message" {
+		t.Fatal(rec.Code)
+	}
+	start := time.Now()
+	if rec := do(t, h, http.MethodGet, "/poll?topic=topic&sub=sub&wait=10ms", ""); rec.Code != http.StatusNotFound {
+		t.Fatal(rec.Code)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.FailNow()
+	}
+	if rec := do(t, h, http.MethodGet, "/poll?topic=topic&sub=sub&wait=soon", ""); rec.Code != http.StatusBadRequest {
+		t.Fatal(rec.Code)
+	}
+}
+
+func TestHandler_MethodNotAllowed(t *testing.T) {
+	h := NewHandler(pubsub.New())
+	if rec := do(t, h, http.MethodGet, "/topics/topic", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodPut, "/subscriptions", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatal(rec.Code)
+	}
+}
+
+func TestHandler_PublishNoSubscribers(t *testing.T) {
+	h := NewHandler(pubsub.New(pubsub.WithNoSubscribersError(true)))
+	if rec := do(t, h, http.MethodPost, "/topics/orders", "message"); rec.Code != http.StatusNotFound {
+		t.Fatal(rec.Code)
+	}
+}
+
+func TestHandler_Admin(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	ps.Subscribe("orders", "billing")
+	ps.Publish("orders", []byte("message"))
+	if rec := do(t, h, http.MethodGet, "/topics", ""); rec.Code != http.StatusOK || rec.Body.String() != "[\"orders\"]\n" {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+	if rec := do(t, h, http.MethodGet, "/stats", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"Pending":1`) {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+	if rec := do(t, h, http.MethodGet, "/snapshot", ""); rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodDelete, "/messages?topic=orders&sub=unknown", ""); rec.Code != http.StatusNotFound {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodDelete, "/messages?topic=orders&sub=billing", ""); rec.Code != http.StatusOK || rec.Body.String() != `{"purged":1}`+"\n" {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandler_RateLimited(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	tn := "some/topic"
+	_ = ps.CreateTopic(tn, pubsub.TopicConfig{PublishRate: 0.001})
+	ps.SubscribeWithOptions(tn, "subscriber/id", pubsub.Options{PollRate: 0.001})
+	poll := "/poll?" + url.Values{"topic": {tn}, "sub": {"subscriber/id"}}.Encode()
+	for _, code := range []int{http.StatusAccepted, http.StatusTooManyRequests} {
+		if rec := do(t, h, http.MethodPost, "/topics/"+url.PathEscape(tn), "message"); rec.Code != code {
+			t.Fatal(rec.Code)
+		}
+	}
+	for _, code := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if rec := do(t, h, http.MethodGet, poll, ""); rec.Code != code {
+			t.Fatal(rec.Code)
+		}
+	}
+}
+
+// PubSuber wrapping errors of publish, as layers over the broker do
+type wrapping struct {
+	pubsub.PubSuber
+	err error
+}
+
+func (w wrapping) PublishMsg(tn string, msg pubsub.Message) (string, error) {
+	return "", fmt.Errorf("wrapped: %w", w.err)
+}
+
+func TestHandler_WrappedErrors(t *testing.T) {
+	for err, code := range map[error]int{
+		pubsub.ErrQueueFull:      http.StatusServiceUnavailable,
+		pubsub.ErrClosed:         http.StatusServiceUnavailable,
+		pubsub.ErrRateLimited:    http.StatusTooManyRequests,
+		pubsub.ErrInvalidMessage: http.StatusBadRequest,
+	} {
+		h := NewHandler(wrapping{PubSuber: pubsub.New(), err: err})
+		if rec := do(t, h, http.MethodPost, "/topics/topic", "message"); rec.Code != code {
+			t.Fatal(err, rec.Code)
+		}
+	}
+}
+
+func TestHandler_IdempotencyKey(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	tn := "some/topic"
+	_ = ps.CreateTopic(tn, pubsub.TopicConfig{DedupWindow: time.Minute})
+	ps.Subscribe(tn, "subscriber/id")
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/topics/"+url.PathEscape(tn), strings.NewReader("message"))
+		req.Header.Set(HeaderIdempotencyKey, "order-1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusAccepted {
+			t.Fatal(rec.Code)
+		}
+	}
+	if depth, _ := ps.Depth(tn, "subscriber/id"); depth != 1 {
+		t.Fatal(depth)
+	}
+}
+
+func TestHandler_InvalidMessage(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	ps.Subscribe("some/topic", "subscriber/id")
+	ps.SetValidator("some/topic", pubsub.ValidatorFunc(func(tn string, msg *pubsub.Message) error {
+		return errors.New("empty message")
+	}))
+	if rec := do(t, h, http.MethodPost, "/topics/some%2Ftopic", "message"); rec.Code != http.StatusBadRequest {
+		t.Fatal(rec.Code)
+	}
+}
+
+func TestHandler_ContentType(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	ps.Subscribe("some/topic", "subscriber/id")
+	poll := "/poll?" + url.Values{"topic": {"some/topic"}, "sub": {"subscriber/id"}}.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/topics/some%2Ftopic", strings.NewReader(`{"id":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	do(t, h, http.MethodPost, "/topics/some%2Ftopic", "raw")
+	for _, ct := range []string{"application/json", "application/octet-stream"} {
+		if rec := do(t, h, http.MethodGet, poll, ""); rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ct {
+			t.Fatal(rec.Code, rec.Header())
+		}
+	}
+}
+
+func TestHandler_Principal(t *testing.T) {
+	ps := pubsub.New(pubsub.WithAuthorizer(pubsub.AuthorizerFunc(func(op pubsub.Operation, tn, sn string, principal any) error {
+		if principal == "admin" || op == pubsub.OpPoll {
+			return nil
+		}
+		return pubsub.ErrForbidden
+	})))
+	h := NewHandler(ps)
+	h.Principal = func(r *http.Request) any { return r.URL.Query().Get("user") }
+	tn := "some/topic"
+	sub := `{"topic":"some/topic","subscription":"subscriber/id"}`
+	if rec := do(t, h, http.MethodPost, "/subscriptions?user=guest", sub); rec.Code != http.StatusForbidden {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodPost, "/subscriptions?user=admin", sub); rec.Code != http.StatusNoContent {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodPost, "/topics/"+url.PathEscape(tn)+"?user=guest", "message"); rec.Code != http.StatusForbidden {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodPost, "/topics/"+url.PathEscape(tn)+"?user=admin", "message"); rec.Code != http.StatusAccepted {
+		t.Fatal(rec.Code)
+	}
+	poll := "/poll?" + url.Values{"topic": {tn}, "sub": {"subscriber/id"}, "user": {"guest"}}.Encode()
+	if rec := do(t, h, http.MethodGet, poll, ""); rec.Code != http.StatusOK || rec.Body.String() != "message" {
+		t.Fatal(rec.Code)
+	}
+	unsubscribe := "/subscriptions?" + url.Values{"topic": {tn}, "sub": {"subscriber/id"}, "user": {"guest"}}.Encode()
+	if rec := do(t, h, http.MethodDelete, unsubscribe, ""); rec.Code != http.StatusForbidden {
+		t.Fatal(rec.Code)
+	}
+}
+
+func TestHandler_Session(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	ps.Subscribe("orders", "billing")
+	ps.Publish("orders", []byte("1"))
+	ps.Publish("orders", []byte("2"))
+	poll := "/poll?topic=orders&sub=billing&session=s1"
+	rec := do(t, h, http.MethodGet, poll, "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "1" || rec.Header().Get(HeaderDelivery) != "1" ||
+		rec.Header().Get(HeaderDeliveryAttempt) != "1" {
+		t.Fatal(rec.Code, rec.Body.String(), rec.Header())
+	}
+	// response was lost, the client polls again without acknowledging
+	rec = do(t, h, http.MethodGet, poll, "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "1" || rec.Header().Get(HeaderDelivery) != "1" {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+	rec = do(t, h, http.MethodGet, poll+"&ack=1&wait=1s", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "2" || rec.Header().Get(HeaderDelivery) != "2" {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+	if rec := do(t, h, http.MethodGet, poll+"&ack=2", ""); rec.Code != http.StatusNotFound {
+		t.Fatal(rec.Code)
+	}
+	if depth, _ := ps.Depth("orders", "billing"); depth != 0 {
+		t.Fatal(depth)
+	}
+	if rec := do(t, h, http.MethodGet, "/poll?topic=orders&sub=billing&ack=x", ""); rec.Code != http.StatusBadRequest {
+		t.Fatal(rec.Code)
+	}
+}
+
+func TestHandler_Heartbeat(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	h.Sessions = pubsub.NewSessions(50 * time.Millisecond)
+	h.Sessions.SetExpiry(pubsub.SessionUnsubscribe)
+	ps.Subscribe("orders", "billing")
+	heartbeat := "/heartbeat?topic=orders&sub=billing&session=s1"
+	if rec := do(t, h, http.MethodPost, heartbeat, ""); rec.Code != http.StatusNotFound {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodGet, "/poll?topic=orders&sub=billing&session=s1", ""); rec.Code != http.StatusNotFound {
+		t.Fatal(rec.Code)
+	}
+	for i := 0; i < 5; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if rec := do(t, h, http.MethodPost, heartbeat, ""); rec.Code != http.StatusNoContent {
+			t.Fatal(rec.Code)
+		}
+	}
+	if subs, _ := ps.Subscriptions("orders"); len(subs) != 1 {
+		t.Fatal(subs)
+	}
+	// the client disappeared
+	time.Sleep(150 * time.Millisecond)
+	if subs, _ := ps.Subscriptions("orders"); len(subs) != 0 {
+		t.Fatal(subs)
+	}
+	if rec := do(t, h, http.MethodPost, heartbeat, ""); rec.Code != http.StatusNotFound {
+		t.Fatal(rec.Code)
+	}
+}
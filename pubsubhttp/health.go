@@ -0,0 +1,40 @@
+package pubsubhttp
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Liveness probe of broker (ps): responds with 200 if broker isn't closed, 503 otherwise
+// Body is pubsub.HealthReport as JSON
+func Healthz(ps pubsub.PubSuber) http.Handler {
+	return healthHandler(ps, func(h pubsub.HealthReport) bool { return h.Live })
+}
+
+// Readiness probe of broker (ps): responds with 200 if broker accepts messages, 503 otherwise
+// Body is pubsub.HealthReport as JSON, its Problems tell why broker isn't ready
+func Readyz(ps pubsub.PubSuber) http.Handler {
+	return healthHandler(ps, func(h pubsub.HealthReport) bool { return h.Ready })
+}
+
+// Responding with report of broker (ps) and status by (ok)
+func healthHandler(ps pubsub.PubSuber, ok func(h pubsub.HealthReport) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h := ps.Health()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if ok(h) {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(h)
+	})
+}
@@ -0,0 +1,44 @@
+package pubsubhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+func TestHealth(t *testing.T) {
+	var connErr error
+	ps := pubsub.New(pubsub.WithHealthCheck("kafka", func() error { return connErr }))
+	h := NewHandler(ps)
+	ps.Subscribe("orders", "billing")
+	ps.Publish("orders", []byte("order"))
+	rec := do(t, h, http.MethodGet, "/readyz", "")
+	var report pubsub.HealthReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil || rec.Code != http.StatusOK {
+		t.Fatal(rec.Code, err)
+	}
+	if !report.Live || !report.Ready || report.Pending != 1 || report.Memory != 5 {
+		t.Fatal(report)
+	}
+	connErr = errors.New("broker is unreachable")
+	if rec := do(t, h, http.MethodGet, "/readyz", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, h, http.MethodGet, "/healthz", ""); rec.Code != http.StatusOK {
+		t.Fatal(rec.Code)
+	}
+	// pending message isn't drained
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = ps.Close(ctx)
+	if rec := do(t, Healthz(ps), http.MethodGet, "/", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatal(rec.Code)
+	}
+	if rec := do(t, Readyz(ps), http.MethodPost, "/", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatal(rec.Code)
+	}
+}
@@ -0,0 +1,29 @@
+package pubsubhttp
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPI 3 document of Handler endpoints, see OpenAPI
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPI 3 document of Handler endpoints as JSON, e.g. for client generators of other languages
+func OpenAPISpec() []byte {
+	return append([]byte(nil), openAPISpec...)
+}
+
+// Handler serving OpenAPI 3 document of Handler endpoints (also available as GET /openapi.json of Handler)
+func OpenAPI() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openAPISpec)
+	})
+}
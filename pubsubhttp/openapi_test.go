@@ -0,0 +1,40 @@
+package pubsubhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+func TestOpenAPI(t *testing.T) {
+	var doc struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(OpenAPISpec(), &doc); err != nil || !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatal(doc.OpenAPI, err)
+	}
+	lib := pubsub.New()
+	lib.Subscribe("orders", "billing")
+	h := NewHandler(lib)
+	// every documented operation is routed by the handler
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			req := httptest.NewRequest(strings.ToUpper(method), strings.Replace(path, "{topic}", "orders", 1), nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code == http.StatusMethodNotAllowed || rec.Code == http.StatusNotFound && rec.Body.String() == "404 page not found\n" {
+				t.Fatal(method, path, rec.Code)
+			}
+		}
+	}
+	h.Authenticate = BearerToken(pubsub.StaticTokens(nil))
+	rec := do(t, h, http.MethodGet, "/openapi.json", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || rec.Body.Len() != len(OpenAPISpec()) {
+		t.Fatal(rec.Code, rec.Header())
+	}
+}
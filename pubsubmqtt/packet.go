@@ -0,0 +1,159 @@
+package pubsubmqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MQTT control packet types (MQTT 3.1.1, section 2.2.1)
+const (
+	typeConnect     = 1
+	typeConnack     = 2
+	typePublish     = 3
+	typePuback      = 4
+	typePubrec      = 5
+	typePubrel      = 6
+	typePubcomp     = 7
+	typeSubscribe   = 8
+	typeSuback      = 9
+	typeUnsubscribe = 10
+	typeUnsuback    = 11
+	typePingreq     = 12
+	typePingresp    = 13
+	typeDisconnect  = 14
+)
+
+// Return codes of CONNACK (MQTT 3.1.1, section 3.2.2.3)
+const (
+	connAccepted          = 0
+	connRefusedVersion    = 1
+	connRefusedIdentifier = 2
+	connRefusedBadAuth    = 4
+)
+
+// Return code of SUBACK for rejected filter
+const subFailure = 0x80
+
+// Error happens if client sends packet violating the protocol
+var errProtocol = errors.New("mqtt protocol error")
+
+// Error happens if client sends packet longer than MaxPacketSize
+var errTooLarge = errors.New("mqtt packet too large")
+
+// Error happens if server refused connection with CONNACK
+var errRefused = errors.New("mqtt connection refused")
+
+// Control packet: type, flags of fixed header and the rest of packet
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+}
+
+// Reading single packet not longer than (max) bytes
+func readPacket(r *bufio.Reader, max int) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	// remaining length is encoded in up to 4 bytes, 7 bits per byte (section 2.2.3)
+	n, shift := 0, 0
+	for i := 0; ; i++ {
+		if i == 4 {
+			return packet{}, errProtocol
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		n |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	if max > 0 && n > max {
+		return packet{}, errTooLarge
+	}
+	p := packet{typ: header >> 4, flags: header & 0x0F, body: make([]byte, n)}
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		return packet{}, err
+	}
+	return p, nil
+}
+
+// Writing single packet of type (typ) with (flags) and (body)
+func writePacket(w *bufio.Writer, typ, flags byte, body []byte) error {
+	header := []byte{typ<<4 | flags}
+	n := len(body)
+	for {
+		b := byte(n & 0x7F)
+		if n >>= 7; n > 0 {
+			b |= 0x80
+		}
+		header = append(header, b)
+		if n == 0 {
+			break
+		}
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Appending two bytes integer
+func appendUint16(b []byte, v uint16) []byte {
+	return binary.BigEndian.AppendUint16(b, v)
+}
+
+// Appending length prefixed string
+func appendString(b []byte, s string) []byte {
+	return append(appendUint16(b, uint16(len(s))), s...)
+}
+
+// Decoder of packet body, the first error is kept and following reads return zero values
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.b) < 1 {
+		d.err = errProtocol
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *decoder) uint16() uint16 {
+	if d.err != nil || len(d.b) < 2 {
+		d.err = errProtocol
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.b)
+	d.b = d.b[2:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := int(d.uint16())
+	if d.err != nil || len(d.b) < n {
+		d.err = errProtocol
+		return nil
+	}
+	v := d.b[:n:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
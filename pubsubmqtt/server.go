@@ -0,0 +1,692 @@
+/*
+MQTT 3.1.1 gateway for pubsub package. Server accepts MQTT connections, so IoT devices publish directly into the
+broker and subscribe to its topics without a separate MQTT broker:
+
+	s := pubsubmqtt.NewServer(ps)
+	err := s.ListenAndServe(":1883")
+
+MQTT topic names are topic names of broker, topic filters (with "+" and "#" wildcards) are wildcard topics, the
+client ID is the subscription name. QoS 0 and 1 are supported for delivery (QoS 2 subscriptions are granted QoS 1),
+publishing with QoS 2 is accepted with duplicates removed. PUBLISH with retain flag is published with
+PublishRetained (an empty message removes retained message of the topic).
+
+Messages are taken from subscription before they are written, so QoS 0 message may be lost if connection breaks
+during writing, QoS 1 messages are kept by session until client acknowledges them and are sent again when client
+reconnects with CleanSession=0. Sessions live in memory of Server, subscriptions of persistent sessions keep
+collecting messages in the broker while client is offline, until Server.SessionExpiry removes them. Clients not
+sending anything during 1.5 keep alive intervals are disconnected. Retain flag of delivered messages is always 0.
+*/
+package pubsubmqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Error returned by Serve and ListenAndServe after Close
+var ErrServerClosed = errors.New("pubsubmqtt: server closed")
+
+// Defaults of Server settings
+const (
+	DefaultMaxPacketSize  = 1 << 20
+	DefaultMaxInflight    = 64
+	DefaultConnectTimeout = 10 * time.Second
+)
+
+// Return code of CONNACK if Authenticate rejected client with pubsub.ErrForbidden
+const connRefusedNotAuthorized = 5
+
+// Server serves MQTT clients with broker
+// Authenticate - optional function checking credentials of client, returned principal is passed to Authorizer of
+// broker (see pubsub.WithAuthorizer), connection is refused if error is returned
+// MaxPacketSize - maximum length of packets sent by clients, DefaultMaxPacketSize is used if zero
+// MaxInflight - how many QoS 1 messages may wait for acknowledgement per client, DefaultMaxInflight is used if zero
+// ConnectTimeout - how long server waits for CONNECT packet, DefaultConnectTimeout is used if zero
+// SessionExpiry - persistent session of client offline longer is removed with its subscriptions, never if zero
+type Server struct {
+	ps             pubsub.PubSuber
+	Authenticate   func(clientID, username string, password []byte) (principal any, err error)
+	MaxPacketSize  int
+	MaxInflight    int
+	ConnectTimeout time.Duration
+	SessionExpiry  time.Duration
+	mux            sync.Mutex
+	closed         bool
+	sessions       map[string]*session
+	listeners      map[net.Listener]struct{}
+	conns          map[*conn]struct{}
+}
+
+// Constructor. Creates a Server serving broker (ps)
+func NewServer(ps pubsub.PubSuber) *Server {
+	return &Server{
+		ps:        ps,
+		sessions:  map[string]*session{},
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[*conn]struct{}{},
+	}
+}
+
+// Listening TCP address (addr) and serving connections until Close
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Accepting connections of listener (l) and serving each of them in a separate goroutine
+// ErrServerClosed is returned after Close, the listener is closed when Serve returns
+func (s *Server) Serve(l net.Listener) error {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mux.Unlock()
+	defer func() {
+		s.mux.Lock()
+		delete(s.listeners, l)
+		s.mux.Unlock()
+		l.Close()
+	}()
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			s.mux.Lock()
+			closed := s.closed
+			s.mux.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.ServeConn(nc)
+	}
+}
+
+// Closing listeners and active connections, sessions of clients are dropped
+func (s *Server) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	return nil
+}
+
+// Serving single client connection (nc) until either side closes it
+func (s *Server) ServeConn(nc net.Conn) {
+	c := &conn{Conn: nc, s: s, r: bufio.NewReader(nc), w: bufio.NewWriter(nc), done: make(chan struct{})}
+	defer close(c.done)
+	defer nc.Close()
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return
+	}
+	s.conns[c] = struct{}{}
+	s.mux.Unlock()
+	defer func() {
+		s.mux.Lock()
+		delete(s.conns, c)
+		s.mux.Unlock()
+	}()
+
+	keepalive, err := s.connect(c)
+	if err != nil {
+		return
+	}
+	err = c.serve(keepalive)
+	s.disconnect(c, err == nil)
+}
+
+func (s *Server) maxPacketSize() int {
+	if s.MaxPacketSize > 0 {
+		return s.MaxPacketSize
+	}
+	return DefaultMaxPacketSize
+}
+
+// Reading CONNECT packet, authenticating client and attaching it to its session
+// Returns keep alive interval of client
+func (s *Server) connect(c *conn) (time.Duration, error) {
+	timeout := s.ConnectTimeout
+	if timeout <= 0 {
+		timeout = DefaultConnectTimeout
+	}
+	c.SetReadDeadline(time.Now().Add(timeout))
+	p, err := readPacket(c.r, s.maxPacketSize())
+	if err != nil {
+		return 0, err
+	}
+	if p.typ != typeConnect {
+		return 0, errProtocol
+	}
+	d := decoder{b: p.body}
+	name, level, flags := d.string(), d.byte(), d.byte()
+	keepalive := time.Duration(d.uint16()) * time.Second
+	id := d.string()
+	if d.err != nil || name != "MQTT" || flags&0x01 != 0 {
+		return 0, errProtocol
+	}
+	if level != 4 {
+		return 0, c.refuse(connRefusedVersion)
+	}
+	if flags&0x04 != 0 {
+		c.will = &will{tn: d.string(), body: d.bytes(), retain: flags&0x20 != 0}
+		if !validTopic(c.will.tn) {
+			return 0, errProtocol
+		}
+	}
+	var username string
+	var password []byte
+	if flags&0x80 != 0 {
+		username = d.string()
+	}
+	if flags&0x40 != 0 {
+		password = d.bytes()
+	}
+	if d.err != nil {
+		return 0, errProtocol
+	}
+	clean := flags&0x02 != 0
+	if id == "" {
+		if !clean {
+			return 0, c.refuse(connRefusedIdentifier)
+		}
+		id = newClientID()
+	}
+	c.ps = s.ps
+	if s.Authenticate != nil {
+		principal, err := s.Authenticate(id, username, password)
+		if err != nil {
+			code := byte(connRefusedBadAuth)
+			if errors.Is(err, pubsub.ErrForbidden) {
+				code = connRefusedNotAuthorized
+			}
+			return 0, c.refuse(code)
+		}
+		c.ps = s.ps.As(principal)
+	}
+
+	present := s.attach(c, id, clean)
+	max := s.MaxInflight
+	if max <= 0 {
+		max = DefaultMaxInflight
+	}
+	c.slots = make(chan struct{}, max)
+	c.received = map[uint16]bool{}
+	c.cancels = map[string]context.CancelFunc{}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	if err := c.connack(present, connAccepted); err != nil {
+		s.disconnect(c, false)
+		return 0, err
+	}
+	if err := c.resume(); err != nil {
+		s.disconnect(c, false)
+		return 0, err
+	}
+	return keepalive, nil
+}
+
+// Attaching connection (c) to session of client (id), previous connection of the client is closed
+// Returns if persistent session existed already
+func (s *Server) attach(c *conn, id string, clean bool) bool {
+	for {
+		s.mux.Lock()
+		sess := s.sessions[id]
+		if sess == nil || sess.c == nil {
+			present := sess != nil && !clean
+			if sess != nil && clean {
+				for f := range sess.subs {
+					c.ps.Unsubscribe(f, id)
+				}
+			}
+			if !present {
+				sess = &session{id: id, subs: map[string]byte{}}
+				s.sessions[id] = sess
+			}
+			if sess.expiry != nil {
+				sess.expiry.Stop()
+				sess.expiry = nil
+			}
+			sess.clean, sess.c, c.sess = clean, c, sess
+			s.mux.Unlock()
+			return present
+		}
+		old := sess.c
+		s.mux.Unlock()
+		old.Close()
+		<-old.done
+	}
+}
+
+// Detaching connection (c) from its session, publishing will message if client didn't disconnect gracefully
+// Subscriptions of clean session are removed
+func (s *Server) disconnect(c *conn, graceful bool) {
+	c.cancel()
+	c.wg.Wait()
+	if !graceful && c.will != nil {
+		if c.will.retain {
+			_ = c.ps.PublishRetained(c.will.tn, c.will.body)
+		} else {
+			c.ps.Publish(c.will.tn, c.will.body)
+		}
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c.sess.c = nil
+	if c.sess.clean {
+		s.remove(c.sess, c.ps)
+	} else if s.SessionExpiry > 0 {
+		sess, ps := c.sess, c.ps
+		sess.expiry = time.AfterFunc(s.SessionExpiry, func() {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+			if sess.c == nil && s.sessions[sess.id] == sess {
+				s.remove(sess, ps)
+			}
+		})
+	}
+}
+
+// Removing session (sess) with its subscriptions of broker (ps), lock of Server must be held by caller
+func (s *Server) remove(sess *session, ps pubsub.PubSuber) {
+	for f := range sess.subs {
+		ps.Unsubscribe(f, sess.id)
+	}
+	if s.sessions[sess.id] == sess {
+		delete(s.sessions, sess.id)
+	}
+}
+
+// Random client ID assigned to client connecting with empty one
+func newClientID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "auto-" + hex.EncodeToString(b)
+}
+
+// Checks if topic name (tn) may be used for publishing
+func validTopic(tn string) bool {
+	return tn != "" && !strings.ContainsAny(tn, "+#\x00")
+}
+
+// Checks if topic filter (f) is valid, wildcards must occupy whole levels and "#" must be the last level
+func validFilter(f string) bool {
+	if f == "" || strings.ContainsRune(f, 0) {
+		return false
+	}
+	levels := strings.Split(f, "/")
+	for i, level := range levels {
+		switch {
+		case level == "#" && i != len(levels)-1:
+			return false
+		case level != "+" && level != "#" && strings.ContainsAny(level, "+#"):
+			return false
+		}
+	}
+	return true
+}
+
+// Will message published when client disconnects without DISCONNECT packet
+type will struct {
+	tn     string
+	body   []byte
+	retain bool
+}
+
+// Message sent with QoS 1 and waiting for PUBACK
+type outgoing struct {
+	id   uint16
+	tn   string
+	body []byte
+}
+
+// Session of client: subscriptions and QoS 1 messages not acknowledged yet, kept between connections unless clean
+// subs - granted QoS by topic filters, c - connection of client (nil if client is offline), expiry - removes session
+// of offline client, protected by mux of Server
+// lastID, inflight - protected by mux
+type session struct {
+	id       string
+	clean    bool
+	subs     map[string]byte
+	c        *conn
+	expiry   *time.Timer
+	mux      sync.Mutex
+	lastID   uint16
+	inflight []outgoing
+}
+
+// Tracking message sent with QoS 1, returns its packet identifier
+func (s *session) track(tn string, body []byte) uint16 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for {
+		if s.lastID++; s.lastID == 0 {
+			continue
+		}
+		if !s.inUse(s.lastID) {
+			break
+		}
+	}
+	s.inflight = append(s.inflight, outgoing{id: s.lastID, tn: tn, body: body})
+	return s.lastID
+}
+
+func (s *session) inUse(id uint16) bool {
+	for _, m := range s.inflight {
+		if m.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Forgetting message acknowledged by client, returns if it was tracked
+func (s *session) ack(id uint16) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for i, m := range s.inflight {
+		if m.id == id {
+			s.inflight = append(s.inflight[:i], s.inflight[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Connection of client with a mutex for writing, because messages are written by delivering goroutines
+// ps - broker (as principal of client), ctx - done when connection is closed, wg - running deliveries
+// cancels - stopping deliveries by topic filters, received - identifiers of QoS 2 messages waiting for PUBREL,
+// both are used by reading goroutine only
+// slots - semaphore of QoS 1 messages in flight, done - closed when connection is served
+type conn struct {
+	net.Conn
+	s        *Server
+	r        *bufio.Reader
+	mux      sync.Mutex
+	w        *bufio.Writer
+	ps       pubsub.PubSuber
+	sess     *session
+	will     *will
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	cancels  map[string]context.CancelFunc
+	received map[uint16]bool
+	slots    chan struct{}
+	done     chan struct{}
+}
+
+// Writing packet of type (typ) with (flags) and (body)
+func (c *conn) write(typ, flags byte, body []byte) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return writePacket(c.w, typ, flags, body)
+}
+
+func (c *conn) connack(present bool, code byte) error {
+	var flags byte
+	if present {
+		flags = 1
+	}
+	return c.write(typeConnack, 0, []byte{flags, code})
+}
+
+// Writing CONNACK with return code (code) refusing connection, errRefused is returned if it's written
+func (c *conn) refuse(code byte) error {
+	if err := c.connack(false, code); err != nil {
+		return err
+	}
+	return errRefused
+}
+
+// Writing PUBLISH packet, packet identifier (id) is written for QoS 1 only
+func (c *conn) publish(tn string, body []byte, qos byte, id uint16, dup bool) error {
+	flags := qos << 1
+	if dup {
+		flags |= 0x08
+	}
+	b := appendString(make([]byte, 0, len(tn)+len(body)+4), tn)
+	if qos > 0 {
+		b = appendUint16(b, id)
+	}
+	return c.write(typePublish, flags, append(b, body...))
+}
+
+// Writing packet of type (typ) with packet identifier (id) only
+func (c *conn) ack(typ, flags byte, id uint16) error {
+	return c.write(typ, flags, appendUint16(nil, id))
+}
+
+// Sending again messages not acknowledged during previous connection and restarting deliveries of subscriptions
+func (c *conn) resume() error {
+	c.sess.mux.Lock()
+	inflight := append([]outgoing(nil), c.sess.inflight...)
+	c.sess.mux.Unlock()
+	for _, m := range inflight {
+		select {
+		case c.slots <- struct{}{}:
+		default:
+		}
+		if err := c.publish(m.tn, m.body, 1, m.id, true); err != nil {
+			return err
+		}
+	}
+	for f, qos := range c.sess.subs {
+		c.start(f, qos)
+	}
+	return nil
+}
+
+// Reading packets until DISCONNECT or error, nil is returned in the first case
+// Client is disconnected if nothing is received during 1.5 keep alive intervals (keepalive)
+func (c *conn) serve(keepalive time.Duration) error {
+	max := c.s.maxPacketSize()
+	for {
+		deadline := time.Time{}
+		if keepalive > 0 {
+			deadline = time.Now().Add(keepalive * 3 / 2)
+		}
+		c.SetReadDeadline(deadline)
+		p, err := readPacket(c.r, max)
+		if err != nil {
+			return err
+		}
+		switch p.typ {
+		case typePublish:
+			err = c.handlePublish(p)
+		case typePuback:
+			d := decoder{b: p.body}
+			if id := d.uint16(); d.err == nil && c.sess.ack(id) {
+				select {
+				case <-c.slots:
+				default:
+				}
+			}
+		case typePubrel:
+			d := decoder{b: p.body}
+			id := d.uint16()
+			if d.err != nil || p.flags != 0x02 {
+				return errProtocol
+			}
+			delete(c.received, id)
+			err = c.ack(typePubcomp, 0, id)
+		case typeSubscribe:
+			err = c.handleSubscribe(p)
+		case typeUnsubscribe:
+			err = c.handleUnsubscribe(p)
+		case typePingreq:
+			err = c.write(typePingresp, 0, nil)
+		case typeDisconnect:
+			return nil
+		default:
+			return errProtocol
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Publishing message sent by client to the broker and acknowledging it according to QoS
+func (c *conn) handlePublish(p packet) error {
+	qos, retain := p.flags>>1&0x03, p.flags&0x01 != 0
+	d := decoder{b: p.body}
+	tn := d.string()
+	var id uint16
+	if qos > 0 {
+		id = d.uint16()
+	}
+	if d.err != nil || qos == 3 || !validTopic(tn) {
+		return errProtocol
+	}
+	switch qos {
+	case 0:
+		c.forward(tn, d.b, retain)
+		return nil
+	case 1:
+		c.forward(tn, d.b, retain)
+		return c.ack(typePuback, 0, id)
+	default:
+		if !c.received[id] {
+			c.received[id] = true
+			c.forward(tn, d.b, retain)
+		}
+		return c.ack(typePubrec, 0, id)
+	}
+}
+
+func (c *conn) forward(tn string, body []byte, retain bool) {
+	if retain {
+		_ = c.ps.PublishRetained(tn, body)
+		return
+	}
+	c.ps.Publish(tn, body)
+}
+
+// Subscribing client to topic filters, filters which are invalid or denied by Authorizer are rejected
+func (c *conn) handleSubscribe(p packet) error {
+	d := decoder{b: p.body}
+	id := d.uint16()
+	var filters []string
+	var codes []byte
+	for d.err == nil && len(d.b) > 0 {
+		filters = append(filters, d.string())
+		codes = append(codes, d.byte())
+	}
+	if d.err != nil || p.flags != 0x02 || len(filters) == 0 {
+		return errProtocol
+	}
+	sn := c.sess.id
+	for i, f := range filters {
+		if codes[i] > 2 {
+			return errProtocol
+		}
+		if !validFilter(f) || pubsub.Authorize(c.ps, pubsub.OpSubscribe, f, sn) != nil ||
+			pubsub.Authorize(c.ps, pubsub.OpPoll, f, sn) != nil {
+			codes[i] = subFailure
+			continue
+		}
+		codes[i] = min(codes[i], 1)
+		c.ps.Subscribe(f, sn)
+		c.s.mux.Lock()
+		c.sess.subs[f] = codes[i]
+		c.s.mux.Unlock()
+	}
+	if err := c.write(typeSuback, 0, append(appendUint16(nil, id), codes...)); err != nil {
+		return err
+	}
+	// retained messages are delivered after SUBACK
+	for i, f := range filters {
+		if codes[i] != subFailure {
+			c.start(f, codes[i])
+		}
+	}
+	return nil
+}
+
+// Unsubscribing client from topic filters, pending messages of subscriptions are dropped
+func (c *conn) handleUnsubscribe(p packet) error {
+	d := decoder{b: p.body}
+	id := d.uint16()
+	var filters []string
+	for d.err == nil && len(d.b) > 0 {
+		filters = append(filters, d.string())
+	}
+	if d.err != nil || p.flags != 0x02 || len(filters) == 0 {
+		return errProtocol
+	}
+	for _, f := range filters {
+		if cancel, ok := c.cancels[f]; ok {
+			cancel()
+			delete(c.cancels, f)
+		}
+		c.s.mux.Lock()
+		_, ok := c.sess.subs[f]
+		delete(c.sess.subs, f)
+		c.s.mux.Unlock()
+		if ok {
+			c.ps.Unsubscribe(f, c.sess.id)
+		}
+	}
+	return c.ack(typeUnsuback, 0, id)
+}
+
+// Starting delivery of messages of topic filter (f) with QoS (qos), running delivery of the filter is replaced
+func (c *conn) start(f string, qos byte) {
+	if cancel, ok := c.cancels[f]; ok {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.cancels[f] = cancel
+	c.wg.Add(1)
+	go c.deliver(ctx, f, qos)
+}
+
+// Polling subscription of topic filter (f) and sending messages until ctx is done or the subscription is removed
+// QoS 1 messages wait for a free slot, so at most MaxInflight messages aren't acknowledged
+func (c *conn) deliver(ctx context.Context, f string, qos byte) {
+	defer c.wg.Done()
+	for ctx.Err() == nil {
+		if qos == 1 {
+			select {
+			case <-ctx.Done():
+				return
+			case c.slots <- struct{}{}:
+			}
+		}
+		msg, err := c.ps.PollMsgWait(ctx, f, c.sess.id)
+		if err != nil {
+			if qos == 1 {
+				<-c.slots
+			}
+			return
+		}
+		var id uint16
+		if qos == 1 {
+			id = c.sess.track(msg.Topic, msg.Body)
+		}
+		if err := c.publish(msg.Topic, msg.Body, qos, id, false); err != nil {
+			c.Close()
+			return
+		}
+	}
+}
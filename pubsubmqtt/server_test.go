@@ -0,0 +1,267 @@
+package pubsubmqtt
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// MQTT client connection used by tests
+type client struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// Starting server of broker (ps) on a random port
+func serve(t *testing.T, s *Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return l.Addr().String()
+}
+
+// Connecting client (id) to server (addr), returns session present flag and return code of CONNACK
+func dial(t *testing.T, addr, id string, clean bool, username, password string) (*client, byte, byte) {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	t.Cleanup(func() { c.Close() })
+	flags := byte(0)
+	if clean {
+		flags |= 0x02
+	}
+	if username != "" {
+		flags |= 0xC0
+	}
+	b := appendString(nil, "MQTT")
+	b = append(b, 4, flags)
+	b = appendUint16(b, 60)
+	b = appendString(b, id)
+	if username != "" {
+		b = appendString(appendString(b, username), password)
+	}
+	c.send(t, typeConnect, 0, b)
+	p := c.expect(t, typeConnack)
+	return c, p.body[0], p.body[1]
+}
+
+func (c *client) send(t *testing.T, typ, flags byte, body []byte) {
+	if err := writePacket(c.w, typ, flags, body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *client) expect(t *testing.T, typ byte) packet {
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	p, err := readPacket(c.r, 0)
+	if err != nil || p.typ != typ {
+		t.Fatal(err, p.typ)
+	}
+	return p
+}
+
+func (c *client) subscribe(t *testing.T, f string, qos byte) byte {
+	c.send(t, typeSubscribe, 0x02, append(appendString(appendUint16(nil, 1), f), qos))
+	return c.expect(t, typeSuback).body[2]
+}
+
+func (c *client) publish(t *testing.T, tn, body string, flags byte, id uint16) {
+	b := appendString(nil, tn)
+	if flags&0x06 != 0 {
+		b = appendUint16(b, id)
+	}
+	c.send(t, typePublish, flags, append(b, body...))
+}
+
+// Reading PUBLISH, returns topic, body, flags and packet identifier
+func (c *client) receive(t *testing.T) (string, string, byte, uint16) {
+	p := c.expect(t, typePublish)
+	d := decoder{b: p.body}
+	tn := d.string()
+	var id uint16
+	if p.flags&0x06 != 0 {
+		id = d.uint16()
+	}
+	return tn, string(d.b), p.flags, id
+}
+
+func TestServer_PublishSubscribe(t *testing.T) {
+	lib := pubsub.New()
+	addr := serve(t, NewServer(lib))
+	sub, present, code := dial(t, addr, "sub", true, "", "")
+	if present != 0 || code != connAccepted {
+		t.FailNow()
+	}
+	if sub.subscribe(t, "sensors/+", 2) != 1 || sub.subscribe(t, "sensors/#/x", 0) != subFailure {
+		t.FailNow()
+	}
+	dev, _, _ := dial(t, addr, "", true, "", "")
+	dev.publish(t, "sensors/t1", "21", 0x02, 7)
+	if d := (decoder{b: dev.expect(t, typePuback).body}); d.uint16() != 7 {
+		t.FailNow()
+	}
+	// QoS 2 duplicates are published once
+	dev.publish(t, "sensors/t2", "22", 0x04, 8)
+	dev.expect(t, typePubrec)
+	dev.publish(t, "sensors/t2", "22", 0x0C, 8)
+	dev.expect(t, typePubrec)
+	dev.send(t, typePubrel, 0x02, appendUint16(nil, 8))
+	dev.expect(t, typePubcomp)
+
+	tn, body, flags, id := sub.receive(t)
+	if tn != "sensors/t1" || body != "21" || flags != 0x02 || id == 0 {
+		t.Fatal(tn, body, flags)
+	}
+	sub.send(t, typePuback, 0, appendUint16(nil, id))
+	if tn, body, _, _ := sub.receive(t); tn != "sensors/t2" || body != "22" {
+		t.FailNow()
+	}
+	sub.send(t, typePingreq, 0, nil)
+	sub.expect(t, typePingresp)
+	if depth, _ := lib.Depth("sensors/+", "sub"); depth != 0 {
+		t.FailNow()
+	}
+}
+
+func TestServer_Retained(t *testing.T) {
+	lib := pubsub.New()
+	addr := serve(t, NewServer(lib))
+	dev, _, _ := dial(t, addr, "dev", true, "", "")
+	dev.publish(t, "sensors/t1", "21", 0x03, 1)
+	dev.expect(t, typePuback)
+	sub, _, _ := dial(t, addr, "sub", true, "", "")
+	sub.subscribe(t, "sensors/#", 0)
+	if tn, body, flags, _ := sub.receive(t); tn != "sensors/t1" || body != "21" || flags != 0 {
+		t.FailNow()
+	}
+}
+
+func TestServer_PersistentSession(t *testing.T) {
+	lib := pubsub.New()
+	addr := serve(t, NewServer(lib))
+	c, _, _ := dial(t, addr, "dev", false, "", "")
+	c.subscribe(t, "orders", 1)
+	lib.Publish("orders", []byte("first"))
+	if _, body, _, _ := c.receive(t); body != "first" {
+		t.FailNow()
+	}
+	// the first message isn't acknowledged, the second one is published while client is offline
+	c.send(t, typeDisconnect, 0, nil)
+	c.Close()
+	time.Sleep(10 * time.Millisecond)
+	lib.Publish("orders", []byte("second"))
+
+	c, present, _ := dial(t, addr, "dev", false, "", "")
+	if present != 1 {
+		t.FailNow()
+	}
+	if _, body, flags, _ := c.receive(t); body != "first" || flags&0x08 == 0 {
+		t.FailNow()
+	}
+	if _, body, flags, _ := c.receive(t); body != "second" || flags&0x08 != 0 {
+		t.FailNow()
+	}
+	// clean session drops subscriptions
+	c.Close()
+	time.Sleep(10 * time.Millisecond)
+	if _, present, _ := dial(t, addr, "dev", true, "", ""); present != 0 {
+		t.FailNow()
+	}
+	if _, err := lib.Depth("orders", "dev"); err == nil {
+		t.FailNow()
+	}
+	if _, _, code := dial(t, addr, "", false, "", ""); code != connRefusedIdentifier {
+		t.FailNow()
+	}
+}
+
+func TestServer_SessionExpiry(t *testing.T) {
+	lib := pubsub.New()
+	s := NewServer(lib)
+	s.SessionExpiry = 50 * time.Millisecond
+	addr := serve(t, s)
+	c, _, _ := dial(t, addr, "dev", false, "", "")
+	c.subscribe(t, "orders", 1)
+	c.send(t, typeDisconnect, 0, nil)
+	c.Close()
+	// client coming back in time keeps its session
+	time.Sleep(10 * time.Millisecond)
+	c, present, _ := dial(t, addr, "dev", false, "", "")
+	if present != 1 {
+		t.FailNow()
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := lib.Depth("orders", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	c.send(t, typeDisconnect, 0, nil)
+	c.Close()
+	time.Sleep(150 * time.Millisecond)
+	if _, err := lib.Depth("orders", "dev"); !errors.Is(err, pubsub.ErrNoSubscriptions) {
+		t.Fatal(err)
+	}
+	if _, present, _ := dial(t, addr, "dev", false, "", ""); present != 0 {
+		t.FailNow()
+	}
+}
+
+func TestServer_Authenticate(t *testing.T) {
+	lib := pubsub.New(pubsub.WithAuthorizer(pubsub.AuthorizerFunc(func(op pubsub.Operation, tn, sn string, principal any) error {
+		if op == pubsub.OpSubscribe && tn == "admin/#" && principal != "admin" {
+			return pubsub.ErrForbidden
+		}
+		return nil
+	})))
+	s := NewServer(lib)
+	s.Authenticate = func(clientID, username string, password []byte) (any, error) {
+		if string(password) != "secret" {
+			return nil, errors.New("wrong password")
+		}
+		return username, nil
+	}
+	addr := serve(t, s)
+	if _, _, code := dial(t, addr, "dev", true, "dev", "wrong"); code != connRefusedBadAuth {
+		t.FailNow()
+	}
+	c, _, code := dial(t, addr, "dev", true, "dev", "secret")
+	if code != connAccepted || c.subscribe(t, "admin/#", 0) != subFailure {
+		t.FailNow()
+	}
+	c, _, _ = dial(t, addr, "admin", true, "admin", "secret")
+	if c.subscribe(t, "admin/#", 0) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestServer_Will(t *testing.T) {
+	lib := pubsub.New()
+	addr := serve(t, NewServer(lib))
+	lib.Subscribe("status/dev", "monitor")
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	b := appendString(nil, "MQTT")
+	b = append(b, 4, 0x06)
+	b = appendUint16(b, 60)
+	b = appendString(b, "dev")
+	b = appendString(appendString(b, "status/dev"), "offline")
+	c.send(t, typeConnect, 0, b)
+	c.expect(t, typeConnack)
+	c.Close()
+	time.Sleep(20 * time.Millisecond)
+	if msg, _ := lib.Poll("status/dev", "monitor"); string(msg) != "offline" {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,98 @@
+package pubsubtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// FakeClock is pubsub.ManualClock which time moves only by Advance, pass it to broker with pubsub.WithClock
+// Timers are fired by Advance in the calling goroutine, in order of their time
+type FakeClock struct {
+	mux    sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers []*fakeTimer
+}
+
+// Timer of FakeClock, fired at time (at), seq keeps order of timers with the same time
+type fakeTimer struct {
+	c   *FakeClock
+	at  time.Time
+	seq uint64
+	f   func()
+}
+
+// Constructor. Creates FakeClock showing time (t)
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// Scheduling call of (f) after duration (d), non-positive duration means the next Advance
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) pubsub.Timer {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.seq++
+	t := &fakeTimer{c: c, at: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mux.Lock()
+	defer t.c.mux.Unlock()
+	return t.c.remove(t)
+}
+
+// Removing timer (t), false if it isn't scheduled, c.mux must be held by caller
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for i, s := range c.timers {
+		if s == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Moving time forward by (d) and firing timers which time has come, timers scheduled by fired ones are fired too
+// if their time is within (d). Time of the clock is the time of the timer while it runs
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	end := c.now.Add(d)
+	for {
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if !t.at.After(end) && (next == nil || t.at.Before(next.at) || t.at.Equal(next.at) && t.seq < next.seq) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		c.remove(next)
+		if next.at.After(c.now) {
+			c.now = next.at
+		}
+		// timer callbacks use the clock, so it isn't locked while they run
+		c.mux.Unlock()
+		next.f()
+		c.mux.Lock()
+	}
+	c.now = end
+	c.mux.Unlock()
+}
+
+// Number of scheduled timers
+func (c *FakeClock) Timers() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.timers)
+}
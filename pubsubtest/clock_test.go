@@ -0,0 +1,101 @@
+package pubsubtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClock_Advance(t *testing.T) {
+	c := NewFakeClock(epoch)
+	var fired []string
+	c.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	c.AfterFunc(time.Second, func() {
+		fired = append(fired, "a")
+		if !c.Now().Equal(epoch.Add(time.Second)) {
+			t.Error(c.Now())
+		}
+		// scheduled by fired timer within the same Advance
+		c.AfterFunc(time.Second, func() { fired = append(fired, "c") })
+	})
+	stopped := c.AfterFunc(time.Second, func() { fired = append(fired, "x") })
+	if !stopped.Stop() || stopped.Stop() {
+		t.FailNow()
+	}
+	c.Advance(1500 * time.Millisecond)
+	if len(fired) != 1 || c.Timers() != 2 {
+		t.Fatal(fired)
+	}
+	c.Advance(time.Second)
+	if len(fired) != 3 || fired[1] != "b" || fired[2] != "c" || !c.Now().Equal(epoch.Add(2500*time.Millisecond)) {
+		t.Fatal(fired, c.Now())
+	}
+}
+
+func TestFakeClock_Broker(t *testing.T) {
+	c := NewFakeClock(epoch)
+	lib := pubsub.New(pubsub.WithClock(c))
+	lib.Subscribe("t", "s")
+	if err := lib.PublishWithTTL("t", []byte("ttl"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	lib.PublishAfter("t", []byte("delayed"), time.Hour)
+	c.Advance(2 * time.Minute)
+	// expired
+	if b, _ := lib.Poll("t", "s"); b != nil {
+		t.Fatal(string(b))
+	}
+	c.Advance(time.Hour)
+	msg, _ := lib.PollMsg("t", "s")
+	if msg == nil || string(msg.Body) != "delayed" || !msg.PublishedAt.Equal(epoch.Add(time.Hour)) {
+		t.Fatal(msg)
+	}
+	// visibility timeout
+	lib.Publish("t", []byte("ack"))
+	if b, _, _ := lib.PollAck("t", "s", time.Second); string(b) != "ack" {
+		t.Fatal(string(b))
+	}
+	if depth, _ := lib.Depth("t", "s"); depth != 0 {
+		t.Fatal(depth)
+	}
+	c.Advance(time.Second)
+	if depth, _ := lib.Depth("t", "s"); depth != 1 {
+		t.Fatal(depth)
+	}
+}
+
+func TestFakeClock_Advance_Broker(t *testing.T) {
+	c := NewFakeClock(epoch)
+	lib := pubsub.New(pubsub.WithClock(c))
+	idle := make(chan string, 1)
+	lib.SubscribeWithOptions("t", "idle", pubsub.Options{IdleTimeout: time.Minute, OnIdle: func(tn, sn string) { idle <- sn }})
+	lib.Subscribe("t", "s")
+	lib.PublishAfter("t", []byte("delayed"), time.Second)
+	if err := lib.Advance(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if b, _, _ := lib.PollAck("t", "s", time.Second); string(b) != "delayed" {
+		t.Fatal(string(b))
+	}
+	// redelivered and idle subscription removed in Advance
+	if err := lib.Advance(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := lib.Poll("t", "s"); string(b) != "delayed" {
+		t.Fatal(string(b))
+	}
+	select {
+	case sn := <-idle:
+		if sn != "idle" {
+			t.Fatal(sn)
+		}
+	default:
+		t.Fatal("idle subscription isn't removed")
+	}
+	if sns, _ := lib.Subscriptions("t"); len(sns) != 1 {
+		t.Fatal(sns)
+	}
+}
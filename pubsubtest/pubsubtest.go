@@ -0,0 +1,115 @@
+/*
+Test helpers for code using pubsub package: fake clock making time-dependent behavior (TTL, delays, visibility
+timeouts, rate limits) deterministic and broker recording published messages with assertions on them.
+Broker with FakeClock has no background work, it's done by Advance of the broker:
+
+	r := pubsubtest.NewRecorder(pubsub.WithClock(pubsubtest.NewFakeClock(time.Now())))
+	svc := NewService(r) // code under test publishes to r
+	...
+	pubsubtest.AssertPublished(t, r, "orders", pubsubtest.Header("type", "created"))
+	_ = r.Advance(time.Minute) // publishes delayed messages, expires old ones
+*/
+package pubsubtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Recorder is a broker remembering published messages, checked by assertions of this package
+type Recorder = pubsub.Recording
+
+// Constructor. Creates broker with options (opts) recording published messages
+func NewRecorder(opts ...pubsub.Option) *Recorder {
+	return pubsub.Recorder(opts...)
+}
+
+// Matcher checks recorded message, nil matcher matches any message
+type Matcher func(msg *pubsub.Message) bool
+
+// Matches messages with body (b)
+func Body(b string) Matcher {
+	return func(msg *pubsub.Message) bool {
+		return string(msg.Body) == b
+	}
+}
+
+// Matches messages with header (k) equal to (v)
+func Header(k, v string) Matcher {
+	return func(msg *pubsub.Message) bool {
+		hv, ok := msg.Headers[k]
+		return ok && hv == v
+	}
+}
+
+// Matches messages with ordering key (key)
+func Key(key string) Matcher {
+	return func(msg *pubsub.Message) bool {
+		return msg.Key == key
+	}
+}
+
+// Matches messages matched by all matchers (ms)
+func All(ms ...Matcher) Matcher {
+	return func(msg *pubsub.Message) bool {
+		for _, m := range ms {
+			if m != nil && !m(msg) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Returns the first message published to topic name (tn) and matched by (m), nil if there is no such message
+func find(r *Recorder, tn string, m Matcher) *pubsub.Message {
+	msgs := r.Published(tn)
+	for i := range msgs {
+		if m == nil || m(&msgs[i]) {
+			return &msgs[i]
+		}
+	}
+	return nil
+}
+
+// Bodies of messages published to topic name (tn) for failure messages
+func describe(r *Recorder, tn string) string {
+	msgs := r.Published(tn)
+	if len(msgs) == 0 {
+		return "nothing was published"
+	}
+	bodies := make([]string, len(msgs))
+	for i, msg := range msgs {
+		bodies[i] = "[" + msg.Topic + "] " + string(msg.Body)
+	}
+	return "published: " + strings.Join(bodies, ", ")
+}
+
+// Failing the test (t) if no message matched by (m) was published to topic name (tn) of recorder (r)
+// Returns the first matched message
+func AssertPublished(t testing.TB, r *Recorder, tn string, m Matcher) *pubsub.Message {
+	t.Helper()
+	msg := find(r, tn, m)
+	if msg == nil {
+		t.Errorf("no matching message was published to %q, %s", tn, describe(r, tn))
+	}
+	return msg
+}
+
+// Failing the test (t) if some message matched by (m) was published to topic name (tn) of recorder (r)
+func AssertNotPublished(t testing.TB, r *Recorder, tn string, m Matcher) {
+	t.Helper()
+	if msg := find(r, tn, m); msg != nil {
+		t.Errorf("unexpected message %q was published to %q", msg.Body, msg.Topic)
+	}
+}
+
+// Failing the test (t) if number of messages published to topic name (tn) of recorder (r) isn't (n)
+func AssertPublishedCount(t testing.TB, r *Recorder, tn string, n int) {
+	t.Helper()
+	if got := len(r.Published(tn)); got != n {
+		t.Errorf("%d messages were published to %q instead of %d, %s", got, tn, n, describe(r, tn))
+	}
+}
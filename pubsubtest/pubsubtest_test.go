@@ -0,0 +1,66 @@
+package pubsubtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// testing.TB remembering failures instead of failing the test
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	r.Subscribe("orders", "billing")
+	r.Publish("orders", []byte("1"))
+	if _, err := r.PublishMsg("orders", pubsub.Message{Headers: map[string]string{"type": "created"}, Body: []byte("2")}); err != nil {
+		t.Fatal(err)
+	}
+	r.Publish("users", []byte("3"))
+	if msg := AssertPublished(t, r, "orders", All(Body("2"), Header("type", "created"))); msg == nil || msg.Topic != "orders" {
+		t.Fatal(msg)
+	}
+	AssertNotPublished(t, r, "orders", Body("3"))
+	AssertPublishedCount(t, r, "orders", 2)
+	AssertPublishedCount(t, r, "", 3)
+	// recorder is a usual broker
+	if b, _ := r.Poll("orders", "billing"); string(b) != "1" {
+		t.Fatal(string(b))
+	}
+	r.Reset()
+	AssertPublishedCount(t, r, "", 0)
+}
+
+func TestRecorder_Failures(t *testing.T) {
+	r := NewRecorder()
+	r.Publish("orders", []byte("1"))
+	rt := &recordingT{TB: t}
+	if AssertPublished(rt, r, "orders", Body("2")) != nil {
+		t.FailNow()
+	}
+	AssertNotPublished(rt, r, "orders", nil)
+	AssertPublishedCount(rt, r, "orders", 2)
+	if len(rt.errors) != 3 || rt.errors[0] != `no matching message was published to "orders", published: [orders] 1` {
+		t.Fatal(rt.errors)
+	}
+}
+
+func TestRecorder_Rejected(t *testing.T) {
+	r := NewRecorder()
+	r.SubscribeWithOptions("orders", "billing", pubsub.Options{MaxMessages: 1, Overflow: pubsub.RejectPublish})
+	r.Publish("orders", []byte("1"))
+	if err := r.TryPublish("orders", []byte("2")); err != pubsub.ErrQueueFull {
+		t.Fatal(err)
+	}
+	AssertPublishedCount(t, r, "orders", 1)
+}
@@ -0,0 +1,106 @@
+package pubsubws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// WebSocket opcodes (RFC 6455, section 5.2)
+const (
+	opText   = 0x1
+	opBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xA
+)
+
+// GUID appended to Sec-WebSocket-Key during handshake (RFC 6455, section 1.3)
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Control frames payload can't be longer (RFC 6455, section 5.5)
+const maxControlPayload = 125
+
+// Error happens if client sends frame violating the protocol
+var errProtocol = errors.New("websocket protocol error")
+
+// Value of Sec-WebSocket-Accept header for client key
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Writing single unmasked (server side) frame with opcode (op) and payload (b)
+func writeFrame(w *bufio.Writer, op byte, b []byte) error {
+	header := []byte{0x80 | op}
+	switch n := len(b); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Reading single frame sent by client, payload of data frames is discarded because gateway only pushes messages
+// returns opcode and payload of control frames
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	op := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	n := uint64(header[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	// clients must mask frames (RFC 6455, section 5.1)
+	if !masked {
+		return 0, nil, errProtocol
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	if op < opClose {
+		_, err := io.CopyN(io.Discard, r, int64(n))
+		return op, nil, err
+	}
+	if n > maxControlPayload {
+		return 0, nil, errProtocol
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, nil, err
+	}
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+	return op, b, nil
+}
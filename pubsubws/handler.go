@@ -0,0 +1,295 @@
+/*
+	WebSocket push gateway for pubsub package. Handler upgrades connection to WebSocket (RFC 6455), subscribes
+	connection to topic and subscription from query string (?topic=tn&sub=sn) and pushes every new message
+	as a separate frame immediately, so browsers don't need to poll.
+
+	Messages are taken from the subscription before they are written, so a message may be lost if connection breaks
+	during writing. Frames sent by the client are ignored except control ones (close, ping).
+
+	With session parameter (?topic=tn&sub=sn&session=id) messages are delivered by pubsub.Session with ID chosen by
+	client and stay in flight until client acknowledges them: every data frame sent by the client acknowledges the
+	oldest message not acknowledged yet. Client reconnecting with the same session ID gets not acknowledged messages
+	again before new ones.
+
+	Server pings client every Handler.PingInterval and closes connection if nothing (pong or other frame) is received
+	during two intervals, so subscriptions of dead clients are removed (see UnsubscribeOnClose) or their sessions
+	expire (see pubsub.Sessions.SetExpiry) instead of leaking.
+*/
+package pubsubws
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubhttp"
+)
+
+// Defaults of Handler settings
+const (
+	DefaultMaxInflight  = 64
+	DefaultPingInterval = 30 * time.Second
+)
+
+// Handler is an http.Handler upgrading requests to WebSocket connections fed by a subscription
+// TextFrames - send messages as text frames instead of binary ones (messages must be valid UTF-8 then)
+// UnsubscribeOnClose - remove subscription when connection without session is closed, otherwise messages are kept
+// until reconnect
+// Principal - optional function extracting principal of request passed to Authorizer of broker
+// (see pubsub.WithAuthorizer), the request is rejected with 403 before upgrade if subscribing or polling is denied
+// MaxInflight - how many messages of session may wait for acknowledgement, DefaultMaxInflight is used if zero
+// Sessions - sessions of connections, may be shared with other handlers (e.g. pubsubhttp) of the broker
+// PingInterval - interval of pings checking that client is alive, pings are disabled if zero
+// Authenticate - optional authenticator of requests (see pubsubhttp.BearerToken), requests without valid credentials
+// are rejected with 401 before upgrade, replaces Principal if it's set
+// CheckOrigin - optional function allowing request by its Origin header, the request is rejected with 403 before
+// upgrade if it returns false, by default only same-origin requests and requests without Origin are allowed
+type Handler struct {
+	ps                 pubsub.PubSuber
+	TextFrames         bool
+	UnsubscribeOnClose bool
+	Principal          func(r *http.Request) any
+	MaxInflight        int
+	Sessions           *pubsub.Sessions
+	PingInterval       time.Duration
+	Authenticate       pubsubhttp.Authenticator
+	CheckOrigin        func(r *http.Request) bool
+}
+
+// Constructor. Creates a Handler serving broker (ps)
+func NewHandler(ps pubsub.PubSuber) *Handler {
+	return &Handler{ps: ps, Sessions: pubsub.NewSessions(pubsub.DefaultSessionTimeout), PingInterval: DefaultPingInterval}
+}
+
+// Connection with a mutex for writing, because pongs are written by reading goroutine
+type conn struct {
+	mux sync.Mutex
+	net.Conn
+	w *bufio.Writer
+}
+
+// Writing frame with opcode (op) and payload (b)
+func (c *conn) write(op byte, b []byte) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return writeFrame(c.w, op, b)
+}
+
+// Upgrading connection and pushing messages until either side closes it
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tn, sn := q.Get("topic"), q.Get("sub")
+	if tn == "" || sn == "" {
+		http.Error(w, "topic and sub are required", http.StatusBadRequest)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+	checkOrigin := h.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	ps := h.ps
+	if h.Authenticate != nil {
+		var ok bool
+		if r, ok = pubsubhttp.Authenticate(w, r, h.Authenticate); !ok {
+			return
+		}
+		ps = ps.As(pubsubhttp.PrincipalOf(r.Context()))
+	} else if h.Principal != nil {
+		ps = ps.As(h.Principal(r))
+	}
+	for _, op := range []pubsub.Operation{pubsub.OpSubscribe, pubsub.OpPoll} {
+		if err := pubsub.Authorize(ps, op, tn, sn); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket is not supported by server", http.StatusInternalServerError)
+		return
+	}
+	nc, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	c := &conn{Conn: nc, w: rw.Writer}
+	defer c.Close()
+	ps.Subscribe(tn, sn)
+	id := q.Get("session")
+	if h.UnsubscribeOnClose && id == "" {
+		defer ps.Unsubscribe(tn, sn)
+	}
+	_, err = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err != nil || rw.Flush() != nil {
+		return
+	}
+
+	op := byte(opBinary)
+	if h.TextFrames {
+		op = opText
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if h.PingInterval > 0 {
+		go h.ping(ctx, c)
+	}
+	if id != "" {
+		h.pushSession(ctx, cancel, c, rw.Reader, ps, h.Sessions.Resume(tn, sn, id), op)
+		return
+	}
+	go func() {
+		defer cancel()
+		h.read(c, rw.Reader, nil)
+	}()
+
+	for {
+		msg, err := ps.PollWait(ctx, tn, sn)
+		if err != nil {
+			_ = c.write(opClose, closePayload(err))
+			return
+		}
+		if err := c.write(op, msg); err != nil {
+			return
+		}
+	}
+}
+
+// Pushing messages of session (sess) with opcode (op) until ctx is done, reading goroutine acknowledges them
+// Slot is taken before the next message, so at most MaxInflight messages aren't acknowledged
+func (h *Handler) pushSession(ctx context.Context, cancel context.CancelFunc, c *conn, r *bufio.Reader, ps pubsub.PubSuber, sess *pubsub.Session, op byte) {
+	max := h.MaxInflight
+	if max <= 0 {
+		max = DefaultMaxInflight
+	}
+	slots, sent := make(chan struct{}, max), make(chan uint64, max)
+	go func() {
+		defer cancel()
+		h.read(c, r, func() {
+			select {
+			case seq := <-sent:
+				_ = sess.Ack(ps, seq)
+				<-slots
+			default:
+			}
+		})
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case slots <- struct{}{}:
+		}
+		msg, seq, err := sess.NextWait(ctx, ps)
+		if err != nil {
+			_ = c.write(opClose, closePayload(err))
+			return
+		}
+		sent <- seq
+		if err := c.write(op, msg.Body); err != nil {
+			return
+		}
+	}
+}
+
+// Pinging client every PingInterval until ctx is done
+func (h *Handler) ping(ctx context.Context, c *conn) {
+	t := time.NewTicker(h.PingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if c.write(opPing, nil) != nil {
+				return
+			}
+		}
+	}
+}
+
+// Reading client frames until close frame or error, answering pings and calling (data) for data frames if it's set
+// Reading fails if nothing is received during two ping intervals
+func (h *Handler) read(c *conn, r *bufio.Reader, data func()) {
+	for {
+		if h.PingInterval > 0 {
+			_ = c.SetReadDeadline(time.Now().Add(2 * h.PingInterval))
+		}
+		op, b, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		switch op {
+		case opClose:
+			return
+		case opPing:
+			if c.write(opPong, b) != nil {
+				return
+			}
+		case opText, opBinary:
+			if data != nil {
+				data()
+			}
+		}
+	}
+}
+
+// Payload of close frame: 1000 (normal closure) if connection is closed by client,
+// 1011 (internal error) with reason if subscription is removed, 1001 (going away) if broker is closed,
+// 1013 (try again later) if poll rate of subscription is exceeded
+func closePayload(err error) []byte {
+	if err == pubsub.ErrClosed {
+		return append([]byte{0x03, 0xE9}, err.Error()...)
+	}
+	if err == pubsub.ErrRateLimited {
+		return append([]byte{0x03, 0xF5}, err.Error()...)
+	}
+	if errors.Is(err, pubsub.ErrNoSubscriptions) {
+		return append([]byte{0x03, 0xF3}, err.Error()...)
+	}
+	return []byte{0x03, 0xE8}
+}
+
+// Checks if comma separated header (name) contains token (case insensitive)
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Checks that host of Origin header equals Host of request (r), requests without Origin aren't made by browsers
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,273 @@
+package pubsubws
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+	"github.com/cejixo3/pubsub.git/pubsubhttp"
+)
+
+// Minimal client side: handshake, reading unmasked frames and writing masked ones
+func dial(t *testing.T, srv *httptest.Server, query string) (net.Conn, *bufio.Reader) {
+	c, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.WriteString(c, "GET /?"+query+" HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	r := bufio.NewReader(c)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatal(resp.Status)
+	}
+	return c, r
+}
+
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(header[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(r, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, b
+}
+
+func writeClientFrame(c net.Conn, op byte, b []byte) {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | op, 0x80 | byte(len(b))}, mask...)
+	for i := range b {
+		frame = append(frame, b[i]^mask[i%4])
+	}
+	_, _ = c.Write(frame)
+}
+
+func TestHandler_Push(t *testing.T) {
+	ps := pubsub.New()
+	srv := httptest.NewServer(NewHandler(ps))
+	defer srv.Close()
+	c, r := dial(t, srv, "topic=topic&sub=sub")
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// subscription is created during handshake
+	ps.Publish("topic", []byte("first"))
+	ps.Publish("topic", []byte(strings.Repeat("x", 200)))
+	if op, b := readServerFrame(t, r); op != opBinary || string(b) != "first" {
+		t.Fatal(op, string(b))
+	}
+	if _, b := readServerFrame(t, r); len(b) != 200 {
+		t.Fatal(len(b))
+	}
+	writeClientFrame(c, opPing, []byte("ping"))
+	if op, b := readServerFrame(t, r); op != opPong || string(b) != "ping" {
+		t.Fatal(op, string(b))
+	}
+	writeClientFrame(c, opClose, nil)
+	if op, _ := readServerFrame(t, r); op != opClose {
+		t.Fatal(op)
+	}
+}
+
+func TestHandler_UnsubscribeOnClose(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	h.UnsubscribeOnClose = true
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c, r := dial(t, srv, "topic=topic&sub=sub")
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	writeClientFrame(c, opClose, nil)
+	readServerFrame(t, r)
+	c.Close()
+	for i := 0; i < 100; i++ {
+		if _, err := ps.Poll("topic", "sub"); errors.Is(err, pubsub.ErrNoSubscriptions) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.FailNow()
+}
+
+func TestHandler_BadRequest(t *testing.T) {
+	h := NewHandler(pubsub.New())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?topic=topic&sub=sub", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatal(rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatal(rec.Code)
+	}
+}
+
+func TestHandler_Principal(t *testing.T) {
+	ps := pubsub.New(pubsub.WithAuthorizer(pubsub.AuthorizerFunc(func(op pubsub.Operation, tn, sn string, principal any) error {
+		if principal != "admin" {
+			return pubsub.ErrForbidden
+		}
+		return nil
+	})))
+	h := NewHandler(ps)
+	h.Principal = func(r *http.Request) any { return r.URL.Query().Get("user") }
+	req := httptest.NewRequest(http.MethodGet, "/?topic=topic&sub=sub&user=guest", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatal(rec.Code)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c, _ := dial(t, srv, "topic=topic&sub=sub&user=admin")
+	defer c.Close()
+}
+
+func TestHandler_Session(t *testing.T) {
+	ps := pubsub.New()
+	srv := httptest.NewServer(NewHandler(ps))
+	defer srv.Close()
+	ps.Subscribe("topic", "sub")
+	ps.Publish("topic", []byte("1"))
+	ps.Publish("topic", []byte("2"))
+	c, r := dial(t, srv, "topic=topic&sub=sub&session=s1")
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []string{"1", "2"} {
+		if _, b := readServerFrame(t, r); string(b) != want {
+			t.Fatal(string(b))
+		}
+	}
+	// acknowledging the first message only
+	writeClientFrame(c, opText, []byte("ack"))
+	writeClientFrame(c, opPing, nil)
+	readServerFrame(t, r)
+	c.Close()
+
+	c, r = dial(t, srv, "topic=topic&sub=sub&session=s1")
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, b := readServerFrame(t, r); string(b) != "2" {
+		t.Fatal(string(b))
+	}
+	ps.Publish("topic", []byte("3"))
+	if _, b := readServerFrame(t, r); string(b) != "3" {
+		t.Fatal(string(b))
+	}
+}
+
+func TestHandler_Ping(t *testing.T) {
+	ps := pubsub.New()
+	h := NewHandler(ps)
+	h.UnsubscribeOnClose = true
+	h.PingInterval = 20 * time.Millisecond
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c, r := dial(t, srv, "topic=topic&sub=sub")
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if op, _ := readServerFrame(t, r); op != opPing {
+		t.Fatal(op)
+	}
+	writeClientFrame(c, opPong, nil)
+	if subs, _ := ps.Subscriptions("topic"); len(subs) != 1 {
+		t.Fatal(subs)
+	}
+	// the client stops answering
+	for i := 0; i < 100; i++ {
+		if _, err := ps.Poll("topic", "sub"); errors.Is(err, pubsub.ErrNoSubscriptions) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.FailNow()
+}
+
+func TestHandler_Authenticate(t *testing.T) {
+	ps := pubsub.New(pubsub.WithAuthorizer(pubsub.AuthorizerFunc(func(op pubsub.Operation, tn, sn string, principal any) error {
+		if principal != "admin" {
+			return pubsub.ErrForbidden
+		}
+		return nil
+	})))
+	h := NewHandler(ps)
+	h.Authenticate = pubsubhttp.BearerToken(pubsub.StaticTokens(map[string]any{"secret": "admin", "guest": "guest"}))
+	for _, c := range []struct {
+		query string
+		code  int
+	}{{"", http.StatusUnauthorized}, {"&access_token=guest", http.StatusForbidden}} {
+		req := httptest.NewRequest(http.MethodGet, "/?topic=topic&sub=sub"+c.query, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Fatal(c, rec.Code)
+		}
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c, _ := dial(t, srv, "topic=topic&sub=sub&access_token=secret")
+	defer c.Close()
+}
+
+func TestHandler_CheckOrigin(t *testing.T) {
+	h := NewHandler(pubsub.New())
+	upgrade := func(origin string) int {
+		req := httptest.NewRequest(http.MethodGet, "/?topic=topic&sub=sub", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// recorder can't be hijacked, so allowed requests fail with 500 after the check
+	for origin, code := range map[string]int{
+		"":                      http.StatusInternalServerError,
+		"http://example.com":    http.StatusInternalServerError,
+		"https://EXAMPLE.com":   http.StatusInternalServerError,
+		"http://evil.com":       http.StatusForbidden,
+		"http://example.com:81": http.StatusForbidden,
+		"null":                  http.StatusForbidden,
+	} {
+		if c := upgrade(origin); c != code {
+			t.Fatal(origin, c)
+		}
+	}
+	h.CheckOrigin = func(r *http.Request) bool { return r.Header.Get("Origin") == "http://evil.com" }
+	if c := upgrade("http://evil.com"); c != http.StatusInternalServerError {
+		t.Fatal(c)
+	}
+	if c := upgrade("http://example.com"); c != http.StatusForbidden {
+		t.Fatal(c)
+	}
+}
@@ -0,0 +1,45 @@
+package pubsub
+
+// Dropping all pending messages of topic name (tn) and subscriber name (sn), returns number of dropped messages
+// Messages in flight (see PollAck) are not dropped
+// error raises if no subscriptions
+func (p *pubSub) PurgeSubscription(tn, sn string) (int, error) {
+	subs, sub, err := p.acquire(tn, sn)
+	if err != nil {
+		return 0, err
+	}
+	defer subs.mux.Unlock()
+	return sub.purge(), nil
+}
+
+// Dropping all pending messages of all subscriptions of topic name (tn), returns number of dropped messages
+// Messages in flight (see PollAck) and topic history are not dropped
+// error raises if topic doesn't exist
+func (p *pubSub) PurgeTopic(tn string) (int, error) {
+	if p.closed.Load() {
+		return 0, ErrClosed
+	}
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		return 0, ErrTopicNotFound
+	}
+	subs.mux.Lock()
+	defer subs.mux.Unlock()
+	n := 0
+	for _, sub := range subs.hm {
+		n += sub.purge()
+	}
+	return n, nil
+}
+
+// Replacing storage of subscription with an empty one, returns number of dropped messages
+// Lock of subscription must be held by caller
+func (s *subscription) purge() int {
+	n := s.len()
+	if n > 0 {
+		s.replace(s.emptyStorage())
+		s.slow = false
+		s.log.Info("subscription purged", "count", n)
+	}
+	return n
+}
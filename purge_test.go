@@ -0,0 +1,60 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPubSub_PurgeSubscription(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if _, err := lib.PurgeSubscription(tn, sn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.SubscribeWithOptions(tn, sn, Options{Priority: true})
+	lib.Subscribe(tn, "other")
+	for _, m := range []string{"a", "b", "c"} {
+		lib.Publish(tn, []byte(m))
+	}
+	_, token, _ := lib.PollAck(tn, sn, time.Minute)
+	if n, err := lib.PurgeSubscription(tn, sn); err != nil || n != 2 {
+		t.FailNow()
+	}
+	if n, _ := lib.PurgeSubscription(tn, sn); n != 0 {
+		t.FailNow()
+	}
+	// message in flight is kept
+	if lib.Nack(tn, sn, token, true) != nil {
+		t.FailNow()
+	}
+	if b, _ := lib.Poll(tn, sn); string(b) != "a" {
+		t.FailNow()
+	}
+	_ = lib.PublishWithPriority(tn, []byte("d"), 1)
+	if b, _ := lib.Poll(tn, sn); string(b) != "d" {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth(tn, "other"); depth != 4 {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_PurgeTopic(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	if _, err := lib.PurgeTopic(tn); !errors.Is(err, ErrNoSubscriptions) {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, "a")
+	lib.Subscribe(tn, "b")
+	lib.Publish(tn, []byte("a"))
+	lib.Publish(tn, []byte("b"))
+	if n, err := lib.PurgeTopic(tn); err != nil || n != 4 {
+		t.FailNow()
+	}
+	if b, err := lib.Poll(tn, "a"); err != nil || b != nil {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+)
+
+// Error happens if publish rate of topic (TopicConfig.PublishRate) or poll rate of subscription (Options.PollRate)
+// is exceeded
+var ErrRateLimited = errors.New("rate limit is exceeded")
+
+// Token bucket: rate tokens per second are added up to burst, every operation takes one token
+// nil bucket means unlimited rate
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// Creates full bucket for (rate) operations per second with (burst), nil is returned if rate isn't positive
+// Burst less than 1 is replaced with 1
+func newBucket(rate float64, burst int) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// Taking a token at the moment now, false is returned if there are no tokens
+// Lock of the owner (topic or subscription) must be held by caller
+func (b *bucket) allow(now time.Time) bool {
+	if !b.has(now, 1) {
+		return false
+	}
+	b.take(1)
+	return true
+}
+
+// Checks if (n) tokens may be taken at the moment now without taking them
+// Lock of the owner (topic or subscription) must be held by caller
+func (b *bucket) has(now time.Time, n int) bool {
+	if b == nil {
+		return true
+	}
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+	return b.tokens >= float64(n)
+}
+
+// Taking (n) tokens checked by has
+// Lock of the owner (topic or subscription) must be held by caller
+func (b *bucket) take(n int) {
+	if b != nil {
+		b.tokens -= float64(n)
+	}
+}
+
+// Returns subscription locked for polling (see acquireShared), but ErrRateLimited raises if poll rate of
+// the subscription is exceeded. Every call takes a token, even if there are no messages
+func (p *pubSub) acquirePoll(tn, sn string) (*subscription, error) {
+	_, sub, err := p.acquireShared(tn, sn)
+	if err != nil {
+		return nil, err
+	}
+	if !sub.pollLimit.allow(p.now()) {
+		sub.Unlock()
+		sub.log.Debug("poll rejected", "reason", "rate limit")
+		return nil, ErrRateLimited
+	}
+	return sub, nil
+}
@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket_allow(t *testing.T) {
+	b := newBucket(10, 2)
+	now := time.Now()
+	if !b.allow(now) || !b.allow(now) || b.allow(now) {
+		t.FailNow()
+	}
+	// one token per 100ms
+	if b.allow(now.Add(50*time.Millisecond)) || !b.allow(now.Add(100*time.Millisecond)) {
+		t.FailNow()
+	}
+	// tokens don't exceed burst
+	later := now.Add(time.Hour)
+	if !b.allow(later) || !b.allow(later) || b.allow(later) {
+		t.FailNow()
+	}
+	if newBucket(0, 10) != nil || !(*bucket)(nil).allow(now) {
+		t.FailNow()
+	}
+}
+
+func TestTopicConfig_PublishRate(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	lib.Subscribe("some/#", sn)
+	if err := lib.CreateTopic(tn, TopicConfig{PublishRate: 0.001, PublishBurst: 2}); err != nil {
+		t.FailNow()
+	}
+	for _, m := range []string{"a", "b"} {
+		if err := lib.TryPublish(tn, []byte(m)); err != nil {
+			t.FailNow()
+		}
+	}
+	if err := lib.TryPublish(tn, []byte("c")); err != ErrRateLimited {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth(tn, sn); depth != 2 {
+		t.FailNow()
+	}
+	// other topics aren't limited
+	if err := lib.TryPublish("some/topic", []byte("d")); err != nil {
+		t.FailNow()
+	}
+}
+
+func TestOptions_PollRate(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions(tn, sn, Options{PollRate: 0.001})
+	lib.Subscribe(tn, "unlimited")
+	lib.Publish(tn, []byte("a"))
+	lib.Publish(tn, []byte("b"))
+	if msgs, err := lib.PollN(tn, sn, 10); err != nil || len(msgs) != 2 {
+		t.FailNow()
+	}
+	if _, err := lib.Poll(tn, sn); err != ErrRateLimited {
+		t.FailNow()
+	}
+	if _, _, err := lib.PollAck(tn, sn, time.Second); err != ErrRateLimited {
+		t.FailNow()
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := lib.Poll(tn, "unlimited"); err != nil {
+			t.FailNow()
+		}
+	}
+	// options without rate remove the limit
+	lib.SubscribeWithOptions(tn, sn, Options{})
+	if _, err := lib.Poll(tn, sn); err != nil {
+		t.FailNow()
+	}
+}
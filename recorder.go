@@ -0,0 +1,61 @@
+package pubsub
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Recording is a broker remembering messages published successfully, it's a usual broker otherwise
+// Messages are recorded after publish middlewares added before the recorder, messages of PublishTx when they're
+// added to transaction, delayed messages when they're published
+type Recording struct {
+	PubSuber
+	mux  sync.Mutex
+	msgs []Message
+}
+
+// Constructor. Creates broker with options (opts) recording published messages
+// Useful for dependency injection in tests, see also pubsubtest package
+func Recorder(opts ...Option) *Recording {
+	r := &Recording{PubSuber: New(opts...)}
+	r.Use(Middleware{Publish: r.record})
+	return r
+}
+
+func (r *Recording) record(next PublishFunc) PublishFunc {
+	return func(ctx context.Context, tn string, msg *Message) error {
+		if err := next(ctx, tn, msg); err != nil {
+			return err
+		}
+		m := *msg
+		m.Topic = tn
+		m.Body = slices.Clone(msg.Body)
+		m.Headers = maps.Clone(msg.Headers)
+		r.mux.Lock()
+		r.msgs = append(r.msgs, m)
+		r.mux.Unlock()
+		return nil
+	}
+}
+
+// Messages published to topic name (tn) in order of publishing, all messages if tn is empty
+func (r *Recording) Published(tn string) []Message {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	var msgs []Message
+	for _, m := range r.msgs {
+		if tn == "" || m.Topic == tn {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// Forgetting recorded messages
+func (r *Recording) Reset() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.msgs = nil
+}
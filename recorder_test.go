@@ -0,0 +1,27 @@
+package pubsub
+
+import "testing"
+
+func TestRecorder(t *testing.T) {
+	lib := Recorder()
+	lib.Subscribe("orders", "billing")
+	body := []byte("1")
+	lib.Publish("orders", body)
+	body[0] = '2'
+	lib.Publish("users", []byte("3"))
+	if msgs := lib.Published("orders"); len(msgs) != 1 || string(msgs[0].Body) != "1" || msgs[0].Topic != "orders" {
+		t.Fatal(msgs)
+	}
+	if msgs := lib.Published(""); len(msgs) != 2 {
+		t.Fatal(msgs)
+	}
+	// rejected messages aren't recorded
+	lib.SubscribeWithOptions("orders", "billing", Options{MaxMessages: 1, Overflow: RejectPublish})
+	if err := lib.TryPublish("orders", []byte("4")); err != ErrQueueFull {
+		t.Fatal(err)
+	}
+	lib.Reset()
+	if msgs := lib.Published(""); len(msgs) != 0 {
+		t.Fatal(msgs)
+	}
+}
@@ -0,0 +1,144 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Headers of request-reply messages
+// HeaderReplyTo - topic the response is published to, HeaderCorrelationID - matches response to request,
+// HeaderError - text of responder error, set in responses only
+const (
+	HeaderReplyTo       = "reply-to"
+	HeaderCorrelationID = "correlation-id"
+	HeaderError         = "error"
+)
+
+// Prefix of temporary reply topics, topics starting with "$" aren't matched by "#" and "+" wildcards
+const replyTopicPrefix = "$reply/"
+
+// Subscription name of temporary reply topics
+const replySubscription = "requester"
+
+// Error returned by Request if responder failed, Msg is text of responder error
+type ResponderError struct {
+	Msg string
+}
+
+func (e *ResponderError) Error() string {
+	return "responder failed: " + e.Msg
+}
+
+// Publishing request (b) to topic name (tn) and waiting for response until ctx is done
+// Response is published by Responder to temporary topic from HeaderReplyTo header, which is removed after that
+func (p *pubSub) Request(ctx context.Context, tn string, b []byte) ([]byte, error) {
+	return request(ctx, p, tn, b)
+}
+
+// Publishing request (b) to topic name (tn) of the namespace and waiting for response until ctx is done
+func (n *namespace) Request(ctx context.Context, tn string, b []byte) ([]byte, error) {
+	return request(ctx, n, tn, b)
+}
+
+// Publishing request (b) to topic name (tn) if principal may publish to it and waiting for response
+// Temporary reply topic isn't checked by Authorizer
+func (a *authorized) Request(ctx context.Context, tn string, b []byte) ([]byte, error) {
+	if err := a.allow(OpPublish, tn, ""); err != nil {
+		return nil, err
+	}
+	return a.next.Request(ctx, tn, b)
+}
+
+// Request-reply round trip over broker (ps)
+// Reply topic is created explicitly, so requests work in strict mode (see WithStrictTopics) too
+func request(ctx context.Context, ps PubSuber, tn string, b []byte) ([]byte, error) {
+	id := newIDPrefix()
+	reply := replyTopicPrefix + id
+	if err := ps.CreateTopic(reply, TopicConfig{}); err != nil {
+		return nil, err
+	}
+	defer ps.DeleteTopic(reply)
+	ps.Subscribe(reply, replySubscription)
+	_, err := ps.PublishMsg(tn, Message{
+		Headers: map[string]string{HeaderReplyTo: reply, HeaderCorrelationID: id},
+		Body:    b,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := ps.PollMsgWait(ctx, reply, replySubscription)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Headers[HeaderCorrelationID] != id {
+			continue
+		}
+		if text, ok := msg.Headers[HeaderError]; ok {
+			return nil, &ResponderError{Msg: text}
+		}
+		return msg.Body, nil
+	}
+}
+
+// Handler of requests, returned bytes are published as response, error is returned to requester as ResponderError
+type ResponderFunc func(ctx context.Context, req *Message) ([]byte, error)
+
+// Responder answers requests published with Request to a topic
+// Responders sharing the subscription name share requests, so the load is balanced between them
+// Workers - number of requests handled concurrently, 1 if zero
+type Responder struct {
+	ps      PubSuber
+	tn, sn  string
+	fn      ResponderFunc
+	Workers int
+}
+
+// Constructor. Creates Responder handling requests of topic name (tn) and subscription name (sn) of broker (ps)
+// with (fn)
+func NewResponder(ps PubSuber, tn, sn string, fn ResponderFunc) *Responder {
+	return &Responder{ps: ps, tn: tn, sn: sn, fn: fn}
+}
+
+// Subscribing and answering requests until ctx is done or the subscription is removed
+// ctx.Err() is returned in the first case, Run returns after running handlers returned
+// Messages without HeaderReplyTo header are dropped
+func (r *Responder) Run(ctx context.Context) error {
+	r.ps.Subscribe(r.tn, r.sn)
+	workers := r.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- r.serve(ctx)
+		}()
+	}
+	wg.Wait()
+	return <-errs
+}
+
+// Polling and answering requests one by one
+func (r *Responder) serve(ctx context.Context) error {
+	for {
+		req, err := r.ps.PollMsgWait(ctx, r.tn, r.sn)
+		if err != nil {
+			return err
+		}
+		reply := req.Headers[HeaderReplyTo]
+		if reply == "" {
+			continue
+		}
+		headers := map[string]string{HeaderCorrelationID: req.Headers[HeaderCorrelationID]}
+		b, err := r.fn(ctx, req)
+		if err != nil {
+			headers[HeaderError], b = err.Error(), nil
+		}
+		// requester may be gone already, its reply topic doesn't exist then
+		_, _ = r.ps.PublishMsg(reply, Message{Headers: headers, Body: b})
+	}
+}
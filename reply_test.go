@@ -0,0 +1,96 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPubSub_Request(t *testing.T) {
+	lib := New()
+	r := NewResponder(lib, "users/get", "users", func(ctx context.Context, req *Message) ([]byte, error) {
+		if string(req.Body) == "unknown" {
+			return nil, errors.New("user not found")
+		}
+		return append([]byte("user "), req.Body...), nil
+	})
+	r.Workers = 2
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+	for {
+		if sns, _ := lib.Subscriptions("users/get"); len(sns) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	rctx, rcancel := context.WithTimeout(context.Background(), time.Second)
+	defer rcancel()
+	if b, err := lib.Request(rctx, "users/get", []byte("1")); err != nil || string(b) != "user 1" {
+		t.Fatal(string(b), err)
+	}
+	var rerr *ResponderError
+	if _, err := lib.Request(rctx, "users/get", []byte("unknown")); !errors.As(err, &rerr) || rerr.Msg != "user not found" {
+		t.Fatal(err)
+	}
+	// reply topics are removed
+	for _, tn := range lib.Topics() {
+		if tn != "users/get" {
+			t.Fatal(tn)
+		}
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal(err)
+	}
+	// nobody answers
+	rctx, rcancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer rcancel()
+	if _, err := lib.Request(rctx, "users/get", []byte("1")); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+}
+
+func TestNamespace_Request(t *testing.T) {
+	lib := New()
+	ns := lib.Namespace("tenant")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go NewResponder(ns, "echo", "echo", func(ctx context.Context, req *Message) ([]byte, error) {
+		return req.Body, nil
+	}).Run(ctx)
+	for {
+		if sns, _ := ns.Subscriptions("echo"); len(sns) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if b, err := ns.Request(ctx, "echo", []byte("hello")); err != nil || string(b) != "hello" {
+		t.Fatal(string(b), err)
+	}
+}
+
+func TestPubSub_Request_Strict(t *testing.T) {
+	lib := New(WithStrictTopics(true))
+	if err := lib.CreateTopic("echo", TopicConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go NewResponder(lib, "echo", "echo", func(ctx context.Context, req *Message) ([]byte, error) {
+		return req.Body, nil
+	}).Run(ctx)
+	for {
+		if sns, _ := lib.Subscriptions("echo"); len(sns) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if b, err := lib.Request(ctx, "echo", []byte("hello")); err != nil || string(b) != "hello" {
+		t.Fatal(string(b), err)
+	}
+	if topics := lib.Topics(); len(topics) != 1 {
+		t.Fatal(topics)
+	}
+}
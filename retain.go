@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"sort"
+	"strings"
+)
+
+// Publish message (b) by topic name (tn) and keep it as retained message of the topic (like in MQTT)
+// Retained message is delivered immediately to every new subscription of the topic (and of wildcard topics
+// matching it), the next PublishRetained replaces it. Empty message removes retained message of the topic
+// Overflow policies are applied like in TryPublish, retained message is kept even if publishing is rejected
+func (p *pubSub) PublishRetained(tn string, b []byte) error {
+	m := p.newMessage(tn, Message{Body: b})
+	m.formatID()
+	m.pin()
+	// retained message becomes visible before publishing
+	if err := p.seal(tn, m); err != nil {
+		return err
+	}
+	p.retainMux.Lock()
+	if len(b) == 0 {
+		delete(p.retained, tn)
+	} else {
+		if p.retained == nil {
+			p.retained = map[string]*message{}
+		}
+		p.retained[tn] = m
+	}
+	p.retainMux.Unlock()
+	// retained message waits for future subscriptions, so it isn't reported as not received
+	if _, err := p.publish(tn, m); err != ErrNoSubscriptions {
+		return err
+	}
+	return nil
+}
+
+// Adding retained messages of topic name (tn) to just created subscription (sub)
+// For wildcard topics retained messages of all matching topics are added in order of topic names
+// Lock of subscriptions list of the subscription must be held by caller
+func (p *pubSub) deliverRetained(tn string, sub *subscription) {
+	p.retainMux.Lock()
+	defer p.retainMux.Unlock()
+	if len(p.retained) == 0 {
+		return
+	}
+	var msgs []*message
+	if isPattern(tn) {
+		for rtn, m := range p.retained {
+			if matchPattern(tn, rtn) {
+				msgs = append(msgs, m)
+			}
+		}
+		sort.Slice(msgs, func(i, j int) bool {
+			return msgs[i].Topic < msgs[j].Topic
+		})
+	} else if m, ok := p.retained[tn]; ok {
+		msgs = append(msgs, m)
+	}
+	for _, m := range msgs {
+		sub.push(m)
+	}
+	if len(msgs) > 0 {
+		sub.cond.Broadcast()
+	}
+}
+
+// Checks if topic name (tn) matches wildcard pattern
+// Topics starting with "$" are matched only by patterns with the same first level (like topicTree.match)
+func matchPattern(pattern, tn string) bool {
+	levels := strings.Split(tn, levelSeparator)
+	patternLevels := strings.Split(pattern, levelSeparator)
+	if strings.HasPrefix(tn, "$") && patternLevels[0] != levels[0] {
+		return false
+	}
+	for i, p := range patternLevels {
+		switch {
+		case p == multiLevel:
+			return true
+		case i >= len(levels):
+			return false
+		case p != singleLevel && p != levels[i]:
+			return false
+		}
+	}
+	return len(levels) == len(patternLevels)
+}
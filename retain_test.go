@@ -0,0 +1,68 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	for _, c := range []struct {
+		pattern, tn string
+		expected    bool
+	}{
+		{"orders/+/created", "orders/1/created", true},
+		{"orders/+/created", "orders/1/deleted", false},
+		{"orders/+", "orders/1/created", false},
+		{"orders/#", "orders", true},
+		{"orders/#", "orders/1/created", true},
+		{"#", "$SYS/uptime", false},
+		{"+/uptime", "$SYS/uptime", false},
+		{"$SYS/#", "$SYS/uptime", true},
+		{"$ns/acme/+", "$ns/acme/orders", true},
+		{"+/+", "orders", false},
+	} {
+		if matchPattern(c.pattern, c.tn) != c.expected {
+			t.Errorf("matchPattern(%q, %q) != %v", c.pattern, c.tn, c.expected)
+		}
+	}
+}
+
+func TestPubSub_PublishRetained(t *testing.T) {
+	lib := New()
+	tn := "config/db"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	_ = lib.PublishRetained(tn, []byte("v1"))
+	_ = lib.PublishRetained(tn, []byte("v2"))
+	_ = lib.PublishRetained("config/cache", []byte("c1"))
+	lib.Publish(tn, []byte("not retained"))
+	// existing subscription gets everything as usual
+	if msgs, _ := lib.PollN(tn, sn, 10); len(msgs) != 3 {
+		t.Fatal(msgs)
+	}
+	// resubscribing doesn't deliver retained message again
+	lib.Subscribe(tn, sn)
+	if msg, _ := lib.Poll(tn, sn); msg != nil {
+		t.FailNow()
+	}
+
+	lib.Subscribe(tn, "late subscriber")
+	if msgs, _ := lib.PollN(tn, "late subscriber", 10); len(msgs) != 1 || string(msgs[0]) != "v2" {
+		t.Fatal(msgs)
+	}
+	lib.Subscribe("config/#", "late subscriber")
+	msgs, _ := lib.PollN("config/#", "late subscriber", 10)
+	if len(msgs) != 2 || string(msgs[0]) != "c1" || string(msgs[1]) != "v2" {
+		t.Fatal(msgs)
+	}
+	ch, _ := lib.SubscribeChan(tn, "channel subscriber", 1)
+	if msg := <-ch; string(msg) != "v2" {
+		t.FailNow()
+	}
+
+	// empty message removes retained one
+	_ = lib.PublishRetained(tn, nil)
+	lib.Subscribe(tn, "another late subscriber")
+	if msg, _ := lib.Poll(tn, "another late subscriber"); msg != nil {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,15 @@
+package pubsub
+
+import "math/rand"
+
+// Checks if message passes sampling of the subscription (Options.SampleEvery and Options.SampleRate)
+// Every call counts a message for SampleEvery, so it's called once per message
+func (s *subscription) sampled() bool {
+	if s.opts.SampleEvery > 1 {
+		s.sampleN++
+		if (s.sampleN-1)%s.opts.SampleEvery != 0 {
+			return false
+		}
+	}
+	return s.opts.SampleRate <= 0 || rand.Float64() < s.opts.SampleRate
+}
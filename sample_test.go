@@ -0,0 +1,33 @@
+package pubsub
+
+import "testing"
+
+func TestPubSub_SampleEvery(t *testing.T) {
+	lib := New()
+	lib.SubscribeWithOptions("metrics", "sampled", Options{SampleEvery: 3, Filter: HeaderExists("cpu")})
+	lib.Subscribe("metrics", "all")
+	for _, b := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		_, _ = lib.PublishMsg("metrics", Message{Headers: map[string]string{"cpu": ""}, Body: []byte(b)})
+		// rejected by filter, so not counted
+		lib.Publish("metrics", []byte("memory"))
+	}
+	for _, want := range []string{"1", "4", "7"} {
+		if b, _ := lib.Poll("metrics", "sampled"); string(b) != want {
+			t.Fatal(string(b))
+		}
+	}
+	if depth, _ := lib.Depth("metrics", "all"); depth != 14 {
+		t.Fatal(depth)
+	}
+}
+
+func TestPubSub_SampleRate(t *testing.T) {
+	lib := New()
+	lib.SubscribeWithOptions("metrics", "sampled", Options{SampleRate: 0.5})
+	for i := 0; i < 1000; i++ {
+		lib.Publish("metrics", []byte("message"))
+	}
+	if depth, _ := lib.Depth("metrics", "sampled"); depth < 350 || depth > 650 {
+		t.Fatal(depth)
+	}
+}
@@ -0,0 +1,338 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Default of NewSessions timeout
+const DefaultSessionTimeout = time.Minute
+
+// Interval of polling for new messages by Session.NextWait of brokers which can't wait for them
+const sessionPollInterval = 20 * time.Millisecond
+
+// Sessions of remote subscribers, used by network layers (pubsubhttp, pubsubws), keyed by topic name, subscription
+// name and session ID chosen by subscriber. Messages delivered to a session are polled with PollAck and stay in
+// flight until subscriber acknowledges them, so subscriber reconnecting with the same session ID gets messages it
+// didn't acknowledge again before new ones instead of losing them. Delivery is at-least-once
+// Session not used (polled, acknowledged or kept alive by Heartbeat) during timeout expires: it's dropped, its
+// messages return to the subscription when their visibility timeout (the same timeout) expires, and the subscription
+// is handled by SetExpiry action once its last session expires
+// parked - subscriptions paused by SessionPause, resumed by the next session
+// clock - source of time of sessions, see SetClock
+type Sessions struct {
+	timeout  time.Duration
+	mux      sync.Mutex
+	sessions map[sessionKey]*Session
+	expiry   SessionExpiry
+	parked   map[sessionKey]bool
+	clock    Clock
+}
+
+// Action applied to subscription when its last session expires, see Sessions.SetExpiry
+type SessionExpiry int
+
+const (
+	// SessionKeep - subscription stays and keeps collecting messages
+	SessionKeep SessionExpiry = iota
+	// SessionUnsubscribe - subscription is removed, so subscriptions of disappeared subscribers don't leak
+	SessionUnsubscribe
+	// SessionPause - subscription is paused (see Pause) and resumed when subscriber comes back with a session
+	SessionPause
+)
+
+type sessionKey struct {
+	tn, sn, id string
+}
+
+// Constructor. Creates sessions dropped after (timeout) of inactivity, DefaultSessionTimeout is used if zero
+func NewSessions(timeout time.Duration) *Sessions {
+	if timeout <= 0 {
+		timeout = DefaultSessionTimeout
+	}
+	return &Sessions{
+		timeout:  timeout,
+		sessions: map[sessionKey]*Session{},
+		parked:   map[sessionKey]bool{},
+		clock:    realClock{},
+	}
+}
+
+// Setting clock (c) of sessions, real time is used by default. It should be the clock of broker (see WithClock),
+// so sessions expire together with visibility timeouts of their messages, e.g. by Advance of ManualClock
+// Sessions created before keep their clock
+func (s *Sessions) SetClock(c Clock) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.clock = c
+}
+
+// Setting action applied to subscription when its last session expires, SessionKeep by default
+func (s *Sessions) SetExpiry(action SessionExpiry) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.expiry = action
+}
+
+// Session of subscription (sn) of topic (tn), see Sessions
+// inflight - delivered messages not acknowledged yet in order of delivery, next - index of the next one to deliver
+// used - time of the last call by clock, timer expires session if it's older than timeout
+// extended - time visibility timeouts of inflight were extended last time (see extend)
+// waiters - number of NextWait calls waiting for a message, session is used while they wait
+// ps - broker of the last call, used by expiry action, unpark - subscription is paused by SessionPause
+type Session struct {
+	tn, sn   string
+	timeout  time.Duration
+	mux      sync.Mutex
+	lastSeq  uint64
+	inflight []delivery
+	next     int
+	clock    Clock
+	used     time.Time
+	extended time.Time
+	timer    Timer
+	waiters  int
+	ps       PubSuber
+	unpark   bool
+}
+
+// Message delivered by session with its sequence number and token of PollAck
+type delivery struct {
+	seq   uint64
+	token AckToken
+	msg   *Message
+}
+
+// Resuming session (id) of subscription (sn) of topic (tn) or creating a new one if it doesn't exist or expired
+// Delivery of resumed session restarts from the oldest message not acknowledged yet
+func (s *Sessions) Resume(tn, sn, id string) *Session {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	key := sessionKey{tn, sn, id}
+	sess, ok := s.sessions[key]
+	if !ok {
+		sess = &Session{tn: tn, sn: sn, timeout: s.timeout, clock: s.clock, used: s.clock.Now()}
+		sess.unpark = s.parked[sessionKey{tn: tn, sn: sn}]
+		delete(s.parked, sessionKey{tn: tn, sn: sn})
+		s.arm(key, sess, s.timeout)
+		s.sessions[key] = sess
+	}
+	sess.mux.Lock()
+	sess.next = 0
+	sess.touch(nil)
+	sess.mux.Unlock()
+	return sess
+}
+
+// Keeping session (id) of subscription (sn) of topic (tn) alive without polling, false if there is no such session
+func (s *Sessions) Heartbeat(tn, sn, id string) bool {
+	s.mux.Lock()
+	sess, ok := s.sessions[sessionKey{tn, sn, id}]
+	s.mux.Unlock()
+	if ok {
+		sess.mux.Lock()
+		sess.touch(nil)
+		sess.mux.Unlock()
+	}
+	return ok
+}
+
+// Arming timer of session (sess) with key (key) calling expire after (d)
+// Lock of session must be held by caller if the session is visible to other goroutines
+func (s *Sessions) arm(key sessionKey, sess *Session, d time.Duration) {
+	sess.timer = sess.clock.AfterFunc(d, func() { s.expire(key, sess) })
+}
+
+// Dropping session (sess) with key (key) if it wasn't used during timeout, otherwise its timer is armed again
+// Session waiting for a message in NextWait is used, timeouts of its messages in flight are extended meanwhile
+// Expiry action is applied to its subscription unless the subscription has other sessions
+func (s *Sessions) expire(key sessionKey, sess *Session) {
+	s.mux.Lock()
+	sess.mux.Lock()
+	current := s.sessions[key] == sess
+	if current && sess.waiters > 0 {
+		sess.used = sess.clock.Now()
+		sess.extend()
+		s.arm(key, sess, sess.timeout/2)
+	}
+	idle, ps := sess.clock.Now().Sub(sess.used), sess.ps
+	expired := idle >= sess.timeout
+	if current && sess.waiters == 0 && !expired {
+		s.arm(key, sess, sess.timeout-idle)
+	}
+	sess.mux.Unlock()
+	if !current || !expired {
+		s.mux.Unlock()
+		return
+	}
+	delete(s.sessions, key)
+	for k := range s.sessions {
+		if k.tn == key.tn && k.sn == key.sn {
+			s.mux.Unlock()
+			return
+		}
+	}
+	action := s.expiry
+	if action == SessionPause && ps != nil {
+		s.parked[sessionKey{tn: key.tn, sn: key.sn}] = true
+	}
+	s.mux.Unlock()
+	if ps == nil {
+		return
+	}
+	switch action {
+	case SessionUnsubscribe:
+		ps.Unsubscribe(key.tn, key.sn)
+	case SessionPause:
+		_ = ps.Pause(key.tn, key.sn, false)
+	}
+}
+
+// Marking session used by broker (ps) now, subscription paused by expiry of previous session is resumed
+// Messages in flight are kept by broker of the last call while the session is used (see extend)
+// Lock of session must be held by caller
+func (s *Session) touch(ps PubSuber) {
+	s.used = s.clock.Now()
+	if ps != nil {
+		s.ps = ps
+		if s.unpark {
+			s.unpark = false
+			_ = ps.Resume(s.tn, s.sn)
+		}
+	}
+	s.extend()
+}
+
+// Extending visibility timeouts of delivered messages with ExtendAck, so they aren't redelivered to other sessions
+// while the session is alive. Messages which timeout expired already were returned to the subscription, they are
+// dropped from the session. Timeouts are extended once per half of timeout at most
+// Lock of session must be held by caller
+func (s *Session) extend() {
+	if s.ps == nil || len(s.inflight) == 0 || s.used.Sub(s.extended) < s.timeout/2 {
+		return
+	}
+	s.extended = s.used
+	n, next := 0, s.next
+	for i, d := range s.inflight {
+		if err := s.ps.ExtendAck(s.tn, s.sn, d.token, s.timeout); errors.Is(err, ErrUnknownAckToken) {
+			if i < s.next {
+				next--
+			}
+			continue
+		}
+		s.inflight[n] = d
+		n++
+	}
+	clear(s.inflight[n:])
+	s.inflight, s.next = s.inflight[:n], next
+}
+
+// Fetching the next message of session: message not acknowledged yet delivered again or a new one polled from
+// broker (ps) with PollAck. Returns message with its sequence number, nil message if there are no messages
+func (s *Session) Next(ps PubSuber) (*Message, uint64, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.touch(ps)
+	if s.next < len(s.inflight) {
+		d := s.inflight[s.next]
+		s.next++
+		return d.msg, d.seq, nil
+	}
+	msg, token, err := ps.PollAckMsg(s.tn, s.sn, s.timeout)
+	if errors.Is(err, ErrEmpty) || errors.Is(err, ErrMaxInFlight) {
+		return nil, 0, nil
+	}
+	if msg == nil {
+		return nil, 0, err
+	}
+	return msg, s.add(msg, token), nil
+}
+
+// Adding message (msg) polled with (token) to delivered messages, returns its sequence number
+// Lock of session must be held by caller
+func (s *Session) add(msg *Message, token AckToken) uint64 {
+	if s.next == len(s.inflight) {
+		s.next++
+	}
+	s.lastSeq++
+	s.inflight = append(s.inflight, delivery{seq: s.lastSeq, token: token, msg: msg})
+	return s.lastSeq
+}
+
+// Broker waiting for a message for PollAckMsg like PollMsgWait, implemented by brokers of the package
+type ackWaiter interface {
+	pollAckWait(ctx context.Context, tn, sn string, visibility time.Duration) (*Message, AckToken, error)
+}
+
+// Waiting for the next message like Next until it arrives or ctx is done
+// Brokers of the package are waited for like PollMsgWait, other brokers are polled periodically by clock of session
+func (s *Session) NextWait(ctx context.Context, ps PubSuber) (*Message, uint64, error) {
+	for {
+		msg, seq, err := s.Next(ps)
+		if msg != nil || err != nil {
+			return msg, seq, err
+		}
+		if w, ok := ps.(ackWaiter); ok {
+			return s.wait(ctx, w, ps)
+		}
+		if err := s.sleep(ctx, sessionPollInterval); err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+// Waiting for a message of session with broker (ps) implementing ackWaiter (w)
+func (s *Session) wait(ctx context.Context, w ackWaiter, ps PubSuber) (*Message, uint64, error) {
+	s.mux.Lock()
+	s.waiters++
+	s.mux.Unlock()
+	msg, token, err := w.pollAckWait(ctx, s.tn, s.sn, s.timeout)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.waiters--
+	s.touch(ps)
+	if msg == nil {
+		return nil, 0, err
+	}
+	return msg, s.add(msg, token), nil
+}
+
+// Sleeping for (d) by clock of session, error of ctx is returned if it's done meanwhile
+func (s *Session) sleep(ctx context.Context, d time.Duration) error {
+	wake := make(chan struct{})
+	t := s.clock.AfterFunc(d, func() { close(wake) })
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wake:
+		return nil
+	}
+}
+
+// Acknowledging messages with sequence numbers up to (seq) with broker (ps)
+// Messages whose visibility timeout expired already are skipped, they were returned to the subscription
+func (s *Session) Ack(ps PubSuber, seq uint64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.touch(ps)
+	n := 0
+	var err error
+	for ; n < len(s.inflight) && s.inflight[n].seq <= seq; n++ {
+		if e := ps.Ack(s.tn, s.sn, s.inflight[n].token); e != nil && e != ErrUnknownAckToken && err == nil {
+			err = e
+		}
+	}
+	s.inflight = s.inflight[n:]
+	s.next = max(s.next-n, 0)
+	return err
+}
+
+// Number of delivered messages not acknowledged yet
+func (s *Session) InFlight() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.inflight)
+}
@@ -0,0 +1,190 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	lib := New()
+	lib.Subscribe("orders", "billing")
+	for _, b := range []string{"1", "2", "3"} {
+		lib.Publish("orders", []byte(b))
+	}
+	sessions := NewSessions(0)
+	sess := sessions.Resume("orders", "billing", "s1")
+	for i, want := range []string{"1", "2"} {
+		msg, seq, err := sess.Next(lib)
+		if err != nil || string(msg.Body) != want || seq != uint64(i+1) {
+			t.Fatal(msg, seq, err)
+		}
+	}
+	if err := sess.Ack(lib, 1); err != nil {
+		t.Fatal(err)
+	}
+	// subscriber reconnects and gets not acknowledged message again
+	sess = sessions.Resume("orders", "billing", "s1")
+	if msg, seq, _ := sess.Next(lib); string(msg.Body) != "2" || seq != 2 {
+		t.Fatal(msg, seq)
+	}
+	if msg, seq, _ := sess.Next(lib); string(msg.Body) != "3" || seq != 3 {
+		t.Fatal(msg, seq)
+	}
+	if sess.InFlight() != 2 {
+		t.Fatal(sess.InFlight())
+	}
+	// other sessions of the subscription don't see messages in flight
+	if msg, _, _ := sessions.Resume("orders", "billing", "s2").Next(lib); msg != nil {
+		t.Fatal(msg)
+	}
+	if err := sess.Ack(lib, 3); err != nil {
+		t.Fatal(err)
+	}
+	if depth, _ := lib.Depth("orders", "billing"); depth != 0 || sess.InFlight() != 0 {
+		t.Fatal(depth)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		lib.Publish("orders", []byte("4"))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if msg, seq, err := sess.NextWait(ctx, lib); err != nil || string(msg.Body) != "4" || seq != 4 {
+		t.Fatal(msg, seq, err)
+	}
+}
+
+// Broker and sessions sharing manual clock
+func newSessionsTest(timeout time.Duration) (PubSuber, *Sessions) {
+	c := &stepClock{now: time.Unix(0, 0)}
+	sessions := NewSessions(timeout)
+	sessions.SetClock(c)
+	return New(WithClock(c)), sessions
+}
+
+func TestSessions_Heartbeat(t *testing.T) {
+	lib, sessions := newSessionsTest(100 * time.Millisecond)
+	lib.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("order"))
+	sess := sessions.Resume("orders", "billing", "s1")
+	if msg, _, _ := sess.Next(lib); msg == nil {
+		t.Fatal(msg)
+	}
+	// heartbeats keep the message in flight longer than its visibility timeout
+	for i := 0; i < 30; i++ {
+		lib.Advance(10 * time.Millisecond)
+		if !sessions.Heartbeat("orders", "billing", "s1") {
+			t.Fatal(i)
+		}
+	}
+	if msg, _, _ := sessions.Resume("orders", "billing", "s2").Next(lib); msg != nil {
+		t.Fatal(msg)
+	}
+	if err := sess.Ack(lib, 1); err != nil || sess.InFlight() != 0 {
+		t.Fatal(err, sess.InFlight())
+	}
+	if depth, _ := lib.Depth("orders", "billing"); depth != 0 {
+		t.Fatal(depth)
+	}
+}
+
+func TestSessions_NextWait(t *testing.T) {
+	lib, sessions := newSessionsTest(100 * time.Millisecond)
+	lib.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("1"))
+	sess := sessions.Resume("orders", "billing", "s1")
+	if msg, _, _ := sess.Next(lib); msg == nil {
+		t.Fatal(msg)
+	}
+	type next struct {
+		msg *Message
+		seq uint64
+		err error
+	}
+	done := make(chan next)
+	go func() {
+		msg, seq, err := sess.NextWait(context.Background(), lib)
+		done <- next{msg, seq, err}
+	}()
+	for waiting := false; !waiting; {
+		time.Sleep(time.Millisecond)
+		sess.mux.Lock()
+		waiting = sess.waiters > 0
+		sess.mux.Unlock()
+	}
+	// waiting session doesn't expire and keeps its message in flight
+	for i := 0; i < 10; i++ {
+		lib.Advance(50 * time.Millisecond)
+	}
+	if n, _ := lib.InFlight("orders", "billing"); n != 1 || sess.InFlight() != 1 {
+		t.Fatal(n, sess.InFlight())
+	}
+	lib.Publish("orders", []byte("2"))
+	if n := <-done; n.err != nil || string(n.msg.Body) != "2" || n.seq != 2 {
+		t.Fatal(n)
+	}
+	if err := sess.Ack(lib, 2); err != nil || sess.InFlight() != 0 {
+		t.Fatal(err, sess.InFlight())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if msg, _, err := sess.NextWait(ctx, lib); err != context.Canceled || msg != nil {
+		t.Fatal(msg, err)
+	}
+}
+
+func TestSessions_Expire(t *testing.T) {
+	lib, sessions := newSessionsTest(20 * time.Millisecond)
+	lib.Subscribe("orders", "billing")
+	lib.Publish("orders", []byte("order"))
+	if msg, _, _ := sessions.Resume("orders", "billing", "s1").Next(lib); msg == nil {
+		t.Fatal(msg)
+	}
+	lib.Advance(50 * time.Millisecond)
+	// expired session is replaced and its message is back in the subscription
+	sess := sessions.Resume("orders", "billing", "s1")
+	if msg, seq, _ := sess.Next(lib); msg == nil || string(msg.Body) != "order" || seq != 1 {
+		t.Fatal(msg, seq)
+	}
+}
+
+func TestSessions_SetExpiry(t *testing.T) {
+	lib, sessions := newSessionsTest(50 * time.Millisecond)
+	lib.Subscribe("orders", "billing")
+	lib.Subscribe("orders", "shipping")
+	sessions.SetExpiry(SessionUnsubscribe)
+	_, _, _ = sessions.Resume("orders", "billing", "s1").Next(lib)
+	sess := sessions.Resume("orders", "shipping", "s1")
+	_, _, _ = sess.Next(lib)
+	// heartbeats keep the session alive
+	for i := 0; i < 5; i++ {
+		lib.Advance(10 * time.Millisecond)
+		if !sessions.Heartbeat("orders", "shipping", "s1") {
+			t.Fatal(i)
+		}
+	}
+	if subs, _ := lib.Subscriptions("orders"); len(subs) != 1 || subs[0] != "shipping" {
+		t.Fatal(subs)
+	}
+	lib.Advance(150 * time.Millisecond)
+	if subs, _ := lib.Subscriptions("orders"); len(subs) != 0 {
+		t.Fatal(subs)
+	}
+	if sessions.Heartbeat("orders", "shipping", "s1") {
+		t.Fatal()
+	}
+
+	lib.Subscribe("orders", "billing")
+	sessions.SetExpiry(SessionPause)
+	_, _, _ = sessions.Resume("orders", "billing", "s1").Next(lib)
+	lib.Advance(150 * time.Millisecond)
+	lib.Publish("orders", []byte("order"))
+	if _, err := lib.Poll("orders", "billing"); err != ErrPaused {
+		t.Fatal(err)
+	}
+	// subscriber comes back
+	if msg, _, err := sessions.Resume("orders", "billing", "s2").Next(lib); err != nil || string(msg.Body) != "order" {
+		t.Fatal(msg, err)
+	}
+}
@@ -0,0 +1,508 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"iter"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of points of every node on HashRing
+const DefaultHashRingReplicas = 128
+
+// Consistent hash ring mapping keys to nodes, adding or removing a node moves only keys of neighbouring points
+// Every node has (replicas) points on the ring, more points spread keys more evenly
+type HashRing struct {
+	mux      sync.RWMutex
+	replicas int
+	points   []hashRingPoint
+	nodes    map[string]struct{}
+}
+
+// Point of node on HashRing
+type hashRingPoint struct {
+	hash uint64
+	node string
+}
+
+// Constructor. Creates ring of nodes (nodes) with (replicas) points of every node, default number if not positive
+func NewHashRing(replicas int, nodes ...string) *HashRing {
+	if replicas <= 0 {
+		replicas = DefaultHashRingReplicas
+	}
+	r := &HashRing{replicas: replicas, nodes: map[string]struct{}{}}
+	for _, node := range nodes {
+		r.Add(node)
+	}
+	return r
+}
+
+// Hash of key (s) on ring, FNV-1a is finalized like in splitmix64, so similar keys spread over the ring
+func hashRingKey(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// Adding node (node) to ring, adding it again does nothing
+func (r *HashRing) Add(node string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.nodes[node]; ok {
+		return
+	}
+	r.nodes[node] = struct{}{}
+	for i := 0; i < r.replicas; i++ {
+		r.points = append(r.points, hashRingPoint{hash: hashRingKey(node + "#" + strconv.Itoa(i)), node: node})
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash != r.points[j].hash {
+			return r.points[i].hash < r.points[j].hash
+		}
+		return r.points[i].node < r.points[j].node
+	})
+}
+
+// Removing node (node) from ring
+func (r *HashRing) Remove(node string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.nodes[node]; !ok {
+		return
+	}
+	delete(r.nodes, node)
+	r.points = slices.DeleteFunc(r.points, func(p hashRingPoint) bool { return p.node == node })
+}
+
+// Node owning key (key): the node of the first point after hash of key, empty if ring has no nodes
+func (r *HashRing) Owner(key string) string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hashRingKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// Nodes of ring, sorted
+func (r *HashRing) Nodes() []string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Facade sharding topics across brokers, see Shard
+// names - names of brokers sorted, ring - owners of topic names
+type sharded struct {
+	brokers  map[string]PubSuber
+	names    []string
+	ring     *HashRing
+	idPrefix string
+	lastID   uint64
+}
+
+// Constructor. Creates PubSuber spreading topics across brokers (brokers) by names of brokers, e.g. clients of
+// remote broker instances: every topic is owned by one broker chosen by consistent hashing of its name, so callers
+// don't know which broker owns which topic, and adding a broker moves a small share of topics only
+// Clients using the same broker names agree on owners. Topic methods go to the owner; Topics, Stats, Health and
+// Events combine all brokers, Use, Close and Advance are called for all of them. Wildcard subscriptions, Forward and
+// transactions work within one broker: a pattern or source topic is owned by the broker its own name hashes to
+// Snapshot and Restore aren't supported
+// At least one broker must be passed
+func Shard(brokers map[string]PubSuber) PubSuber {
+	s := &sharded{
+		brokers:  brokers,
+		ring:     NewHashRing(DefaultHashRingReplicas),
+		idPrefix: newIDPrefix(),
+	}
+	for name := range brokers {
+		s.names = append(s.names, name)
+		s.ring.Add(name)
+	}
+	sort.Strings(s.names)
+	return s
+}
+
+// Broker owning topic name (tn)
+func (s *sharded) owner(tn string) PubSuber {
+	return s.brokers[s.ring.Owner(tn)]
+}
+
+// Calling (fn) for every broker in order of names, errors are joined
+func (s *sharded) each(fn func(ps PubSuber) error) error {
+	var errs []error
+	for _, name := range s.names {
+		if err := fn(s.brokers[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *sharded) Publish(tn string, b []byte) {
+	s.owner(tn).Publish(tn, b)
+}
+
+func (s *sharded) TryPublish(tn string, b []byte) error {
+	return s.owner(tn).TryPublish(tn, b)
+}
+
+func (s *sharded) PublishResult(tn string, b []byte) (int, error) {
+	return s.owner(tn).PublishResult(tn, b)
+}
+
+// Messages of (fn) are grouped by owners and published by transaction of every owner, (fn) is called once
+// Transaction is atomic within every owner, but not across them
+func (s *sharded) PublishTx(fn func(tx Tx) error) error {
+	t := &shardedTx{s: s}
+	defer func() { t.done = true }()
+	if err := fn(t); err != nil {
+		return err
+	}
+	t.done = true
+	groups := map[string][]multiTxItem{}
+	for _, it := range t.items {
+		name := s.ring.Owner(it.tn)
+		groups[name] = append(groups[name], it)
+	}
+	var errs []error
+	for _, name := range s.names {
+		items, ok := groups[name]
+		if !ok {
+			continue
+		}
+		err := s.brokers[name].PublishTx(func(tx Tx) error {
+			for _, it := range items {
+				if _, err := tx.PublishMsg(it.tn, it.msg); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *sharded) PublishMsg(tn string, msg Message) (string, error) {
+	return s.owner(tn).PublishMsg(tn, msg)
+}
+
+func (s *sharded) PublishAfter(tn string, b []byte, delay time.Duration) {
+	s.owner(tn).PublishAfter(tn, b, delay)
+}
+
+func (s *sharded) PublishAt(tn string, b []byte, at time.Time) {
+	s.owner(tn).PublishAt(tn, b, at)
+}
+
+func (s *sharded) PublishWithPriority(tn string, b []byte, prio int) error {
+	return s.owner(tn).PublishWithPriority(tn, b, prio)
+}
+
+func (s *sharded) PublishRetained(tn string, b []byte) error {
+	return s.owner(tn).PublishRetained(tn, b)
+}
+
+func (s *sharded) PublishWithTTL(tn string, b []byte, ttl time.Duration) error {
+	return s.owner(tn).PublishWithTTL(tn, b, ttl)
+}
+
+func (s *sharded) PublishWithKey(tn string, key string, b []byte) {
+	s.owner(tn).PublishWithKey(tn, key, b)
+}
+
+func (s *sharded) Subscribe(tn, sn string) {
+	s.owner(tn).Subscribe(tn, sn)
+}
+
+func (s *sharded) SubscribeFrom(tn, sn string, from SeekPosition) error {
+	return s.owner(tn).SubscribeFrom(tn, sn, from)
+}
+
+func (s *sharded) Seek(tn, sn string, seq uint64) error {
+	return s.owner(tn).Seek(tn, sn, seq)
+}
+
+func (s *sharded) SetHistory(tn string, n int) {
+	s.owner(tn).SetHistory(tn, n)
+}
+
+func (s *sharded) SubscribeWithOptions(tn, sn string, opts Options) {
+	s.owner(tn).SubscribeWithOptions(tn, sn, opts)
+}
+
+func (s *sharded) SubscribeChan(tn, sn string, buf int) (<-chan []byte, error) {
+	return s.owner(tn).SubscribeChan(tn, sn, buf)
+}
+
+func (s *sharded) SubscribeFunc(tn, sn string, fn func([]byte), opts ...HandlerOption) error {
+	return s.owner(tn).SubscribeFunc(tn, sn, fn, opts...)
+}
+
+func (s *sharded) Unsubscribe(tn, sn string) {
+	s.owner(tn).Unsubscribe(tn, sn)
+}
+
+func (s *sharded) PurgeSubscription(tn, sn string) (int, error) {
+	return s.owner(tn).PurgeSubscription(tn, sn)
+}
+
+func (s *sharded) PurgeTopic(tn string) (int, error) {
+	return s.owner(tn).PurgeTopic(tn)
+}
+
+func (s *sharded) CreateTopic(tn string, cfg TopicConfig) error {
+	return s.owner(tn).CreateTopic(tn, cfg)
+}
+
+func (s *sharded) ConfigureTopic(tn string, cfg TopicConfig) error {
+	return s.owner(tn).ConfigureTopic(tn, cfg)
+}
+
+func (s *sharded) TopicConfig(tn string) (TopicConfig, error) {
+	return s.owner(tn).TopicConfig(tn)
+}
+
+// Handle of topic of the broker owning it, so it caches the topic like handles of the broker
+func (s *sharded) Topic(tn string) *Topic {
+	return s.owner(tn).Topic(tn)
+}
+
+func (s *sharded) DeleteTopic(tn string) {
+	s.owner(tn).DeleteTopic(tn)
+}
+
+func (s *sharded) Poll(tn, sn string) ([]byte, error) {
+	return s.owner(tn).Poll(tn, sn)
+}
+
+func (s *sharded) PollMsg(tn, sn string) (*Message, error) {
+	return s.owner(tn).PollMsg(tn, sn)
+}
+
+func (s *sharded) PollN(tn, sn string, max int) ([][]byte, error) {
+	return s.owner(tn).PollN(tn, sn, max)
+}
+
+func (s *sharded) Iter(ctx context.Context, tn, sn string) iter.Seq2[[]byte, error] {
+	return s.owner(tn).Iter(ctx, tn, sn)
+}
+
+func (s *sharded) PollBytes(tn, sn string, maxBytes int) ([][]byte, error) {
+	return s.owner(tn).PollBytes(tn, sn, maxBytes)
+}
+
+func (s *sharded) PollWait(ctx context.Context, tn, sn string) ([]byte, error) {
+	return s.owner(tn).PollWait(ctx, tn, sn)
+}
+
+func (s *sharded) PollMsgWait(ctx context.Context, tn, sn string) (*Message, error) {
+	return s.owner(tn).PollMsgWait(ctx, tn, sn)
+}
+
+func (s *sharded) PollAck(tn, sn string, visibility time.Duration) ([]byte, AckToken, error) {
+	return s.owner(tn).PollAck(tn, sn, visibility)
+}
+
+func (s *sharded) PollAckMsg(tn, sn string, visibility time.Duration) (*Message, AckToken, error) {
+	return s.owner(tn).PollAckMsg(tn, sn, visibility)
+}
+
+func (s *sharded) Ack(tn, sn string, token AckToken) error {
+	return s.owner(tn).Ack(tn, sn, token)
+}
+
+func (s *sharded) Nack(tn, sn string, token AckToken, requeue bool) error {
+	return s.owner(tn).Nack(tn, sn, token, requeue)
+}
+
+func (s *sharded) ExtendAck(tn, sn string, token AckToken, visibility time.Duration) error {
+	return s.owner(tn).ExtendAck(tn, sn, token, visibility)
+}
+
+func (s *sharded) PollDLQ(tn, sn string) (*Message, error) {
+	return s.owner(tn).PollDLQ(tn, sn)
+}
+
+func (s *sharded) Redrive(tn, sn string) (int, error) {
+	return s.owner(tn).Redrive(tn, sn)
+}
+
+// Names of topics of all brokers, sorted
+func (s *sharded) Topics() []string {
+	var topics []string
+	for _, name := range s.names {
+		topics = append(topics, s.brokers[name].Topics()...)
+	}
+	slices.Sort(topics)
+	return slices.Compact(topics)
+}
+
+func (s *sharded) Subscriptions(tn string) ([]string, error) {
+	return s.owner(tn).Subscriptions(tn)
+}
+
+func (s *sharded) Depth(tn, sn string) (int, error) {
+	return s.owner(tn).Depth(tn, sn)
+}
+
+func (s *sharded) InFlight(tn, sn string) (int, error) {
+	return s.owner(tn).InFlight(tn, sn)
+}
+
+func (s *sharded) Peek(tn, sn string) ([]byte, error) {
+	return s.owner(tn).Peek(tn, sn)
+}
+
+func (s *sharded) PeekN(tn, sn string, max int) ([][]byte, error) {
+	return s.owner(tn).PeekN(tn, sn, max)
+}
+
+// Statistics of topics of all brokers, sorted by topic names
+func (s *sharded) Stats() BrokerStats {
+	var stats BrokerStats
+	for _, name := range s.names {
+		stats.Topics = append(stats.Topics, s.brokers[name].Stats().Topics...)
+	}
+	sort.SliceStable(stats.Topics, func(i, j int) bool { return stats.Topics[i].Name < stats.Topics[j].Name })
+	return stats
+}
+
+// Health of all brokers: live and ready if all of them are, counters are summed, problems and failed checks are
+// prefixed with name of broker
+func (s *sharded) Health() HealthReport {
+	h := HealthReport{Live: true, Ready: true}
+	for _, name := range s.names {
+		h.add(name, s.brokers[name].Health())
+	}
+	return h
+}
+
+// Adding middleware (mw) to every broker
+func (s *sharded) Use(mw Middleware) {
+	for _, name := range s.names {
+		s.brokers[name].Use(mw)
+	}
+}
+
+func (s *sharded) SetValidator(tn string, v Validator) error {
+	return s.owner(tn).SetValidator(tn, v)
+}
+
+// Adding forwarding rule to the owner of (src), (dst) must be owned by the same broker
+func (s *sharded) Forward(src, dst string, transform func([]byte) ([]byte, bool)) error {
+	return s.owner(src).Forward(src, dst, transform)
+}
+
+func (s *sharded) Unforward(src, dst string) {
+	s.owner(src).Unforward(src, dst)
+}
+
+func (s *sharded) Pause(tn, sn string, drop bool) error {
+	return s.owner(tn).Pause(tn, sn, drop)
+}
+
+func (s *sharded) Resume(tn, sn string) error {
+	return s.owner(tn).Resume(tn, sn)
+}
+
+// Events of all brokers, channel is closed when channels of all brokers are closed
+func (s *sharded) Events(ctx context.Context) <-chan Event {
+	chans := make([]<-chan Event, 0, len(s.names))
+	for _, name := range s.names {
+		chans = append(chans, s.brokers[name].Events(ctx))
+	}
+	return mergeEvents(chans)
+}
+
+// Snapshot of several brokers can't be restored to one of them, so it isn't supported
+func (s *sharded) Snapshot(w io.Writer) error {
+	return errors.ErrUnsupported
+}
+
+func (s *sharded) Close(ctx context.Context) error {
+	return s.each(func(ps PubSuber) error { return ps.Close(ctx) })
+}
+
+func (s *sharded) Advance(d time.Duration) error {
+	return s.each(func(ps PubSuber) error { return ps.Advance(d) })
+}
+
+func (s *sharded) Restore(r io.Reader) error {
+	return errors.ErrUnsupported
+}
+
+// Shard of namespaces (name) of all brokers, topics of namespace are sharded by their names within namespace
+func (s *sharded) Namespace(name string) PubSuber {
+	brokers := make(map[string]PubSuber, len(s.brokers))
+	for n, ps := range s.brokers {
+		brokers[n] = ps.Namespace(name)
+	}
+	return Shard(brokers)
+}
+
+// Shard of facades of principal of all brokers
+func (s *sharded) As(principal any) PubSuber {
+	brokers := make(map[string]PubSuber, len(s.brokers))
+	for n, ps := range s.brokers {
+		brokers[n] = ps.As(principal)
+	}
+	return Shard(brokers)
+}
+
+// Request is published to the owner of (tn), reply topic is sharded like any other topic
+func (s *sharded) Request(ctx context.Context, tn string, b []byte) ([]byte, error) {
+	return request(ctx, s, tn, b)
+}
+
+// Web UI showing topics of all brokers, tail view subscribes to the owner of the topic
+func (s *sharded) AdminHandler() http.Handler {
+	return AdminHandler(s)
+}
+
+// Transaction of sharded, messages are collected and published to their owners by PublishTx
+type shardedTx struct {
+	s     *sharded
+	items []multiTxItem
+	done  bool
+}
+
+func (t *shardedTx) Publish(tn string, b []byte) error {
+	_, err := t.PublishMsg(tn, Message{Body: b})
+	return err
+}
+
+func (t *shardedTx) PublishMsg(tn string, msg Message) (string, error) {
+	if t.done {
+		return "", ErrTxDone
+	}
+	if msg.ID == "" {
+		msg.ID = t.s.idPrefix + "-" + strconv.FormatUint(atomic.AddUint64(&t.s.lastID, 1), 10)
+	}
+	t.items = append(t.items, multiTxItem{tn: tn, msg: msg})
+	return msg.ID, nil
+}
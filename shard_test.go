@@ -0,0 +1,134 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestHashRing(t *testing.T) {
+	r := NewHashRing(0, "a", "b", "c")
+	if nodes := r.Nodes(); len(nodes) != 3 || nodes[0] != "a" || nodes[2] != "c" {
+		t.Fatal(nodes)
+	}
+	owners := map[string]string{}
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		key := "topic/" + strconv.Itoa(i)
+		owners[key] = r.Owner(key)
+		counts[owners[key]]++
+	}
+	for node, n := range counts {
+		if n < 500 {
+			t.Fatal(node, counts)
+		}
+	}
+	// adding a node moves only keys it takes over
+	r.Add("d")
+	r.Add("d")
+	moved := 0
+	for key, owner := range owners {
+		if o := r.Owner(key); o != owner {
+			if o != "d" {
+				t.Fatal(key, owner, o)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 1200 {
+		t.Fatal(moved)
+	}
+	r.Remove("d")
+	for key, owner := range owners {
+		if r.Owner(key) != owner {
+			t.Fatal(key)
+		}
+	}
+	if owner := NewHashRing(1).Owner("orders"); owner != "" {
+		t.Fatal(owner)
+	}
+}
+
+func TestShard(t *testing.T) {
+	brokers := map[string]PubSuber{"a": New(), "b": New(), "c": New()}
+	lib := Shard(brokers)
+	ring := NewHashRing(DefaultHashRingReplicas, "a", "b", "c")
+	used := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		tn := "orders/" + strconv.Itoa(i)
+		lib.Subscribe(tn, "billing")
+		lib.Publish(tn, []byte(tn))
+		owner := ring.Owner(tn)
+		used[owner] = true
+		for name, ps := range brokers {
+			depth, _ := ps.Depth(tn, "billing")
+			if name == owner && depth != 1 || name != owner && depth != 0 {
+				t.Fatal(tn, name, depth)
+			}
+		}
+		if b, err := lib.Poll(tn, "billing"); err != nil || string(b) != tn {
+			t.Fatal(b, err)
+		}
+	}
+	if len(used) != 3 {
+		t.Fatal(used)
+	}
+	if topics := lib.Topics(); len(topics) != 20 {
+		t.Fatal(topics)
+	}
+	if stats := lib.Stats(); len(stats.Topics) != 20 || stats.Topics[0].Name != "orders/0" {
+		t.Fatal(stats)
+	}
+	if h := lib.Health(); !h.Ready || h.Topics != 20 || h.Subscriptions != 20 {
+		t.Fatal(h)
+	}
+	if err := lib.Snapshot(nil); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatal(err)
+	}
+	if err := lib.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShard_PublishTx(t *testing.T) {
+	brokers := map[string]PubSuber{"a": New(), "b": New()}
+	lib := Shard(brokers)
+	for i := 0; i < 10; i++ {
+		lib.Subscribe("t"+strconv.Itoa(i), "s")
+	}
+	if err := lib.PublishTx(func(tx Tx) error {
+		for i := 0; i < 10; i++ {
+			if err := tx.Publish("t"+strconv.Itoa(i), []byte("m")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, ps := range brokers {
+		for _, tn := range ps.Topics() {
+			depth, _ := ps.Depth(tn, "s")
+			total += depth
+		}
+	}
+	if total != 10 {
+		t.Fatal(total)
+	}
+}
+
+func TestShard_Namespace(t *testing.T) {
+	a, b := New(), New()
+	lib := Shard(map[string]PubSuber{"a": a, "b": b})
+	ns := lib.Namespace("tenant")
+	ns.Subscribe("orders", "billing")
+	ns.Publish("orders", []byte("order"))
+	if topics := ns.Topics(); len(topics) != 1 || topics[0] != "orders" {
+		t.Fatal(topics)
+	}
+	if b, _ := ns.Poll("orders", "billing"); string(b) != "order" {
+		t.Fatal(b)
+	}
+}
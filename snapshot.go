@@ -0,0 +1,276 @@
+package pubsub
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version of snapshot format, must be increased on any incompatible change of snapshot structures
+const snapshotVersion = 1
+
+// Error happens if snapshot was written by incompatible version of the package
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+// Error happens if snapshot refers to messages it doesn't contain
+var ErrSnapshotCorrupted = errors.New("snapshot is corrupted")
+
+// Root of the snapshot, every message body is stored once even if it's pending in several subscriptions
+// Retained - indexes of retained messages in Messages
+type snapshot struct {
+	Version  int
+	Messages []snapshotMessage
+	Topics   []snapshotTopic
+	Retained []int
+}
+
+// Compression - name of compressor of body, empty if body isn't compressed
+// KeyID - ID of key body is encrypted with (see WithEncryption), empty if body isn't encrypted
+type snapshotMessage struct {
+	Message
+	Expires     time.Time
+	Priority    int
+	Compression string
+	KeyID       string
+}
+
+// History - indexes in snapshot.Messages from the oldest message
+// Compacted - indexes of the latest messages of keys of compacted topic in order of publish
+type snapshotTopic struct {
+	Name          string
+	Subscriptions []snapshotSubscription
+	LastSeq       uint64
+	HistorySize   int
+	History       []int
+	Config        *TopicConfig
+	Compacted     []int
+}
+
+// Messages - indexes in snapshot.Messages in delivery order
+type snapshotSubscription struct {
+	Name     string
+	Options  Options
+	Messages []int
+}
+
+// Writing all topics, subscriptions and pending messages into (w) using gob encoding (or codec of WithSnapshotCodec)
+// Messages in flight are written as pending ones (they will be delivered again after Restore), expired messages
+// are skipped. Every topic is consistent, but topics are written one by one, not at the same moment
+func (p *pubSub) Snapshot(w io.Writer) error {
+	return p.snapshot(w, "")
+}
+
+// Writing topics which names start with (prefix) like Snapshot, prefix is removed from topic names
+func (p *pubSub) snapshot(w io.Writer, prefix string) error {
+	now := p.now()
+	snap := snapshot{Version: snapshotVersion}
+	indexes := map[*message]int{}
+	index := func(m *message) int {
+		i, ok := indexes[m]
+		if !ok {
+			i = len(snap.Messages)
+			indexes[m] = i
+			msg := m.Message
+			msg.ID = m.id()
+			msg.Topic = strings.TrimPrefix(msg.Topic, prefix)
+			snap.Messages = append(snap.Messages, snapshotMessage{
+				Message: msg, Expires: m.expires, Priority: m.priority, Compression: m.compression, KeyID: m.keyID,
+			})
+		}
+		return i
+	}
+	for _, subs := range p.topics.all() {
+		tn := subs.tn
+		if !strings.HasPrefix(tn, prefix) {
+			continue
+		}
+		subs.mux.Lock()
+		topic := snapshotTopic{Name: tn[len(prefix):], LastSeq: subs.lastSeq, HistorySize: subs.historySize,
+			Config: subs.config.Load()}
+		for _, m := range subs.history {
+			topic.History = append(topic.History, index(m))
+		}
+		for _, m := range subs.compacted(now) {
+			topic.Compacted = append(topic.Compacted, index(m))
+		}
+		for sn, sub := range subs.hm {
+			ss := snapshotSubscription{Name: sn, Options: sub.opts}
+			for _, token := range sub.inFlightTokens() {
+				if it := sub.inFlight[token].it; !it.expired(now) {
+					ss.Messages = append(ss.Messages, index(it.message))
+				}
+			}
+			for _, it := range sub.items() {
+				if !it.expired(now) {
+					ss.Messages = append(ss.Messages, index(it.message))
+				}
+			}
+			topic.Subscriptions = append(topic.Subscriptions, ss)
+		}
+		subs.mux.Unlock()
+		snap.Topics = append(snap.Topics, topic)
+	}
+	p.retainMux.Lock()
+	for tn, m := range p.retained {
+		if strings.HasPrefix(tn, prefix) {
+			snap.Retained = append(snap.Retained, index(m))
+		}
+	}
+	p.retainMux.Unlock()
+	if p.snapshotCodec == nil {
+		return gob.NewEncoder(w).Encode(snap)
+	}
+	b, err := p.snapshotCodec.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Encoding snapshots with codec (c) instead of gob, e.g. JSONCodec for snapshots readable by other tools
+// Restore reads snapshots with the same codec. Gob snapshots are streamed, others are built in memory first
+func WithSnapshotCodec(c Codec) Option {
+	return func(p *pubSub) {
+		p.snapshotCodec = c
+	}
+}
+
+// Reading topics, subscriptions and pending messages written by Snapshot from (r)
+// Missing topics and subscriptions are created (as polling ones), options of existing subscriptions are replaced and
+// messages are added after already pending ones. Subscriptions removed concurrently with Restore are skipped
+// ErrNoKeyProvider raises if snapshot has encrypted messages, but the broker is created without WithEncryption
+func (p *pubSub) Restore(r io.Reader) error {
+	return p.restore(r, "")
+}
+
+// Reading snapshot like Restore, (prefix) is added to topic names
+func (p *pubSub) restore(r io.Reader, prefix string) error {
+	if p.closed.Load() {
+		return ErrClosed
+	}
+	var snap snapshot
+	if p.snapshotCodec == nil {
+		if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+			return err
+		}
+	} else {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if err := p.snapshotCodec.Unmarshal(b, &snap); err != nil {
+			return err
+		}
+	}
+	if snap.Version != snapshotVersion {
+		return ErrSnapshotVersion
+	}
+	msgs := make([]*message, len(snap.Messages))
+	for i, m := range snap.Messages {
+		// restored messages are shared by subscriptions and history without references, so they're pinned
+		msgs[i] = &message{Message: m.Message, expires: m.Expires, priority: m.Priority, refs: 1}
+		msgs[i].Topic = prefix + m.Topic
+		if m.Compression != "" {
+			if msgs[i].codec = compressorOf(m.Compression); msgs[i].codec == nil {
+				return ErrUnknownCompressor
+			}
+			msgs[i].compression = m.Compression
+		}
+		if m.KeyID != "" {
+			if p.enc == nil {
+				return ErrNoKeyProvider
+			}
+			msgs[i].enc, msgs[i].keyID = p.enc, m.KeyID
+		}
+	}
+	for _, topic := range snap.Topics {
+		for _, ss := range topic.Subscriptions {
+			for _, i := range ss.Messages {
+				if i < 0 || i >= len(msgs) {
+					return ErrSnapshotCorrupted
+				}
+			}
+		}
+		for _, i := range topic.History {
+			if i < 0 || i >= len(msgs) {
+				return ErrSnapshotCorrupted
+			}
+		}
+		for _, i := range topic.Compacted {
+			if i < 0 || i >= len(msgs) {
+				return ErrSnapshotCorrupted
+			}
+		}
+	}
+	for _, i := range snap.Retained {
+		if i < 0 || i >= len(msgs) {
+			return ErrSnapshotCorrupted
+		}
+	}
+	p.retainMux.Lock()
+	for _, i := range snap.Retained {
+		if p.retained == nil {
+			p.retained = map[string]*message{}
+		}
+		p.retained[msgs[i].Topic] = msgs[i]
+	}
+	p.retainMux.Unlock()
+	for _, topic := range snap.Topics {
+		topic.Name = prefix + topic.Name
+		if topic.Config != nil {
+			if err := p.CreateTopic(topic.Name, *topic.Config); err != nil && err != ErrTopicExists {
+				return err
+			}
+		}
+		for _, ss := range topic.Subscriptions {
+			opts := ss.Options
+			p.subscribe(topic.Name, ss.Name, &opts)
+		}
+		subs, err := p.lockSubscribable(topic.Name)
+		if err != nil {
+			// topic of snapshot doesn't exist in strict mode
+			continue
+		}
+		if topic.LastSeq > subs.lastSeq {
+			subs.lastSeq = topic.LastSeq
+		}
+		if topic.HistorySize > 0 {
+			subs.historySize = topic.HistorySize
+			for _, i := range topic.History {
+				subs.record(msgs[i])
+			}
+		}
+		for _, i := range topic.Compacted {
+			subs.compact(msgs[i])
+		}
+		for _, ss := range topic.Subscriptions {
+			sub, ok := subs.hm[ss.Name]
+			if !ok {
+				continue
+			}
+			for _, i := range ss.Messages {
+				msgs[i].retain()
+				sub.add(msgs[i])
+			}
+			sub.cond.Broadcast()
+		}
+		subs.mux.Unlock()
+	}
+	return nil
+}
+
+// Tokens of messages in flight in the order they were polled
+func (s *subscription) inFlightTokens() []AckToken {
+	tokens := make([]AckToken, 0, len(s.inFlight))
+	for token := range s.inFlight {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i] < tokens[j]
+	})
+	return tokens
+}
@@ -0,0 +1,60 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+)
+
+func TestPubSub_Snapshot_Restore(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	sn2 := "limited subscriber/id"
+	lib.Subscribe(tn, sn)
+	lib.SubscribeWithOptions(tn, sn2, Options{MaxMessages: 5, Overflow: RejectPublish})
+	lib.Publish(tn, []byte("a"))
+	lib.Publish(tn, []byte("b"))
+	_ = lib.PublishWithTTL(tn, []byte("expired"), time.Nanosecond)
+	// message in flight is saved as pending
+	if msg, _, _ := lib.PollAck(tn, sn, time.Hour); string(msg) != "a" {
+		t.FailNow()
+	}
+	var buf bytes.Buffer
+	if err := lib.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := New()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, sn := range []string{sn, sn2} {
+		msgs, err := restored.PollN(tn, sn, 10)
+		if err != nil || len(msgs) != 2 || string(msgs[0]) != "a" || string(msgs[1]) != "b" {
+			t.FailNow()
+		}
+	}
+	_ = lib.PublishRetained("config", []byte("retained"))
+	buf.Reset()
+	_ = lib.Snapshot(&buf)
+	restored = New()
+	_ = restored.Restore(&buf)
+	restored.Subscribe("config", sn)
+	if msg, _ := restored.Poll("config", sn); string(msg) != "retained" {
+		t.FailNow()
+	}
+	subs, _ := restored.(*pubSub).topics.get(tn)
+	if subs.hm[sn2].opts.Overflow != RejectPublish {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_Restore_Version(t *testing.T) {
+	var buf bytes.Buffer
+	_ = gob.NewEncoder(&buf).Encode(snapshot{Version: snapshotVersion + 1})
+	if err := New().Restore(&buf); err != ErrSnapshotVersion {
+		t.FailNow()
+	}
+}
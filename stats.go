@@ -0,0 +1,101 @@
+package pubsub
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Counters of a single subscription
+// Delivered - messages returned by Poll methods (redeliveries of PollAck are counted again)
+// Dropped - messages removed because of overflow policy or expired time-to-live
+// Pending, InFlight - messages waiting for poll and polled with PollAck but not acknowledged yet
+// OldestAge - age of the oldest pending message, zero if there are no pending messages
+// Bytes - size of bodies of pending and in flight messages, messages shared with other subscriptions are counted
+// by each of them
+// Paused - subscription is paused (see Pause)
+type SubscriptionStats struct {
+	Name      string
+	Delivered uint64
+	Dropped   uint64
+	Pending   int
+	InFlight  int
+	OldestAge time.Duration
+	Bytes     int64
+	Paused    bool
+}
+
+// Counters of a topic and its subscriptions sorted by names
+// Published - messages published to the topic directly (not through wildcards)
+type TopicStats struct {
+	Name          string
+	Published     uint64
+	Subscriptions []SubscriptionStats
+}
+
+// Counters of all topics sorted by names
+type BrokerStats struct {
+	Topics []TopicStats
+}
+
+// Counters of all topics and subscriptions
+// Published, delivered and dropped counters are updated atomically, so collecting statistics doesn't slow down
+// publishing and polling. Pending messages are counted under lock of each topic one by one
+// Complexity: O(n) where n - number of pending messages of all subscriptions
+func (p *pubSub) Stats() BrokerStats {
+	topics := p.topics.all()
+	sort.Slice(topics, func(i, j int) bool {
+		return topics[i].tn < topics[j].tn
+	})
+	now := p.now()
+	stats := BrokerStats{Topics: make([]TopicStats, 0, len(topics))}
+	for _, subs := range topics {
+		stats.Topics = append(stats.Topics, subs.stats(now))
+	}
+	return stats
+}
+
+// Counters of the topic at the moment now
+func (s *subscriptions) stats(now time.Time) TopicStats {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ts := TopicStats{
+		Name:          s.tn,
+		Published:     atomic.LoadUint64(&s.published),
+		Subscriptions: make([]SubscriptionStats, 0, len(s.hm)),
+	}
+	for _, sub := range s.hm {
+		ts.Subscriptions = append(ts.Subscriptions, sub.stats(now))
+	}
+	sort.Slice(ts.Subscriptions, func(i, j int) bool {
+		return ts.Subscriptions[i].Name < ts.Subscriptions[j].Name
+	})
+	return ts
+}
+
+// Counters of the subscription at the moment now
+// Lock of subscription must be held by caller
+func (s *subscription) stats(now time.Time) SubscriptionStats {
+	ss := SubscriptionStats{
+		Name:      s.sn,
+		Delivered: atomic.LoadUint64(&s.delivered),
+		Dropped:   atomic.LoadUint64(&s.dropped),
+		Pending:   s.len(),
+		InFlight:  len(s.inFlight),
+		Paused:    s.paused,
+	}
+	var oldest time.Time
+	for _, it := range s.items() {
+		ss.Bytes += int64(len(it.Body))
+		if oldest.IsZero() || it.PublishedAt.Before(oldest) {
+			oldest = it.PublishedAt
+		}
+	}
+	for _, f := range s.inFlight {
+		ss.Bytes += int64(len(f.it.Body))
+	}
+	if !oldest.IsZero() {
+		ss.OldestAge = now.Sub(oldest)
+	}
+	return ss
+}
@@ -0,0 +1,47 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPubSub_Stats(t *testing.T) {
+	lib := New()
+	if stats := lib.Stats(); len(stats.Topics) != 0 {
+		t.FailNow()
+	}
+	tn := "some topic"
+	lib.SubscribeWithOptions(tn, "b", Options{MaxMessages: 1, Overflow: DropNewest})
+	lib.Subscribe(tn, "a")
+	lib.Subscribe("some/+", "c")
+	lib.Publish(tn, []byte("first"))
+	lib.Publish(tn, []byte("second"))
+	_ = lib.PublishWithTTL(tn, []byte("expired"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	lib.Poll(tn, "a")
+	if _, _, err := lib.PollAck(tn, "a", time.Minute); err != nil {
+		t.FailNow()
+	}
+	stats := lib.Stats()
+	if len(stats.Topics) != 2 || stats.Topics[0].Name != tn || stats.Topics[1].Name != "some/+" {
+		t.FailNow()
+	}
+	ts := stats.Topics[0]
+	if ts.Published != 3 || len(ts.Subscriptions) != 2 || stats.Topics[1].Published != 0 {
+		t.FailNow()
+	}
+	a, b := ts.Subscriptions[0], ts.Subscriptions[1]
+	if a.Name != "a" || a.Delivered != 2 || a.Dropped != 0 || a.Pending != 1 || a.InFlight != 1 {
+		t.FailNow()
+	}
+	if a.Bytes != int64(len("second")+len("expired")) || a.OldestAge <= 0 {
+		t.FailNow()
+	}
+	if b.Name != "b" || b.Delivered != 0 || b.Dropped != 2 || b.Pending != 1 || b.Bytes != int64(len("first")) {
+		t.FailNow()
+	}
+	lib.Poll(tn, "a")
+	if a := lib.Stats().Topics[0].Subscriptions[0]; a.Dropped != 1 || a.Pending != 0 || a.OldestAge != 0 {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,259 @@
+package pubsub
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Published message shared by all subscriptions of a topic
+// expires - time after which message is not delivered anymore, zero means never
+// priority - used only by subscriptions with Options.Priority
+// ownID - ID was supplied by producer (see TopicConfig.DedupWindow)
+// codec, compression - compressor of body and its name (see TopicConfig.Compression), nil if body isn't compressed
+// enc, keyID - encryption of body and ID of its key (see WithEncryption), nil if body isn't encrypted
+// sealed - message was validated and its body was prepared for storing (see pubSub.seal)
+// hops - how many times message was forwarded by Forward rules before
+// via - handle the message is published through, its cached topic replaces lookup by name (see Topic)
+// num, idPrefix - ID generated by broker, formatted only when it's needed (see id); num is 0 if Message.ID is set
+// refs - number of references (publisher, subscriptions), message returns to messagePool when the last one is
+// released (see release); holders which never release message pin it. Messages not made by newMessage must be pinned
+type message struct {
+	Message
+	num         uint64
+	idPrefix    string
+	refs        int32
+	expires     time.Time
+	priority    int
+	ownID       bool
+	codec       Compressor
+	compression string
+	enc         *encryption
+	keyID       string
+	sealed      bool
+	hops        int
+	via         *Topic
+}
+
+// Checks if message time-to-live is over at the moment now
+func (m *message) expired(now time.Time) bool {
+	return !m.expires.IsZero() && !now.Before(m.expires)
+}
+
+// Message pending in a particular subscription
+// attempts - how many times message was delivered by PollAck to this subscription
+type item struct {
+	*message
+	attempts int
+}
+
+// Storage of pending messages of a subscription, implementations define delivery order
+// All methods are called with subscription lock held
+type storage interface {
+	// Add new message (m)
+	add(m *message)
+	// Put item (it) back, so it will be taken first
+	pushFront(it item)
+	// Put item (it) back, so it will be taken last
+	pushBack(it item)
+	// Replace pending message (old) with (m) keeping its place, false should be returned if old isn't pending
+	swap(old, m *message) bool
+	// Take the next item and remove it, false should be returned if storage is empty
+	take() (item, bool)
+	// Remove the least valuable item to free space for a new one (DropOldest policy)
+	evict()
+	// Item which evict removes, false should be returned if storage is empty
+	evictable() (item, bool)
+	// Number of items
+	len() int
+	// Total size of message bodies
+	bytes() int64
+	// Forget size of all items, called when storage is thrown away (see usage)
+	release()
+	// Remove all messages which time-to-live is over at the moment now, returns number of removed messages
+	removeExpired(now time.Time) int
+	// Copy of all items in delivery order
+	items() []item
+	// Copy of up to max first items in delivery order
+	peek(max int) []item
+}
+
+// Total size of message bodies of a storage
+// meters - counters of several storages (see WithMaxMemory and NamespaceQuota) changed too, accessed atomically
+type usage struct {
+	size   int64
+	meters []*int64
+}
+
+// Changing size by delta (n)
+func (u *usage) grow(n int64) {
+	u.size += n
+	for _, m := range u.meters {
+		atomic.AddInt64(m, n)
+	}
+}
+
+// Total size of message bodies
+func (u *usage) bytes() int64 {
+	return u.size
+}
+
+// Forgetting size of storage which isn't used anymore
+func (u *usage) release() {
+	u.grow(-u.size)
+}
+
+// Storage for messages in FIFO order: growable ring buffer, so taken items are released immediately
+// and the buffer is reused instead of growing while the subscription is polled
+// head - index of the first item, n - number of items
+type ringStorage struct {
+	usage
+	buf  []item
+	head int
+	n    int
+}
+
+// Minimal capacity of ring buffer, buffers larger than shrinkRingSize shrink when they're used by a quarter only
+// (smaller ones keep their capacity, so polling in batches doesn't reallocate them)
+const (
+	minRingSize    = 8
+	shrinkRingSize = 1024
+)
+
+// Add message (m) to the end of ring
+func (s *ringStorage) add(m *message) {
+	s.pushBack(item{message: m})
+}
+
+// Put item (it) back to the beginning of ring, so it will be taken first
+func (s *ringStorage) pushFront(it item) {
+	s.reserve()
+	s.head = (s.head - 1 + len(s.buf)) % len(s.buf)
+	s.buf[s.head] = it
+	s.n++
+	s.grow(int64(len(it.Body)))
+}
+
+// Put item (it) to the end of ring
+func (s *ringStorage) pushBack(it item) {
+	s.reserve()
+	s.buf[(s.head+s.n)%len(s.buf)] = it
+	s.n++
+	s.grow(int64(len(it.Body)))
+}
+
+// Replace pending message (old) with (m) keeping its place
+// Complexity: O(n)
+func (s *ringStorage) swap(old, m *message) bool {
+	for i := 0; i < s.n; i++ {
+		j := (s.head + i) % len(s.buf)
+		if s.buf[j].message == old {
+			s.grow(int64(len(m.Body) - len(old.Body)))
+			s.buf[j] = item{message: m}
+			return true
+		}
+	}
+	return false
+}
+
+// Doubling capacity of full ring, items are moved to the beginning of new buffer
+func (s *ringStorage) reserve() {
+	if s.n < len(s.buf) {
+		return
+	}
+	n := 2 * len(s.buf)
+	if n < minRingSize {
+		n = minRingSize
+	}
+	s.resize(n)
+}
+
+// Moving items to new buffer of capacity (size) which must fit all of them
+func (s *ringStorage) resize(size int) {
+	buf := make([]item, size)
+	s.copyTo(buf)
+	s.buf = buf
+	s.head = 0
+}
+
+// Copying up to len(dst) first items in order to (dst), returns number of copied items
+func (s *ringStorage) copyTo(dst []item) int {
+	if s.head+s.n <= len(s.buf) {
+		return copy(dst, s.buf[s.head:s.head+s.n])
+	}
+	n := copy(dst, s.buf[s.head:])
+	return n + copy(dst[n:], s.buf[:s.n-(len(s.buf)-s.head)])
+}
+
+// Number of items in ring
+func (s *ringStorage) len() int {
+	return s.n
+}
+
+// Copy of all items in order
+func (s *ringStorage) items() []item {
+	return s.peek(s.n)
+}
+
+// Copy of up to max items from the beginning of ring
+func (s *ringStorage) peek(max int) []item {
+	if max > s.n {
+		max = s.n
+	}
+	if max <= 0 {
+		return nil
+	}
+	items := make([]item, max)
+	s.copyTo(items)
+	return items
+}
+
+// Take the oldest item and remove it from ring, the slot is cleared so the message may be collected
+// false should be returned if ring is empty
+func (s *ringStorage) take() (item, bool) {
+	if s.n == 0 {
+		return item{}, false
+	}
+	it := s.buf[s.head]
+	s.buf[s.head] = item{}
+	s.head = (s.head + 1) % len(s.buf)
+	s.n--
+	s.grow(-int64(len(it.Body)))
+	if len(s.buf) > shrinkRingSize && s.n <= len(s.buf)/4 {
+		s.resize(len(s.buf) / 2)
+	}
+	return it, true
+}
+
+// Remove the oldest item
+func (s *ringStorage) evict() {
+	s.take()
+}
+
+// The oldest item
+func (s *ringStorage) evictable() (item, bool) {
+	if s.n == 0 {
+		return item{}, false
+	}
+	return s.buf[s.head], true
+}
+
+// Remove all messages which time-to-live is over at the moment now
+// returns number of removed messages
+func (s *ringStorage) removeExpired(now time.Time) int {
+	removed := 0
+	for i := 0; i < s.n; i++ {
+		it := s.buf[(s.head+i)%len(s.buf)]
+		if it.expired(now) {
+			s.grow(-int64(len(it.Body)))
+			removed++
+			continue
+		}
+		s.buf[(s.head+i-removed)%len(s.buf)] = it
+	}
+	// release the tail, otherwise expired messages stay reachable
+	for i := s.n - removed; i < s.n; i++ {
+		s.buf[(s.head+i)%len(s.buf)] = item{}
+	}
+	s.n -= removed
+	return removed
+}
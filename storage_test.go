@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRingStorage(t *testing.T) {
+	s := &ringStorage{}
+	var expected []string
+	// wrap around, grow and shrink several times
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 3*minRingSize; i++ {
+			b := strconv.Itoa(round*100 + i)
+			s.add(&message{Message: Message{Body: []byte(b)}})
+			expected = append(expected, b)
+		}
+		for i := 0; i < 2*minRingSize+round; i++ {
+			it, ok := s.take()
+			if !ok || string(it.Body) != expected[0] {
+				t.Fatal(round, i, string(it.Body))
+			}
+			expected = expected[1:]
+		}
+	}
+	for _, b := range expected {
+		if it, _ := s.take(); string(it.Body) != b {
+			t.FailNow()
+		}
+	}
+	if _, ok := s.take(); ok || s.bytes() != 0 {
+		t.FailNow()
+	}
+	s.pushBack(item{message: &message{Message: Message{Body: []byte("b")}}})
+	s.pushFront(item{message: &message{Message: Message{Body: []byte("a")}}})
+	items := s.items()
+	if len(items) != 2 || string(items[0].Body) != "a" || string(items[1].Body) != "b" || s.bytes() != 2 {
+		t.FailNow()
+	}
+	if peeked := s.peek(1); len(peeked) != 1 || string(peeked[0].Body) != "a" {
+		t.FailNow()
+	}
+}
+
+func TestRingStorage_take(t *testing.T) {
+	s := &ringStorage{}
+	for i := 0; i < 4*shrinkRingSize; i++ {
+		s.add(&message{Message: Message{Body: []byte("message")}})
+	}
+	for i := 0; i < 4*shrinkRingSize-1; i++ {
+		s.take()
+	}
+	// consumed messages are released and the buffer shrinks
+	if len(s.buf) > shrinkRingSize || s.len() != 1 || s.bytes() != 7 {
+		t.Fatal(len(s.buf))
+	}
+	for _, it := range s.buf {
+		if it.message != nil && it.message != s.buf[s.head].message {
+			t.FailNow()
+		}
+	}
+}
+
+func TestRingStorage_removeExpired(t *testing.T) {
+	s := &ringStorage{}
+	now := time.Now()
+	// move head, so items wrap around the end of the buffer
+	for i := 0; i < 6; i++ {
+		s.add(&message{})
+	}
+	for i := 0; i < 6; i++ {
+		s.take()
+	}
+	for i, ttl := range []time.Duration{time.Hour, time.Minute, time.Hour, time.Minute, time.Hour} {
+		s.add(&message{Message: Message{ID: strconv.Itoa(i), Body: []byte("b")}, expires: now.Add(ttl)})
+	}
+	if s.removeExpired(now.Add(2*time.Minute)) != 2 || s.len() != 3 || s.bytes() != 3 {
+		t.FailNow()
+	}
+	items := s.items()
+	if items[0].ID != "0" || items[1].ID != "2" || items[2].ID != "4" {
+		t.Fatal(items)
+	}
+	for i, it := range s.buf {
+		if it.message != nil && (i-s.head+len(s.buf))%len(s.buf) >= s.len() {
+			t.FailNow()
+		}
+	}
+}
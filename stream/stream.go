@@ -0,0 +1,216 @@
+/*
+Stream processing operators for pubsub package. Every operator reads a topic with its own subscription and
+publishes results to a new topic, so operators are chained into in-process pipelines:
+
+	orders := stream.From(ctx, ps, "orders")
+	big := orders.Filter("orders/big", isBig).Map("orders/big/json", toJSON)
+	stream.Merge("audit", big, stream.From(ctx, ps, "refunds")).Batch("audit/batches", 100, time.Second)
+
+Operators run until ctx is done. Subscription of operator is named "stream:" followed by its destination topic,
+it's created when operator is added and stays after ctx is done, so restarted pipeline continues where it stopped.
+Messages are taken from subscriptions with PollMsgWait, so a message being processed when the process stops is lost.
+Key and headers of messages are kept by Map, Filter, Debounce, Throttle and Merge
+*/
+package stream
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Error happens if Unbatch gets body which isn't a batch
+var ErrMalformedBatch = errors.New("malformed batch")
+
+// Prefix of subscription names of operators
+const subscriptionPrefix = "stream:"
+
+// Stream is a topic of broker which operators read
+type Stream struct {
+	ctx context.Context
+	ps  pubsub.PubSuber
+	tn  string
+}
+
+// Constructor. Creates stream of topic name (tn) of broker (ps), operators of the stream run until ctx is done
+func From(ctx context.Context, ps pubsub.PubSuber, tn string) *Stream {
+	return &Stream{ctx: ctx, ps: ps, tn: tn}
+}
+
+// Topic name of the stream
+func (s *Stream) Topic() string {
+	return s.tn
+}
+
+// Reader of operator: subscription (sn) of source topic (tn) and destination topic (dst)
+type reader struct {
+	ctx    context.Context
+	ps     pubsub.PubSuber
+	tn, sn string
+	dst    string
+}
+
+// Subscribing operator publishing to topic name (dst) and running (loop) in a goroutine, returns stream of dst
+func (s *Stream) start(dst string, loop func(r *reader)) *Stream {
+	r := &reader{ctx: s.ctx, ps: s.ps, tn: s.tn, sn: subscriptionPrefix + dst, dst: dst}
+	s.ps.Subscribe(r.tn, r.sn)
+	go loop(r)
+	return &Stream{ctx: s.ctx, ps: s.ps, tn: dst}
+}
+
+// Waiting for the next message, nil is returned if ctx is done or subscription is gone
+func (r *reader) next() *pubsub.Message {
+	msg, err := r.ps.PollMsgWait(r.ctx, r.tn, r.sn)
+	if err != nil {
+		return nil
+	}
+	return msg
+}
+
+// Waiting for the next message until (deadline), nil message is returned if there was no message by then
+// false is returned if ctx is done or subscription is gone
+func (r *reader) nextUntil(deadline time.Time) (*pubsub.Message, bool) {
+	ctx, cancel := context.WithDeadline(r.ctx, deadline)
+	defer cancel()
+	msg, err := r.ps.PollMsgWait(ctx, r.tn, r.sn)
+	if err != nil {
+		return nil, err == context.DeadlineExceeded && r.ctx.Err() == nil
+	}
+	return msg, true
+}
+
+// Publishing message (msg) to destination topic as a new message
+func (r *reader) publish(msg pubsub.Message) {
+	_, _ = r.ps.PublishMsg(r.dst, pubsub.Message{Key: msg.Key, Headers: msg.Headers, Body: msg.Body})
+}
+
+// Publishing bodies of messages transformed by (fn) to topic name (dst)
+func (s *Stream) Map(dst string, fn func([]byte) []byte) *Stream {
+	return s.start(dst, func(r *reader) {
+		for msg := r.next(); msg != nil; msg = r.next() {
+			msg.Body = fn(msg.Body)
+			r.publish(*msg)
+		}
+	})
+}
+
+// Publishing messages which bodies are accepted by (fn) to topic name (dst)
+func (s *Stream) Filter(dst string, fn func([]byte) bool) *Stream {
+	return s.start(dst, func(r *reader) {
+		for msg := r.next(); msg != nil; msg = r.next() {
+			if fn(msg.Body) {
+				r.publish(*msg)
+			}
+		}
+	})
+}
+
+// Publishing up to (n) messages at once to topic name (dst) as one message, see Unbatch
+// Batch is published when it has n messages or duration (d) passed since its first message was taken
+func (s *Stream) Batch(dst string, n int, d time.Duration) *Stream {
+	return s.start(dst, func(r *reader) {
+		for msg := r.next(); msg != nil; msg = r.next() {
+			bodies := [][]byte{msg.Body}
+			deadline := time.Now().Add(d)
+			running := true
+			for running && len(bodies) < n {
+				var next *pubsub.Message
+				if next, running = r.nextUntil(deadline); next == nil {
+					break
+				}
+				bodies = append(bodies, next.Body)
+			}
+			// taken messages are published even if ctx is done
+			r.publish(pubsub.Message{Body: encodeBatch(bodies)})
+			if !running {
+				return
+			}
+		}
+	})
+}
+
+// Publishing message to topic name (dst) only if no newer message was taken during duration (d) after it
+// The last message is published when ctx is done
+func (s *Stream) Debounce(dst string, d time.Duration) *Stream {
+	return s.start(dst, func(r *reader) {
+		pending := r.next()
+		for pending != nil {
+			next, running := r.nextUntil(time.Now().Add(d))
+			switch {
+			case !running:
+				r.publish(*pending)
+				return
+			case next == nil:
+				r.publish(*pending)
+				pending = r.next()
+			default:
+				pending = next
+			}
+		}
+	})
+}
+
+// Publishing at most one message per duration (d) to topic name (dst), messages taken in between are dropped
+func (s *Stream) Throttle(dst string, d time.Duration) *Stream {
+	return s.start(dst, func(r *reader) {
+		var last time.Time
+		for msg := r.next(); msg != nil; msg = r.next() {
+			if now := time.Now(); last.IsZero() || now.Sub(last) >= d {
+				last = now
+				r.publish(*msg)
+			}
+		}
+	})
+}
+
+// Publishing messages of all streams (streams) to topic name (dst), streams must be of the same broker
+// Context of the first stream is used for the result
+func Merge(dst string, streams ...*Stream) *Stream {
+	for _, s := range streams {
+		s.start(dst, func(r *reader) {
+			for msg := r.next(); msg != nil; msg = r.next() {
+				r.publish(*msg)
+			}
+		})
+	}
+	return &Stream{ctx: streams[0].ctx, ps: streams[0].ps, tn: dst}
+}
+
+// Body of Batch message: number of bodies and then every body prefixed by its length, as uvarints
+func encodeBatch(bodies [][]byte) []byte {
+	size := binary.MaxVarintLen64 * (len(bodies) + 1)
+	for _, b := range bodies {
+		size += len(b)
+	}
+	buf := binary.AppendUvarint(make([]byte, 0, size), uint64(len(bodies)))
+	for _, b := range bodies {
+		buf = binary.AppendUvarint(buf, uint64(len(b)))
+		buf = append(buf, b...)
+	}
+	return buf
+}
+
+// Bodies of messages of batch (b) published by Batch
+func Unbatch(b []byte) ([][]byte, error) {
+	n, l := binary.Uvarint(b)
+	if l <= 0 || n > uint64(len(b)) {
+		return nil, ErrMalformedBatch
+	}
+	b = b[l:]
+	bodies := make([][]byte, 0, n)
+	for i := uint64(0); i < n; i++ {
+		size, l := binary.Uvarint(b)
+		if l <= 0 || size > uint64(len(b)-l) {
+			return nil, ErrMalformedBatch
+		}
+		bodies = append(bodies, b[l:l+int(size)])
+		b = b[l+int(size):]
+	}
+	if len(b) != 0 {
+		return nil, ErrMalformedBatch
+	}
+	return bodies, nil
+}
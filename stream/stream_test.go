@@ -0,0 +1,120 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Waiting for message of topic name (tn) polled by subscription "test"
+func poll(t *testing.T, ps pubsub.PubSuber, tn string) *pubsub.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := ps.PollMsgWait(ctx, tn, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestStream_MapFilter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lib := pubsub.New()
+	s := From(ctx, lib, "numbers").
+		Filter("numbers/even", func(b []byte) bool { n, _ := strconv.Atoi(string(b)); return n%2 == 0 }).
+		Map("numbers/even/x10", func(b []byte) []byte { return append(b, '0') })
+	if s.Topic() != "numbers/even/x10" {
+		t.Fatal(s.Topic())
+	}
+	lib.Subscribe(s.Topic(), "test")
+	for i := 1; i <= 4; i++ {
+		_, _ = lib.PublishMsg("numbers", pubsub.Message{Key: "k", Body: []byte(strconv.Itoa(i))})
+	}
+	for _, want := range []string{"20", "40"} {
+		if msg := poll(t, lib, s.Topic()); string(msg.Body) != want || msg.Key != "k" {
+			t.Fatal(msg)
+		}
+	}
+}
+
+func TestStream_Batch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lib := pubsub.New()
+	s := From(ctx, lib, "events").Batch("events/batches", 2, 20*time.Millisecond)
+	lib.Subscribe(s.Topic(), "test")
+	for _, b := range []string{"1", "2", "3"} {
+		lib.Publish("events", []byte(b))
+	}
+	for _, want := range [][]string{{"1", "2"}, {"3"}} {
+		bodies, err := Unbatch(poll(t, lib, s.Topic()).Body)
+		if err != nil || len(bodies) != len(want) {
+			t.Fatal(bodies, err)
+		}
+		for i := range want {
+			if string(bodies[i]) != want[i] {
+				t.Fatal(bodies)
+			}
+		}
+	}
+	if _, err := Unbatch([]byte{5, 1}); err != ErrMalformedBatch {
+		t.Fatal(err)
+	}
+	if bodies, err := Unbatch(encodeBatch(nil)); err != nil || len(bodies) != 0 {
+		t.Fatal(bodies, err)
+	}
+}
+
+func TestStream_DebounceThrottle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lib := pubsub.New()
+	src := From(ctx, lib, "clicks")
+	debounced := src.Debounce("clicks/debounced", 20*time.Millisecond)
+	throttled := src.Throttle("clicks/throttled", time.Hour)
+	lib.Subscribe(debounced.Topic(), "test")
+	lib.Subscribe(throttled.Topic(), "test")
+	for _, b := range []string{"1", "2", "3"} {
+		lib.Publish("clicks", []byte(b))
+	}
+	if msg := poll(t, lib, debounced.Topic()); string(msg.Body) != "3" {
+		t.Fatal(string(msg.Body))
+	}
+	if msg := poll(t, lib, throttled.Topic()); string(msg.Body) != "1" {
+		t.Fatal(string(msg.Body))
+	}
+	time.Sleep(10 * time.Millisecond)
+	if depth, _ := lib.Depth(throttled.Topic(), "test"); depth != 0 {
+		t.Fatal(depth)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lib := pubsub.New()
+	s := Merge("all", From(ctx, lib, "a"), From(ctx, lib, "b"))
+	lib.Subscribe(s.Topic(), "test")
+	lib.Publish("a", []byte("a"))
+	lib.Publish("b", []byte("b"))
+	var got [][]byte
+	for i := 0; i < 2; i++ {
+		got = append(got, poll(t, lib, s.Topic()).Body)
+	}
+	if !bytes.Equal(got[0], []byte("a")) && !bytes.Equal(got[1], []byte("a")) {
+		t.Fatal(got)
+	}
+	// operators stop, subscriptions stay
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	lib.Publish("a", []byte("c"))
+	if depth, _ := lib.Depth("a", "stream:all"); depth != 1 {
+		t.Fatal(depth)
+	}
+}
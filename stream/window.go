@@ -0,0 +1,97 @@
+package stream
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Headers of messages published by Aggregate, bounds of the window in RFC 3339 format with nanoseconds
+const (
+	HeaderWindowStart = "window-start"
+	HeaderWindowEnd   = "window-end"
+)
+
+// Window of Aggregate: windows of duration Size start every Step, both must be positive
+// Windows are aligned to multiples of Step since zero time, e.g. one minute windows start at the beginning of minutes
+type Window struct {
+	Size, Step time.Duration
+}
+
+// Tumbling windows of duration (size) following each other, every message belongs to one window
+func Tumbling(size time.Duration) Window {
+	return Window{Size: size, Step: size}
+}
+
+// Sliding windows of duration (size) starting every (step), a message belongs to size/step windows
+func Sliding(size, step time.Duration) Window {
+	return Window{Size: size, Step: step}
+}
+
+// Aggregate folds bodies of messages of a window into body of result
+type Aggregate func(bodies [][]byte) []byte
+
+// Aggregate counting messages
+func Count(bodies [][]byte) []byte {
+	return strconv.AppendInt(nil, int64(len(bodies)), 10)
+}
+
+// Aggregate summing values of messages returned by (value)
+func Sum(value func([]byte) float64) Aggregate {
+	return func(bodies [][]byte) []byte {
+		sum := 0.0
+		for _, b := range bodies {
+			sum += value(b)
+		}
+		return strconv.AppendFloat(nil, sum, 'g', -1, 64)
+	}
+}
+
+// Message taken at time (at)
+type windowed struct {
+	at   time.Time
+	body []byte
+}
+
+// Publishing results of (fn) over messages of windows (w) to topic name (dst) when windows end
+// Messages belong to windows by the time operator takes them. Windows without messages aren't published
+func (s *Stream) Aggregate(dst string, w Window, fn Aggregate) *Stream {
+	return s.start(dst, func(r *reader) {
+		var taken []windowed
+		end := time.Now().Truncate(w.Step).Add(w.Step)
+		for {
+			msg, running := r.nextUntil(end)
+			if msg != nil {
+				taken = append(taken, windowed{at: time.Now(), body: msg.Body})
+			} else if !running {
+				return
+			}
+			// windows are checked after every message too, so busy topics don't delay them
+			for now := time.Now(); !now.Before(end); end = end.Add(w.Step) {
+				r.publishWindow(taken, end.Add(-w.Size), end, fn)
+				// messages which don't belong to the next windows aren't needed anymore
+				keep := end.Add(w.Step - w.Size)
+				for len(taken) > 0 && taken[0].at.Before(keep) {
+					taken = taken[1:]
+				}
+			}
+		}
+	})
+}
+
+// Publishing result of (fn) over messages (taken) of window [start, end) if there are such messages
+func (r *reader) publishWindow(taken []windowed, start, end time.Time, fn Aggregate) {
+	var bodies [][]byte
+	for _, t := range taken {
+		if !t.at.Before(start) && t.at.Before(end) {
+			bodies = append(bodies, t.body)
+		}
+	}
+	if len(bodies) > 0 {
+		r.publish(pubsub.Message{Headers: map[string]string{
+			HeaderWindowStart: start.Format(time.RFC3339Nano),
+			HeaderWindowEnd:   end.Format(time.RFC3339Nano),
+		}, Body: fn(bodies)})
+	}
+}
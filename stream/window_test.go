@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Summing results of windows published to topic name (tn) until they reach (total)
+func collect(t *testing.T, ps pubsub.PubSuber, tn string, size time.Duration, total int) {
+	t.Helper()
+	sum := 0
+	for sum < total {
+		msg := poll(t, ps, tn)
+		start, _ := time.Parse(time.RFC3339Nano, msg.Headers[HeaderWindowStart])
+		end, _ := time.Parse(time.RFC3339Nano, msg.Headers[HeaderWindowEnd])
+		if end.Sub(start) != size {
+			t.Fatal(msg.Headers)
+		}
+		n, err := strconv.Atoi(string(msg.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += n
+	}
+	if sum != total {
+		t.Fatal(sum)
+	}
+}
+
+func TestStream_Aggregate_Tumbling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lib := pubsub.New()
+	counts := From(ctx, lib, "clicks").Aggregate("clicks/count", Tumbling(20*time.Millisecond), Count)
+	sums := From(ctx, lib, "clicks").Aggregate("clicks/sum", Tumbling(20*time.Millisecond), Sum(func(b []byte) float64 {
+		f, _ := strconv.ParseFloat(string(b), 64)
+		return f
+	}))
+	lib.Subscribe(counts.Topic(), "test")
+	lib.Subscribe(sums.Topic(), "test")
+	for _, b := range []string{"1", "2", "3"} {
+		lib.Publish("clicks", []byte(b))
+	}
+	collect(t, lib, counts.Topic(), 20*time.Millisecond, 3)
+	collect(t, lib, sums.Topic(), 20*time.Millisecond, 6)
+}
+
+func TestStream_Aggregate_Sliding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lib := pubsub.New()
+	counts := From(ctx, lib, "clicks").Aggregate("clicks/count", Sliding(40*time.Millisecond, 20*time.Millisecond), Count)
+	lib.Subscribe(counts.Topic(), "test")
+	lib.Publish("clicks", []byte("1"))
+	lib.Publish("clicks", []byte("2"))
+	// every message is counted by two windows
+	collect(t, lib, counts.Topic(), 40*time.Millisecond, 4)
+}
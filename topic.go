@@ -0,0 +1,240 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// Error happens if topic was created with CreateTopic before
+	ErrTopicExists = errors.New("topic exists already")
+	// Error happens if topic name is empty or is a wildcard pattern where a concrete topic is required
+	ErrInvalidTopic = errors.New("invalid topic name")
+)
+
+// Settings of topic created with CreateTopic or changed with ConfigureTopic
+// Retention - messages published to the topic expire after this time (unless PublishWithTTL sets shorter one),
+// zero means never
+// MaxMessages - limit of pending messages of subscriptions of the topic which have no own Options.MaxMessages,
+// Options.Overflow of subscription is applied when it's reached, zero means unlimited
+// MaxBytes - limit of total body size of pending messages of every subscription of the topic, Options.Overflow
+// of subscription is applied when it's reached, zero means unlimited
+// Priority - subscriptions of the topic deliver messages with higher priority first like with Options.Priority
+// RetainLast - every message published to the topic becomes its retained message (see PublishRetained)
+// PublishRate - limit of messages published to the topic per second (token bucket), exceeding messages are rejected
+// with ErrRateLimited, zero means unlimited
+// PublishBurst - number of messages allowed at once before PublishRate applies, at least 1
+// Partitions - number of partitions: every message is also delivered to one partition chosen by hash of its key
+// (see PublishWithKey), messages without key are spread round-robin. Subscribe to Partition(tn, i) to consume
+// partition i, so every partition is processed in order while partitions are processed in parallel. Settings of
+// the topic apply to its messages, limits of partition subscriptions are set by their Options. Zero disables
+// partitioning
+// Compact - the topic keeps the latest message of every key (see PublishWithKey) and delivers them to new
+// subscriptions of the topic in order of publish, like log compaction of Kafka. Message with empty body removes
+// its key (it's still delivered to current subscriptions), messages without key aren't kept
+// Compression - name of compressor (see RegisterCompressor, "gzip" is built in) of bodies of messages published
+// to the topic, so large messages take less memory while pending. Bodies are decompressed when they are polled,
+// memory limits and Stats count compressed size. Empty means no compression
+// CompressThreshold - messages smaller than this size in bytes aren't compressed, DefaultCompressThreshold if zero
+// DedupWindow - messages with producer-supplied ID (see PublishMsg) are dropped if a message with the same ID was
+// published to the topic within this time, zero disables deduplication
+type TopicConfig struct {
+	Retention         time.Duration
+	MaxMessages       int
+	MaxBytes          int64
+	Priority          bool
+	RetainLast        bool
+	PublishRate       float64
+	PublishBurst      int
+	DedupWindow       time.Duration
+	Partitions        int
+	Compact           bool
+	Compression       string
+	CompressThreshold int
+}
+
+// Settings of topic name (tn), zero settings if the topic exists but wasn't created with CreateTopic or configured
+// with ConfigureTopic. ErrTopicNotFound raises if there is no such topic
+func (p *pubSub) TopicConfig(tn string) (TopicConfig, error) {
+	if p.closed.Load() {
+		return TopicConfig{}, ErrClosed
+	}
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		return TopicConfig{}, ErrTopicNotFound
+	}
+	cfg := subs.config.Load()
+	if cfg == nil {
+		return TopicConfig{}, nil
+	}
+	return *cfg, nil
+}
+
+// Requiring topics to be created with CreateTopic before use
+// Publishing to unknown topic returns ErrTopicNotFound, subscribing to it is ignored (methods returning error return
+// ErrTopicNotFound). Wildcard subscriptions and dead-letter topics don't need to be created
+func WithStrictTopics(enabled bool) Option {
+	return func(p *pubSub) {
+		p.strict = enabled
+	}
+}
+
+// Creating topic name (tn) with settings (cfg), the topic isn't removed when its last subscription is removed
+// Topic existing implicitly (created by Subscribe) gets the settings like with ConfigureTopic
+// ErrTopicExists raises if topic was created with CreateTopic before, ErrInvalidTopic - if tn is empty or a pattern,
+// ErrUnknownCompressor - if TopicConfig.Compression isn't registered
+func (p *pubSub) CreateTopic(tn string, cfg TopicConfig) error {
+	if tn == "" || isPattern(tn) {
+		return ErrInvalidTopic
+	}
+	if cfg.Compression != "" && compressorOf(cfg.Compression) == nil {
+		return ErrUnknownCompressor
+	}
+	if p.closed.Load() {
+		return ErrClosed
+	}
+	cfg.normalize()
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		if err := p.admitTopic(tn); err != nil {
+			return err
+		}
+		subs = p.newTopic(tn)
+	}
+	subs.mux.Lock()
+	defer subs.mux.Unlock()
+	if subs.config.Load() != nil {
+		return ErrTopicExists
+	}
+	p.configure(subs, cfg)
+	subs.log.Info("topic created")
+	return nil
+}
+
+// Changing settings of topic name (tn) to cfg, they are applied to all subscriptions of the topic immediately:
+// storage of subscriptions is rebuilt if ordering changes, DropOldest subscriptions exceeding new limits lose
+// their oldest messages. Retention is applied to messages published later
+// Creates new topic if not exist before (ErrTopicNotFound raises in strict mode instead),
+// ErrInvalidTopic raises if tn is empty or a pattern,
+// ErrUnknownCompressor - if TopicConfig.Compression isn't registered
+func (p *pubSub) ConfigureTopic(tn string, cfg TopicConfig) error {
+	if tn == "" || isPattern(tn) {
+		return ErrInvalidTopic
+	}
+	if cfg.Compression != "" && compressorOf(cfg.Compression) == nil {
+		return ErrUnknownCompressor
+	}
+	if p.closed.Load() {
+		return ErrClosed
+	}
+	cfg.normalize()
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	subs, ok := p.topics.get(tn)
+	if !ok {
+		if p.strict {
+			return ErrTopicNotFound
+		}
+		if err := p.admitTopic(tn); err != nil {
+			return err
+		}
+		subs = p.newTopic(tn)
+	}
+	subs.mux.Lock()
+	defer subs.mux.Unlock()
+	p.configure(subs, cfg)
+	subs.log.Info("topic configured")
+	return nil
+}
+
+// Replacing negative limits with zero (unlimited)
+func (cfg *TopicConfig) normalize() {
+	if cfg.Retention < 0 {
+		cfg.Retention = 0
+	}
+	if cfg.MaxMessages < 0 {
+		cfg.MaxMessages = 0
+	}
+	if cfg.MaxBytes < 0 {
+		cfg.MaxBytes = 0
+	}
+	if cfg.Partitions < 0 {
+		cfg.Partitions = 0
+	}
+}
+
+// Setting settings (cfg) of topic (subs) and applying them to its subscriptions
+// p.mux and lock of the topic must be held by caller
+func (p *pubSub) configure(subs *subscriptions, cfg TopicConfig) {
+	subs.config.Store(&cfg)
+	subs.publishLimit = newBucket(cfg.PublishRate, cfg.PublishBurst)
+	if cfg.DedupWindow <= 0 {
+		subs.seen, subs.seenOrder = nil, nil
+	}
+	if !cfg.Compact {
+		subs.latest = nil
+	}
+	if cfg.Compression != "" {
+		p.compression.Store(true)
+	}
+	for _, sub := range subs.hm {
+		sub.syncStorage()
+		sub.trim()
+	}
+	if cfg.Retention > 0 {
+		p.startSweeper()
+	}
+}
+
+// Applying topic settings (cfg) to message (m) published to the topic
+// Lock of the topic must be held by caller
+func (p *pubSub) applyConfig(cfg *TopicConfig, m *message) {
+	if cfg.Retention > 0 {
+		if expires := m.PublishedAt.Add(cfg.Retention); m.expires.IsZero() || expires.Before(m.expires) {
+			m.expires = expires
+		}
+	}
+	if cfg.RetainLast {
+		p.retainMux.Lock()
+		if p.retained == nil {
+			p.retained = map[string]*message{}
+		}
+		m.pin()
+		p.retained[m.Topic] = m
+		p.retainMux.Unlock()
+	}
+}
+
+// Checks if topic name (tn) may be used for subscribing, in strict mode topic must be created with CreateTopic
+// (or be its partition)
+func (p *pubSub) subscribable(tn string) bool {
+	if !p.strict || isPattern(tn) {
+		return true
+	}
+	subs, ok := p.topics.get(tn)
+	return ok && subs.config.Load() != nil || p.isPartition(tn)
+}
+
+// Returns locked subscriptions list of topic name (tn) for subscribing like lockTopic, but in strict mode
+// ErrTopicNotFound raises if topic wasn't created with CreateTopic
+func (p *pubSub) lockSubscribable(tn string) (*subscriptions, error) {
+	if !p.strict || isPattern(tn) {
+		return p.lockTopic(tn)
+	}
+	if p.isPartition(tn) {
+		return p.lockTopic(tn)
+	}
+	for {
+		subs, ok := p.topics.get(tn)
+		if !ok || subs.config.Load() == nil {
+			return nil, ErrTopicNotFound
+		}
+		subs.mux.Lock()
+		if !subs.deleted.Load() {
+			return subs, nil
+		}
+		subs.mux.Unlock()
+	}
+}
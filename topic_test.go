@@ -0,0 +1,187 @@
+package pubsub
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPubSub_CreateTopic(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if lib.CreateTopic("", TopicConfig{}) != ErrInvalidTopic || lib.CreateTopic("a/+", TopicConfig{}) != ErrInvalidTopic {
+		t.FailNow()
+	}
+	if err := lib.CreateTopic(tn, TopicConfig{MaxMessages: 2, RetainLast: true}); err != nil {
+		t.FailNow()
+	}
+	if err := lib.CreateTopic(tn, TopicConfig{}); err != ErrTopicExists {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	lib.SubscribeWithOptions(tn, "own limit", Options{MaxMessages: 3})
+	for _, m := range []string{"a", "b", "c", "d"} {
+		lib.Publish(tn, []byte(m))
+	}
+	if msgs, _ := lib.PeekN(tn, sn, 10); len(msgs) != 2 || string(msgs[0]) != "c" {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth(tn, "own limit"); depth != 3 {
+		t.FailNow()
+	}
+	// the last message is retained
+	lib.Subscribe(tn, "new")
+	if b, _ := lib.Poll(tn, "new"); string(b) != "d" {
+		t.FailNow()
+	}
+	// created topic isn't removed with its last subscription
+	for _, sn := range []string{sn, "own limit", "new"} {
+		lib.Unsubscribe(tn, sn)
+	}
+	if topics := lib.Topics(); len(topics) != 1 || topics[0] != tn {
+		t.FailNow()
+	}
+	lib.DeleteTopic(tn)
+	if len(lib.Topics()) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestTopicConfig_Retention(t *testing.T) {
+	lib := New(WithClock(&stepClock{now: time.Unix(0, 0)}))
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	if err := lib.CreateTopic(tn, TopicConfig{Retention: 10 * time.Millisecond}); err != nil {
+		t.FailNow()
+	}
+	_ = lib.PublishWithTTL(tn, []byte("long ttl"), time.Hour)
+	lib.Publish(tn, []byte("default"))
+	_ = lib.Advance(20 * time.Millisecond)
+	_ = lib.PublishWithTTL(tn, []byte("short ttl"), time.Millisecond)
+	lib.Publish(tn, []byte("fresh"))
+	_ = lib.Advance(5 * time.Millisecond)
+	if msgs, _ := lib.PollN(tn, sn, 10); len(msgs) != 1 || string(msgs[0]) != "fresh" {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_ConfigureTopic(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if lib.ConfigureTopic("a/#", TopicConfig{}) != ErrInvalidTopic {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	lib.SubscribeWithOptions(tn, "newest", Options{Overflow: DropNewest})
+	_ = lib.PublishWithPriority(tn, []byte("low"), 1)
+	_ = lib.PublishWithPriority(tn, []byte("high"), 5)
+	_ = lib.PublishWithPriority(tn, []byte("middle"), 3)
+	if err := lib.ConfigureTopic(tn, TopicConfig{Priority: true}); err != nil {
+		t.FailNow()
+	}
+	if msgs, _ := lib.PeekN(tn, sn, 10); len(msgs) != 3 || string(msgs[0]) != "high" || string(msgs[2]) != "low" {
+		t.FailNow()
+	}
+	// configuring again is allowed, lower limit removes the oldest messages of DropOldest subscriptions
+	if err := lib.ConfigureTopic(tn, TopicConfig{MaxMessages: 2, Priority: true}); err != nil {
+		t.FailNow()
+	}
+	if msgs, _ := lib.PeekN(tn, sn, 10); len(msgs) != 2 || string(msgs[0]) != "high" || string(msgs[1]) != "middle" {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth(tn, "newest"); depth != 3 {
+		t.FailNow()
+	}
+	if stats := lib.Stats(); stats.Topics[0].Subscriptions[1].Dropped != 1 {
+		t.FailNow()
+	}
+}
+
+func TestTopicConfig_MaxBytes(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	if err := lib.CreateTopic(tn, TopicConfig{MaxBytes: 5}); err != nil {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	lib.SubscribeWithOptions(tn, "reject", Options{Overflow: RejectPublish})
+	for _, m := range []string{"ab", "cd"} {
+		if err := lib.TryPublish(tn, []byte(m)); err != nil {
+			t.FailNow()
+		}
+	}
+	if err := lib.TryPublish(tn, []byte("ef")); err != ErrQueueFull {
+		t.FailNow()
+	}
+	lib.Unsubscribe(tn, "reject")
+	lib.Publish(tn, []byte("ef"))
+	if msgs, _ := lib.PeekN(tn, sn, 10); len(msgs) != 2 || string(msgs[0]) != "cd" {
+		t.FailNow()
+	}
+	// message bigger than the limit is dropped
+	lib.Publish(tn, []byte("too long"))
+	if msgs, _ := lib.PeekN(tn, sn, 10); len(msgs) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestWithStrictTopics(t *testing.T) {
+	lib := New(WithStrictTopics(true))
+	tn := "some topic"
+	sn := "subscriber/id"
+	if err := lib.TryPublish(tn, []byte("message")); err != ErrTopicNotFound {
+		t.FailNow()
+	}
+	if err := lib.ConfigureTopic(tn, TopicConfig{}); err != ErrTopicNotFound {
+		t.FailNow()
+	}
+	lib.Subscribe(tn, sn)
+	if _, err := lib.Poll(tn, sn); !errors.Is(err, ErrTopicNotFound) {
+		t.FailNow()
+	}
+	if _, err := lib.SubscribeChan(tn, sn, 0); err != ErrTopicNotFound {
+		t.FailNow()
+	}
+	if err := lib.SubscribeFrom(tn, sn, Beginning); err != ErrTopicNotFound {
+		t.FailNow()
+	}
+	lib.SetHistory(tn, 10)
+	if len(lib.Topics()) != 0 {
+		t.FailNow()
+	}
+	// wildcard subscriptions don't need topics
+	lib.Subscribe("some/#", sn)
+	if err := lib.CreateTopic(tn, TopicConfig{}); err != nil {
+		t.FailNow()
+	}
+	lib.SubscribeWithOptions(tn, sn, Options{MaxDeliveries: 1})
+	if err := lib.TryPublish(tn, []byte("message")); err != nil {
+		t.FailNow()
+	}
+	if b, err := lib.Poll(tn, sn); err != nil || string(b) != "message" {
+		t.FailNow()
+	}
+	if err := lib.TryPublish("some/topic", []byte("message")); err != ErrTopicNotFound {
+		t.FailNow()
+	}
+
+	var buf bytes.Buffer
+	if err := lib.Snapshot(&buf); err != nil {
+		t.FailNow()
+	}
+	restored := New(WithStrictTopics(true))
+	if err := restored.Restore(&buf); err != nil {
+		t.FailNow()
+	}
+	if err := restored.TryPublish(tn, []byte("message")); err != nil {
+		t.FailNow()
+	}
+	if b, err := restored.Poll(tn, sn); err != nil || string(b) != "message" {
+		t.FailNow()
+	}
+}
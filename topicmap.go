@@ -0,0 +1,88 @@
+package pubsub
+
+import "sync"
+
+// Number of buckets of topics map
+const topicBuckets = 64
+
+// Topics of broker by name spread between buckets by hash of name, so publishers and pollers of different topics
+// don't contend on one lock
+// Bucket lock is held only while its map is accessed, no other lock is taken under it
+// Topics are added and removed under pubSub.mux held for writing, so holders of pubSub.mux see a stable set of topics
+type topicMap struct {
+	buckets [topicBuckets]topicBucket
+}
+
+// Bucket of topics map, padded to a cache line, so neighbour buckets don't share it
+type topicBucket struct {
+	mux sync.RWMutex
+	hm  map[string]*subscriptions
+	_   [32]byte
+}
+
+// FNV-1a hash of string (s)
+func fnv32(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
+
+// Bucket of topic name (tn)
+func (t *topicMap) bucket(tn string) *topicBucket {
+	return &t.buckets[fnv32(tn)%topicBuckets]
+}
+
+// Subscriptions list of topic name (tn), false if there is no such topic
+func (t *topicMap) get(tn string) (*subscriptions, bool) {
+	b := t.bucket(tn)
+	b.mux.RLock()
+	subs, ok := b.hm[tn]
+	b.mux.RUnlock()
+	return subs, ok
+}
+
+// Adding subscriptions list (subs) of its topic, pubSub.mux must be held for writing by caller
+func (t *topicMap) add(subs *subscriptions) {
+	b := t.bucket(subs.tn)
+	b.mux.Lock()
+	if b.hm == nil {
+		b.hm = map[string]*subscriptions{}
+	}
+	b.hm[subs.tn] = subs
+	b.mux.Unlock()
+}
+
+// Removing topic name (tn), pubSub.mux must be held for writing by caller
+func (t *topicMap) remove(tn string) {
+	b := t.bucket(tn)
+	b.mux.Lock()
+	delete(b.hm, tn)
+	b.mux.Unlock()
+}
+
+// Subscriptions lists of all topics in no particular order
+func (t *topicMap) all() []*subscriptions {
+	var topics []*subscriptions
+	for i := range t.buckets {
+		b := &t.buckets[i]
+		b.mux.RLock()
+		for _, subs := range b.hm {
+			topics = append(topics, subs)
+		}
+		b.mux.RUnlock()
+	}
+	return topics
+}
+
+// Removing all topics, pubSub.mux must be held for writing by caller
+func (t *topicMap) clear() {
+	for i := range t.buckets {
+		b := &t.buckets[i]
+		b.mux.Lock()
+		b.hm = nil
+		b.mux.Unlock()
+	}
+}
@@ -0,0 +1,48 @@
+package pubsub
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestTopicMap(t *testing.T) {
+	var m topicMap
+	if _, ok := m.get("some topic"); ok || len(m.all()) != 0 {
+		t.FailNow()
+	}
+	var names []string
+	for i := 0; i < 200; i++ {
+		tn := fmt.Sprintf("topic %d", i)
+		names = append(names, tn)
+		m.add(&subscriptions{tn: tn})
+	}
+	for _, tn := range names {
+		if subs, ok := m.get(tn); !ok || subs.tn != tn {
+			t.Fatal(tn)
+		}
+	}
+	m.remove("topic 7")
+	if _, ok := m.get("topic 7"); ok || len(m.all()) != 199 {
+		t.FailNow()
+	}
+	var all []string
+	for _, subs := range m.all() {
+		all = append(all, subs.tn)
+	}
+	sort.Strings(all)
+	if all[0] != "topic 0" || all[len(all)-1] != "topic 99" {
+		t.Fatal(all)
+	}
+	m.clear()
+	if _, ok := m.get("topic 0"); ok || len(m.all()) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestFnv32(t *testing.T) {
+	// reference values of FNV-1a
+	if fnv32("") != 2166136261 || fnv32("a") != 0xe40c292c || fnv32("foobar") != 0xbf9cf968 {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// How often background sweeper removes expired messages
+const defaultSweepInterval = time.Second
+
+// Publish message (b) by topic name (tn) which is dropped if not polled during time-to-live (ttl)
+// Expired messages are never returned by Poll methods and are removed from memory by a background sweeper,
+// which is started on first call. Non-positive ttl means message never expires.
+// Overflow policies are applied like in TryPublish
+func (p *pubSub) PublishWithTTL(tn string, b []byte, ttl time.Duration) error {
+	m := p.newMessage(tn, Message{Body: b})
+	if ttl > 0 {
+		m.expires = p.now().Add(ttl)
+		p.startSweeper()
+	}
+	_, err := p.publish(tn, m)
+	return err
+}
+
+// Starting background sweeper if it isn't started yet
+// Broker with ManualClock is swept by Advance only
+func (p *pubSub) startSweeper() {
+	if p.manual() {
+		return
+	}
+	p.sweepOnce.Do(func() {
+		go p.sweeper()
+	})
+}
+
+// Removing expired messages and idle subscriptions and checking watchdog every p.sweepEvery
+func (p *pubSub) sweeper() {
+	ticker := time.NewTicker(p.sweepEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := p.now()
+			p.sweep(now)
+			p.expireIdle(now)
+			p.watch(now)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Removing messages expired at the moment now from subscription, they are counted as dropped
+func (s *subscription) dropExpired(now time.Time) {
+	if n := s.removeExpired(now); n > 0 {
+		atomic.AddUint64(&s.dropped, uint64(n))
+		s.log.Debug("expired messages dropped", "count", n)
+		s.emit(EventMessageDropped, nil, n, "expired")
+	}
+}
+
+// Removing messages expired at the moment now from all subscriptions
+// Topics are copied first, so locks of topics map aren't held while subscriptions are cleaned up
+func (p *pubSub) sweep(now time.Time) {
+	topics := p.topics.all()
+	for _, subs := range topics {
+		subs.mux.Lock()
+		for _, sub := range subs.hm {
+			sub.dropExpired(now)
+		}
+		subs.mux.Unlock()
+	}
+}
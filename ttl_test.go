@@ -0,0 +1,44 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPubSub_PublishWithTTL(t *testing.T) {
+	lib := New()
+	tn := "some topic"
+	sn := "subscriber/id"
+	lib.Subscribe(tn, sn)
+	if err := lib.PublishWithTTL(tn, []byte("short"), 10*time.Millisecond); err != nil {
+		t.FailNow()
+	}
+	if err := lib.PublishWithTTL(tn, []byte("long"), time.Hour); err != nil {
+		t.FailNow()
+	}
+	if err := lib.PublishWithTTL(tn, []byte("forever"), 0); err != nil {
+		t.FailNow()
+	}
+	time.Sleep(20 * time.Millisecond)
+	msgs, _ := lib.PollN(tn, sn, 10)
+	if len(msgs) != 2 || string(msgs[0]) != "long" || string(msgs[1]) != "forever" {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_sweep(t *testing.T) {
+	p := New().(*pubSub)
+	tn := "some topic"
+	sn := "subscriber/id"
+	p.Subscribe(tn, sn)
+	_ = p.PublishWithTTL(tn, []byte("short"), time.Minute)
+	_ = p.PublishWithTTL(tn, []byte("long"), time.Hour)
+	p.sweep(time.Now().Add(2 * time.Minute))
+	subs, _ := p.topics.get(tn)
+	subs.mux.Lock()
+	defer subs.mux.Unlock()
+	sub := subs.hm[sn]
+	if sub.len() != 1 || string(sub.items()[0].Body) != "long" {
+		t.FailNow()
+	}
+}
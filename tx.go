@@ -0,0 +1,231 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Error happens if transaction is used after PublishTx returned
+var ErrTxDone = errors.New("transaction is finished")
+
+// Tx collects messages of PublishTx, it must not be used concurrently or after PublishTx returned
+type Tx interface {
+	// Adding message (b) for topic name (tn) to the transaction
+	Publish(tn string, b []byte) error
+	// Adding message with headers for topic name (tn) to the transaction, returns message ID
+	PublishMsg(tn string, msg Message) (string, error)
+}
+
+// Message of transaction with subscriptions lists it's delivered to
+type txItem struct {
+	tn      string
+	m       *message
+	targets []*subscriptions
+}
+
+// Transaction of broker, publish middlewares are applied when message is added
+type tx struct {
+	p     *pubSub
+	items []txItem
+	done  bool
+}
+
+func (t *tx) Publish(tn string, b []byte) error {
+	_, err := t.PublishMsg(tn, Message{Body: b})
+	return err
+}
+
+func (t *tx) PublishMsg(tn string, msg Message) (string, error) {
+	if t.done {
+		return "", ErrTxDone
+	}
+	// messages of transaction aren't released, so they're never reused
+	m := t.p.newMessage(tn, msg)
+	m.formatID()
+	c := t.p.chain.Load()
+	if c == nil || len(c.publish) == 0 {
+		t.items = append(t.items, txItem{tn: tn, m: m})
+		return m.ID, nil
+	}
+	next := func(_ context.Context, tn string, msg *Message) error {
+		if msg != &m.Message {
+			m.Message = *msg
+		}
+		m.Topic = tn
+		t.items = append(t.items, txItem{tn: tn, m: m})
+		return nil
+	}
+	for i := len(c.publish) - 1; i >= 0; i-- {
+		next = c.publish[i](next)
+	}
+	return m.ID, next(context.Background(), tn, &m.Message)
+}
+
+// Publishing messages added by (fn) to the transaction atomically: subscribers see either all of them or none
+// Messages are published only if fn returns nil, then they are checked all together like in TryPublish (if some of
+// them would be rejected, none is published) and delivered in order they were added. Pollers see the messages only
+// after all of them are delivered
+// With WithNoSubscribersError the transaction fails with ErrNoSubscriptions if some message has no subscriptions
+// Duplicates (see TopicConfig.DedupWindow) are dropped, the rest of messages is published
+func (p *pubSub) PublishTx(fn func(tx Tx) error) error {
+	t := &tx{p: p}
+	defer func() { t.done = true }()
+	if err := fn(t); err != nil {
+		return err
+	}
+	t.done = true
+	if len(t.items) == 0 {
+		return nil
+	}
+	if p.closing.Load() {
+		return ErrClosed
+	}
+	delivered, err := p.commit(t.items)
+	if err != nil {
+		return err
+	}
+	for _, it := range delivered {
+		p.forward(it.tn, it.m)
+	}
+	return nil
+}
+
+// Delivering messages of transaction (items) under locks of all their subscriptions lists at once
+// Returns delivered items, duplicates are dropped
+func (p *pubSub) commit(items []txItem) ([]txItem, error) {
+	for _, it := range items {
+		if err := p.seal(it.tn, it.m); err != nil {
+			return nil, err
+		}
+	}
+	if p.maxMemory > 0 && p.memoryPolicy == DropOldest {
+		// runs after topics are unlocked
+		defer p.reclaim()
+	}
+	all, err := p.lockTx(items)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, subs := range all {
+			subs.mux.Unlock()
+		}
+	}()
+	now := p.now()
+	if items = dropDuplicates(items, now); len(items) == 0 {
+		return nil, nil
+	}
+	if err := p.admitTx(items); err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		dedupOf(it.tn, it.targets).remember(it.m, now)
+		p.push(it.tn, it.m, it.targets)
+	}
+	return items, nil
+}
+
+// Routing messages of transaction (items) and locking subscriptions lists of all of them, caller must unlock
+// returned lists. Lists are locked once each in order of topic names like in deliver, routing is repeated if some
+// topic was removed between lookup and locking
+func (p *pubSub) lockTx(items []txItem) ([]*subscriptions, error) {
+	for {
+		var all []*subscriptions
+		for i := range items {
+			it := &items[i]
+			subs, _ := p.topics.get(it.tn)
+			if p.strict && (subs == nil || subs.config.Load() == nil) {
+				return nil, ErrTopicNotFound
+			}
+			it.targets = p.route(it.tn, subs, it.m, nil)
+			all = append(all, it.targets...)
+		}
+		slices.SortFunc(all, func(a, b *subscriptions) int {
+			return strings.Compare(a.tn, b.tn)
+		})
+		all = slices.Compact(all)
+		for _, subs := range all {
+			subs.mux.Lock()
+		}
+		if !slices.ContainsFunc(all, func(subs *subscriptions) bool { return subs.deleted.Load() }) {
+			return all, nil
+		}
+		for _, subs := range all {
+			subs.mux.Unlock()
+		}
+	}
+}
+
+// Pending messages and bytes added to a subscription by a transaction
+type txUsage struct {
+	n     int
+	bytes int64
+}
+
+// Checks limits for all messages of transaction (items) together
+// Subscriptions lists of items must be locked by caller
+func (p *pubSub) admitTx(items []txItem) error {
+	added := map[*subscription]txUsage{}
+	need := map[*namespaceUsage]int64{}
+	memory := atomic.LoadInt64(&p.memory)
+	for _, it := range items {
+		size := int64(len(it.m.Body))
+		accepted := 0
+		for _, subs := range it.targets {
+			for _, sub := range subs.hm {
+				if !sub.accepts(it.m) {
+					continue
+				}
+				accepted++
+				u := added[sub]
+				if sub.opts.Overflow == RejectPublish {
+					max, maxBytes := sub.limit(), sub.maxBytes()
+					if max > 0 && sub.len()+u.n >= max || maxBytes > 0 && sub.bytes()+u.bytes+size > maxBytes {
+						sub.warnSlow()
+						return ErrQueueFull
+					}
+				}
+				added[sub] = txUsage{n: u.n + 1, bytes: u.bytes + size}
+				memory += size
+				if subs.ns != nil {
+					need[subs.ns] += size
+				}
+			}
+		}
+		if accepted == 0 && p.noSubsError {
+			return ErrNoSubscriptions
+		}
+	}
+	if p.maxMemory > 0 && p.memoryPolicy != DropOldest && memory > p.maxMemory {
+		p.log.Warn("memory limit is reached", "topic", items[0].tn, "max", p.maxMemory)
+		return ErrMemoryLimit
+	}
+	for ns, size := range need {
+		if ns.quota.MaxMemory > 0 && atomic.LoadInt64(&ns.memory)+size > ns.quota.MaxMemory {
+			p.log.Warn("namespace quota is exceeded", "topic", items[0].tn, "max_memory", ns.quota.MaxMemory)
+			return ErrQuotaExceeded
+		}
+	}
+	// tokens are taken only if rate limits of all topics allow the whole transaction
+	now := p.now()
+	tokens := map[*subscriptions]int{}
+	for _, it := range items {
+		for _, subs := range it.targets {
+			if subs.tn != it.tn || subs.publishLimit == nil {
+				continue
+			}
+			tokens[subs]++
+			if !subs.publishLimit.has(now, tokens[subs]) {
+				subs.log.Debug("message rejected", "id", it.m, "reason", "rate limit")
+				return ErrRateLimited
+			}
+		}
+	}
+	for subs, n := range tokens {
+		subs.publishLimit.take(n)
+	}
+	return nil
+}
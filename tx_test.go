@@ -0,0 +1,136 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestPubSub_PublishTx(t *testing.T) {
+	lib := New()
+	lib.Subscribe("orders", "billing")
+	lib.Subscribe("invoices", "billing")
+	lib.Subscribe("#", "audit")
+	err := lib.PublishTx(func(tx Tx) error {
+		if err := tx.Publish("orders", []byte("order")); err != nil {
+			return err
+		}
+		_, err := tx.PublishMsg("invoices", Message{Headers: map[string]string{"type": "x"}, Body: []byte("invoice")})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ := lib.Poll("orders", "billing"); string(msg) != "order" {
+		t.FailNow()
+	}
+	if msg, _ := lib.PollMsg("invoices", "billing"); msg == nil || msg.Headers["type"] != "x" || msg.Seq != 1 {
+		t.FailNow()
+	}
+	// messages are delivered in order of the transaction
+	if msgs, _ := lib.PollN("#", "audit", 10); len(msgs) != 2 || string(msgs[0]) != "order" || string(msgs[1]) != "invoice" {
+		t.FailNow()
+	}
+
+	// nothing is published if fn fails
+	var saved Tx
+	failure := errors.New("failure")
+	if err := lib.PublishTx(func(tx Tx) error {
+		saved = tx
+		tx.Publish("orders", []byte("order"))
+		return failure
+	}); err != failure {
+		t.FailNow()
+	}
+	if saved.Publish("orders", []byte("late")) != ErrTxDone {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth("orders", "billing"); depth != 0 {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_PublishTx_Rejected(t *testing.T) {
+	lib := New()
+	lib.Subscribe("orders", "billing")
+	lib.SubscribeWithOptions("invoices", "billing", Options{MaxMessages: 2, Overflow: RejectPublish})
+	lib.Publish("invoices", []byte("old"))
+	// the second invoice doesn't fit, so the whole transaction is rejected
+	err := lib.PublishTx(func(tx Tx) error {
+		tx.Publish("orders", []byte("order"))
+		tx.Publish("invoices", []byte("first"))
+		return tx.Publish("invoices", []byte("second"))
+	})
+	if err != ErrQueueFull {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth("orders", "billing"); depth != 0 {
+		t.FailNow()
+	}
+	if depth, _ := lib.Depth("invoices", "billing"); depth != 1 {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_PublishTx_RateLimited(t *testing.T) {
+	lib := New()
+	lib.Subscribe("orders", "billing")
+	lib.Subscribe("invoices", "billing")
+	_ = lib.CreateTopic("orders", TopicConfig{PublishRate: 0.001, PublishBurst: 2})
+	_ = lib.CreateTopic("invoices", TopicConfig{PublishRate: 0.001, PublishBurst: 1})
+	err := lib.PublishTx(func(tx Tx) error {
+		tx.Publish("orders", []byte("order"))
+		tx.Publish("invoices", []byte("first"))
+		return tx.Publish("invoices", []byte("second"))
+	})
+	if err != ErrRateLimited {
+		t.Fatal(err)
+	}
+	// tokens of rejected transaction aren't taken
+	for _, m := range []string{"a", "b"} {
+		if err := lib.TryPublish("orders", []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lib.TryPublish("invoices", []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPubSub_PublishTx_Atomic(t *testing.T) {
+	lib := New()
+	lib.Subscribe("a", "sub")
+	lib.Subscribe("b", "sub")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			_ = lib.PublishTx(func(tx Tx) error {
+				tx.Publish("a", []byte("x"))
+				return tx.Publish("b", []byte("x"))
+			})
+		}
+	}()
+	// depth of b is checked after depth of a, so it can't be lower
+	for i := 0; i < 1000; i++ {
+		da, _ := lib.Depth("a", "sub")
+		db, _ := lib.Depth("b", "sub")
+		if db < da {
+			t.Fatal(da, db)
+		}
+	}
+	wg.Wait()
+}
+
+func TestNamespace_PublishTx(t *testing.T) {
+	lib := New()
+	ns := lib.Namespace("tenant")
+	ns.Subscribe("orders", "billing")
+	if err := ns.PublishTx(func(tx Tx) error { return tx.Publish("orders", []byte("order")) }); err != nil {
+		t.FailNow()
+	}
+	if msg, _ := ns.Poll("orders", "billing"); string(msg) != "order" {
+		t.FailNow()
+	}
+}
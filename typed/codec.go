@@ -0,0 +1,25 @@
+package typed
+
+import "github.com/cejixo3/pubsub.git"
+
+// Codec converts values to message payloads and back
+// Unmarshal receives a pointer to the value, like json.Unmarshal
+// Codecs implementing pubsub.Codec also set content type header of published messages, so polled messages are
+// decoded with codec registered for their content type (see pubsub.RegisterCodec)
+// JSON, Gob, Binary and Proto codecs are shipped, MessagePack or CBOR are plugged the same way (see pubsub.Codec)
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+}
+
+// Error happens if value passed to Binary codec doesn't implement encoding.BinaryMarshaler/BinaryUnmarshaler
+var ErrNotBinary = pubsub.ErrNotBinary
+
+// JSON codec uses encoding/json
+type JSON = pubsub.JSONCodec
+
+// Gob codec uses encoding/gob, every message carries type information, so it's bigger than JSON for small values
+type Gob = pubsub.GobCodec
+
+// Binary codec uses encoding.BinaryMarshaler and encoding.BinaryUnmarshaler implemented by values
+type Binary = pubsub.BinaryCodec
@@ -0,0 +1,52 @@
+package typed
+
+import (
+	"errors"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Error happens if value passed to Proto codec isn't a protocol buffers message
+var ErrNotProto = errors.New("value isn't a protocol buffers message")
+
+// Proto codec uses google.golang.org/protobuf, content type "application/x-protobuf"
+// T must be a pointer type generated by protoc-gen-go, e.g. New[*pb.Order](broker, typed.Proto{})
+// It isn't registered by default, pubsub.RegisterCodec(typed.Proto{}) lets other layers decode its messages
+type Proto struct{}
+
+func (Proto) ContentType() string { return "application/x-protobuf" }
+
+func (Proto) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProto
+	}
+	return proto.Marshal(m)
+}
+
+func (Proto) Unmarshal(b []byte, v interface{}) error {
+	m, ok := protoMessage(v)
+	if !ok {
+		return ErrNotProto
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// Message which (v) points to, the message is allocated if (v) points to a nil pointer of generated type
+func protoMessage(v interface{}) (proto.Message, bool) {
+	if m, ok := v.(proto.Message); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+		return nil, false
+	}
+	if _, ok := rv.Elem().Interface().(proto.Message); !ok {
+		return nil, false
+	}
+	if rv.Elem().IsNil() {
+		rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+	}
+	return rv.Elem().Interface().(proto.Message), true
+}
@@ -0,0 +1,91 @@
+/*
+	Generic wrapper over pubsub.PubSuber which marshals values of type T to []byte messages with a pluggable Codec.
+
+		orders := typed.New[Order](broker, typed.JSON{})
+		err := orders.Publish("orders", Order{ID: 1})
+		order, err := orders.Poll("orders", "billing")
+*/
+package typed
+
+import (
+	"context"
+	"encoding"
+	"errors"
+
+	"github.com/cejixo3/pubsub.git"
+)
+
+// Error happens if subscription has no pending messages
+var ErrNoMessages = errors.New("there are no messages")
+
+// Typed publishes and polls values of type T through a broker
+type Typed[T any] struct {
+	ps    pubsub.PubSuber
+	codec Codec
+}
+
+// Constructor. Creates a Typed wrapper over broker (ps) using codec for marshalling
+func New[T any](ps pubsub.PubSuber, codec Codec) *Typed[T] {
+	return &Typed[T]{ps: ps, codec: codec}
+}
+
+// Publish value (v) by topic name (tn), errors of marshalling and TryPublish are returned
+// Message gets pubsub.HeaderContentType header if codec implements pubsub.Codec
+func (t *Typed[T]) Publish(tn string, v T) error {
+	b, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c, ok := t.codec.(pubsub.Codec)
+	if !ok {
+		return t.ps.TryPublish(tn, b)
+	}
+	headers := map[string]string{pubsub.HeaderContentType: c.ContentType()}
+	_, err = t.ps.PublishMsg(tn, pubsub.Message{Headers: headers, Body: b})
+	return err
+}
+
+// Fetching value for topic name (tn) and subscriber name (sn)
+// ErrNoMessages raises if all messages was fetched already
+func (t *Typed[T]) Poll(tn, sn string) (T, error) {
+	msg, err := t.ps.PollMsg(tn, sn)
+	if err == nil && msg == nil || errors.Is(err, pubsub.ErrEmpty) {
+		err = ErrNoMessages
+	}
+	return t.decode(msg, err)
+}
+
+// Waiting for a value for topic name (tn) and subscriber name (sn) until it arrives or ctx is done
+func (t *Typed[T]) PollWait(ctx context.Context, tn, sn string) (T, error) {
+	return t.decode(t.ps.PollMsgWait(ctx, tn, sn))
+}
+
+// Unmarshalling message (msg) if polling error (err) is nil
+// Codec registered for content type of the message is used if there is such and it decodes T, codec of Typed otherwise
+func (t *Typed[T]) decode(msg *pubsub.Message, err error) (T, error) {
+	var v T
+	if err != nil {
+		return v, err
+	}
+	var codec Codec = t.codec
+	if ct, ok := msg.Headers[pubsub.HeaderContentType]; ok {
+		if c := pubsub.CodecFor(ct); c != nil && decodes(c, &v) {
+			codec = c
+		}
+	}
+	err = codec.Unmarshal(msg.Body, &v)
+	return v, err
+}
+
+// Whether codec (c) is able to unmarshal into pointer (v), Binary and Proto codecs decode only types of their own
+func decodes(c Codec, v interface{}) bool {
+	switch c.(type) {
+	case Binary:
+		_, ok := v.(encoding.BinaryUnmarshaler)
+		return ok
+	case Proto:
+		_, ok := protoMessage(v)
+		return ok
+	}
+	return true
+}
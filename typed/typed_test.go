@@ -0,0 +1,129 @@
+package typed
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cejixo3/pubsub.git"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type order struct {
+	ID    int
+	Items []string
+}
+
+func TestTyped_PublishPoll(t *testing.T) {
+	for name, codec := range map[string]Codec{"json": JSON{}, "gob": Gob{}} {
+		ps := pubsub.New()
+		orders := New[order](ps, codec)
+		if _, err := orders.Poll("orders", "billing"); !errors.Is(err, pubsub.ErrNoSubscriptions) {
+			t.Fatal(name, err)
+		}
+		ps.Subscribe("orders", "billing")
+		if _, err := orders.Poll("orders", "billing"); err != ErrNoMessages {
+			t.Fatal(name, err)
+		}
+		if err := orders.Publish("orders", order{ID: 1, Items: []string{"book"}}); err != nil {
+			t.Fatal(name, err)
+		}
+		o, err := orders.Poll("orders", "billing")
+		if err != nil || o.ID != 1 || len(o.Items) != 1 || o.Items[0] != "book" {
+			t.Fatal(name, o, err)
+		}
+	}
+}
+
+func TestTyped_PollWait(t *testing.T) {
+	ps := pubsub.New()
+	ps.Subscribe("numbers", "sum")
+	numbers := New[int](ps, JSON{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = numbers.Publish("numbers", 42)
+	}()
+	if n, err := numbers.PollWait(context.Background(), "numbers", "sum"); err != nil || n != 42 {
+		t.Fatal(n, err)
+	}
+}
+
+type version struct {
+	major, minor byte
+}
+
+func (v version) MarshalBinary() ([]byte, error) {
+	return []byte{v.major, v.minor}, nil
+}
+
+func (v *version) UnmarshalBinary(b []byte) error {
+	v.major, v.minor = b[0], b[1]
+	return nil
+}
+
+func TestBinary(t *testing.T) {
+	ps := pubsub.New()
+	ps.Subscribe("versions", "sub")
+	versions := New[version](ps, Binary{})
+	_ = versions.Publish("versions", version{1, 2})
+	if v, err := versions.Poll("versions", "sub"); err != nil || v.major != 1 || v.minor != 2 {
+		t.Fatal(v, err)
+	}
+	if err := New[int](ps, Binary{}).Publish("versions", 1); err != ErrNotBinary {
+		t.Fatal(err)
+	}
+}
+
+func TestTyped_ContentType(t *testing.T) {
+	ps := pubsub.New()
+	ps.Subscribe("orders", "billing")
+	_ = New[order](ps, Gob{}).Publish("orders", order{ID: 1})
+	// JSON body published without typed wrapper
+	_, _ = ps.PublishMsg("orders", pubsub.Message{
+		Headers: map[string]string{pubsub.HeaderContentType: "application/json"},
+		Body:    []byte(`{"ID": 2}`),
+	})
+	orders := New[order](ps, JSON{})
+	for _, id := range []int{1, 2} {
+		if o, err := orders.Poll("orders", "billing"); err != nil || o.ID != id {
+			t.Fatal(o, err)
+		}
+	}
+}
+
+func TestTyped_ContentTypeFallback(t *testing.T) {
+	ps := pubsub.New()
+	ps.Subscribe("orders", "billing")
+	// registered Binary codec can't decode order, so codec of the wrapper is used
+	_, _ = ps.PublishMsg("orders", pubsub.Message{
+		Headers: map[string]string{pubsub.HeaderContentType: "application/octet-stream"},
+		Body:    []byte(`{"ID": 1}`),
+	})
+	if o, err := New[order](ps, JSON{}).Poll("orders", "billing"); err != nil || o.ID != 1 {
+		t.Fatal(o, err)
+	}
+}
+
+func TestProto(t *testing.T) {
+	ps := pubsub.New()
+	ps.Subscribe("names", "sub")
+	ps.Subscribe("names", "raw")
+	names := New[*wrapperspb.StringValue](ps, Proto{})
+	if err := names.Publish("names", wrapperspb.String("name")); err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := ps.PollMsg("names", "raw")
+	if msg == nil || msg.Headers[pubsub.HeaderContentType] != "application/x-protobuf" {
+		t.Fatal(msg)
+	}
+	if v, err := names.Poll("names", "sub"); err != nil || v.GetValue() != "name" {
+		t.Fatal(v, err)
+	}
+	if err := New[order](ps, Proto{}).Publish("names", order{}); err != ErrNotProto {
+		t.Fatal(err)
+	}
+	if err := (Proto{}).Unmarshal(nil, &order{}); err != ErrNotProto {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error happens if Validator of topic rejects published message, it wraps the error of Validator
+var ErrInvalidMessage = errors.New("invalid message")
+
+// Validator checks messages published to topics it's set for with SetValidator, e.g. against JSON Schema or
+// protobuf descriptor. Message must not be modified, validator must be safe for concurrent use
+type Validator interface {
+	Validate(tn string, msg *Message) error
+}
+
+// ValidatorFunc is a function implementing Validator
+type ValidatorFunc func(tn string, msg *Message) error
+
+func (f ValidatorFunc) Validate(tn string, msg *Message) error {
+	return f(tn, msg)
+}
+
+// Validators by topic names and patterns, replaced as a whole (copy on write)
+type validators map[string]Validator
+
+// Setting validator (v) of messages published to topic name (tn), which may be a wildcard pattern, nil removes it
+// Messages rejected by v aren't published, publishing methods return ErrInvalidMessage wrapping the error of v.
+// Validators run after publish middlewares, before message is stored, so invalid messages never reach queues.
+// Every validator matching the topic must accept the message. ErrInvalidTopic raises if tn is empty
+func (p *pubSub) SetValidator(tn string, v Validator) error {
+	if tn == "" {
+		return ErrInvalidTopic
+	}
+	p.validatorsMux.Lock()
+	defer p.validatorsMux.Unlock()
+	vs := validators{}
+	if old := p.validators.Load(); old != nil {
+		for pattern, v := range *old {
+			vs[pattern] = v
+		}
+	}
+	if v == nil {
+		delete(vs, tn)
+	} else {
+		vs[tn] = v
+	}
+	p.validators.Store(&vs)
+	return nil
+}
+
+// Checking message (m) published to topic name (tn) with validators of the topic
+func (p *pubSub) validate(tn string, m *message) error {
+	vs := p.validators.Load()
+	if vs == nil || len(*vs) == 0 {
+		return nil
+	}
+	for pattern, v := range *vs {
+		if pattern != tn && !(isPattern(pattern) && matchPattern(pattern, tn)) {
+			continue
+		}
+		// validators see ID of message and may keep it, so it isn't reused
+		m.formatID()
+		m.pin()
+		if err := v.Validate(tn, &m.Message); err != nil {
+			p.log.Debug("message rejected", "topic", tn, "id", m, "reason", "invalid", "error", err)
+			return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+		}
+	}
+	return nil
+}
+
+// Setting validator (v) of topic name (tn) of the namespace, v gets topic names of the namespace
+func (n *namespace) SetValidator(tn string, v Validator) error {
+	if tn == "" {
+		return ErrInvalidTopic
+	}
+	if v == nil {
+		return n.p.SetValidator(n.topic(tn), nil)
+	}
+	return n.p.SetValidator(n.topic(tn), ValidatorFunc(func(tn string, msg *Message) error {
+		local, _ := n.local(tn)
+		scoped := *msg
+		scoped.Topic = local
+		return v.Validate(local, &scoped)
+	}))
+}
+
+// Setting validator (v) of topic name (tn) if principal may manage the topic
+func (a *authorized) SetValidator(tn string, v Validator) error {
+	if err := a.allow(OpManage, tn, ""); err != nil {
+		return err
+	}
+	return a.next.SetValidator(tn, v)
+}
@@ -0,0 +1,77 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// Validator accepting JSON objects only
+var jsonValidator = ValidatorFunc(func(tn string, msg *Message) error {
+	var v map[string]any
+	return json.Unmarshal(msg.Body, &v)
+})
+
+func TestPubSub_SetValidator(t *testing.T) {
+	lib := New()
+	if lib.SetValidator("", jsonValidator) != ErrInvalidTopic {
+		t.FailNow()
+	}
+	lib.SetValidator("orders/+", jsonValidator)
+	lib.Subscribe("orders/eu", "billing")
+	lib.Subscribe("audit", "billing")
+	if err := lib.TryPublish("orders/eu", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	err := lib.TryPublish("orders/eu", []byte("not json"))
+	var syntaxErr *json.SyntaxError
+	if !errors.Is(err, ErrInvalidMessage) || !errors.As(err, &syntaxErr) {
+		t.Fatal(err)
+	}
+	if _, err := lib.PublishMsg("orders/eu", Message{Body: []byte("[]")}); !errors.Is(err, ErrInvalidMessage) {
+		t.Fatal(err)
+	}
+	if err := lib.PublishRetained("orders/eu", []byte("x")); !errors.Is(err, ErrInvalidMessage) {
+		t.Fatal(err)
+	}
+	err = lib.PublishTx(func(tx Tx) error {
+		tx.Publish("audit", []byte("x"))
+		return tx.Publish("orders/eu", []byte("x"))
+	})
+	if !errors.Is(err, ErrInvalidMessage) {
+		t.Fatal(err)
+	}
+	// other topics aren't validated
+	if err := lib.TryPublish("audit", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if depth, _ := lib.Depth("orders/eu", "billing"); depth != 1 {
+		t.Fatal(depth)
+	}
+	if depth, _ := lib.Depth("audit", "billing"); depth != 1 {
+		t.Fatal(depth)
+	}
+	lib.SetValidator("orders/+", nil)
+	if err := lib.TryPublish("orders/eu", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamespace_SetValidator(t *testing.T) {
+	lib := New()
+	ns := lib.Namespace("tenant")
+	var topic string
+	ns.SetValidator("orders", ValidatorFunc(func(tn string, msg *Message) error {
+		topic = tn + " " + msg.Topic
+		return jsonValidator(tn, msg)
+	}))
+	ns.Subscribe("orders", "billing")
+	if err := ns.TryPublish("orders", []byte("x")); !errors.Is(err, ErrInvalidMessage) || topic != "orders orders" {
+		t.Fatal(err, topic)
+	}
+	// the same topic name out of the namespace
+	lib.Subscribe("orders", "billing")
+	if err := lib.TryPublish("orders", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+package pubsub
+
+import "time"
+
+// Thresholds of slow subscriptions checked by WithWatchdog and callbacks called when subscriptions cross them
+// MaxDepth - number of pending messages, MaxAge - age of the next message to be delivered, zero means unchecked
+// OnSlow is called once when subscription exceeds any threshold, OnRecover when it's within all of them again,
+// both get topic name and counters of the subscription. Callbacks are called by background sweeper without locks
+// held, so they may use broker, e.g. Pause or Unsubscribe the subscription
+type Watchdog struct {
+	MaxDepth  int
+	MaxAge    time.Duration
+	OnSlow    func(tn string, s SubscriptionStats)
+	OnRecover func(tn string, s SubscriptionStats)
+}
+
+// Checking subscriptions against thresholds (w) every sweep interval (second) by background sweeper, so operators
+// learn about stuck pollers before memory is exhausted. Crossing thresholds is logged as warning
+// and emitted as EventSlowSubscriber too
+// Broker with ManualClock checks subscriptions on Advance
+func WithWatchdog(w Watchdog) Option {
+	return func(p *pubSub) {
+		p.watchdog = &w
+	}
+}
+
+// Subscription which crossed thresholds of watchdog
+type watched struct {
+	tn    string
+	stats SubscriptionStats
+	slow  bool
+}
+
+// Checking all subscriptions at the moment now and calling callbacks of watchdog for changed ones
+func (p *pubSub) watch(now time.Time) {
+	w := p.watchdog
+	if w == nil || w.MaxDepth <= 0 && w.MaxAge <= 0 {
+		return
+	}
+	topics := p.topics.all()
+	var changed []watched
+	for _, subs := range topics {
+		subs.mux.Lock()
+		for _, sub := range subs.hm {
+			if slow := sub.lags(w, now); slow != sub.lagging {
+				sub.lagging = slow
+				changed = append(changed, watched{tn: subs.tn, stats: sub.stats(now), slow: slow})
+			}
+		}
+		subs.mux.Unlock()
+	}
+	for _, c := range changed {
+		if c.slow {
+			p.log.Warn("subscription is slow", "topic", c.tn, "subscription", c.stats.Name,
+				"pending", c.stats.Pending, "age", c.stats.OldestAge)
+			if p.events.active.Load() {
+				p.events.emit(Event{Type: EventSlowSubscriber, Topic: c.tn, Subscription: c.stats.Name,
+					Reason: "watchdog", Time: now})
+			}
+			if w.OnSlow != nil {
+				w.OnSlow(c.tn, c.stats)
+			}
+		} else if w.OnRecover != nil {
+			w.OnRecover(c.tn, c.stats)
+		}
+	}
+}
+
+// Checks if subscription exceeds any threshold of watchdog (w) at the moment now
+// Lock of subscription must be held by caller
+func (s *subscription) lags(w *Watchdog, now time.Time) bool {
+	if w.MaxDepth > 0 && s.len() > w.MaxDepth {
+		return true
+	}
+	if w.MaxAge <= 0 {
+		return false
+	}
+	next := s.peek(1)
+	return len(next) > 0 && now.Sub(next[0].PublishedAt) > w.MaxAge
+}
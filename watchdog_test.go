@@ -0,0 +1,45 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithWatchdog(t *testing.T) {
+	c := &stepClock{now: time.Unix(0, 0)}
+	var slow, recovered []string
+	lib := New(WithClock(c), WithWatchdog(Watchdog{
+		MaxDepth: 2,
+		MaxAge:   time.Minute,
+		OnSlow: func(tn string, s SubscriptionStats) {
+			slow = append(slow, tn+"/"+s.Name)
+		},
+		OnRecover: func(tn string, s SubscriptionStats) {
+			recovered = append(recovered, tn+"/"+s.Name)
+		},
+	}))
+	lib.Subscribe("orders", "billing")
+	lib.Subscribe("events", "audit")
+	for i := 0; i < 3; i++ {
+		lib.Publish("orders", []byte("order"))
+	}
+	lib.Publish("events", []byte("event"))
+	_ = lib.Advance(time.Second)
+	if len(slow) != 1 || slow[0] != "orders/billing" {
+		t.Fatal(slow)
+	}
+	// reported once
+	_ = lib.Advance(time.Second)
+	if len(slow) != 1 {
+		t.Fatal(slow)
+	}
+	_, _ = lib.Poll("orders", "billing")
+	_ = lib.Advance(time.Second)
+	if len(recovered) != 1 || recovered[0] != "orders/billing" {
+		t.Fatal(recovered)
+	}
+	_ = lib.Advance(time.Minute)
+	if len(slow) != 3 {
+		t.Fatal(slow)
+	}
+}
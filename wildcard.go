@@ -0,0 +1,139 @@
+package pubsub
+
+import (
+	"slices"
+	"strings"
+)
+
+// Topic levels separator and wildcards
+const (
+	levelSeparator = "/"
+	singleLevel    = "+"
+	multiLevel     = "#"
+)
+
+// Checks if topic name (tn) is a valid wildcard pattern
+func isPattern(tn string) bool {
+	if !strings.ContainsAny(tn, singleLevel+multiLevel) {
+		return false
+	}
+	levels := strings.Split(tn, levelSeparator)
+	for i, level := range levels {
+		switch {
+		case level == multiLevel && i != len(levels)-1:
+			return false
+		case level != singleLevel && level != multiLevel && strings.ContainsAny(level, singleLevel+multiLevel):
+			return false
+		}
+	}
+	return true
+}
+
+// Checks if topic name (tn) is matched by topic name or wildcard pattern (pattern) like subscriptions match it
+func MatchTopic(pattern, tn string) bool {
+	if !isPattern(pattern) {
+		return pattern == tn
+	}
+	var t topicTree
+	t.insert(pattern)
+	return len(t.match(tn)) > 0
+}
+
+// Trie of wildcard topic names by levels, node keeps pattern if some pattern ends at it
+type topicTree struct {
+	children map[string]*topicTree
+	pattern  string
+}
+
+// Adding pattern to the tree
+func (t *topicTree) insert(pattern string) {
+	node := t
+	for _, level := range strings.Split(pattern, levelSeparator) {
+		if node.children == nil {
+			node.children = map[string]*topicTree{}
+		}
+		child, ok := node.children[level]
+		if !ok {
+			child = &topicTree{}
+			node.children[level] = child
+		}
+		node = child
+	}
+	node.pattern = pattern
+}
+
+// Removing pattern from the tree, empty branches are removed too
+func (t *topicTree) remove(pattern string) {
+	t.removeLevels(strings.Split(pattern, levelSeparator))
+}
+
+// Removing rest of pattern (levels) from subtree, returns true if subtree became empty
+func (t *topicTree) removeLevels(levels []string) bool {
+	if len(levels) == 0 {
+		t.pattern = ""
+	} else if child, ok := t.children[levels[0]]; ok && child.removeLevels(levels[1:]) {
+		delete(t.children, levels[0])
+	}
+	return t.pattern == "" && len(t.children) == 0
+}
+
+// Collecting patterns matching topic name (tn)
+// Topics starting with "$" are matched only by patterns with the same first level (like "$SYS/#")
+func (t *topicTree) match(tn string) []string {
+	var patterns []string
+	if len(t.children) == 0 {
+		return patterns
+	}
+	levels := strings.Split(tn, levelSeparator)
+	if strings.HasPrefix(tn, "$") {
+		if child, ok := t.children[levels[0]]; ok {
+			child.matchLevels(levels[1:], &patterns)
+		}
+		return patterns
+	}
+	t.matchLevels(levels, &patterns)
+	return patterns
+}
+
+// Collecting patterns of subtree matching rest of topic name (levels)
+func (t *topicTree) matchLevels(levels []string, patterns *[]string) {
+	if child, ok := t.children[multiLevel]; ok {
+		*patterns = append(*patterns, child.pattern)
+	}
+	if len(levels) == 0 {
+		if t.pattern != "" {
+			*patterns = append(*patterns, t.pattern)
+		}
+		return
+	}
+	if child, ok := t.children[levels[0]]; ok {
+		child.matchLevels(levels[1:], patterns)
+	}
+	if child, ok := t.children[singleLevel]; ok && levels[0] != singleLevel {
+		child.matchLevels(levels[1:], patterns)
+	}
+}
+
+// Appending subscriptions lists of topic name (tn) (subs, nil if there is no such topic) and of wildcard topics
+// matching it to (targets), they are sorted by topic names
+// p.mux is taken for reading only if there are wildcard topics, so caller must not hold it
+func (p *pubSub) match(tn string, subs *subscriptions, targets []*subscriptions) []*subscriptions {
+	if subs != nil {
+		targets = append(targets, subs)
+	}
+	if p.patterns.Load() > 0 {
+		p.mux.RLock()
+		for _, pattern := range p.wildcards.match(tn) {
+			if subs, ok := p.topics.get(pattern); ok && pattern != tn {
+				targets = append(targets, subs)
+			}
+		}
+		p.mux.RUnlock()
+	}
+	if len(targets) > 1 {
+		slices.SortFunc(targets, func(a, b *subscriptions) int {
+			return strings.Compare(a.tn, b.tn)
+		})
+	}
+	return targets
+}
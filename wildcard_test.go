@@ -0,0 +1,117 @@
+package pubsub
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIsPattern(t *testing.T) {
+	for tn, expected := range map[string]bool{
+		"orders/created":   false,
+		"orders/+/created": true,
+		"orders/#":         true,
+		"#":                true,
+		"+":                true,
+		"orders+":          false,
+		"orders/#/created": false,
+		"orders/a#":        false,
+	} {
+		if isPattern(tn) != expected {
+			t.Errorf("isPattern(%q) != %v", tn, expected)
+		}
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		pattern, tn string
+		match       bool
+	}{
+		{"orders", "orders", true},
+		{"orders", "orders/eu", false},
+		{"orders/+", "orders/eu", true},
+		{"orders/+", "orders/eu/paid", false},
+		{"orders/#", "orders/eu/paid", true},
+		{"#", "$SYS/load", false},
+		{"$SYS/#", "$SYS/load", true},
+	}
+	for _, c := range cases {
+		if MatchTopic(c.pattern, c.tn) != c.match {
+			t.Fatal(c)
+		}
+	}
+}
+
+func TestTopicTree(t *testing.T) {
+	var tree topicTree
+	for _, pattern := range []string{"orders/+/created", "orders/#", "#", "+/+", "orders/+", "$SYS/+"} {
+		tree.insert(pattern)
+	}
+	for tn, expected := range map[string][]string{
+		"orders/1/created": {"#", "orders/#", "orders/+/created"},
+		"orders/1":         {"#", "+/+", "orders/#", "orders/+"},
+		"orders":           {"#", "orders/#"},
+		"users/1/created":  {"#"},
+		"$SYS/orders":      {"$SYS/+"},
+		"$SYS":             nil,
+	} {
+		got := tree.match(tn)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("match(%q) = %v, expected %v", tn, got, expected)
+		}
+	}
+	tree.remove("orders/+/created")
+	tree.remove("orders/#")
+	if got := tree.match("orders/1/created"); !reflect.DeepEqual(got, []string{"#"}) {
+		t.Errorf("match after remove = %v", got)
+	}
+	tree.remove("#")
+	tree.remove("+/+")
+	tree.remove("orders/+")
+	tree.remove("$SYS/+")
+	if len(tree.children) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestPubSub_WildcardSubscribe(t *testing.T) {
+	lib := New()
+	sn := "subscriber/id"
+	lib.Subscribe("orders/+/created", sn)
+	lib.Subscribe("orders/#", sn)
+	lib.Subscribe("orders/1/created", sn)
+	lib.Publish("orders/1/created", []byte("a"))
+	lib.Publish("orders/2/deleted", []byte("b"))
+	lib.Publish("users/1/created", []byte("c"))
+	for tn, expected := range map[string][]string{
+		"orders/+/created": {"a"},
+		"orders/#":         {"a", "b"},
+		"orders/1/created": {"a"},
+	} {
+		msgs, err := lib.PollN(tn, sn, 10)
+		if err != nil || len(msgs) != len(expected) {
+			t.Fatalf("%s: %v %v", tn, msgs, err)
+		}
+		for i := range msgs {
+			if string(msgs[i]) != expected[i] {
+				t.Fatalf("%s: %v", tn, msgs)
+			}
+		}
+	}
+}
+
+func TestPubSub_WildcardRejectPublish(t *testing.T) {
+	lib := New()
+	sn := "subscriber/id"
+	lib.SubscribeWithOptions("orders/#", sn, Options{MaxMessages: 1, Overflow: RejectPublish})
+	lib.Subscribe("orders/1", sn)
+	_ = lib.TryPublish("orders/1", []byte("a"))
+	if err := lib.TryPublish("orders/1", []byte("b")); err != ErrQueueFull {
+		t.FailNow()
+	}
+	if n, _ := lib.Depth("orders/1", sn); n != 1 {
+		t.FailNow()
+	}
+}